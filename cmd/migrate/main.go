@@ -0,0 +1,191 @@
+// Package main provides the command that migrates the schema of the database of the application.
+package main
+
+import (
+	"context"                                                                                     // Context package provides the functionality to start and stop the application.
+	"errors"                                                                                      // Errors package provides the functionality to create the usage errors.
+	"fmt"                                                                                         // Fmt package provides the functionality to print the results of the command.
+	"github.com/nikita-voronoy/go-clean-arch/config"                                              // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/app"                                        // App package provides the migrations group of the modules.
+	auditmodule "github.com/nikita-voronoy/go-clean-arch/internal/modules/audit/module"           // Module package provides the migrations of the audit module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth/module"                        // Module package provides the migrations of the auth module.
+	invitationmodule "github.com/nikita-voronoy/go-clean-arch/internal/modules/invitation/module" // Module package provides the migrations of the invitation module.
+	orgmodule "github.com/nikita-voronoy/go-clean-arch/internal/modules/organization/module"      // Module package provides the migrations of the organization module.
+	outboxmodule "github.com/nikita-voronoy/go-clean-arch/internal/modules/outbox/module"         // Module package provides the migrations of the outbox module.
+	webhookmodule "github.com/nikita-voronoy/go-clean-arch/internal/modules/webhook/module"       // Module package provides the migrations of the webhook module.
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"                                        // Database package provides the functionality to interact with the database of the application.
+	"github.com/nikita-voronoy/go-clean-arch/pkg/migrate"                                         // Migrate package provides the functionality to apply the versioned SQL migrations.
+	"go.uber.org/fx"                                                                              // Fx is a framework for Go that provides the tools needed to build a dependency graph and invoke components in the correct order.
+	"os"                                                                                          // OS package provides the functionality to read the arguments and exit with a status.
+	"path/filepath"                                                                               // Filepath package provides the functionality to build the path of the migrations directory of a module.
+	"strconv"                                                                                     // Strconv package provides the functionality to parse the number of steps.
+	"text/tabwriter"                                                                              // Tabwriter package provides the functionality to print the status as a table.
+	"time"                                                                                        // Time package provides the functionality to version the new migrations.
+)
+
+// usage describes the commands.
+const usage = `usage: migrate <command> [arguments]
+
+commands:
+  up [N]                 applies the pending migrations, or the next N
+  down [N]               reverts the last applied migration, or the last N
+  status                 lists the migrations and whether they are applied
+  create <module> <name> writes the up and down files of a new migration of the module for every dialect
+  reencrypt              encrypts the sensitive fields of the stored records with the primary encryption key`
+
+// reencryptBatch is the number of records the reencrypt command reads at once.
+const reencryptBatch = 500
+
+// dialects are the dialects a new migration is written for.
+var dialects = []string{"sqlite", "postgres", "mysql"}
+
+// main function is the entry point for the command.
+// It reads the configuration from the config directory, like the server, and migrates the configured database.
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run runs the command.
+// args: The command and its arguments.
+// Returns an error if the arguments are invalid or the command fails.
+func run(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	if args[0] == "create" {
+		if len(args) != 3 {
+			return errors.New(usage)
+		}
+		dir := filepath.Join("internal", "modules", args[1])
+		if _, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("unknown module %q, run the command from the root of the repository: %w", args[1], err)
+		}
+		paths, err := migrate.Create(filepath.Join(dir, "migrations"), args[2], dialects, time.Now())
+		for _, path := range paths {
+			fmt.Println("Created", path)
+		}
+		return err
+	}
+
+	n, err := steps(args)
+	if err != nil {
+		return err
+	}
+
+	var (
+		cfg        *config.Config
+		db         database.Database
+		migrations app.Migrations
+	)
+	application := fx.New(
+		fx.NopLogger,
+		fx.Provide(
+			config.NewConfig,     // Provides the configuration of the application.
+			database.NewDatabase, // Provides the database of the application.
+		),
+		auditmodule.Migrations,      // Registers the migrations of the audit module.
+		module.Migrations,           // Registers the migrations of the auth module.
+		orgmodule.Migrations,        // Registers the migrations of the organization module.
+		invitationmodule.Migrations, // Registers the migrations of the invitation module.
+		outboxmodule.Migrations,     // Registers the migrations of the outbox module.
+		webhookmodule.Migrations,    // Registers the migrations of the webhook module.
+		fx.Populate(&cfg, &db, &migrations),
+	)
+	ctx := context.Background()
+	if err := application.Start(ctx); err != nil {
+		return err
+	}
+	defer func() { _ = application.Stop(ctx) }()
+
+	if args[0] == "reencrypt" {
+		return reencrypt(ctx, cfg, db)
+	}
+	migrator, err := database.NewMigrator(db, migrations.Sources...)
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx, n)
+		for _, migration := range applied {
+			fmt.Printf("Applied %s (%s)\n", migration, migration.Module)
+		}
+		return err
+	case "down":
+		reverted, err := migrator.Down(ctx, n)
+		for _, migration := range reverted {
+			fmt.Printf("Reverted %s (%s)\n", migration, migration.Module)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tMODULE\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			switch {
+			case status.Missing:
+				appliedAt = status.AppliedAt.UTC().Format(time.RFC3339) + " (files missing)"
+			case !status.AppliedAt.IsZero():
+				appliedAt = status.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, status.Module, status.Name, appliedAt)
+		}
+		return w.Flush()
+	}
+	return nil
+}
+
+// steps parses the optional number of migrations of the up and down commands.
+// By default, up applies every pending migration and down reverts the last applied migration.
+// args: The command and its arguments.
+// Returns the number of migrations, and an error if the command is unknown or the number is not a positive number.
+func steps(args []string) (int, error) {
+	fallback := map[string]int{"up": 0, "down": 1, "status": 0, "reencrypt": 0}
+	n, ok := fallback[args[0]]
+	switch {
+	case !ok || ((args[0] == "status" || args[0] == "reencrypt") && len(args) > 1):
+		return 0, errors.New(usage)
+	case len(args) == 1:
+		return n, nil
+	case len(args) == 2:
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid number of migrations %q", args[1])
+		}
+		return n, nil
+	default:
+		return 0, errors.New(usage)
+	}
+}
+
+// reencrypt encrypts the sensitive fields of the records of the encrypted entities with the primary key, so that the records written
+// before the encryption was enabled or before the last key rotation no longer need the previous keys.
+// ctx: The context for the operation.
+// cfg: The configuration of the encryption keys.
+// db: The database whose records are encrypted.
+// Returns an error if no key is configured, or if a record cannot be decrypted or written.
+func reencrypt(ctx context.Context, cfg *config.Config, db database.Database) error {
+	keys, err := app.NewKeyring(cfg)
+	if err != nil {
+		return err
+	}
+	if keys == nil {
+		return errors.New("no encryption key is configured")
+	}
+	encrypted := database.NewEncryptedDatabase(db, keys)
+	for _, entity := range app.EncryptedEntities {
+		n, err := encrypted.Reencrypt(ctx, entity, reencryptBatch)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Encrypted %d %T records\n", n, entity)
+	}
+	return nil
+}
@@ -2,25 +2,44 @@
 package main
 
 import (
-	"github.com/nikita-voronoy/go-clean-arch/config"                       // Config package provides the functionality to interact with the configuration of the application.
-	"github.com/nikita-voronoy/go-clean-arch/internal/app"                 // App package provides the functionality to create and manage the server of the application.
-	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth/module" // Module package provides the functionality to interact with the auth module of the application.
-	"github.com/nikita-voronoy/go-clean-arch/pkg/database"                 // Database package provides the functionality to interact with the database of the application.
-	"go.uber.org/fx"                                                       // Fx is a framework for Go that provides the tools needed to build a dependency graph and invoke components in the correct order.
+	"github.com/nikita-voronoy/go-clean-arch/config"                                                  // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/app"                                            // App package provides the functionality to create and manage the server of the application.
+	auditmodule "github.com/nikita-voronoy/go-clean-arch/internal/modules/audit/module"               // Module package provides the functionality to interact with the audit module of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth/module"                            // Module package provides the functionality to interact with the auth module of the application.
+	backupmodule "github.com/nikita-voronoy/go-clean-arch/internal/modules/backup/module"             // Module package provides the functionality to interact with the backup module of the application.
+	invitationmodule "github.com/nikita-voronoy/go-clean-arch/internal/modules/invitation/module"     // Module package provides the functionality to interact with the invitation module of the application.
+	orgmodule "github.com/nikita-voronoy/go-clean-arch/internal/modules/organization/module"          // Module package provides the functionality to interact with the organization module of the application.
+	outboxmodule "github.com/nikita-voronoy/go-clean-arch/internal/modules/outbox/module"             // Module package provides the functionality to interact with the outbox module of the application.
+	provisioningmodule "github.com/nikita-voronoy/go-clean-arch/internal/modules/provisioning/module" // Module package provides the functionality to interact with the provisioning module of the application.
+	webhookmodule "github.com/nikita-voronoy/go-clean-arch/internal/modules/webhook/module"           // Module package provides the functionality to interact with the webhook module of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"                                        // Storage package provides the functionality to run storage operations in transactions.
+	"go.uber.org/fx"                                                                                  // Fx is a framework for Go that provides the tools needed to build a dependency graph and invoke components in the correct order.
 )
 
 // main function is the entry point for the application.
 // It creates a new Fx application with the provided providers and modules.
-// The providers are the configuration, database, and server of the application.
-// The modules are the auth module of the application.
+// The providers are the configuration, database, transactor, event bus, NATS client, and server of the application.
+// The modules are the audit, auth, organization, invitation, provisioning, backup, outbox and webhook modules of the application.
 // The application is run with the Run method of Fx.
 func main() {
 	fx.New(
 		fx.Provide(
-			config.NewConfig,     // Provides the configuration of the application.
-			database.NewDatabase, // Provides the database of the application.
-			app.NewServer,        // Provides the server of the application.
+			config.NewConfig,      // Provides the configuration of the application.
+			app.NewDatabase,       // Provides the instrumented database of the application.
+			storage.NewTransactor, // Provides the transactions of the database to the use cases.
+			app.NewEventBus,       // Provides the event bus the modules communicate through.
+			app.NewMessaging,      // Provides the client of the NATS server the domain events are exchanged through.
+			app.NewServer,         // Provides the server of the application.
 		),
-		module.Module, // Provides the auth module of the application.
+		fx.Invoke(app.Migrate),       // Applies the pending migrations of the modules before the server starts.
+		fx.Invoke(app.CloseEventBus), // Closes the event bus once the server stops.
+		auditmodule.Module,           // Provides the audit module of the application.
+		module.Module,                // Provides the auth module of the application.
+		orgmodule.Module,             // Provides the organization module of the application.
+		invitationmodule.Module,      // Provides the invitation module of the application.
+		provisioningmodule.Module,    // Provides the provisioning module of the application.
+		backupmodule.Module,          // Provides the backup module of the application.
+		outboxmodule.Module,          // Provides the outbox module of the application.
+		webhookmodule.Module,         // Provides the webhook module of the application.
 	).Run() // Runs the Fx application.
 }
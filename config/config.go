@@ -7,12 +7,35 @@ import (
 	"log"                    // Log package provides the functionality to implement logging.
 )
 
-// Config struct represents the configuration of the application with fields for the server and database configurations.
+// Config struct represents the configuration of the application with a field for every configuration section.
 // Server: The server configuration of the application.
 // DB: The database configuration of the application.
+// Admin: The admin API configuration of the application.
+// Audit: The audit log configuration of the application.
+// Tenancy: The multi-tenancy configuration of the application.
+// Auth: The authentication configuration of the application.
+// Invitations: The invitation configuration of the application.
+// SCIM: The SCIM provisioning configuration of the application.
+// Cache: The cache of the users the application reads.
+// Outbox: The outbox of the domain events and their relay to the broker.
+// NATS: The NATS server the domain events are published to and consumed from.
+// Webhooks: The webhooks the domain events are posted to.
+// Encryption: The keys the sensitive fields of the stored records are encrypted with.
 type Config struct {
-	Server ServerConfig   `mapstructure:"app"` // The server configuration of the application.
-	DB     DatabaseConfig `mapstructure:"db"`  // The database configuration of the application.
+	Server  ServerConfig   `mapstructure:"app"`     // The server configuration of the application.
+	DB      DatabaseConfig `mapstructure:"db"`      // The database configuration of the application.
+	Admin   AdminConfig    `mapstructure:"admin"`   // The admin API configuration of the application.
+	Audit   AuditConfig    `mapstructure:"audit"`   // The audit log configuration of the application.
+	Tenancy TenancyConfig  `mapstructure:"tenancy"` // The multi-tenancy configuration of the application.
+	Auth    AuthConfig     `mapstructure:"auth"`    // The authentication configuration of the application.
+
+	Invitations InvitationConfig `mapstructure:"invitations"` // The invitation configuration of the application.
+	SCIM        SCIMConfig       `mapstructure:"scim"`        // The SCIM provisioning configuration of the application.
+	Cache       CacheConfig      `mapstructure:"cache"`       // The cache of the users the application reads.
+	Outbox      OutboxConfig     `mapstructure:"outbox"`      // The outbox of the domain events and their relay to the broker.
+	NATS        NATSConfig       `mapstructure:"nats"`        // The NATS server the domain events are published to and consumed from.
+	Webhooks    WebhookConfig    `mapstructure:"webhooks"`    // The webhooks the domain events are posted to.
+	Encryption  EncryptionConfig `mapstructure:"encryption"`  // The keys the sensitive fields of the stored records are encrypted with.
 }
 
 // ServerConfig struct represents the server configuration with fields for the host, port, mode, and debug.
@@ -27,18 +50,334 @@ type ServerConfig struct {
 	Debug bool   `mapstructure:"debug"` // The debug mode of the server.
 }
 
-// DatabaseConfig struct represents the database configuration with fields for the database type and SQLite configuration.
-// DatabaseType: The type of the database.
+// DatabaseConfig struct represents the database configuration with fields for the database type and the configuration of every database.
+// DatabaseType: The type of the database, "sqlite", "postgres", "mysql", "bolt" or "memory". The memory database loses its records when the server stops.
 // Sqlite: The SQLite configuration.
+// Postgres: The PostgreSQL configuration.
+// MySQL: The MySQL or MariaDB configuration.
+// Bolt: The bbolt configuration.
+// Isolation: The isolation level of the transactions, "read_uncommitted", "read_committed", "repeatable_read" or "serializable".
+// The default level of the database is used if it is empty. SQLite, bbolt and the memory database always serialize their transactions.
+// AutoMigrate: Whether the pending migrations are applied when the server starts. Otherwise they are applied with the migrate command.
+// Replication: The read replicas of the SQLite, PostgreSQL or MySQL database.
+// Instrumentation: The tracing and the slow query logging of the database operations.
 type DatabaseConfig struct {
-	DatabaseType string       `mapstructure:"database_type"` // The type of the database.
-	Sqlite       SqliteConfig `mapstructure:"sqlite"`        // The SQLite configuration.
+	DatabaseType string            `mapstructure:"database_type"` // The type of the database.
+	Isolation    string            `mapstructure:"isolation"`     // The isolation level of the transactions.
+	AutoMigrate  bool              `mapstructure:"auto_migrate"`  // Whether the pending migrations are applied on start.
+	Sqlite       SqliteConfig      `mapstructure:"sqlite"`        // The SQLite configuration.
+	Postgres     PostgresConfig    `mapstructure:"postgres"`      // The PostgreSQL configuration.
+	MySQL        MySQLConfig       `mapstructure:"mysql"`         // The MySQL or MariaDB configuration.
+	Bolt         BoltConfig        `mapstructure:"bolt"`          // The bbolt configuration.
+	Replication  ReplicationConfig `mapstructure:"replication"`   // The read replicas of the database.
+
+	Instrumentation InstrumentationConfig `mapstructure:"instrumentation"` // The tracing and the slow query logging of the database operations.
+}
+
+// InstrumentationConfig struct represents the instrumentation of the database operations, which are measured and handed to the
+// observability sinks of the application.
+// SlowQueryMilliseconds: The number of milliseconds from which an operation is logged, with the values of its query redacted. Zero disables the logging.
+type InstrumentationConfig struct {
+	SlowQueryMilliseconds int `mapstructure:"slow_query_milliseconds"` // The number of milliseconds from which an operation is logged.
+}
+
+// ReplicationConfig struct represents the read replicas of the database, which receive the plain reads while the primary receives
+// the writes and the transactions.
+// Replicas: The replicas of the database. The reads are sent to the primary if it is empty.
+// StickySeconds: The number of seconds the reads of a request are sent to the primary after it wrote, which should exceed the replication lag.
+// HealthCheckSeconds: The number of seconds between the health checks of the replicas. Zero disables the periodic checks.
+type ReplicationConfig struct {
+	Replicas           []ReplicaConfig `mapstructure:"replicas"`             // The replicas of the database.
+	StickySeconds      int             `mapstructure:"sticky_seconds"`       // The number of seconds a request reads its writes from the primary.
+	HealthCheckSeconds int             `mapstructure:"health_check_seconds"` // The number of seconds between the health checks.
 }
 
-// SqliteConfig struct represents the SQLite configuration with a field for the database path.
+// ReplicaConfig struct represents a read replica, which shares the credentials, the database name and the pool settings of the primary.
+// Host: The host of the PostgreSQL or MySQL replica.
+// Port: The port of the PostgreSQL or MySQL replica. The port of the primary is used if it is zero.
+// DatabasePath: The path of the SQLite replica.
+type ReplicaConfig struct {
+	Host         string `mapstructure:"host"`          // The host of the PostgreSQL or MySQL replica.
+	Port         int    `mapstructure:"port"`          // The port of the PostgreSQL or MySQL replica.
+	DatabasePath string `mapstructure:"database_path"` // The path of the SQLite replica.
+}
+
+// SqliteConfig struct represents the SQLite configuration with fields for the database file, its pragmas and the connection pool.
 // DatabasePath: The path of the SQLite database.
+// JournalMode: The journal mode of the database, e.g. "wal" or "delete". The default mode of the database file is kept if it is empty.
+// BusyTimeoutMilliseconds: The number of milliseconds an operation waits for the lock of the database. Zero waits five seconds.
+// ForeignKeys: Whether the foreign key constraints are enforced.
+// MaxOpenConns: The maximum number of open connections. Zero means no limit.
+// MaxIdleConns: The maximum number of idle connections.
+// ConnMaxLifetimeMinutes: The number of minutes a connection is reused. Zero reuses the connections forever.
 type SqliteConfig struct {
-	DatabasePath string `mapstructure:"database_path"` // The path of the SQLite database.
+	DatabasePath            string `mapstructure:"database_path"`             // The path of the SQLite database.
+	JournalMode             string `mapstructure:"journal_mode"`              // The journal mode of the database.
+	BusyTimeoutMilliseconds int    `mapstructure:"busy_timeout_milliseconds"` // The number of milliseconds an operation waits for the lock.
+	ForeignKeys             bool   `mapstructure:"foreign_keys"`              // Whether the foreign key constraints are enforced.
+	MaxOpenConns            int    `mapstructure:"max_open_conns"`            // The maximum number of open connections.
+	MaxIdleConns            int    `mapstructure:"max_idle_conns"`            // The maximum number of idle connections.
+	ConnMaxLifetimeMinutes  int    `mapstructure:"conn_max_lifetime_minutes"` // The number of minutes a connection is reused.
+}
+
+// PostgresConfig struct represents the PostgreSQL configuration with fields for the connection and the connection pool.
+// Host: The host of the PostgreSQL server.
+// Port: The port of the PostgreSQL server.
+// User: The user to connect as.
+// Password: The password of the user.
+// DBName: The name of the database.
+// SSLMode: The SSL mode of the connection, e.g. "disable", "prefer", "require" or "verify-full".
+// SSLRootCert: The path of the CA certificate the server certificate is verified with. The system pool is used if it is empty.
+// MaxOpenConns: The maximum number of open connections. Zero means no limit.
+// MaxIdleConns: The maximum number of idle connections.
+// ConnMaxLifetimeMinutes: The number of minutes a connection is reused. Zero reuses the connections forever.
+type PostgresConfig struct {
+	Host                   string `mapstructure:"host"`                      // The host of the PostgreSQL server.
+	Port                   int    `mapstructure:"port"`                      // The port of the PostgreSQL server.
+	User                   string `mapstructure:"user"`                      // The user to connect as.
+	Password               string `mapstructure:"password"`                  // The password of the user.
+	DBName                 string `mapstructure:"db_name"`                   // The name of the database.
+	SSLMode                string `mapstructure:"ssl_mode"`                  // The SSL mode of the connection.
+	SSLRootCert            string `mapstructure:"ssl_root_cert"`             // The path of the CA certificate.
+	MaxOpenConns           int    `mapstructure:"max_open_conns"`            // The maximum number of open connections.
+	MaxIdleConns           int    `mapstructure:"max_idle_conns"`            // The maximum number of idle connections.
+	ConnMaxLifetimeMinutes int    `mapstructure:"conn_max_lifetime_minutes"` // The number of minutes a connection is reused.
+}
+
+// MySQLConfig struct represents the MySQL or MariaDB configuration with fields for the connection, the UUID storage and the connection pool.
+// Host: The host of the MySQL server.
+// Port: The port of the MySQL server.
+// User: The user to connect as.
+// Password: The password of the user.
+// DBName: The name of the database.
+// TLS: The TLS mode of the connection, "false", "true", "skip-verify" or "preferred".
+// UUIDStorage: The column type of the UUIDs, "char(36)" or "binary(16)". It cannot be changed once the tables are created.
+// MaxOpenConns: The maximum number of open connections. Zero means no limit.
+// MaxIdleConns: The maximum number of idle connections.
+// ConnMaxLifetimeMinutes: The number of minutes a connection is reused. Zero reuses the connections forever.
+type MySQLConfig struct {
+	Host                   string `mapstructure:"host"`                      // The host of the MySQL server.
+	Port                   int    `mapstructure:"port"`                      // The port of the MySQL server.
+	User                   string `mapstructure:"user"`                      // The user to connect as.
+	Password               string `mapstructure:"password"`                  // The password of the user.
+	DBName                 string `mapstructure:"db_name"`                   // The name of the database.
+	TLS                    string `mapstructure:"tls"`                       // The TLS mode of the connection.
+	UUIDStorage            string `mapstructure:"uuid_storage"`              // The column type of the UUIDs.
+	MaxOpenConns           int    `mapstructure:"max_open_conns"`            // The maximum number of open connections.
+	MaxIdleConns           int    `mapstructure:"max_idle_conns"`            // The maximum number of idle connections.
+	ConnMaxLifetimeMinutes int    `mapstructure:"conn_max_lifetime_minutes"` // The number of minutes a connection is reused.
+}
+
+// BoltConfig struct represents the bbolt configuration with fields for the database file.
+// Path: The path of the database file. It is created if it does not exist.
+// TimeoutSeconds: The number of seconds to wait for the lock of the file, which only one process can hold. Zero waits forever.
+type BoltConfig struct {
+	Path           string `mapstructure:"path"`            // The path of the database file.
+	TimeoutSeconds int    `mapstructure:"timeout_seconds"` // The number of seconds to wait for the lock of the file.
+}
+
+// AdminConfig struct represents the admin API configuration with a field for the API key.
+// APIKey: The bearer key required by the admin endpoints. The admin endpoints reject every request if it is empty.
+type AdminConfig struct {
+	APIKey string `mapstructure:"api_key"` // The bearer key required by the admin endpoints.
+}
+
+// AuditConfig struct represents the audit log configuration with a field for the retention.
+// RetentionDays: The number of days the audit events are kept. Zero keeps the events forever.
+type AuditConfig struct {
+	RetentionDays int `mapstructure:"retention_days"` // The number of days the audit events are kept.
+}
+
+// TenancyConfig struct represents the multi-tenancy configuration with fields for the tenant resolution.
+// Header: The request header that carries the ID or slug of the tenant organization.
+// BaseDomain: The domain under which every organization is served on its own subdomain. Subdomains are ignored if it is empty.
+type TenancyConfig struct {
+	Header     string `mapstructure:"header"`      // The request header that carries the tenant organization.
+	BaseDomain string `mapstructure:"base_domain"` // The domain under which every organization is served on its own subdomain.
+}
+
+// AuthConfig struct represents the authentication configuration with fields for the registration and the authentication backends.
+// OpenRegistration: Whether anyone can create an account through POST /auth/register. If it is false, accounts can only be created by accepting an invitation.
+// Backends: The backends that check the credentials on login, in the order they are tried. The supported backends are "local" and "ldap".
+// LDAP: The LDAP configuration of the "ldap" backend.
+type AuthConfig struct {
+	OpenRegistration bool       `mapstructure:"open_registration"` // Whether anyone can create an account.
+	Backends         []string   `mapstructure:"backends"`          // The backends that check the credentials on login.
+	LDAP             LDAPConfig `mapstructure:"ldap"`              // The LDAP configuration of the "ldap" backend.
+}
+
+// LDAPConfig struct represents the configuration of the LDAP or Active Directory authentication backend.
+// URL: The URL of the directory server, e.g. ldaps://ldap.example.com:636.
+// StartTLS: Whether the connection is upgraded with StartTLS before binding.
+// InsecureSkipVerify: Whether the certificate of the directory server is accepted without verification. It must only be used for testing.
+// BindDN: The DN of the service account that searches the users. The search binds anonymously if it is empty.
+// BindPassword: The password of the service account.
+// BaseDN: The DN the users are searched under.
+// UserFilter: The filter that finds a user by the email it signs in with. The escaped email replaces %s.
+// UsernameAttribute: The attribute the username of a provisioned user is derived from.
+// EmailAttribute: The attribute that holds the email of a user.
+// IDAttribute: The attribute that identifies a user for good, e.g. objectGUID or entryUUID, which the local account is linked to. The DN is used if it is empty.
+// GroupAttribute: The attribute of a user that lists the DNs of its groups, e.g. memberOf.
+// GroupBaseDN: The DN the groups are searched under. Groups are only read from the group attribute if it is empty.
+// GroupFilter: The filter that finds the groups of a user. The escaped DN of the user replaces %s.
+// GroupRoles: The mapping of the directory groups to the organization roles.
+// TimeoutSeconds: The number of seconds the directory server is given to respond.
+type LDAPConfig struct {
+	URL                string          `mapstructure:"url"`                  // The URL of the directory server.
+	StartTLS           bool            `mapstructure:"start_tls"`            // Whether the connection is upgraded with StartTLS.
+	InsecureSkipVerify bool            `mapstructure:"insecure_skip_verify"` // Whether the certificate is accepted without verification.
+	BindDN             string          `mapstructure:"bind_dn"`              // The DN of the service account.
+	BindPassword       string          `mapstructure:"bind_password"`        // The password of the service account.
+	BaseDN             string          `mapstructure:"base_dn"`              // The DN the users are searched under.
+	UserFilter         string          `mapstructure:"user_filter"`          // The filter that finds a user by email.
+	UsernameAttribute  string          `mapstructure:"username_attribute"`   // The attribute the username is derived from.
+	EmailAttribute     string          `mapstructure:"email_attribute"`      // The attribute that holds the email.
+	IDAttribute        string          `mapstructure:"id_attribute"`         // The attribute the local account is linked to.
+	GroupAttribute     string          `mapstructure:"group_attribute"`      // The attribute that lists the groups of a user.
+	GroupBaseDN        string          `mapstructure:"group_base_dn"`        // The DN the groups are searched under.
+	GroupFilter        string          `mapstructure:"group_filter"`         // The filter that finds the groups of a user.
+	GroupRoles         []LDAPGroupRole `mapstructure:"group_roles"`          // The mapping of the groups to the organization roles.
+	TimeoutSeconds     int             `mapstructure:"timeout_seconds"`      // The number of seconds the directory server is given to respond.
+}
+
+// LDAPGroupRole struct represents the role the members of a directory group get in an organization.
+// Group: The DN of the directory group.
+// Organization: The slug of the organization.
+// Role: The role the members of the group get in the organization.
+type LDAPGroupRole struct {
+	Group        string `mapstructure:"group"`        // The DN of the directory group.
+	Organization string `mapstructure:"organization"` // The slug of the organization.
+	Role         string `mapstructure:"role"`         // The role the members of the group get.
+}
+
+// InvitationConfig struct represents the invitation configuration with fields for the lifetime and the link of the invitations.
+// TTLHours: The number of hours an invitation can be accepted.
+// LinkBaseURL: The URL the invitation token is appended to in order to build the invite link.
+type InvitationConfig struct {
+	TTLHours    int    `mapstructure:"ttl_hours"`     // The number of hours an invitation can be accepted.
+	LinkBaseURL string `mapstructure:"link_base_url"` // The URL the invitation token is appended to.
+}
+
+// SCIMConfig struct represents the SCIM provisioning configuration with a field for the bearer token.
+// Token: The bearer token the identity provider authenticates with. The SCIM endpoints reject every request if it is empty.
+type SCIMConfig struct {
+	Token string `mapstructure:"token"` // The bearer token the identity provider authenticates with.
+}
+
+// CacheConfig struct represents the configuration of the cache of the users, which the reads by ID, email and username go through.
+// Type: The type of the cache, "none", "memory" or "redis". The users are not cached if it is empty or "none".
+// The memory cache is not shared, so that an instance may read a user another instance modified until the entry expires.
+// TTLSeconds: The number of seconds a user is cached.
+// NegativeTTLSeconds: The number of seconds the absence of a user is cached.
+// Size: The maximum number of entries of the memory cache.
+// Redis: The Redis server of the redis cache.
+type CacheConfig struct {
+	Type               string      `mapstructure:"type"`                 // The type of the cache.
+	TTLSeconds         int         `mapstructure:"ttl_seconds"`          // The number of seconds a user is cached.
+	NegativeTTLSeconds int         `mapstructure:"negative_ttl_seconds"` // The number of seconds the absence of a user is cached.
+	Size               int         `mapstructure:"size"`                 // The maximum number of entries of the memory cache.
+	Redis              RedisConfig `mapstructure:"redis"`                // The Redis server of the redis cache.
+}
+
+// OutboxConfig struct represents the configuration of the outbox of the domain events, which a relay publishes to a broker.
+// Broker: The broker the events are published to. "log" writes them to the log, "nats" publishes them to NATS JetStream,
+// and "none" leaves them in the outbox for another instance to relay, unless the webhooks are enabled, which the relay delivers them to.
+// IntervalMilliseconds: The number of milliseconds the relay waits for new events once the outbox is drained.
+// BatchSize: The maximum number of events the relay reads at once.
+// LeaseSeconds: The number of seconds an event is reserved to the relay that publishes it, after which another relay may publish it again.
+// RetryBaseSeconds: The number of seconds the relay waits before it publishes an event again after the first failure, doubled by every further failure.
+// RetryMaxSeconds: The maximum number of seconds the relay waits before it publishes an event again.
+// MaxAttempts: The number of failed attempts after which an event is dead, and is kept in the outbox without being published.
+// RetentionHours: The number of hours the published events are kept. Zero keeps them forever.
+type OutboxConfig struct {
+	Broker               string `mapstructure:"broker"`                // The broker the events are published to.
+	IntervalMilliseconds int    `mapstructure:"interval_milliseconds"` // The number of milliseconds the relay waits for new events.
+	BatchSize            int    `mapstructure:"batch_size"`            // The maximum number of events the relay reads at once.
+	LeaseSeconds         int    `mapstructure:"lease_seconds"`         // The number of seconds an event is reserved to the relay that publishes it.
+	RetryBaseSeconds     int    `mapstructure:"retry_base_seconds"`    // The number of seconds the relay waits after the first failure.
+	RetryMaxSeconds      int    `mapstructure:"retry_max_seconds"`     // The maximum number of seconds the relay waits after a failure.
+	MaxAttempts          int    `mapstructure:"max_attempts"`          // The number of failed attempts after which an event is dead.
+	RetentionHours       int    `mapstructure:"retention_hours"`       // The number of hours the published events are kept.
+}
+
+// NATSConfig struct represents the configuration of the NATS server the domain events are published to, in JetStream streams.
+// Mode: "embedded" runs a server in the process, for development and tests, "external" connects to the server at URL,
+// and "none" or an empty mode connects to no server.
+// URL: The URL of the external server, e.g. "nats://localhost:4222".
+// Port: The port the embedded server listens on for the other services. Zero only serves the application.
+// StoreDir: The directory the embedded server stores the streams in. The streams are kept in memory if it is empty.
+// Stream: The name of the stream of the domain events, which is created if it does not exist.
+// SubjectPrefix: The prefix of the subjects of the domain events, which are published to "<prefix>.<type>", e.g. "events.user.registered".
+// Encoding: The encoding of the envelopes of the published events, "json" or "protobuf".
+// Source: The name of the application in the envelopes, which tells the consumers where an event comes from.
+// DuplicateWindowSeconds: The number of seconds the stream drops the events that are published again with the same ID.
+// TimeoutSeconds: The number of seconds the connection and a publication may take.
+type NATSConfig struct {
+	Mode                   string `mapstructure:"mode"`                     // The server the application connects to.
+	URL                    string `mapstructure:"url"`                      // The URL of the external server.
+	Port                   int    `mapstructure:"port"`                     // The port the embedded server listens on.
+	StoreDir               string `mapstructure:"store_dir"`                // The directory the embedded server stores the streams in.
+	Stream                 string `mapstructure:"stream"`                   // The name of the stream of the domain events.
+	SubjectPrefix          string `mapstructure:"subject_prefix"`           // The prefix of the subjects of the domain events.
+	Encoding               string `mapstructure:"encoding"`                 // The encoding of the envelopes of the published events.
+	Source                 string `mapstructure:"source"`                   // The name of the application in the envelopes.
+	DuplicateWindowSeconds int    `mapstructure:"duplicate_window_seconds"` // The number of seconds the stream drops the duplicate events.
+	TimeoutSeconds         int    `mapstructure:"timeout_seconds"`          // The number of seconds the connection and a publication may take.
+}
+
+// WebhookConfig struct represents the configuration of the webhooks, which post the domain events to the registered endpoints.
+// Enabled: Whether the relay of the outbox delivers the domain events to the webhooks.
+// IntervalMilliseconds: The number of milliseconds the worker waits for new deliveries once none is due.
+// BatchSize: The maximum number of deliveries the worker reads at once.
+// Concurrency: The maximum number of deliveries the worker sends at once.
+// TimeoutSeconds: The number of seconds an endpoint may take to respond.
+// MaxAttempts: The number of failed attempts after which a delivery is dead, and only sent again on request.
+// LeaseSeconds: The number of seconds a delivery is reserved to the worker that sends it. It must exceed the timeout.
+// RetryBaseSeconds: The number of seconds the worker waits before it sends a delivery again after the first failure, doubled by every further failure.
+// RetryMaxSeconds: The maximum number of seconds the worker waits before it sends a delivery again.
+// RotationGraceHours: The number of hours the deliveries are signed with the previous secret as well after a rotation.
+// RetentionHours: The number of hours the succeeded and dead deliveries are kept. Zero keeps them forever.
+type WebhookConfig struct {
+	Enabled              bool `mapstructure:"enabled"`               // Whether the domain events are delivered to the webhooks.
+	IntervalMilliseconds int  `mapstructure:"interval_milliseconds"` // The number of milliseconds the worker waits for new deliveries.
+	BatchSize            int  `mapstructure:"batch_size"`            // The maximum number of deliveries the worker reads at once.
+	Concurrency          int  `mapstructure:"concurrency"`           // The maximum number of deliveries the worker sends at once.
+	TimeoutSeconds       int  `mapstructure:"timeout_seconds"`       // The number of seconds an endpoint may take to respond.
+	MaxAttempts          int  `mapstructure:"max_attempts"`          // The number of failed attempts after which a delivery is dead.
+	LeaseSeconds         int  `mapstructure:"lease_seconds"`         // The number of seconds a delivery is reserved to the worker that sends it.
+	RetryBaseSeconds     int  `mapstructure:"retry_base_seconds"`    // The number of seconds the worker waits after the first failure.
+	RetryMaxSeconds      int  `mapstructure:"retry_max_seconds"`     // The maximum number of seconds the worker waits after a failure.
+	RotationGraceHours   int  `mapstructure:"rotation_grace_hours"`  // The number of hours the previous secret is used after a rotation.
+	RetentionHours       int  `mapstructure:"retention_hours"`       // The number of hours the finished deliveries are kept.
+}
+
+// EncryptionConfig struct represents the keys the sensitive fields of the stored records are encrypted with: the emails and the tokens
+// of the users, the emails of the invitations, the actors of the audit events, and the payloads of the outbox messages and the webhook deliveries.
+// The fields are stored in plaintext if no key is configured.
+// A key is written "<id>:<base64 of 32 bytes>". To rotate the keys, a new primary key is added, the records are encrypted again
+// with the reencrypt command of the migrate command, and the previous key is removed.
+// Keys: The encryption keys.
+// KeyFile: The path of a file that lists further keys, one per line, e.g. a mounted secret.
+// PrimaryKey: The ID of the key the values are encrypted with. The first key, from the configuration then from the key file, is used if it is empty.
+// IndexKey: The base64 of the 32 bytes of the key of the blind indexes, which the encrypted fields are looked up with. It is required with the keys,
+// and changing it breaks the lookups until the records are encrypted again.
+type EncryptionConfig struct {
+	Keys       []string `mapstructure:"keys"`        // The encryption keys.
+	KeyFile    string   `mapstructure:"key_file"`    // The path of a file that lists further keys.
+	PrimaryKey string   `mapstructure:"primary_key"` // The ID of the key the values are encrypted with.
+	IndexKey   string   `mapstructure:"index_key"`   // The key of the blind indexes.
+}
+
+// RedisConfig struct represents the configuration of a Redis server.
+// Address: The host and port of the server.
+// Password: The password of the server. The connections are not authenticated if it is empty.
+// DB: The number of the database of the server.
+// TimeoutSeconds: The number of seconds a command may take.
+type RedisConfig struct {
+	Address        string `mapstructure:"address"`         // The host and port of the server.
+	Password       string `mapstructure:"password"`        // The password of the server.
+	DB             int    `mapstructure:"db"`              // The number of the database of the server.
+	TimeoutSeconds int    `mapstructure:"timeout_seconds"` // The number of seconds a command may take.
 }
 
 // NewConfig creates a new configuration by reading from a YAML file and environment variables.
@@ -53,6 +392,67 @@ func NewConfig() (*Config, error) {
 	v.AddConfigPath(".")             // Adds the current directory as a path to look for the configuration file.
 	v.AutomaticEnv()                 // Reads in environment variables that match.
 
+	v.SetDefault("auth.open_registration", true)                              // Keeps the registration open unless it is turned off explicitly.
+	v.SetDefault("invitations.ttl_hours", 72)                                 // Lets invitations be accepted for three days by default.
+	v.SetDefault("db.instrumentation.slow_query_milliseconds", 200)           // Logs the database operations that take longer than 200 milliseconds by default.
+	v.SetDefault("db.sqlite.journal_mode", "wal")                             // Lets the reads run while a transaction writes by default.
+	v.SetDefault("db.sqlite.busy_timeout_milliseconds", 5000)                 // Waits five seconds for the lock of the SQLite database by default.
+	v.SetDefault("db.sqlite.foreign_keys", true)                              // Enforces the foreign key constraints by default.
+	v.SetDefault("db.sqlite.max_idle_conns", 2)                               // Keeps at most two idle connections by default.
+	v.SetDefault("db.postgres.port", 5432)                                    // Connects to the default PostgreSQL port by default.
+	v.SetDefault("db.postgres.ssl_mode", "prefer")                            // Uses SSL whenever the server supports it by default.
+	v.SetDefault("db.postgres.max_open_conns", 10)                            // Opens at most ten connections by default.
+	v.SetDefault("db.postgres.max_idle_conns", 5)                             // Keeps at most five idle connections by default.
+	v.SetDefault("db.mysql.port", 3306)                                       // Connects to the default MySQL port by default.
+	v.SetDefault("db.mysql.tls", "preferred")                                 // Uses TLS whenever the server supports it by default.
+	v.SetDefault("db.mysql.uuid_storage", "char(36)")                         // Stores the UUIDs as text by default.
+	v.SetDefault("db.mysql.max_open_conns", 10)                               // Opens at most ten connections by default.
+	v.SetDefault("db.mysql.max_idle_conns", 5)                                // Keeps at most five idle connections by default.
+	v.SetDefault("db.bolt.path", "db.bolt")                                   // Stores the bbolt database next to the binary by default.
+	v.SetDefault("db.bolt.timeout_seconds", 5)                                // Waits five seconds for the lock of the bbolt file by default.
+	v.SetDefault("db.replication.sticky_seconds", 5)                          // Lets a request read its writes from the primary for five seconds by default.
+	v.SetDefault("db.replication.health_check_seconds", 10)                   // Checks the replicas every ten seconds by default.
+	v.SetDefault("auth.backends", []string{"local"})                          // Checks the credentials against the local accounts only by default.
+	v.SetDefault("auth.ldap.user_filter", "(&(objectClass=person)(mail=%s))") // Finds the users by their mail attribute by default.
+	v.SetDefault("auth.ldap.username_attribute", "uid")                       // Derives the usernames from the uid attribute by default.
+	v.SetDefault("auth.ldap.email_attribute", "mail")                         // Reads the emails from the mail attribute by default.
+	v.SetDefault("auth.ldap.group_attribute", "memberOf")                     // Reads the groups from the memberOf attribute by default.
+	v.SetDefault("auth.ldap.group_filter", "(member=%s)")                     // Finds the groups by their member attribute by default.
+	v.SetDefault("auth.ldap.timeout_seconds", 10)                             // Gives the directory server ten seconds to respond by default.
+	v.SetDefault("cache.type", "none")                                        // Reads the users from the database unless a cache is configured.
+	v.SetDefault("cache.ttl_seconds", 60)                                     // Caches the users for a minute by default.
+	v.SetDefault("cache.negative_ttl_seconds", 5)                             // Caches the absence of a user for five seconds by default.
+	v.SetDefault("cache.size", 10000)                                         // Caches at most ten thousand entries in memory by default.
+	v.SetDefault("cache.redis.address", "localhost:6379")                     // Connects to the default Redis port by default.
+	v.SetDefault("cache.redis.timeout_seconds", 1)                            // Gives the Redis server a second to respond by default.
+	v.SetDefault("outbox.broker", "log")                                      // Writes the domain events to the log unless a broker is configured.
+	v.SetDefault("outbox.interval_milliseconds", 1000)                        // Looks for new domain events every second by default.
+	v.SetDefault("outbox.batch_size", 100)                                    // Publishes at most a hundred domain events at once by default.
+	v.SetDefault("outbox.lease_seconds", 30)                                  // Reserves a domain event to its relay for thirty seconds by default.
+	v.SetDefault("outbox.retry_base_seconds", 1)                              // Publishes a domain event again a second after the first failure by default.
+	v.SetDefault("outbox.retry_max_seconds", 300)                             // Waits at most five minutes between the attempts by default.
+	v.SetDefault("outbox.max_attempts", 20)                                   // Gives up on a domain event after twenty failed attempts, about an hour, by default.
+	v.SetDefault("outbox.retention_hours", 168)                               // Keeps the published domain events for a week by default.
+	v.SetDefault("nats.mode", "none")                                         // Connects to no NATS server unless one is configured.
+	v.SetDefault("nats.url", "nats://localhost:4222")                         // Connects to the default NATS port by default.
+	v.SetDefault("nats.stream", "EVENTS")                                     // Publishes the domain events to the EVENTS stream by default.
+	v.SetDefault("nats.subject_prefix", "events")                             // Publishes the domain events under the "events." subjects by default.
+	v.SetDefault("nats.encoding", "json")                                     // Encodes the envelopes as JSON by default.
+	v.SetDefault("nats.source", "go-clean-arch")                              // Names the application in the envelopes by default.
+	v.SetDefault("nats.duplicate_window_seconds", 120)                        // Drops the events published again within two minutes by default.
+	v.SetDefault("nats.timeout_seconds", 5)                                   // Gives the NATS server five seconds to respond by default.
+	v.SetDefault("webhooks.enabled", true)                                    // Delivers the domain events to the registered webhooks by default.
+	v.SetDefault("webhooks.interval_milliseconds", 1000)                      // Looks for due deliveries every second by default.
+	v.SetDefault("webhooks.batch_size", 50)                                   // Sends at most fifty deliveries per round by default.
+	v.SetDefault("webhooks.concurrency", 4)                                   // Sends four deliveries at once by default.
+	v.SetDefault("webhooks.timeout_seconds", 10)                              // Gives an endpoint ten seconds to respond by default.
+	v.SetDefault("webhooks.max_attempts", 8)                                  // Gives up on a delivery after eight failed attempts by default.
+	v.SetDefault("webhooks.lease_seconds", 60)                                // Reserves a delivery to its worker for a minute by default.
+	v.SetDefault("webhooks.retry_base_seconds", 10)                           // Sends a delivery again ten seconds after the first failure by default.
+	v.SetDefault("webhooks.retry_max_seconds", 3600)                          // Waits at most an hour between the attempts by default.
+	v.SetDefault("webhooks.rotation_grace_hours", 24)                         // Signs with the previous secret for a day after a rotation by default.
+	v.SetDefault("webhooks.retention_hours", 720)                             // Keeps the finished deliveries for thirty days by default.
+
 	// Reads the configuration file.
 	// If the configuration file is not found, it returns an error.
 	// If the configuration file is found, it unmarshals the configuration into a Config object.
@@ -69,7 +469,8 @@ func NewConfig() (*Config, error) {
 		return nil, err
 	}
 
-	log.Printf("Config loaded from %v", v.AllSettings()) // Logs the loaded configuration.
+	// Only the path of the configuration file is logged, since the settings hold secrets, such as the passwords and the encryption keys.
+	log.Printf("Config loaded from %s", v.ConfigFileUsed()) // Logs the path of the loaded configuration.
 
 	return cfg, nil // Returns the Config object.
 }
@@ -0,0 +1,40 @@
+// Package app provides the functionality to instrument the database of the application.
+package app
+
+import (
+	"github.com/nikita-voronoy/go-clean-arch/config"       // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database" // Database package provides the functionality to interact with the database of the application.
+	"go.uber.org/fx"                                       // Fx is a framework for Go that provides the tools needed to build a dependency graph and invoke components in the correct order.
+	"time"                                                 // Time package provides the functionality to work with time.
+)
+
+// DatabaseSinks struct represents the observability backends the modules register with the database_sinks group,
+// e.g. fx.Supply(fx.Annotated{Group: "database_sinks", Target: database.Sink(sink)}).
+type DatabaseSinks struct {
+	fx.In
+
+	Sinks []database.Sink `group:"database_sinks"` // The backends that receive the operations of the database.
+}
+
+// NewDatabase creates the database of the application, whose every operation is measured, handed to the sinks,
+// and logged if it exceeds the slow query threshold, and whose tagged fields are encrypted if encryption keys are configured.
+// The modules decorate it further, so that the instrumentation measures the operations as the database runs them.
+// cfg: The configuration of the database, of its slow query threshold and of the encryption keys.
+// sinks: The observability backends of the application.
+// Returns the instrumented database, and an error if the connection cannot be established or a key is invalid.
+func NewDatabase(cfg *config.Config, sinks DatabaseSinks) (database.Database, error) {
+	keys, err := NewKeyring(cfg)
+	if err != nil {
+		return nil, err
+	}
+	db, err := database.NewDatabase(cfg)
+	if err != nil {
+		return nil, err
+	}
+	slow := time.Duration(cfg.DB.Instrumentation.SlowQueryMilliseconds) * time.Millisecond
+	instrumented := database.NewInstrumentedDatabase(db, slow, nil, sinks.Sinks...)
+	if keys == nil {
+		return instrumented, nil
+	}
+	return database.NewEncryptedDatabase(instrumented, keys), nil
+}
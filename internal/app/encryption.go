@@ -0,0 +1,42 @@
+// Package app provides the functionality to encrypt the sensitive fields of the records of the application.
+package app
+
+import (
+	"encoding/base64"                                           // Base64 package provides the functionality to decode the index key.
+	"fmt"                                                       // Fmt package provides the functionality to describe the invalid keys.
+	"github.com/nikita-voronoy/go-clean-arch/config"            // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities" // Entities package provides the entities whose fields are encrypted.
+	"github.com/nikita-voronoy/go-clean-arch/pkg/keyring"       // Keyring package provides the keys the fields are encrypted with.
+)
+
+// EncryptedEntities are the entities with fields tagged with database.EncryptTag, whose records the reencrypt command encrypts again.
+var EncryptedEntities = []interface{}{entities.User{}, entities.Invitation{}, entities.AuditEvent{}, entities.OutboxMessage{}, entities.WebhookEndpoint{}, entities.WebhookDelivery{}}
+
+// NewKeyring creates the keyring of the configured encryption keys, from the configuration then from the key file.
+// cfg: The configuration of the encryption.
+// Returns the keyring, nil if no key is configured, and an error if a key is invalid or the key file cannot be read.
+func NewKeyring(cfg *config.Config) (*keyring.Keyring, error) {
+	var keys []keyring.Key
+	for _, text := range cfg.Encryption.Keys {
+		key, err := keyring.ParseKey(text)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if cfg.Encryption.KeyFile != "" {
+		file, err := keyring.ReadFile(cfg.Encryption.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, file...)
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	indexKey, err := base64.StdEncoding.DecodeString(cfg.Encryption.IndexKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: the index key is not base64: %v", keyring.ErrInvalidKey, err)
+	}
+	return keyring.New(keys, cfg.Encryption.PrimaryKey, indexKey)
+}
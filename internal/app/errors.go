@@ -0,0 +1,27 @@
+// Package app provides the functionality to map the errors of the storage layer to HTTP status codes.
+package app
+
+import (
+	"errors"                                               // Errors package provides the functionality to inspect the chain of an error.
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database" // Database package provides the errors every database maps its native errors to.
+	"net/http"                                             // HTTP package provides the status codes.
+)
+
+// StatusCode returns the HTTP status code of an error reported by the storage layer, so that every module maps them alike.
+// err: The error to map.
+// fallback: The status code of any other error.
+// Returns 404 if no record matched, 412 if the record was modified since it was read, 409 if the record conflicts with an existing one, 503 if the database timed out, and the fallback otherwise.
+func StatusCode(err error, fallback int) int {
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrStale):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, database.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrTimeout):
+		return http.StatusServiceUnavailable
+	default:
+		return fallback
+	}
+}
@@ -0,0 +1,25 @@
+// Package app provides the functionality to create the event bus of the application.
+package app
+
+import (
+	"context"                                              // Context package provides the functionality to bound the wait for the asynchronous subscribers.
+	"github.com/nikita-voronoy/go-clean-arch/pkg/eventbus" // Eventbus package provides the in-process bus of typed events.
+	"go.uber.org/fx"                                       // Fx is a framework for Go that provides the tools needed to build a dependency graph and invoke components in the correct order.
+)
+
+// NewEventBus creates the event bus the modules publish their domain events on and subscribe to each other's with.
+// Returns a *eventbus.Bus object.
+func NewEventBus() *eventbus.Bus {
+	return eventbus.New()
+}
+
+// CloseEventBus closes the event bus when the application stops, once its asynchronous subscribers complete.
+// It must be invoked before the server is created, so that the bus is closed after the server stops
+// and the requests it completes can still publish their events.
+// lc: The lifecycle of the application.
+// bus: The event bus of the application.
+func CloseEventBus(lc fx.Lifecycle, bus *eventbus.Bus) {
+	lc.Append(fx.Hook{OnStop: func(ctx context.Context) error {
+		return bus.Close(ctx)
+	}})
+}
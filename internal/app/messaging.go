@@ -0,0 +1,26 @@
+// Package app provides the functionality to connect the application to its NATS server.
+package app
+
+import (
+	"context"                                               // Context package provides the functionality to stop the connection with the application.
+	"github.com/nikita-voronoy/go-clean-arch/config"        // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/pkg/messaging" // Messaging package provides the client of NATS JetStream.
+	"go.uber.org/fx"                                        // Fx is a framework for Go that provides the tools needed to build a dependency graph and invoke components in the correct order.
+)
+
+// NewMessaging connects to the configured NATS server, which is embedded in the process or external,
+// so that the modules publish their domain events to the other services and consume theirs.
+// The connection is closed, and the embedded server shut down, when the application stops.
+// lc: The lifecycle of the application.
+// cfg: The configuration of the NATS server.
+// Returns the client, which is nil if no server is configured, and an error if the server cannot be reached.
+func NewMessaging(lc fx.Lifecycle, cfg *config.Config) (*messaging.Client, error) {
+	client, err := messaging.Connect(cfg.NATS)
+	if err != nil || client == nil {
+		return nil, err
+	}
+	lc.Append(fx.Hook{OnStop: func(context.Context) error {
+		return client.Close()
+	}})
+	return client, nil
+}
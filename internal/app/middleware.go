@@ -0,0 +1,40 @@
+// Package app provides the functionality to create and manage the server of the application.
+package app
+
+import (
+	"crypto/subtle"                                        // Subtle package provides the functionality to compare secrets in constant time.
+	"github.com/labstack/echo/v4"                          // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/labstack/echo/v4/middleware"               // Middleware package provides the functionality to use middleware with Echo.
+	"github.com/nikita-voronoy/go-clean-arch/config"       // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database" // Database package provides the sessions that let a request read its own writes.
+)
+
+// AdminAuth creates a middleware that protects the admin endpoints with the admin API key.
+// The key is expected in the Authorization header as a bearer token.
+// cfg: The configuration that contains the admin API key.
+// Every request is rejected if no admin API key is configured.
+// Returns an echo.MiddlewareFunc.
+func AdminAuth(cfg *config.Config) echo.MiddlewareFunc {
+	return middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
+		KeyLookup:  "header:Authorization",
+		AuthScheme: "Bearer",
+		Validator: func(key string, c echo.Context) (bool, error) {
+			if cfg.Admin.APIKey == "" {
+				return false, nil
+			}
+			return subtle.ConstantTimeCompare([]byte(key), []byte(cfg.Admin.APIKey)) == 1, nil
+		},
+	})
+}
+
+// DatabaseSession creates a middleware that gives every request a database session,
+// so that the reads that follow a write of the request are not sent to a replica that has not caught up yet.
+// Returns an echo.MiddlewareFunc.
+func DatabaseSession() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.SetRequest(c.Request().WithContext(database.WithSession(c.Request().Context())))
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Package app provides the functionality to migrate the schema of the database when the application starts.
+package app
+
+import (
+	"context"                                              // Context package provides the functionality to bound the time the migrations may take.
+	"errors"                                               // Errors package provides the functionality to inspect the errors of the migrations.
+	"github.com/nikita-voronoy/go-clean-arch/config"       // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database" // Database package provides the functionality to interact with the database of the application.
+	"github.com/nikita-voronoy/go-clean-arch/pkg/migrate"  // Migrate package provides the functionality to apply the versioned SQL migrations.
+	"go.uber.org/fx"                                       // Fx is a framework for Go that provides the tools needed to build a dependency graph and invoke components in the correct order.
+	"log"                                                  // Log package provides the functionality to implement logging.
+	"time"                                                 // Time package provides the functionality to work with time.
+)
+
+// migrateTimeout is the time the migrations may take when the application starts, including the wait for the other instances that migrate.
+const migrateTimeout = 10 * time.Minute
+
+// Migrations struct represents the SQL migrations the modules register with the migrations group.
+type Migrations struct {
+	fx.In
+
+	Sources []migrate.Source `group:"migrations"` // The migrations of every module.
+}
+
+// Migrate applies the pending migrations of the modules when the auto migration is enabled.
+// The databases without a schema are skipped.
+// cfg: The configuration that enables the auto migration.
+// db: The database to migrate.
+// migrations: The migrations of the modules.
+// Returns an error if a migration fails, which stops the application.
+func Migrate(cfg *config.Config, db database.Database, migrations Migrations) error {
+	if !cfg.DB.AutoMigrate {
+		return nil
+	}
+	migrator, err := database.NewMigrator(db, migrations.Sources...)
+	if errors.Is(err, database.ErrMigrationsNotSupported) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+	defer cancel()
+	applied, err := migrator.Up(ctx, 0)
+	for _, migration := range applied {
+		log.Printf("Applied the migration %s of the %s module\n", migration, migration.Module)
+	}
+	return err
+}
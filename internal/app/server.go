@@ -14,7 +14,7 @@ import (
 // NewServer creates a new Echo server with the provided lifecycle and configuration.
 // lc: The lifecycle for the server.
 // cfg: The configuration for the server.
-// The server uses the Logger and Recover middleware from Echo.
+// The server uses the Logger and Recover middleware from Echo, and gives every request a database session.
 // The server starts when the lifecycle starts and shuts down when the lifecycle stops.
 // Returns an Echo object.
 func NewServer(lc fx.Lifecycle, cfg *config.Config) *echo.Echo {
@@ -27,6 +27,9 @@ func NewServer(lc fx.Lifecycle, cfg *config.Config) *echo.Echo {
 	// Adds the Recover middleware to the Echo instance.
 	server.Use(middleware.Recover())
 
+	// Lets every request read its own writes when the reads are spread over replicas.
+	server.Use(DatabaseSession())
+
 	// Appends a Hook to the lifecycle with OnStart and OnStop functions.
 	lc.Append(fx.Hook{
 		// The OnStart function starts the Echo server in a new goroutine.
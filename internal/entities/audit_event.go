@@ -0,0 +1,121 @@
+// Package entities provides the functionality to interact with the audit entities of the application.
+package entities
+
+import (
+	"crypto/sha256" // SHA256 package provides the functionality to compute SHA-256 digests.
+	"encoding/hex"  // Hex package provides the functionality to encode digests as hexadecimal strings.
+	"strconv"       // Strconv package provides the functionality to convert basic data types to strings.
+	"strings"       // Strings package provides the functionality to build the canonical form of an event.
+	"time"          // Time package provides the functionality to work with time.
+)
+
+// Audit actions recorded by the use cases of the application.
+const (
+	AuditActionRegister           = "register"            // A user account was created.
+	AuditActionLogin              = "login"               // A user attempted to log in.
+	AuditActionCredentialsChanged = "credentials_changed" // The credentials of a user were changed.
+	AuditActionDeactivated        = "deactivated"         // A user was deactivated and its token revoked.
+	AuditActionReactivated        = "reactivated"         // A deactivated user was activated again.
+)
+
+// Audit outcomes of the recorded actions.
+const (
+	AuditOutcomeSuccess = "success" // The action succeeded.
+	AuditOutcomeFailure = "failure" // The action failed.
+)
+
+// AuditEvent struct represents an append-only security audit entry.
+// ID: The sequence number of the event. Events are chained in ascending ID order.
+// Actor: The identity that performed the action, usually an email or a username. It is encrypted when the encryption is enabled.
+// ActorIndex: The blind index of the actor, which the lookups by actor use once it is encrypted. It is nil until it is encrypted.
+// Action: The action that was performed.
+// Target: The entity the action was performed on.
+// IP: The IP address of the client.
+// UserAgent: The user agent of the client.
+// Outcome: The outcome of the action.
+// Reason: The reason of a failure. It is empty for successful actions.
+// Timestamp: The time the action was performed.
+// PrevHash: The hash of the previous event in the chain.
+// Hash: The hash of this event, computed over its fields and PrevHash.
+type AuditEvent struct {
+	ID         uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
+	Actor      string    `json:"actor" gorm:"index" encrypt:"actor_index"`
+	ActorIndex *string   `json:"-" gorm:"index"`
+	Action     string    `json:"action" gorm:"index;not null"`
+	Target     string    `json:"target"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	Outcome    string    `json:"outcome" gorm:"index;not null"`
+	Reason     string    `json:"reason"`
+	Timestamp  time.Time `json:"timestamp" gorm:"index;not null"`
+	PrevHash   string    `json:"prev_hash" gorm:"size:64"`
+	Hash       string    `json:"hash" gorm:"size:64;not null"`
+}
+
+// AuditHead struct represents the head of the audit chain, which every append moves to the appended event,
+// so that the removal of the most recent events, which leaves a valid chain behind, is detected.
+// ID: The ID of the head. The chain has a single head, whose ID is AuditHeadID.
+// LastID: The sequence number of the last appended event.
+// Hash: The hash of the last appended event.
+// Count: The number of events of the chain. Every append increments it and every purge decrements it by the number of purged events.
+// Version: The version of the head. Concurrent appends conflict on it, so that only one of them extends the chain from a given event.
+type AuditHead struct {
+	ID      uint64 `json:"id" gorm:"primaryKey;autoIncrement:false"`
+	LastID  uint64 `json:"last_id" gorm:"not null;default:0"`
+	Hash    string `json:"hash" gorm:"size:64"`
+	Count   int64  `json:"count" gorm:"not null;default:0"`
+	Version int64  `json:"version" gorm:"not null;default:1"`
+}
+
+// AuditHeadID is the ID of the head of the audit chain.
+const AuditHeadID = 1
+
+// ComputeHash computes the chain hash of the event.
+// The hash covers every field except ID and Hash, so that any modification of a stored event
+// or of the order of events is detected when the chain is verified.
+// Returns the hexadecimal SHA-256 digest.
+func (e AuditEvent) ComputeHash() string {
+	fields := []string{
+		e.PrevHash,
+		e.Actor,
+		e.Action,
+		e.Target,
+		e.IP,
+		e.UserAgent,
+		e.Outcome,
+		e.Reason,
+		strconv.FormatInt(e.Timestamp.UTC().UnixNano(), 10),
+	}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditFilter struct represents the criteria used to query audit events.
+// Actor: Only events of this actor are returned if it is set.
+// Action: Only events with this action are returned if it is set.
+// Outcome: Only events with this outcome are returned if it is set.
+// IP: Only events from this IP address are returned if it is set.
+// From: Only events that happened at or after this time are returned if it is set.
+// To: Only events that happened before this time are returned if it is set.
+// Limit: The maximum number of events to return.
+// Offset: The number of events to skip.
+type AuditFilter struct {
+	Actor   string    `query:"actor"`
+	Action  string    `query:"action"`
+	Outcome string    `query:"outcome"`
+	IP      string    `query:"ip"`
+	From    time.Time `query:"from"`
+	To      time.Time `query:"to"`
+	Limit   int       `query:"limit"`
+	Offset  int       `query:"offset"`
+}
+
+// AuditVerification struct represents the result of a verification of the audit chain.
+// Valid: Whether the chain is intact.
+// Checked: The number of events that were checked.
+// BrokenAt: The sequence number of the first event that does not match the chain. It is zero if the chain is intact.
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Checked  int    `json:"checked"`
+	BrokenAt uint64 `json:"broken_at,omitempty"`
+}
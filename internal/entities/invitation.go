@@ -0,0 +1,69 @@
+// Package entities provides the functionality to interact with the invitation entities of the application.
+package entities
+
+import (
+	"github.com/google/uuid" // UUID package provides the functionality to generate and use UUIDs.
+	"time"                   // Time package provides the functionality to work with time.
+)
+
+// Invitation statuses, derived from the timestamps of the invitation.
+const (
+	InvitationPending  = "pending"  // The invitation can be accepted.
+	InvitationAccepted = "accepted" // The invitation has been accepted.
+	InvitationRevoked  = "revoked"  // The invitation has been revoked.
+	InvitationExpired  = "expired"  // The invitation has expired before it was accepted.
+)
+
+// Invitation struct represents an invitation to create an account, optionally joining an organization.
+// ID: The UUID of the invitation.
+// OrganizationID: The UUID of the organization the invitee joins. It is the nil UUID for invitations that only create an account.
+// Email: The email the invitation was sent to. It is required and must be a valid email address. It is encrypted when the encryption is enabled.
+// Role: The role the invitee gets in the organization. It is optional and only allowed for organization invitations.
+// Token: The secret token of the invite link. It is never exposed over JSON nor stored, and is only known when the link is issued.
+// TokenHash: The SHA-256 hash of the token, hex-encoded, which the invitation is looked up by. It is never exposed over JSON.
+// InvitedBy: The UUID of the user that created the invitation. It is the nil UUID for invitations created through the admin API.
+// ExpiresAt: The time after which the invitation can no longer be accepted.
+// AcceptedAt: The time the invitation was accepted. It is set to null until then.
+// RevokedAt: The time the invitation was revoked. It is set to null unless it was revoked.
+// Status: The status of the invitation. It is computed and not stored.
+// Metadata: The metadata of the invitation.
+type Invitation struct {
+	ID             uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default"`
+	OrganizationID uuid.UUID `json:"organization_id" gorm:"type:uuid;index"`
+	Email          string    `json:"email" gorm:"not null" validate:"required,email" encrypt:""`
+	Role           string    `json:"role" validate:"omitempty,oneof=member admin owner"`
+	Token          string    `json:"-" gorm:"-"`
+	TokenHash      string    `json:"-" gorm:"unique;not null"`
+	InvitedBy      uuid.UUID `json:"invited_by" gorm:"type:uuid"`
+	ExpiresAt      time.Time `json:"expires_at" gorm:"not null"`
+	AcceptedAt     time.Time `json:"accepted_at" gorm:"default:null"`
+	RevokedAt      time.Time `json:"revoked_at" gorm:"default:null"`
+	Status         string    `json:"status" gorm:"-"`
+	Metadata       Metadata  `json:"metadata" gorm:"embedded;embedded_prefix:meta_"`
+}
+
+// GetTenantID returns the ID of the organization the invitation belongs to.
+func (i Invitation) GetTenantID() uuid.UUID {
+	return i.OrganizationID
+}
+
+// SetTenantID assigns the invitation to the organization with the given ID.
+func (i *Invitation) SetTenantID(id uuid.UUID) {
+	i.OrganizationID = id
+}
+
+// StatusAt computes the status of the invitation at the given time.
+// now: The time to compute the status at.
+// Returns the status of the invitation.
+func (i Invitation) StatusAt(now time.Time) string {
+	switch {
+	case !i.AcceptedAt.IsZero():
+		return InvitationAccepted
+	case !i.RevokedAt.IsZero():
+		return InvitationRevoked
+	case now.After(i.ExpiresAt):
+		return InvitationExpired
+	default:
+		return InvitationPending
+	}
+}
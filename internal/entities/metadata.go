@@ -3,12 +3,14 @@ package entities
 
 import "time" // Time package provides the functionality to work with time.
 
-// Metadata struct represents a metadata entity with fields for the creation time, update time, and last login time of a user.
+// Metadata struct represents a metadata entity with fields for the creation time, update time, last login time and version of a user.
 // CreatedAt: The creation time of the user. It is automatically set when the user is created.
 // UpdatedAt: The update time of the user. It is automatically updated when the user is updated.
 // LastLoginAt: The last login time of the user. It is set to null by default.
+// Version: The version of the record. It is 1 when the record is created and incremented by every update, which fails if the version is outdated.
 type Metadata struct {
 	CreatedAt   time.Time `json:"created_at" db:"created_at" gorm:"autoCreateTime"`     // The creation time of the user.
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at" gorm:"autoUpdateTime"`     // The update time of the user.
 	LastLoginAt time.Time `json:"last_login_at" db:"last_login_at" gorm:"default:null"` // The last login time of the user.
+	Version     int64     `json:"version" db:"version" gorm:"not null;default:1"`       // The version of the record.
 }
@@ -0,0 +1,75 @@
+// Package entities provides the functionality to interact with the organization entities of the application.
+package entities
+
+import (
+	"github.com/google/uuid" // UUID package provides the functionality to generate and use UUIDs.
+)
+
+// Organization roles, from the least to the most privileged.
+const (
+	RoleMember = "member" // A member can read the data of the organization.
+	RoleAdmin  = "admin"  // An admin can additionally manage the members and invitations of the organization.
+	RoleOwner  = "owner"  // An owner can additionally grant the owner role.
+)
+
+// roleRanks maps the organization roles to their privilege rank.
+var roleRanks = map[string]int{
+	RoleMember: 1,
+	RoleAdmin:  2,
+	RoleOwner:  3,
+}
+
+// ValidRole reports whether the role is a known organization role.
+// role: The role to check.
+// Returns true if the role is known.
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// RoleAtLeast reports whether the role is at least as privileged as the minimum role.
+// role: The role to check.
+// minimum: The minimum role.
+// Returns true if the role is at least as privileged as the minimum role.
+func RoleAtLeast(role, minimum string) bool {
+	return ValidRole(role) && roleRanks[role] >= roleRanks[minimum]
+}
+
+// Organization struct represents a customer organization, which is the tenant of the application.
+// ID: The UUID of the organization.
+// Name: The display name of the organization. It is required.
+// Slug: The unique short name of the organization, used as its subdomain. It must be lowercase alphanumeric.
+// ExternalID: The ID of the group in the identity provider, for organizations provisioned over SCIM.
+// Metadata: The metadata of the organization.
+type Organization struct {
+	ID       uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default"`
+	Name     string    `json:"name" gorm:"not null" validate:"required,max=100"`
+	Slug     string    `json:"slug" gorm:"unique;not null" validate:"required,lowercase,alphanum,min=2,max=63"`
+	Metadata Metadata  `json:"metadata" gorm:"embedded;embedded_prefix:meta_"`
+
+	ExternalID string `json:"-" gorm:"index"`
+}
+
+// Membership struct represents the membership of a user in an organization.
+// ID: The UUID of the membership.
+// OrganizationID: The UUID of the organization.
+// UserID: The UUID of the user.
+// Role: The role of the user in the organization.
+// Metadata: The metadata of the membership.
+type Membership struct {
+	ID             uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default"`
+	OrganizationID uuid.UUID `json:"organization_id" gorm:"type:uuid;not null;uniqueIndex:idx_membership_org_user"`
+	UserID         uuid.UUID `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_membership_org_user"`
+	Role           string    `json:"role" gorm:"not null" validate:"required,oneof=member admin owner"`
+	Metadata       Metadata  `json:"metadata" gorm:"embedded;embedded_prefix:meta_"`
+}
+
+// GetTenantID returns the ID of the organization the membership belongs to.
+func (m Membership) GetTenantID() uuid.UUID {
+	return m.OrganizationID
+}
+
+// SetTenantID assigns the membership to the organization with the given ID.
+func (m *Membership) SetTenantID(id uuid.UUID) {
+	m.OrganizationID = id
+}
@@ -0,0 +1,85 @@
+// Package entities provides the functionality to interact with the outbox entities of the application.
+package entities
+
+import (
+	"encoding/json"          // JSON package provides the functionality to encode the payloads of the messages.
+	"github.com/google/uuid" // UUID package provides the functionality to generate and use UUIDs.
+	"time"                   // Time package provides the functionality to work with time.
+)
+
+// The aggregates the domain events are about.
+const (
+	AggregateUser = "user" // The events of a user, whose aggregate ID is the ID of the user.
+)
+
+// The types of the domain events.
+const (
+	EventUserRegistered      = "user.registered"       // A user was created.
+	EventUserUpdated         = "user.updated"          // The username or the email of a user was changed.
+	EventUserPasswordChanged = "user.password_changed" // The password of a user was changed.
+	EventUserDeleted         = "user.deleted"          // A user was removed.
+)
+
+// OutboxMessage struct represents a domain event written to the outbox in the transaction of the change it describes,
+// until the relay publishes it to the broker.
+// ID: The sequence number of the message. The messages of an aggregate are published in this order.
+// AggregateType: The type of the aggregate the event is about, e.g. "user".
+// AggregateID: The ID of the aggregate the event is about.
+// Type: The type of the event, e.g. "user.registered".
+// Payload: The JSON encoding of the event, which may carry personal data. It is encrypted when the encryption is enabled.
+// Published: Whether the message was published.
+// Dead: Whether the message failed to be published too many times. It is kept in the outbox, but is not published any more,
+// and no longer holds back the later messages of its aggregate.
+// Attempts: The number of failed attempts to publish the message.
+// LastError: The error of the last failed attempt.
+// NextAttemptAt: The time from which the message may be published, which is pushed back while a relay publishes it and after a failure.
+// CreatedAt: The time the event happened.
+// UpdatedAt: The time the message was last modified, which is the time it was published once it is.
+// Version: The version of the message, which lets a single relay claim it.
+type OutboxMessage struct {
+	ID            uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
+	AggregateType string    `json:"aggregate_type" gorm:"not null"`
+	AggregateID   string    `json:"aggregate_id" gorm:"not null"`
+	Type          string    `json:"type" gorm:"not null"`
+	Payload       string    `json:"payload" encrypt:""`
+	Published     bool      `json:"published" gorm:"index;not null;default:false"`
+	Dead          bool      `json:"dead" gorm:"not null;default:false"`
+	Attempts      int       `json:"attempts" gorm:"not null;default:0"`
+	LastError     string    `json:"last_error"`
+	NextAttemptAt time.Time `json:"next_attempt_at" gorm:"not null"`
+	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	Version       int64     `json:"version" gorm:"not null;default:1"`
+}
+
+// NewOutboxMessage creates a message of a domain event, which may be published at once.
+// aggregateType: The type of the aggregate the event is about.
+// aggregateID: The ID of the aggregate the event is about.
+// eventType: The type of the event.
+// payload: The event, which is encoded to JSON.
+// Returns the message and an error if the event cannot be encoded.
+func NewOutboxMessage(aggregateType, aggregateID, eventType string, payload interface{}) (OutboxMessage, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return OutboxMessage{}, err
+	}
+	now := time.Now().UTC()
+	return OutboxMessage{
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Type:          eventType,
+		Payload:       string(data),
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}, nil
+}
+
+// UserEvent struct represents the payload of the events of a user. It never carries the password or the token.
+// ID: The UUID of the user.
+// Username: The username of the user.
+// Email: The email of the user.
+type UserEvent struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username,omitempty"`
+	Email    string    `json:"email,omitempty"`
+}
@@ -9,16 +9,28 @@ import (
 // ID: The UUID of the user.
 // Username: The username of the user. It is unique and required, and must be alphanumeric and between 3 and 20 characters long.
 // Password: The password of the user. It is required and must be at least 8 characters long.
-// Email: The email of the user. It is unique and required, and must be a valid email address.
+// Email: The email of the user. It is unique and required, and must be a valid email address. It is encrypted when the encryption is enabled.
+// EmailIndex: The blind index of the email, which the lookups by email and its uniqueness use once it is encrypted. It is nil until it is encrypted.
 // Metadata: The metadata of the user.
-// Token: The token of the user. It is optional.
+// Token: The token of the user. It is optional and never exposed or accepted over JSON. It is encrypted when the encryption is enabled.
+// TokenIndex: The blind index of the token, which the lookups by token use once it is encrypted.
+// TokenOrganizationID: The UUID of the organization the current token is bound to. It is the tenant claim of the token.
+// ExternalID: The ID of the user in the identity provider that provisions it. It is empty for users that signed up themselves.
+// Deactivated: Whether the user has been deactivated by the identity provider. Deactivated users cannot sign in.
 type User struct {
 	ID       uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default"`
 	Username string    `json:"username" gorm:"unique;not null" validate:"required,alphanum,min=3,max=20"`
 	Password string    `json:"password" gorm:"size:255" validate:"required,min=8"`
-	Email    string    `json:"email" gorm:"unique;not null" validate:"required,email"`
+	Email    string    `json:"email" gorm:"unique;not null" validate:"required,email" encrypt:"email_index"`
 	Metadata Metadata  `json:"metadata" gorm:"embedded;embedded_prefix:meta_"`
-	Token    string    `json:"token" gorm:"token" validate:"omitempty"`
+	Token    string    `json:"-" gorm:"token" validate:"omitempty" encrypt:"token_index"`
+
+	EmailIndex *string `json:"-" gorm:"unique"`
+	TokenIndex *string `json:"-" gorm:"index"`
+
+	TokenOrganizationID uuid.UUID `json:"-" gorm:"type:uuid"`
+	ExternalID          string    `json:"-" gorm:"index"`
+	Deactivated         bool      `json:"-" gorm:"not null;default:false"`
 }
 
 // UserLogin struct represents a user login entity with fields for the user's email and password.
@@ -28,3 +40,44 @@ type UserLogin struct {
 	Email    string `json:"email" db:"email" validate:"required,email"`
 	Password string `json:"password" db:"password" validate:"required,gte=6"`
 }
+
+// UserUpdate struct represents the changes an admin makes to the profile of a user.
+// Username: The new username of the user. It is required, and must be alphanumeric and between 3 and 20 characters long.
+// Email: The new email of the user. It is required and must be a valid email address.
+type UserUpdate struct {
+	Username string `json:"username" validate:"required,alphanum,min=3,max=20"`
+	Email    string `json:"email" validate:"required,email"`
+}
+
+// UserSearch struct represents a search of the users by their username or email.
+// Q: The text to search. Every word of it must start a word of the username or the email of the found users, e.g. "ali exa" finds alice@example.com.
+// Limit: The maximum number of users to return.
+// Offset: The number of users to skip.
+type UserSearch struct {
+	Q      string `query:"q"`
+	Limit  int    `query:"limit"`
+	Offset int    `query:"offset"`
+}
+
+// UserSearchHit struct represents a user found by a search.
+// User: The found user.
+// Rank: The relevance of the user to the search, higher is more relevant. The ranks of different searches are not comparable.
+// Highlights: The username and the email, keyed by "username" and "email", HTML-escaped and with the matching parts of their words
+// wrapped in <mark> tags. A field is left out if it does not match.
+type UserSearchHit struct {
+	User       User              `json:"user"`
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// UserSearchResult struct represents a page of the users found by a search.
+// Hits: The found users, most relevant first.
+// Total: The number of users the search finds, regardless of the page.
+// Limit: The maximum number of users of the page.
+// Offset: The number of users skipped before the page.
+type UserSearchResult struct {
+	Hits   []UserSearchHit `json:"hits"`
+	Total  int64           `json:"total"`
+	Limit  int             `json:"limit"`
+	Offset int             `json:"offset"`
+}
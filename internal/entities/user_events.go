@@ -0,0 +1,45 @@
+// Package entities provides the functionality to interact with the domain events the auth module publishes on the event bus.
+package entities
+
+import (
+	"github.com/google/uuid" // UUID package provides the functionality to generate and use UUIDs.
+	"time"                   // Time package provides the functionality to work with time.
+)
+
+// UserRegistered struct represents the event of a user that signed up.
+// UserID: The UUID of the user.
+// Username: The username of the user.
+// Email: The email of the user.
+// OccurredAt: The time the user was registered.
+type UserRegistered struct {
+	UserID     uuid.UUID `json:"user_id"`
+	Username   string    `json:"username"`
+	Email      string    `json:"email"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// UserLoggedIn struct represents the event of a user that logged in.
+// UserID: The UUID of the user.
+// Email: The email the user logged in with.
+// IP: The IP address of the client.
+// OccurredAt: The time the user logged in.
+type UserLoggedIn struct {
+	UserID     uuid.UUID `json:"user_id"`
+	Email      string    `json:"email"`
+	IP         string    `json:"ip"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// LoginFailed struct represents the event of a login that was rejected.
+// UserID: The UUID of the user the email belongs to. It is uuid.Nil if no user was found.
+// Email: The email the login was attempted with.
+// IP: The IP address of the client.
+// Reason: The reason the login was rejected.
+// OccurredAt: The time the login was attempted.
+type LoginFailed struct {
+	UserID     uuid.UUID `json:"user_id"`
+	Email      string    `json:"email"`
+	IP         string    `json:"ip"`
+	Reason     string    `json:"reason"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
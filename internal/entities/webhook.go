@@ -0,0 +1,116 @@
+// Package entities provides the functionality to interact with the webhook entities of the application.
+package entities
+
+import (
+	"github.com/google/uuid" // UUID package provides the functionality to generate and use UUIDs.
+	"strings"                // Strings package provides the functionality to match the event types.
+	"time"                   // Time package provides the functionality to work with time.
+)
+
+// Webhook delivery statuses.
+const (
+	WebhookDeliveryPending   = "pending"   // The delivery is sent, or sent again after a failure, once it is due.
+	WebhookDeliverySucceeded = "succeeded" // The endpoint accepted the delivery.
+	WebhookDeliveryDead      = "dead"      // The delivery failed too many times, or its endpoint was disabled, and is only sent again on request.
+)
+
+// WebhookEndpoint struct represents an HTTP endpoint the domain events are delivered to.
+// ID: The UUID of the endpoint.
+// URL: The HTTP or HTTPS URL the events are posted to. It is required.
+// Description: The description of the endpoint.
+// Events: The types of the events the endpoint receives, e.g. "user.registered", "user.*" or "*". It receives every event if it is empty.
+// EventTypes: The comma separated types of the events, as they are stored. It is never exposed over JSON.
+// Disabled: Whether the deliveries to the endpoint are suspended.
+// Secret: The secret the deliveries are signed with. It is only exposed when it is generated, and encrypted when the encryption is enabled.
+// PreviousSecret: The secret that was rotated out, which the deliveries are signed with as well until it expires. It is encrypted when the encryption is enabled.
+// PreviousSecretExpiresAt: The time the previous secret stops being used.
+// CreatedAt: The creation time of the endpoint.
+// UpdatedAt: The update time of the endpoint.
+// Version: The version of the endpoint.
+type WebhookEndpoint struct {
+	ID                      uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default"`
+	URL                     string    `json:"url" gorm:"not null" validate:"required,http_url"`
+	Description             string    `json:"description"`
+	Events                  []string  `json:"events" gorm:"-" validate:"dive,required"`
+	EventTypes              string    `json:"-"`
+	Disabled                bool      `json:"disabled" gorm:"not null;default:false"`
+	Secret                  string    `json:"-" gorm:"not null" encrypt:""`
+	PreviousSecret          string    `json:"-" encrypt:""`
+	PreviousSecretExpiresAt time.Time `json:"previous_secret_expires_at" gorm:"default:null"`
+	CreatedAt               time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt               time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	Version                 int64     `json:"version" gorm:"not null;default:1"`
+}
+
+// Subscribes reports whether the endpoint receives the events of a type.
+// eventType: The type of the event.
+// Returns true if the endpoint receives every event, the type, or a wildcard such as "user.*" that matches it.
+func (e WebhookEndpoint) Subscribes(eventType string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, event := range e.Events {
+		if event == "*" || event == eventType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(event, "*"); ok && strings.HasPrefix(eventType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// SecretsAt returns the secrets the deliveries are signed with at the given time.
+// now: The time of the delivery.
+// Returns the secret, and the previous secret until it expires.
+func (e WebhookEndpoint) SecretsAt(now time.Time) []string {
+	if e.PreviousSecret != "" && now.Before(e.PreviousSecretExpiresAt) {
+		return []string{e.Secret, e.PreviousSecret}
+	}
+	return []string{e.Secret}
+}
+
+// WebhookDelivery struct represents the delivery of a domain event to a webhook endpoint, and the outcome of its last attempt.
+// ID: The UUID of the delivery, which is derived from the endpoint and the event so that an event is delivered once to each endpoint.
+// It is sent as the Webhook-Id header, which the receivers may use to discard the duplicates.
+// EndpointID: The UUID of the endpoint.
+// EventID: The ID of the event, which is the sequence number of its outbox message.
+// EventType: The type of the event.
+// Payload: The body that is posted to the endpoint, which may carry personal data. It is encrypted when the encryption is enabled.
+// Status: The status of the delivery: pending, succeeded or dead.
+// Attempts: The number of attempts to deliver the event.
+// NextAttemptAt: The time from which the delivery may be sent, which is pushed back while it is sent and after a failure.
+// LastAttemptAt: The time of the last attempt.
+// ResponseStatus: The HTTP status code of the response to the last attempt. It is zero if no response was received.
+// ResponseBody: The beginning of the body of the response to the last attempt.
+// LastError: The error of the last failed attempt.
+// CreatedAt: The time the event was enqueued.
+// UpdatedAt: The update time of the delivery.
+// Version: The version of the delivery, which lets a single worker claim it.
+type WebhookDelivery struct {
+	ID             uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default"`
+	EndpointID     uuid.UUID `json:"endpoint_id" gorm:"type:uuid;index;not null"`
+	EventID        string    `json:"event_id" gorm:"not null"`
+	EventType      string    `json:"event_type" gorm:"not null"`
+	Payload        string    `json:"payload" encrypt:""`
+	Status         string    `json:"status" gorm:"index;not null"`
+	Attempts       int       `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt  time.Time `json:"next_attempt_at" gorm:"not null"`
+	LastAttemptAt  time.Time `json:"last_attempt_at" gorm:"default:null"`
+	ResponseStatus int       `json:"response_status"`
+	ResponseBody   string    `json:"response_body"`
+	LastError      string    `json:"last_error"`
+	CreatedAt      time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	Version        int64     `json:"version" gorm:"not null;default:1"`
+}
+
+// WebhookDeliveryFilter struct represents the criteria used to list the deliveries of an endpoint.
+// Status: Only deliveries with this status are returned if it is set.
+// Limit: The maximum number of deliveries to return.
+// Offset: The number of deliveries to skip.
+type WebhookDeliveryFilter struct {
+	Status string `query:"status"`
+	Limit  int    `query:"limit"`
+	Offset int    `query:"offset"`
+}
@@ -0,0 +1,31 @@
+// Package audit provides the functionality to interact with the security audit log.
+package audit
+
+import "context"
+
+// clientKey is the context key under which the client information is stored.
+type clientKey struct{}
+
+// Client struct represents the client that issued a request.
+// IP: The IP address of the client.
+// UserAgent: The user agent of the client.
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// WithClient returns a copy of the context that carries the client information.
+// ctx: The parent context.
+// client: The client information to store.
+// Returns the new context.
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// ClientFromContext retrieves the client information from the context.
+// ctx: The context to read from.
+// Returns the client information, which is empty if the context does not carry any.
+func ClientFromContext(ctx context.Context) Client {
+	client, _ := ctx.Value(clientKey{}).(Client)
+	return client
+}
@@ -0,0 +1,16 @@
+// Package audit provides the functionality to interact with the security audit log.
+package audit
+
+import "github.com/labstack/echo/v4"
+
+// Handlers is an interface that defines the methods required for handling audit log operations.
+// It includes methods for querying and verifying the audit log.
+type Handlers interface {
+	// Query handles the retrieval of audit events.
+	// Returns an echo.HandlerFunc that handles the HTTP request for querying the audit log.
+	Query() echo.HandlerFunc
+
+	// Verify handles the verification of the audit chain.
+	// Returns an echo.HandlerFunc that handles the HTTP request for verifying the audit log.
+	Verify() echo.HandlerFunc
+}
@@ -0,0 +1,74 @@
+// Package http provides the functionality to handle HTTP requests for the audit module.
+package http
+
+import (
+	"fmt"
+	"github.com/labstack/echo/v4"                                    // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/config"                 // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"      // Entities package provides the functionality to interact with the entities of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/audit" // Audit package provides the functionality to interact with the audit module.
+	"net/http"
+)
+
+// AuditHandlers struct represents audit handlers that provide methods for handling HTTP requests for the audit module.
+type AuditHandlers struct {
+	cfg     *config.Config // The configuration for the audit handlers.
+	auditUC audit.UseCase  // The audit use case for the audit handlers.
+}
+
+// NewAuditHandlers creates new audit handlers with the provided configuration and audit use case.
+// cfg: The configuration for the audit handlers.
+// auditUC: The audit use case for the audit handlers.
+// Returns an AuditHandlers object.
+func NewAuditHandlers(cfg *config.Config, auditUC audit.UseCase) *AuditHandlers {
+	return &AuditHandlers{
+		cfg:     cfg,
+		auditUC: auditUC,
+	}
+}
+
+// Query retrieves the audit events matching the query parameters.
+// @route GET /admin/audit
+// @group Audit
+// @param {string} actor.query - Actor of the events
+// @param {string} action.query - Action of the events
+// @param {string} outcome.query - Outcome of the events
+// @param {string} ip.query - IP address of the events
+// @param {string} from.query - RFC 3339 time the events happened at or after
+// @param {string} to.query - RFC 3339 time the events happened before
+// @param {integer} limit.query - Maximum number of events
+// @param {integer} offset.query - Number of events to skip
+// @returns {Array} 200 - An array of audit events, most recent first
+// @returns {object} 400 - The query parameters are invalid.
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 500 - Server error
+func (h *AuditHandlers) Query() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var filter entities.AuditFilter
+		if err := c.Bind(&filter); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "failed to bind audit filter")
+		}
+
+		events, err := h.auditUC.Query(c.Request().Context(), filter)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to query audit events: %v", err))
+		}
+		return c.JSON(http.StatusOK, events)
+	}
+}
+
+// Verify verifies the audit chain.
+// @route GET /admin/audit/verify
+// @group Audit
+// @returns {object} 200 - The result of the verification
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 500 - Server error
+func (h *AuditHandlers) Verify() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		result, err := h.auditUC.Verify(c.Request().Context())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to verify audit log: %v", err))
+		}
+		return c.JSON(http.StatusOK, result)
+	}
+}
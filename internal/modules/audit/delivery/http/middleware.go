@@ -0,0 +1,23 @@
+// Package http provides the functionality to handle HTTP requests for the audit module.
+package http
+
+import (
+	"github.com/labstack/echo/v4"                                    // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/audit" // Audit package provides the functionality to interact with the audit module.
+)
+
+// ClientInfo creates a middleware that stores the IP address and user agent of the client in the request context,
+// so that the use cases can attach them to the audit events they record.
+// Returns an echo.MiddlewareFunc.
+func ClientInfo() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := audit.WithClient(c.Request().Context(), audit.Client{
+				IP:        c.RealIP(),
+				UserAgent: c.Request().UserAgent(),
+			})
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
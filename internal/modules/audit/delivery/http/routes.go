@@ -0,0 +1,29 @@
+// Package http provides the functionality to map the routes of the audit module over HTTP.
+package http
+
+import (
+	"github.com/labstack/echo/v4"                                    // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/audit" // Audit package provides the functionality to interact with the audit module.
+)
+
+// MapAuditRoutes maps the audit routes to the provided Echo group with the provided audit handlers.
+// auditGroup: The Echo group to map the routes to. It is expected to be protected by the admin authentication.
+// h: The audit handlers to use for the routes.
+// The routes include:
+// GET /: Retrieves the audit events matching the query parameters.
+// GET /verify: Verifies the audit chain.
+func MapAuditRoutes(auditGroup *echo.Group, h audit.Handlers) {
+	// @route GET /admin/audit
+	// @group Audit
+	// @returns {Array} 200 - An array of audit events
+	// @returns {object} 401 - Unauthorized access
+	// @returns {object} 500 - Server error
+	auditGroup.GET("", h.Query())
+
+	// @route GET /admin/audit/verify
+	// @group Audit
+	// @returns {object} 200 - The result of the verification
+	// @returns {object} 401 - Unauthorized access
+	// @returns {object} 500 - Server error
+	auditGroup.GET("/verify", h.Verify())
+}
@@ -0,0 +1,33 @@
+// Package delivery provides the functionality to deliver the responses of the audit module.
+package delivery
+
+import (
+	"github.com/labstack/echo/v4"                                                  // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/config"                               // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/app"                         // App package provides the functionality to create and manage the server of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/audit"               // Audit package provides the functionality to interact with the audit module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/audit/delivery/http" // HTTP package provides the functionality to deliver the responses of the audit module over HTTP.
+)
+
+// AuditDelivery struct represents an audit delivery that provides methods for delivering the responses of the audit module.
+// It includes an AuditHandlers object for handling the responses and a function for setting up the routes.
+type AuditDelivery struct {
+	Handlers        *http.AuditHandlers   // The handlers for the audit responses.
+	SetupRoutesFunc func(echo *echo.Echo) // The function for setting up the routes.
+}
+
+// NewAuditDelivery creates a new audit delivery with the provided configuration and audit use case.
+// cfg: The configuration for the audit delivery.
+// uc: The audit use case for the audit delivery.
+// Returns an AuditDelivery object.
+func NewAuditDelivery(cfg *config.Config, uc audit.UseCase) *AuditDelivery {
+	handlers := http.NewAuditHandlers(cfg, uc) // Creates new audit handlers with the provided configuration and audit use case.
+
+	// Returns a new AuditDelivery object with the created handlers and a function for setting up the routes.
+	return &AuditDelivery{
+		Handlers: handlers,
+		SetupRoutesFunc: func(e *echo.Echo) {
+			http.MapAuditRoutes(e.Group("/admin/audit", app.AdminAuth(cfg)), handlers) // Maps the audit routes to the admin-protected "/admin/audit" group.
+		},
+	}
+}
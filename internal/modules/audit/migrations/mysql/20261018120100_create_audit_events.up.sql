@@ -0,0 +1,20 @@
+-- The audit events table, as it was created before the migrations were versioned, so that an existing database is adopted.
+-- The hashes cover the timestamps to the microsecond, so the timestamps keep six fractional digits.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id bigint unsigned NOT NULL AUTO_INCREMENT,
+    actor varchar(191),
+    action varchar(191) NOT NULL,
+    target longtext,
+    ip longtext,
+    user_agent longtext,
+    outcome varchar(191) NOT NULL,
+    reason longtext,
+    timestamp datetime(6) NOT NULL,
+    prev_hash varchar(64),
+    hash varchar(64) NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_audit_events_timestamp (timestamp),
+    KEY idx_audit_events_outcome (outcome),
+    KEY idx_audit_events_action (action),
+    KEY idx_audit_events_actor (actor)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,3 @@
+ALTER TABLE audit_events DROP KEY idx_audit_events_actor_index;
+ALTER TABLE audit_events DROP COLUMN actor_index;
+ALTER TABLE audit_events MODIFY actor varchar(191);
@@ -0,0 +1,6 @@
+-- The blind index of the encrypted actor, which the lookups by actor use once it is encrypted.
+-- It stays NULL until the encryption is enabled and the events are written or encrypted again.
+-- The actors are widened, since their encryption is longer than the actors.
+ALTER TABLE audit_events MODIFY actor varchar(512);
+ALTER TABLE audit_events ADD COLUMN actor_index varchar(64) NULL;
+ALTER TABLE audit_events ADD KEY idx_audit_events_actor_index (actor_index);
@@ -0,0 +1,18 @@
+-- The audit events table, as it was created before the migrations were versioned, so that an existing database is adopted.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id bigserial PRIMARY KEY,
+    actor text,
+    action text NOT NULL,
+    target text,
+    ip text,
+    user_agent text,
+    outcome text NOT NULL,
+    reason text,
+    timestamp timestamptz NOT NULL,
+    prev_hash varchar(64),
+    hash varchar(64) NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_timestamp ON audit_events (timestamp);
+CREATE INDEX IF NOT EXISTS idx_audit_events_outcome ON audit_events (outcome);
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events (actor);
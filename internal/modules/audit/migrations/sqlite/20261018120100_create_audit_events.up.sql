@@ -0,0 +1,18 @@
+-- The audit events table, as it was created before the migrations were versioned, so that an existing database is adopted.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    actor text,
+    action text NOT NULL,
+    target text,
+    ip text,
+    user_agent text,
+    outcome text NOT NULL,
+    reason text,
+    timestamp datetime NOT NULL,
+    prev_hash text,
+    hash text NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_timestamp ON audit_events (timestamp);
+CREATE INDEX IF NOT EXISTS idx_audit_events_outcome ON audit_events (outcome);
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events (actor);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_audit_events_actor_index;
+ALTER TABLE audit_events DROP COLUMN actor_index;
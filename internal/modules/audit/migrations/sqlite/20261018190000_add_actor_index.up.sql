@@ -0,0 +1,4 @@
+-- The blind index of the encrypted actor, which the lookups by actor use once it is encrypted.
+-- It stays NULL until the encryption is enabled and the events are written or encrypted again.
+ALTER TABLE audit_events ADD COLUMN actor_index text;
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_index ON audit_events (actor_index);
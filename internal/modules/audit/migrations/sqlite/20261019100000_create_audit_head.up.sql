@@ -0,0 +1,11 @@
+-- The head of the audit chain, which every append moves to the appended event, so that the removal of the most recent events is detected.
+-- It starts at the last existing event, and counts the existing events.
+CREATE TABLE IF NOT EXISTS audit_heads (
+    id integer PRIMARY KEY,
+    last_id integer NOT NULL DEFAULT 0,
+    hash text,
+    count integer NOT NULL DEFAULT 0,
+    version integer NOT NULL DEFAULT 1
+);
+INSERT INTO audit_heads (id, last_id, hash, count)
+SELECT 1, COALESCE(MAX(id), 0), (SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1), COUNT(*) FROM audit_events;
@@ -0,0 +1,92 @@
+// Package module provides the functionality to interact with the audit module.
+package module
+
+import (
+	"context"                                                                      // Context package provides the functionality to pass deadlines and cancel signals to the retention worker.
+	"github.com/labstack/echo/v4"                                                  // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/config"                               // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/app"                         // App package provides the functionality to create and manage the server of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/audit"               // Audit package provides the functionality to interact with the audit module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/audit/delivery"      // Delivery package provides the functionality to deliver the responses of the audit module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/audit/delivery/http" // HTTP package provides the functionality to deliver the responses of the audit module over HTTP.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/audit/migrations"    // Migrations package provides the SQL migrations of the schema of the audit module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/audit/usecase"       // Usecase package provides the functionality to interact with the use cases of the audit module.
+	auditstorage "github.com/nikita-voronoy/go-clean-arch/internal/storage/audit"  // Audit storage package provides the functionality to interact with the audit event storage.
+	"go.uber.org/fx"                                                               // Fx is a framework for Go that provides the building blocks for your service architectures.
+	"log"                                                                          // Log package provides the functionality to implement logging.
+	"time"                                                                         // Time package provides the functionality to schedule the retention worker.
+)
+
+// retentionInterval is the interval at which the audit events that fall out of the retention window are purged.
+const retentionInterval = time.Hour
+
+// Migrations is a Fx option that registers the SQL migrations of the audit module with the migrations group.
+var Migrations = fx.Supply(fx.Annotated{Group: "migrations", Target: migrations.Source})
+
+// Module is a Fx options group that provides and invokes the necessary dependencies for the audit module.
+var Module = fx.Options(
+	Migrations, // Registers the SQL migrations of the audit module.
+	fx.Provide(
+		auditstorage.NewAuditRepository, // Provides a new audit repository.
+		usecase.NewAuditUC,              // Provides a new audit use case.
+		usecase.NewRecorder,             // Provides the audit recorder used by the other modules.
+		http.NewAuditHandlers,           // Provides new audit handlers.
+		delivery.NewAuditDelivery,       // Provides a new audit delivery.
+	),
+	fx.Invoke(registerAuditRoutes), // Invokes the function to register the audit routes.
+	fx.Invoke(registerRetention),   // Invokes the function to register the retention worker.
+)
+
+// registerAuditRoutes registers the audit routes with the provided Echo instance and audit handlers.
+// It also installs the middleware that makes the client information available to the audit recorder.
+// e: The Echo instance to register the routes with.
+// cfg: The configuration that contains the admin API key.
+// handlers: The audit handlers to use for the routes.
+func registerAuditRoutes(e *echo.Echo, cfg *config.Config, handlers *http.AuditHandlers) {
+	e.Use(http.ClientInfo())                                                   // Stores the client information of every request in its context.
+	http.MapAuditRoutes(e.Group("/admin/audit", app.AdminAuth(cfg)), handlers) // Maps the audit routes to the admin-protected "/admin/audit" group.
+}
+
+// registerRetention starts a worker that periodically purges the audit events older than the configured retention.
+// lc: The lifecycle the worker is bound to.
+// cfg: The configuration that contains the retention.
+// uc: The audit use case used to purge the events.
+func registerRetention(lc fx.Lifecycle, cfg *config.Config, uc audit.UseCase) {
+	if cfg.Audit.RetentionDays <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				ticker := time.NewTicker(retentionInterval)
+				defer ticker.Stop()
+				for {
+					if removed, err := uc.Purge(ctx); err != nil {
+						log.Printf("Failed to purge audit events: %v\n", err)
+					} else if removed > 0 {
+						log.Printf("Purged %d audit events\n", removed)
+					}
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return stopCtx.Err()
+			}
+		},
+	})
+}
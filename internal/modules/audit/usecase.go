@@ -0,0 +1,39 @@
+// Package audit provides the functionality to interact with the security audit log.
+package audit
+
+import (
+	"context"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+)
+
+// Recorder is an interface that defines the method the use cases of other modules use to write to the audit log.
+type Recorder interface {
+	// Record appends an event to the audit log.
+	// The IP address and user agent are taken from the context if the event does not set them.
+	// ctx: The context for the operation.
+	// event: The audit event to record.
+	// Returns an error if the operation fails.
+	Record(ctx context.Context, event entities.AuditEvent) error
+}
+
+// UseCase is an interface that defines the methods required for audit log operations.
+// It includes methods for recording, querying, verifying and purging audit events.
+type UseCase interface {
+	Recorder
+
+	// Query retrieves the audit events matching the filter, most recent first.
+	// ctx: The context for the operation.
+	// filter: The criteria of the audit events to retrieve.
+	// Returns the audit events and an error if the operation fails.
+	Query(ctx context.Context, filter entities.AuditFilter) ([]entities.AuditEvent, error)
+
+	// Verify walks the audit chain and checks that no event was modified, removed or reordered.
+	// ctx: The context for the operation.
+	// Returns the result of the verification and an error if the operation fails.
+	Verify(ctx context.Context) (entities.AuditVerification, error)
+
+	// Purge removes the audit events that are older than the configured retention.
+	// ctx: The context for the operation.
+	// Returns the number of removed audit events and an error if the operation fails.
+	Purge(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,260 @@
+// Package usecase provides the functionality to interact with the security audit log.
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/audit"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"time"
+)
+
+const (
+	// defaultQueryLimit is the number of events returned by Query when the filter sets no limit.
+	defaultQueryLimit = 100
+	// maxQueryLimit is the maximum number of events returned by Query.
+	maxQueryLimit = 1000
+	// verifyBatchSize is the number of events read at once while verifying the chain.
+	verifyBatchSize = 500
+	// headAttempts is the number of attempts to move the head of the chain, which another writer may move first.
+	headAttempts = 5
+)
+
+// AuditUseCase struct represents an audit use case that provides methods for audit log operations.
+// Every event is appended in a transaction that moves the head of the chain to it, so that concurrent writers,
+// in this process or in others, cannot chain two events to the same one. The writer that loses the race appends again
+// on the new head, so that no lock is held across the transaction, which the caller may already be running in.
+type AuditUseCase struct {
+	cfg  *config.Config
+	repo storage.AuditRepository
+	tx   storage.Transactor
+}
+
+// NewAuditUC creates a new audit use case with the provided configuration, audit repository and transactor.
+// cfg: The configuration for the audit use case.
+// repo: The audit repository for the audit use case.
+// tx: The transactor that appends an event and moves the head of the chain atomically.
+// Returns an audit.UseCase object.
+func NewAuditUC(cfg *config.Config, repo storage.AuditRepository, tx storage.Transactor) audit.UseCase {
+	return &AuditUseCase{
+		cfg:  cfg,
+		repo: repo,
+		tx:   tx,
+	}
+}
+
+// NewRecorder exposes the audit use case as the recorder used by the other modules.
+// uc: The audit use case.
+// Returns an audit.Recorder object.
+func NewRecorder(uc audit.UseCase) audit.Recorder {
+	return uc
+}
+
+// Record appends an event to the audit log.
+// ctx: The context for the operation.
+// event: The audit event to record.
+// Returns an error if the operation fails.
+func (uc AuditUseCase) Record(ctx context.Context, event entities.AuditEvent) error {
+	client := audit.ClientFromContext(ctx)
+	if event.IP == "" {
+		event.IP = client.IP
+	}
+	if event.UserAgent == "" {
+		event.UserAgent = client.UserAgent
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	event.ID = 0
+	// The timestamp is part of the hash, so it is truncated to the precision every database stores.
+	event.Timestamp = event.Timestamp.UTC().Truncate(time.Microsecond)
+
+	return uc.moveHead(ctx, func(ctx context.Context, head entities.AuditHead) (entities.AuditHead, error) {
+		event.PrevHash = head.Hash
+		event.Hash = event.ComputeHash()
+		appended, err := uc.repo.Append(ctx, event)
+		if err != nil {
+			return entities.AuditHead{}, err
+		}
+		head.LastID = appended.ID
+		head.Hash = appended.Hash
+		head.Count++
+		return head, nil
+	})
+}
+
+// moveHead modifies the chain and moves its head in a transaction, which is run again if another writer moved the head first.
+// The head is created from the last event and the number of events if it does not exist yet.
+// ctx: The context for the operation.
+// fn: The modification of the chain, which is given the current head and returns the moved head.
+// Returns an error if the operation fails, or if the head kept being moved by other writers.
+func (uc AuditUseCase) moveHead(ctx context.Context, fn func(ctx context.Context, head entities.AuditHead) (entities.AuditHead, error)) error {
+	for attempt := 1; ; attempt++ {
+		err := uc.tx.WithTx(ctx, func(ctx context.Context) error {
+			head, found, err := uc.repo.Head(ctx)
+			if err != nil {
+				return err
+			}
+			if !found {
+				if head, err = uc.initialHead(ctx); err != nil {
+					return err
+				}
+			}
+			if head, err = fn(ctx, head); err != nil {
+				return err
+			}
+			_, err = uc.repo.SaveHead(ctx, head)
+			return err
+		})
+		if attempt == headAttempts || !errors.Is(err, database.ErrConflict) {
+			return err
+		}
+	}
+}
+
+// initialHead builds the head of a chain that has none, from the events appended before the heads were introduced.
+func (uc AuditUseCase) initialHead(ctx context.Context) (entities.AuditHead, error) {
+	last, found, err := uc.repo.Last(ctx)
+	if err != nil || !found {
+		return entities.AuditHead{}, err
+	}
+	count, err := uc.repo.Count(ctx)
+	if err != nil {
+		return entities.AuditHead{}, err
+	}
+	return entities.AuditHead{LastID: last.ID, Hash: last.Hash, Count: count}, nil
+}
+
+// Query retrieves the audit events matching the filter, most recent first.
+// ctx: The context for the operation.
+// filter: The criteria of the audit events to retrieve.
+// Returns the audit events and an error if the operation fails.
+func (uc AuditUseCase) Query(ctx context.Context, filter entities.AuditFilter) ([]entities.AuditEvent, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultQueryLimit
+	}
+	if filter.Limit > maxQueryLimit {
+		filter.Limit = maxQueryLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	return uc.repo.Find(ctx, filter)
+}
+
+// Verify walks the audit chain and checks that no event was modified, removed or reordered.
+// The first remaining event anchors the chain, since older events may have been purged by the retention.
+// The chain must end at its head and hold as many events as the head counts, so that the removal of the most recent
+// or the oldest events outside of a purge is detected too. The events appended after the head was read are not checked,
+// and the verification is run again if a purge moved the head meanwhile.
+// ctx: The context for the operation.
+// Returns the result of the verification and an error if the operation fails.
+func (uc AuditUseCase) Verify(ctx context.Context) (entities.AuditVerification, error) {
+	for attempt := 1; ; attempt++ {
+		head, found, err := uc.repo.Head(ctx)
+		if err != nil {
+			return entities.AuditVerification{}, err
+		}
+		result, err := uc.verify(ctx, head, found)
+		if err != nil || result.Valid || attempt == headAttempts {
+			return result, err
+		}
+		current, _, err := uc.repo.Head(ctx)
+		if err != nil {
+			return entities.AuditVerification{}, err
+		}
+		if current.Version == head.Version {
+			return result, nil
+		}
+	}
+}
+
+// verify walks the audit chain up to its head.
+// ctx: The context for the operation.
+// head: The head of the chain.
+// found: Whether the chain has a head. Only an empty chain has none, since the first append creates it and the migrations create it for the existing events.
+// Returns the result of the verification and an error if the operation fails.
+func (uc AuditUseCase) verify(ctx context.Context, head entities.AuditHead, found bool) (entities.AuditVerification, error) {
+	result := entities.AuditVerification{Valid: true}
+
+	var afterID, firstID uint64
+	var prevHash string
+walk:
+	for {
+		events, err := uc.repo.ReadAfter(ctx, afterID, verifyBatchSize)
+		if err != nil {
+			return entities.AuditVerification{}, err
+		}
+		for _, event := range events {
+			if found && event.ID > head.LastID {
+				break walk
+			}
+			if result.Checked == 0 {
+				prevHash = event.PrevHash
+				firstID = event.ID
+			}
+			if event.PrevHash != prevHash || event.ComputeHash() != event.Hash {
+				result.Valid = false
+				result.BrokenAt = event.ID
+				return result, nil
+			}
+			result.Checked++
+			prevHash = event.Hash
+			afterID = event.ID
+		}
+		if len(events) < verifyBatchSize {
+			break
+		}
+	}
+
+	switch {
+	case !found && result.Checked > 0:
+		// The head was removed: the end of the chain cannot be confirmed, so the events after the last one are assumed removed.
+		result.Valid = false
+		result.BrokenAt = afterID + 1
+	case !found:
+	case result.Checked > 0 && prevHash != head.Hash:
+		// The most recent events were removed: the chain breaks at the first of them.
+		result.Valid = false
+		result.BrokenAt = afterID + 1
+	case int64(result.Checked) != head.Count:
+		// The oldest events were removed outside of a purge: the chain breaks at the first remaining one.
+		result.Valid = false
+		result.BrokenAt = max(firstID, 1)
+	}
+	return result, nil
+}
+
+// Purge removes the audit events that are older than the configured retention, up to the most recent of them,
+// so that only a prefix of the chain is removed.
+// ctx: The context for the operation.
+// Returns the number of removed audit events and an error if the operation fails.
+func (uc AuditUseCase) Purge(ctx context.Context) (int64, error) {
+	if uc.cfg.Audit.RetentionDays <= 0 {
+		return 0, nil
+	}
+	before := time.Now().AddDate(0, 0, -uc.cfg.Audit.RetentionDays)
+
+	var removed int64
+	err := uc.moveHead(ctx, func(ctx context.Context, head entities.AuditHead) (entities.AuditHead, error) {
+		// The chain is ordered by sequence number rather than by timestamp, so the events up to the last one that is old enough
+		// are removed, which leaves a chain that starts at the next one.
+		cutoff, found, err := uc.repo.LastBefore(ctx, before)
+		if err != nil || !found {
+			removed = 0
+			return head, err
+		}
+		if removed, err = uc.repo.DeleteThrough(ctx, cutoff.ID); err != nil {
+			return entities.AuditHead{}, err
+		}
+		head.Count -= removed
+		return head, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
@@ -0,0 +1,240 @@
+package usecase
+
+import (
+	"context"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/audit"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	auditstorage "github.com/nikita-voronoy/go-clean-arch/internal/storage/audit"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/memory"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestUC(t *testing.T, retentionDays int) (audit.UseCase, *memory.Database) {
+	cfg := &config.Config{
+		Audit: config.AuditConfig{RetentionDays: retentionDays},
+	}
+	db := memory.NewDatabase()
+	return NewAuditUC(cfg, auditstorage.NewAuditRepository(db), storage.NewTransactor(db)), db
+}
+
+func TestRecordChainsEvents(t *testing.T) {
+	uc, _ := newTestUC(t, 0)
+	ctx := audit.WithClient(context.Background(), audit.Client{IP: "10.0.0.1", UserAgent: "test"})
+
+	for _, outcome := range []string{entities.AuditOutcomeSuccess, entities.AuditOutcomeFailure, entities.AuditOutcomeSuccess} {
+		err := uc.Record(ctx, entities.AuditEvent{Actor: "user@example.com", Action: entities.AuditActionLogin, Outcome: outcome})
+		require.NoError(t, err, "Failed to record event")
+	}
+
+	events, err := uc.Query(ctx, entities.AuditFilter{})
+	require.NoError(t, err, "Failed to query events")
+	require.Len(t, events, 3)
+	assert.Equal(t, "10.0.0.1", events[0].IP)
+	assert.Equal(t, "test", events[0].UserAgent)
+	assert.Equal(t, events[1].Hash, events[0].PrevHash)
+	assert.Equal(t, events[2].Hash, events[1].PrevHash)
+	assert.Empty(t, events[2].PrevHash)
+
+	result, err := uc.Verify(ctx)
+	require.NoError(t, err, "Failed to verify chain")
+	assert.True(t, result.Valid)
+	assert.Equal(t, 3, result.Checked)
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	uc, db := newTestUC(t, 0)
+	ctx := context.Background()
+
+	for _, actor := range []string{"alice", "bob", "carol"} {
+		require.NoError(t, uc.Record(ctx, entities.AuditEvent{Actor: actor, Action: entities.AuditActionRegister, Outcome: entities.AuditOutcomeSuccess}))
+	}
+
+	var event entities.AuditEvent
+	require.NoError(t, db.Read(ctx, &event, query.Eq("actor", "bob")))
+	event.Outcome = entities.AuditOutcomeFailure
+	require.NoError(t, db.Update(ctx, &event))
+
+	result, err := uc.Verify(ctx)
+	require.NoError(t, err, "Failed to verify chain")
+	assert.False(t, result.Valid)
+	assert.Equal(t, event.ID, result.BrokenAt)
+}
+
+func TestVerifyDetectsRemovedEvents(t *testing.T) {
+	uc, db := newTestUC(t, 0)
+	ctx := context.Background()
+
+	for _, actor := range []string{"alice", "bob", "carol", "dave"} {
+		require.NoError(t, uc.Record(ctx, entities.AuditEvent{Actor: actor, Action: entities.AuditActionRegister, Outcome: entities.AuditOutcomeSuccess}))
+	}
+	var first, last entities.AuditEvent
+	require.NoError(t, db.Read(ctx, &first, query.Eq("actor", "alice")))
+	require.NoError(t, db.Read(ctx, &last, query.Eq("actor", "dave")))
+
+	require.NoError(t, db.Delete(ctx, entities.AuditEvent{}, last.ID))
+	result, err := uc.Verify(ctx)
+	require.NoError(t, err)
+	assert.False(t, result.Valid, "The removal of the most recent event leaves a valid chain, which must not end at the head")
+	assert.Equal(t, last.ID, result.BrokenAt)
+
+	require.NoError(t, uc.Record(ctx, entities.AuditEvent{Actor: "erin", Action: entities.AuditActionRegister, Outcome: entities.AuditOutcomeSuccess}))
+	require.NoError(t, db.Delete(ctx, entities.AuditEvent{}, first.ID))
+	result, err = uc.Verify(ctx)
+	require.NoError(t, err)
+	assert.False(t, result.Valid, "The removal of the oldest event outside of a purge must be detected")
+}
+
+func TestVerifyDetectsRemovedHead(t *testing.T) {
+	uc, db := newTestUC(t, 0)
+	ctx := context.Background()
+
+	result, err := uc.Verify(ctx)
+	require.NoError(t, err)
+	assert.True(t, result.Valid, "An empty chain has no head")
+
+	for _, actor := range []string{"alice", "bob"} {
+		require.NoError(t, uc.Record(ctx, entities.AuditEvent{Actor: actor, Action: entities.AuditActionRegister, Outcome: entities.AuditOutcomeSuccess}))
+	}
+	var last entities.AuditEvent
+	require.NoError(t, db.Read(ctx, &last, query.Eq("actor", "bob")))
+	require.NoError(t, db.Delete(ctx, entities.AuditHead{}, uint64(entities.AuditHeadID)))
+
+	result, err = uc.Verify(ctx)
+	require.NoError(t, err)
+	assert.False(t, result.Valid, "Without its head, the removal of the most recent events would go unnoticed")
+	assert.Equal(t, last.ID+1, result.BrokenAt)
+}
+
+func TestConcurrentWritersKeepOneChain(t *testing.T) {
+	db := memory.NewDatabase()
+	cfg := &config.Config{}
+	// The use cases stand for the writers of two processes.
+	writers := []audit.UseCase{
+		NewAuditUC(cfg, auditstorage.NewAuditRepository(db), storage.NewTransactor(db)),
+		NewAuditUC(cfg, auditstorage.NewAuditRepository(db), storage.NewTransactor(db)),
+	}
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for _, uc := range writers {
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(uc audit.UseCase) {
+				defer wg.Done()
+				assert.NoError(t, uc.Record(ctx, entities.AuditEvent{Actor: "alice", Action: entities.AuditActionLogin, Outcome: entities.AuditOutcomeSuccess}))
+			}(uc)
+		}
+	}
+	wg.Wait()
+
+	result, err := writers[0].Verify(ctx)
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.Equal(t, 40, result.Checked)
+}
+
+func TestRecordInsideTransaction(t *testing.T) {
+	db := memory.NewDatabase()
+	tx := storage.NewTransactor(db)
+	uc := NewAuditUC(&config.Config{}, auditstorage.NewAuditRepository(db), tx)
+	ctx := context.Background()
+
+	// The events recorded by callers that already run in a transaction are appended along with those of the others,
+	// which must not wait for the transaction while it waits for them.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, tx.WithTx(ctx, func(ctx context.Context) error {
+				// The transaction does some work before it records, while the other writers start their appends.
+				time.Sleep(time.Millisecond)
+				return uc.Record(ctx, entities.AuditEvent{Actor: "alice", Action: entities.AuditActionRegister, Outcome: entities.AuditOutcomeSuccess})
+			}))
+		}()
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, uc.Record(ctx, entities.AuditEvent{Actor: "bob", Action: entities.AuditActionLogin, Outcome: entities.AuditOutcomeSuccess}))
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("The writers deadlocked")
+	}
+
+	result, err := uc.Verify(ctx)
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.Equal(t, 40, result.Checked)
+}
+
+func TestQueryFilters(t *testing.T) {
+	uc, _ := newTestUC(t, 0)
+	ctx := context.Background()
+
+	require.NoError(t, uc.Record(ctx, entities.AuditEvent{Actor: "alice", Action: entities.AuditActionLogin, Outcome: entities.AuditOutcomeSuccess}))
+	require.NoError(t, uc.Record(ctx, entities.AuditEvent{Actor: "alice", Action: entities.AuditActionLogin, Outcome: entities.AuditOutcomeFailure}))
+	require.NoError(t, uc.Record(ctx, entities.AuditEvent{Actor: "bob", Action: entities.AuditActionRegister, Outcome: entities.AuditOutcomeSuccess}))
+
+	events, err := uc.Query(ctx, entities.AuditFilter{Actor: "alice", Outcome: entities.AuditOutcomeFailure})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, entities.AuditActionLogin, events[0].Action)
+
+	events, err = uc.Query(ctx, entities.AuditFilter{From: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestPurgeKeepsRemainingChainVerifiable(t *testing.T) {
+	uc, _ := newTestUC(t, 30)
+	ctx := context.Background()
+
+	require.NoError(t, uc.Record(ctx, entities.AuditEvent{Actor: "old", Action: entities.AuditActionLogin, Outcome: entities.AuditOutcomeSuccess, Timestamp: time.Now().AddDate(0, 0, -60)}))
+	require.NoError(t, uc.Record(ctx, entities.AuditEvent{Actor: "new", Action: entities.AuditActionLogin, Outcome: entities.AuditOutcomeSuccess}))
+
+	removed, err := uc.Purge(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+
+	result, err := uc.Verify(ctx)
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.Equal(t, 1, result.Checked)
+}
+
+func TestPurgeRemovesOnlyPrefixOfChain(t *testing.T) {
+	uc, db := newTestUC(t, 30)
+	ctx := context.Background()
+
+	// The clock of the writer of the second event was behind, so an event that is old enough follows a recent one.
+	for _, days := range []int{-60, 0, -45, 0} {
+		require.NoError(t, uc.Record(ctx, entities.AuditEvent{Actor: "alice", Action: entities.AuditActionLogin, Outcome: entities.AuditOutcomeSuccess, Timestamp: time.Now().AddDate(0, 0, days)}))
+	}
+
+	removed, err := uc.Purge(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), removed, "The events up to the last one that is old enough are removed")
+
+	var events []entities.AuditEvent
+	require.NoError(t, db.Find(ctx, &events, query.Query{}))
+	assert.Len(t, events, 1)
+	result, err := uc.Verify(ctx)
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.Equal(t, 1, result.Checked)
+}
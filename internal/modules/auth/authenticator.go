@@ -0,0 +1,21 @@
+// Package auth provides the functionality to interact with user authentication data.
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+)
+
+// ErrInvalidCredentials is returned by an Authenticator if the email or the password is wrong.
+var ErrInvalidCredentials = errors.New("invalid email or password")
+
+// Authenticator is an interface that defines the method used to check the credentials of a user on login.
+// Every authentication backend, such as the local accounts or an LDAP directory, implements it.
+type Authenticator interface {
+	// Authenticate checks the credentials and resolves the local user they belong to.
+	// ctx: The context for the operation.
+	// credentials: The user login record to check.
+	// Returns the user, which carries the ID of the user whenever it was found, and an error if the credentials are not valid.
+	Authenticate(ctx context.Context, credentials entities.UserLogin) (entities.User, error)
+}
@@ -0,0 +1,89 @@
+package authenticator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"log"
+)
+
+// Chain struct represents an authentication backend that tries several backends in order until one accepts the credentials.
+type Chain struct {
+	backends []auth.Authenticator
+}
+
+// NewChain creates a new chain of the provided authentication backends.
+// backends: The backends to try, in order.
+// Returns a *Chain object.
+func NewChain(backends ...auth.Authenticator) *Chain {
+	return &Chain{backends: backends}
+}
+
+// Authenticate tries the backends in order and returns the user of the first one that accepts the credentials.
+// A backend that fails for another reason than invalid credentials, e.g. because its server is down, does not stop the chain.
+// ctx: The context for the operation.
+// credentials: The user login record to check.
+// Returns the user and the first error other than auth.ErrInvalidCredentials, or auth.ErrInvalidCredentials if every backend rejected the credentials.
+func (c *Chain) Authenticate(ctx context.Context, credentials entities.UserLogin) (entities.User, error) {
+	var (
+		rejected entities.User
+		failure  error
+	)
+	for _, backend := range c.backends {
+		user, err := backend.Authenticate(ctx, credentials)
+		if err == nil {
+			return user, nil
+		}
+		if rejected.ID == uuid.Nil {
+			rejected = user
+		}
+		if !errors.Is(err, auth.ErrInvalidCredentials) {
+			log.Printf("authenticator: %T failed: %v", backend, err)
+			if failure == nil {
+				failure = err
+			}
+		}
+	}
+	if failure != nil {
+		return rejected, failure
+	}
+	return rejected, auth.ErrInvalidCredentials
+}
+
+// NewAuthenticator creates the authentication backend configured in auth.backends.
+// cfg: The configuration of the application.
+// users: The user repository.
+// orgs: The organization repository, used by the LDAP backend to map the groups to roles.
+// memberships: The membership repository, used by the LDAP backend to map the groups to roles.
+// Returns an auth.Authenticator and an error if a backend is unknown or not configured properly.
+func NewAuthenticator(cfg *config.Config, users storage.UserRepository, orgs storage.OrganizationRepository, memberships storage.MembershipRepository) (auth.Authenticator, error) {
+	names := cfg.Auth.Backends
+	if len(names) == 0 {
+		names = []string{"local"}
+	}
+
+	backends := make([]auth.Authenticator, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case "local":
+			backends = append(backends, NewLocal(users))
+		case "ldap":
+			backend, err := NewLDAP(cfg.Auth.LDAP, users, orgs, memberships)
+			if err != nil {
+				return nil, err
+			}
+			backends = append(backends, backend)
+		default:
+			return nil, fmt.Errorf("unknown authentication backend %q", name)
+		}
+	}
+	if len(backends) == 1 {
+		return backends[0], nil
+	}
+	return NewChain(backends...), nil
+}
@@ -0,0 +1,406 @@
+package authenticator
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"golang.org/x/crypto/bcrypt"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// LDAP struct represents the authentication backend that checks the credentials against an LDAP or Active Directory server.
+// The user is searched with the service account and its password is checked by binding as the user (search-then-bind).
+// The users that sign in for the first time get a local account linked to their entry, and the organization roles follow the directory groups.
+type LDAP struct {
+	cfg         config.LDAPConfig
+	users       storage.UserRepository
+	orgs        storage.OrganizationRepository
+	memberships storage.MembershipRepository
+}
+
+// NewLDAP creates a new LDAP authentication backend with the provided configuration and repositories.
+// cfg: The LDAP configuration. The URL and the base DN are required.
+// users: The user repository the accounts are provisioned in.
+// orgs: The organization repository the mapped organizations are read from.
+// memberships: The membership repository the mapped roles are written to.
+// Returns a *LDAP object and an error if the configuration is not valid.
+func NewLDAP(cfg config.LDAPConfig, users storage.UserRepository, orgs storage.OrganizationRepository, memberships storage.MembershipRepository) (*LDAP, error) {
+	if cfg.URL == "" || cfg.BaseDN == "" {
+		return nil, errors.New("ldap: the url and the base dn are required")
+	}
+	for _, mapping := range cfg.GroupRoles {
+		if _, err := ldap.ParseDN(mapping.Group); err != nil {
+			return nil, fmt.Errorf("ldap: invalid group %q: %w", mapping.Group, err)
+		}
+		if mapping.Organization == "" || !entities.ValidRole(mapping.Role) {
+			return nil, fmt.Errorf("ldap: the group %q must be mapped to an organization and a valid role", mapping.Group)
+		}
+	}
+	return &LDAP{
+		cfg:         cfg,
+		users:       users,
+		orgs:        orgs,
+		memberships: memberships,
+	}, nil
+}
+
+// Authenticate searches the user by email, binds as the user with the password, provisions the local account and syncs the organization roles.
+// ctx: The context for the operation.
+// credentials: The user login record to check.
+// Returns the local user and auth.ErrInvalidCredentials if the credentials are not valid, or another error if the directory cannot be reached.
+func (l *LDAP) Authenticate(ctx context.Context, credentials entities.UserLogin) (entities.User, error) {
+	// An empty password would make the bind unauthenticated, which directories accept for any DN.
+	if credentials.Email == "" || credentials.Password == "" {
+		return entities.User{}, auth.ErrInvalidCredentials
+	}
+
+	conn, err := l.connect()
+	if err != nil {
+		return entities.User{}, err
+	}
+	defer conn.Close()
+
+	if err := l.bindService(conn); err != nil {
+		return entities.User{}, err
+	}
+	entry, err := l.findUser(conn, credentials.Email)
+	if err != nil {
+		return entities.User{}, err
+	}
+	if err := conn.Bind(entry.DN, credentials.Password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return entities.User{}, auth.ErrInvalidCredentials
+		}
+		return entities.User{}, fmt.Errorf("ldap: user bind: %w", err)
+	}
+
+	groups, err := l.groups(conn, entry)
+	if err != nil {
+		return entities.User{}, err
+	}
+	user, err := l.provision(ctx, entry, credentials.Email)
+	if err != nil {
+		return entities.User{}, err
+	}
+	if user.Deactivated {
+		return user, nil
+	}
+	if err := l.syncRoles(ctx, user.ID, groups); err != nil {
+		return user, err
+	}
+	return user, nil
+}
+
+// connect dials the directory server and upgrades the connection with StartTLS if configured.
+// Returns the connection and an error if the server cannot be reached.
+func (l *LDAP) connect() (*ldap.Conn, error) {
+	timeout := time.Duration(l.cfg.TimeoutSeconds) * time.Second
+	tlsConfig := &tls.Config{InsecureSkipVerify: l.cfg.InsecureSkipVerify}
+	if u, err := url.Parse(l.cfg.URL); err == nil {
+		tlsConfig.ServerName = u.Hostname()
+	}
+
+	conn, err := ldap.DialURL(l.cfg.URL, ldap.DialWithDialer(&net.Dialer{Timeout: timeout}), ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("ldap: dial: %w", err)
+	}
+	if timeout > 0 {
+		conn.SetTimeout(timeout)
+	}
+	if l.cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap: start tls: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// bindService binds as the service account, or anonymously if no service account is configured.
+// conn: The connection to bind.
+// Returns an error if the bind fails.
+func (l *LDAP) bindService(conn *ldap.Conn) error {
+	var err error
+	if l.cfg.BindDN == "" {
+		err = conn.UnauthenticatedBind("")
+	} else {
+		err = conn.Bind(l.cfg.BindDN, l.cfg.BindPassword)
+	}
+	if err != nil {
+		return fmt.Errorf("ldap: service bind: %w", err)
+	}
+	return nil
+}
+
+// findUser searches the single entry of the user with the given email under the base DN.
+// conn: The connection bound as the service account.
+// email: The email the user signs in with.
+// Returns the entry and auth.ErrInvalidCredentials if no single entry matches.
+func (l *LDAP) findUser(conn *ldap.Conn, email string) (*ldap.Entry, error) {
+	attributes := []string{l.cfg.UsernameAttribute, l.cfg.EmailAttribute}
+	if l.cfg.IDAttribute != "" {
+		attributes = append(attributes, l.cfg.IDAttribute)
+	}
+	if l.cfg.GroupAttribute != "" {
+		attributes = append(attributes, l.cfg.GroupAttribute)
+	}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		l.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, l.cfg.TimeoutSeconds, false,
+		strings.ReplaceAll(l.cfg.UserFilter, "%s", ldap.EscapeFilter(email)), attributes, nil,
+	))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("ldap: user search: %w", err)
+	}
+	if result == nil || len(result.Entries) != 1 {
+		return nil, auth.ErrInvalidCredentials
+	}
+	return result.Entries[0], nil
+}
+
+// groups collects the DNs of the groups of the user from the group attribute and, if a group base DN is configured, from a group search.
+// conn: The connection bound as the user. It is bound as the service account again for the group search.
+// entry: The entry of the user.
+// Returns the DNs of the groups and an error if the search fails.
+func (l *LDAP) groups(conn *ldap.Conn, entry *ldap.Entry) ([]string, error) {
+	var groups []string
+	if l.cfg.GroupAttribute != "" {
+		groups = append(groups, entry.GetEqualFoldAttributeValues(l.cfg.GroupAttribute)...)
+	}
+	if l.cfg.GroupBaseDN == "" {
+		return groups, nil
+	}
+
+	if err := l.bindService(conn); err != nil {
+		return nil, err
+	}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		l.cfg.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, l.cfg.TimeoutSeconds, false,
+		strings.ReplaceAll(l.cfg.GroupFilter, "%s", ldap.EscapeFilter(entry.DN)), []string{"dn"}, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("ldap: group search: %w", err)
+	}
+	for _, group := range result.Entries {
+		groups = append(groups, group.DN)
+	}
+	return groups, nil
+}
+
+// provision retrieves the local account linked to the entry of the user, or creates one the first time the user signs in.
+// The account gets a random password, so it can only be used through the directory.
+// An account the directory did not provision is never linked by its email, since anyone who can register it would take over the directory user.
+// ctx: The context for the operation.
+// entry: The entry of the user.
+// fallbackEmail: The email the user signed in with, used if the entry has no email.
+// Returns the local user, auth.ErrInvalidCredentials if the email belongs to another account, and an error if the operation fails.
+func (l *LDAP) provision(ctx context.Context, entry *ldap.Entry, fallbackEmail string) (entities.User, error) {
+	externalID, err := l.externalID(entry)
+	if err != nil {
+		return entities.User{}, err
+	}
+	linked, err := l.users.List(ctx, query.Where(query.Eq("external_id", externalID)).Window(1, 0))
+	if err != nil {
+		return entities.User{}, err
+	}
+	if len(linked) > 0 {
+		return linked[0], nil
+	}
+
+	email := entry.GetEqualFoldAttributeValue(l.cfg.EmailAttribute)
+	if email == "" {
+		email = fallbackEmail
+	}
+	_, err = l.users.ReadByEmail(ctx, email)
+	if err == nil {
+		log.Printf("ldap: the email of %s belongs to an account the directory did not provision, which an admin must link", entry.DN)
+		return entities.User{}, auth.ErrInvalidCredentials
+	}
+	if !errors.Is(err, database.ErrNotFound) {
+		return entities.User{}, err
+	}
+	if err := validator.New().Var(email, "required,email"); err != nil {
+		return entities.User{}, fmt.Errorf("ldap: the entry %q has no valid email", entry.DN)
+	}
+
+	username, err := l.username(ctx, entry.GetEqualFoldAttributeValue(l.cfg.UsernameAttribute), email)
+	if err != nil {
+		return entities.User{}, err
+	}
+	password, err := randomPasswordHash()
+	if err != nil {
+		return entities.User{}, err
+	}
+	user := entities.User{
+		ID:         uuid.New(),
+		Username:   username,
+		Email:      email,
+		Password:   password,
+		ExternalID: externalID,
+	}
+	if err := l.users.Create(ctx, user); err != nil {
+		return entities.User{}, err
+	}
+	log.Printf("ldap: provisioned the user %s for %s", user.ID, entry.DN)
+	// The user is read back with the version the storage gave it, which the login then updates.
+	return l.users.Read(ctx, user.ID)
+}
+
+// externalID identifies the entry of the user for the local account it is linked to: "ldap:" and the hex-encoded value
+// of the ID attribute, or "ldap:" and the DN if no ID attribute is configured. The prefix keeps it apart from the SCIM external IDs.
+// entry: The entry of the user.
+// Returns the external ID and an error if the entry has no ID attribute.
+func (l *LDAP) externalID(entry *ldap.Entry) (string, error) {
+	if l.cfg.IDAttribute == "" {
+		return "ldap:" + entry.DN, nil
+	}
+	id := entry.GetEqualFoldRawAttributeValue(l.cfg.IDAttribute)
+	if len(id) == 0 {
+		return "", fmt.Errorf("ldap: the entry %q has no %s", entry.DN, l.cfg.IDAttribute)
+	}
+	return "ldap:" + hex.EncodeToString(id), nil
+}
+
+// username derives a free username from the username attribute, or from the email if the attribute is not usable.
+// Usernames are alphanumeric and between 3 and 20 characters long, so the other characters are dropped and a number is appended on a collision.
+// ctx: The context for the operation.
+// name: The value of the username attribute.
+// email: The email of the user.
+// Returns the username and an error if no free username is found.
+func (l *LDAP) username(ctx context.Context, name, email string) (string, error) {
+	base := alphanumeric(name)
+	if len(base) < 3 {
+		base = alphanumeric(strings.SplitN(email, "@", 2)[0])
+	}
+	if len(base) < 3 {
+		base = "user"
+	}
+	if len(base) > 16 {
+		base = base[:16]
+	}
+
+	for i := 1; i < 1000; i++ {
+		candidate := base
+		if i > 1 {
+			candidate += strconv.Itoa(i)
+		}
+		_, err := l.users.ReadByUsername(ctx, candidate)
+		if errors.Is(err, database.ErrNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("ldap: no free username for %q", base)
+}
+
+// syncRoles gives the user the highest role its groups are mapped to in every mapped organization.
+// The mapped organizations are managed by the directory, so the membership is removed if none of the groups of the user is mapped to the organization.
+// ctx: The context for the operation.
+// userID: The ID of the local user.
+// groups: The DNs of the groups of the user.
+// Returns an error if the operation fails.
+func (l *LDAP) syncRoles(ctx context.Context, userID uuid.UUID, groups []string) error {
+	roles := make(map[string]string)
+	var slugs []string
+	for _, mapping := range l.cfg.GroupRoles {
+		if _, ok := roles[mapping.Organization]; !ok {
+			roles[mapping.Organization] = ""
+			slugs = append(slugs, mapping.Organization)
+		}
+		if memberOf(groups, mapping.Group) && !entities.RoleAtLeast(roles[mapping.Organization], mapping.Role) {
+			roles[mapping.Organization] = mapping.Role
+		}
+	}
+
+	for _, slug := range slugs {
+		org, err := l.orgs.ReadBySlug(ctx, slug)
+		if errors.Is(err, database.ErrNotFound) {
+			log.Printf("ldap: the mapped organization %q does not exist", slug)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		tenantCtx := database.WithTenant(ctx, org.ID)
+		role := roles[slug]
+		membership, err := l.memberships.Read(tenantCtx, userID)
+		if err != nil && !errors.Is(err, database.ErrNotFound) {
+			return err
+		}
+		found := err == nil
+
+		switch {
+		case role == "" && found:
+			err = l.memberships.Delete(tenantCtx, membership.ID)
+		case role != "" && !found:
+			err = l.memberships.Create(tenantCtx, entities.Membership{ID: uuid.New(), UserID: userID, Role: role})
+		case role != "" && membership.Role != role:
+			membership.Role = role
+			err = l.memberships.Update(tenantCtx, membership)
+		default:
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// memberOf reports whether the group is one of the groups. DNs are compared case-insensitively.
+func memberOf(groups []string, group string) bool {
+	want, err := ldap.ParseDN(group)
+	if err != nil {
+		return false
+	}
+	for _, g := range groups {
+		if dn, err := ldap.ParseDN(g); err == nil && dn.EqualFold(want) {
+			return true
+		}
+	}
+	return false
+}
+
+// alphanumeric drops every character that is not an ASCII letter or digit.
+func alphanumeric(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// randomPasswordHash hashes a random password nobody knows, for the accounts that are only used through the directory.
+func randomPasswordHash() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(base64.RawURLEncoding.EncodeToString(b)), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
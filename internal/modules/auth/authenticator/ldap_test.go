@@ -0,0 +1,403 @@
+package authenticator
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/google/uuid"
+	"github.com/jimlambrt/gldap"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/membership"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/organization"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/user"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/memory"
+	"golang.org/x/crypto/bcrypt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	serviceDN       = "cn=service,dc=example,dc=com"
+	servicePassword = "service-secret"
+	peopleDN        = "ou=people,dc=example,dc=com"
+	groupsDN        = "ou=groups,dc=example,dc=com"
+	adminsDN        = "cn=admins," + groupsDN
+	developersDN    = "cn=developers," + groupsDN
+)
+
+// directory is an in-process LDAP server. Searches are only answered for the service account, like most directories are configured.
+type directory struct {
+	mu        sync.Mutex
+	entries   map[string]map[string][]string
+	passwords map[string]string
+	bound     map[int]string
+	url       string
+}
+
+func newDirectory(t *testing.T) *directory {
+	d := &directory{
+		entries:   map[string]map[string][]string{},
+		passwords: map[string]string{serviceDN: servicePassword},
+		bound:     map[int]string{},
+	}
+	d.addUser("alice", "alice@example.com", "alice-password", adminsDN)
+	d.addUser("bob", "bob@example.com", "bob-password")
+	d.addUser("twin1", "twin@example.com", "twin-password")
+	d.addUser("twin2", "twin@example.com", "twin-password")
+	d.entries[adminsDN] = map[string][]string{"objectClass": {"groupOfNames"}, "cn": {"admins"}, "member": {"uid=alice," + peopleDN}}
+	d.entries[developersDN] = map[string][]string{"objectClass": {"groupOfNames"}, "cn": {"developers"}, "member": {"uid=alice," + peopleDN, "uid=bob," + peopleDN}}
+
+	mux, err := gldap.NewMux()
+	require.NoError(t, err)
+	require.NoError(t, mux.Bind(d.handleBind))
+	require.NoError(t, mux.Search(d.handleSearch))
+	server, err := gldap.NewServer()
+	require.NoError(t, err)
+	require.NoError(t, server.Router(mux))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	go func() { _ = server.Run(addr) }()
+	require.Eventually(t, server.Ready, 5*time.Second, 10*time.Millisecond)
+	t.Cleanup(func() { _ = server.Stop() })
+	d.url = "ldap://" + addr
+	return d
+}
+
+func (d *directory) addUser(uid, mail, password string, groups ...string) {
+	dn := fmt.Sprintf("uid=%s,%s", uid, peopleDN)
+	d.entries[dn] = map[string][]string{"objectClass": {"person", "inetOrgPerson"}, "uid": {uid}, "mail": {mail}, "memberOf": groups}
+	d.passwords[dn] = password
+}
+
+// leave removes a user from a group.
+func (d *directory) leave(group, uid string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var members []string
+	for _, member := range d.entries[group]["member"] {
+		if member != "uid="+uid+","+peopleDN {
+			members = append(members, member)
+		}
+	}
+	d.entries[group]["member"] = members
+}
+
+func (d *directory) handleBind(w *gldap.ResponseWriter, r *gldap.Request) {
+	resp := r.NewBindResponse(gldap.WithResponseCode(gldap.ResultInvalidCredentials))
+	defer func() { _ = w.Write(resp) }()
+	m, err := r.GetSimpleBindMessage()
+	if err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.bound, r.ConnectionID())
+	if m.UserName == "" && m.Password == "" {
+		resp.SetResultCode(gldap.ResultSuccess)
+		return
+	}
+	for dn, password := range d.passwords {
+		if strings.EqualFold(dn, m.UserName) && password != "" && password == string(m.Password) {
+			d.bound[r.ConnectionID()] = dn
+			resp.SetResultCode(gldap.ResultSuccess)
+			return
+		}
+	}
+}
+
+func (d *directory) handleSearch(w *gldap.ResponseWriter, r *gldap.Request) {
+	resp := r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultInsufficientAccessRights))
+	defer func() { _ = w.Write(resp) }()
+	m, err := r.GetSearchMessage()
+	if err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.bound[r.ConnectionID()] != serviceDN {
+		return
+	}
+	filter, err := ldap.CompileFilter(m.Filter)
+	if err != nil {
+		resp.SetResultCode(gldap.ResultOperationsError)
+		return
+	}
+	for dn, attributes := range d.entries {
+		if !strings.HasSuffix(strings.ToLower(dn), ","+strings.ToLower(m.BaseDN)) || !matches(filter, attributes) {
+			continue
+		}
+		entry := r.NewSearchResponseEntry(dn)
+		for _, name := range m.Attributes {
+			for attribute, values := range attributes {
+				if strings.EqualFold(attribute, name) && len(values) > 0 {
+					entry.AddAttribute(attribute, values)
+				}
+			}
+		}
+		_ = w.Write(entry)
+	}
+	resp.SetResultCode(gldap.ResultSuccess)
+}
+
+// matches evaluates the and, or, not, equality and presence filters case-insensitively.
+func matches(filter *ber.Packet, attributes map[string][]string) bool {
+	values := func(name string) []string {
+		for attribute, values := range attributes {
+			if strings.EqualFold(attribute, name) {
+				return values
+			}
+		}
+		return nil
+	}
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !matches(child, attributes) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if matches(child, attributes) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return !matches(filter.Children[0], attributes)
+	case ldap.FilterPresent:
+		return len(values(fmt.Sprint(filter.Value))) > 0
+	case ldap.FilterEqualityMatch:
+		for _, value := range values(fmt.Sprint(filter.Children[0].Value)) {
+			if strings.EqualFold(value, fmt.Sprint(filter.Children[1].Value)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type fixture struct {
+	dir         *directory
+	cfg         *config.Config
+	users       storage.UserRepository
+	orgs        storage.OrganizationRepository
+	memberships storage.MembershipRepository
+	acme        uuid.UUID
+	globex      uuid.UUID
+}
+
+func newFixture(t *testing.T) fixture {
+	dir := newDirectory(t)
+	cfg := &config.Config{
+		Auth: config.AuthConfig{
+			Backends: []string{"ldap"},
+			LDAP: config.LDAPConfig{
+				URL:               dir.url,
+				BindDN:            serviceDN,
+				BindPassword:      servicePassword,
+				BaseDN:            peopleDN,
+				UserFilter:        "(&(objectClass=person)(mail=%s))",
+				UsernameAttribute: "uid",
+				EmailAttribute:    "mail",
+				GroupAttribute:    "memberOf",
+				GroupBaseDN:       groupsDN,
+				GroupFilter:       "(&(objectClass=groupOfNames)(member=%s))",
+				TimeoutSeconds:    5,
+				GroupRoles: []config.LDAPGroupRole{
+					{Group: "CN=Admins,OU=Groups,DC=example,DC=com", Organization: "acme", Role: entities.RoleAdmin},
+					{Group: developersDN, Organization: "acme", Role: entities.RoleMember},
+					{Group: developersDN, Organization: "globex", Role: entities.RoleMember},
+				},
+			},
+		},
+	}
+	db := database.NewTenantDatabase(memory.NewDatabase(), "organization_id")
+
+	f := fixture{
+		dir:         dir,
+		cfg:         cfg,
+		users:       user.NewUserRepository(db),
+		orgs:        organization.NewOrganizationRepository(db),
+		memberships: membership.NewMembershipRepository(db),
+		acme:        uuid.New(),
+		globex:      uuid.New(),
+	}
+	require.NoError(t, f.orgs.Create(context.Background(), entities.Organization{ID: f.acme, Name: "Acme", Slug: "acme"}))
+	require.NoError(t, f.orgs.Create(context.Background(), entities.Organization{ID: f.globex, Name: "Globex", Slug: "globex"}))
+	return f
+}
+
+func (f fixture) authenticator(t *testing.T) auth.Authenticator {
+	a, err := NewAuthenticator(f.cfg, f.users, f.orgs, f.memberships)
+	require.NoError(t, err)
+	return a
+}
+
+// roles returns the role of the user in every organization.
+func (f fixture) roles(t *testing.T, userID uuid.UUID) map[uuid.UUID]string {
+	memberships, err := f.memberships.ReadByUser(context.Background(), userID)
+	require.NoError(t, err)
+	roles := map[uuid.UUID]string{}
+	for _, m := range memberships {
+		roles[m.OrganizationID] = m.Role
+	}
+	return roles
+}
+
+func TestLDAPProvisionsUserAndMapsGroups(t *testing.T) {
+	f := newFixture(t)
+
+	u, err := f.authenticator(t).Authenticate(context.Background(), entities.UserLogin{Email: "alice@example.com", Password: "alice-password"})
+	require.NoError(t, err)
+	assert.Equal(t, "alice", u.Username)
+	assert.Equal(t, "alice@example.com", u.Email)
+
+	stored, err := f.users.ReadByEmail(context.Background(), "alice@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, u.ID, stored.ID)
+	assert.Error(t, bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte("alice-password")), "the directory password must not be stored")
+
+	// alice is in admins through memberOf and in developers through the group search.
+	assert.Equal(t, map[uuid.UUID]string{f.acme: entities.RoleAdmin, f.globex: entities.RoleMember}, f.roles(t, u.ID))
+}
+
+func TestLDAPRejectsInvalidCredentials(t *testing.T) {
+	f := newFixture(t)
+	a := f.authenticator(t)
+
+	for name, login := range map[string]entities.UserLogin{
+		"wrong password":   {Email: "alice@example.com", Password: "wrong-password"},
+		"empty password":   {Email: "alice@example.com", Password: ""},
+		"unknown user":     {Email: "mallory@example.com", Password: "alice-password"},
+		"ambiguous email":  {Email: "twin@example.com", Password: "twin-password"},
+		"filter injection": {Email: "*)(uid=alice", Password: "alice-password"},
+	} {
+		_, err := a.Authenticate(context.Background(), login)
+		assert.ErrorIs(t, err, auth.ErrInvalidCredentials, name)
+	}
+
+	var users []entities.User
+	users, err := f.users.ReadAll(context.Background(), users)
+	require.NoError(t, err)
+	assert.Empty(t, users, "rejected logins must not provision users")
+}
+
+func TestLDAPRolesFollowGroupChanges(t *testing.T) {
+	f := newFixture(t)
+	a := f.authenticator(t)
+	login := entities.UserLogin{Email: "bob@example.com", Password: "bob-password"}
+
+	first, err := a.Authenticate(context.Background(), login)
+	require.NoError(t, err)
+	assert.Equal(t, map[uuid.UUID]string{f.acme: entities.RoleMember, f.globex: entities.RoleMember}, f.roles(t, first.ID))
+
+	f.dir.leave(developersDN, "bob")
+	second, err := a.Authenticate(context.Background(), login)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID, "the provisioned user must be reused")
+	assert.Empty(t, f.roles(t, second.ID))
+}
+
+func TestLDAPLinksProvisionedUsersAndAvoidsUsernameCollisions(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	existing := entities.User{ID: uuid.New(), Username: "robert", Email: "robert@example.com", Password: "hash", ExternalID: "ldap:uid=bob," + peopleDN}
+	require.NoError(t, f.users.Create(ctx, existing))
+	require.NoError(t, f.users.Create(ctx, entities.User{ID: uuid.New(), Username: "alice", Email: "alice@corp.example.com", Password: "hash"}))
+
+	a := f.authenticator(t)
+	bob, err := a.Authenticate(ctx, entities.UserLogin{Email: "bob@example.com", Password: "bob-password"})
+	require.NoError(t, err)
+	assert.Equal(t, existing.ID, bob.ID, "the account is linked by the entry, whatever its email")
+
+	alice, err := a.Authenticate(ctx, entities.UserLogin{Email: "alice@example.com", Password: "alice-password"})
+	require.NoError(t, err)
+	assert.Equal(t, "alice2", alice.Username)
+	assert.Equal(t, "ldap:uid=alice,"+peopleDN, alice.ExternalID)
+
+	// With an ID attribute, the account is linked to its value rather than to the DN, which changes when the entry is renamed.
+	f = newFixture(t)
+	f.cfg.Auth.LDAP.IDAttribute = "uid"
+	a = f.authenticator(t)
+	first, err := a.Authenticate(ctx, entities.UserLogin{Email: "bob@example.com", Password: "bob-password"})
+	require.NoError(t, err)
+	assert.Equal(t, "ldap:"+hex.EncodeToString([]byte("bob")), first.ExternalID)
+	second, err := a.Authenticate(ctx, entities.UserLogin{Email: "bob@example.com", Password: "bob-password"})
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+}
+
+func TestLDAPRefusesAccountsItDidNotProvision(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	// An account registered with the email of a directory user must not be taken over by the directory login, nor the other way round.
+	local := entities.User{ID: uuid.New(), Username: "mallory", Email: "bob@example.com", Password: "hash"}
+	require.NoError(t, f.users.Create(ctx, local))
+
+	_, err := f.authenticator(t).Authenticate(ctx, entities.UserLogin{Email: "bob@example.com", Password: "bob-password"})
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	assert.Empty(t, f.roles(t, local.ID), "the account must not get the roles of the directory user")
+
+	stored, err := f.users.Read(ctx, local.ID)
+	require.NoError(t, err)
+	assert.Empty(t, stored.ExternalID)
+}
+
+func TestChainFallsBackToLocalAccounts(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	hash, err := bcrypt.GenerateFromPassword([]byte("carol-password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	carol := entities.User{ID: uuid.New(), Username: "carol", Email: "carol@example.com", Password: string(hash)}
+	require.NoError(t, f.users.Create(ctx, carol))
+
+	f.cfg.Auth.Backends = []string{"ldap", "local"}
+	a := f.authenticator(t)
+
+	u, err := a.Authenticate(ctx, entities.UserLogin{Email: "carol@example.com", Password: "carol-password"})
+	require.NoError(t, err)
+	assert.Equal(t, carol.ID, u.ID)
+
+	u, err = a.Authenticate(ctx, entities.UserLogin{Email: "carol@example.com", Password: "wrong-password"})
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	assert.Equal(t, carol.ID, u.ID, "the rejected user is kept for the audit log")
+
+	// An unreachable directory does not lock out the local accounts.
+	f.cfg.Auth.LDAP.URL = "ldap://127.0.0.1:1"
+	u, err = f.authenticator(t).Authenticate(ctx, entities.UserLogin{Email: "carol@example.com", Password: "carol-password"})
+	require.NoError(t, err)
+	assert.Equal(t, carol.ID, u.ID)
+}
+
+func TestNewAuthenticatorRejectsInvalidConfiguration(t *testing.T) {
+	f := newFixture(t)
+
+	f.cfg.Auth.Backends = []string{"kerberos"}
+	_, err := NewAuthenticator(f.cfg, f.users, f.orgs, f.memberships)
+	assert.Error(t, err)
+
+	f.cfg.Auth.Backends = []string{"ldap"}
+	f.cfg.Auth.LDAP.GroupRoles = []config.LDAPGroupRole{{Group: adminsDN, Organization: "acme", Role: "superuser"}}
+	_, err = NewAuthenticator(f.cfg, f.users, f.orgs, f.memberships)
+	assert.Error(t, err)
+}
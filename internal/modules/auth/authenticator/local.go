@@ -0,0 +1,49 @@
+// Package authenticator provides the authentication backends that check the credentials of the users on login.
+package authenticator
+
+import (
+	"context"
+	"errors"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyHash is the password hash the password of an unknown email is compared against, so that the login takes as long
+// as the one of a wrong password and does not reveal which emails have an account. Its cost is bcrypt.DefaultCost,
+// the cost of the password hashes of the accounts.
+var dummyHash = []byte("$2a$10$sCW1laV10FOCU2sRRQlqIudogIrL1TM5yrV/KDY7iFVu8tLDhDeeO")
+
+// Local struct represents the authentication backend that checks the credentials against the password hashes of the local accounts.
+type Local struct {
+	users storage.UserRepository
+}
+
+// NewLocal creates a new local authentication backend with the provided user repository.
+// users: The user repository the accounts are read from.
+// Returns a *Local object.
+func NewLocal(users storage.UserRepository) *Local {
+	return &Local{users: users}
+}
+
+// Authenticate checks the password against the password hash of the account with the given email.
+// ctx: The context for the operation.
+// credentials: The user login record to check.
+// Returns the user, which carries the ID of the user whenever it was found, and auth.ErrInvalidCredentials if the credentials are not valid,
+// or the error of the storage if the account cannot be read.
+func (l *Local) Authenticate(ctx context.Context, credentials entities.UserLogin) (entities.User, error) {
+	user, err := l.users.ReadByEmail(ctx, credentials.Email)
+	if errors.Is(err, database.ErrNotFound) {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(credentials.Password))
+		return entities.User{}, auth.ErrInvalidCredentials
+	}
+	if err != nil {
+		return entities.User{}, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(credentials.Password)); err != nil {
+		return user, auth.ErrInvalidCredentials
+	}
+	return user, nil
+}
@@ -0,0 +1,41 @@
+package authenticator
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/user"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/memory"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	users := user.NewUserRepository(memory.NewDatabase())
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.DefaultCost)
+	require.NoError(t, err)
+	alice := entities.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", Password: string(hash)}
+	require.NoError(t, users.Create(ctx, alice))
+	local := NewLocal(users)
+
+	found, err := local.Authenticate(ctx, entities.UserLogin{Email: "alice@example.com", Password: "password"})
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, found.ID)
+
+	found, err = local.Authenticate(ctx, entities.UserLogin{Email: "alice@example.com", Password: "wrong password"})
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	assert.Equal(t, alice.ID, found.ID, "the user carries its ID whenever it was found")
+
+	found, err = local.Authenticate(ctx, entities.UserLogin{Email: "nobody@example.com", Password: "password"})
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	assert.Equal(t, uuid.Nil, found.ID)
+
+	cost, err := bcrypt.Cost(dummyHash)
+	require.NoError(t, err, "the dummy hash is a bcrypt hash, so that comparing it takes as long as comparing a real one")
+	assert.Equal(t, bcrypt.DefaultCost, cost, "the dummy hash has the cost of the password hashes of the accounts")
+}
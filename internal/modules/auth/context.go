@@ -0,0 +1,26 @@
+// Package auth provides the functionality to interact with user authentication data.
+package auth
+
+import (
+	"context"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+)
+
+// userKey is the context key under which the authenticated user is stored.
+type userKey struct{}
+
+// WithUser returns a copy of the context that carries the authenticated user.
+// ctx: The parent context.
+// user: The authenticated user.
+// Returns the new context.
+func WithUser(ctx context.Context, user entities.User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext retrieves the authenticated user from the context.
+// ctx: The context to read from.
+// Returns the user and a boolean indicating if the request is authenticated.
+func UserFromContext(ctx context.Context) (entities.User, bool) {
+	user, ok := ctx.Value(userKey{}).(entities.User)
+	return user, ok
+}
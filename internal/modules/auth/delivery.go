@@ -4,7 +4,7 @@ package auth
 import "github.com/labstack/echo/v4"
 
 // Handlers is an interface that defines the methods required for handling user authentication operations.
-// It includes methods for registering, getting all users, and logging in.
+// It includes methods for registering, getting all users, searching, getting and updating the users, and logging in.
 type Handlers interface {
 	// Register handles the registration of a new user.
 	// Returns an echo.HandlerFunc that handles the HTTP request for user registration.
@@ -14,6 +14,18 @@ type Handlers interface {
 	// Returns an echo.HandlerFunc that handles the HTTP request for retrieving all users.
 	GetAll() echo.HandlerFunc
 
+	// Search handles the search of the users by their username or email.
+	// Returns an echo.HandlerFunc that handles the HTTP request for searching the users.
+	Search() echo.HandlerFunc
+
+	// GetUser handles the retrieval of a user, whose version is the ETag of the response.
+	// Returns an echo.HandlerFunc that handles the HTTP request for retrieving a user.
+	GetUser() echo.HandlerFunc
+
+	// UpdateUser handles the change of the username and the email of a user, which honours the If-Match header.
+	// Returns an echo.HandlerFunc that handles the HTTP request for updating a user.
+	UpdateUser() echo.HandlerFunc
+
 	// Login handles the login of a user.
 	// Returns an echo.HandlerFunc that handles the HTTP request for user login.
 	Login() echo.HandlerFunc
@@ -2,12 +2,17 @@
 package http
 
 import (
+	"errors"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"                                   // Echo is a high performance, extensible, minimalist web framework for Go.
 	"github.com/nikita-voronoy/go-clean-arch/config"                // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/app"          // App package provides the functionality to map the errors of the storage layer to HTTP status codes.
 	"github.com/nikita-voronoy/go-clean-arch/internal/entities"     // Entities package provides the functionality to interact with the entities of the application.
 	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth" // Auth package provides the functionality to interact with the auth module.
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -34,18 +39,27 @@ func NewAuthHandlers(cfg *config.Config, authUC auth.UseCase) *AuthHandlers {
 // @param {User.model} user.body.required - User details
 // @returns {object} 201 - An account has been successfully created.
 // @returns {object} 400 - The request could not be understood or was missing required parameters.
+// @returns {object} 403 - Open registration is disabled, accounts can only be created through an invitation.
 // @returns {object} 409 - An account with the given email or username already exists.
+// @returns {object} 500 - Server error
+// @returns {object} 503 - The database timed out.
 func (h *AuthHandlers) Register() echo.HandlerFunc {
 	return func(c echo.Context) error {
+		if !h.cfg.Auth.OpenRegistration {
+			return echo.NewHTTPError(http.StatusForbidden, "registration is by invitation only")
+		}
+
 		var user entities.User
 		if err := c.Bind(&user); err != nil {
 			// Return an HTTP error with status code 400 for bad requests.
 			return echo.NewHTTPError(http.StatusBadRequest, "failed to bind user")
 		}
 
-		if err := h.authUC.Register(c.Request().Context(), user); err != nil {
-			return echo.NewHTTPError(http.StatusConflict, fmt.Sprintf("failed to register user: %v", err))
+		user, err := h.authUC.Register(c.Request().Context(), user)
+		if err != nil {
+			return statusError(err, "failed to register user")
 		}
+		user.Password = ""
 
 		return c.JSON(http.StatusCreated, user)
 	}
@@ -56,24 +70,123 @@ func (h *AuthHandlers) Register() echo.HandlerFunc {
 // @group Authentication
 // @returns {Array} 200 - An array of user info
 // @returns {object} 500 - Server error
+// @returns {object} 503 - The database timed out.
 func (h *AuthHandlers) GetAll() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		var users []entities.User
 		users, err := h.authUC.GetAll(c.Request().Context())
 		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to get all users: %v", err))
+			return statusError(err, "failed to get all users")
 		}
 		return c.JSON(http.StatusOK, users)
 	}
 }
 
+// Search searches the users by their username or email. The emails are not searched when they are encrypted at rest.
+// @route GET /admin/users/search
+// @group Authentication
+// @param {string} q.query.required - Words that start the words of the usernames or emails, e.g. "ali exa"
+// @param {integer} limit.query - Maximum number of users, 20 by default and at most 100
+// @param {integer} offset.query - Number of users to skip
+// @returns {object} 200 - A page of the found users, most relevant first, with their ranks and highlights
+// @returns {object} 400 - The search has no word to search.
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 500 - Server error
+// @returns {object} 503 - The database timed out.
+func (h *AuthHandlers) Search() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var search entities.UserSearch
+		if err := c.Bind(&search); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "failed to bind user search")
+		}
+
+		result, err := h.authUC.Search(c.Request().Context(), search)
+		if err != nil {
+			return statusError(err, "failed to search users")
+		}
+		for i := range result.Hits {
+			result.Hits[i].User.Password = ""
+		}
+		return c.JSON(http.StatusOK, result)
+	}
+}
+
+// GetUser retrieves a user.
+// @route GET /admin/users/:id
+// @group Authentication
+// @param {string} id.path.required - ID of the user
+// @returns {object} 200 - The user, with its version in the ETag header
+// @returns {object} 400 - The ID is not a UUID.
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 404 - The user does not exist.
+// @returns {object} 500 - Server error
+// @returns {object} 503 - The database timed out.
+func (h *AuthHandlers) GetUser() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+		}
+
+		user, err := h.authUC.GetUser(c.Request().Context(), id)
+		if err != nil {
+			return statusError(err, "failed to get user")
+		}
+		user.Password = ""
+		c.Response().Header().Set("ETag", etag(user.Metadata.Version))
+		return c.JSON(http.StatusOK, user)
+	}
+}
+
+// UpdateUser changes the username and the email of a user.
+// @route PUT /admin/users/:id
+// @group Authentication
+// @param {string} id.path.required - ID of the user
+// @param {UserUpdate.model} update.body.required - New username and email of the user
+// @param {string} If-Match.header - ETag of the version of the user the change is based on
+// @returns {object} 200 - The updated user, with its new version in the ETag header
+// @returns {object} 400 - The request could not be understood or the change is invalid.
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 404 - The user does not exist.
+// @returns {object} 409 - The username or the email is already taken.
+// @returns {object} 412 - The user was modified since the version of the If-Match header.
+// @returns {object} 500 - Server error
+// @returns {object} 503 - The database timed out.
+func (h *AuthHandlers) UpdateUser() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+		}
+		version, err := ifMatch(c)
+		if err != nil {
+			return err
+		}
+		var update entities.UserUpdate
+		if err := c.Bind(&update); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "failed to bind user update")
+		}
+
+		user, err := h.authUC.UpdateUser(c.Request().Context(), id, update, version)
+		if err != nil {
+			return statusError(err, "failed to update user")
+		}
+		user.Password = ""
+		c.Response().Header().Set("ETag", etag(user.Metadata.Version))
+		return c.JSON(http.StatusOK, user)
+	}
+}
+
 // Login logs in a user.
 // @route POST /auth/login
 // @group Authentication
 // @param {UserLogin.model} userLogin.body.required - User login details
 // @returns {object} 200 - Successful login
-// @returns {object} 400 - Invalid username or password
-// @returns {object} 401 - Unauthorized access
+// @returns {object} 400 - The request could not be understood.
+// @returns {object} 401 - Invalid email or password
+// @returns {object} 403 - The account has been deactivated.
+// @returns {object} 500 - Server error
+// @returns {object} 503 - The database timed out.
 func (h *AuthHandlers) Login() echo.HandlerFunc {
 	return func(c echo.Context) error {
 		var login entities.UserLogin
@@ -83,7 +196,7 @@ func (h *AuthHandlers) Login() echo.HandlerFunc {
 
 		token, err := h.authUC.Login(c.Request().Context(), login)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusUnauthorized, fmt.Sprintf("failed to login user: %v", err))
+			return statusError(err, "failed to login user")
 		}
 
 		c.SetCookie(&http.Cookie{
@@ -95,3 +208,45 @@ func (h *AuthHandlers) Login() echo.HandlerFunc {
 		return c.JSON(http.StatusOK, fmt.Sprintf("Bearer: %s", token))
 	}
 }
+
+// etag returns the entity tag of a version of a user. The tag is weak, since it only identifies the version.
+func etag(version int64) string {
+	return fmt.Sprintf("W/%q", strconv.FormatInt(version, 10))
+}
+
+// ifMatch returns the version of the user the If-Match header of a request is based on, or 0 if the header is missing or is "*".
+// The tags are compared weakly, as etag issues them.
+// Returns an *echo.HTTPError with 412 if the header holds no version of a user.
+func ifMatch(c echo.Context) (int64, error) {
+	header := strings.TrimSpace(c.Request().Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	if unquoted, err := strconv.Unquote(strings.TrimPrefix(header, "W/")); err == nil {
+		if version, err := strconv.ParseInt(unquoted, 10, 64); err == nil && version > 0 {
+			return version, nil
+		}
+	}
+	return 0, echo.NewHTTPError(http.StatusPreconditionFailed, fmt.Sprintf("the entity tag %s does not match the user", header))
+}
+
+// statusError converts an error of the auth use case into an HTTP error.
+// err: The error to convert.
+// message: The message describing the failed operation.
+// Returns an *echo.HTTPError with 400 for invalid users and searches, 401 for invalid credentials, 403 for deactivated accounts,
+// 409 for existing accounts, the status code of the storage errors, such as 412 for the users modified since the version of the If-Match header,
+// and 500 otherwise.
+func statusError(err error, message string) error {
+	status := app.StatusCode(err, http.StatusInternalServerError)
+	switch {
+	case errors.Is(err, auth.ErrInvalidUser), errors.Is(err, auth.ErrInvalidSearch):
+		status = http.StatusBadRequest
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		status = http.StatusUnauthorized
+	case errors.Is(err, auth.ErrDeactivated):
+		status = http.StatusForbidden
+	case errors.Is(err, auth.ErrAccountExists):
+		status = http.StatusConflict
+	}
+	return echo.NewHTTPError(status, fmt.Sprintf("%s: %v", message, err))
+}
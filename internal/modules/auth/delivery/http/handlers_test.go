@@ -0,0 +1,169 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failingUC is an auth use case whose operations fail with the given error.
+type failingUC struct {
+	auth.UseCase
+	err error
+}
+
+func (uc failingUC) Register(ctx context.Context, user entities.User) (entities.User, error) {
+	return entities.User{}, uc.err
+}
+
+func (uc failingUC) Login(ctx context.Context, user entities.UserLogin) (string, error) {
+	return "", uc.err
+}
+
+func (uc failingUC) GetAll(ctx context.Context) ([]entities.User, error) {
+	return nil, uc.err
+}
+
+func (uc failingUC) Search(ctx context.Context, search entities.UserSearch) (entities.UserSearchResult, error) {
+	return entities.UserSearchResult{}, uc.err
+}
+
+func (uc failingUC) GetUser(ctx context.Context, id uuid.UUID) (entities.User, error) {
+	return entities.User{}, uc.err
+}
+
+func (uc failingUC) UpdateUser(ctx context.Context, id uuid.UUID, update entities.UserUpdate, version int64) (entities.User, error) {
+	return entities.User{}, uc.err
+}
+
+func (uc failingUC) Authenticate(ctx context.Context, token string) (entities.User, error) {
+	return entities.User{}, uc.err
+}
+
+func serve(err error, method, path string) int {
+	cfg := &config.Config{Auth: config.AuthConfig{OpenRegistration: true}}
+	handlers := NewAuthHandlers(cfg, failingUC{err: err})
+	e := echo.New()
+	e.POST("/auth/register", handlers.Register())
+	e.POST("/auth/login", handlers.Login())
+	e.GET("/auth/all", handlers.GetAll(), Authenticate(failingUC{err: err}))
+	e.GET("/admin/users/search", handlers.Search())
+	e.GET("/admin/users/:id", handlers.GetUser())
+	e.PUT("/admin/users/:id", handlers.UpdateUser())
+
+	req := httptest.NewRequest(method, path, strings.NewReader(`{}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer token")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestStatusCodes(t *testing.T) {
+	conflict := &database.ConflictError{Table: "users", Fields: []string{"email"}}
+	timeout := errors.Join(database.ErrTimeout, context.DeadlineExceeded)
+	user := "/admin/users/" + uuid.NewString()
+	for _, tc := range []struct {
+		name   string
+		method string
+		path   string
+		err    error
+		status int
+	}{
+		{"invalid user", http.MethodPost, "/auth/register", auth.ErrInvalidUser, http.StatusBadRequest},
+		{"existing account", http.MethodPost, "/auth/register", auth.ErrAccountExists, http.StatusConflict},
+		{"unmapped conflict", http.MethodPost, "/auth/register", conflict, http.StatusConflict},
+		{"registration timeout", http.MethodPost, "/auth/register", timeout, http.StatusServiceUnavailable},
+		{"registration failure", http.MethodPost, "/auth/register", errors.New("disk full"), http.StatusInternalServerError},
+		{"invalid credentials", http.MethodPost, "/auth/login", auth.ErrInvalidCredentials, http.StatusUnauthorized},
+		{"deactivated account", http.MethodPost, "/auth/login", auth.ErrDeactivated, http.StatusForbidden},
+		{"login timeout", http.MethodPost, "/auth/login", timeout, http.StatusServiceUnavailable},
+		{"login failure", http.MethodPost, "/auth/login", errors.New("directory unreachable"), http.StatusInternalServerError},
+		{"invalid token", http.MethodGet, "/auth/all", auth.ErrInvalidToken, http.StatusUnauthorized},
+		{"authentication timeout", http.MethodGet, "/auth/all", timeout, http.StatusServiceUnavailable},
+		{"invalid search", http.MethodGet, "/admin/users/search?q=", auth.ErrInvalidSearch, http.StatusBadRequest},
+		{"search timeout", http.MethodGet, "/admin/users/search?q=ali", timeout, http.StatusServiceUnavailable},
+		{"invalid user id", http.MethodGet, "/admin/users/alice", nil, http.StatusBadRequest},
+		{"missing user", http.MethodGet, user, database.ErrNotFound, http.StatusNotFound},
+		{"invalid update", http.MethodPut, user, auth.ErrInvalidUser, http.StatusBadRequest},
+		{"taken username", http.MethodPut, user, auth.ErrAccountExists, http.StatusConflict},
+		{"stale update", http.MethodPut, user, database.Stale("users"), http.StatusPreconditionFailed},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.status, serve(tc.err, tc.method, tc.path))
+		})
+	}
+}
+
+// versionedUC is an auth use case that holds one user and records the version the updates are based on.
+type versionedUC struct {
+	auth.UseCase
+	user    entities.User
+	version *int64
+}
+
+func (uc versionedUC) GetUser(ctx context.Context, id uuid.UUID) (entities.User, error) {
+	return uc.user, nil
+}
+
+func (uc versionedUC) UpdateUser(ctx context.Context, id uuid.UUID, update entities.UserUpdate, version int64) (entities.User, error) {
+	*uc.version = version
+	if version != 0 && version != uc.user.Metadata.Version {
+		return entities.User{}, database.Stale("users")
+	}
+	user := uc.user
+	user.Username, user.Email = update.Username, update.Email
+	user.Metadata.Version++
+	return user, nil
+}
+
+func TestUserETags(t *testing.T) {
+	var version int64
+	uc := versionedUC{
+		user:    entities.User{ID: uuid.New(), Username: "alice", Password: "hash", Metadata: entities.Metadata{Version: 3}},
+		version: &version,
+	}
+	handlers := NewAuthHandlers(&config.Config{}, uc)
+	e := echo.New()
+	e.GET("/admin/users/:id", handlers.GetUser())
+	e.PUT("/admin/users/:id", handlers.UpdateUser())
+	serve := func(method, ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/users/"+uc.user.ID.String(), strings.NewReader(`{"username":"alicia","email":"alicia@example.com"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodGet, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `W/"3"`, rec.Header().Get("ETag"))
+	assert.NotContains(t, rec.Body.String(), "hash", "the password hash is not exposed")
+
+	rec = serve(http.MethodPut, rec.Header().Get("ETag"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.EqualValues(t, 3, version, "the update is based on the version of the If-Match header")
+	assert.Equal(t, `W/"4"`, rec.Header().Get("ETag"), "the response carries the new version")
+
+	assert.Equal(t, http.StatusPreconditionFailed, serve(http.MethodPut, `W/"2"`).Code, "an outdated version is rejected")
+	assert.Equal(t, http.StatusPreconditionFailed, serve(http.MethodPut, `"alice"`).Code, "a tag that is not a version is rejected")
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodPut, "*").Code)
+	assert.Zero(t, version, "a wildcard updates the current version")
+	assert.Equal(t, http.StatusOK, serve(http.MethodPut, "").Code)
+	assert.Zero(t, version)
+}
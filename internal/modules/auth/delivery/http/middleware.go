@@ -0,0 +1,67 @@
+// Package http provides the functionality to handle HTTP requests for the auth module.
+package http
+
+import (
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"                                   // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/internal/app"          // App package provides the functionality to map the errors of the storage layer to HTTP status codes.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth" // Auth package provides the functionality to interact with the auth module.
+	"net/http"
+	"strings"
+)
+
+// Authenticate creates a middleware that requires a valid bearer token.
+// The token is read from the Authorization header, or from the "token" cookie set by the login.
+// The authenticated user is stored in the request context.
+// uc: The auth use case used to check the token.
+// Returns an echo.MiddlewareFunc.
+func Authenticate(uc auth.UseCase) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user, err := uc.Authenticate(c.Request().Context(), bearerToken(c))
+			switch {
+			case errors.Is(err, auth.ErrMissingToken), errors.Is(err, auth.ErrInvalidToken):
+				return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+			case err != nil:
+				return echo.NewHTTPError(app.StatusCode(err, http.StatusInternalServerError), fmt.Sprintf("failed to authenticate: %v", err))
+			}
+			c.SetRequest(c.Request().WithContext(auth.WithUser(c.Request().Context(), user)))
+			return next(c)
+		}
+	}
+}
+
+// OptionalAuthenticate creates a middleware that authenticates the request if it carries a bearer token.
+// Requests without a token pass through anonymously, while requests with an invalid token are rejected.
+// uc: The auth use case used to check the token.
+// Returns an echo.MiddlewareFunc.
+func OptionalAuthenticate(uc auth.UseCase) echo.MiddlewareFunc {
+	required := Authenticate(uc)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		authenticated := required(next)
+		return func(c echo.Context) error {
+			if bearerToken(c) == "" {
+				return next(c)
+			}
+			return authenticated(c)
+		}
+	}
+}
+
+// bearerToken extracts the bearer token from the request.
+// c: The Echo context of the request.
+// Returns the token, or an empty string if the request carries none.
+func bearerToken(c echo.Context) string {
+	if header := c.Request().Header.Get(echo.HeaderAuthorization); header != "" {
+		scheme, token, found := strings.Cut(header, " ")
+		if found && strings.EqualFold(strings.TrimSuffix(scheme, ":"), "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	if cookie, err := c.Cookie("token"); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
@@ -36,3 +36,41 @@ func MapAuthRoutes(authGroup *echo.Group, h auth.Handlers) {
 	// @returns {object} 500 - Server error
 	authGroup.GET("/all", h.GetAll())
 }
+
+// MapAdminRoutes maps the admin routes of the users to the provided Echo group with the provided auth handlers.
+// adminGroup: The Echo group to map the routes to. It is expected to be protected by the admin authentication.
+// h: The auth handlers to use for the routes.
+// The routes include:
+// GET /search: Searches the users by their username or email.
+// GET /:id: Retrieves a user, with its version in the ETag header.
+// PUT /:id: Changes the username and the email of a user. Honours the If-Match header.
+func MapAdminRoutes(adminGroup *echo.Group, h auth.Handlers) {
+	// @route GET /admin/users/search
+	// @group Authentication
+	// @returns {object} 200 - A page of the found users
+	// @returns {object} 400 - The search has no word to search.
+	// @returns {object} 401 - Unauthorized access
+	// @returns {object} 500 - Server error
+	adminGroup.GET("/search", h.Search())
+
+	// @route GET /admin/users/:id
+	// @group Authentication
+	// @returns {object} 200 - The user, with its version in the ETag header
+	// @returns {object} 401 - Unauthorized access
+	// @returns {object} 404 - The user does not exist.
+	// @returns {object} 500 - Server error
+	adminGroup.GET("/:id", h.GetUser())
+
+	// @route PUT /admin/users/:id
+	// @group Authentication
+	// @param {UserUpdate.model} update.body.required - New username and email of the user
+	// @param {string} If-Match.header - ETag of the version of the user the change is based on
+	// @returns {object} 200 - The updated user, with its new version in the ETag header
+	// @returns {object} 400 - The change is invalid.
+	// @returns {object} 401 - Unauthorized access
+	// @returns {object} 404 - The user does not exist.
+	// @returns {object} 409 - The username or the email is already taken.
+	// @returns {object} 412 - The user was modified since the version of the If-Match header.
+	// @returns {object} 500 - Server error
+	adminGroup.PUT("/:id", h.UpdateUser())
+}
@@ -4,6 +4,7 @@ package delivery
 import (
 	"github.com/labstack/echo/v4"                                                 // Echo is a high performance, extensible, minimalist web framework for Go.
 	"github.com/nikita-voronoy/go-clean-arch/config"                              // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/app"                        // App package provides the admin authentication of the admin routes.
 	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth"               // Auth package provides the functionality to interact with the auth module.
 	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth/delivery/http" // HTTP package provides the functionality to deliver the responses of the auth module over HTTP.
 )
@@ -26,7 +27,8 @@ func NewAuthDelivery(cfg *config.Config, uc auth.UseCase) *AuthDelivery {
 	return &AuthDelivery{
 		Handlers: handlers,
 		SetupRoutesFunc: func(e *echo.Echo) {
-			http.MapAuthRoutes(e.Group("/auth"), handlers) // Maps the auth routes to the "/auth" group of the Echo instance.
+			http.MapAuthRoutes(e.Group("/auth"), handlers)                             // Maps the auth routes to the "/auth" group of the Echo instance.
+			http.MapAdminRoutes(e.Group("/admin/users", app.AdminAuth(cfg)), handlers) // Maps the admin routes of the users to the admin-protected "/admin/users" group.
 		},
 	}
 }
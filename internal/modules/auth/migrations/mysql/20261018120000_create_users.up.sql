@@ -0,0 +1,16 @@
+-- The users table, as it was created before the migrations were versioned, so that an existing database is adopted.
+-- The columns added since then are added by the following migrations.
+-- The unique keys are named after their columns, which the conflicts of the database package report.
+CREATE TABLE IF NOT EXISTS users (
+    id {{.UUID}} NOT NULL,
+    username varchar(191) NOT NULL,
+    password varchar(255),
+    email varchar(191) NOT NULL,
+    created_at datetime(6) NULL,
+    updated_at datetime(6) NULL,
+    last_login_at datetime(6) NULL DEFAULT NULL,
+    token longtext,
+    PRIMARY KEY (id),
+    UNIQUE KEY username (username),
+    UNIQUE KEY email (email)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,2 @@
+-- The organization the current token of a user is bound to, which is the tenant claim of the token.
+ALTER TABLE users ADD COLUMN token_organization_id {{.UUID}};
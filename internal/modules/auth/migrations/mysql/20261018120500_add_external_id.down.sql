@@ -0,0 +1,2 @@
+ALTER TABLE users DROP KEY idx_users_external_id;
+ALTER TABLE users DROP COLUMN external_id;
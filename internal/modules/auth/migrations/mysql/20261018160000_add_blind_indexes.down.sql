@@ -0,0 +1,5 @@
+ALTER TABLE users DROP KEY idx_users_token_index;
+ALTER TABLE users DROP KEY email_index;
+ALTER TABLE users DROP COLUMN token_index;
+ALTER TABLE users DROP COLUMN email_index;
+ALTER TABLE users MODIFY email varchar(191) NOT NULL;
@@ -0,0 +1,8 @@
+-- The blind indexes of the encrypted email and token, which the lookups and the uniqueness of the emails use once they are encrypted.
+-- They stay NULL until the encryption is enabled and the users are written or encrypted again.
+-- The emails are widened, since their encryption is longer than the emails.
+ALTER TABLE users MODIFY email varchar(512) NOT NULL;
+ALTER TABLE users ADD COLUMN email_index varchar(64) NULL;
+ALTER TABLE users ADD COLUMN token_index varchar(64) NULL;
+ALTER TABLE users ADD UNIQUE KEY email_index (email_index);
+ALTER TABLE users ADD KEY idx_users_token_index (token_index);
@@ -0,0 +1,2 @@
+-- Whether a user has been deactivated by the identity provider. The existing users are active.
+ALTER TABLE users ADD COLUMN deactivated boolean NOT NULL DEFAULT false;
@@ -0,0 +1,2 @@
+-- The versions of the records, which the updates compare and increment. The existing records start at the first version.
+ALTER TABLE users ADD COLUMN version bigint NOT NULL DEFAULT 1;
@@ -0,0 +1,8 @@
+-- The full-text index of the usernames and the emails, which the admins search the users with.
+-- The punctuation separates the words, so that the parts of the emails are searched as words, and the usernames weigh more than the emails.
+-- The encrypted emails are not indexed, since their ciphertext matches no search.
+ALTER TABLE users ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', regexp_replace(username, '[^[:alnum:]]+', ' ', 'g')), 'A') ||
+    setweight(to_tsvector('simple', CASE WHEN email LIKE 'enc:%' THEN '' ELSE regexp_replace(email, '[^[:alnum:]]+', ' ', 'g') END), 'B')
+) STORED;
+CREATE INDEX IF NOT EXISTS idx_users_search ON users USING gin (search);
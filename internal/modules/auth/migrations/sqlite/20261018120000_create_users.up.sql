@@ -0,0 +1,13 @@
+-- The users table, as it was created before the migrations were versioned, so that an existing database is adopted.
+-- The columns added since then are added by the following migrations.
+CREATE TABLE IF NOT EXISTS users (
+    id uuid NOT NULL,
+    username text NOT NULL UNIQUE,
+    password text,
+    email text NOT NULL UNIQUE,
+    created_at datetime,
+    updated_at datetime,
+    last_login_at datetime DEFAULT NULL,
+    token text,
+    PRIMARY KEY (id)
+);
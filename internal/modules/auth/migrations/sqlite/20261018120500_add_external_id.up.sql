@@ -0,0 +1,3 @@
+-- The ID of a user in the identity provider that provisions it, which is empty for the users that signed up themselves.
+ALTER TABLE users ADD COLUMN external_id text;
+CREATE INDEX IF NOT EXISTS idx_users_external_id ON users (external_id);
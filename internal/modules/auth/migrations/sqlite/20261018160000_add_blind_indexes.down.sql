@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_users_token_index;
+DROP INDEX IF EXISTS idx_users_email_index;
+ALTER TABLE users DROP COLUMN token_index;
+ALTER TABLE users DROP COLUMN email_index;
@@ -0,0 +1,6 @@
+-- The blind indexes of the encrypted email and token, which the lookups and the uniqueness of the emails use once they are encrypted.
+-- They stay NULL until the encryption is enabled and the users are written or encrypted again.
+ALTER TABLE users ADD COLUMN email_index text;
+ALTER TABLE users ADD COLUMN token_index text;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_index ON users (email_index);
+CREATE INDEX IF NOT EXISTS idx_users_token_index ON users (token_index);
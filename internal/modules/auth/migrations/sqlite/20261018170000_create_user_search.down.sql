@@ -0,0 +1,5 @@
+-- Drops the full-text index of the users, which the repeatable script creates again on the next migration if the library has the FTS5 extension.
+DROP TRIGGER IF EXISTS users_search_delete;
+DROP TRIGGER IF EXISTS users_search_update;
+DROP TRIGGER IF EXISTS users_search_insert;
+DROP TABLE IF EXISTS users_search;
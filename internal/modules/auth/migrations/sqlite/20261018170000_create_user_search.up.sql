@@ -0,0 +1,3 @@
+-- The full-text index of the users is created by the repeatable script repeatable/users_search.sql, which runs on every migration,
+-- so that it is created whenever the SQLite library has the FTS5 extension, even in a database migrated without it.
+-- This migration is kept so that the databases which recorded it stay consistent.
@@ -0,0 +1,27 @@
+-- The full-text index of the usernames and the emails, which the admins search the users with.
+-- It needs a SQLite library with the FTS5 extension, see the sqlite_fts5 build tag; without it the users are searched by a scan.
+-- It is a repeatable script rather than a migration so that a library with the extension creates it in a database
+-- migrated by a library without it. Once it is created, the users can only be written by a library with the extension,
+-- since the triggers update the index.
+-- The encrypted emails are not indexed, since their ciphertext matches no search.
+{{if .FTS5}}
+CREATE VIRTUAL TABLE IF NOT EXISTS users_search USING fts5(id UNINDEXED, username, email);
+-- The usernames weigh twice as much as the emails in the ranking.
+INSERT INTO users_search(users_search, rank) VALUES ('rank', 'bm25(0.0, 2.0, 1.0)');
+-- The triggers keep the index up to date once it exists, so the users are only copied into a new index.
+INSERT INTO users_search(id, username, email)
+    SELECT id, username, CASE WHEN email LIKE 'enc:%' THEN '' ELSE email END FROM users
+    WHERE NOT EXISTS (SELECT 1 FROM users_search);
+CREATE TRIGGER IF NOT EXISTS users_search_insert AFTER INSERT ON users BEGIN
+    INSERT INTO users_search(id, username, email)
+        VALUES (new.id, new.username, CASE WHEN new.email LIKE 'enc:%' THEN '' ELSE new.email END);
+END;
+CREATE TRIGGER IF NOT EXISTS users_search_update AFTER UPDATE OF id, username, email ON users BEGIN
+    DELETE FROM users_search WHERE id = old.id;
+    INSERT INTO users_search(id, username, email)
+        VALUES (new.id, new.username, CASE WHEN new.email LIKE 'enc:%' THEN '' ELSE new.email END);
+END;
+CREATE TRIGGER IF NOT EXISTS users_search_delete AFTER DELETE ON users BEGIN
+    DELETE FROM users_search WHERE id = old.id;
+END;
+{{end}}
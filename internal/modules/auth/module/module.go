@@ -2,28 +2,76 @@
 package module
 
 import (
+	"context"                                                                     // Context package provides the functionality to carry deadlines and cancellation signals.
 	"github.com/labstack/echo/v4"                                                 // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/config"                              // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/app"                        // App package provides the admin authentication of the admin routes.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth/authenticator" // Authenticator package provides the authentication backends of the auth module.
 	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth/delivery"      // Delivery package provides the functionality to deliver the responses of the auth module.
 	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth/delivery/http" // HTTP package provides the functionality to deliver the responses of the auth module over HTTP.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth/migrations"    // Migrations package provides the SQL migrations of the schema of the auth module.
 	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth/usecase"       // Usecase package provides the functionality to interact with the use cases of the auth module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"                    // Storage package provides the interfaces of the repositories.
 	"github.com/nikita-voronoy/go-clean-arch/internal/storage/user"               // User package provides the functionality to interact with the user storage.
+	"github.com/nikita-voronoy/go-clean-arch/pkg/cache"                           // Cache package provides the caches of the application.
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"                        // Database package provides the functionality to interact with the database of the application.
 	"go.uber.org/fx"                                                              // Fx is a framework for Go that provides the building blocks for your service architectures.
+	"io"                                                                          // IO package provides the functionality to close the connections of the cache.
+	"time"                                                                        // Time package provides the functionality to work with time.
 )
 
+// Migrations is a Fx option that registers the SQL migrations of the auth module with the migrations group.
+var Migrations = fx.Supply(fx.Annotated{Group: "migrations", Target: migrations.Source})
+
 // Module is a Fx options group that provides and invokes the necessary dependencies for the auth module.
 var Module = fx.Options(
+	Migrations, // Registers the SQL migrations of the auth module.
 	fx.Provide(
-		user.NewUserRepository,   // Provides a new user repository.
-		usecase.NewAuthUC,        // Provides a new auth use case.
-		http.NewAuthHandlers,     // Provides new auth handlers.
-		delivery.NewAuthDelivery, // Provides a new auth delivery.
+		newUserRepository,              // Provides a new user repository, which records the domain events of the users and reads through the configured cache.
+		authenticator.NewAuthenticator, // Provides the configured authentication backend.
+		usecase.NewAuthUC,              // Provides a new auth use case.
+		http.NewAuthHandlers,           // Provides new auth handlers.
+		delivery.NewAuthDelivery,       // Provides a new auth delivery.
 	),
 	fx.Invoke(registerAuthRoutes), // Invokes the function to register the auth routes.
 )
 
-// registerAuthRoutes registers the auth routes with the provided Echo instance and auth handlers.
+// newUserRepository creates the user repository, which appends the domain events of the writes to the outbox,
+// and decorates it with the configured cache. The connections of the cache are closed when the application stops.
+// lc: The lifecycle of the application.
+// cfg: The configuration of the cache, and of the encryption keys whose blind indexes the emails are cached under.
+// db: The database of the users.
+// tx: The transactions the writes and their events run in.
+// outbox: The outbox the domain events are appended to.
+// Returns the user repository, and an error if the cache type is not supported or an encryption key is invalid.
+func newUserRepository(lc fx.Lifecycle, cfg *config.Config, db database.Database, tx storage.Transactor, outbox storage.OutboxRepository) (storage.UserRepository, error) {
+	var users storage.UserRepository = user.NewEventRepository(user.NewUserRepository(db), tx, outbox)
+	keys, err := app.NewKeyring(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c, err := cache.New(cfg.Cache)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return users, nil
+	}
+	if closer, ok := c.(io.Closer); ok {
+		lc.Append(fx.Hook{OnStop: func(context.Context) error {
+			return closer.Close()
+		}})
+	}
+	ttl := time.Duration(cfg.Cache.TTLSeconds) * time.Second
+	negativeTTL := time.Duration(cfg.Cache.NegativeTTLSeconds) * time.Second
+	return user.NewCachedRepository(users, c, keys, ttl, negativeTTL), nil
+}
+
+// registerAuthRoutes registers the auth routes and the admin routes of the users with the provided Echo instance and auth handlers.
 // e: The Echo instance to register the routes with.
+// cfg: The configuration of the admin authentication.
 // handlers: The auth handlers to use for the routes.
-func registerAuthRoutes(e *echo.Echo, handlers *http.AuthHandlers) {
-	http.MapAuthRoutes(e.Group("/auth"), handlers) // Maps the auth routes to the "/auth" group of the Echo instance.
+func registerAuthRoutes(e *echo.Echo, cfg *config.Config, handlers *http.AuthHandlers) {
+	http.MapAuthRoutes(e.Group("/auth"), handlers)                             // Maps the auth routes to the "/auth" group of the Echo instance.
+	http.MapAdminRoutes(e.Group("/admin/users", app.AdminAuth(cfg)), handlers) // Maps the admin routes of the users to the admin-protected "/admin/users" group.
 }
@@ -3,10 +3,26 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"github.com/google/uuid"
 	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
 )
 
+var (
+	// ErrInvalidUser is returned when the user record of a registration is not valid.
+	ErrInvalidUser = errors.New("invalid user")
+	// ErrAccountExists is returned when an account with the given email or username already exists.
+	ErrAccountExists = errors.New("an account with the given email or username already exists")
+	// ErrDeactivated is returned when a deactivated account logs in.
+	ErrDeactivated = errors.New("the account has been deactivated")
+	// ErrMissingToken is returned when a request that requires authentication carries no bearer token.
+	ErrMissingToken = errors.New("missing bearer token")
+	// ErrInvalidToken is returned when the bearer token was not issued or belongs to a deactivated account.
+	ErrInvalidToken = errors.New("invalid bearer token")
+	// ErrInvalidSearch is returned when a search of the users has no word to search.
+	ErrInvalidSearch = errors.New("invalid search")
+)
+
 // UseCase is an interface that defines the methods required for user authentication operations.
 // It includes methods for registering, logging in, getting all users, hashing and comparing passwords, generating UUIDs and bearer tokens, and validating users.
 // Each method requires a context and an entity.
@@ -15,8 +31,8 @@ type UseCase interface {
 	// Register adds a new user record to the storage.
 	// ctx: The context for the operation.
 	// user: The user record to add.
-	// Returns an error if the operation fails.
-	Register(ctx context.Context, user entities.User) error
+	// Returns the created user record and an error if the operation fails.
+	Register(ctx context.Context, user entities.User) (entities.User, error)
 
 	// Login checks the user credentials and logs in the user.
 	// ctx: The context for the operation.
@@ -29,6 +45,34 @@ type UseCase interface {
 	// Returns the user records and an error if the operation fails.
 	GetAll(ctx context.Context) ([]entities.User, error)
 
+	// GetUser retrieves a user.
+	// ctx: The context for the operation.
+	// id: The ID of the user.
+	// Returns the user record, whose version is its entity tag, and database.ErrNotFound if there is no such user.
+	GetUser(ctx context.Context, id uuid.UUID) (entities.User, error)
+
+	// UpdateUser changes the username and the email of a user.
+	// ctx: The context for the operation.
+	// id: The ID of the user.
+	// update: The new username and email of the user.
+	// version: The version of the user the client based the change on, or 0 to change the current version.
+	// Returns the updated user record, ErrInvalidUser if the change is not valid, ErrAccountExists if the username or the email is taken,
+	// database.ErrNotFound if there is no such user, and database.ErrStale if the user was modified since the version.
+	UpdateUser(ctx context.Context, id uuid.UUID, update entities.UserUpdate, version int64) (entities.User, error)
+
+	// Search retrieves the users whose username or email matches the search, most relevant first, with their matching parts highlighted.
+	// The emails are not searched when they are encrypted at rest, since their ciphertext cannot be indexed.
+	// ctx: The context for the operation.
+	// search: The text and the window of the search. The window defaults to the first 20 users and holds at most 100 users.
+	// Returns a page of the found users and ErrInvalidSearch if the text has no word.
+	Search(ctx context.Context, search entities.UserSearch) (entities.UserSearchResult, error)
+
+	// Authenticate retrieves the user the bearer token was issued to.
+	// ctx: The context for the operation.
+	// token: The bearer token to check.
+	// Returns the user record and an error if the token is not valid.
+	Authenticate(ctx context.Context, token string) (entities.User, error)
+
 	// HashPassword hashes the provided password.
 	// password: The password to hash.
 	// Returns the hashed password and an error if the operation fails.
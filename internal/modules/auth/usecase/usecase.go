@@ -11,26 +11,59 @@ import (
 	"github.com/google/uuid"
 	"github.com/nikita-voronoy/go-clean-arch/config"
 	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/audit"
 	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth"
 	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/eventbus"
 	"golang.org/x/crypto/bcrypt"
+	"html"
+	"log"
+	"strings"
 	"time"
+	"unicode"
 )
 
+const (
+	// defaultSearchLimit is the number of users returned by Search when the search sets no limit.
+	defaultSearchLimit = 20
+	// maxSearchLimit is the maximum number of users returned by Search.
+	maxSearchLimit = 100
+)
+
+// updateAttempts is the number of times a change that names no version is applied before it gives up on a user that keeps being modified.
+const updateAttempts = 3
+
+// loginAttempts is the number of times a login issues the bearer token before it gives up on a user that keeps being modified.
+const loginAttempts = 3
+
 // AuthUseCase struct represents a user authentication use case that provides methods for user authentication operations.
 type AuthUseCase struct {
-	cfg  *config.Config
-	repo storage.UserRepository
+	cfg           *config.Config
+	repo          storage.UserRepository
+	tx            storage.Transactor
+	authenticator auth.Authenticator
+	audit         audit.Recorder
+	events        *eventbus.Bus
 }
 
-// NewAuthUC creates a new user authentication use case with the provided configuration and user repository.
+// NewAuthUC creates a new user authentication use case with the provided configuration, user repository, transactor, authenticator, audit recorder and event bus.
 // cfg: The configuration for the user authentication use case.
 // repo: The user repository for the user authentication use case.
+// tx: The transactor that makes the registration atomic.
+// authenticator: The authentication backend that checks the credentials on login.
+// recorder: The audit recorder the registrations and logins are written to.
+// events: The event bus the UserRegistered, UserLoggedIn and LoginFailed events are published on. A nil bus publishes no events.
 // Returns an auth.UseCase object.
-func NewAuthUC(cfg *config.Config, repo storage.UserRepository) auth.UseCase {
+func NewAuthUC(cfg *config.Config, repo storage.UserRepository, tx storage.Transactor, authenticator auth.Authenticator, recorder audit.Recorder, events *eventbus.Bus) auth.UseCase {
 	return &AuthUseCase{
-		cfg:  cfg,
-		repo: repo,
+		cfg:           cfg,
+		repo:          repo,
+		tx:            tx,
+		authenticator: authenticator,
+		audit:         recorder,
+		events:        events,
 	}
 }
 
@@ -93,28 +126,174 @@ func (uc AuthUseCase) GetAll(ctx context.Context) ([]entities.User, error) {
 	return users, nil
 }
 
-// Register adds a new user record to the storage.
+// GetUser retrieves a user.
 // ctx: The context for the operation.
-// user: The user record to add.
-// Returns an error if the operation fails.
-func (uc AuthUseCase) Register(ctx context.Context, user entities.User) error {
+// id: The ID of the user.
+// Returns the user record, whose version is its entity tag, and database.ErrNotFound if there is no such user.
+func (uc AuthUseCase) GetUser(ctx context.Context, id uuid.UUID) (entities.User, error) {
+	return uc.repo.Read(ctx, id)
+}
 
-	if err := uc.Validate(user); err != nil {
+// UpdateUser changes the username and the email of a user.
+// The user is read from the primary rather than from a cache or a replica, which may still hold an outdated version.
+// A change based on the version the client read is never retried, since the client has to see the new version first,
+// while a change that names no version is applied again to the new version whenever a concurrent modification wins the race.
+// ctx: The context for the operation.
+// id: The ID of the user.
+// update: The new username and email of the user.
+// version: The version of the user the client based the change on, or 0 to change the current version.
+// Returns the updated user record, auth.ErrInvalidUser if the change is not valid, auth.ErrAccountExists if the username or the email is taken,
+// database.ErrNotFound if there is no such user, and database.ErrStale if the user was modified since the version.
+func (uc AuthUseCase) UpdateUser(ctx context.Context, id uuid.UUID, update entities.UserUpdate, version int64) (entities.User, error) {
+	if err := validator.New().Struct(update); err != nil {
 		var validationErrors validator.ValidationErrors
 		if errors.As(err, &validationErrors) {
-			return formatValidationError(validationErrors)
+			return entities.User{}, fmt.Errorf("%w: %w", auth.ErrInvalidUser, formatValidationError(validationErrors))
 		}
-		return err
+		return entities.User{}, fmt.Errorf("%w: %w", auth.ErrInvalidUser, err)
 	}
 
-	exists, err := uc.repo.CheckUserExists(ctx, user.Email, user.Username)
-	if err != nil && !errors.Is(err, errors.New("record not found")) {
-		return err
+	ctx = database.WithPrimary(ctx)
+	for attempt := 1; ; attempt++ {
+		user, err := uc.repo.Read(ctx, id)
+		if err != nil {
+			return entities.User{}, err
+		}
+		if version != 0 && user.Metadata.Version != version {
+			return entities.User{}, database.Stale("users")
+		}
+		user.Username, user.Email = update.Username, update.Email
+
+		err = uc.repo.Update(ctx, user)
+		var conflict *database.ConflictError
+		switch {
+		case err == nil:
+			return uc.repo.Read(ctx, id)
+		case errors.Is(err, database.ErrStale):
+			if version == 0 && attempt < updateAttempts {
+				continue
+			}
+			return entities.User{}, err
+		case errors.As(err, &conflict) && conflict.Field() != "":
+			return entities.User{}, fmt.Errorf("%w: the %s is taken", auth.ErrAccountExists, conflict.Field())
+		case errors.Is(err, database.ErrConflict):
+			return entities.User{}, auth.ErrAccountExists
+		default:
+			return entities.User{}, err
+		}
 	}
-	if exists {
-		return errors.New("an account with the given email or username already exists")
+}
+
+// Search retrieves the users whose username or email matches the search, most relevant first, with their matching parts highlighted.
+// The emails are not searched when they are encrypted at rest, since their ciphertext cannot be indexed.
+// ctx: The context for the operation.
+// search: The text and the window of the search. The window defaults to the first defaultSearchLimit users and holds at most maxSearchLimit users.
+// Returns a page of the found users and auth.ErrInvalidSearch if the text has no word.
+func (uc AuthUseCase) Search(ctx context.Context, search entities.UserSearch) (entities.UserSearchResult, error) {
+	terms := query.Terms(search.Q)
+	if len(terms) == 0 {
+		return entities.UserSearchResult{}, fmt.Errorf("%w: the text has no word to search", auth.ErrInvalidSearch)
+	}
+	if search.Limit <= 0 {
+		search.Limit = defaultSearchLimit
+	}
+	if search.Limit > maxSearchLimit {
+		search.Limit = maxSearchLimit
 	}
+	if search.Offset < 0 {
+		search.Offset = 0
+	}
+	result, err := uc.repo.Search(ctx, search)
+	if err != nil {
+		return entities.UserSearchResult{}, err
+	}
+	for i, hit := range result.Hits {
+		highlights := map[string]string{}
+		if text, ok := highlight(hit.User.Username, terms); ok {
+			highlights["username"] = text
+		}
+		if text, ok := highlight(hit.User.Email, terms); ok {
+			highlights["email"] = text
+		}
+		result.Hits[i].Highlights = highlights
+	}
+	return result, nil
+}
 
+// highlight escapes a text for HTML and wraps the parts of its words that the terms start in <mark> tags.
+// The words are split as query.Terms splits them, so that the highlights match the words the search matched.
+// text: The text to highlight.
+// terms: The lowercase terms of the search.
+// Returns the highlighted text, and false if no word of the text matches.
+func highlight(text string, terms []string) (string, bool) {
+	var (
+		b       strings.Builder
+		matched bool
+	)
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !unicode.IsLetter(runes[i]) && !unicode.IsDigit(runes[i]) {
+			b.WriteString(html.EscapeString(string(runes[i])))
+			i++
+			continue
+		}
+		end := i
+		for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end])) {
+			end++
+		}
+		word := runes[i:end]
+		length := 0
+		for _, term := range terms {
+			if n := len([]rune(term)); n > length && n <= len(word) && strings.ToLower(string(word[:n])) == term {
+				length = n
+			}
+		}
+		if length > 0 {
+			matched = true
+			b.WriteString("<mark>" + html.EscapeString(string(word[:length])) + "</mark>")
+		}
+		b.WriteString(html.EscapeString(string(word[length:])))
+		i = end
+	}
+	return b.String(), matched
+}
+
+// Register adds a new user record to the storage.
+// ctx: The context for the operation.
+// user: The user record to add.
+// Returns the created user record and an error if the operation fails.
+func (uc AuthUseCase) Register(ctx context.Context, user entities.User) (entities.User, error) {
+	if err := uc.register(ctx, &user); err != nil {
+		uc.record(ctx, entities.AuditActionRegister, user.Email, "", err)
+		return entities.User{}, err
+	}
+	uc.record(ctx, entities.AuditActionRegister, user.Email, user.ID.String(), nil)
+	publish(ctx, uc.events, entities.UserRegistered{
+		UserID:     user.ID,
+		Username:   user.Username,
+		Email:      user.Email,
+		OccurredAt: time.Now().UTC(),
+	})
+	return user, nil
+}
+
+// register validates, hashes and stores the new user record.
+// The check for an existing account and the creation run in a transaction, so that concurrent registrations
+// of the same email or username cannot both succeed.
+// ctx: The context for the operation.
+// user: The user record to add. Its ID and password are replaced by the generated ID and the password hash.
+// Returns an error if the operation fails.
+func (uc AuthUseCase) register(ctx context.Context, user *entities.User) error {
+	if err := uc.Validate(*user); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			return fmt.Errorf("%w: %w", auth.ErrInvalidUser, formatValidationError(validationErrors))
+		}
+		return fmt.Errorf("%w: %w", auth.ErrInvalidUser, err)
+	}
+
+	// The password is hashed before the transaction starts, since hashing is slow on purpose.
+	var err error
 	user.ID, err = uc.GenerateUUID()
 	if err != nil {
 		return err
@@ -124,11 +303,26 @@ func (uc AuthUseCase) Register(ctx context.Context, user entities.User) error {
 		return err
 	}
 
-	if err := uc.repo.Create(ctx, user); err != nil {
+	err = uc.tx.WithTx(ctx, func(ctx context.Context) error {
+		exists, err := uc.repo.CheckUserExists(ctx, user.Email, user.Username)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return auth.ErrAccountExists
+		}
+		return uc.repo.Create(ctx, *user)
+	})
+	// Under a weaker isolation level, concurrent registrations can both pass the check, and the unique constraints reject the later one.
+	var conflict *database.ConflictError
+	switch {
+	case errors.As(err, &conflict) && conflict.Field() != "":
+		return fmt.Errorf("%w: the %s is taken", auth.ErrAccountExists, conflict.Field())
+	case errors.Is(err, database.ErrConflict):
+		return auth.ErrAccountExists
+	default:
 		return err
 	}
-
-	return nil
 }
 
 // Login checks the user credentials and logs in the user.
@@ -136,27 +330,138 @@ func (uc AuthUseCase) Register(ctx context.Context, user entities.User) error {
 // userLogin: The user login record to check.
 // Returns a string and an error if the operation fails.
 func (uc AuthUseCase) Login(ctx context.Context, userLogin entities.UserLogin) (string, error) {
-
-	existingUser, err := uc.repo.ReadByEmail(ctx, userLogin.Email)
+	existingUser, err := uc.login(ctx, userLogin)
+	ip := audit.ClientFromContext(ctx).IP
 	if err != nil {
+		uc.record(ctx, entities.AuditActionLogin, userLogin.Email, existingUser.ID.String(), err)
+		publish(ctx, uc.events, entities.LoginFailed{
+			UserID:     existingUser.ID,
+			Email:      userLogin.Email,
+			IP:         ip,
+			Reason:     err.Error(),
+			OccurredAt: time.Now().UTC(),
+		})
 		return "", err
 	}
+	uc.record(ctx, entities.AuditActionLogin, userLogin.Email, existingUser.ID.String(), nil)
+	publish(ctx, uc.events, entities.UserLoggedIn{
+		UserID:     existingUser.ID,
+		Email:      userLogin.Email,
+		IP:         ip,
+		OccurredAt: time.Now().UTC(),
+	})
+	return existingUser.Token, nil
+}
 
-	if err := uc.ComparePasswords(existingUser.Password, userLogin.Password); err != nil {
-		return "", err
+// login checks the user credentials and issues a new bearer token.
+// ctx: The context for the operation.
+// userLogin: The user login record to check.
+// Returns the logged in user, which carries the ID of the user whenever it was found, and an error if the operation fails.
+func (uc AuthUseCase) login(ctx context.Context, userLogin entities.UserLogin) (entities.User, error) {
+	existingUser, err := uc.authenticator.Authenticate(ctx, userLogin)
+	if err != nil {
+		return existingUser, err
+	}
+	if existingUser.Deactivated {
+		return existingUser, auth.ErrDeactivated
+	}
+
+	// The user is read again whenever a concurrent modification, such as an admin change, wins the race,
+	// so that issuing the token never overwrites it. It is read from the primary rather than from a cache or a replica,
+	// which may still hold the outdated version.
+	for attempt := 1; ; attempt++ {
+		existingUser.Token, err = uc.GenerateBearerToken()
+		if err != nil {
+			return existingUser, err
+		}
+		existingUser.TokenOrganizationID = uuid.Nil
+		existingUser.Metadata.LastLoginAt = time.Now()
+
+		err = uc.repo.Update(ctx, existingUser)
+		if !errors.Is(err, database.ErrStale) || attempt == loginAttempts {
+			return existingUser, err
+		}
+		current, err := uc.repo.Read(database.WithPrimary(ctx), existingUser.ID)
+		if err != nil {
+			return existingUser, err
+		}
+		existingUser = current
+		if existingUser.Deactivated {
+			return existingUser, auth.ErrDeactivated
+		}
 	}
+}
 
-	existingUser.Token, err = uc.GenerateBearerToken()
+// Authenticate retrieves the user the bearer token was issued to.
+// ctx: The context for the operation.
+// token: The bearer token to check.
+// Returns the user record and an error if the token is not valid.
+func (uc AuthUseCase) Authenticate(ctx context.Context, token string) (entities.User, error) {
+	if token == "" {
+		return entities.User{}, auth.ErrMissingToken
+	}
+	user, err := uc.repo.ReadByToken(ctx, token)
+	if errors.Is(err, database.ErrNotFound) || (err == nil && user.Deactivated) {
+		return entities.User{}, auth.ErrInvalidToken
+	}
 	if err != nil {
-		return "", err
+		return entities.User{}, err
 	}
-	existingUser.Metadata.LastLoginAt = time.Now()
+	return user, nil
+}
 
-	if err := uc.repo.Update(ctx, existingUser); err != nil {
-		return "", err
+// record writes the outcome of an action to the audit log, after the transaction of the context if it carries one,
+// such as the transaction of an invitation that registers the invitee, so that the audit log is not written while it is open.
+// A success is recorded once the transaction is committed, since it is undone by a rollback, while a failure is recorded once
+// the transaction ends, whatever its outcome.
+// A failure to write the audit event is logged and does not fail the action itself.
+// ctx: The context for the operation.
+// action: The action that was performed.
+// actor: The identity that performed the action.
+// target: The ID of the user the action was performed on.
+// err: The error the action failed with, or nil if it succeeded.
+func (uc AuthUseCase) record(ctx context.Context, action, actor, target string, err error) {
+	event := entities.AuditEvent{
+		Actor:   actor,
+		Action:  action,
+		Target:  target,
+		Outcome: entities.AuditOutcomeSuccess,
 	}
+	if target == uuid.Nil.String() {
+		event.Target = ""
+	}
+	if err != nil {
+		event.Outcome = entities.AuditOutcomeFailure
+		event.Reason = err.Error()
+	}
+	write := func(ctx context.Context) {
+		if err := uc.audit.Record(ctx, event); err != nil {
+			log.Printf("Failed to record audit event: %v\n", err)
+		}
+	}
+	switch {
+	case err == nil && storage.AfterCommit(ctx, write):
+	case err != nil && storage.AfterTx(ctx, write):
+	default:
+		write(ctx)
+	}
+}
 
-	return existingUser.Token, nil
+// publish publishes a domain event on the event bus, once the transaction of the context is committed if it carries one,
+// such as the transaction of an invitation that registers the invitee.
+// A failure of a subscriber is logged and does not fail the action that was already performed.
+// ctx: The context for the operation.
+// bus: The event bus to publish the event on.
+// event: The event to publish.
+func publish[E any](ctx context.Context, bus *eventbus.Bus, event E) {
+	send := func(ctx context.Context) {
+		if err := eventbus.Publish(ctx, bus, event); err != nil {
+			log.Printf("Failed to publish %T event: %v\n", event, err)
+		}
+	}
+	if !storage.AfterCommit(ctx, send) {
+		send(ctx)
+	}
 }
 
 // formatValidationError formats the validation errors.
@@ -0,0 +1,338 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/audit"
+	auditmigrations "github.com/nikita-voronoy/go-clean-arch/internal/modules/audit/migrations"
+	auditusecase "github.com/nikita-voronoy/go-clean-arch/internal/modules/audit/usecase"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth/authenticator"
+	authmigrations "github.com/nikita-voronoy/go-clean-arch/internal/modules/auth/migrations"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	auditstorage "github.com/nikita-voronoy/go-clean-arch/internal/storage/audit"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/user"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/cache"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/memory"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/eventbus"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/eventbus/eventbustest"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// concurrentRegistrations is the number of registrations that race for the same account.
+const concurrentRegistrations = 8
+
+// databases are the configurations of the databases the registrations race on, which need no server.
+var databases = map[string]func(t *testing.T) config.DatabaseConfig{
+	"sqlite": func(t *testing.T) config.DatabaseConfig {
+		return config.DatabaseConfig{DatabaseType: "sqlite", Sqlite: config.SqliteConfig{DatabasePath: filepath.Join(t.TempDir(), "auth.sqlite3")}}
+	},
+	"bolt": func(t *testing.T) config.DatabaseConfig {
+		return config.DatabaseConfig{DatabaseType: "bolt", Bolt: config.BoltConfig{Path: filepath.Join(t.TempDir(), "auth.bolt"), TimeoutSeconds: 1}}
+	},
+	"memory": func(t *testing.T) config.DatabaseConfig {
+		return config.DatabaseConfig{DatabaseType: "memory"}
+	},
+}
+
+func newTestUC(t *testing.T, dbCfg config.DatabaseConfig) (auth.UseCase, storage.UserRepository, storage.AuditRepository) {
+	cfg := &config.Config{DB: dbCfg}
+	db, err := database.NewDatabase(cfg)
+	require.NoError(t, err, "Failed to create new database")
+	if closer, ok := db.(io.Closer); ok {
+		t.Cleanup(func() { _ = closer.Close() })
+	}
+	if migrator, err := database.NewMigrator(db, authmigrations.Source, auditmigrations.Source); err == nil {
+		_, err = migrator.Up(context.Background(), 0)
+		require.NoError(t, err, "Failed to migrate the database")
+	}
+	users := user.NewUserRepository(db)
+	events := auditstorage.NewAuditRepository(db)
+	uc := NewAuthUC(cfg, users, storage.NewTransactor(db), authenticator.NewLocal(users), auditusecase.NewAuditUC(cfg, events, storage.NewTransactor(db)), nil)
+	return uc, users, events
+}
+
+func TestRegister(t *testing.T) {
+	uc, users, _ := newTestUC(t, config.DatabaseConfig{DatabaseType: "memory"})
+	ctx := context.Background()
+
+	registered, err := uc.Register(ctx, entities.User{Username: "alice", Email: "alice@example.com", Password: "password"})
+	require.NoError(t, err)
+	stored, err := users.Read(ctx, registered.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, "password", stored.Password, "The password must be hashed")
+
+	_, err = uc.Register(ctx, entities.User{Username: "alice", Email: "other@example.com", Password: "password"})
+	assert.ErrorIs(t, err, auth.ErrAccountExists, "Usernames are unique")
+	_, err = uc.Register(ctx, entities.User{Username: "other", Email: "alice@example.com", Password: "password"})
+	assert.ErrorIs(t, err, auth.ErrAccountExists, "Emails are unique")
+	_, err = uc.Register(ctx, entities.User{Username: "bob", Email: "not an email", Password: "password"})
+	assert.ErrorIs(t, err, auth.ErrInvalidUser)
+
+	_, err = uc.Login(ctx, entities.UserLogin{Email: "nobody@example.com", Password: "password"})
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials, "An unknown email is not a storage error")
+	stored.Deactivated = true
+	require.NoError(t, users.Update(ctx, stored))
+	_, err = uc.Login(ctx, entities.UserLogin{Email: "alice@example.com", Password: "password"})
+	assert.ErrorIs(t, err, auth.ErrDeactivated)
+}
+
+func TestSearch(t *testing.T) {
+	uc, _, _ := newTestUC(t, config.DatabaseConfig{DatabaseType: "memory"})
+	ctx := context.Background()
+	for _, name := range []string{"alice", "alicia", "bob"} {
+		_, err := uc.Register(ctx, entities.User{Username: name, Email: name + "@example.com", Password: "password"})
+		require.NoError(t, err)
+	}
+
+	result, err := uc.Search(ctx, entities.UserSearch{Q: "ali EX", Limit: 1000, Offset: -1})
+	require.NoError(t, err)
+	assert.Equal(t, maxSearchLimit, result.Limit)
+	assert.Zero(t, result.Offset)
+	require.Len(t, result.Hits, 2)
+	assert.Equal(t, "alice", result.Hits[0].User.Username)
+	assert.Equal(t, map[string]string{
+		"username": "<mark>ali</mark>ce",
+		"email":    "<mark>ali</mark>ce@<mark>ex</mark>ample.com",
+	}, result.Hits[0].Highlights)
+
+	result, err = uc.Search(ctx, entities.UserSearch{Q: "example"})
+	require.NoError(t, err)
+	assert.Equal(t, defaultSearchLimit, result.Limit)
+	assert.Len(t, result.Hits, 3)
+	assert.NotContains(t, result.Hits[0].Highlights, "username", "the fields that do not match are not highlighted")
+
+	_, err = uc.Search(ctx, entities.UserSearch{Q: " *:! "})
+	assert.ErrorIs(t, err, auth.ErrInvalidSearch)
+
+	highlighted, ok := highlight(`<Alice> & al`, []string{"al", "ali"})
+	assert.True(t, ok)
+	assert.Equal(t, `&lt;<mark>Ali</mark>ce&gt; &amp; <mark>al</mark>`, highlighted, "the text is escaped and the longest term is marked")
+	_, ok = highlight("bob", []string{"ali"})
+	assert.False(t, ok)
+}
+
+func TestEvents(t *testing.T) {
+	db := memory.NewDatabase()
+	users := user.NewUserRepository(db)
+	bus := eventbus.New()
+	recorder := eventbustest.NewRecorder(bus)
+	uc := NewAuthUC(&config.Config{}, users, storage.NewTransactor(db), authenticator.NewLocal(users), nopRecorder{}, bus)
+	ctx := audit.WithClient(context.Background(), audit.Client{IP: "10.0.0.1"})
+
+	failure := errors.New("failure")
+	err := storage.NewTransactor(db).WithTx(ctx, func(ctx context.Context) error {
+		_, err := uc.Register(ctx, entities.User{Username: "carol", Email: "carol@example.com", Password: "password"})
+		require.NoError(t, err)
+		assert.Empty(t, recorder.Events(), "The registration is published once the enclosing transaction is committed")
+		return failure
+	})
+	require.ErrorIs(t, err, failure)
+	assert.Empty(t, recorder.Events(), "A registration that is rolled back publishes no event")
+
+	registered, err := uc.Register(ctx, entities.User{Username: "alice", Email: "alice@example.com", Password: "password"})
+	require.NoError(t, err)
+	_, err = uc.Register(ctx, entities.User{Username: "alice", Email: "alice@example.com", Password: "password"})
+	require.Error(t, err)
+	_, err = uc.Login(ctx, entities.UserLogin{Email: "alice@example.com", Password: "password"})
+	require.NoError(t, err)
+	_, err = uc.Login(ctx, entities.UserLogin{Email: "alice@example.com", Password: "wrong password"})
+	require.Error(t, err)
+	_, err = uc.Login(ctx, entities.UserLogin{Email: "nobody@example.com", Password: "password"})
+	require.Error(t, err)
+
+	require.Len(t, recorder.Events(), 4, "A failed registration publishes no event")
+	registrations := eventbustest.Events[entities.UserRegistered](recorder)
+	require.Len(t, registrations, 1)
+	assert.Equal(t, registered.ID, registrations[0].UserID)
+	assert.Equal(t, "alice", registrations[0].Username)
+
+	logins := eventbustest.Events[entities.UserLoggedIn](recorder)
+	require.Len(t, logins, 1)
+	assert.Equal(t, registered.ID, logins[0].UserID)
+	assert.Equal(t, "10.0.0.1", logins[0].IP)
+
+	failures := eventbustest.Events[entities.LoginFailed](recorder)
+	require.Len(t, failures, 2)
+	assert.Equal(t, registered.ID, failures[0].UserID, "The user is known when the password is wrong")
+	assert.Equal(t, auth.ErrInvalidCredentials.Error(), failures[0].Reason)
+	assert.Equal(t, "nobody@example.com", failures[1].Email)
+}
+
+func TestConcurrentRegistrations(t *testing.T) {
+	for name, dbCfg := range databases {
+		t.Run(name, func(t *testing.T) {
+			uc, users, events := newTestUC(t, dbCfg(t))
+			ctx := context.Background()
+
+			var wg sync.WaitGroup
+			results := make(chan error, concurrentRegistrations)
+			for i := 0; i < concurrentRegistrations; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					// Every registration claims the same email, and half of them the same username as well.
+					_, err := uc.Register(ctx, entities.User{Username: fmt.Sprintf("alice%d", i%2), Email: "alice@example.com", Password: "password"})
+					results <- err
+				}(i)
+			}
+			wg.Wait()
+			close(results)
+
+			succeeded := 0
+			for err := range results {
+				if err == nil {
+					succeeded++
+				}
+			}
+			assert.Equal(t, 1, succeeded, "Exactly one registration must win the race")
+
+			var all []entities.User
+			all, err := users.ReadAll(ctx, all)
+			require.NoError(t, err)
+			assert.Len(t, all, 1)
+
+			recorded, err := events.Find(ctx, entities.AuditFilter{Action: entities.AuditActionRegister, Outcome: entities.AuditOutcomeSuccess})
+			require.NoError(t, err)
+			assert.Len(t, recorded, 1, "Only the winning registration is audited as a success")
+		})
+	}
+}
+
+// racingAuthenticator modifies the user in the storage once the credentials are checked,
+// as an admin change that lands between the read and the write of a login would.
+type racingAuthenticator struct {
+	auth.Authenticator
+	users  storage.UserRepository
+	change func(user *entities.User)
+}
+
+func (a racingAuthenticator) Authenticate(ctx context.Context, credentials entities.UserLogin) (entities.User, error) {
+	user, err := a.Authenticator.Authenticate(ctx, credentials)
+	if err != nil {
+		return user, err
+	}
+	stored, err := a.users.Read(ctx, user.ID)
+	if err != nil {
+		return user, err
+	}
+	a.change(&stored)
+	return user, a.users.Update(ctx, stored)
+}
+
+func TestLoginKeepsConcurrentChanges(t *testing.T) {
+	for name, dbCfg := range databases {
+		t.Run(name, func(t *testing.T) {
+			uc, users, _ := newTestUC(t, dbCfg(t))
+			ctx := context.Background()
+			registered, err := uc.Register(ctx, entities.User{Username: "alice", Email: "alice@example.com", Password: "password"})
+			require.NoError(t, err)
+
+			cfg := &config.Config{}
+			racing := racingAuthenticator{Authenticator: authenticator.NewLocal(users), users: users, change: func(user *entities.User) {
+				user.ExternalID = "changed-by-admin"
+			}}
+			uc = NewAuthUC(cfg, users, nil, racing, nopRecorder{}, nil)
+			token, err := uc.Login(ctx, entities.UserLogin{Email: "alice@example.com", Password: "password"})
+			require.NoError(t, err)
+			stored, err := users.Read(ctx, registered.ID)
+			require.NoError(t, err)
+			assert.Equal(t, "changed-by-admin", stored.ExternalID, "The login must not overwrite the change")
+			assert.Equal(t, token, stored.Token)
+
+			racing.change = func(user *entities.User) {
+				user.Deactivated = true
+			}
+			uc = NewAuthUC(cfg, users, nil, racing, nopRecorder{}, nil)
+			_, err = uc.Login(ctx, entities.UserLogin{Email: "alice@example.com", Password: "password"})
+			assert.ErrorIs(t, err, auth.ErrDeactivated, "A user deactivated during the login gets no token")
+		})
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	for name, dbCfg := range databases {
+		t.Run(name, func(t *testing.T) {
+			uc, _, _ := newTestUC(t, dbCfg(t))
+			ctx := context.Background()
+			alice, err := uc.Register(ctx, entities.User{Username: "alice", Email: "alice@example.com", Password: "password"})
+			require.NoError(t, err)
+			_, err = uc.Register(ctx, entities.User{Username: "bob", Email: "bob@example.com", Password: "password"})
+			require.NoError(t, err)
+
+			read, err := uc.GetUser(ctx, alice.ID)
+			require.NoError(t, err)
+			updated, err := uc.UpdateUser(ctx, alice.ID, entities.UserUpdate{Username: "alicia", Email: "alicia@example.com"}, read.Metadata.Version)
+			require.NoError(t, err)
+			assert.Equal(t, "alicia", updated.Username)
+			assert.Greater(t, updated.Metadata.Version, read.Metadata.Version, "The update returns the new version")
+
+			_, err = uc.UpdateUser(ctx, alice.ID, entities.UserUpdate{Username: "alison", Email: "alison@example.com"}, read.Metadata.Version)
+			assert.ErrorIs(t, err, database.ErrStale, "An update based on an outdated version is rejected")
+			assert.NotErrorIs(t, err, auth.ErrAccountExists)
+			current, err := uc.GetUser(ctx, alice.ID)
+			require.NoError(t, err)
+			assert.Equal(t, "alicia", current.Username, "The rejected update changes nothing")
+
+			_, err = uc.UpdateUser(ctx, alice.ID, entities.UserUpdate{Username: "bob", Email: "alicia@example.com"}, 0)
+			assert.ErrorIs(t, err, auth.ErrAccountExists, "Usernames are unique")
+			_, err = uc.UpdateUser(ctx, alice.ID, entities.UserUpdate{Username: "alicia", Email: "not an email"}, 0)
+			assert.ErrorIs(t, err, auth.ErrInvalidUser)
+			_, err = uc.UpdateUser(ctx, uuid.New(), entities.UserUpdate{Username: "carol", Email: "carol@example.com"}, 0)
+			assert.ErrorIs(t, err, database.ErrNotFound)
+
+			_, err = uc.Login(ctx, entities.UserLogin{Email: "alicia@example.com", Password: "password"})
+			assert.NoError(t, err, "The update keeps the password")
+		})
+	}
+}
+
+// refilledCache struct represents a cache shared with other instances, which fill it again with the version they read
+// as soon as an entry is removed.
+type refilledCache struct {
+	cache.Cache
+}
+
+func (refilledCache) Delete(ctx context.Context, keys ...string) error {
+	return nil
+}
+
+func TestLoginRetriesPastTheCache(t *testing.T) {
+	uc, users, _ := newTestUC(t, config.DatabaseConfig{DatabaseType: "memory"})
+	ctx := context.Background()
+	registered, err := uc.Register(ctx, entities.User{Username: "alice", Email: "alice@example.com", Password: "password"})
+	require.NoError(t, err)
+
+	cached := user.NewCachedRepository(users, refilledCache{Cache: cache.NewLRU(10)}, nil, time.Minute, 0)
+	_, err = cached.Read(ctx, registered.ID)
+	require.NoError(t, err)
+	racing := racingAuthenticator{Authenticator: authenticator.NewLocal(cached), users: users, change: func(user *entities.User) {
+		user.ExternalID = "changed-by-admin"
+	}}
+	uc = NewAuthUC(&config.Config{}, cached, nil, racing, nopRecorder{}, nil)
+	token, err := uc.Login(ctx, entities.UserLogin{Email: "alice@example.com", Password: "password"})
+	require.NoError(t, err, "The retry must not read the outdated user from the cache")
+	stored, err := users.Read(ctx, registered.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "changed-by-admin", stored.ExternalID)
+	assert.Equal(t, token, stored.Token)
+}
+
+// nopRecorder discards the audit events.
+type nopRecorder struct{}
+
+func (nopRecorder) Record(ctx context.Context, event entities.AuditEvent) error {
+	return nil
+}
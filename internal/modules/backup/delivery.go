@@ -0,0 +1,11 @@
+// Package backup provides the functionality to back up the database of the application while it is in use.
+package backup
+
+import "github.com/labstack/echo/v4"
+
+// Handlers is an interface that defines the methods required for handling backup operations.
+type Handlers interface {
+	// Download handles the download of a backup of the database.
+	// Returns an echo.HandlerFunc that handles the HTTP request for downloading a backup.
+	Download() echo.HandlerFunc
+}
@@ -0,0 +1,55 @@
+// Package http provides the functionality to handle HTTP requests for the backup module.
+package http
+
+import (
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"                                     // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/backup" // Backup package provides the functionality to interact with the backup module.
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"            // Database package provides the error returned when the database cannot be backed up.
+	"log"
+	"net/http"
+	"time"
+)
+
+// BackupHandlers struct represents backup handlers that provide methods for handling HTTP requests for the backup module.
+type BackupHandlers struct {
+	backupUC backup.UseCase // The backup use case for the backup handlers.
+}
+
+// NewBackupHandlers creates new backup handlers with the provided backup use case.
+// backupUC: The backup use case for the backup handlers.
+// Returns a BackupHandlers object.
+func NewBackupHandlers(backupUC backup.UseCase) *BackupHandlers {
+	return &BackupHandlers{backupUC: backupUC}
+}
+
+// Download streams a consistent copy of the database while the application keeps serving requests.
+// @route GET /admin/backup
+// @group Backup
+// @returns {file} 200 - The copy of the database file
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 500 - Server error
+// @returns {object} 501 - The configured database does not support online backups.
+func (h *BackupHandlers) Download() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		res := c.Response()
+		res.Header().Set(echo.HeaderContentType, echo.MIMEOctetStream)
+		res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", "backup-"+time.Now().UTC().Format("20060102T150405Z")+".db"))
+
+		written, err := h.backupUC.Backup(c.Request().Context(), res)
+		if errors.Is(err, database.ErrBackupNotSupported) {
+			res.Header().Del(echo.HeaderContentDisposition)
+			return echo.NewHTTPError(http.StatusNotImplemented, err.Error())
+		}
+		if err != nil {
+			if res.Committed {
+				// The status is already sent, so the client only notices the truncated body.
+				log.Printf("Failed to write backup after %d bytes: %v\n", written, err)
+				return nil
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("failed to back up database: %v", err))
+		}
+		return nil
+	}
+}
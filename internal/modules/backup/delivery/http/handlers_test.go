@@ -0,0 +1,61 @@
+package http
+
+import (
+	"context"
+	"github.com/labstack/echo/v4"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/backup/usecase"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/bolt"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/memory"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(db database.Database) *httptest.ResponseRecorder {
+	e := echo.New()
+	MapBackupRoutes(e.Group("/admin/backup"), NewBackupHandlers(usecase.NewBackupUC(db)))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/backup", nil))
+	return rec
+}
+
+func TestDownloadNotSupported(t *testing.T) {
+	rec := serve(memory.NewDatabase())
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	assert.Empty(t, rec.Header().Get(echo.HeaderContentDisposition))
+}
+
+func TestDownload(t *testing.T) {
+	dir := t.TempDir()
+	db, err := bolt.NewDatabase(&config.Config{DB: config.DatabaseConfig{Bolt: config.BoltConfig{Path: filepath.Join(dir, "test.bolt"), TimeoutSeconds: 1}}})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	user := entities.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com"}
+	require.NoError(t, db.Create(context.Background(), &user))
+
+	// The backup is taken of the database behind the tenant decorator.
+	rec := serve(database.NewTenantDatabase(db, "organization_id"))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, echo.MIMEOctetStream, rec.Header().Get(echo.HeaderContentType))
+	assert.True(t, strings.HasPrefix(rec.Header().Get(echo.HeaderContentDisposition), `attachment; filename="backup-`))
+
+	path := filepath.Join(dir, "backup.bolt")
+	require.NoError(t, os.WriteFile(path, rec.Body.Bytes(), 0o600))
+	restored, err := bolt.NewDatabase(&config.Config{DB: config.DatabaseConfig{Bolt: config.BoltConfig{Path: path, TimeoutSeconds: 1}}})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = restored.Close() })
+	var read entities.User
+	require.NoError(t, restored.Read(context.Background(), &read, query.Eq("username", "alice")))
+	assert.Equal(t, user.ID, read.ID)
+}
@@ -0,0 +1,21 @@
+// Package http provides the functionality to map the routes of the backup module over HTTP.
+package http
+
+import (
+	"github.com/labstack/echo/v4"                                     // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/backup" // Backup package provides the functionality to interact with the backup module.
+)
+
+// MapBackupRoutes maps the backup routes to the provided Echo group with the provided backup handlers.
+// backupGroup: The Echo group to map the routes to. It is expected to be protected by the admin authentication.
+// h: The backup handlers to use for the routes.
+// The routes include:
+// GET /: Downloads a consistent copy of the database.
+func MapBackupRoutes(backupGroup *echo.Group, h backup.Handlers) {
+	// @route GET /admin/backup
+	// @group Backup
+	// @returns {file} 200 - The copy of the database file
+	// @returns {object} 401 - Unauthorized access
+	// @returns {object} 501 - The configured database does not support online backups.
+	backupGroup.GET("", h.Download())
+}
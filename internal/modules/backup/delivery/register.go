@@ -0,0 +1,33 @@
+// Package delivery provides the functionality to deliver the responses of the backup module.
+package delivery
+
+import (
+	"github.com/labstack/echo/v4"                                                   // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/config"                                // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/app"                          // App package provides the functionality to create and manage the server of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/backup"               // Backup package provides the functionality to interact with the backup module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/backup/delivery/http" // HTTP package provides the functionality to deliver the responses of the backup module over HTTP.
+)
+
+// BackupDelivery struct represents a backup delivery that provides methods for delivering the responses of the backup module.
+// It includes a BackupHandlers object for handling the responses and a function for setting up the routes.
+type BackupDelivery struct {
+	Handlers        *http.BackupHandlers  // The handlers for the backup responses.
+	SetupRoutesFunc func(echo *echo.Echo) // The function for setting up the routes.
+}
+
+// NewBackupDelivery creates a new backup delivery with the provided configuration and backup use case.
+// cfg: The configuration for the backup delivery.
+// uc: The backup use case for the backup delivery.
+// Returns a BackupDelivery object.
+func NewBackupDelivery(cfg *config.Config, uc backup.UseCase) *BackupDelivery {
+	handlers := http.NewBackupHandlers(uc) // Creates new backup handlers with the provided backup use case.
+
+	// Returns a new BackupDelivery object with the created handlers and a function for setting up the routes.
+	return &BackupDelivery{
+		Handlers: handlers,
+		SetupRoutesFunc: func(e *echo.Echo) {
+			http.MapBackupRoutes(e.Group("/admin/backup", app.AdminAuth(cfg)), handlers) // Maps the backup routes to the admin-protected "/admin/backup" group.
+		},
+	}
+}
@@ -0,0 +1,30 @@
+// Package module provides the functionality to interact with the backup module.
+package module
+
+import (
+	"github.com/labstack/echo/v4"                                                   // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/config"                                // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/app"                          // App package provides the functionality to create and manage the server of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/backup/delivery"      // Delivery package provides the functionality to deliver the responses of the backup module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/backup/delivery/http" // HTTP package provides the functionality to deliver the responses of the backup module over HTTP.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/backup/usecase"       // Usecase package provides the functionality to interact with the use cases of the backup module.
+	"go.uber.org/fx"                                                                // Fx is a framework for Go that provides the building blocks for your service architectures.
+)
+
+// Module is a Fx options group that provides and invokes the necessary dependencies for the backup module.
+var Module = fx.Options(
+	fx.Provide(
+		usecase.NewBackupUC,        // Provides a new backup use case.
+		http.NewBackupHandlers,     // Provides new backup handlers.
+		delivery.NewBackupDelivery, // Provides a new backup delivery.
+	),
+	fx.Invoke(registerBackupRoutes), // Invokes the function to register the backup routes.
+)
+
+// registerBackupRoutes registers the backup routes with the provided Echo instance and backup handlers.
+// e: The Echo instance to register the routes with.
+// cfg: The configuration that contains the admin API key.
+// handlers: The backup handlers to use for the routes.
+func registerBackupRoutes(e *echo.Echo, cfg *config.Config, handlers *http.BackupHandlers) {
+	http.MapBackupRoutes(e.Group("/admin/backup", app.AdminAuth(cfg)), handlers) // Maps the backup routes to the admin-protected "/admin/backup" group.
+}
@@ -0,0 +1,17 @@
+// Package backup provides the functionality to back up the database of the application while it is in use.
+package backup
+
+import (
+	"context"
+	"io"
+)
+
+// UseCase is an interface that defines the methods required for backup operations.
+type UseCase interface {
+	// Backup writes a consistent copy of the database.
+	// ctx: The context for the operation.
+	// w: The writer to write the copy to.
+	// Returns the number of written bytes, and database.ErrBackupNotSupported before writing anything
+	// if the configured database does not support online backups.
+	Backup(ctx context.Context, w io.Writer) (int64, error)
+}
@@ -0,0 +1,29 @@
+// Package usecase provides the functionality to back up the database of the application.
+package usecase
+
+import (
+	"context"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/backup"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"io"
+)
+
+// BackupUseCase struct represents a backup use case that provides methods for backup operations.
+type BackupUseCase struct {
+	db database.Database
+}
+
+// NewBackupUC creates a new backup use case with the provided database.
+// db: The database to back up. It may be decorated, the backup is taken of the decorated database.
+// Returns a backup.UseCase object.
+func NewBackupUC(db database.Database) backup.UseCase {
+	return &BackupUseCase{db: db}
+}
+
+// Backup writes a consistent copy of the database.
+// ctx: The context for the operation.
+// w: The writer to write the copy to.
+// Returns the number of written bytes, and database.ErrBackupNotSupported if the database does not support online backups.
+func (uc *BackupUseCase) Backup(ctx context.Context, w io.Writer) (int64, error) {
+	return database.Backup(ctx, uc.db, w)
+}
@@ -0,0 +1,23 @@
+// Package invitation provides the functionality to invite people to create an account and join an organization.
+package invitation
+
+import "context"
+
+// inviterKey is the context key under which the inviter is stored.
+type inviterKey struct{}
+
+// WithInviter returns a copy of the context that carries the inviter.
+// ctx: The parent context.
+// inviter: The inviter to store.
+// Returns the new context.
+func WithInviter(ctx context.Context, inviter Inviter) context.Context {
+	return context.WithValue(ctx, inviterKey{}, inviter)
+}
+
+// InviterFromContext retrieves the inviter from the context.
+// ctx: The context to read from.
+// Returns the inviter and a boolean indicating if the context carries one.
+func InviterFromContext(ctx context.Context) (Inviter, bool) {
+	inviter, ok := ctx.Value(inviterKey{}).(Inviter)
+	return inviter, ok
+}
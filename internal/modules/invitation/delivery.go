@@ -0,0 +1,27 @@
+// Package invitation provides the functionality to invite people to create an account and join an organization.
+package invitation
+
+import "github.com/labstack/echo/v4"
+
+// Handlers is an interface that defines the methods required for handling invitation operations.
+type Handlers interface {
+	// Create handles the creation of an invitation.
+	// Returns an echo.HandlerFunc that handles the HTTP request for creating an invitation.
+	Create() echo.HandlerFunc
+
+	// List handles the retrieval of the invitations.
+	// Returns an echo.HandlerFunc that handles the HTTP request for listing the invitations.
+	List() echo.HandlerFunc
+
+	// Resend handles resending an invitation.
+	// Returns an echo.HandlerFunc that handles the HTTP request for resending an invitation.
+	Resend() echo.HandlerFunc
+
+	// Revoke handles revoking an invitation.
+	// Returns an echo.HandlerFunc that handles the HTTP request for revoking an invitation.
+	Revoke() echo.HandlerFunc
+
+	// Accept handles accepting an invitation.
+	// Returns an echo.HandlerFunc that handles the HTTP request for accepting an invitation.
+	Accept() echo.HandlerFunc
+}
@@ -0,0 +1,188 @@
+// Package http provides the functionality to handle HTTP requests for the invitation module.
+package http
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"                                         // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/config"                      // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/app"                // App package provides the functionality to map the errors of the storage layer to HTTP status codes.
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"           // Entities package provides the functionality to interact with the entities of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth"       // Auth package provides the functionality to interact with the auth module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/invitation" // Invitation package provides the functionality to interact with the invitation module.
+	"net/http"
+)
+
+// InvitationHandlers struct represents invitation handlers that provide methods for handling HTTP requests for the invitation module.
+type InvitationHandlers struct {
+	cfg          *config.Config     // The configuration for the invitation handlers.
+	invitationUC invitation.UseCase // The invitation use case for the invitation handlers.
+}
+
+// invitationResponse struct represents an invitation together with its invite link.
+type invitationResponse struct {
+	entities.Invitation
+	Link string `json:"link"`
+}
+
+// NewInvitationHandlers creates new invitation handlers with the provided configuration and invitation use case.
+// cfg: The configuration for the invitation handlers.
+// invitationUC: The invitation use case for the invitation handlers.
+// Returns an InvitationHandlers object.
+func NewInvitationHandlers(cfg *config.Config, invitationUC invitation.UseCase) *InvitationHandlers {
+	return &InvitationHandlers{
+		cfg:          cfg,
+		invitationUC: invitationUC,
+	}
+}
+
+// Create creates an invitation and sends its link to the invitee.
+// @route POST /org/invitations
+// @route POST /admin/invitations
+// @group Invitations
+// @param {Invitation.model} invitation.body.required - Email, and optionally organization and role, of the invitee
+// @returns {object} 201 - The invitation and its invite link
+// @returns {object} 400 - The request could not be understood or was missing required parameters.
+// @returns {object} 403 - The inviter is not allowed to create the invitation.
+func (h *InvitationHandlers) Create() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		inviter, _ := invitation.InviterFromContext(c.Request().Context())
+
+		var inv entities.Invitation
+		if err := c.Bind(&inv); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "failed to bind invitation")
+		}
+
+		inv, link, err := h.invitationUC.Create(c.Request().Context(), inviter, inv)
+		if err != nil {
+			return statusError(err, "failed to create invitation")
+		}
+		return c.JSON(http.StatusCreated, invitationResponse{Invitation: inv, Link: link})
+	}
+}
+
+// List retrieves the invitations.
+// @route GET /org/invitations
+// @route GET /admin/invitations
+// @group Invitations
+// @returns {Array} 200 - An array of invitations, most recent first
+// @returns {object} 403 - The inviter is not allowed to list the invitations.
+func (h *InvitationHandlers) List() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		inviter, _ := invitation.InviterFromContext(c.Request().Context())
+
+		invitations, err := h.invitationUC.List(c.Request().Context(), inviter)
+		if err != nil {
+			return statusError(err, "failed to list invitations")
+		}
+		return c.JSON(http.StatusOK, invitations)
+	}
+}
+
+// Resend issues a new invite link and sends it again.
+// @route POST /org/invitations/:id/resend
+// @route POST /admin/invitations/:id/resend
+// @group Invitations
+// @returns {object} 200 - The invitation and its new invite link
+// @returns {object} 403 - The inviter is not allowed to resend the invitation.
+// @returns {object} 404 - There is no invitation with the given ID.
+// @returns {object} 410 - The invitation has already been accepted or revoked.
+func (h *InvitationHandlers) Resend() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		inviter, _ := invitation.InviterFromContext(c.Request().Context())
+
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid invitation id")
+		}
+
+		inv, link, err := h.invitationUC.Resend(c.Request().Context(), inviter, id)
+		if err != nil {
+			return statusError(err, "failed to resend invitation")
+		}
+		return c.JSON(http.StatusOK, invitationResponse{Invitation: inv, Link: link})
+	}
+}
+
+// Revoke revokes a pending invitation.
+// @route DELETE /org/invitations/:id
+// @route DELETE /admin/invitations/:id
+// @group Invitations
+// @returns {object} 200 - The revoked invitation
+// @returns {object} 403 - The inviter is not allowed to revoke the invitation.
+// @returns {object} 404 - There is no invitation with the given ID.
+// @returns {object} 410 - The invitation is no longer pending.
+func (h *InvitationHandlers) Revoke() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		inviter, _ := invitation.InviterFromContext(c.Request().Context())
+
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid invitation id")
+		}
+
+		inv, err := h.invitationUC.Revoke(c.Request().Context(), inviter, id)
+		if err != nil {
+			return statusError(err, "failed to revoke invitation")
+		}
+		return c.JSON(http.StatusOK, inv)
+	}
+}
+
+// Accept accepts an invitation.
+// Authenticated users join the organization of the invitation. Anonymous users create their account with the
+// username and password in the body and the email of the invitation.
+// @route POST /invitations/:token/accept
+// @group Invitations
+// @param {User.model} user.body - Username and password of the new account, for anonymous users
+// @returns {object} 200 - The accepted invitation, for authenticated users
+// @returns {object} 201 - The created account, for anonymous users
+// @returns {object} 400 - The request could not be understood or the account could not be created.
+// @returns {object} 404 - There is no invitation with the given token.
+// @returns {object} 409 - The user is already a member, or an account with the given username already exists.
+// @returns {object} 410 - The invitation has already been accepted, revoked or has expired.
+func (h *InvitationHandlers) Accept() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		token := c.Param("token")
+
+		if user, ok := auth.UserFromContext(c.Request().Context()); ok {
+			inv, err := h.invitationUC.Accept(c.Request().Context(), user, token)
+			if err != nil {
+				return statusError(err, "failed to accept invitation")
+			}
+			return c.JSON(http.StatusOK, inv)
+		}
+
+		var registration entities.User
+		if err := c.Bind(&registration); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "failed to bind user")
+		}
+		user, err := h.invitationUC.AcceptWithRegistration(c.Request().Context(), token, registration)
+		if err != nil {
+			return statusError(err, "failed to accept invitation")
+		}
+		user.Password = ""
+		return c.JSON(http.StatusCreated, user)
+	}
+}
+
+// statusError converts an error of the invitation use case into an HTTP error.
+// err: The error to convert.
+// message: The message describing the failed operation.
+// Returns an *echo.HTTPError with 403 for permission errors, 404 for unknown invitations, 409 for existing members and accounts,
+// 410 for invitations that are no longer pending, the status code of the storage errors and 400 otherwise.
+func statusError(err error, message string) error {
+	status := app.StatusCode(err, http.StatusBadRequest)
+	switch {
+	case errors.Is(err, invitation.ErrForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, invitation.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, invitation.ErrAlreadyMember), errors.Is(err, auth.ErrAccountExists):
+		status = http.StatusConflict
+	case errors.Is(err, invitation.ErrNotPending):
+		status = http.StatusGone
+	}
+	return echo.NewHTTPError(status, fmt.Sprintf("%s: %v", message, err))
+}
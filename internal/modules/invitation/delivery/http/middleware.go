@@ -0,0 +1,39 @@
+// Package http provides the functionality to handle HTTP requests for the invitation module.
+package http
+
+import (
+	"github.com/labstack/echo/v4"                                           // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/invitation"   // Invitation package provides the functionality to interact with the invitation module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/organization" // Organization package provides the functionality to interact with the organization module.
+	"net/http"
+)
+
+// AdminInviter creates a middleware that acts on the invitations as a global admin.
+// The middleware must run after the admin authentication.
+// Returns an echo.MiddlewareFunc.
+func AdminInviter() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := invitation.WithInviter(c.Request().Context(), invitation.Inviter{Admin: true})
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// MemberInviter creates a middleware that acts on the invitations as the member of the current organization.
+// The middleware must run after the tenant resolution.
+// Returns an echo.MiddlewareFunc.
+func MemberInviter() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			membership, ok := organization.MembershipFromContext(c.Request().Context())
+			if !ok {
+				return echo.NewHTTPError(http.StatusForbidden, invitation.ErrForbidden.Error())
+			}
+			ctx := invitation.WithInviter(c.Request().Context(), invitation.Inviter{Membership: membership})
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
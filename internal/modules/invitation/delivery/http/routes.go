@@ -0,0 +1,31 @@
+// Package http provides the functionality to map the routes of the invitation module over HTTP.
+package http
+
+import (
+	"github.com/labstack/echo/v4"                                         // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/invitation" // Invitation package provides the functionality to interact with the invitation module.
+)
+
+// MapManagementRoutes maps the routes that manage invitations to the provided Echo group.
+// managementGroup: The Echo group to map the routes to. It is expected to store the inviter in the request context.
+// h: The invitation handlers to use for the routes.
+// The routes include:
+// POST /: Creates an invitation and sends its link.
+// GET /: Retrieves the invitations.
+// POST /:id/resend: Issues a new invite link and sends it again.
+// DELETE /:id: Revokes a pending invitation.
+func MapManagementRoutes(managementGroup *echo.Group, h invitation.Handlers) {
+	managementGroup.POST("", h.Create())
+	managementGroup.GET("", h.List())
+	managementGroup.POST("/:id/resend", h.Resend())
+	managementGroup.DELETE("/:id", h.Revoke())
+}
+
+// MapAcceptRoutes maps the routes that accept invitations to the provided Echo group.
+// acceptGroup: The Echo group to map the routes to. It is expected to authenticate the requests that carry a bearer token.
+// h: The invitation handlers to use for the routes.
+// The routes include:
+// POST /:token/accept: Accepts an invitation, creating the account of anonymous invitees.
+func MapAcceptRoutes(acceptGroup *echo.Group, h invitation.Handlers) {
+	acceptGroup.POST("/:token/accept", h.Accept())
+}
@@ -0,0 +1,62 @@
+// Package delivery provides the functionality to deliver the responses of the invitation module.
+package delivery
+
+import (
+	"github.com/labstack/echo/v4"                                                                 // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/config"                                              // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/app"                                        // App package provides the admin authentication middleware.
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"                                   // Entities package provides the functionality to interact with the entities of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth"                               // Auth package provides the functionality to interact with the auth module.
+	authhttp "github.com/nikita-voronoy/go-clean-arch/internal/modules/auth/delivery/http"        // Auth HTTP package provides the authentication middleware.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/invitation"                         // Invitation package provides the functionality to interact with the invitation module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/invitation/delivery/http"           // HTTP package provides the functionality to deliver the responses of the invitation module over HTTP.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/organization"                       // Organization package provides the functionality to interact with the organization module.
+	orghttp "github.com/nikita-voronoy/go-clean-arch/internal/modules/organization/delivery/http" // Organization HTTP package provides the tenant resolution middleware.
+)
+
+// InvitationDelivery struct represents an invitation delivery that provides methods for delivering the responses of the invitation module.
+// It includes an InvitationHandlers object for handling the responses and a function for setting up the routes.
+type InvitationDelivery struct {
+	Handlers        *http.InvitationHandlers // The handlers for the invitation responses.
+	SetupRoutesFunc func(echo *echo.Echo)    // The function for setting up the routes.
+}
+
+// NewInvitationDelivery creates a new invitation delivery with the provided configuration and use cases.
+// cfg: The configuration for the invitation delivery.
+// uc: The invitation use case for the invitation delivery.
+// authUC: The auth use case used to authenticate the requests.
+// orgUC: The organization use case used to resolve the tenant.
+// Returns an InvitationDelivery object.
+func NewInvitationDelivery(cfg *config.Config, uc invitation.UseCase, authUC auth.UseCase, orgUC organization.UseCase) *InvitationDelivery {
+	handlers := http.NewInvitationHandlers(cfg, uc) // Creates new invitation handlers with the provided configuration and invitation use case.
+
+	// Returns a new InvitationDelivery object with the created handlers and a function for setting up the routes.
+	return &InvitationDelivery{
+		Handlers: handlers,
+		SetupRoutesFunc: func(e *echo.Echo) {
+			MapRoutes(e, cfg, handlers, authUC, orgUC)
+		},
+	}
+}
+
+// MapRoutes maps the invitation routes to the provided Echo instance.
+// e: The Echo instance to map the routes to.
+// cfg: The configuration that contains the admin and tenancy settings.
+// handlers: The invitation handlers to use for the routes.
+// authUC: The auth use case used to authenticate the requests.
+// orgUC: The organization use case used to resolve the tenant.
+func MapRoutes(e *echo.Echo, cfg *config.Config, handlers *http.InvitationHandlers, authUC auth.UseCase, orgUC organization.UseCase) {
+	// Maps the organization invitation routes to the "/org/invitations" group, for the admins and owners of the current organization.
+	http.MapManagementRoutes(e.Group("/org/invitations",
+		authhttp.Authenticate(authUC),
+		orghttp.ResolveTenant(cfg, orgUC),
+		orghttp.RequireRole(entities.RoleAdmin),
+		http.MemberInviter(),
+	), handlers)
+
+	// Maps the admin invitation routes to the admin-protected "/admin/invitations" group.
+	http.MapManagementRoutes(e.Group("/admin/invitations", app.AdminAuth(cfg), http.AdminInviter()), handlers)
+
+	// Maps the accept route to the "/invitations" group, which is open to anonymous invitees.
+	http.MapAcceptRoutes(e.Group("/invitations", authhttp.OptionalAuthenticate(authUC)), handlers)
+}
@@ -0,0 +1,15 @@
+// Package migrations provides the SQL migrations of the schema of the invitation module.
+package migrations
+
+import (
+	"embed"                                               // Embed package provides the functionality to embed the migration files in the binary.
+	"github.com/nikita-voronoy/go-clean-arch/pkg/migrate" // Migrate package provides the functionality to apply the versioned SQL migrations.
+)
+
+// files holds the migration files, in a directory per dialect.
+//
+//go:embed sqlite postgres mysql
+var files embed.FS
+
+// Source is the source of the migrations of the invitations.
+var Source = migrate.Source{Module: "invitation", FS: files}
@@ -0,0 +1,18 @@
+-- The invitations table, as it was created before the migrations were versioned, so that an existing database is adopted.
+CREATE TABLE IF NOT EXISTS invitations (
+    id {{.UUID}} NOT NULL,
+    organization_id {{.UUID}},
+    email longtext NOT NULL,
+    role longtext,
+    token varchar(191) NOT NULL,
+    invited_by {{.UUID}},
+    expires_at datetime(6) NOT NULL,
+    accepted_at datetime(6) NULL DEFAULT NULL,
+    revoked_at datetime(6) NULL DEFAULT NULL,
+    created_at datetime(6) NULL,
+    updated_at datetime(6) NULL,
+    last_login_at datetime(6) NULL DEFAULT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY token (token),
+    KEY idx_invitations_organization_id (organization_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
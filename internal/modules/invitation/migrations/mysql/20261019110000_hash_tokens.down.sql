@@ -0,0 +1,3 @@
+-- The hashes cannot be turned back into tokens, so the outstanding invitations expire so that they can be resent.
+ALTER TABLE invitations RENAME COLUMN token_hash TO token;
+UPDATE invitations SET expires_at = CURRENT_TIMESTAMP(6) WHERE accepted_at IS NULL AND revoked_at IS NULL;
@@ -0,0 +1,17 @@
+-- The invitations table, as it was created before the migrations were versioned, so that an existing database is adopted.
+CREATE TABLE IF NOT EXISTS invitations (
+    id uuid NOT NULL,
+    organization_id uuid,
+    email text NOT NULL,
+    role text,
+    token text NOT NULL UNIQUE,
+    invited_by uuid,
+    expires_at timestamptz NOT NULL,
+    accepted_at timestamptz DEFAULT NULL,
+    revoked_at timestamptz DEFAULT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    last_login_at timestamptz DEFAULT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_invitations_organization_id ON invitations (organization_id);
@@ -0,0 +1,3 @@
+-- The invitations store the SHA-256 hashes of their tokens, hex-encoded, so that the table holds no working invite link.
+ALTER TABLE invitations RENAME COLUMN token TO token_hash;
+UPDATE invitations SET token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
@@ -0,0 +1,6 @@
+-- The invitations store the SHA-256 hashes of their tokens, hex-encoded, so that the table holds no working invite link.
+-- SQLite has no SHA-256 function to hash the existing tokens, so they are replaced by values that match no hash,
+-- and the outstanding invitations expire so that they can be resent.
+ALTER TABLE invitations RENAME COLUMN token TO token_hash;
+UPDATE invitations SET token_hash = 'unhashed:' || id;
+UPDATE invitations SET expires_at = CURRENT_TIMESTAMP WHERE accepted_at IS NULL AND revoked_at IS NULL;
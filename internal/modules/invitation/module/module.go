@@ -0,0 +1,41 @@
+// Package module provides the functionality to interact with the invitation module.
+package module
+
+import (
+	"github.com/labstack/echo/v4"                                                       // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/config"                                    // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth"                     // Auth package provides the functionality to interact with the auth module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/invitation/delivery"      // Delivery package provides the functionality to deliver the responses of the invitation module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/invitation/delivery/http" // HTTP package provides the functionality to deliver the responses of the invitation module over HTTP.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/invitation/migrations"    // Migrations package provides the SQL migrations of the schema of the invitation module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/invitation/usecase"       // Usecase package provides the functionality to interact with the use cases of the invitation module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/organization"             // Organization package provides the functionality to interact with the organization module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/invitation"               // Invitation package provides the functionality to interact with the invitation storage.
+	"go.uber.org/fx"                                                                    // Fx is a framework for Go that provides the building blocks for your service architectures.
+)
+
+// Migrations is a Fx option that registers the SQL migrations of the invitation module with the migrations group.
+var Migrations = fx.Supply(fx.Annotated{Group: "migrations", Target: migrations.Source})
+
+// Module is a Fx options group that provides and invokes the necessary dependencies for the invitation module.
+var Module = fx.Options(
+	Migrations, // Registers the SQL migrations of the invitation module.
+	fx.Provide(
+		invitation.NewInvitationRepository, // Provides a new invitation repository.
+		usecase.NewLogSender,               // Provides the sender that writes the invite links to the log.
+		usecase.NewInvitationUC,            // Provides a new invitation use case.
+		http.NewInvitationHandlers,         // Provides new invitation handlers.
+		delivery.NewInvitationDelivery,     // Provides a new invitation delivery.
+	),
+	fx.Invoke(registerInvitationRoutes), // Invokes the function to register the invitation routes.
+)
+
+// registerInvitationRoutes registers the invitation routes with the provided Echo instance and invitation handlers.
+// e: The Echo instance to register the routes with.
+// cfg: The configuration that contains the admin and tenancy settings.
+// handlers: The invitation handlers to use for the routes.
+// authUC: The auth use case used to authenticate the requests.
+// orgUC: The organization use case used to resolve the tenant.
+func registerInvitationRoutes(e *echo.Echo, cfg *config.Config, handlers *http.InvitationHandlers, authUC auth.UseCase, orgUC organization.UseCase) {
+	delivery.MapRoutes(e, cfg, handlers, authUC, orgUC) // Maps the invitation routes to the Echo instance.
+}
@@ -0,0 +1,85 @@
+// Package invitation provides the functionality to invite people to create an account and join an organization.
+package invitation
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+)
+
+var (
+	// ErrForbidden is returned when the inviter is not allowed to perform the operation.
+	ErrForbidden = errors.New("not allowed to manage this invitation")
+	// ErrNotPending is returned when an invitation has already been accepted, revoked or has expired.
+	ErrNotPending = errors.New("invitation is no longer pending")
+	// ErrNotFound is returned when there is no invitation with the given ID or token.
+	ErrNotFound = errors.New("invitation not found")
+	// ErrAlreadyMember is returned when the invitee is already a member of the organization of the invitation.
+	ErrAlreadyMember = errors.New("user is already a member of the organization")
+)
+
+// Inviter struct represents the party that manages invitations.
+// Admin: Whether the inviter is a global admin authenticated with the admin API key. Admins manage every invitation.
+// Membership: The membership of the inviter in the current organization. It is only set for organization inviters.
+type Inviter struct {
+	Admin      bool
+	Membership entities.Membership
+}
+
+// Sender is an interface that defines the method used to deliver invite links to the invitees.
+type Sender interface {
+	// Send delivers the invite link to the email of the invitation.
+	// ctx: The context for the operation.
+	// invitation: The invitation to deliver.
+	// link: The invite link.
+	// Returns an error if the delivery fails.
+	Send(ctx context.Context, invitation entities.Invitation, link string) error
+}
+
+// UseCase is an interface that defines the methods required for invitation operations.
+// It includes methods for creating, listing, resending, revoking and accepting invitations.
+type UseCase interface {
+	// Create creates an invitation and sends its link to the invitee.
+	// ctx: The context for the operation.
+	// inviter: The party creating the invitation.
+	// invitation: The invitation with the email, the optional organization and the optional role of the invitee.
+	// Returns the invitation, its link and an error if the operation fails.
+	Create(ctx context.Context, inviter Inviter, invitation entities.Invitation) (entities.Invitation, string, error)
+
+	// List retrieves the invitations the inviter manages, most recent first.
+	// ctx: The context for the operation.
+	// inviter: The party listing the invitations.
+	// Returns the invitations and an error if the operation fails.
+	List(ctx context.Context, inviter Inviter) ([]entities.Invitation, error)
+
+	// Resend issues a new link for an invitation that has not been accepted or revoked, extends its expiry and sends it again.
+	// The previous link stops working.
+	// ctx: The context for the operation.
+	// inviter: The party resending the invitation.
+	// id: The ID of the invitation.
+	// Returns the invitation, its new link and an error if the operation fails.
+	Resend(ctx context.Context, inviter Inviter, id uuid.UUID) (entities.Invitation, string, error)
+
+	// Revoke revokes a pending invitation.
+	// ctx: The context for the operation.
+	// inviter: The party revoking the invitation.
+	// id: The ID of the invitation.
+	// Returns the revoked invitation and an error if the operation fails.
+	Revoke(ctx context.Context, inviter Inviter, id uuid.UUID) (entities.Invitation, error)
+
+	// Accept accepts an invitation on behalf of an existing user, who joins the organization of the invitation.
+	// ctx: The context for the operation.
+	// user: The authenticated user. Its email must match the email of the invitation.
+	// token: The token of the invitation.
+	// Returns the accepted invitation and an error if the operation fails.
+	Accept(ctx context.Context, user entities.User, token string) (entities.Invitation, error)
+
+	// AcceptWithRegistration accepts an invitation by creating the account of the invitee, who then joins the organization of the invitation.
+	// The account is created through the same validation path as the registration, with the email of the invitation.
+	// ctx: The context for the operation.
+	// token: The token of the invitation.
+	// registration: The username and password of the new account.
+	// Returns the created user and an error if the operation fails.
+	AcceptWithRegistration(ctx context.Context, token string, registration entities.User) (entities.User, error)
+}
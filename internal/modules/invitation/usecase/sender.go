@@ -0,0 +1,29 @@
+// Package usecase provides the functionality to invite people to create an account and join an organization.
+package usecase
+
+import (
+	"context"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/invitation"
+	"log"
+)
+
+// LogSender struct represents a sender that writes the invite links to the application log.
+// It is meant for development, until an email sender is configured.
+type LogSender struct{}
+
+// NewLogSender creates a new sender that writes the invite links to the application log.
+// Returns an invitation.Sender object.
+func NewLogSender() invitation.Sender {
+	return &LogSender{}
+}
+
+// Send writes the invite link to the application log.
+// ctx: The context for the operation.
+// inv: The invitation to deliver.
+// link: The invite link.
+// Returns nil.
+func (s LogSender) Send(ctx context.Context, inv entities.Invitation, link string) error {
+	log.Printf("Invitation for %s expires at %s: %s\n", inv.Email, inv.ExpiresAt.Format("2006-01-02 15:04:05"), link)
+	return nil
+}
@@ -0,0 +1,379 @@
+// Package usecase provides the functionality to invite people to create an account and join an organization.
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/invitation"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"strings"
+	"time"
+)
+
+// defaultTTL is the lifetime of an invitation when the configuration sets none.
+const defaultTTL = 72 * time.Hour
+
+// InvitationUseCase struct represents an invitation use case that provides methods for invitation operations.
+type InvitationUseCase struct {
+	cfg         *config.Config
+	invitations storage.InvitationRepository
+	memberships storage.MembershipRepository
+	orgs        storage.OrganizationRepository
+	authUC      auth.UseCase
+	sender      invitation.Sender
+	tx          storage.Transactor
+}
+
+// NewInvitationUC creates a new invitation use case with the provided configuration, repositories, auth use case and sender.
+// cfg: The configuration for the invitation use case.
+// invitations: The invitation repository.
+// memberships: The membership repository, used to add the invitees to their organization.
+// orgs: The organization repository, used to check the organization of admin invitations.
+// authUC: The auth use case, used to create the accounts of the invitees.
+// sender: The sender that delivers the invite links.
+// tx: The transactor that creates the account of an invitee and accepts the invitation atomically.
+// Returns an invitation.UseCase object.
+func NewInvitationUC(cfg *config.Config, invitations storage.InvitationRepository, memberships storage.MembershipRepository, orgs storage.OrganizationRepository, authUC auth.UseCase, sender invitation.Sender, tx storage.Transactor) invitation.UseCase {
+	return &InvitationUseCase{
+		cfg:         cfg,
+		invitations: invitations,
+		memberships: memberships,
+		orgs:        orgs,
+		authUC:      authUC,
+		sender:      sender,
+		tx:          tx,
+	}
+}
+
+// Create creates an invitation and sends its link to the invitee.
+// Organization inviters invite into their own organization and must be at least admins. Only owners and global admins can invite owners.
+// ctx: The context for the operation.
+// inviter: The party creating the invitation.
+// inv: The invitation with the email, the optional organization and the optional role of the invitee.
+// Returns the invitation, its link and an error if the operation fails.
+func (uc InvitationUseCase) Create(ctx context.Context, inviter invitation.Inviter, inv entities.Invitation) (entities.Invitation, string, error) {
+	inv.Email = strings.TrimSpace(inv.Email)
+	if !inviter.Admin {
+		inv.OrganizationID = inviter.Membership.OrganizationID
+	}
+	if inv.OrganizationID != uuid.Nil && inv.Role == "" {
+		inv.Role = entities.RoleMember
+	}
+	if err := validator.New().Struct(inv); err != nil {
+		return entities.Invitation{}, "", fmt.Errorf("invalid invitation: %w", err)
+	}
+	if inv.OrganizationID == uuid.Nil && inv.Role != "" {
+		return entities.Invitation{}, "", errors.New("a role can only be assigned with an organization")
+	}
+	if err := uc.authorize(inviter, inv.Role); err != nil {
+		return entities.Invitation{}, "", err
+	}
+	if inviter.Admin && inv.OrganizationID != uuid.Nil {
+		if _, err := uc.orgs.Read(ctx, inv.OrganizationID); err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				return entities.Invitation{}, "", errors.New("organization not found")
+			}
+			return entities.Invitation{}, "", err
+		}
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return entities.Invitation{}, "", err
+	}
+	inv.ID = uuid.New()
+	inv.Token, inv.TokenHash = token, hashToken(token)
+	inv.InvitedBy = inviter.Membership.UserID
+	inv.ExpiresAt = time.Now().Add(uc.ttl())
+	inv.AcceptedAt = time.Time{}
+	inv.RevokedAt = time.Time{}
+
+	ctx = uc.scope(ctx, inviter)
+	if err := uc.invitations.Create(ctx, inv); err != nil {
+		return entities.Invitation{}, "", err
+	}
+	return uc.send(ctx, inv)
+}
+
+// List retrieves the invitations the inviter manages, most recent first.
+// ctx: The context for the operation.
+// inviter: The party listing the invitations.
+// Returns the invitations and an error if the operation fails.
+func (uc InvitationUseCase) List(ctx context.Context, inviter invitation.Inviter) ([]entities.Invitation, error) {
+	if err := uc.authorize(inviter, ""); err != nil {
+		return nil, err
+	}
+	invitations, err := uc.invitations.ReadAll(uc.scope(ctx, inviter))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range invitations {
+		invitations[i].Status = invitations[i].StatusAt(now)
+	}
+	return invitations, nil
+}
+
+// Resend issues a new link for an invitation that has not been accepted or revoked, extends its expiry and sends it again.
+// ctx: The context for the operation.
+// inviter: The party resending the invitation.
+// id: The ID of the invitation.
+// Returns the invitation, its new link and an error if the operation fails.
+func (uc InvitationUseCase) Resend(ctx context.Context, inviter invitation.Inviter, id uuid.UUID) (entities.Invitation, string, error) {
+	ctx = uc.scope(ctx, inviter)
+	inv, err := uc.manageable(ctx, inviter, id)
+	if err != nil {
+		return entities.Invitation{}, "", err
+	}
+	if status := inv.StatusAt(time.Now()); status != entities.InvitationPending && status != entities.InvitationExpired {
+		return entities.Invitation{}, "", invitation.ErrNotPending
+	}
+
+	if inv.Token, err = generateToken(); err != nil {
+		return entities.Invitation{}, "", err
+	}
+	inv.TokenHash = hashToken(inv.Token)
+	inv.ExpiresAt = time.Now().Add(uc.ttl())
+	if err := uc.invitations.Update(ctx, inv); err != nil {
+		return entities.Invitation{}, "", err
+	}
+	return uc.send(ctx, inv)
+}
+
+// Revoke revokes a pending invitation.
+// ctx: The context for the operation.
+// inviter: The party revoking the invitation.
+// id: The ID of the invitation.
+// Returns the revoked invitation and an error if the operation fails.
+func (uc InvitationUseCase) Revoke(ctx context.Context, inviter invitation.Inviter, id uuid.UUID) (entities.Invitation, error) {
+	ctx = uc.scope(ctx, inviter)
+	inv, err := uc.manageable(ctx, inviter, id)
+	if err != nil {
+		return entities.Invitation{}, err
+	}
+	if inv.StatusAt(time.Now()) != entities.InvitationPending {
+		return entities.Invitation{}, invitation.ErrNotPending
+	}
+
+	inv.RevokedAt = time.Now()
+	if err := uc.invitations.Update(ctx, inv); err != nil {
+		return entities.Invitation{}, err
+	}
+	inv.Status = entities.InvitationRevoked
+	return inv, nil
+}
+
+// Accept accepts an invitation on behalf of an existing user, who joins the organization of the invitation.
+// The user joins the organization and the invitation is accepted in a transaction, so that of concurrent acceptances of the invitation,
+// the ones that find it accepted by another leave no membership behind.
+// ctx: The context for the operation.
+// user: The authenticated user. Its email must match the email of the invitation.
+// token: The token of the invitation.
+// Returns the accepted invitation, invitation.ErrNotPending if it was accepted concurrently, and an error if the operation fails.
+func (uc InvitationUseCase) Accept(ctx context.Context, user entities.User, token string) (entities.Invitation, error) {
+	var accepted entities.Invitation
+	err := uc.tx.WithTx(ctx, func(ctx context.Context) error {
+		inv, err := uc.pending(ctx, token)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(inv.Email, user.Email) {
+			return errors.New("invitation was issued to another email address")
+		}
+		accepted, err = uc.complete(ctx, inv, user)
+		return err
+	})
+	if err != nil {
+		return entities.Invitation{}, err
+	}
+	return accepted, nil
+}
+
+// AcceptWithRegistration accepts an invitation by creating the account of the invitee, who then joins the organization of the invitation.
+// The account is created and the invitation accepted in a transaction, so that no account is left behind if the invitation
+// cannot be accepted, such as when it is accepted concurrently, and a retry of the invitee does not find the email taken. The registration is audited and published
+// once the transaction ends, so that the audit log is not written while it holds the database.
+// ctx: The context for the operation.
+// token: The token of the invitation.
+// registration: The username and password of the new account.
+// Returns the created user, invitation.ErrNotPending if the invitation was accepted concurrently, and an error if the operation fails.
+func (uc InvitationUseCase) AcceptWithRegistration(ctx context.Context, token string, registration entities.User) (entities.User, error) {
+	var user entities.User
+	err := uc.tx.WithTx(ctx, func(ctx context.Context) error {
+		inv, err := uc.pending(ctx, token)
+		if err != nil {
+			return err
+		}
+
+		registration.Email = inv.Email
+		if user, err = uc.authUC.Register(ctx, registration); err != nil {
+			return err
+		}
+		_, err = uc.complete(ctx, inv, user)
+		return err
+	})
+	if err != nil {
+		return entities.User{}, err
+	}
+	return user, nil
+}
+
+// complete adds the user to the organization of the invitation and marks the invitation as accepted.
+// ctx: The context for the operation.
+// inv: The pending invitation.
+// user: The user accepting the invitation.
+// Returns the accepted invitation and an error if the operation fails.
+func (uc InvitationUseCase) complete(ctx context.Context, inv entities.Invitation, user entities.User) (entities.Invitation, error) {
+	if inv.OrganizationID == uuid.Nil {
+		ctx = database.WithoutTenantScope(ctx)
+	} else {
+		ctx = database.WithTenant(ctx, inv.OrganizationID)
+		_, err := uc.memberships.Read(ctx, user.ID)
+		if err == nil {
+			return entities.Invitation{}, invitation.ErrAlreadyMember
+		}
+		if !errors.Is(err, database.ErrNotFound) {
+			return entities.Invitation{}, err
+		}
+		membership := entities.Membership{
+			ID:     uuid.New(),
+			UserID: user.ID,
+			Role:   inv.Role,
+		}
+		if err := uc.memberships.Create(ctx, membership); err != nil {
+			return entities.Invitation{}, err
+		}
+	}
+
+	// The update is versioned, so that of concurrent acceptances, the ones that read the invitation before another accepted it fail.
+	inv.AcceptedAt = time.Now()
+	err := uc.invitations.Update(ctx, inv)
+	if errors.Is(err, database.ErrStale) {
+		return entities.Invitation{}, invitation.ErrNotPending
+	}
+	if err != nil {
+		return entities.Invitation{}, err
+	}
+	inv.Status = entities.InvitationAccepted
+	return inv, nil
+}
+
+// pending retrieves a pending invitation by the hash of its token.
+// ctx: The context for the operation.
+// token: The token of the invitation.
+// Returns the invitation and an error if it does not exist or is no longer pending.
+func (uc InvitationUseCase) pending(ctx context.Context, token string) (entities.Invitation, error) {
+	inv, err := uc.invitations.ReadByTokenHash(ctx, hashToken(token))
+	if errors.Is(err, database.ErrNotFound) {
+		return entities.Invitation{}, invitation.ErrNotFound
+	}
+	if err != nil {
+		return entities.Invitation{}, err
+	}
+	if inv.StatusAt(time.Now()) != entities.InvitationPending {
+		return entities.Invitation{}, invitation.ErrNotPending
+	}
+	return inv, nil
+}
+
+// manageable retrieves an invitation the inviter is allowed to manage.
+// ctx: The context for the operation, already scoped for the inviter.
+// inviter: The party managing the invitation.
+// id: The ID of the invitation.
+// Returns the invitation and an error if it does not exist or the inviter is not allowed to manage it.
+func (uc InvitationUseCase) manageable(ctx context.Context, inviter invitation.Inviter, id uuid.UUID) (entities.Invitation, error) {
+	if err := uc.authorize(inviter, ""); err != nil {
+		return entities.Invitation{}, err
+	}
+	inv, err := uc.invitations.Read(ctx, id)
+	if errors.Is(err, database.ErrNotFound) {
+		return entities.Invitation{}, invitation.ErrNotFound
+	}
+	if err != nil {
+		return entities.Invitation{}, err
+	}
+	if err := uc.authorize(inviter, inv.Role); err != nil {
+		return entities.Invitation{}, err
+	}
+	return inv, nil
+}
+
+// authorize checks that the inviter may manage invitations that grant the given role.
+// inviter: The party managing the invitation.
+// role: The role granted by the invitation.
+// Returns ErrForbidden if the inviter is not allowed to.
+func (uc InvitationUseCase) authorize(inviter invitation.Inviter, role string) error {
+	if inviter.Admin {
+		return nil
+	}
+	if !entities.RoleAtLeast(inviter.Membership.Role, entities.RoleAdmin) {
+		return invitation.ErrForbidden
+	}
+	if role == entities.RoleOwner && inviter.Membership.Role != entities.RoleOwner {
+		return invitation.ErrForbidden
+	}
+	return nil
+}
+
+// scope returns the context the repositories are called with for the inviter.
+// Organization inviters work within the tenant of the request, while admins work across organizations.
+// ctx: The context for the operation.
+// inviter: The party managing the invitations.
+// Returns the scoped context.
+func (uc InvitationUseCase) scope(ctx context.Context, inviter invitation.Inviter) context.Context {
+	if inviter.Admin {
+		return database.WithoutTenantScope(ctx)
+	}
+	return ctx
+}
+
+// send delivers the invite link of the invitation.
+// ctx: The context for the operation.
+// inv: The invitation to deliver.
+// Returns the invitation, its link and an error if the delivery fails.
+func (uc InvitationUseCase) send(ctx context.Context, inv entities.Invitation) (entities.Invitation, string, error) {
+	link := uc.cfg.Invitations.LinkBaseURL + inv.Token
+	if err := uc.sender.Send(ctx, inv, link); err != nil {
+		return entities.Invitation{}, "", fmt.Errorf("failed to send invitation: %w", err)
+	}
+	inv.Status = inv.StatusAt(time.Now())
+	return inv, link, nil
+}
+
+// ttl returns the configured lifetime of an invitation.
+func (uc InvitationUseCase) ttl() time.Duration {
+	if uc.cfg.Invitations.TTLHours <= 0 {
+		return defaultTTL
+	}
+	return time.Duration(uc.cfg.Invitations.TTLHours) * time.Hour
+}
+
+// generateToken generates the secret token of an invite link.
+// Returns the URL-safe token and an error if the operation fails.
+func generateToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// hashToken returns the hash the invitations are stored and looked up with, so that the stored invitations hold no working invite link.
+// The tokens are random, so a plain SHA-256 cannot be reversed by guessing them.
+// token: The token of an invite link.
+// Returns the SHA-256 hash of the token, hex-encoded.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,360 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/audit"
+	auditusecase "github.com/nikita-voronoy/go-clean-arch/internal/modules/audit/usecase"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth/authenticator"
+	authusecase "github.com/nikita-voronoy/go-clean-arch/internal/modules/auth/usecase"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/invitation"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	auditstorage "github.com/nikita-voronoy/go-clean-arch/internal/storage/audit"
+	invitationstorage "github.com/nikita-voronoy/go-clean-arch/internal/storage/invitation"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/membership"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/organization"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/user"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/bolt"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/memory"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingMemberships fails to add the invitees to their organization, once their account is created.
+type failingMemberships struct {
+	storage.MembershipRepository
+}
+
+func (failingMemberships) Create(ctx context.Context, model entities.Membership) error {
+	return errors.New("membership failed")
+}
+
+// recordingSender remembers the last link it was asked to deliver.
+type recordingSender struct {
+	link string
+}
+
+func (s *recordingSender) Send(ctx context.Context, inv entities.Invitation, link string) error {
+	s.link = link
+	return nil
+}
+
+// outsideTx records the audit events, and fails the test if one is recorded while the transaction of an acceptance is open,
+// which would take the locks of the audit log and of the database in the opposite order of the logins.
+type outsideTx struct {
+	audit.Recorder
+	t *testing.T
+}
+
+func (r outsideTx) Record(ctx context.Context, event entities.AuditEvent) error {
+	assert.False(r.t, storage.InTx(ctx), "The audit events must be recorded once the transaction ends")
+	return r.Recorder.Record(ctx, event)
+}
+
+type fixture struct {
+	uc          invitation.UseCase
+	db          database.Database
+	authUC      auth.UseCase
+	audit       audit.UseCase
+	sender      *recordingSender
+	orgID       uuid.UUID
+	owner       invitation.Inviter
+	memberships func(ctx context.Context) ([]entities.Membership, error)
+}
+
+func newFixture(t *testing.T) fixture {
+	return newFixtureOn(t, memory.NewDatabase())
+}
+
+// newFixtureOn builds the invitation use case on the given database, with the real auth and audit use cases.
+func newFixtureOn(t *testing.T, base database.Database) fixture {
+	cfg := &config.Config{
+		Invitations: config.InvitationConfig{TTLHours: 1, LinkBaseURL: "https://example.com/invitations/"},
+	}
+	db := database.NewTenantDatabase(base, "organization_id")
+
+	orgs := organization.NewOrganizationRepository(db)
+	orgID := uuid.New()
+	require.NoError(t, orgs.Create(context.Background(), entities.Organization{ID: orgID, Name: "Acme", Slug: "acme"}))
+
+	users := user.NewUserRepository(db)
+	auditUC := auditusecase.NewAuditUC(cfg, auditstorage.NewAuditRepository(db), storage.NewTransactor(db))
+	authUC := authusecase.NewAuthUC(cfg, users, storage.NewTransactor(db), authenticator.NewLocal(users), outsideTx{Recorder: auditUC, t: t}, nil)
+
+	memberships := membership.NewMembershipRepository(db)
+	sender := &recordingSender{}
+	return fixture{
+		uc:          NewInvitationUC(cfg, invitationstorage.NewInvitationRepository(db), memberships, orgs, authUC, sender, storage.NewTransactor(db)),
+		db:          db,
+		authUC:      authUC,
+		audit:       auditUC,
+		sender:      sender,
+		orgID:       orgID,
+		owner:       invitation.Inviter{Membership: entities.Membership{OrganizationID: orgID, UserID: uuid.New(), Role: entities.RoleOwner}},
+		memberships: memberships.ReadAll,
+	}
+}
+
+func (f fixture) tenant() context.Context {
+	return database.WithTenant(context.Background(), f.orgID)
+}
+
+func TestAcceptWithRegistrationJoinsOrganization(t *testing.T) {
+	f := newFixture(t)
+
+	inv, link, err := f.uc.Create(f.tenant(), f.owner, entities.Invitation{Email: "new@example.com", Role: entities.RoleAdmin})
+	require.NoError(t, err)
+	assert.Equal(t, link, f.sender.link)
+	assert.Equal(t, entities.InvitationPending, inv.Status)
+
+	created, err := f.uc.AcceptWithRegistration(context.Background(), inv.Token, entities.User{Username: "newbie", Password: "password1", Email: "ignored@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "new@example.com", created.Email, "The account must use the email of the invitation")
+
+	members, err := f.memberships(f.tenant())
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	assert.Equal(t, created.ID, members[0].UserID)
+	assert.Equal(t, entities.RoleAdmin, members[0].Role)
+
+	_, err = f.uc.AcceptWithRegistration(context.Background(), inv.Token, entities.User{Username: "again", Password: "password1"})
+	assert.ErrorIs(t, err, invitation.ErrNotPending)
+}
+
+func TestAcceptWithRegistrationLeavesNoAccountOnFailure(t *testing.T) {
+	f := newFixture(t)
+	inv, _, err := f.uc.Create(f.tenant(), f.owner, entities.Invitation{Email: "atomic@example.com"})
+	require.NoError(t, err)
+
+	failing := NewInvitationUC(&config.Config{}, invitationstorage.NewInvitationRepository(f.db), failingMemberships{membership.NewMembershipRepository(f.db)},
+		organization.NewOrganizationRepository(f.db), f.authUC, f.sender, storage.NewTransactor(f.db))
+	_, err = failing.AcceptWithRegistration(context.Background(), inv.Token, entities.User{Username: "atomic", Password: "password1"})
+	require.Error(t, err)
+	_, err = user.NewUserRepository(f.db).ReadByEmail(context.Background(), "atomic@example.com")
+	assert.ErrorIs(t, err, database.ErrNotFound, "The account must be rolled back with the acceptance")
+
+	created, err := f.uc.AcceptWithRegistration(context.Background(), inv.Token, entities.User{Username: "atomic", Password: "password1"})
+	require.NoError(t, err, "The invitee can retry with the same username")
+	assert.Equal(t, "atomic@example.com", created.Email)
+}
+
+func TestAcceptWithRegistrationRecordsAfterTransaction(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	inv, _, err := f.uc.Create(f.tenant(), f.owner, entities.Invitation{Email: "audited@example.com"})
+	require.NoError(t, err)
+
+	_, err = f.uc.AcceptWithRegistration(ctx, inv.Token, entities.User{Username: "audited", Password: "short"})
+	require.ErrorIs(t, err, auth.ErrInvalidUser)
+	events, err := f.audit.Query(ctx, entities.AuditFilter{Action: entities.AuditActionRegister})
+	require.NoError(t, err)
+	require.Len(t, events, 1, "The failed registration is recorded although the transaction is rolled back")
+	assert.Equal(t, entities.AuditOutcomeFailure, events[0].Outcome)
+
+	failing := NewInvitationUC(&config.Config{}, invitationstorage.NewInvitationRepository(f.db), failingMemberships{membership.NewMembershipRepository(f.db)},
+		organization.NewOrganizationRepository(f.db), f.authUC, f.sender, storage.NewTransactor(f.db))
+	_, err = failing.AcceptWithRegistration(ctx, inv.Token, entities.User{Username: "audited", Password: "password1"})
+	require.Error(t, err)
+	events, err = f.audit.Query(ctx, entities.AuditFilter{Action: entities.AuditActionRegister})
+	require.NoError(t, err)
+	assert.Len(t, events, 1, "The registration rolled back with the acceptance is not recorded as a success")
+
+	created, err := f.uc.AcceptWithRegistration(ctx, inv.Token, entities.User{Username: "audited", Password: "password1"})
+	require.NoError(t, err)
+	events, err = f.audit.Query(ctx, entities.AuditFilter{Action: entities.AuditActionRegister})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, entities.AuditOutcomeSuccess, events[0].Outcome)
+	assert.Equal(t, created.ID.String(), events[0].Target)
+}
+
+func TestAcceptWithRegistrationAlongsideLogins(t *testing.T) {
+	const invitees = 20
+	backends := map[string]func(t *testing.T) database.Database{
+		"memory": func(t *testing.T) database.Database {
+			return memory.NewDatabase()
+		},
+		"bolt": func(t *testing.T) database.Database {
+			db, err := bolt.NewDatabase(&config.Config{DB: config.DatabaseConfig{Bolt: config.BoltConfig{Path: filepath.Join(t.TempDir(), "invitations.bolt"), TimeoutSeconds: 1}}})
+			require.NoError(t, err)
+			t.Cleanup(func() { _ = db.Close() })
+			return db
+		},
+	}
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			f := newFixtureOn(t, backend(t))
+			ctx := context.Background()
+
+			tokens := make([]string, invitees)
+			for i := range tokens {
+				inv, _, err := f.uc.Create(f.tenant(), f.owner, entities.Invitation{Email: fmt.Sprintf("invitee%d@example.com", i)})
+				require.NoError(t, err)
+				tokens[i] = inv.Token
+				_, err = f.authUC.Register(ctx, entities.User{Username: fmt.Sprintf("member%d", i), Email: fmt.Sprintf("member%d@example.com", i), Password: "password1"})
+				require.NoError(t, err)
+			}
+
+			// The registrations of the invitees run in the transactions of their acceptance, while the logins write the audit log
+			// on their own, so neither must hold a lock the other waits for.
+			var wg sync.WaitGroup
+			for i := 0; i < invitees; i++ {
+				wg.Add(2)
+				go func(i int) {
+					defer wg.Done()
+					_, err := f.uc.AcceptWithRegistration(ctx, tokens[i], entities.User{Username: fmt.Sprintf("invitee%d", i), Password: "password1"})
+					assert.NoError(t, err)
+				}(i)
+				go func(i int) {
+					defer wg.Done()
+					_, err := f.authUC.Login(ctx, entities.UserLogin{Email: fmt.Sprintf("member%d@example.com", i), Password: "password1"})
+					assert.NoError(t, err)
+				}(i)
+			}
+			done := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(20 * time.Second):
+				t.Fatal("The registrations and the logins deadlocked")
+			}
+
+			result, err := f.audit.Verify(ctx)
+			require.NoError(t, err)
+			assert.True(t, result.Valid)
+			assert.Equal(t, 3*invitees, result.Checked, "Every registration and login is recorded once")
+		})
+	}
+}
+
+func TestResendInvalidatesPreviousLink(t *testing.T) {
+	f := newFixture(t)
+
+	inv, _, err := f.uc.Create(f.tenant(), f.owner, entities.Invitation{Email: "resend@example.com"})
+	require.NoError(t, err)
+	resent, _, err := f.uc.Resend(f.tenant(), f.owner, inv.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, inv.Token, resent.Token)
+
+	_, err = f.uc.Accept(context.Background(), entities.User{ID: uuid.New(), Email: "resend@example.com"}, inv.Token)
+	assert.Error(t, err, "The previous link must no longer work")
+	_, err = f.uc.Accept(context.Background(), entities.User{ID: uuid.New(), Email: "resend@example.com"}, resent.Token)
+	assert.NoError(t, err)
+}
+
+func TestTokensAreStoredHashed(t *testing.T) {
+	f := newFixture(t)
+
+	inv, link, err := f.uc.Create(f.tenant(), f.owner, entities.Invitation{Email: "hashed@example.com"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(link, "/"+inv.Token), "The link carries the token")
+
+	var stored entities.Invitation
+	require.NoError(t, f.db.Read(f.tenant(), &stored, query.Eq("id", inv.ID)))
+	assert.Empty(t, stored.Token, "The token is not stored")
+	sum := sha256.Sum256([]byte(inv.Token))
+	assert.Equal(t, hex.EncodeToString(sum[:]), stored.TokenHash)
+
+	_, err = f.uc.Accept(context.Background(), entities.User{ID: uuid.New(), Email: "hashed@example.com"}, stored.TokenHash)
+	assert.ErrorIs(t, err, invitation.ErrNotFound, "The stored hash is not a working token")
+	_, err = f.uc.Accept(context.Background(), entities.User{ID: uuid.New(), Email: "hashed@example.com"}, inv.Token)
+	assert.NoError(t, err)
+}
+
+// racingInvitations accepts every invitation it looks up by token before returning it, as a concurrent acceptance that commits
+// between the read and the write of another would.
+type racingInvitations struct {
+	storage.InvitationRepository
+}
+
+func (r racingInvitations) ReadByTokenHash(ctx context.Context, hash string) (entities.Invitation, error) {
+	inv, err := r.InvitationRepository.ReadByTokenHash(ctx, hash)
+	if err != nil {
+		return inv, err
+	}
+	accepted := inv
+	accepted.AcceptedAt = time.Now()
+	return inv, r.InvitationRepository.Update(database.WithTenant(ctx, inv.OrganizationID), accepted)
+}
+
+func TestConcurrentAcceptLeavesNoMembership(t *testing.T) {
+	f := newFixture(t)
+	inv, _, err := f.uc.Create(f.tenant(), f.owner, entities.Invitation{Email: "racing@example.com"})
+	require.NoError(t, err)
+
+	cfg := &config.Config{Invitations: config.InvitationConfig{TTLHours: 1}}
+	memberships := membership.NewMembershipRepository(f.db)
+	racing := NewInvitationUC(cfg, racingInvitations{invitationstorage.NewInvitationRepository(f.db)}, memberships,
+		organization.NewOrganizationRepository(f.db), f.authUC, f.sender, storage.NewTransactor(f.db))
+	_, err = racing.Accept(context.Background(), entities.User{ID: uuid.New(), Email: "racing@example.com"}, inv.Token)
+	assert.ErrorIs(t, err, invitation.ErrNotPending, "The acceptance that loses the race fails")
+
+	joined, err := f.memberships(f.tenant())
+	require.NoError(t, err)
+	assert.Empty(t, joined, "The acceptance that loses the race leaves no membership behind")
+}
+
+func TestRevokedAndExpiredInvitationsCannotBeAccepted(t *testing.T) {
+	f := newFixture(t)
+
+	revoked, _, err := f.uc.Create(f.tenant(), f.owner, entities.Invitation{Email: "revoked@example.com"})
+	require.NoError(t, err)
+	_, err = f.uc.Revoke(f.tenant(), f.owner, revoked.ID)
+	require.NoError(t, err)
+	_, err = f.uc.Accept(context.Background(), entities.User{ID: uuid.New(), Email: "revoked@example.com"}, revoked.Token)
+	assert.ErrorIs(t, err, invitation.ErrNotPending)
+
+	expired, _, err := f.uc.Create(f.tenant(), f.owner, entities.Invitation{Email: "expired@example.com"})
+	require.NoError(t, err)
+	token := expired.Token
+	require.NoError(t, f.db.Read(f.tenant(), &expired, query.Eq("id", expired.ID)))
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	require.NoError(t, f.db.Update(f.tenant(), &expired))
+	_, err = f.uc.Accept(context.Background(), entities.User{ID: uuid.New(), Email: "expired@example.com"}, token)
+	assert.ErrorIs(t, err, invitation.ErrNotPending)
+
+	invitations, err := f.uc.List(f.tenant(), f.owner)
+	require.NoError(t, err)
+	statuses := map[string]string{}
+	for _, inv := range invitations {
+		statuses[inv.Email] = inv.Status
+	}
+	assert.Equal(t, entities.InvitationRevoked, statuses["revoked@example.com"])
+	assert.Equal(t, entities.InvitationExpired, statuses["expired@example.com"])
+}
+
+func TestInvitationPermissions(t *testing.T) {
+	f := newFixture(t)
+	member := invitation.Inviter{Membership: entities.Membership{OrganizationID: f.orgID, UserID: uuid.New(), Role: entities.RoleMember}}
+	admin := invitation.Inviter{Membership: entities.Membership{OrganizationID: f.orgID, UserID: uuid.New(), Role: entities.RoleAdmin}}
+
+	_, _, err := f.uc.Create(f.tenant(), member, entities.Invitation{Email: "a@example.com"})
+	assert.ErrorIs(t, err, invitation.ErrForbidden)
+
+	_, _, err = f.uc.Create(f.tenant(), admin, entities.Invitation{Email: "b@example.com", Role: entities.RoleOwner})
+	assert.ErrorIs(t, err, invitation.ErrForbidden)
+
+	_, _, err = f.uc.Create(context.Background(), invitation.Inviter{Admin: true}, entities.Invitation{Email: "c@example.com", OrganizationID: f.orgID, Role: entities.RoleOwner})
+	assert.NoError(t, err, "Global admins can invite owners into any organization")
+
+	_, _, err = f.uc.Create(context.Background(), invitation.Inviter{Admin: true}, entities.Invitation{Email: "d@example.com", Role: entities.RoleAdmin})
+	assert.Error(t, err, "A role requires an organization")
+}
@@ -0,0 +1,26 @@
+// Package organization provides the functionality to interact with organizations and their members.
+package organization
+
+import (
+	"context"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+)
+
+// membershipKey is the context key under which the membership of the user in the current organization is stored.
+type membershipKey struct{}
+
+// WithMembership returns a copy of the context that carries the membership of the user in the current organization.
+// ctx: The parent context.
+// membership: The membership to store.
+// Returns the new context.
+func WithMembership(ctx context.Context, membership entities.Membership) context.Context {
+	return context.WithValue(ctx, membershipKey{}, membership)
+}
+
+// MembershipFromContext retrieves the membership of the user in the current organization from the context.
+// ctx: The context to read from.
+// Returns the membership and a boolean indicating if the context carries one.
+func MembershipFromContext(ctx context.Context) (entities.Membership, bool) {
+	membership, ok := ctx.Value(membershipKey{}).(entities.Membership)
+	return membership, ok
+}
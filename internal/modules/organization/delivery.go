@@ -0,0 +1,25 @@
+// Package organization provides the functionality to interact with organizations and their members.
+package organization
+
+import "github.com/labstack/echo/v4"
+
+// Handlers is an interface that defines the methods required for handling organization operations.
+type Handlers interface {
+	// Create handles the creation of an organization.
+	Create() echo.HandlerFunc
+
+	// List handles the retrieval of the organizations of the authenticated user.
+	List() echo.HandlerFunc
+
+	// Switch handles binding the bearer token to an organization.
+	Switch() echo.HandlerFunc
+
+	// Members handles the retrieval of the members of the current organization.
+	Members() echo.HandlerFunc
+
+	// SetRole handles changing the role of a member of the current organization.
+	SetRole() echo.HandlerFunc
+
+	// RemoveMember handles removing a member from the current organization.
+	RemoveMember() echo.HandlerFunc
+}
@@ -0,0 +1,184 @@
+// Package http provides the functionality to handle HTTP requests for the organization module.
+package http
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"                                           // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/config"                        // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/app"                  // App package provides the functionality to map the errors of the storage layer to HTTP status codes.
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"             // Entities package provides the functionality to interact with the entities of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth"         // Auth package provides the functionality to interact with the auth module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/organization" // Organization package provides the functionality to interact with the organization module.
+	"net/http"
+)
+
+// OrganizationHandlers struct represents organization handlers that provide methods for handling HTTP requests for the organization module.
+type OrganizationHandlers struct {
+	cfg   *config.Config       // The configuration for the organization handlers.
+	orgUC organization.UseCase // The organization use case for the organization handlers.
+}
+
+// roleRequest struct represents the body of a role change request.
+type roleRequest struct {
+	Role string `json:"role"`
+}
+
+// NewOrganizationHandlers creates new organization handlers with the provided configuration and organization use case.
+// cfg: The configuration for the organization handlers.
+// orgUC: The organization use case for the organization handlers.
+// Returns an OrganizationHandlers object.
+func NewOrganizationHandlers(cfg *config.Config, orgUC organization.UseCase) *OrganizationHandlers {
+	return &OrganizationHandlers{
+		cfg:   cfg,
+		orgUC: orgUC,
+	}
+}
+
+// Create creates a new organization owned by the authenticated user.
+// @route POST /orgs
+// @group Organizations
+// @param {Organization.model} organization.body.required - Organization details
+// @returns {object} 201 - The organization has been successfully created.
+// @returns {object} 400 - The request could not be understood or was missing required parameters.
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 409 - An organization with the given slug already exists.
+func (h *OrganizationHandlers) Create() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, _ := auth.UserFromContext(c.Request().Context())
+
+		var org entities.Organization
+		if err := c.Bind(&org); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "failed to bind organization")
+		}
+
+		org, err := h.orgUC.Create(c.Request().Context(), user, org)
+		if err != nil {
+			return statusError(err, "failed to create organization")
+		}
+		return c.JSON(http.StatusCreated, org)
+	}
+}
+
+// List retrieves the organizations of the authenticated user.
+// @route GET /orgs
+// @group Organizations
+// @returns {Array} 200 - An array of organizations
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 500 - Server error
+func (h *OrganizationHandlers) List() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, _ := auth.UserFromContext(c.Request().Context())
+
+		orgs, err := h.orgUC.ListForUser(c.Request().Context(), user.ID)
+		if err != nil {
+			return echo.NewHTTPError(app.StatusCode(err, http.StatusInternalServerError), fmt.Sprintf("failed to list organizations: %v", err))
+		}
+		return c.JSON(http.StatusOK, orgs)
+	}
+}
+
+// Switch binds the bearer token of the authenticated user to an organization.
+// @route POST /orgs/:id/switch
+// @group Organizations
+// @returns {object} 204 - The token is now bound to the organization.
+// @returns {object} 400 - The organization ID is invalid.
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 403 - The user is not a member of the organization.
+func (h *OrganizationHandlers) Switch() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, _ := auth.UserFromContext(c.Request().Context())
+
+		orgID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid organization id")
+		}
+
+		if err := h.orgUC.Switch(c.Request().Context(), user, orgID); err != nil {
+			return statusError(err, "failed to switch organization")
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// Members retrieves the members of the current organization.
+// @route GET /org/members
+// @group Organizations
+// @returns {Array} 200 - An array of memberships
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 403 - The user is not a member of the organization.
+func (h *OrganizationHandlers) Members() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		members, err := h.orgUC.Members(c.Request().Context())
+		if err != nil {
+			return echo.NewHTTPError(app.StatusCode(err, http.StatusInternalServerError), fmt.Sprintf("failed to list members: %v", err))
+		}
+		return c.JSON(http.StatusOK, members)
+	}
+}
+
+// SetRole changes the role of a member of the current organization.
+// @route PUT /org/members/:user_id
+// @group Organizations
+// @param {object} role.body.required - The new role
+// @returns {object} 200 - The updated membership
+// @returns {object} 400 - The role or user ID is invalid.
+// @returns {object} 403 - The role of the user does not allow the change.
+func (h *OrganizationHandlers) SetRole() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		actor, _ := organization.MembershipFromContext(c.Request().Context())
+
+		userID, err := uuid.Parse(c.Param("user_id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+		}
+		var request roleRequest
+		if err := c.Bind(&request); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "failed to bind role")
+		}
+
+		membership, err := h.orgUC.SetRole(c.Request().Context(), actor, userID, request.Role)
+		if err != nil {
+			return statusError(err, "failed to change role")
+		}
+		return c.JSON(http.StatusOK, membership)
+	}
+}
+
+// RemoveMember removes a member from the current organization.
+// @route DELETE /org/members/:user_id
+// @group Organizations
+// @returns {object} 204 - The member has been removed.
+// @returns {object} 400 - The user ID is invalid.
+// @returns {object} 403 - The role of the user does not allow the removal.
+func (h *OrganizationHandlers) RemoveMember() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		actor, _ := organization.MembershipFromContext(c.Request().Context())
+
+		userID, err := uuid.Parse(c.Param("user_id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid user id")
+		}
+
+		if err := h.orgUC.RemoveMember(c.Request().Context(), actor, userID); err != nil {
+			return statusError(err, "failed to remove member")
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// statusError converts an error of the organization use case into an HTTP error.
+// err: The error to convert.
+// message: The message describing the failed operation.
+// Returns an *echo.HTTPError with 403 for permission errors, 409 for taken slugs, the status code of the storage errors and 400 otherwise.
+func statusError(err error, message string) error {
+	status := app.StatusCode(err, http.StatusBadRequest)
+	switch {
+	case errors.Is(err, organization.ErrForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, organization.ErrSlugTaken):
+		status = http.StatusConflict
+	}
+	return echo.NewHTTPError(status, fmt.Sprintf("%s: %v", message, err))
+}
@@ -0,0 +1,119 @@
+// Package http provides the functionality to handle HTTP requests for the organization module.
+package http
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"                                           // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/config"                        // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/app"                  // App package provides the functionality to map the errors of the storage layer to HTTP status codes.
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"             // Entities package provides the functionality to interact with the entities of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth"         // Auth package provides the functionality to interact with the auth module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/organization" // Organization package provides the functionality to interact with the organization module.
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"                  // Database package provides the functionality to scope the database operations to the tenant.
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ResolveTenant creates a middleware that resolves the tenant organization of the request and scopes the request to it.
+// The tenant is read, in order of precedence, from the configured header, from the subdomain of the configured
+// base domain, and from the organization claim of the bearer token.
+// The authenticated user must be a member of the tenant. The membership is stored in the request context, and every
+// database operation on tenant-owned entities made with the request context is scoped to the tenant.
+// The middleware must run after the authentication middleware.
+// cfg: The configuration that contains the tenancy settings.
+// uc: The organization use case used to resolve the tenant and the membership.
+// Returns an echo.MiddlewareFunc.
+func ResolveTenant(cfg *config.Config, uc organization.UseCase) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+			user, ok := auth.UserFromContext(ctx)
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+			}
+
+			ref := tenantReference(c, cfg, user)
+			if ref == "" {
+				return echo.NewHTTPError(http.StatusBadRequest, "tenant could not be resolved")
+			}
+			org, err := uc.Resolve(ctx, ref)
+			if errors.Is(err, database.ErrNotFound) {
+				return echo.NewHTTPError(http.StatusNotFound, "organization not found")
+			}
+			if err != nil {
+				return echo.NewHTTPError(app.StatusCode(err, http.StatusInternalServerError), fmt.Sprintf("failed to resolve organization: %v", err))
+			}
+			membership, err := uc.Membership(ctx, org.ID, user.ID)
+			if errors.Is(err, database.ErrNotFound) {
+				return echo.NewHTTPError(http.StatusForbidden, "not a member of the organization")
+			}
+			if err != nil {
+				return echo.NewHTTPError(app.StatusCode(err, http.StatusInternalServerError), fmt.Sprintf("failed to read membership: %v", err))
+			}
+
+			ctx = database.WithTenant(ctx, org.ID)
+			ctx = organization.WithMembership(ctx, membership)
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// RequireRole creates a middleware that requires the membership in the current organization to have at least the given role.
+// The middleware must run after ResolveTenant.
+// role: The minimum role.
+// Returns an echo.MiddlewareFunc.
+func RequireRole(role string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			membership, ok := organization.MembershipFromContext(c.Request().Context())
+			if !ok || !entities.RoleAtLeast(membership.Role, role) {
+				return echo.NewHTTPError(http.StatusForbidden, organization.ErrForbidden.Error())
+			}
+			return next(c)
+		}
+	}
+}
+
+// tenantReference reads the ID or slug of the tenant organization from the request.
+// c: The Echo context of the request.
+// cfg: The configuration that contains the tenancy settings.
+// user: The authenticated user, whose token may carry an organization claim.
+// Returns the reference, or an empty string if the request does not name a tenant.
+func tenantReference(c echo.Context, cfg *config.Config, user entities.User) string {
+	if cfg.Tenancy.Header != "" {
+		if ref := strings.TrimSpace(c.Request().Header.Get(cfg.Tenancy.Header)); ref != "" {
+			return ref
+		}
+	}
+	if ref := subdomain(c.Request().Host, cfg.Tenancy.BaseDomain); ref != "" {
+		return ref
+	}
+	if user.TokenOrganizationID != uuid.Nil {
+		return user.TokenOrganizationID.String()
+	}
+	return ""
+}
+
+// subdomain extracts the first label of the host below the base domain.
+// host: The host of the request, optionally with a port.
+// baseDomain: The base domain. Nothing is extracted if it is empty.
+// Returns the subdomain, or an empty string if the host is not a subdomain of the base domain.
+func subdomain(host, baseDomain string) string {
+	if baseDomain == "" {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	suffix := "." + strings.ToLower(strings.TrimPrefix(baseDomain, "."))
+	if !strings.HasSuffix(host, suffix) {
+		return ""
+	}
+	labels := strings.Split(strings.TrimSuffix(host, suffix), ".")
+	return labels[len(labels)-1]
+}
@@ -0,0 +1,34 @@
+// Package http provides the functionality to map the routes of the organization module over HTTP.
+package http
+
+import (
+	"github.com/labstack/echo/v4"                                           // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"             // Entities package provides the functionality to interact with the entities of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/organization" // Organization package provides the functionality to interact with the organization module.
+)
+
+// MapOrganizationRoutes maps the routes that work across organizations to the provided Echo group.
+// orgsGroup: The Echo group to map the routes to. It is expected to require authentication.
+// h: The organization handlers to use for the routes.
+// The routes include:
+// POST /: Creates an organization owned by the authenticated user.
+// GET /: Retrieves the organizations of the authenticated user.
+// POST /:id/switch: Binds the bearer token to an organization.
+func MapOrganizationRoutes(orgsGroup *echo.Group, h organization.Handlers) {
+	orgsGroup.POST("", h.Create())
+	orgsGroup.GET("", h.List())
+	orgsGroup.POST("/:id/switch", h.Switch())
+}
+
+// MapTenantRoutes maps the routes that work on the current organization to the provided Echo group.
+// tenantGroup: The Echo group to map the routes to. It is expected to require authentication and to resolve the tenant.
+// h: The organization handlers to use for the routes.
+// The routes include:
+// GET /members: Retrieves the members of the organization.
+// PUT /members/:user_id: Changes the role of a member. Requires the admin role.
+// DELETE /members/:user_id: Removes a member. Members can remove themselves, admins can remove others.
+func MapTenantRoutes(tenantGroup *echo.Group, h organization.Handlers) {
+	tenantGroup.GET("/members", h.Members())
+	tenantGroup.PUT("/members/:user_id", h.SetRole(), RequireRole(entities.RoleAdmin))
+	tenantGroup.DELETE("/members/:user_id", h.RemoveMember())
+}
@@ -0,0 +1,37 @@
+// Package delivery provides the functionality to deliver the responses of the organization module.
+package delivery
+
+import (
+	"github.com/labstack/echo/v4"                                                          // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/config"                                       // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth"                        // Auth package provides the functionality to interact with the auth module.
+	authhttp "github.com/nikita-voronoy/go-clean-arch/internal/modules/auth/delivery/http" // Auth HTTP package provides the authentication middleware.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/organization"                // Organization package provides the functionality to interact with the organization module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/organization/delivery/http"  // HTTP package provides the functionality to deliver the responses of the organization module over HTTP.
+)
+
+// OrganizationDelivery struct represents an organization delivery that provides methods for delivering the responses of the organization module.
+// It includes an OrganizationHandlers object for handling the responses and a function for setting up the routes.
+type OrganizationDelivery struct {
+	Handlers        *http.OrganizationHandlers // The handlers for the organization responses.
+	SetupRoutesFunc func(echo *echo.Echo)      // The function for setting up the routes.
+}
+
+// NewOrganizationDelivery creates a new organization delivery with the provided configuration and use cases.
+// cfg: The configuration for the organization delivery.
+// uc: The organization use case for the organization delivery.
+// authUC: The auth use case used to authenticate the requests.
+// Returns an OrganizationDelivery object.
+func NewOrganizationDelivery(cfg *config.Config, uc organization.UseCase, authUC auth.UseCase) *OrganizationDelivery {
+	handlers := http.NewOrganizationHandlers(cfg, uc) // Creates new organization handlers with the provided configuration and organization use case.
+
+	// Returns a new OrganizationDelivery object with the created handlers and a function for setting up the routes.
+	return &OrganizationDelivery{
+		Handlers: handlers,
+		SetupRoutesFunc: func(e *echo.Echo) {
+			authenticate := authhttp.Authenticate(authUC)
+			http.MapOrganizationRoutes(e.Group("/orgs", authenticate), handlers)                       // Maps the organization routes to the "/orgs" group.
+			http.MapTenantRoutes(e.Group("/org", authenticate, http.ResolveTenant(cfg, uc)), handlers) // Maps the tenant routes to the "/org" group.
+		},
+	}
+}
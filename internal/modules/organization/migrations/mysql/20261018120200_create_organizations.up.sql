@@ -0,0 +1,24 @@
+-- The organizations and memberships tables, as they were created before the migrations were versioned, so that an existing database is adopted.
+-- The columns added since then are added by the following migrations.
+CREATE TABLE IF NOT EXISTS organizations (
+    id {{.UUID}} NOT NULL,
+    name longtext NOT NULL,
+    slug varchar(191) NOT NULL,
+    created_at datetime(6) NULL,
+    updated_at datetime(6) NULL,
+    last_login_at datetime(6) NULL DEFAULT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY slug (slug)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS memberships (
+    id {{.UUID}} NOT NULL,
+    organization_id {{.UUID}} NOT NULL,
+    user_id {{.UUID}} NOT NULL,
+    role longtext NOT NULL,
+    created_at datetime(6) NULL,
+    updated_at datetime(6) NULL,
+    last_login_at datetime(6) NULL DEFAULT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY idx_membership_org_user (organization_id, user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,2 @@
+ALTER TABLE organizations DROP KEY idx_organizations_external_id;
+ALTER TABLE organizations DROP COLUMN external_id;
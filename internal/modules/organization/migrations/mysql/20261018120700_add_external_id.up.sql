@@ -0,0 +1,3 @@
+-- The ID of the group in the identity provider, for the organizations provisioned over SCIM.
+ALTER TABLE organizations ADD COLUMN external_id varchar(191);
+ALTER TABLE organizations ADD KEY idx_organizations_external_id (external_id);
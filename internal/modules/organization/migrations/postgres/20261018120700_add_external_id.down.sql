@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_organizations_external_id;
+ALTER TABLE organizations DROP COLUMN external_id;
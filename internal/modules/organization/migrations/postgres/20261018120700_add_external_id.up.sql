@@ -0,0 +1,3 @@
+-- The ID of the group in the identity provider, for the organizations provisioned over SCIM.
+ALTER TABLE organizations ADD COLUMN external_id text;
+CREATE INDEX IF NOT EXISTS idx_organizations_external_id ON organizations (external_id);
@@ -0,0 +1,2 @@
+ALTER TABLE organizations DROP COLUMN version;
+ALTER TABLE memberships DROP COLUMN version;
@@ -0,0 +1,3 @@
+-- The versions of the records, which the updates compare and increment. The existing records start at the first version.
+ALTER TABLE organizations ADD COLUMN version bigint NOT NULL DEFAULT 1;
+ALTER TABLE memberships ADD COLUMN version bigint NOT NULL DEFAULT 1;
@@ -0,0 +1,23 @@
+-- The organizations and memberships tables, as they were created before the migrations were versioned, so that an existing database is adopted.
+-- The columns added since then are added by the following migrations.
+CREATE TABLE IF NOT EXISTS organizations (
+    id uuid NOT NULL,
+    name text NOT NULL,
+    slug text NOT NULL UNIQUE,
+    created_at datetime,
+    updated_at datetime,
+    last_login_at datetime DEFAULT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS memberships (
+    id uuid NOT NULL,
+    organization_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    role text NOT NULL,
+    created_at datetime,
+    updated_at datetime,
+    last_login_at datetime DEFAULT NULL,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_membership_org_user ON memberships (organization_id, user_id);
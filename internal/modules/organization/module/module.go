@@ -0,0 +1,58 @@
+// Package module provides the functionality to interact with the organization module.
+package module
+
+import (
+	"github.com/labstack/echo/v4"                                                          // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/config"                                       // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth"                        // Auth package provides the functionality to interact with the auth module.
+	authhttp "github.com/nikita-voronoy/go-clean-arch/internal/modules/auth/delivery/http" // Auth HTTP package provides the authentication middleware.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/organization"                // Organization package provides the functionality to interact with the organization module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/organization/delivery"       // Delivery package provides the functionality to deliver the responses of the organization module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/organization/delivery/http"  // HTTP package provides the functionality to deliver the responses of the organization module over HTTP.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/organization/migrations"     // Migrations package provides the SQL migrations of the schema of the organization module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/organization/usecase"        // Usecase package provides the functionality to interact with the use cases of the organization module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/membership"                  // Membership package provides the functionality to interact with the membership storage.
+	orgstorage "github.com/nikita-voronoy/go-clean-arch/internal/storage/organization"     // Organization storage package provides the functionality to interact with the organization storage.
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"                                 // Database package provides the functionality to interact with the database of the application.
+	"go.uber.org/fx"                                                                       // Fx is a framework for Go that provides the building blocks for your service architectures.
+)
+
+// TenantColumn is the column that holds the tenant ID of the tenant-owned entities.
+const TenantColumn = "organization_id"
+
+// Migrations is a Fx option that registers the SQL migrations of the organization module with the migrations group.
+var Migrations = fx.Supply(fx.Annotated{Group: "migrations", Target: migrations.Source})
+
+// Module is a Fx options group that provides and invokes the necessary dependencies for the organization module.
+// It also decorates the database of the application, so that every repository is scoped to the tenant of the request.
+var Module = fx.Options(
+	Migrations, // Registers the SQL migrations of the organization module.
+	fx.Provide(
+		orgstorage.NewOrganizationRepository, // Provides a new organization repository.
+		membership.NewMembershipRepository,   // Provides a new membership repository.
+		usecase.NewOrganizationUC,            // Provides a new organization use case.
+		http.NewOrganizationHandlers,         // Provides new organization handlers.
+		delivery.NewOrganizationDelivery,     // Provides a new organization delivery.
+	),
+	fx.Decorate(scopeDatabase),            // Decorates the database with the tenant scoping.
+	fx.Invoke(registerOrganizationRoutes), // Invokes the function to register the organization routes.
+)
+
+// scopeDatabase decorates the database so that the operations on tenant-owned entities are scoped to the tenant in the context.
+// db: The database to decorate.
+// Returns the tenant-scoped database.
+func scopeDatabase(db database.Database) database.Database {
+	return database.NewTenantDatabase(db, TenantColumn)
+}
+
+// registerOrganizationRoutes registers the organization routes with the provided Echo instance and organization handlers.
+// e: The Echo instance to register the routes with.
+// cfg: The configuration that contains the tenancy settings.
+// handlers: The organization handlers to use for the routes.
+// orgUC: The organization use case used to resolve the tenant.
+// authUC: The auth use case used to authenticate the requests.
+func registerOrganizationRoutes(e *echo.Echo, cfg *config.Config, handlers *http.OrganizationHandlers, orgUC organization.UseCase, authUC auth.UseCase) {
+	authenticate := authhttp.Authenticate(authUC)
+	http.MapOrganizationRoutes(e.Group("/orgs", authenticate), handlers)                          // Maps the organization routes to the "/orgs" group.
+	http.MapTenantRoutes(e.Group("/org", authenticate, http.ResolveTenant(cfg, orgUC)), handlers) // Maps the tenant routes to the "/org" group.
+}
@@ -0,0 +1,76 @@
+// Package organization provides the functionality to interact with organizations and their members.
+package organization
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+)
+
+var (
+	// ErrForbidden is returned when the role of the acting member does not allow the operation.
+	ErrForbidden = errors.New("insufficient organization role")
+	// ErrLastOwner is returned when an operation would leave an organization without an owner.
+	ErrLastOwner = errors.New("an organization must keep at least one owner")
+	// ErrSlugTaken is returned when an organization with the given slug already exists.
+	ErrSlugTaken = errors.New("an organization with the given slug already exists")
+)
+
+// UseCase is an interface that defines the methods required for organization operations.
+// It includes methods for managing organizations and their members.
+// The methods that work on the members of an organization are scoped to the tenant in the context.
+type UseCase interface {
+	// Create adds a new organization and makes the user its owner.
+	// ctx: The context for the operation.
+	// owner: The user that creates the organization.
+	// org: The organization to add.
+	// Returns the organization and an error if the operation fails.
+	Create(ctx context.Context, owner entities.User, org entities.Organization) (entities.Organization, error)
+
+	// ListForUser retrieves the organizations the user is a member of.
+	// ctx: The context for the operation.
+	// userID: The ID of the user.
+	// Returns the organizations and an error if the operation fails.
+	ListForUser(ctx context.Context, userID uuid.UUID) ([]entities.Organization, error)
+
+	// Resolve retrieves an organization by its ID or slug.
+	// ctx: The context for the operation.
+	// ref: The ID or slug of the organization.
+	// Returns the organization and an error if the operation fails.
+	Resolve(ctx context.Context, ref string) (entities.Organization, error)
+
+	// Membership retrieves the membership of a user in an organization.
+	// ctx: The context for the operation.
+	// orgID: The ID of the organization.
+	// userID: The ID of the user.
+	// Returns the membership and an error if the user is not a member.
+	Membership(ctx context.Context, orgID, userID uuid.UUID) (entities.Membership, error)
+
+	// Switch binds the current bearer token of the user to an organization the user is a member of.
+	// ctx: The context for the operation.
+	// user: The authenticated user.
+	// orgID: The ID of the organization.
+	// Returns an error if the operation fails.
+	Switch(ctx context.Context, user entities.User, orgID uuid.UUID) error
+
+	// Members retrieves the members of the organization in the context.
+	// ctx: The context for the operation.
+	// Returns the memberships and an error if the operation fails.
+	Members(ctx context.Context) ([]entities.Membership, error)
+
+	// SetRole changes the role of a member of the organization in the context.
+	// ctx: The context for the operation.
+	// actor: The membership of the user performing the operation.
+	// userID: The ID of the member.
+	// role: The new role of the member.
+	// Returns the updated membership and an error if the operation fails.
+	SetRole(ctx context.Context, actor entities.Membership, userID uuid.UUID, role string) (entities.Membership, error)
+
+	// RemoveMember removes a member from the organization in the context. Members can always remove themselves.
+	// ctx: The context for the operation.
+	// actor: The membership of the user performing the operation.
+	// userID: The ID of the member.
+	// Returns an error if the operation fails.
+	RemoveMember(ctx context.Context, actor entities.Membership, userID uuid.UUID) error
+}
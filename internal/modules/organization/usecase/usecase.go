@@ -0,0 +1,219 @@
+// Package usecase provides the functionality to interact with organizations and their members.
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/organization"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"strings"
+)
+
+// OrganizationUseCase struct represents an organization use case that provides methods for organization operations.
+type OrganizationUseCase struct {
+	cfg         *config.Config
+	orgs        storage.OrganizationRepository
+	memberships storage.MembershipRepository
+	users       storage.UserRepository
+	tx          storage.Transactor
+}
+
+// NewOrganizationUC creates a new organization use case with the provided configuration and repositories.
+// cfg: The configuration for the organization use case.
+// orgs: The organization repository.
+// memberships: The membership repository.
+// users: The user repository, used to bind bearer tokens to organizations.
+// tx: The transactor that adds an organization and its owner atomically.
+// Returns an organization.UseCase object.
+func NewOrganizationUC(cfg *config.Config, orgs storage.OrganizationRepository, memberships storage.MembershipRepository, users storage.UserRepository, tx storage.Transactor) organization.UseCase {
+	return &OrganizationUseCase{
+		cfg:         cfg,
+		orgs:        orgs,
+		memberships: memberships,
+		users:       users,
+		tx:          tx,
+	}
+}
+
+// Create adds a new organization and makes the user its owner.
+// ctx: The context for the operation.
+// owner: The user that creates the organization.
+// org: The organization to add.
+// Returns the organization and an error if the operation fails.
+func (uc OrganizationUseCase) Create(ctx context.Context, owner entities.User, org entities.Organization) (entities.Organization, error) {
+	org.Slug = strings.ToLower(strings.TrimSpace(org.Slug))
+	if err := validator.New().Struct(org); err != nil {
+		return entities.Organization{}, fmt.Errorf("invalid organization: %w", err)
+	}
+
+	// The organization and the membership of its owner are added together, so that an organization never lacks its owner.
+	org.ID = uuid.New()
+	err := uc.tx.WithTx(ctx, func(ctx context.Context) error {
+		_, err := uc.orgs.ReadBySlug(ctx, org.Slug)
+		if err == nil {
+			return organization.ErrSlugTaken
+		}
+		if !errors.Is(err, database.ErrNotFound) {
+			return err
+		}
+		if err := uc.orgs.Create(ctx, org); err != nil {
+			if errors.Is(err, database.ErrConflict) {
+				return organization.ErrSlugTaken
+			}
+			return err
+		}
+		membership := entities.Membership{
+			ID:     uuid.New(),
+			UserID: owner.ID,
+			Role:   entities.RoleOwner,
+		}
+		return uc.memberships.Create(database.WithTenant(ctx, org.ID), membership)
+	})
+	if err != nil {
+		return entities.Organization{}, err
+	}
+	return org, nil
+}
+
+// ListForUser retrieves the organizations the user is a member of.
+// ctx: The context for the operation.
+// userID: The ID of the user.
+// Returns the organizations and an error if the operation fails.
+func (uc OrganizationUseCase) ListForUser(ctx context.Context, userID uuid.UUID) ([]entities.Organization, error) {
+	memberships, err := uc.memberships.ReadByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uuid.UUID, 0, len(memberships))
+	for _, membership := range memberships {
+		ids = append(ids, membership.OrganizationID)
+	}
+	return uc.orgs.ReadMany(ctx, ids)
+}
+
+// Resolve retrieves an organization by its ID or slug.
+// ctx: The context for the operation.
+// ref: The ID or slug of the organization.
+// Returns the organization and an error if the operation fails.
+func (uc OrganizationUseCase) Resolve(ctx context.Context, ref string) (entities.Organization, error) {
+	if id, err := uuid.Parse(ref); err == nil {
+		return uc.orgs.Read(ctx, id)
+	}
+	return uc.orgs.ReadBySlug(ctx, strings.ToLower(ref))
+}
+
+// Membership retrieves the membership of a user in an organization.
+// ctx: The context for the operation.
+// orgID: The ID of the organization.
+// userID: The ID of the user.
+// Returns the membership and an error if the user is not a member.
+func (uc OrganizationUseCase) Membership(ctx context.Context, orgID, userID uuid.UUID) (entities.Membership, error) {
+	return uc.memberships.Read(database.WithTenant(ctx, orgID), userID)
+}
+
+// Switch binds the current bearer token of the user to an organization the user is a member of.
+// ctx: The context for the operation.
+// user: The authenticated user.
+// orgID: The ID of the organization.
+// Returns an error if the operation fails.
+func (uc OrganizationUseCase) Switch(ctx context.Context, user entities.User, orgID uuid.UUID) error {
+	if _, err := uc.Membership(ctx, orgID, user.ID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return organization.ErrForbidden
+		}
+		return err
+	}
+	user.TokenOrganizationID = orgID
+	return uc.users.Update(ctx, user)
+}
+
+// Members retrieves the members of the organization in the context.
+// ctx: The context for the operation.
+// Returns the memberships and an error if the operation fails.
+func (uc OrganizationUseCase) Members(ctx context.Context) ([]entities.Membership, error) {
+	return uc.memberships.ReadAll(ctx)
+}
+
+// SetRole changes the role of a member of the organization in the context.
+// Admins can change the roles of members and admins, and only owners can grant or revoke the owner role.
+// ctx: The context for the operation.
+// actor: The membership of the user performing the operation.
+// userID: The ID of the member.
+// role: The new role of the member.
+// Returns the updated membership and an error if the operation fails.
+func (uc OrganizationUseCase) SetRole(ctx context.Context, actor entities.Membership, userID uuid.UUID, role string) (entities.Membership, error) {
+	if !entities.ValidRole(role) {
+		return entities.Membership{}, fmt.Errorf("unknown role %q", role)
+	}
+	if !entities.RoleAtLeast(actor.Role, entities.RoleAdmin) {
+		return entities.Membership{}, organization.ErrForbidden
+	}
+
+	membership, err := uc.memberships.Read(ctx, userID)
+	if err != nil {
+		return entities.Membership{}, err
+	}
+	if (membership.Role == entities.RoleOwner || role == entities.RoleOwner) && actor.Role != entities.RoleOwner {
+		return entities.Membership{}, organization.ErrForbidden
+	}
+	if membership.Role == entities.RoleOwner && role != entities.RoleOwner {
+		if err := uc.ensureAnotherOwner(ctx, membership); err != nil {
+			return entities.Membership{}, err
+		}
+	}
+
+	membership.Role = role
+	if err := uc.memberships.Update(ctx, membership); err != nil {
+		return entities.Membership{}, err
+	}
+	return membership, nil
+}
+
+// RemoveMember removes a member from the organization in the context. Members can always remove themselves.
+// ctx: The context for the operation.
+// actor: The membership of the user performing the operation.
+// userID: The ID of the member.
+// Returns an error if the operation fails.
+func (uc OrganizationUseCase) RemoveMember(ctx context.Context, actor entities.Membership, userID uuid.UUID) error {
+	membership, err := uc.memberships.Read(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if actor.UserID != userID {
+		if !entities.RoleAtLeast(actor.Role, entities.RoleAdmin) {
+			return organization.ErrForbidden
+		}
+		if membership.Role == entities.RoleOwner && actor.Role != entities.RoleOwner {
+			return organization.ErrForbidden
+		}
+	}
+	if membership.Role == entities.RoleOwner {
+		if err := uc.ensureAnotherOwner(ctx, membership); err != nil {
+			return err
+		}
+	}
+	return uc.memberships.Delete(ctx, membership.ID)
+}
+
+// ensureAnotherOwner checks that the organization keeps an owner besides the given membership.
+// ctx: The context for the operation.
+// membership: The owner membership that is about to be demoted or removed.
+// Returns ErrLastOwner if the membership is the only owner.
+func (uc OrganizationUseCase) ensureAnotherOwner(ctx context.Context, membership entities.Membership) error {
+	members, err := uc.memberships.ReadAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		if member.ID != membership.ID && member.Role == entities.RoleOwner {
+			return nil
+		}
+	}
+	return organization.ErrLastOwner
+}
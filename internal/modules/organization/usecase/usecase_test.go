@@ -0,0 +1,54 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/organization"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/membership"
+	orgstorage "github.com/nikita-voronoy/go-clean-arch/internal/storage/organization"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/user"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/memory"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingMemberships is a membership repository whose creations fail.
+type failingMemberships struct {
+	storage.MembershipRepository
+}
+
+func (failingMemberships) Create(ctx context.Context, model entities.Membership) error {
+	return errors.New("disk full")
+}
+
+func TestCreate(t *testing.T) {
+	db := database.NewTenantDatabase(memory.NewDatabase(), "organization_id")
+	orgs := orgstorage.NewOrganizationRepository(db)
+	memberships := membership.NewMembershipRepository(db)
+	ctx := context.Background()
+	owner := entities.User{ID: uuid.New()}
+
+	failing := NewOrganizationUC(&config.Config{}, orgs, failingMemberships{memberships}, user.NewUserRepository(db), storage.NewTransactor(db))
+	_, err := failing.Create(ctx, owner, entities.Organization{Name: "Acme", Slug: "acme"})
+	require.Error(t, err)
+	_, err = orgs.ReadBySlug(ctx, "acme")
+	assert.ErrorIs(t, err, database.ErrNotFound, "an organization whose owner cannot be added is not added")
+
+	uc := NewOrganizationUC(&config.Config{}, orgs, memberships, user.NewUserRepository(db), storage.NewTransactor(db))
+	org, err := uc.Create(ctx, owner, entities.Organization{Name: "Acme", Slug: " ACME "})
+	require.NoError(t, err, "the slug of the failed organization is free")
+	assert.Equal(t, "acme", org.Slug)
+	stored, err := memberships.Read(database.WithTenant(ctx, org.ID), owner.ID)
+	require.NoError(t, err)
+	assert.Equal(t, entities.RoleOwner, stored.Role)
+
+	_, err = uc.Create(ctx, owner, entities.Organization{Name: "Other", Slug: "acme"})
+	assert.ErrorIs(t, err, organization.ErrSlugTaken)
+}
@@ -0,0 +1,49 @@
+// Package broker provides the brokers the domain events of the outbox are published to.
+package broker
+
+import (
+	"context"
+	"errors"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/outbox"
+)
+
+// Fanout struct represents a publisher that publishes the domain events to several publishers, e.g. a broker and the webhooks.
+// A message that one of them fails to accept is published again to all of them, which tolerate the duplicates.
+type Fanout struct {
+	publishers []outbox.Publisher
+}
+
+// NewFanout creates a new publisher that publishes the domain events to every publisher.
+// publishers: The publishers to publish to. The nil publishers are skipped.
+// Returns the only publisher if there is a single one, nil if there is none, and a *Fanout object otherwise.
+func NewFanout(publishers ...outbox.Publisher) outbox.Publisher {
+	var fanout Fanout
+	for _, publisher := range publishers {
+		if publisher != nil {
+			fanout.publishers = append(fanout.publishers, publisher)
+		}
+	}
+	switch len(fanout.publishers) {
+	case 0:
+		return nil
+	case 1:
+		return fanout.publishers[0]
+	default:
+		return &fanout
+	}
+}
+
+// Publish publishes a message to every publisher.
+// ctx: The context for the operation.
+// message: The message to publish.
+// Returns the errors of the publishers that did not accept the message.
+func (f *Fanout) Publish(ctx context.Context, message entities.OutboxMessage) error {
+	var errs []error
+	for _, publisher := range f.publishers {
+		if err := publisher.Publish(ctx, message); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
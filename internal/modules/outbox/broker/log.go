@@ -0,0 +1,27 @@
+// Package broker provides the brokers the domain events of the outbox are published to.
+package broker
+
+import (
+	"context"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/outbox"
+	"log"
+)
+
+// LogPublisher struct represents a broker that writes the domain events to the log, for the deployments without a broker.
+type LogPublisher struct{}
+
+// NewLogPublisher creates a new publisher that writes the domain events to the log.
+// Returns an outbox.Publisher object.
+func NewLogPublisher() outbox.Publisher {
+	return LogPublisher{}
+}
+
+// Publish writes a message to the log.
+// ctx: The context for the operation.
+// message: The message to write.
+// Returns nil.
+func (LogPublisher) Publish(_ context.Context, message entities.OutboxMessage) error {
+	log.Printf("Published event %d %s of %s %s: %s\n", message.ID, message.Type, message.AggregateType, message.AggregateID, message.Payload)
+	return nil
+}
@@ -0,0 +1,43 @@
+// Package broker provides a broker that publishes the domain events of the outbox to NATS JetStream.
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/outbox"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/messaging"
+	"strconv"
+)
+
+// eventVersion is the version of the schema of the payloads of the domain events, which an incompatible change increments.
+const eventVersion = 1
+
+// NATSPublisher struct represents a broker that publishes the domain events to the subjects of their types in NATS JetStream.
+// The ID of an event is the sequence number of its message, so that the stream drops the events the relay publishes again.
+type NATSPublisher struct {
+	client *messaging.Client
+}
+
+// NewNATSPublisher creates a new publisher of the domain events to NATS JetStream.
+// client: The client of the NATS server.
+// Returns an outbox.Publisher object.
+func NewNATSPublisher(client *messaging.Client) outbox.Publisher {
+	return NATSPublisher{client: client}
+}
+
+// Publish publishes a message in an envelope, and waits for the stream to store it.
+// ctx: The context for the operation.
+// message: The message to publish.
+// Returns an error if the stream did not store the message.
+func (p NATSPublisher) Publish(ctx context.Context, message entities.OutboxMessage) error {
+	return p.client.Publish(ctx, messaging.Envelope{
+		ID:            strconv.FormatUint(message.ID, 10),
+		Type:          message.Type,
+		Version:       eventVersion,
+		AggregateType: message.AggregateType,
+		AggregateID:   message.AggregateID,
+		OccurredAt:    message.CreatedAt,
+		Data:          json.RawMessage(message.Payload),
+	})
+}
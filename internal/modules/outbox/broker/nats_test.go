@@ -0,0 +1,51 @@
+package broker
+
+import (
+	"context"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/messaging"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNATSPublisher(t *testing.T) {
+	client, err := messaging.Connect(config.NATSConfig{Mode: "embedded", Stream: "EVENTS", SubjectPrefix: "events", Encoding: "protobuf", Source: "test", DuplicateWindowSeconds: 60})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	message, err := entities.NewOutboxMessage(entities.AggregateUser, "alice", entities.EventUserRegistered, entities.UserEvent{Username: "alice"})
+	require.NoError(t, err)
+	message.ID = 7
+	publisher := NewNATSPublisher(client)
+	require.NoError(t, publisher.Publish(ctx, message))
+	require.NoError(t, publisher.Publish(ctx, message), "the relay may publish a message again")
+
+	received := make(chan messaging.Envelope, 2)
+	_, err = client.Consume(ctx, messaging.ConsumerConfig{Name: "test", Types: []string{"user.registered"}}, func(_ context.Context, envelope messaging.Envelope) error {
+		received <- envelope
+		return nil
+	})
+	require.NoError(t, err)
+
+	envelope := <-received
+	assert.Equal(t, "7", envelope.ID)
+	assert.Equal(t, entities.EventUserRegistered, envelope.Type)
+	assert.Equal(t, eventVersion, envelope.Version)
+	assert.Equal(t, "alice", envelope.AggregateID)
+	assert.True(t, message.CreatedAt.Equal(envelope.OccurredAt))
+	event, err := messaging.Decode[entities.UserEvent](envelope)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", event.Username)
+
+	select {
+	case duplicate := <-received:
+		t.Fatalf("the duplicate %s was delivered", duplicate.ID)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
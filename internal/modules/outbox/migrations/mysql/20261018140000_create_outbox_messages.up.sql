@@ -0,0 +1,17 @@
+-- The outbox of the domain events, which are appended in the transaction of the change they describe and relayed to the broker.
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    id bigint unsigned NOT NULL AUTO_INCREMENT,
+    aggregate_type longtext NOT NULL,
+    aggregate_id longtext NOT NULL,
+    type longtext NOT NULL,
+    payload longtext,
+    published tinyint(1) NOT NULL DEFAULT '0',
+    attempts bigint NOT NULL DEFAULT '0',
+    last_error longtext,
+    next_attempt_at datetime(6) NOT NULL,
+    created_at datetime(6) NULL,
+    updated_at datetime(6) NULL,
+    version bigint NOT NULL DEFAULT 1,
+    PRIMARY KEY (id),
+    KEY idx_outbox_messages_published (published)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
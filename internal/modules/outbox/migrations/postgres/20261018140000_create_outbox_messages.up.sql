@@ -0,0 +1,16 @@
+-- The outbox of the domain events, which are appended in the transaction of the change they describe and relayed to the broker.
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    id bigserial PRIMARY KEY,
+    aggregate_type text NOT NULL,
+    aggregate_id text NOT NULL,
+    type text NOT NULL,
+    payload text,
+    published boolean NOT NULL DEFAULT false,
+    attempts bigint NOT NULL DEFAULT 0,
+    last_error text,
+    next_attempt_at timestamptz NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    version bigint NOT NULL DEFAULT 1
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_published ON outbox_messages (published);
@@ -0,0 +1,16 @@
+-- The outbox of the domain events, which are appended in the transaction of the change they describe and relayed to the broker.
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    aggregate_type text NOT NULL,
+    aggregate_id text NOT NULL,
+    type text NOT NULL,
+    payload text,
+    published numeric NOT NULL DEFAULT false,
+    attempts integer NOT NULL DEFAULT 0,
+    last_error text,
+    next_attempt_at datetime NOT NULL,
+    created_at datetime,
+    updated_at datetime,
+    version integer NOT NULL DEFAULT 1
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_published ON outbox_messages (published);
@@ -0,0 +1,2 @@
+-- The messages that failed to be published too many times, which are kept in the outbox but no longer relayed.
+ALTER TABLE outbox_messages ADD COLUMN dead numeric NOT NULL DEFAULT false;
@@ -0,0 +1,148 @@
+// Package module provides the functionality to interact with the outbox module.
+package module
+
+import (
+	"context"                                                                       // Context package provides the functionality to pass deadlines and cancel signals to the workers.
+	"fmt"                                                                           // Fmt package provides the functionality to format the errors of the configuration.
+	"github.com/nikita-voronoy/go-clean-arch/config"                                // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/outbox"               // Outbox package provides the functionality to relay the domain events of the outbox to a broker.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/outbox/broker"        // Broker package provides the brokers the domain events are published to.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/outbox/migrations"    // Migrations package provides the SQL migrations of the schema of the outbox module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/outbox/usecase"       // Usecase package provides the functionality to relay the domain events of the outbox.
+	outboxstorage "github.com/nikita-voronoy/go-clean-arch/internal/storage/outbox" // Outbox storage package provides the functionality to interact with the outbox message storage.
+	"github.com/nikita-voronoy/go-clean-arch/pkg/messaging"                         // Messaging package provides the client of NATS JetStream.
+	"go.uber.org/fx"                                                                // Fx is a framework for Go that provides the building blocks for your service architectures.
+	"log"                                                                           // Log package provides the functionality to implement logging.
+	"time"                                                                          // Time package provides the functionality to schedule the workers.
+)
+
+// retentionInterval is the interval at which the published messages that fall out of the retention window are purged.
+const retentionInterval = time.Hour
+
+// Migrations is a Fx option that registers the SQL migrations of the outbox module with the migrations group.
+var Migrations = fx.Supply(fx.Annotated{Group: "migrations", Target: migrations.Source})
+
+// Module is a Fx options group that provides and invokes the necessary dependencies for the outbox module.
+var Module = fx.Options(
+	Migrations, // Registers the SQL migrations of the outbox module.
+	fx.Provide(
+		outboxstorage.NewOutboxRepository, // Provides a new outbox repository, which the repositories append their events to.
+		newPublisher,                      // Provides the configured broker, along with the subscribers of the other modules.
+		usecase.NewOutboxUC,               // Provides a new outbox use case.
+	),
+	fx.Invoke(registerRelay),     // Invokes the function to register the relay worker.
+	fx.Invoke(registerRetention), // Invokes the function to register the retention worker.
+)
+
+// Subscribers struct represents the publishers the other modules register with the outbox_subscribers group,
+// which receive the domain events along with the broker.
+type Subscribers struct {
+	fx.In
+
+	Publishers []outbox.Publisher `group:"outbox_subscribers"` // The publishers of the modules, which are nil if they are disabled.
+}
+
+// newPublisher creates the configured broker, and fans the domain events out to it and to the subscribers of the other modules.
+// cfg: The configuration that contains the broker.
+// client: The client of the NATS server, which is nil if none is configured.
+// subscribers: The publishers of the other modules.
+// Returns the publisher, which is nil if the broker is "none" and no module subscribes, and an error if the broker is unknown or needs a server that is not configured.
+func newPublisher(cfg *config.Config, client *messaging.Client, subscribers Subscribers) (outbox.Publisher, error) {
+	var publisher outbox.Publisher
+	switch cfg.Outbox.Broker {
+	case "", "log":
+		publisher = broker.NewLogPublisher()
+	case "nats":
+		if client == nil {
+			return nil, fmt.Errorf("the nats outbox broker needs a nats server, which the nats mode configures")
+		}
+		publisher = broker.NewNATSPublisher(client)
+	case "none":
+	default:
+		return nil, fmt.Errorf("unknown outbox broker %q", cfg.Outbox.Broker)
+	}
+	return broker.NewFanout(append([]outbox.Publisher{publisher}, subscribers.Publishers...)...), nil
+}
+
+// registerRelay starts a worker that publishes the messages of the outbox. It relays again at once while it finds
+// full batches, and otherwise waits for the configured interval.
+// The worker does not run if the messages are published nowhere.
+// lc: The lifecycle the worker is bound to.
+// cfg: The configuration that contains the interval.
+// publisher: The publisher the messages are relayed to.
+// uc: The outbox use case used to relay the messages.
+func registerRelay(lc fx.Lifecycle, cfg *config.Config, publisher outbox.Publisher, uc outbox.UseCase) {
+	if publisher == nil {
+		return
+	}
+	interval := time.Duration(cfg.Outbox.IntervalMilliseconds) * time.Millisecond
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	runWorker(lc, func(ctx context.Context) time.Duration {
+		published, err := uc.Relay(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to relay outbox messages: %v\n", err)
+			}
+			return interval
+		}
+		if cfg.Outbox.BatchSize > 0 && published >= cfg.Outbox.BatchSize {
+			return 0
+		}
+		return interval
+	})
+}
+
+// registerRetention starts a worker that periodically purges the published messages older than the configured retention.
+// lc: The lifecycle the worker is bound to.
+// cfg: The configuration that contains the retention.
+// uc: The outbox use case used to purge the messages.
+func registerRetention(lc fx.Lifecycle, cfg *config.Config, uc outbox.UseCase) {
+	if cfg.Outbox.RetentionHours <= 0 {
+		return
+	}
+
+	runWorker(lc, func(ctx context.Context) time.Duration {
+		if removed, err := uc.Purge(ctx); err != nil {
+			log.Printf("Failed to purge outbox messages: %v\n", err)
+		} else if removed > 0 {
+			log.Printf("Purged %d outbox messages\n", removed)
+		}
+		return retentionInterval
+	})
+}
+
+// runWorker runs a task in the background while the application runs, each time after the delay the previous run returned.
+func runWorker(lc fx.Lifecycle, task func(ctx context.Context) time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				timer := time.NewTimer(0)
+				defer timer.Stop()
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-timer.C:
+					}
+					timer.Reset(task(ctx))
+				}
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return stopCtx.Err()
+			}
+		},
+	})
+}
@@ -0,0 +1,31 @@
+// Package outbox provides the functionality to relay the domain events of the outbox to a broker.
+package outbox
+
+import (
+	"context"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+)
+
+// Publisher is an interface that defines the method required to deliver the domain events to a broker.
+type Publisher interface {
+	// Publish delivers a message to the broker. A message may be published more than once, e.g. if the relay stops
+	// before it records the publication, so the consumers must tolerate duplicates, which share the ID of the message.
+	// ctx: The context for the operation.
+	// message: The message to deliver.
+	// Returns an error if the broker did not accept the message, in which case it is published again later.
+	Publish(ctx context.Context, message entities.OutboxMessage) error
+}
+
+// UseCase is an interface that defines the methods required for outbox operations.
+type UseCase interface {
+	// Relay publishes the messages of the outbox that are due, in sequence order for each aggregate.
+	// A message that fails to be published is retried later, and holds back the following messages of its aggregate.
+	// ctx: The context for the operation.
+	// Returns the number of published messages and an error if the outbox cannot be read or written.
+	Relay(ctx context.Context) (int, error)
+
+	// Purge removes the published messages that are older than the configured retention.
+	// ctx: The context for the operation.
+	// Returns the number of removed messages and an error if the operation fails.
+	Purge(ctx context.Context) (int64, error)
+}
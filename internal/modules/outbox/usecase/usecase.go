@@ -0,0 +1,135 @@
+// Package usecase provides the functionality to relay the domain events of the outbox.
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/outbox"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/attempt"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"time"
+)
+
+// maxErrorLength is the maximum length of the error of a failed attempt that is stored with the message.
+const maxErrorLength = 1000
+
+// OutboxUseCase struct represents an outbox use case that relays the messages of the outbox to a broker.
+// A relay claims a message with a lease before it publishes it, and retries it after a backoff, as described in package attempt.
+type OutboxUseCase struct {
+	cfg       *config.Config
+	repo      storage.OutboxRepository
+	publisher outbox.Publisher
+	now       func() time.Time
+}
+
+// NewOutboxUC creates a new outbox use case with the provided configuration, outbox repository and publisher.
+// cfg: The configuration for the outbox use case.
+// repo: The outbox repository for the outbox use case.
+// publisher: The broker the messages are published to. A nil publisher leaves the messages in the outbox.
+// Returns an outbox.UseCase object.
+func NewOutboxUC(cfg *config.Config, repo storage.OutboxRepository, publisher outbox.Publisher) outbox.UseCase {
+	return &OutboxUseCase{
+		cfg:       cfg,
+		repo:      repo,
+		publisher: publisher,
+		now:       time.Now,
+	}
+}
+
+// Relay publishes the messages of the outbox that are due, in sequence order for each aggregate.
+// A message that fails to be published is retried later, and holds back the following messages of its aggregate,
+// until it fails the configured number of times and is dead.
+// The outbox is read page by page until a batch of messages is attempted, so that the messages that are not due
+// or are held back do not keep the relay from the messages of the other aggregates.
+// ctx: The context for the operation.
+// Returns the number of published messages and an error if the outbox cannot be read or written.
+func (uc *OutboxUseCase) Relay(ctx context.Context) (int, error) {
+	if uc.publisher == nil {
+		return 0, nil
+	}
+
+	// The aggregates whose earlier messages are not published yet, whose later messages must wait.
+	blocked := make(map[string]bool)
+	published, attempted := 0, 0
+	var after uint64
+	for attempted < uc.cfg.Outbox.BatchSize {
+		messages, err := uc.repo.Pending(ctx, after, uc.cfg.Outbox.BatchSize)
+		if err != nil {
+			return published, err
+		}
+		for _, message := range messages {
+			after = message.ID
+			aggregate := message.AggregateType + "/" + message.AggregateID
+			if blocked[aggregate] {
+				continue
+			}
+			if message.NextAttemptAt.After(uc.now().UTC()) {
+				blocked[aggregate] = true
+				continue
+			}
+			attempted++
+			ok, err := uc.relay(ctx, message)
+			if err != nil {
+				return published, err
+			}
+			if ok {
+				published++
+			} else {
+				blocked[aggregate] = true
+			}
+			if attempted == uc.cfg.Outbox.BatchSize {
+				break
+			}
+		}
+		if len(messages) < uc.cfg.Outbox.BatchSize {
+			break
+		}
+	}
+	return published, nil
+}
+
+// Purge removes the published messages that are older than the configured retention.
+// ctx: The context for the operation.
+// Returns the number of removed messages and an error if the operation fails.
+func (uc *OutboxUseCase) Purge(ctx context.Context) (int64, error) {
+	if uc.cfg.Outbox.RetentionHours <= 0 {
+		return 0, nil
+	}
+	return uc.repo.DeletePublishedBefore(ctx, uc.now().Add(-time.Duration(uc.cfg.Outbox.RetentionHours)*time.Hour))
+}
+
+// relay claims and publishes a due message, and records the outcome.
+// Returns true if the message was published, and false if it was claimed by another relay or failed to be published.
+func (uc *OutboxUseCase) relay(ctx context.Context, message entities.OutboxMessage) (bool, error) {
+	now := uc.now().UTC()
+	message.NextAttemptAt = attempt.Lease(now, time.Duration(uc.cfg.Outbox.LeaseSeconds)*time.Second)
+	message, err := uc.repo.Save(ctx, message)
+	if errors.Is(err, database.ErrStale) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := uc.publisher.Publish(ctx, message); err != nil {
+		message.Attempts++
+		message.LastError = attempt.Truncate(err.Error(), maxErrorLength)
+		message.NextAttemptAt = uc.now().UTC().Add(attempt.Backoff(message.Attempts,
+			time.Duration(uc.cfg.Outbox.RetryBaseSeconds)*time.Second, time.Duration(uc.cfg.Outbox.RetryMaxSeconds)*time.Second))
+		message.Dead = message.Attempts >= uc.cfg.Outbox.MaxAttempts
+		if _, err := uc.repo.Save(ctx, message); err != nil && !errors.Is(err, database.ErrStale) {
+			return false, err
+		}
+		return false, nil
+	}
+
+	message.Published = true
+	message.LastError = ""
+	if _, err := uc.repo.Save(ctx, message); err != nil && !errors.Is(err, database.ErrStale) {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,197 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	outboxstorage "github.com/nikita-voronoy/go-clean-arch/internal/storage/outbox"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/memory"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingPublisher struct represents a broker that records the published messages, and fails for the aggregates it is told to.
+type recordingPublisher struct {
+	published []entities.OutboxMessage
+	failing   map[string]bool
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, message entities.OutboxMessage) error {
+	if p.failing[message.AggregateID] {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, message)
+	return nil
+}
+
+func (p *recordingPublisher) events() []string {
+	var events []string
+	for _, message := range p.published {
+		events = append(events, message.AggregateID+":"+message.Payload)
+	}
+	return events
+}
+
+func newTestUC(t *testing.T) (*OutboxUseCase, storage.OutboxRepository, *recordingPublisher, *time.Time) {
+	cfg := &config.Config{
+		Outbox: config.OutboxConfig{BatchSize: 10, LeaseSeconds: 30, RetryBaseSeconds: 1, RetryMaxSeconds: 5, MaxAttempts: 3, RetentionHours: 1},
+	}
+	repo := outboxstorage.NewOutboxRepository(memory.NewDatabase())
+	publisher := &recordingPublisher{failing: map[string]bool{}}
+	now := time.Now().UTC()
+	uc := NewOutboxUC(cfg, repo, publisher).(*OutboxUseCase)
+	uc.now = func() time.Time { return now }
+	return uc, repo, publisher, &now
+}
+
+func appendMessages(t *testing.T, repo storage.OutboxRepository, messages ...string) {
+	for _, message := range messages {
+		aggregate, payload := message[:1], message[2:]
+		stored, err := entities.NewOutboxMessage(entities.AggregateUser, aggregate, entities.EventUserRegistered, payload)
+		require.NoError(t, err)
+		stored.NextAttemptAt = time.Now().UTC().Add(-time.Second)
+		_, err = repo.Append(context.Background(), stored)
+		require.NoError(t, err)
+	}
+}
+
+func TestRelayPublishesInOrder(t *testing.T) {
+	uc, repo, publisher, _ := newTestUC(t)
+	ctx := context.Background()
+	appendMessages(t, repo, "a:1", "b:1", "a:2")
+
+	published, err := uc.Relay(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, published)
+	assert.Equal(t, []string{`a:"1"`, `b:"1"`, `a:"2"`}, publisher.events())
+
+	published, err = uc.Relay(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, published, "a published message is not published again")
+}
+
+func TestRelayRetriesFailures(t *testing.T) {
+	uc, repo, publisher, now := newTestUC(t)
+	ctx := context.Background()
+	appendMessages(t, repo, "a:1", "b:1", "a:2")
+	publisher.failing["a"] = true
+
+	published, err := uc.Relay(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, []string{`b:"1"`}, publisher.events(), "a failed message holds back the later messages of its aggregate only")
+
+	pending, err := repo.Pending(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, "broker unavailable", pending[0].LastError)
+	assert.Equal(t, now.Add(time.Second), pending[0].NextAttemptAt, "the first retry waits for the base delay")
+
+	*now = now.Add(time.Second)
+	_, err = uc.Relay(ctx)
+	require.NoError(t, err)
+	pending, err = repo.Pending(ctx, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(2*time.Second), pending[0].NextAttemptAt, "the delay doubles with every failure")
+
+	publisher.failing["a"] = false
+	published, err = uc.Relay(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, published, "a message is not retried before its delay")
+
+	*now = now.Add(2 * time.Second)
+	published, err = uc.Relay(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+	assert.Equal(t, []string{`b:"1"`, `a:"1"`, `a:"2"`}, publisher.events())
+}
+
+func TestRelayReadsPastHeldBackMessages(t *testing.T) {
+	uc, repo, publisher, _ := newTestUC(t)
+	ctx := context.Background()
+	uc.cfg.Outbox.BatchSize = 2
+	appendMessages(t, repo, "a:1", "a:2", "a:3", "a:4", "a:5", "b:1", "b:2")
+	publisher.failing["a"] = true
+
+	published, err := uc.Relay(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published, "a batch is two attempts, of which the failed one")
+	assert.Equal(t, []string{`b:"1"`}, publisher.events(), "the messages held back do not fill the batch")
+
+	published, err = uc.Relay(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published, "the message that is not due yet holds back its aggregate without an attempt")
+	assert.Equal(t, []string{`b:"1"`, `b:"2"`}, publisher.events())
+}
+
+func TestRelayGivesUpOnDeadMessages(t *testing.T) {
+	uc, repo, publisher, now := newTestUC(t)
+	ctx := context.Background()
+	appendMessages(t, repo, "a:1", "a:2")
+	publisher.failing["a"] = true
+
+	for attempt := 1; attempt <= uc.cfg.Outbox.MaxAttempts; attempt++ {
+		_, err := uc.Relay(ctx)
+		require.NoError(t, err)
+		*now = now.Add(time.Minute)
+	}
+	pending, err := repo.Pending(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1, "a message that failed the maximum number of times is dead")
+	assert.Equal(t, `"2"`, pending[0].Payload)
+	assert.Zero(t, pending[0].Attempts, "the later messages were held back until then")
+
+	publisher.failing["a"] = false
+	published, err := uc.Relay(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published, "a dead message no longer holds back its aggregate")
+	assert.Equal(t, []string{`a:"2"`}, publisher.events())
+}
+
+func TestRelaySkipsClaimedMessages(t *testing.T) {
+	uc, repo, publisher, now := newTestUC(t)
+	ctx := context.Background()
+	appendMessages(t, repo, "a:1", "a:2")
+
+	// Another relay claims the first message, and stops before it records its publication.
+	pending, err := repo.Pending(ctx, 0, 1)
+	require.NoError(t, err)
+	claimed := pending[0]
+	claimed.NextAttemptAt = now.Add(30 * time.Second)
+	_, err = repo.Save(ctx, claimed)
+	require.NoError(t, err)
+
+	published, err := uc.Relay(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, published, "the messages of an aggregate wait for its claimed message")
+
+	*now = now.Add(31 * time.Second)
+	published, err = uc.Relay(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, published, "a message whose lease expired is published again")
+	assert.Equal(t, []string{`a:"1"`, `a:"2"`}, publisher.events())
+}
+
+func TestPurge(t *testing.T) {
+	uc, repo, _, now := newTestUC(t)
+	ctx := context.Background()
+	appendMessages(t, repo, "a:1", "b:1")
+	uc.publisher.(*recordingPublisher).failing["b"] = true
+
+	_, err := uc.Relay(ctx)
+	require.NoError(t, err)
+	removed, err := uc.Purge(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, removed, "the published messages are kept for the retention")
+
+	*now = now.Add(2 * time.Hour)
+	removed, err = uc.Purge(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed, "the pending messages are kept")
+}
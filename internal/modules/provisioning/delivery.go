@@ -0,0 +1,71 @@
+// Package provisioning provides the functionality to let identity providers provision users and groups over SCIM 2.0.
+package provisioning
+
+import "github.com/labstack/echo/v4"
+
+// Handlers is an interface that defines the methods required for handling SCIM operations.
+type Handlers interface {
+	// ListUsers handles the retrieval of the users.
+	// Returns an echo.HandlerFunc that handles the HTTP request for listing the users.
+	ListUsers() echo.HandlerFunc
+
+	// GetUser handles the retrieval of a user.
+	// Returns an echo.HandlerFunc that handles the HTTP request for retrieving a user.
+	GetUser() echo.HandlerFunc
+
+	// CreateUser handles the provisioning of a user.
+	// Returns an echo.HandlerFunc that handles the HTTP request for provisioning a user.
+	CreateUser() echo.HandlerFunc
+
+	// ReplaceUser handles the replacement of a user.
+	// Returns an echo.HandlerFunc that handles the HTTP request for replacing a user.
+	ReplaceUser() echo.HandlerFunc
+
+	// PatchUser handles the modification of a user.
+	// Returns an echo.HandlerFunc that handles the HTTP request for modifying a user.
+	PatchUser() echo.HandlerFunc
+
+	// DeleteUser handles the deprovisioning of a user.
+	// Returns an echo.HandlerFunc that handles the HTTP request for deprovisioning a user.
+	DeleteUser() echo.HandlerFunc
+
+	// ListGroups handles the retrieval of the groups.
+	// Returns an echo.HandlerFunc that handles the HTTP request for listing the groups.
+	ListGroups() echo.HandlerFunc
+
+	// GetGroup handles the retrieval of a group.
+	// Returns an echo.HandlerFunc that handles the HTTP request for retrieving a group.
+	GetGroup() echo.HandlerFunc
+
+	// CreateGroup handles the provisioning of a group.
+	// Returns an echo.HandlerFunc that handles the HTTP request for provisioning a group.
+	CreateGroup() echo.HandlerFunc
+
+	// ReplaceGroup handles the replacement of a group.
+	// Returns an echo.HandlerFunc that handles the HTTP request for replacing a group.
+	ReplaceGroup() echo.HandlerFunc
+
+	// PatchGroup handles the modification of a group.
+	// Returns an echo.HandlerFunc that handles the HTTP request for modifying a group.
+	PatchGroup() echo.HandlerFunc
+
+	// DeleteGroup handles the deprovisioning of a group.
+	// Returns an echo.HandlerFunc that handles the HTTP request for deprovisioning a group.
+	DeleteGroup() echo.HandlerFunc
+
+	// ServiceProviderConfig handles the retrieval of the features of the service provider.
+	// Returns an echo.HandlerFunc that handles the HTTP request for the service provider configuration.
+	ServiceProviderConfig() echo.HandlerFunc
+
+	// Schemas handles the retrieval of the schemas.
+	// Returns an echo.HandlerFunc that handles the HTTP request for listing the schemas.
+	Schemas() echo.HandlerFunc
+
+	// Schema handles the retrieval of a schema.
+	// Returns an echo.HandlerFunc that handles the HTTP request for retrieving a schema.
+	Schema() echo.HandlerFunc
+
+	// ResourceTypes handles the retrieval of the resource types.
+	// Returns an echo.HandlerFunc that handles the HTTP request for listing the resource types.
+	ResourceTypes() echo.HandlerFunc
+}
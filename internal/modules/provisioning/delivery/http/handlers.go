@@ -0,0 +1,463 @@
+// Package http provides the functionality to handle HTTP requests for the provisioning module.
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"                                           // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/config"                        // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/app"                  // App package provides the functionality to map the errors of the storage layer to HTTP status codes.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/provisioning" // Provisioning package provides the functionality to interact with the provisioning module.
+	"github.com/nikita-voronoy/go-clean-arch/pkg/scim"                      // SCIM package provides the functionality to speak the SCIM 2.0 protocol.
+	"net/http"
+)
+
+// ProvisioningHandlers struct represents provisioning handlers that provide methods for handling SCIM requests.
+type ProvisioningHandlers struct {
+	cfg            *config.Config       // The configuration for the provisioning handlers.
+	provisioningUC provisioning.UseCase // The provisioning use case for the provisioning handlers.
+}
+
+// NewProvisioningHandlers creates new provisioning handlers with the provided configuration and provisioning use case.
+// cfg: The configuration for the provisioning handlers.
+// provisioningUC: The provisioning use case for the provisioning handlers.
+// Returns a ProvisioningHandlers object.
+func NewProvisioningHandlers(cfg *config.Config, provisioningUC provisioning.UseCase) *ProvisioningHandlers {
+	return &ProvisioningHandlers{
+		cfg:            cfg,
+		provisioningUC: provisioningUC,
+	}
+}
+
+// ListUsers retrieves the users matching the filter, one page at a time.
+// @route GET /scim/v2/Users
+// @group SCIM
+// @param {string} filter.query - SCIM filter expression
+// @param {integer} startIndex.query - 1-based index of the first user
+// @param {integer} count.query - Maximum number of users
+// @returns {object} 200 - A list response of users
+// @returns {object} 400 - The filter is invalid.
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 500 - Server error
+func (h *ProvisioningHandlers) ListUsers() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		query, err := parseQuery(c)
+		if err != nil {
+			return fail(c, "list users", err)
+		}
+		users, total, err := h.provisioningUC.ListUsers(c.Request().Context(), query)
+		if err != nil {
+			return fail(c, "list users", err)
+		}
+		for i := range users {
+			locateUser(c, &users[i])
+		}
+		return respond(c, http.StatusOK, scim.NewListResponse(users, len(users), total, query.StartIndex))
+	}
+}
+
+// GetUser retrieves a user.
+// @route GET /scim/v2/Users/{id}
+// @group SCIM
+// @param {string} id.path.required - ID of the user
+// @returns {object} 200 - The user, with its version in the ETag header
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 404 - The user does not exist.
+// @returns {object} 500 - Server error
+func (h *ProvisioningHandlers) GetUser() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, err := h.provisioningUC.GetUser(c.Request().Context(), c.Param("id"))
+		if err != nil {
+			return fail(c, "get user", err)
+		}
+		locateUser(c, &user)
+		tag(c, user)
+		return respond(c, http.StatusOK, user)
+	}
+}
+
+// CreateUser provisions a user.
+// @route POST /scim/v2/Users
+// @group SCIM
+// @param {object} user.body.required - SCIM user
+// @returns {object} 201 - The provisioned user, with its version in the ETag header
+// @returns {object} 400 - The user is invalid.
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 409 - The userName or the email is already taken.
+// @returns {object} 500 - Server error
+func (h *ProvisioningHandlers) CreateUser() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var resource scim.User
+		if err := decode(c, &resource); err != nil {
+			return fail(c, "create user", err)
+		}
+		user, err := h.provisioningUC.CreateUser(c.Request().Context(), resource)
+		if err != nil {
+			return fail(c, "create user", err)
+		}
+		locateUser(c, &user)
+		tag(c, user)
+		c.Response().Header().Set(echo.HeaderLocation, user.Meta.Location)
+		return respond(c, http.StatusCreated, user)
+	}
+}
+
+// ReplaceUser replaces the attributes of a user.
+// @route PUT /scim/v2/Users/{id}
+// @group SCIM
+// @param {string} id.path.required - ID of the user
+// @param {object} user.body.required - SCIM user
+// @param {string} If-Match.header - ETag of the version of the user the replacement is based on
+// @returns {object} 200 - The updated user
+// @returns {object} 400 - The user is invalid.
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 404 - The user does not exist.
+// @returns {object} 409 - The userName or the email is already taken.
+// @returns {object} 412 - The user was modified since the version of the If-Match header.
+// @returns {object} 500 - Server error
+func (h *ProvisioningHandlers) ReplaceUser() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		version, err := ifMatch(c)
+		if err != nil {
+			return fail(c, "replace user", err)
+		}
+		var resource scim.User
+		if err := decode(c, &resource); err != nil {
+			return fail(c, "replace user", err)
+		}
+		user, err := h.provisioningUC.ReplaceUser(c.Request().Context(), c.Param("id"), resource, version)
+		if err != nil {
+			return fail(c, "replace user", err)
+		}
+		locateUser(c, &user)
+		tag(c, user)
+		return respond(c, http.StatusOK, user)
+	}
+}
+
+// PatchUser modifies a user.
+// @route PATCH /scim/v2/Users/{id}
+// @group SCIM
+// @param {string} id.path.required - ID of the user
+// @param {object} patch.body.required - SCIM PATCH request
+// @param {string} If-Match.header - ETag of the version of the user the operations are based on
+// @returns {object} 200 - The updated user
+// @returns {object} 400 - The operations are invalid.
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 404 - The user does not exist.
+// @returns {object} 409 - The userName or the email is already taken.
+// @returns {object} 412 - The user was modified since the version of the If-Match header.
+// @returns {object} 500 - Server error
+func (h *ProvisioningHandlers) PatchUser() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		version, err := ifMatch(c)
+		if err != nil {
+			return fail(c, "patch user", err)
+		}
+		var patch scim.PatchRequest
+		if err := decode(c, &patch); err != nil {
+			return fail(c, "patch user", err)
+		}
+		user, err := h.provisioningUC.PatchUser(c.Request().Context(), c.Param("id"), patch, version)
+		if err != nil {
+			return fail(c, "patch user", err)
+		}
+		locateUser(c, &user)
+		tag(c, user)
+		return respond(c, http.StatusOK, user)
+	}
+}
+
+// DeleteUser deprovisions a user.
+// @route DELETE /scim/v2/Users/{id}
+// @group SCIM
+// @param {string} id.path.required - ID of the user
+// @param {string} If-Match.header - ETag of the version of the user the deletion is based on
+// @returns {object} 204 - The user was deprovisioned.
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 404 - The user does not exist.
+// @returns {object} 412 - The user was modified since the version of the If-Match header.
+// @returns {object} 500 - Server error
+func (h *ProvisioningHandlers) DeleteUser() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		version, err := ifMatch(c)
+		if err != nil {
+			return fail(c, "delete user", err)
+		}
+		if err := h.provisioningUC.DeleteUser(c.Request().Context(), c.Param("id"), version); err != nil {
+			return fail(c, "delete user", err)
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// ListGroups retrieves the groups matching the filter, one page at a time.
+// @route GET /scim/v2/Groups
+// @group SCIM
+// @param {string} filter.query - SCIM filter expression
+// @param {integer} startIndex.query - 1-based index of the first group
+// @param {integer} count.query - Maximum number of groups
+// @returns {object} 200 - A list response of groups
+// @returns {object} 400 - The filter is invalid.
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 500 - Server error
+func (h *ProvisioningHandlers) ListGroups() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		query, err := parseQuery(c)
+		if err != nil {
+			return fail(c, "list groups", err)
+		}
+		groups, total, err := h.provisioningUC.ListGroups(c.Request().Context(), query)
+		if err != nil {
+			return fail(c, "list groups", err)
+		}
+		for i := range groups {
+			locateGroup(c, &groups[i])
+		}
+		return respond(c, http.StatusOK, scim.NewListResponse(groups, len(groups), total, query.StartIndex))
+	}
+}
+
+// GetGroup retrieves a group.
+// @route GET /scim/v2/Groups/{id}
+// @group SCIM
+// @param {string} id.path.required - ID of the group
+// @returns {object} 200 - The group
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 404 - The group does not exist.
+// @returns {object} 500 - Server error
+func (h *ProvisioningHandlers) GetGroup() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		group, err := h.provisioningUC.GetGroup(c.Request().Context(), c.Param("id"))
+		if err != nil {
+			return fail(c, "get group", err)
+		}
+		locateGroup(c, &group)
+		return respond(c, http.StatusOK, group)
+	}
+}
+
+// CreateGroup provisions a group.
+// @route POST /scim/v2/Groups
+// @group SCIM
+// @param {object} group.body.required - SCIM group
+// @returns {object} 201 - The provisioned group
+// @returns {object} 400 - The group is invalid.
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 500 - Server error
+func (h *ProvisioningHandlers) CreateGroup() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var resource scim.Group
+		if err := decode(c, &resource); err != nil {
+			return fail(c, "create group", err)
+		}
+		group, err := h.provisioningUC.CreateGroup(c.Request().Context(), resource)
+		if err != nil {
+			return fail(c, "create group", err)
+		}
+		locateGroup(c, &group)
+		c.Response().Header().Set(echo.HeaderLocation, group.Meta.Location)
+		return respond(c, http.StatusCreated, group)
+	}
+}
+
+// ReplaceGroup replaces the attributes and the members of a group.
+// @route PUT /scim/v2/Groups/{id}
+// @group SCIM
+// @param {string} id.path.required - ID of the group
+// @param {object} group.body.required - SCIM group
+// @returns {object} 200 - The updated group
+// @returns {object} 400 - The group is invalid.
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 404 - The group does not exist.
+// @returns {object} 500 - Server error
+func (h *ProvisioningHandlers) ReplaceGroup() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var resource scim.Group
+		if err := decode(c, &resource); err != nil {
+			return fail(c, "replace group", err)
+		}
+		group, err := h.provisioningUC.ReplaceGroup(c.Request().Context(), c.Param("id"), resource)
+		if err != nil {
+			return fail(c, "replace group", err)
+		}
+		locateGroup(c, &group)
+		return respond(c, http.StatusOK, group)
+	}
+}
+
+// PatchGroup modifies a group.
+// @route PATCH /scim/v2/Groups/{id}
+// @group SCIM
+// @param {string} id.path.required - ID of the group
+// @param {object} patch.body.required - SCIM PATCH request
+// @returns {object} 200 - The updated group
+// @returns {object} 400 - The operations are invalid.
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 404 - The group does not exist.
+// @returns {object} 500 - Server error
+func (h *ProvisioningHandlers) PatchGroup() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var patch scim.PatchRequest
+		if err := decode(c, &patch); err != nil {
+			return fail(c, "patch group", err)
+		}
+		group, err := h.provisioningUC.PatchGroup(c.Request().Context(), c.Param("id"), patch)
+		if err != nil {
+			return fail(c, "patch group", err)
+		}
+		locateGroup(c, &group)
+		return respond(c, http.StatusOK, group)
+	}
+}
+
+// DeleteGroup deprovisions a group.
+// @route DELETE /scim/v2/Groups/{id}
+// @group SCIM
+// @param {string} id.path.required - ID of the group
+// @returns {object} 204 - The group was deprovisioned.
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 404 - The group does not exist.
+// @returns {object} 500 - Server error
+func (h *ProvisioningHandlers) DeleteGroup() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if err := h.provisioningUC.DeleteGroup(c.Request().Context(), c.Param("id")); err != nil {
+			return fail(c, "delete group", err)
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// ServiceProviderConfig retrieves the features of the service provider.
+// @route GET /scim/v2/ServiceProviderConfig
+// @group SCIM
+// @returns {object} 200 - The service provider configuration
+// @returns {object} 401 - Unauthorized access
+func (h *ProvisioningHandlers) ServiceProviderConfig() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return respond(c, http.StatusOK, scim.NewServiceProviderConfig(baseURL(c)))
+	}
+}
+
+// Schemas retrieves the schemas of the resources.
+// @route GET /scim/v2/Schemas
+// @group SCIM
+// @returns {object} 200 - A list response of schemas
+// @returns {object} 401 - Unauthorized access
+func (h *ProvisioningHandlers) Schemas() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		schemas := scim.Schemas(baseURL(c))
+		return respond(c, http.StatusOK, scim.NewListResponse(schemas, len(schemas), len(schemas), 1))
+	}
+}
+
+// Schema retrieves the schema with the given URN.
+// @route GET /scim/v2/Schemas/{id}
+// @group SCIM
+// @param {string} id.path.required - URN of the schema
+// @returns {object} 200 - The schema
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 404 - The schema does not exist.
+func (h *ProvisioningHandlers) Schema() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		for _, schema := range scim.Schemas(baseURL(c)) {
+			if schema.ID == c.Param("id") {
+				return respond(c, http.StatusOK, schema)
+			}
+		}
+		return fail(c, "get schema", scim.NotFound("Schema", c.Param("id")))
+	}
+}
+
+// ResourceTypes retrieves the types of the resources.
+// @route GET /scim/v2/ResourceTypes
+// @group SCIM
+// @returns {object} 200 - A list response of resource types
+// @returns {object} 401 - Unauthorized access
+func (h *ProvisioningHandlers) ResourceTypes() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		types := scim.ResourceTypes(baseURL(c))
+		return respond(c, http.StatusOK, scim.NewListResponse(types, len(types), len(types), 1))
+	}
+}
+
+// parseQuery parses the filter and the pagination of a list request.
+func parseQuery(c echo.Context) (scim.Query, error) {
+	return scim.ParseQuery(c.QueryParam("filter"), c.QueryParam("startIndex"), c.QueryParam("count"))
+}
+
+// ifMatch returns the version of the resource the If-Match header of a request is based on, or 0 if it has none.
+func ifMatch(c echo.Context) (int64, error) {
+	return scim.ParseIfMatch(c.Request().Header.Get("If-Match"))
+}
+
+// tag sets the ETag header of a response to the version of the user.
+func tag(c echo.Context, user scim.User) {
+	if user.Meta != nil && user.Meta.Version != "" {
+		c.Response().Header().Set("ETag", user.Meta.Version)
+	}
+}
+
+// decode decodes the JSON body of a request, which identity providers send as application/scim+json.
+// c: The context of the request.
+// v: A pointer to the value to decode into.
+// Returns a SCIM error with the invalidSyntax keyword if the body is not valid JSON.
+func decode(c echo.Context, v interface{}) error {
+	if err := json.NewDecoder(c.Request().Body).Decode(v); err != nil {
+		return scim.BadRequest(scim.ErrInvalidSyntax, "invalid request body: %v", err)
+	}
+	return nil
+}
+
+// respond writes a SCIM response.
+// c: The context of the request.
+// status: The HTTP status code of the response.
+// v: The body of the response.
+// Returns an error if the response cannot be written.
+func respond(c echo.Context, status int, v interface{}) error {
+	c.Response().Header().Set(echo.HeaderContentType, scim.MediaType)
+	return c.JSON(status, v)
+}
+
+// fail writes a SCIM error response.
+// Errors the client is responsible for keep their status, the errors of the storage get their status code,
+// and any other error is a server error.
+// c: The context of the request.
+// operation: The operation that failed, for the description of server errors.
+// err: The error.
+// Returns an error if the response cannot be written.
+func fail(c echo.Context, operation string, err error) error {
+	var scimErr *scim.Error
+	if !errors.As(err, &scimErr) {
+		status, scimType := app.StatusCode(err, http.StatusInternalServerError), ""
+		if status == http.StatusConflict {
+			scimType = scim.ErrUniqueness
+		}
+		scimErr = scim.NewError(status, scimType, "failed to %s: %v", operation, err)
+	}
+	return respond(c, scimErr.Code(), scimErr)
+}
+
+// baseURL returns the URL the SCIM endpoints are served under, as seen by the client.
+func baseURL(c echo.Context) string {
+	return fmt.Sprintf("%s://%s%s", c.Scheme(), c.Request().Host, BasePath)
+}
+
+// locateUser sets the URIs of a user and of the groups it references.
+func locateUser(c echo.Context, user *scim.User) {
+	base := baseURL(c)
+	user.Meta.Location = base + "/Users/" + user.ID
+	for i := range user.Groups {
+		user.Groups[i].Ref = base + "/Groups/" + user.Groups[i].Value
+	}
+}
+
+// locateGroup sets the URIs of a group and of the users it references.
+func locateGroup(c echo.Context, group *scim.Group) {
+	base := baseURL(c)
+	group.Meta.Location = base + "/Groups/" + group.ID
+	for i := range group.Members {
+		group.Members[i].Ref = base + "/Users/" + group.Members[i].Value
+	}
+}
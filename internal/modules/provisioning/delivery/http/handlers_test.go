@@ -0,0 +1,307 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth/authenticator"
+	authusecase "github.com/nikita-voronoy/go-clean-arch/internal/modules/auth/usecase"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/provisioning/usecase"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/membership"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/organization"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/user"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/memory"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/scim"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "scim-test-token"
+
+// fixture struct represents a recorded provisioning session of an identity provider.
+// Every step is a request the identity provider sent and the parts of the response it relied on.
+type fixture struct {
+	Name  string `json:"name"`
+	Steps []step `json:"steps"`
+}
+
+// step struct represents a recorded request and the expected response.
+// Headers are the headers the request is sent with besides the bearer token, and the headers the response must have.
+// Capture names the attributes of the response body that later steps refer to as {{name}}.
+type step struct {
+	Request struct {
+		Method  string            `json:"method"`
+		Path    string            `json:"path"`
+		Token   *string           `json:"token"`
+		Headers map[string]string `json:"headers"`
+		Body    json.RawMessage   `json:"body"`
+	} `json:"request"`
+	Response struct {
+		Status  int               `json:"status"`
+		Headers map[string]string `json:"headers"`
+		Body    json.RawMessage   `json:"body"`
+	} `json:"response"`
+	Capture map[string]string `json:"capture"`
+}
+
+// nopRecorder discards the audit events.
+type nopRecorder struct{}
+
+func (nopRecorder) Record(ctx context.Context, event entities.AuditEvent) error {
+	return nil
+}
+
+func newServer(t *testing.T) *echo.Echo {
+	cfg := &config.Config{
+		SCIM: config.SCIMConfig{Token: testToken},
+	}
+	db := database.NewTenantDatabase(memory.NewDatabase(), "organization_id")
+
+	users := user.NewUserRepository(db)
+	uc := usecase.NewProvisioningUC(users, organization.NewOrganizationRepository(db), membership.NewMembershipRepository(db), authusecase.NewAuthUC(cfg, users, storage.NewTransactor(db), authenticator.NewLocal(users), nopRecorder{}, nil), storage.NewTransactor(db), nopRecorder{})
+
+	e := echo.New()
+	MapProvisioningRoutes(e.Group(BasePath, BearerAuth(cfg)), NewProvisioningHandlers(cfg, uc))
+	return e
+}
+
+var placeholder = regexp.MustCompile(`\{\{(\w+)\}\}`)
+
+// expand replaces the placeholders with the captured values.
+func expand(t *testing.T, s string, captured map[string]string) string {
+	return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		name := placeholder.FindStringSubmatch(m)[1]
+		value, ok := captured[name]
+		require.True(t, ok, "nothing captured as %s", name)
+		return value
+	})
+}
+
+// requestURL encodes the query of a recorded path, which is written unescaped in the fixtures for readability.
+func requestURL(path string) string {
+	i := strings.Index(path, "?")
+	if i < 0 {
+		return path
+	}
+	query, _ := url.ParseQuery(path[i+1:])
+	return path[:i] + "?" + query.Encode()
+}
+
+// assertSubset checks that every attribute of the expected value is present and equal in the actual value.
+// Arrays must have the same length, and the string "*" matches any value.
+func assertSubset(t *testing.T, expected, actual interface{}, at string) {
+	switch expected := expected.(type) {
+	case map[string]interface{}:
+		object, ok := actual.(map[string]interface{})
+		if !assert.True(t, ok, "%s: expected an object but got %v", at, actual) {
+			return
+		}
+		for key, value := range expected {
+			v, ok := object[key]
+			if assert.True(t, ok, "%s.%s is missing", at, key) {
+				assertSubset(t, value, v, at+"."+key)
+			}
+		}
+	case []interface{}:
+		items, ok := actual.([]interface{})
+		if !assert.True(t, ok, "%s: expected an array but got %v", at, actual) ||
+			!assert.Len(t, items, len(expected), "%s has the wrong length", at) {
+			return
+		}
+		for i := range expected {
+			assertSubset(t, expected[i], items[i], fmt.Sprintf("%s[%d]", at, i))
+		}
+	default:
+		if expected == "*" {
+			return
+		}
+		assert.Equal(t, expected, actual, at)
+	}
+}
+
+func TestRecordedFixtures(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		var f fixture
+		require.NoError(t, json.Unmarshal(data, &f), file)
+
+		t.Run(f.Name, func(t *testing.T) {
+			e := newServer(t)
+			captured := map[string]string{}
+
+			for i, s := range f.Steps {
+				name := fmt.Sprintf("step %d: %s %s", i+1, s.Request.Method, s.Request.Path)
+				path := expand(t, s.Request.Path, captured)
+
+				var body *bytes.Reader
+				if len(s.Request.Body) > 0 {
+					body = bytes.NewReader([]byte(expand(t, string(s.Request.Body), captured)))
+				} else {
+					body = bytes.NewReader(nil)
+				}
+				req := httptest.NewRequest(s.Request.Method, requestURL(path), body)
+				req.Header.Set(echo.HeaderContentType, scim.MediaType)
+				token := testToken
+				if s.Request.Token != nil {
+					token = *s.Request.Token
+				}
+				if token != "" {
+					req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+				}
+				for header, value := range s.Request.Headers {
+					req.Header.Set(header, value)
+				}
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+
+				require.Equal(t, s.Response.Status, rec.Code, "%s: %s", name, rec.Body.String())
+				for header, value := range s.Response.Headers {
+					assert.Equal(t, value, rec.Header().Get(header), "%s: %s header", name, header)
+				}
+				if rec.Code == http.StatusNoContent {
+					continue
+				}
+				assert.Equal(t, scim.MediaType, rec.Header().Get(echo.HeaderContentType), name)
+
+				var actual map[string]interface{}
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &actual), name)
+				if len(s.Response.Body) > 0 {
+					var expected interface{}
+					require.NoError(t, json.Unmarshal([]byte(expand(t, string(s.Response.Body), captured)), &expected), name)
+					assertSubset(t, expected, actual, name)
+				}
+				for as, attr := range s.Capture {
+					value, ok := actual[attr].(string)
+					require.True(t, ok, "%s: cannot capture %s", name, attr)
+					captured[as] = value
+				}
+			}
+		})
+	}
+}
+
+// failingMemberships is a membership repository whose writes fail.
+type failingMemberships struct {
+	storage.MembershipRepository
+}
+
+func (failingMemberships) Create(ctx context.Context, model entities.Membership) error {
+	return errors.New("disk full")
+}
+
+func (failingMemberships) Delete(ctx context.Context, id uuid.UUID) error {
+	return errors.New("disk full")
+}
+
+func TestWritesAreRolledBackTogether(t *testing.T) {
+	db := database.NewTenantDatabase(memory.NewDatabase(), "organization_id")
+	users := user.NewUserRepository(db)
+	orgs := organization.NewOrganizationRepository(db)
+	memberships := membership.NewMembershipRepository(db)
+	ctx := context.Background()
+	member := entities.User{ID: uuid.New(), Username: "member", Email: "member@example.com", Password: "hash"}
+	require.NoError(t, users.Create(ctx, member))
+	org := entities.Organization{ID: uuid.New(), Name: "Acme", Slug: "acme"}
+	require.NoError(t, orgs.Create(ctx, org))
+	require.NoError(t, memberships.Create(database.WithTenant(ctx, org.ID), entities.Membership{ID: uuid.New(), UserID: member.ID, Role: entities.RoleMember}))
+
+	authUC := authusecase.NewAuthUC(&config.Config{}, users, storage.NewTransactor(db), authenticator.NewLocal(users), nopRecorder{}, nil)
+	uc := usecase.NewProvisioningUC(users, orgs, failingMemberships{memberships}, authUC, storage.NewTransactor(db), nopRecorder{})
+
+	_, err := uc.CreateGroup(ctx, scim.Group{DisplayName: "Globex", Members: []scim.Reference{{Value: member.ID.String()}}})
+	require.Error(t, err)
+	_, err = orgs.ReadBySlug(ctx, "globex")
+	assert.ErrorIs(t, err, database.ErrNotFound, "a group whose members cannot be added is not created")
+
+	require.Error(t, uc.DeleteUser(ctx, member.ID.String(), 0))
+	_, err = users.Read(ctx, member.ID)
+	assert.NoError(t, err, "a user whose memberships cannot be removed is not deleted")
+
+	require.Error(t, uc.DeleteGroup(ctx, org.ID.String()))
+	_, err = orgs.Read(ctx, org.ID)
+	assert.NoError(t, err, "a group whose memberships cannot be removed is not deleted")
+}
+
+// recordingRecorder remembers the actions of the audit events.
+type recordingRecorder struct {
+	actions []string
+}
+
+func (r *recordingRecorder) Record(ctx context.Context, event entities.AuditEvent) error {
+	r.actions = append(r.actions, event.Action)
+	return nil
+}
+
+func TestUserChangesAreAudited(t *testing.T) {
+	db := database.NewTenantDatabase(memory.NewDatabase(), "organization_id")
+	users := user.NewUserRepository(db)
+	recorder := &recordingRecorder{}
+	authUC := authusecase.NewAuthUC(&config.Config{}, users, storage.NewTransactor(db), authenticator.NewLocal(users), nopRecorder{}, nil)
+	uc := usecase.NewProvisioningUC(users, organization.NewOrganizationRepository(db), membership.NewMembershipRepository(db), authUC, storage.NewTransactor(db), recorder)
+	ctx := context.Background()
+
+	resource := scim.User{UserName: "bjensen", Emails: []scim.MultiValue{{Value: "bjensen@example.com", Primary: true}}, Active: scim.Bool(true)}
+	created, err := uc.CreateUser(ctx, resource)
+	require.NoError(t, err)
+	assert.Equal(t, []string{entities.AuditActionRegister}, recorder.actions)
+
+	recorder.actions = nil
+	_, err = uc.ReplaceUser(ctx, created.ID, resource, 0)
+	require.NoError(t, err)
+	assert.Empty(t, recorder.actions, "a replacement without a password nor an activation change is not audited")
+
+	resource.Password = "new-password"
+	resource.Active = scim.Bool(false)
+	_, err = uc.ReplaceUser(ctx, created.ID, resource, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{entities.AuditActionCredentialsChanged, entities.AuditActionDeactivated}, recorder.actions)
+
+	recorder.actions = nil
+	patch := scim.PatchRequest{Schemas: []string{scim.PatchOpSchema}, Operations: []scim.PatchOperation{{Op: "replace", Path: "active", Value: true}}}
+	_, err = uc.PatchUser(ctx, created.ID, patch, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{entities.AuditActionReactivated}, recorder.actions)
+}
+
+func TestCreateGroupGivesUpWithoutFreeSlug(t *testing.T) {
+	db := database.NewTenantDatabase(memory.NewDatabase(), "organization_id")
+	users := user.NewUserRepository(db)
+	orgs := organization.NewOrganizationRepository(db)
+	ctx := context.Background()
+	for i := 1; i < 1000; i++ {
+		slug := "acme"
+		if i > 1 {
+			slug += fmt.Sprint(i)
+		}
+		require.NoError(t, orgs.Create(ctx, entities.Organization{ID: uuid.New(), Name: "Acme", Slug: slug}))
+	}
+
+	authUC := authusecase.NewAuthUC(&config.Config{}, users, storage.NewTransactor(db), authenticator.NewLocal(users), nopRecorder{}, nil)
+	uc := usecase.NewProvisioningUC(users, orgs, membership.NewMembershipRepository(db), authUC, storage.NewTransactor(db), nopRecorder{})
+	_, err := uc.CreateGroup(ctx, scim.Group{DisplayName: "Acme"})
+	var scimErr *scim.Error
+	require.ErrorAs(t, err, &scimErr)
+	assert.Equal(t, "409", scimErr.Status)
+}
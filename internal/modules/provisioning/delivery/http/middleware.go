@@ -0,0 +1,33 @@
+// Package http provides the functionality to handle HTTP requests for the provisioning module.
+package http
+
+import (
+	"crypto/subtle"                                    // Subtle package provides the functionality to compare secrets in constant time.
+	"github.com/labstack/echo/v4"                      // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/labstack/echo/v4/middleware"           // Middleware package provides the functionality to use middleware with Echo.
+	"github.com/nikita-voronoy/go-clean-arch/config"   // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/pkg/scim" // SCIM package provides the functionality to speak the SCIM 2.0 protocol.
+	"net/http"
+)
+
+// BearerAuth creates a middleware that protects the SCIM endpoints with the bearer token of the identity provider.
+// Rejected requests get a SCIM error response, which identity providers can display.
+// cfg: The configuration that contains the SCIM bearer token.
+// Every request is rejected if no SCIM bearer token is configured.
+// Returns an echo.MiddlewareFunc.
+func BearerAuth(cfg *config.Config) echo.MiddlewareFunc {
+	return middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
+		KeyLookup:  "header:Authorization",
+		AuthScheme: "Bearer",
+		Validator: func(key string, c echo.Context) (bool, error) {
+			if cfg.SCIM.Token == "" {
+				return false, nil
+			}
+			return subtle.ConstantTimeCompare([]byte(key), []byte(cfg.SCIM.Token)) == 1, nil
+		},
+		ErrorHandler: func(err error, c echo.Context) error {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+			return respond(c, http.StatusUnauthorized, scim.NewError(http.StatusUnauthorized, "", "invalid or missing bearer token"))
+		},
+	})
+}
@@ -0,0 +1,101 @@
+// Package http provides the functionality to map the routes of the provisioning module over HTTP.
+package http
+
+import (
+	"github.com/labstack/echo/v4"                                           // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/provisioning" // Provisioning package provides the functionality to interact with the provisioning module.
+)
+
+// BasePath is the path the SCIM endpoints are served under.
+const BasePath = "/scim/v2"
+
+// MapProvisioningRoutes maps the SCIM routes to the provided Echo group with the provided provisioning handlers.
+// scimGroup: The Echo group to map the routes to. It is expected to be protected by the SCIM bearer authentication.
+// h: The provisioning handlers to use for the routes.
+// The routes include:
+// GET, POST /Users: Lists and provisions users.
+// GET, PUT, PATCH, DELETE /Users/:id: Retrieves, replaces, modifies and deprovisions a user.
+// GET, POST /Groups: Lists and provisions groups.
+// GET, PUT, PATCH, DELETE /Groups/:id: Retrieves, replaces, modifies and deprovisions a group.
+// GET /ServiceProviderConfig, /Schemas, /Schemas/:id, /ResourceTypes: Describes the service provider.
+func MapProvisioningRoutes(scimGroup *echo.Group, h provisioning.Handlers) {
+	// @route GET /scim/v2/Users
+	// @group SCIM
+	// @returns {object} 200 - A list response of users
+	scimGroup.GET("/Users", h.ListUsers())
+
+	// @route POST /scim/v2/Users
+	// @group SCIM
+	// @returns {object} 201 - The provisioned user
+	scimGroup.POST("/Users", h.CreateUser())
+
+	// @route GET /scim/v2/Users/{id}
+	// @group SCIM
+	// @returns {object} 200 - The user
+	scimGroup.GET("/Users/:id", h.GetUser())
+
+	// @route PUT /scim/v2/Users/{id}
+	// @group SCIM
+	// @returns {object} 200 - The updated user
+	scimGroup.PUT("/Users/:id", h.ReplaceUser())
+
+	// @route PATCH /scim/v2/Users/{id}
+	// @group SCIM
+	// @returns {object} 200 - The updated user
+	scimGroup.PATCH("/Users/:id", h.PatchUser())
+
+	// @route DELETE /scim/v2/Users/{id}
+	// @group SCIM
+	// @returns {object} 204 - The user was deprovisioned.
+	scimGroup.DELETE("/Users/:id", h.DeleteUser())
+
+	// @route GET /scim/v2/Groups
+	// @group SCIM
+	// @returns {object} 200 - A list response of groups
+	scimGroup.GET("/Groups", h.ListGroups())
+
+	// @route POST /scim/v2/Groups
+	// @group SCIM
+	// @returns {object} 201 - The provisioned group
+	scimGroup.POST("/Groups", h.CreateGroup())
+
+	// @route GET /scim/v2/Groups/{id}
+	// @group SCIM
+	// @returns {object} 200 - The group
+	scimGroup.GET("/Groups/:id", h.GetGroup())
+
+	// @route PUT /scim/v2/Groups/{id}
+	// @group SCIM
+	// @returns {object} 200 - The updated group
+	scimGroup.PUT("/Groups/:id", h.ReplaceGroup())
+
+	// @route PATCH /scim/v2/Groups/{id}
+	// @group SCIM
+	// @returns {object} 200 - The updated group
+	scimGroup.PATCH("/Groups/:id", h.PatchGroup())
+
+	// @route DELETE /scim/v2/Groups/{id}
+	// @group SCIM
+	// @returns {object} 204 - The group was deprovisioned.
+	scimGroup.DELETE("/Groups/:id", h.DeleteGroup())
+
+	// @route GET /scim/v2/ServiceProviderConfig
+	// @group SCIM
+	// @returns {object} 200 - The service provider configuration
+	scimGroup.GET("/ServiceProviderConfig", h.ServiceProviderConfig())
+
+	// @route GET /scim/v2/Schemas
+	// @group SCIM
+	// @returns {object} 200 - A list response of schemas
+	scimGroup.GET("/Schemas", h.Schemas())
+
+	// @route GET /scim/v2/Schemas/{id}
+	// @group SCIM
+	// @returns {object} 200 - The schema
+	scimGroup.GET("/Schemas/:id", h.Schema())
+
+	// @route GET /scim/v2/ResourceTypes
+	// @group SCIM
+	// @returns {object} 200 - A list response of resource types
+	scimGroup.GET("/ResourceTypes", h.ResourceTypes())
+}
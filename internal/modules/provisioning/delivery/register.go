@@ -0,0 +1,32 @@
+// Package delivery provides the functionality to deliver the responses of the provisioning module.
+package delivery
+
+import (
+	"github.com/labstack/echo/v4"                                                         // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/config"                                      // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/provisioning"               // Provisioning package provides the functionality to interact with the provisioning module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/provisioning/delivery/http" // HTTP package provides the functionality to deliver the responses of the provisioning module over HTTP.
+)
+
+// ProvisioningDelivery struct represents a provisioning delivery that provides methods for delivering the responses of the provisioning module.
+// It includes a ProvisioningHandlers object for handling the responses and a function for setting up the routes.
+type ProvisioningDelivery struct {
+	Handlers        *http.ProvisioningHandlers // The handlers for the provisioning responses.
+	SetupRoutesFunc func(echo *echo.Echo)      // The function for setting up the routes.
+}
+
+// NewProvisioningDelivery creates a new provisioning delivery with the provided configuration and provisioning use case.
+// cfg: The configuration for the provisioning delivery.
+// uc: The provisioning use case for the provisioning delivery.
+// Returns a ProvisioningDelivery object.
+func NewProvisioningDelivery(cfg *config.Config, uc provisioning.UseCase) *ProvisioningDelivery {
+	handlers := http.NewProvisioningHandlers(cfg, uc) // Creates new provisioning handlers with the provided configuration and provisioning use case.
+
+	// Returns a new ProvisioningDelivery object with the created handlers and a function for setting up the routes.
+	return &ProvisioningDelivery{
+		Handlers: handlers,
+		SetupRoutesFunc: func(e *echo.Echo) {
+			http.MapProvisioningRoutes(e.Group(http.BasePath, http.BearerAuth(cfg)), handlers) // Maps the SCIM routes to the bearer-protected "/scim/v2" group.
+		},
+	}
+}
@@ -0,0 +1,30 @@
+// Package module provides the functionality to interact with the provisioning module.
+package module
+
+import (
+	"github.com/labstack/echo/v4"                                                         // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/config"                                      // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/provisioning/delivery"      // Delivery package provides the functionality to deliver the responses of the provisioning module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/provisioning/delivery/http" // HTTP package provides the functionality to deliver the responses of the provisioning module over HTTP.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/provisioning/usecase"       // Usecase package provides the functionality to interact with the use cases of the provisioning module.
+	"go.uber.org/fx"                                                                      // Fx is a framework for Go that provides the building blocks for your service architectures.
+)
+
+// Module is a Fx options group that provides and invokes the necessary dependencies for the provisioning module.
+// It relies on the user, organization and membership repositories provided by the auth and organization modules, and on the audit recorder of the audit module.
+var Module = fx.Options(
+	fx.Provide(
+		usecase.NewProvisioningUC,        // Provides a new provisioning use case.
+		http.NewProvisioningHandlers,     // Provides new provisioning handlers.
+		delivery.NewProvisioningDelivery, // Provides a new provisioning delivery.
+	),
+	fx.Invoke(registerProvisioningRoutes), // Invokes the function to register the SCIM routes.
+)
+
+// registerProvisioningRoutes registers the SCIM routes with the provided Echo instance and provisioning handlers.
+// e: The Echo instance to register the routes with.
+// cfg: The configuration that contains the SCIM bearer token.
+// handlers: The provisioning handlers to use for the routes.
+func registerProvisioningRoutes(e *echo.Echo, cfg *config.Config, handlers *http.ProvisioningHandlers) {
+	http.MapProvisioningRoutes(e.Group(http.BasePath, http.BearerAuth(cfg)), handlers) // Maps the SCIM routes to the bearer-protected "/scim/v2" group.
+}
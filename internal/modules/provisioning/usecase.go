@@ -0,0 +1,92 @@
+// Package provisioning provides the functionality to let identity providers provision users and groups over SCIM 2.0.
+// Users map onto the user accounts of the application, and groups map onto organizations and their members.
+package provisioning
+
+import (
+	"context"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/scim"
+)
+
+// UseCase is an interface that defines the methods required for SCIM provisioning operations.
+// The methods return *scim.Error values for the failures the client is responsible for,
+// including the 412 error of a modification based on an outdated version of a user.
+type UseCase interface {
+	// ListUsers retrieves a page of the users that match the query.
+	// ctx: The context for the operation.
+	// query: The filter and the pagination of the query.
+	// Returns the users of the page, the number of users that match the filter and an error if the operation fails.
+	ListUsers(ctx context.Context, query scim.Query) ([]scim.User, int, error)
+
+	// GetUser retrieves a user.
+	// ctx: The context for the operation.
+	// id: The ID of the user.
+	// Returns the user and an error if the operation fails.
+	GetUser(ctx context.Context, id string) (scim.User, error)
+
+	// CreateUser provisions a new user.
+	// ctx: The context for the operation.
+	// user: The user to provision.
+	// Returns the provisioned user and an error if the operation fails.
+	CreateUser(ctx context.Context, user scim.User) (scim.User, error)
+
+	// ReplaceUser replaces the attributes of a user.
+	// ctx: The context for the operation.
+	// id: The ID of the user.
+	// user: The new attributes of the user.
+	// version: The version of the user the client based the replacement on, or 0 to replace the current version.
+	// Returns the updated user and an error if the operation fails.
+	ReplaceUser(ctx context.Context, id string, user scim.User, version int64) (scim.User, error)
+
+	// PatchUser applies PATCH operations to a user.
+	// ctx: The context for the operation.
+	// id: The ID of the user.
+	// patch: The operations to apply.
+	// version: The version of the user the client based the operations on, or 0 to apply them to the current version.
+	// Returns the updated user and an error if the operation fails.
+	PatchUser(ctx context.Context, id string, patch scim.PatchRequest, version int64) (scim.User, error)
+
+	// DeleteUser deprovisions a user and removes it from every group.
+	// ctx: The context for the operation.
+	// id: The ID of the user.
+	// version: The version of the user the client based the deletion on, or 0 to delete any version.
+	// Returns an error if the operation fails.
+	DeleteUser(ctx context.Context, id string, version int64) error
+
+	// ListGroups retrieves a page of the groups that match the query.
+	// ctx: The context for the operation.
+	// query: The filter and the pagination of the query.
+	// Returns the groups of the page, the number of groups that match the filter and an error if the operation fails.
+	ListGroups(ctx context.Context, query scim.Query) ([]scim.Group, int, error)
+
+	// GetGroup retrieves a group.
+	// ctx: The context for the operation.
+	// id: The ID of the group.
+	// Returns the group and an error if the operation fails.
+	GetGroup(ctx context.Context, id string) (scim.Group, error)
+
+	// CreateGroup provisions a new group.
+	// ctx: The context for the operation.
+	// group: The group to provision.
+	// Returns the provisioned group and an error if the operation fails.
+	CreateGroup(ctx context.Context, group scim.Group) (scim.Group, error)
+
+	// ReplaceGroup replaces the attributes and the members of a group.
+	// ctx: The context for the operation.
+	// id: The ID of the group.
+	// group: The new attributes of the group.
+	// Returns the updated group and an error if the operation fails.
+	ReplaceGroup(ctx context.Context, id string, group scim.Group) (scim.Group, error)
+
+	// PatchGroup applies PATCH operations to a group.
+	// ctx: The context for the operation.
+	// id: The ID of the group.
+	// patch: The operations to apply.
+	// Returns the updated group and an error if the operation fails.
+	PatchGroup(ctx context.Context, id string, patch scim.PatchRequest) (scim.Group, error)
+
+	// DeleteGroup deprovisions a group and its memberships.
+	// ctx: The context for the operation.
+	// id: The ID of the group.
+	// Returns an error if the operation fails.
+	DeleteGroup(ctx context.Context, id string) error
+}
@@ -0,0 +1,806 @@
+// Package usecase provides the functionality to map SCIM users and groups onto the users and organizations of the application.
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/audit"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/auth"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/provisioning"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/scim"
+	"log"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// userColumns are the columns of the users that the filters on the attributes of the SCIM users are translated to.
+var userColumns = map[string]scim.Column{
+	"id":         {Name: "id", Value: uuidValue},
+	"externalid": {Name: "external_id"},
+	"username":   {Name: "username"},
+	"active":     {Name: "deactivated", Value: inactive},
+}
+
+// auditActor is the actor of the audit events of the changes the identity provider makes over SCIM.
+const auditActor = "scim"
+
+// groupColumns are the columns of the organizations that the filters on the attributes of the SCIM groups are translated to.
+var groupColumns = map[string]scim.Column{
+	"id":          {Name: "id", Value: uuidValue},
+	"externalid":  {Name: "external_id"},
+	"displayname": {Name: "name"},
+}
+
+// ProvisioningUseCase struct represents a provisioning use case that provides methods for SCIM operations.
+type ProvisioningUseCase struct {
+	users       storage.UserRepository
+	orgs        storage.OrganizationRepository
+	memberships storage.MembershipRepository
+	auth        auth.UseCase
+	tx          storage.Transactor
+	audit       audit.Recorder
+}
+
+// NewProvisioningUC creates a new provisioning use case with the provided repositories.
+// users: The user repository that SCIM users map onto.
+// orgs: The organization repository that SCIM groups map onto.
+// memberships: The membership repository that the members of SCIM groups map onto.
+// authUC: The auth use case, used to hash the passwords of the provisioned users.
+// tx: The transactions the operations that write several records run in.
+// recorder: The audit recorder the creations, the password changes and the activation changes of the users are written to.
+// Returns a provisioning.UseCase object.
+func NewProvisioningUC(users storage.UserRepository, orgs storage.OrganizationRepository, memberships storage.MembershipRepository, authUC auth.UseCase, tx storage.Transactor, recorder audit.Recorder) provisioning.UseCase {
+	return &ProvisioningUseCase{
+		users:       users,
+		orgs:        orgs,
+		memberships: memberships,
+		auth:        authUC,
+		tx:          tx,
+		audit:       recorder,
+	}
+}
+
+// ListUsers retrieves a page of the users that match the query, ordered by username.
+// The storage filters, counts and pages the users, unless the filter has parts it cannot evaluate,
+// in which case these parts are applied to the users the rest of the filter selects.
+// ctx: The context for the operation.
+// q: The filter and the pagination of the query.
+// Returns the users of the page, the number of users that match the filter and an error if the operation fails.
+func (uc ProvisioningUseCase) ListUsers(ctx context.Context, q scim.Query) ([]scim.User, int, error) {
+	where, rest := scim.Condition(q.Filter, userColumns)
+	list := query.Where(where).OrderedBy(query.Asc("username"))
+	if rest != nil {
+		users, err := uc.users.List(ctx, list)
+		if err != nil {
+			return nil, 0, err
+		}
+		resources, err := uc.userResources(ctx, users)
+		if err != nil {
+			return nil, 0, err
+		}
+		return page(resources, scim.Query{Filter: rest, StartIndex: q.StartIndex, Count: q.Count})
+	}
+
+	total, err := uc.users.Count(ctx, where)
+	if err != nil {
+		return nil, 0, err
+	}
+	if total == 0 || q.Count == 0 {
+		return []scim.User{}, int(total), nil
+	}
+	users, err := uc.users.List(ctx, list.Window(q.Count, q.StartIndex-1))
+	if err != nil {
+		return nil, 0, err
+	}
+	resources, err := uc.userResources(ctx, users)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resources, int(total), nil
+}
+
+// GetUser retrieves a user.
+// ctx: The context for the operation.
+// id: The ID of the user.
+// Returns the user and an error if the operation fails.
+func (uc ProvisioningUseCase) GetUser(ctx context.Context, id string) (scim.User, error) {
+	user, err := uc.readUser(ctx, id)
+	if err != nil {
+		return scim.User{}, err
+	}
+	return uc.userResource(ctx, user)
+}
+
+// CreateUser provisions a new user.
+// Users provisioned without a password get a random one, so they can only sign in once a password is set for them.
+// ctx: The context for the operation.
+// resource: The user to provision.
+// Returns the provisioned user and an error if the operation fails.
+func (uc ProvisioningUseCase) CreateUser(ctx context.Context, resource scim.User) (scim.User, error) {
+	user := entities.User{ID: uuid.New()}
+	if resource.Password == "" {
+		password, err := randomPassword()
+		if err != nil {
+			return scim.User{}, err
+		}
+		resource.Password = password
+	}
+	if err := uc.applyUser(ctx, &user, resource); err != nil {
+		return scim.User{}, err
+	}
+	if err := uc.users.Create(ctx, user); err != nil {
+		return scim.User{}, err
+	}
+	uc.record(ctx, entities.AuditActionRegister, user.ID)
+	return uc.GetUser(ctx, user.ID.String())
+}
+
+// ReplaceUser replaces the attributes of a user. The password is kept unless a new one is given.
+// ctx: The context for the operation.
+// id: The ID of the user.
+// resource: The new attributes of the user.
+// version: The version of the user the client based the replacement on, or 0 to replace the current version.
+// Returns the updated user and an error if the operation fails.
+func (uc ProvisioningUseCase) ReplaceUser(ctx context.Context, id string, resource scim.User, version int64) (scim.User, error) {
+	var before, after entities.User
+	err := retry(version, func() error {
+		user, err := uc.readVersion(ctx, id, version)
+		if err != nil {
+			return err
+		}
+		before, after = user, user
+		if err := uc.applyUser(ctx, &after, resource); err != nil {
+			return err
+		}
+		return uc.users.Update(ctx, after)
+	})
+	if err != nil {
+		return scim.User{}, err
+	}
+	uc.recordChanges(ctx, before, after)
+	return uc.GetUser(ctx, id)
+}
+
+// PatchUser applies PATCH operations to a user.
+// ctx: The context for the operation.
+// id: The ID of the user.
+// patch: The operations to apply.
+// version: The version of the user the client based the operations on, or 0 to apply them to the current version.
+// Returns the updated user and an error if the operation fails.
+func (uc ProvisioningUseCase) PatchUser(ctx context.Context, id string, patch scim.PatchRequest, version int64) (scim.User, error) {
+	var before, after entities.User
+	err := retry(version, func() error {
+		user, err := uc.readVersion(ctx, id, version)
+		if err != nil {
+			return err
+		}
+		before = user
+		current, err := uc.userResource(ctx, user)
+		if err != nil {
+			return err
+		}
+		object, err := scim.ToMap(current)
+		if err != nil {
+			return err
+		}
+		if err := patch.Apply(object); err != nil {
+			return err
+		}
+		var resource scim.User
+		if err := scim.FromMap(object, &resource); err != nil {
+			return err
+		}
+		if err := uc.applyUser(ctx, &user, resource); err != nil {
+			return err
+		}
+		after = user
+		return uc.users.Update(ctx, user)
+	})
+	if err != nil {
+		return scim.User{}, err
+	}
+	uc.recordChanges(ctx, before, after)
+	return uc.GetUser(ctx, id)
+}
+
+// DeleteUser deprovisions a user and removes it from every group, in a transaction that also checks the version.
+// ctx: The context for the operation.
+// id: The ID of the user.
+// version: The version of the user the client based the deletion on, or 0 to delete any version.
+// Returns an error if the operation fails.
+func (uc ProvisioningUseCase) DeleteUser(ctx context.Context, id string, version int64) error {
+	return uc.tx.WithTx(ctx, func(ctx context.Context) error {
+		user, err := uc.readVersion(ctx, id, version)
+		if err != nil {
+			return err
+		}
+		memberships, err := uc.memberships.ReadByUser(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		for _, membership := range memberships {
+			if err := uc.memberships.Delete(database.WithTenant(ctx, membership.OrganizationID), membership.ID); err != nil {
+				return err
+			}
+		}
+		return uc.users.Delete(ctx, user.ID)
+	})
+}
+
+// ListGroups retrieves a page of the groups that match the query, ordered by display name.
+// The storage filters, counts and pages the groups, unless the filter has parts it cannot evaluate,
+// in which case these parts are applied to the groups the rest of the filter selects.
+// ctx: The context for the operation.
+// q: The filter and the pagination of the query.
+// Returns the groups of the page, the number of groups that match the filter and an error if the operation fails.
+func (uc ProvisioningUseCase) ListGroups(ctx context.Context, q scim.Query) ([]scim.Group, int, error) {
+	where, rest := scim.Condition(q.Filter, groupColumns)
+	list := query.Where(where).OrderedBy(query.Asc("name"), query.Asc("id"))
+	if rest != nil {
+		orgs, err := uc.orgs.List(ctx, list)
+		if err != nil {
+			return nil, 0, err
+		}
+		resources, err := uc.groupResources(ctx, orgs)
+		if err != nil {
+			return nil, 0, err
+		}
+		return page(resources, scim.Query{Filter: rest, StartIndex: q.StartIndex, Count: q.Count})
+	}
+
+	total, err := uc.orgs.Count(ctx, where)
+	if err != nil {
+		return nil, 0, err
+	}
+	if total == 0 || q.Count == 0 {
+		return []scim.Group{}, int(total), nil
+	}
+	orgs, err := uc.orgs.List(ctx, list.Window(q.Count, q.StartIndex-1))
+	if err != nil {
+		return nil, 0, err
+	}
+	resources, err := uc.groupResources(ctx, orgs)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resources, int(total), nil
+}
+
+// GetGroup retrieves a group.
+// ctx: The context for the operation.
+// id: The ID of the group.
+// Returns the group and an error if the operation fails.
+func (uc ProvisioningUseCase) GetGroup(ctx context.Context, id string) (scim.Group, error) {
+	org, err := uc.readGroup(ctx, id)
+	if err != nil {
+		return scim.Group{}, err
+	}
+	return uc.groupResource(ctx, org)
+}
+
+// CreateGroup provisions a new group as an organization with a slug derived from the display name.
+// The organization and its memberships are created in a transaction.
+// ctx: The context for the operation.
+// resource: The group to provision.
+// Returns the provisioned group and an error if the operation fails.
+func (uc ProvisioningUseCase) CreateGroup(ctx context.Context, resource scim.Group) (scim.Group, error) {
+	org := entities.Organization{
+		ID:         uuid.New(),
+		Name:       resource.DisplayName,
+		ExternalID: resource.ExternalID,
+	}
+	err := uc.tx.WithTx(ctx, func(ctx context.Context) error {
+		slug, err := uc.slug(ctx, resource.DisplayName)
+		if err != nil {
+			return err
+		}
+		org.Slug = slug
+		if err := validateGroup(org); err != nil {
+			return err
+		}
+		members, err := uc.members(ctx, resource.Members)
+		if err != nil {
+			return err
+		}
+
+		if err := uc.orgs.Create(ctx, org); err != nil {
+			return err
+		}
+		return uc.syncMembers(ctx, org.ID, members)
+	})
+	if err != nil {
+		return scim.Group{}, err
+	}
+	return uc.GetGroup(ctx, org.ID.String())
+}
+
+// ReplaceGroup replaces the display name and the members of a group in a transaction. The slug of the organization is kept.
+// ctx: The context for the operation.
+// id: The ID of the group.
+// resource: The new attributes of the group.
+// Returns the updated group and an error if the operation fails.
+func (uc ProvisioningUseCase) ReplaceGroup(ctx context.Context, id string, resource scim.Group) (scim.Group, error) {
+	err := uc.tx.WithTx(ctx, func(ctx context.Context) error {
+		org, err := uc.readGroup(ctx, id)
+		if err != nil {
+			return err
+		}
+		org.Name = resource.DisplayName
+		org.ExternalID = resource.ExternalID
+		if err := validateGroup(org); err != nil {
+			return err
+		}
+		members, err := uc.members(ctx, resource.Members)
+		if err != nil {
+			return err
+		}
+
+		if err := uc.orgs.Update(ctx, org); err != nil {
+			return err
+		}
+		return uc.syncMembers(ctx, org.ID, members)
+	})
+	if err != nil {
+		return scim.Group{}, err
+	}
+	return uc.GetGroup(ctx, id)
+}
+
+// PatchGroup applies PATCH operations to a group.
+// ctx: The context for the operation.
+// id: The ID of the group.
+// patch: The operations to apply.
+// Returns the updated group and an error if the operation fails.
+func (uc ProvisioningUseCase) PatchGroup(ctx context.Context, id string, patch scim.PatchRequest) (scim.Group, error) {
+	current, err := uc.GetGroup(ctx, id)
+	if err != nil {
+		return scim.Group{}, err
+	}
+	object, err := scim.ToMap(current)
+	if err != nil {
+		return scim.Group{}, err
+	}
+	if err := patch.Apply(object); err != nil {
+		return scim.Group{}, err
+	}
+	var resource scim.Group
+	if err := scim.FromMap(object, &resource); err != nil {
+		return scim.Group{}, err
+	}
+	return uc.ReplaceGroup(ctx, id, resource)
+}
+
+// DeleteGroup deprovisions a group and its memberships in a transaction.
+// ctx: The context for the operation.
+// id: The ID of the group.
+// Returns an error if the operation fails.
+func (uc ProvisioningUseCase) DeleteGroup(ctx context.Context, id string) error {
+	return uc.tx.WithTx(ctx, func(ctx context.Context) error {
+		org, err := uc.readGroup(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := uc.syncMembers(ctx, org.ID, nil); err != nil {
+			return err
+		}
+		return uc.orgs.Delete(ctx, org.ID)
+	})
+}
+
+// recordChanges writes the password change and the activation change of a user to the audit log.
+// ctx: The context for the operation.
+// before: The user before the change.
+// after: The user after the change.
+func (uc ProvisioningUseCase) recordChanges(ctx context.Context, before, after entities.User) {
+	if after.Password != before.Password {
+		uc.record(ctx, entities.AuditActionCredentialsChanged, after.ID)
+	}
+	switch {
+	case after.Deactivated && !before.Deactivated:
+		uc.record(ctx, entities.AuditActionDeactivated, after.ID)
+	case !after.Deactivated && before.Deactivated:
+		uc.record(ctx, entities.AuditActionReactivated, after.ID)
+	}
+}
+
+// record writes a change the identity provider made to a user to the audit log.
+// A failure to write the audit event is logged and does not fail the change itself.
+// ctx: The context for the operation.
+// action: The action that was performed.
+// userID: The ID of the user the action was performed on.
+func (uc ProvisioningUseCase) record(ctx context.Context, action string, userID uuid.UUID) {
+	event := entities.AuditEvent{
+		Actor:   auditActor,
+		Action:  action,
+		Target:  userID.String(),
+		Outcome: entities.AuditOutcomeSuccess,
+	}
+	if err := uc.audit.Record(ctx, event); err != nil {
+		log.Printf("Failed to record audit event: %v\n", err)
+	}
+}
+
+// readUser retrieves the user with the given ID.
+// Returns a SCIM error with the 404 status code if there is no such user.
+func (uc ProvisioningUseCase) readUser(ctx context.Context, id string) (entities.User, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return entities.User{}, scim.NotFound("User", id)
+	}
+	user, err := uc.users.Read(ctx, userID)
+	if errors.Is(err, database.ErrNotFound) {
+		return entities.User{}, scim.NotFound("User", id)
+	}
+	return user, err
+}
+
+// readVersion retrieves the user with the given ID, which must have the version the client based a modification on.
+// Returns a SCIM error with the 404 status code if there is no such user, or with the 412 status code if the user has another version.
+func (uc ProvisioningUseCase) readVersion(ctx context.Context, id string, version int64) (entities.User, error) {
+	user, err := uc.readUser(ctx, id)
+	if err != nil {
+		return entities.User{}, err
+	}
+	if version != 0 && user.Metadata.Version != version {
+		return entities.User{}, scim.PreconditionFailed("User %s was modified, its version is %s", id, scim.ETag(user.Metadata.Version))
+	}
+	return user, nil
+}
+
+// readGroup retrieves the organization of the group with the given ID.
+// Returns a SCIM error with the 404 status code if there is no such group.
+func (uc ProvisioningUseCase) readGroup(ctx context.Context, id string) (entities.Organization, error) {
+	orgID, err := uuid.Parse(id)
+	if err != nil {
+		return entities.Organization{}, scim.NotFound("Group", id)
+	}
+	org, err := uc.orgs.Read(ctx, orgID)
+	if errors.Is(err, database.ErrNotFound) {
+		return entities.Organization{}, scim.NotFound("Group", id)
+	}
+	return org, err
+}
+
+// userResource maps a user onto a SCIM user, including the groups the user is a member of.
+func (uc ProvisioningUseCase) userResource(ctx context.Context, user entities.User) (scim.User, error) {
+	resources, err := uc.userResources(ctx, []entities.User{user})
+	if err != nil {
+		return scim.User{}, err
+	}
+	return resources[0], nil
+}
+
+// userResources maps users onto SCIM users, reading the memberships and the organizations of all the users at once.
+// The groups of a user are ordered by name.
+func (uc ProvisioningUseCase) userResources(ctx context.Context, users []entities.User) ([]scim.User, error) {
+	ids := make([]uuid.UUID, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	memberships, err := uc.memberships.ReadByUsers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	members := make(map[uuid.UUID][]uuid.UUID)
+	orgIDs := make([]uuid.UUID, 0, len(memberships))
+	for _, membership := range memberships {
+		if _, ok := members[membership.OrganizationID]; !ok {
+			orgIDs = append(orgIDs, membership.OrganizationID)
+		}
+		members[membership.OrganizationID] = append(members[membership.OrganizationID], membership.UserID)
+	}
+	orgs, err := uc.orgs.ReadMany(ctx, orgIDs)
+	if err != nil {
+		return nil, err
+	}
+	groups := make(map[uuid.UUID][]scim.Reference, len(users))
+	for _, org := range orgs {
+		for _, userID := range members[org.ID] {
+			groups[userID] = append(groups[userID], scim.Reference{Value: org.ID.String(), Display: org.Name, Type: "direct"})
+		}
+	}
+
+	resources := make([]scim.User, 0, len(users))
+	for _, user := range users {
+		resources = append(resources, scim.User{
+			Schemas:    []string{scim.UserSchema},
+			ID:         user.ID.String(),
+			ExternalID: user.ExternalID,
+			UserName:   user.Username,
+			Emails:     []scim.MultiValue{{Value: user.Email, Type: "work", Primary: true}},
+			Active:     scim.Bool(!user.Deactivated),
+			Groups:     groups[user.ID],
+			Meta: &scim.Meta{
+				ResourceType: "User",
+				Created:      user.Metadata.CreatedAt,
+				LastModified: user.Metadata.UpdatedAt,
+				Version:      scim.ETag(user.Metadata.Version),
+			},
+		})
+	}
+	return resources, nil
+}
+
+// applyUser maps the attributes of a SCIM user onto a user.
+// Deactivating a user also revokes its bearer token.
+// ctx: The context for the operation.
+// user: The user to modify.
+// resource: The SCIM user to take the attributes from.
+// Returns a SCIM error if an attribute is not valid or not unique, or the error of the storage if the uniqueness cannot be checked.
+func (uc ProvisioningUseCase) applyUser(ctx context.Context, user *entities.User, resource scim.User) error {
+	user.Username = resource.UserName
+	user.Email = resource.PrimaryEmail()
+	if err := validator.New().StructPartial(*user, "Username", "Email"); err != nil {
+		if errs, ok := err.(validator.ValidationErrors); ok && len(errs) > 0 {
+			switch errs[0].Field() {
+			case "Username":
+				return scim.BadRequest(scim.ErrInvalidValue, "userName is required and must be alphanumeric and between 3 and 20 characters long")
+			case "Email":
+				return scim.BadRequest(scim.ErrInvalidValue, "a valid email is required")
+			}
+		}
+		return scim.BadRequest(scim.ErrInvalidValue, "%v", err)
+	}
+	existing, err := uc.users.ReadByUsername(ctx, user.Username)
+	if err == nil && existing.ID != user.ID {
+		return scim.Conflict("the userName %q is already taken", user.Username)
+	}
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return err
+	}
+	existing, err = uc.users.ReadByEmail(ctx, user.Email)
+	if err == nil && existing.ID != user.ID {
+		return scim.Conflict("the email %q is already taken", user.Email)
+	}
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return err
+	}
+
+	if resource.Password != "" {
+		if len(resource.Password) < 8 {
+			return scim.BadRequest(scim.ErrInvalidValue, "password must be at least 8 characters long")
+		}
+		hash, err := uc.auth.HashPassword(resource.Password)
+		if err != nil {
+			return err
+		}
+		user.Password = hash
+	}
+
+	user.ExternalID = resource.ExternalID
+	user.Deactivated = resource.Active != nil && !bool(*resource.Active)
+	if user.Deactivated {
+		user.Token = ""
+	}
+	return nil
+}
+
+// groupResource maps an organization onto a SCIM group, with the users of its memberships as members.
+func (uc ProvisioningUseCase) groupResource(ctx context.Context, org entities.Organization) (scim.Group, error) {
+	resources, err := uc.groupResources(ctx, []entities.Organization{org})
+	if err != nil {
+		return scim.Group{}, err
+	}
+	return resources[0], nil
+}
+
+// groupResources maps organizations onto SCIM groups, reading the memberships and the users of all the organizations at once.
+func (uc ProvisioningUseCase) groupResources(ctx context.Context, orgs []entities.Organization) ([]scim.Group, error) {
+	ids := make([]uuid.UUID, 0, len(orgs))
+	for _, org := range orgs {
+		ids = append(ids, org.ID)
+	}
+	memberships, err := uc.memberships.ReadByOrganizations(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[uuid.UUID]bool, len(memberships))
+	userIDs := make([]uuid.UUID, 0, len(memberships))
+	for _, membership := range memberships {
+		if !seen[membership.UserID] {
+			seen[membership.UserID] = true
+			userIDs = append(userIDs, membership.UserID)
+		}
+	}
+	users, err := uc.users.ReadMany(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	usernames := make(map[uuid.UUID]string, len(users))
+	for _, user := range users {
+		usernames[user.ID] = user.Username
+	}
+	members := make(map[uuid.UUID][]scim.Reference, len(orgs))
+	for _, membership := range memberships {
+		member := scim.Reference{Value: membership.UserID.String(), Display: usernames[membership.UserID], Type: "User"}
+		members[membership.OrganizationID] = append(members[membership.OrganizationID], member)
+	}
+
+	resources := make([]scim.Group, 0, len(orgs))
+	for _, org := range orgs {
+		resources = append(resources, scim.Group{
+			Schemas:     []string{scim.GroupSchema},
+			ID:          org.ID.String(),
+			ExternalID:  org.ExternalID,
+			DisplayName: org.Name,
+			Members:     members[org.ID],
+			Meta: &scim.Meta{
+				ResourceType: "Group",
+				Created:      org.Metadata.CreatedAt,
+				LastModified: org.Metadata.UpdatedAt,
+			},
+		})
+	}
+	return resources, nil
+}
+
+// members resolves the members of a SCIM group to the IDs of existing users.
+// Returns a SCIM error if a member is not an existing user.
+func (uc ProvisioningUseCase) members(ctx context.Context, refs []scim.Reference) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0, len(refs))
+	for _, ref := range refs {
+		user, err := uc.readUser(ctx, ref.Value)
+		if err != nil {
+			return nil, scim.BadRequest(scim.ErrInvalidValue, "member %q is not a user", ref.Value)
+		}
+		ids = append(ids, user.ID)
+	}
+	return ids, nil
+}
+
+// syncMembers makes the given users the only members of an organization.
+// New members join with the member role, and existing members keep their role.
+// ctx: The context for the operation.
+// orgID: The ID of the organization.
+// userIDs: The IDs of the users that must be members.
+// Returns an error if the operation fails.
+func (uc ProvisioningUseCase) syncMembers(ctx context.Context, orgID uuid.UUID, userIDs []uuid.UUID) error {
+	ctx = database.WithTenant(ctx, orgID)
+	current, err := uc.memberships.ReadAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[uuid.UUID]bool, len(userIDs))
+	for _, id := range userIDs {
+		wanted[id] = true
+	}
+	for _, membership := range current {
+		if wanted[membership.UserID] {
+			delete(wanted, membership.UserID)
+			continue
+		}
+		if err := uc.memberships.Delete(ctx, membership.ID); err != nil {
+			return err
+		}
+	}
+	for _, id := range userIDs {
+		if !wanted[id] {
+			continue
+		}
+		delete(wanted, id)
+		membership := entities.Membership{ID: uuid.New(), UserID: id, Role: entities.RoleMember}
+		if err := uc.memberships.Create(ctx, membership); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// slug derives a free organization slug from the display name of a group.
+// A number is appended on a collision, up to a bound so that a flood of groups with the same name cannot make it loop forever.
+// ctx: The context for the operation.
+// name: The display name of the group.
+// Returns the slug, a SCIM error with the 409 status code if no free slug is found, and an error if the operation fails.
+func (uc ProvisioningUseCase) slug(ctx context.Context, name string) (string, error) {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) && b.Len() < 56 {
+			b.WriteRune(r)
+		}
+	}
+	base := b.String()
+	if len(base) < 2 {
+		base = "group"
+	}
+	for i := 1; i < 1000; i++ {
+		slug := base
+		if i > 1 {
+			slug += strconv.Itoa(i)
+		}
+		_, err := uc.orgs.ReadBySlug(ctx, slug)
+		if errors.Is(err, database.ErrNotFound) {
+			return slug, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", scim.Conflict("no free slug for the displayName %q", name)
+}
+
+// retries is the number of attempts of a modification that applies to the current version of a user,
+// which is read again whenever a concurrent modification wins the race.
+const retries = 3
+
+// retry runs a read-modify-write of a user until it is not based on an outdated version.
+// A modification based on the version the client read is never retried, since the client has to see the new version first.
+// version: The version the client based the modification on, or 0.
+// fn: The read-modify-write.
+// Returns the error of the last attempt.
+func retry(version int64, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if version != 0 || attempt == retries || !errors.Is(err, database.ErrStale) {
+			return err
+		}
+	}
+}
+
+// page applies the filter and the pagination of a query to resources.
+// resources: The resources to query.
+// q: The filter and the pagination of the query.
+// Returns the resources of the page, the number of resources that match the filter and an error if a resource cannot be serialized.
+func page[T any](resources []T, q scim.Query) ([]T, int, error) {
+	objects := make([]map[string]interface{}, 0, len(resources))
+	for _, resource := range resources {
+		object, err := scim.ToMap(resource)
+		if err != nil {
+			return nil, 0, err
+		}
+		objects = append(objects, object)
+	}
+	indexes, total := q.Page(objects)
+	result := make([]T, 0, len(indexes))
+	for _, i := range indexes {
+		result = append(result, resources[i])
+	}
+	return result, total, nil
+}
+
+// uuidValue converts the ID of a resource to the ID of its record. The IDs that are not UUIDs in their canonical form
+// are left to the filter, which compares them as they are.
+func uuidValue(value interface{}) (interface{}, bool) {
+	text, ok := value.(string)
+	if !ok {
+		return nil, false
+	}
+	id, err := uuid.Parse(text)
+	if err != nil || id.String() != text {
+		return nil, false
+	}
+	return id, true
+}
+
+// inactive converts the active attribute of a user to the deactivated column.
+func inactive(value interface{}) (interface{}, bool) {
+	active, ok := value.(bool)
+	return !active, ok
+}
+
+// validateGroup checks the attributes of the organization of a group.
+// Returns a SCIM error if the display name is not valid.
+func validateGroup(org entities.Organization) error {
+	if err := validator.New().Struct(org); err != nil {
+		return scim.BadRequest(scim.ErrInvalidValue, "displayName is required and must be no more than 100 characters long")
+	}
+	return nil
+}
+
+// randomPassword generates a password nobody knows, for the users provisioned without one.
+func randomPassword() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,39 @@
+// Package webhook provides the functionality to deliver the domain events to the HTTP endpoints of the customers.
+package webhook
+
+import "github.com/labstack/echo/v4"
+
+// Handlers is an interface that defines the methods required for handling webhook operations.
+type Handlers interface {
+	// Create handles the registration of an endpoint.
+	// Returns an echo.HandlerFunc that handles the HTTP request for registering an endpoint.
+	Create() echo.HandlerFunc
+
+	// List handles the retrieval of the endpoints.
+	// Returns an echo.HandlerFunc that handles the HTTP request for listing the endpoints.
+	List() echo.HandlerFunc
+
+	// Read handles the retrieval of an endpoint.
+	// Returns an echo.HandlerFunc that handles the HTTP request for reading an endpoint.
+	Read() echo.HandlerFunc
+
+	// Update handles the modification of an endpoint.
+	// Returns an echo.HandlerFunc that handles the HTTP request for modifying an endpoint.
+	Update() echo.HandlerFunc
+
+	// Delete handles the removal of an endpoint.
+	// Returns an echo.HandlerFunc that handles the HTTP request for removing an endpoint.
+	Delete() echo.HandlerFunc
+
+	// RotateSecret handles the rotation of the secret of an endpoint.
+	// Returns an echo.HandlerFunc that handles the HTTP request for rotating the secret of an endpoint.
+	RotateSecret() echo.HandlerFunc
+
+	// Deliveries handles the retrieval of the delivery history of an endpoint.
+	// Returns an echo.HandlerFunc that handles the HTTP request for listing the deliveries of an endpoint.
+	Deliveries() echo.HandlerFunc
+
+	// Redeliver handles sending a delivery again.
+	// Returns an echo.HandlerFunc that handles the HTTP request for redelivering a delivery.
+	Redeliver() echo.HandlerFunc
+}
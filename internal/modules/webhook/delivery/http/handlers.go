@@ -0,0 +1,234 @@
+// Package http provides the functionality to handle HTTP requests for the webhook module.
+package http
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"                                      // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/config"                   // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/app"             // App package provides the functionality to map the errors of the storage layer to HTTP status codes.
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"        // Entities package provides the functionality to interact with the entities of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/webhook" // Webhook package provides the functionality to interact with the webhook module.
+	"net/http"
+)
+
+// WebhookHandlers struct represents webhook handlers that provide methods for handling HTTP requests for the webhook module.
+type WebhookHandlers struct {
+	cfg       *config.Config  // The configuration for the webhook handlers.
+	webhookUC webhook.UseCase // The webhook use case for the webhook handlers.
+}
+
+// endpointResponse struct represents an endpoint together with its secret, which is only returned when it is generated.
+type endpointResponse struct {
+	entities.WebhookEndpoint
+	Secret string `json:"secret"`
+}
+
+// NewWebhookHandlers creates new webhook handlers with the provided configuration and webhook use case.
+// cfg: The configuration for the webhook handlers.
+// webhookUC: The webhook use case for the webhook handlers.
+// Returns a WebhookHandlers object.
+func NewWebhookHandlers(cfg *config.Config, webhookUC webhook.UseCase) *WebhookHandlers {
+	return &WebhookHandlers{
+		cfg:       cfg,
+		webhookUC: webhookUC,
+	}
+}
+
+// Create registers an endpoint.
+// @route POST /admin/webhooks
+// @group Webhooks
+// @param {WebhookEndpoint.model} endpoint.body.required - URL, and optionally description, event types and state, of the endpoint
+// @returns {object} 201 - The endpoint and its secret
+// @returns {object} 400 - The request could not be understood or the endpoint is invalid.
+// @returns {object} 401 - Unauthorized access
+func (h *WebhookHandlers) Create() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var endpoint entities.WebhookEndpoint
+		if err := c.Bind(&endpoint); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "failed to bind webhook endpoint")
+		}
+
+		endpoint, err := h.webhookUC.Create(c.Request().Context(), endpoint)
+		if err != nil {
+			return statusError(err, "failed to create webhook endpoint")
+		}
+		return c.JSON(http.StatusCreated, endpointResponse{WebhookEndpoint: endpoint, Secret: endpoint.Secret})
+	}
+}
+
+// List retrieves the endpoints.
+// @route GET /admin/webhooks
+// @group Webhooks
+// @returns {Array} 200 - An array of endpoints, oldest first
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 500 - Server error
+func (h *WebhookHandlers) List() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		endpoints, err := h.webhookUC.List(c.Request().Context())
+		if err != nil {
+			return statusError(err, "failed to list webhook endpoints")
+		}
+		return c.JSON(http.StatusOK, endpoints)
+	}
+}
+
+// Read retrieves an endpoint.
+// @route GET /admin/webhooks/:id
+// @group Webhooks
+// @returns {object} 200 - The endpoint
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 404 - There is no endpoint with the given ID.
+func (h *WebhookHandlers) Read() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid webhook endpoint id")
+		}
+
+		endpoint, err := h.webhookUC.Read(c.Request().Context(), id)
+		if err != nil {
+			return statusError(err, "failed to read webhook endpoint")
+		}
+		return c.JSON(http.StatusOK, endpoint)
+	}
+}
+
+// Update modifies the URL, description, event types and state of an endpoint.
+// @route PUT /admin/webhooks/:id
+// @group Webhooks
+// @param {WebhookEndpoint.model} endpoint.body.required - URL, description, event types, state and optionally version of the endpoint
+// @returns {object} 200 - The modified endpoint
+// @returns {object} 400 - The request could not be understood or the endpoint is invalid.
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 404 - There is no endpoint with the given ID.
+// @returns {object} 412 - The endpoint was modified since the given version.
+func (h *WebhookHandlers) Update() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid webhook endpoint id")
+		}
+		var endpoint entities.WebhookEndpoint
+		if err := c.Bind(&endpoint); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "failed to bind webhook endpoint")
+		}
+		endpoint.ID = id
+
+		endpoint, err = h.webhookUC.Update(c.Request().Context(), endpoint)
+		if err != nil {
+			return statusError(err, "failed to update webhook endpoint")
+		}
+		return c.JSON(http.StatusOK, endpoint)
+	}
+}
+
+// Delete removes an endpoint and its deliveries.
+// @route DELETE /admin/webhooks/:id
+// @group Webhooks
+// @returns {object} 204 - The endpoint was removed.
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 404 - There is no endpoint with the given ID.
+func (h *WebhookHandlers) Delete() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid webhook endpoint id")
+		}
+
+		if err := h.webhookUC.Delete(c.Request().Context(), id); err != nil {
+			return statusError(err, "failed to delete webhook endpoint")
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// RotateSecret replaces the secret of an endpoint. The previous secret signs the deliveries as well for the configured grace period.
+// @route POST /admin/webhooks/:id/rotate-secret
+// @group Webhooks
+// @returns {object} 200 - The endpoint and its new secret
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 404 - There is no endpoint with the given ID.
+func (h *WebhookHandlers) RotateSecret() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid webhook endpoint id")
+		}
+
+		endpoint, err := h.webhookUC.RotateSecret(c.Request().Context(), id)
+		if err != nil {
+			return statusError(err, "failed to rotate webhook secret")
+		}
+		return c.JSON(http.StatusOK, endpointResponse{WebhookEndpoint: endpoint, Secret: endpoint.Secret})
+	}
+}
+
+// Deliveries retrieves the delivery history of an endpoint.
+// @route GET /admin/webhooks/:id/deliveries
+// @group Webhooks
+// @param {string} status.query - Status of the deliveries: pending, succeeded or dead
+// @param {integer} limit.query - Maximum number of deliveries
+// @param {integer} offset.query - Number of deliveries to skip
+// @returns {Array} 200 - An array of deliveries, most recent first
+// @returns {object} 400 - The query parameters are invalid.
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 404 - There is no endpoint with the given ID.
+func (h *WebhookHandlers) Deliveries() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid webhook endpoint id")
+		}
+		var filter entities.WebhookDeliveryFilter
+		if err := c.Bind(&filter); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "failed to bind webhook delivery filter")
+		}
+
+		deliveries, err := h.webhookUC.Deliveries(c.Request().Context(), id, filter)
+		if err != nil {
+			return statusError(err, "failed to list webhook deliveries")
+		}
+		return c.JSON(http.StatusOK, deliveries)
+	}
+}
+
+// Redeliver sends a delivery again as soon as possible.
+// @route POST /admin/webhooks/:id/deliveries/:delivery/redeliver
+// @group Webhooks
+// @returns {object} 202 - The pending delivery
+// @returns {object} 401 - Unauthorized access
+// @returns {object} 404 - The endpoint has no delivery with the given ID.
+func (h *WebhookHandlers) Redeliver() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid webhook endpoint id")
+		}
+		deliveryID, err := uuid.Parse(c.Param("delivery"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid webhook delivery id")
+		}
+
+		delivery, err := h.webhookUC.Redeliver(c.Request().Context(), id, deliveryID)
+		if err != nil {
+			return statusError(err, "failed to redeliver webhook delivery")
+		}
+		return c.JSON(http.StatusAccepted, delivery)
+	}
+}
+
+// statusError converts an error of the webhook use case into an HTTP error.
+// err: The error to convert.
+// message: The message describing the failed operation.
+// Returns an *echo.HTTPError with 400 for invalid endpoints, the status code of the storage errors and 500 otherwise.
+func statusError(err error, message string) error {
+	status := app.StatusCode(err, http.StatusInternalServerError)
+	var invalid validator.ValidationErrors
+	if errors.As(err, &invalid) {
+		status = http.StatusBadRequest
+	}
+	return echo.NewHTTPError(status, fmt.Sprintf("%s: %v", message, err))
+}
@@ -0,0 +1,30 @@
+// Package http provides the functionality to map the routes of the webhook module over HTTP.
+package http
+
+import (
+	"github.com/labstack/echo/v4"                                      // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/webhook" // Webhook package provides the functionality to interact with the webhook module.
+)
+
+// MapWebhookRoutes maps the webhook routes to the provided Echo group with the provided webhook handlers.
+// webhookGroup: The Echo group to map the routes to. It is expected to be protected by the admin authentication.
+// h: The webhook handlers to use for the routes.
+// The routes include:
+// POST /: Registers an endpoint.
+// GET /: Retrieves the endpoints.
+// GET /:id: Retrieves an endpoint.
+// PUT /:id: Modifies an endpoint.
+// DELETE /:id: Removes an endpoint and its deliveries.
+// POST /:id/rotate-secret: Replaces the secret of an endpoint.
+// GET /:id/deliveries: Retrieves the delivery history of an endpoint.
+// POST /:id/deliveries/:delivery/redeliver: Sends a delivery again.
+func MapWebhookRoutes(webhookGroup *echo.Group, h webhook.Handlers) {
+	webhookGroup.POST("", h.Create())
+	webhookGroup.GET("", h.List())
+	webhookGroup.GET("/:id", h.Read())
+	webhookGroup.PUT("/:id", h.Update())
+	webhookGroup.DELETE("/:id", h.Delete())
+	webhookGroup.POST("/:id/rotate-secret", h.RotateSecret())
+	webhookGroup.GET("/:id/deliveries", h.Deliveries())
+	webhookGroup.POST("/:id/deliveries/:delivery/redeliver", h.Redeliver())
+}
@@ -0,0 +1,33 @@
+// Package delivery provides the functionality to deliver the responses of the webhook module.
+package delivery
+
+import (
+	"github.com/labstack/echo/v4"                                                    // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/config"                                 // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/app"                           // App package provides the admin authentication middleware.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/webhook"               // Webhook package provides the functionality to interact with the webhook module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/webhook/delivery/http" // HTTP package provides the functionality to deliver the responses of the webhook module over HTTP.
+)
+
+// WebhookDelivery struct represents a webhook delivery that provides methods for delivering the responses of the webhook module.
+// It includes a WebhookHandlers object for handling the responses and a function for setting up the routes.
+type WebhookDelivery struct {
+	Handlers        *http.WebhookHandlers // The handlers for the webhook responses.
+	SetupRoutesFunc func(echo *echo.Echo) // The function for setting up the routes.
+}
+
+// NewWebhookDelivery creates a new webhook delivery with the provided configuration and webhook use case.
+// cfg: The configuration for the webhook delivery.
+// uc: The webhook use case for the webhook delivery.
+// Returns a WebhookDelivery object.
+func NewWebhookDelivery(cfg *config.Config, uc webhook.UseCase) *WebhookDelivery {
+	handlers := http.NewWebhookHandlers(cfg, uc) // Creates new webhook handlers with the provided configuration and webhook use case.
+
+	// Returns a new WebhookDelivery object with the created handlers and a function for setting up the routes.
+	return &WebhookDelivery{
+		Handlers: handlers,
+		SetupRoutesFunc: func(e *echo.Echo) {
+			http.MapWebhookRoutes(e.Group("/admin/webhooks", app.AdminAuth(cfg)), handlers) // Maps the webhook routes to the admin-protected "/admin/webhooks" group.
+		},
+	}
+}
@@ -0,0 +1,35 @@
+-- The endpoints the domain events are posted to, and the deliveries of the events to each of them.
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id {{.UUID}} NOT NULL,
+    url longtext NOT NULL,
+    description longtext,
+    event_types longtext,
+    disabled tinyint(1) NOT NULL DEFAULT '0',
+    secret longtext NOT NULL,
+    previous_secret longtext,
+    previous_secret_expires_at datetime(6) NULL DEFAULT NULL,
+    created_at datetime(6) NULL,
+    updated_at datetime(6) NULL,
+    version bigint NOT NULL DEFAULT 1,
+    PRIMARY KEY (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id {{.UUID}} NOT NULL,
+    endpoint_id {{.UUID}} NOT NULL,
+    event_id longtext NOT NULL,
+    event_type longtext NOT NULL,
+    payload longtext,
+    status varchar(191) NOT NULL,
+    attempts bigint NOT NULL DEFAULT '0',
+    next_attempt_at datetime(6) NOT NULL,
+    last_attempt_at datetime(6) NULL DEFAULT NULL,
+    response_status bigint,
+    response_body longtext,
+    last_error longtext,
+    created_at datetime(6) NULL,
+    updated_at datetime(6) NULL,
+    version bigint NOT NULL DEFAULT 1,
+    PRIMARY KEY (id),
+    KEY idx_webhook_deliveries_endpoint_id (endpoint_id),
+    KEY idx_webhook_deliveries_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
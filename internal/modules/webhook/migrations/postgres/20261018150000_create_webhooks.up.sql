@@ -0,0 +1,35 @@
+-- The endpoints the domain events are posted to, and the deliveries of the events to each of them.
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+    id uuid NOT NULL,
+    url text NOT NULL,
+    description text,
+    event_types text,
+    disabled boolean NOT NULL DEFAULT false,
+    secret text NOT NULL,
+    previous_secret text,
+    previous_secret_expires_at timestamptz DEFAULT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    version bigint NOT NULL DEFAULT 1,
+    PRIMARY KEY (id)
+);
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id uuid NOT NULL,
+    endpoint_id uuid NOT NULL,
+    event_id text NOT NULL,
+    event_type text NOT NULL,
+    payload text,
+    status text NOT NULL,
+    attempts bigint NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL,
+    last_attempt_at timestamptz DEFAULT NULL,
+    response_status bigint,
+    response_body text,
+    last_error text,
+    created_at timestamptz,
+    updated_at timestamptz,
+    version bigint NOT NULL DEFAULT 1,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_endpoint_id ON webhook_deliveries (endpoint_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status ON webhook_deliveries (status);
@@ -0,0 +1,141 @@
+// Package module provides the functionality to interact with the webhook module.
+package module
+
+import (
+	"context"                                                                         // Context package provides the functionality to pass deadlines and cancel signals to the workers.
+	"github.com/labstack/echo/v4"                                                     // Echo is a high performance, extensible, minimalist web framework for Go.
+	"github.com/nikita-voronoy/go-clean-arch/config"                                  // Config package provides the functionality to interact with the configuration of the application.
+	"github.com/nikita-voronoy/go-clean-arch/internal/app"                            // App package provides the admin authentication middleware.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/outbox"                 // Outbox package provides the functionality to relay the domain events of the outbox to a broker.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/webhook"                // Webhook package provides the functionality to interact with the webhook module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/webhook/delivery"       // Delivery package provides the functionality to deliver the responses of the webhook module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/webhook/delivery/http"  // HTTP package provides the functionality to deliver the responses of the webhook module over HTTP.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/webhook/migrations"     // Migrations package provides the SQL migrations of the schema of the webhook module.
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/webhook/usecase"        // Usecase package provides the functionality to manage the webhooks and deliver the domain events to them.
+	webhookstorage "github.com/nikita-voronoy/go-clean-arch/internal/storage/webhook" // Webhook storage package provides the functionality to interact with the webhook endpoint and delivery storage.
+	"go.uber.org/fx"                                                                  // Fx is a framework for Go that provides the building blocks for your service architectures.
+	"log"                                                                             // Log package provides the functionality to implement logging.
+	"time"                                                                            // Time package provides the functionality to schedule the workers.
+)
+
+// retentionInterval is the interval at which the finished deliveries that fall out of the retention window are purged.
+const retentionInterval = time.Hour
+
+// Migrations is a Fx option that registers the SQL migrations of the webhook module with the migrations group.
+var Migrations = fx.Supply(fx.Annotated{Group: "migrations", Target: migrations.Source})
+
+// Module is a Fx options group that provides and invokes the necessary dependencies for the webhook module.
+var Module = fx.Options(
+	Migrations, // Registers the SQL migrations of the webhook module.
+	fx.Provide(
+		webhookstorage.NewEndpointRepository,                             // Provides a new webhook endpoint repository.
+		webhookstorage.NewDeliveryRepository,                             // Provides a new webhook delivery repository.
+		usecase.NewWebhookUC,                                             // Provides a new webhook use case.
+		http.NewWebhookHandlers,                                          // Provides new webhook handlers.
+		delivery.NewWebhookDelivery,                                      // Provides a new webhook delivery.
+		fx.Annotated{Group: "outbox_subscribers", Target: newSubscriber}, // Subscribes the webhooks to the domain events the outbox relays.
+	),
+	fx.Invoke(registerWebhookRoutes), // Invokes the function to register the webhook routes.
+	fx.Invoke(registerDispatcher),    // Invokes the function to register the delivery worker.
+	fx.Invoke(registerRetention),     // Invokes the function to register the retention worker.
+)
+
+// newSubscriber subscribes the webhooks to the domain events the relay of the outbox publishes, if they are enabled.
+// cfg: The configuration that enables the webhooks.
+// uc: The webhook use case, which enqueues the deliveries of the events.
+// Returns the publisher of the webhooks, which is nil if they are disabled.
+func newSubscriber(cfg *config.Config, uc webhook.UseCase) outbox.Publisher {
+	if !cfg.Webhooks.Enabled {
+		return nil
+	}
+	return uc
+}
+
+// registerWebhookRoutes registers the webhook routes with the provided Echo instance and webhook handlers.
+// e: The Echo instance to register the routes with.
+// cfg: The configuration that contains the admin API key.
+// handlers: The webhook handlers to use for the routes.
+func registerWebhookRoutes(e *echo.Echo, cfg *config.Config, handlers *http.WebhookHandlers) {
+	http.MapWebhookRoutes(e.Group("/admin/webhooks", app.AdminAuth(cfg)), handlers) // Maps the webhook routes to the admin-protected "/admin/webhooks" group.
+}
+
+// registerDispatcher starts a worker that sends the deliveries that are due. It sends again at once while it finds
+// full batches, and otherwise waits for the configured interval.
+// lc: The lifecycle the worker is bound to.
+// cfg: The configuration that enables the webhooks and contains the interval.
+// uc: The webhook use case used to send the deliveries.
+func registerDispatcher(lc fx.Lifecycle, cfg *config.Config, uc webhook.UseCase) {
+	if !cfg.Webhooks.Enabled {
+		return
+	}
+	interval := time.Duration(cfg.Webhooks.IntervalMilliseconds) * time.Millisecond
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	runWorker(lc, func(ctx context.Context) time.Duration {
+		attempted, err := uc.Dispatch(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Failed to dispatch webhook deliveries: %v\n", err)
+			}
+			return interval
+		}
+		if cfg.Webhooks.BatchSize > 0 && attempted >= cfg.Webhooks.BatchSize {
+			return 0
+		}
+		return interval
+	})
+}
+
+// registerRetention starts a worker that periodically purges the finished deliveries older than the configured retention.
+// lc: The lifecycle the worker is bound to.
+// cfg: The configuration that contains the retention.
+// uc: The webhook use case used to purge the deliveries.
+func registerRetention(lc fx.Lifecycle, cfg *config.Config, uc webhook.UseCase) {
+	if cfg.Webhooks.RetentionHours <= 0 {
+		return
+	}
+
+	runWorker(lc, func(ctx context.Context) time.Duration {
+		if removed, err := uc.Purge(ctx); err != nil {
+			log.Printf("Failed to purge webhook deliveries: %v\n", err)
+		} else if removed > 0 {
+			log.Printf("Purged %d webhook deliveries\n", removed)
+		}
+		return retentionInterval
+	})
+}
+
+// runWorker runs a task in the background while the application runs, each time after the delay the previous run returned.
+func runWorker(lc fx.Lifecycle, task func(ctx context.Context) time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				timer := time.NewTimer(0)
+				defer timer.Stop()
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-timer.C:
+					}
+					timer.Reset(task(ctx))
+				}
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return stopCtx.Err()
+			}
+		},
+	})
+}
@@ -0,0 +1,78 @@
+// Package webhook provides the functionality to deliver the domain events to the HTTP endpoints of the customers.
+package webhook
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/outbox"
+)
+
+// UseCase is an interface that defines the methods required for webhook operations.
+// It includes methods for managing the endpoints, enqueuing, sending and redelivering the deliveries, and browsing their history.
+type UseCase interface {
+	// Publisher enqueues a delivery of each domain event the relay of the outbox publishes to every endpoint that subscribes to it.
+	// An event that is published again is not enqueued again.
+	outbox.Publisher
+
+	// Create registers an endpoint, with a new secret.
+	// ctx: The context for the operation.
+	// endpoint: The URL, description, event types and state of the endpoint.
+	// Returns the endpoint with its secret and an error if the operation fails.
+	Create(ctx context.Context, endpoint entities.WebhookEndpoint) (entities.WebhookEndpoint, error)
+
+	// List retrieves the endpoints, oldest first.
+	// ctx: The context for the operation.
+	// Returns the endpoints and an error if the operation fails.
+	List(ctx context.Context) ([]entities.WebhookEndpoint, error)
+
+	// Read retrieves an endpoint.
+	// ctx: The context for the operation.
+	// id: The ID of the endpoint.
+	// Returns the endpoint and an error if the operation fails.
+	Read(ctx context.Context, id uuid.UUID) (entities.WebhookEndpoint, error)
+
+	// Update modifies the URL, description, event types and state of an endpoint. Its secret is kept.
+	// ctx: The context for the operation.
+	// endpoint: The endpoint to modify. If it sets a version, the endpoint is only modified if it is still at that version.
+	// Returns the modified endpoint and an error if the operation fails.
+	Update(ctx context.Context, endpoint entities.WebhookEndpoint) (entities.WebhookEndpoint, error)
+
+	// Delete removes an endpoint and its deliveries.
+	// ctx: The context for the operation.
+	// id: The ID of the endpoint.
+	// Returns an error if the operation fails.
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	// RotateSecret replaces the secret of an endpoint. The deliveries are signed with the previous secret as well
+	// for the configured grace period, so that the receiver may switch to the new one in the meantime.
+	// ctx: The context for the operation.
+	// id: The ID of the endpoint.
+	// Returns the endpoint with its new secret and an error if the operation fails.
+	RotateSecret(ctx context.Context, id uuid.UUID) (entities.WebhookEndpoint, error)
+
+	// Deliveries retrieves the deliveries of an endpoint matching the filter, most recent first.
+	// ctx: The context for the operation.
+	// endpointID: The ID of the endpoint.
+	// filter: The status and window of the deliveries.
+	// Returns the deliveries and an error if the operation fails.
+	Deliveries(ctx context.Context, endpointID uuid.UUID, filter entities.WebhookDeliveryFilter) ([]entities.WebhookDelivery, error)
+
+	// Redeliver sends a delivery again as soon as possible, with a full set of attempts, whatever its status.
+	// ctx: The context for the operation.
+	// endpointID: The ID of the endpoint of the delivery.
+	// deliveryID: The ID of the delivery.
+	// Returns the pending delivery and an error if the operation fails.
+	Redeliver(ctx context.Context, endpointID, deliveryID uuid.UUID) (entities.WebhookDelivery, error)
+
+	// Dispatch sends the deliveries that are due. A failed delivery is retried later with an exponential backoff,
+	// until it is dead after the configured number of attempts.
+	// ctx: The context for the operation.
+	// Returns the number of deliveries that were attempted and an error if the deliveries cannot be read or written.
+	Dispatch(ctx context.Context) (int, error)
+
+	// Purge removes the succeeded and dead deliveries that are older than the configured retention.
+	// ctx: The context for the operation.
+	// Returns the number of removed deliveries and an error if the operation fails.
+	Purge(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,387 @@
+// Package usecase provides the functionality to manage the webhooks and deliver the domain events to them.
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/modules/webhook"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/attempt"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/signature"
+	"golang.org/x/sync/errgroup"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultQueryLimit is the number of deliveries returned by Deliveries when the filter sets no limit.
+	defaultQueryLimit = 100
+	// maxQueryLimit is the maximum number of deliveries returned by Deliveries.
+	maxQueryLimit = 1000
+	// maxResponseLength is the maximum length of the body of a response, and of the error of an attempt, that is stored with the delivery.
+	maxResponseLength = 1000
+	// userAgent is the user agent of the deliveries.
+	userAgent = "go-clean-arch-webhooks/1"
+)
+
+// payload struct represents the body of a delivery.
+// Type: The type of the event.
+// Timestamp: The time the event happened.
+// Data: The event.
+type payload struct {
+	Type      string          `json:"type"`
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// WebhookUseCase struct represents a webhook use case that manages the endpoints and sends the deliveries.
+// A worker claims a delivery with a lease before it sends it, and retries it after a backoff, as described in package attempt.
+type WebhookUseCase struct {
+	cfg        *config.Config
+	endpoints  storage.WebhookEndpointRepository
+	deliveries storage.WebhookDeliveryRepository
+	tx         storage.Transactor
+	client     *http.Client
+	now        func() time.Time
+}
+
+// NewWebhookUC creates a new webhook use case with the provided configuration, repositories and transactor.
+// cfg: The configuration for the webhook use case.
+// endpoints: The webhook endpoint repository for the webhook use case.
+// deliveries: The webhook delivery repository for the webhook use case.
+// tx: The transactions an endpoint and its deliveries are removed in.
+// Returns a webhook.UseCase object.
+func NewWebhookUC(cfg *config.Config, endpoints storage.WebhookEndpointRepository, deliveries storage.WebhookDeliveryRepository, tx storage.Transactor) webhook.UseCase {
+	return &WebhookUseCase{
+		cfg:        cfg,
+		endpoints:  endpoints,
+		deliveries: deliveries,
+		tx:         tx,
+		client: &http.Client{
+			Timeout: time.Duration(cfg.Webhooks.TimeoutSeconds) * time.Second,
+			// A redirect is a failure, so that a delivery is never posted again to a URL that was not registered.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		now: time.Now,
+	}
+}
+
+// Publish enqueues a delivery of a domain event to every enabled endpoint that subscribes to it.
+// ctx: The context for the operation.
+// message: The outbox message of the event.
+// Returns an error if the deliveries cannot be enqueued, in which case the event is published again later.
+func (uc *WebhookUseCase) Publish(ctx context.Context, message entities.OutboxMessage) error {
+	endpoints, err := uc.endpoints.ReadAll(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload{Type: message.Type, Timestamp: message.CreatedAt.UTC(), Data: json.RawMessage(message.Payload)})
+	if err != nil {
+		return err
+	}
+
+	eventID := strconv.FormatUint(message.ID, 10)
+	for _, endpoint := range endpoints {
+		if endpoint.Disabled || !endpoint.Subscribes(message.Type) {
+			continue
+		}
+		err := uc.deliveries.Create(ctx, entities.WebhookDelivery{
+			ID:            uuid.NewSHA1(endpoint.ID, []byte(eventID)),
+			EndpointID:    endpoint.ID,
+			EventID:       eventID,
+			EventType:     message.Type,
+			Payload:       string(body),
+			Status:        entities.WebhookDeliveryPending,
+			NextAttemptAt: uc.now().UTC(),
+		})
+		if err != nil && !errors.Is(err, database.ErrConflict) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Create registers an endpoint, with a new secret.
+// ctx: The context for the operation.
+// endpoint: The URL, description, event types and state of the endpoint.
+// Returns the endpoint with its secret and an error if the operation fails.
+func (uc *WebhookUseCase) Create(ctx context.Context, endpoint entities.WebhookEndpoint) (entities.WebhookEndpoint, error) {
+	if err := validator.New().Struct(endpoint); err != nil {
+		return entities.WebhookEndpoint{}, err
+	}
+	secret, err := signature.NewSecret()
+	if err != nil {
+		return entities.WebhookEndpoint{}, err
+	}
+	return uc.endpoints.Create(ctx, entities.WebhookEndpoint{
+		ID:          uuid.New(),
+		URL:         endpoint.URL,
+		Description: endpoint.Description,
+		Events:      endpoint.Events,
+		Disabled:    endpoint.Disabled,
+		Secret:      secret,
+	})
+}
+
+// List retrieves the endpoints, oldest first.
+// ctx: The context for the operation.
+// Returns the endpoints and an error if the operation fails.
+func (uc *WebhookUseCase) List(ctx context.Context) ([]entities.WebhookEndpoint, error) {
+	return uc.endpoints.ReadAll(ctx)
+}
+
+// Read retrieves an endpoint.
+// ctx: The context for the operation.
+// id: The ID of the endpoint.
+// Returns the endpoint and an error if the operation fails.
+func (uc *WebhookUseCase) Read(ctx context.Context, id uuid.UUID) (entities.WebhookEndpoint, error) {
+	return uc.endpoints.Read(ctx, id)
+}
+
+// Update modifies the URL, description, event types and state of an endpoint. Its secret is kept.
+// ctx: The context for the operation.
+// endpoint: The endpoint to modify. If it sets a version, the endpoint is only modified if it is still at that version.
+// Returns the modified endpoint and an error if the operation fails.
+func (uc *WebhookUseCase) Update(ctx context.Context, endpoint entities.WebhookEndpoint) (entities.WebhookEndpoint, error) {
+	if err := validator.New().Struct(endpoint); err != nil {
+		return entities.WebhookEndpoint{}, err
+	}
+	stored, err := uc.endpoints.Read(ctx, endpoint.ID)
+	if err != nil {
+		return entities.WebhookEndpoint{}, err
+	}
+	stored.URL = endpoint.URL
+	stored.Description = endpoint.Description
+	stored.Events = endpoint.Events
+	stored.Disabled = endpoint.Disabled
+	if endpoint.Version != 0 {
+		stored.Version = endpoint.Version
+	}
+	return uc.endpoints.Save(ctx, stored)
+}
+
+// Delete removes an endpoint and its deliveries.
+// ctx: The context for the operation.
+// id: The ID of the endpoint.
+// Returns an error if the operation fails.
+func (uc *WebhookUseCase) Delete(ctx context.Context, id uuid.UUID) error {
+	return uc.tx.WithTx(ctx, func(ctx context.Context) error {
+		if _, err := uc.endpoints.Read(ctx, id); err != nil {
+			return err
+		}
+		if _, err := uc.deliveries.DeleteByEndpoint(ctx, id); err != nil {
+			return err
+		}
+		return uc.endpoints.Delete(ctx, id)
+	})
+}
+
+// RotateSecret replaces the secret of an endpoint. The deliveries are signed with the previous secret as well
+// for the configured grace period, so that the receiver may switch to the new one in the meantime.
+// ctx: The context for the operation.
+// id: The ID of the endpoint.
+// Returns the endpoint with its new secret and an error if the operation fails.
+func (uc *WebhookUseCase) RotateSecret(ctx context.Context, id uuid.UUID) (entities.WebhookEndpoint, error) {
+	endpoint, err := uc.endpoints.Read(ctx, id)
+	if err != nil {
+		return entities.WebhookEndpoint{}, err
+	}
+	secret, err := signature.NewSecret()
+	if err != nil {
+		return entities.WebhookEndpoint{}, err
+	}
+	endpoint.PreviousSecret, endpoint.PreviousSecretExpiresAt = "", time.Time{}
+	if grace := time.Duration(uc.cfg.Webhooks.RotationGraceHours) * time.Hour; grace > 0 {
+		endpoint.PreviousSecret, endpoint.PreviousSecretExpiresAt = endpoint.Secret, uc.now().UTC().Add(grace)
+	}
+	endpoint.Secret = secret
+	return uc.endpoints.Save(ctx, endpoint)
+}
+
+// Deliveries retrieves the deliveries of an endpoint matching the filter, most recent first.
+// ctx: The context for the operation.
+// endpointID: The ID of the endpoint.
+// filter: The status and window of the deliveries.
+// Returns the deliveries and an error if the operation fails.
+func (uc *WebhookUseCase) Deliveries(ctx context.Context, endpointID uuid.UUID, filter entities.WebhookDeliveryFilter) ([]entities.WebhookDelivery, error) {
+	if _, err := uc.endpoints.Read(ctx, endpointID); err != nil {
+		return nil, err
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultQueryLimit
+	}
+	if filter.Limit > maxQueryLimit {
+		filter.Limit = maxQueryLimit
+	}
+	return uc.deliveries.ReadByEndpoint(ctx, endpointID, filter)
+}
+
+// Redeliver sends a delivery again as soon as possible, with a full set of attempts, whatever its status.
+// ctx: The context for the operation.
+// endpointID: The ID of the endpoint of the delivery.
+// deliveryID: The ID of the delivery.
+// Returns the pending delivery and an error if the operation fails.
+func (uc *WebhookUseCase) Redeliver(ctx context.Context, endpointID, deliveryID uuid.UUID) (entities.WebhookDelivery, error) {
+	delivery, err := uc.deliveries.Read(ctx, deliveryID)
+	if err != nil {
+		return entities.WebhookDelivery{}, err
+	}
+	if delivery.EndpointID != endpointID {
+		return entities.WebhookDelivery{}, database.ErrNotFound
+	}
+	delivery.Status = entities.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = uc.now().UTC()
+	return uc.deliveries.Save(ctx, delivery)
+}
+
+// Dispatch sends the deliveries that are due, several at once. A failed delivery is retried later with an exponential backoff,
+// until it is dead after the configured number of attempts.
+// ctx: The context for the operation.
+// Returns the number of deliveries that were attempted and an error if the deliveries cannot be read or written.
+func (uc *WebhookUseCase) Dispatch(ctx context.Context) (int, error) {
+	due, err := uc.deliveries.Due(ctx, uc.now(), uc.cfg.Webhooks.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		mu        sync.Mutex
+		endpoints = make(map[uuid.UUID]entities.WebhookEndpoint)
+		attempted atomic.Int64
+		group     errgroup.Group
+	)
+	// endpoint reads the endpoint of a delivery once per dispatch.
+	endpoint := func(id uuid.UUID) (entities.WebhookEndpoint, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if endpoint, ok := endpoints[id]; ok {
+			return endpoint, nil
+		}
+		endpoint, err := uc.endpoints.Read(ctx, id)
+		if err == nil {
+			endpoints[id] = endpoint
+		}
+		return endpoint, err
+	}
+	if uc.cfg.Webhooks.Concurrency > 0 {
+		group.SetLimit(uc.cfg.Webhooks.Concurrency)
+	}
+	for _, delivery := range due {
+		delivery := delivery
+		group.Go(func() error {
+			endpoint, err := endpoint(delivery.EndpointID)
+			if err != nil && !errors.Is(err, database.ErrNotFound) {
+				return err
+			}
+			ok, err := uc.deliver(ctx, delivery, endpoint, err == nil)
+			if ok {
+				attempted.Add(1)
+			}
+			return err
+		})
+	}
+	err = group.Wait()
+	return int(attempted.Load()), err
+}
+
+// Purge removes the succeeded and dead deliveries that are older than the configured retention.
+// ctx: The context for the operation.
+// Returns the number of removed deliveries and an error if the operation fails.
+func (uc *WebhookUseCase) Purge(ctx context.Context) (int64, error) {
+	if uc.cfg.Webhooks.RetentionHours <= 0 {
+		return 0, nil
+	}
+	return uc.deliveries.DeleteFinishedBefore(ctx, uc.now().Add(-time.Duration(uc.cfg.Webhooks.RetentionHours)*time.Hour))
+}
+
+// deliver claims and sends a delivery, and records the outcome. The deliveries of a removed or disabled endpoint are dead.
+// Returns true if the delivery was attempted, and false if it is not due or was claimed by another worker.
+func (uc *WebhookUseCase) deliver(ctx context.Context, delivery entities.WebhookDelivery, endpoint entities.WebhookEndpoint, found bool) (bool, error) {
+	now := uc.now().UTC()
+	if delivery.NextAttemptAt.After(now) {
+		return false, nil
+	}
+	if !found || endpoint.Disabled {
+		delivery.Status = entities.WebhookDeliveryDead
+		delivery.LastError = "the endpoint is disabled"
+		if !found {
+			delivery.LastError = "the endpoint was removed"
+		}
+		return false, uc.save(ctx, delivery)
+	}
+
+	delivery.NextAttemptAt = attempt.Lease(now, time.Duration(uc.cfg.Webhooks.LeaseSeconds)*time.Second)
+	delivery, err := uc.deliveries.Save(ctx, delivery)
+	if errors.Is(err, database.ErrStale) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	delivery.Attempts++
+	delivery.LastAttemptAt = now
+	delivery.ResponseStatus, delivery.ResponseBody, err = uc.send(ctx, delivery, endpoint, now)
+	switch {
+	case err == nil:
+		delivery.Status = entities.WebhookDeliverySucceeded
+		delivery.LastError = ""
+	case delivery.Attempts >= uc.cfg.Webhooks.MaxAttempts:
+		delivery.Status = entities.WebhookDeliveryDead
+		delivery.LastError = attempt.Truncate(err.Error(), maxResponseLength)
+	default:
+		delivery.LastError = attempt.Truncate(err.Error(), maxResponseLength)
+		delivery.NextAttemptAt = uc.now().UTC().Add(attempt.Backoff(delivery.Attempts,
+			time.Duration(uc.cfg.Webhooks.RetryBaseSeconds)*time.Second, time.Duration(uc.cfg.Webhooks.RetryMaxSeconds)*time.Second))
+	}
+	return true, uc.save(ctx, delivery)
+}
+
+// send posts a delivery to its endpoint, signed with the secrets of the endpoint.
+// Returns the status code and the beginning of the body of the response, and an error unless the status code is 2xx.
+func (uc *WebhookUseCase) send(ctx context.Context, delivery entities.WebhookDelivery, endpoint entities.WebhookEndpoint, now time.Time) (int, string, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	if err := signature.SetHeaders(req.Header, delivery.ID.String(), now, body, endpoint.SecretsAt(now)...); err != nil {
+		return 0, "", err
+	}
+
+	resp, err := uc.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseLength))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, attempt.Truncate(string(response), maxResponseLength), fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, attempt.Truncate(string(response), maxResponseLength), nil
+}
+
+// save records the outcome of a delivery. A delivery that was modified in the meantime, e.g. redelivered, keeps the modification.
+func (uc *WebhookUseCase) save(ctx context.Context, delivery entities.WebhookDelivery) error {
+	if _, err := uc.deliveries.Save(ctx, delivery); err != nil && !errors.Is(err, database.ErrStale) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	webhookstorage "github.com/nikita-voronoy/go-clean-arch/internal/storage/webhook"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/memory"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/signature"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver struct represents a webhook receiver that verifies the signatures of the requests with its secret at the time of the test,
+// and answers with the status codes it is told to, then 204.
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	now      *time.Time
+	secret   string
+	statuses []int
+	received []http.Header
+	bodies   []string
+	invalid  int
+}
+
+func newReceiver(t *testing.T, now *time.Time, statuses ...int) *receiver {
+	r := &receiver{now: now, statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if err := signature.Verify(req.Header, body, r.secret, 5*time.Minute, *r.now); err != nil {
+			r.invalid++
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.received = append(r.received, req.Header.Clone())
+		r.bodies = append(r.bodies, string(body))
+		status := http.StatusNoContent
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("received"))
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.received)
+}
+
+func newTestUC(t *testing.T) (*WebhookUseCase, storage.WebhookDeliveryRepository, *time.Time) {
+	cfg := &config.Config{
+		Webhooks: config.WebhookConfig{BatchSize: 10, Concurrency: 2, TimeoutSeconds: 5, MaxAttempts: 3, LeaseSeconds: 30, RetryBaseSeconds: 10, RetryMaxSeconds: 60, RotationGraceHours: 1, RetentionHours: 1},
+	}
+	db := memory.NewDatabase()
+	deliveries := webhookstorage.NewDeliveryRepository(db)
+	uc := NewWebhookUC(cfg, webhookstorage.NewEndpointRepository(db), deliveries, storage.NewTransactor(db)).(*WebhookUseCase)
+	now := time.Now().UTC()
+	uc.now = func() time.Time { return now }
+	return uc, deliveries, &now
+}
+
+// register registers an endpoint of the receiver, which learns its secret.
+func register(t *testing.T, uc *WebhookUseCase, r *receiver, events ...string) entities.WebhookEndpoint {
+	endpoint, err := uc.Create(context.Background(), entities.WebhookEndpoint{URL: r.URL, Events: events})
+	require.NoError(t, err)
+	r.secret = endpoint.Secret
+	return endpoint
+}
+
+func publish(t *testing.T, uc *WebhookUseCase, id uint64, eventType string) {
+	message, err := entities.NewOutboxMessage(entities.AggregateUser, "alice", eventType, entities.UserEvent{Username: "alice"})
+	require.NoError(t, err)
+	message.ID = id
+	require.NoError(t, uc.Publish(context.Background(), message))
+}
+
+func TestDeliver(t *testing.T) {
+	uc, _, now := newTestUC(t)
+	ctx := context.Background()
+	all, users, deletions := newReceiver(t, now), newReceiver(t, now), newReceiver(t, now)
+	register(t, uc, all)
+	usersEndpoint := register(t, uc, users, "user.*")
+	register(t, uc, deletions, entities.EventUserDeleted)
+	disabled := register(t, uc, newReceiver(t, now))
+	disabled.Disabled = true
+	_, err := uc.Update(ctx, disabled)
+	require.NoError(t, err)
+
+	publish(t, uc, 1, entities.EventUserRegistered)
+	publish(t, uc, 1, entities.EventUserRegistered)
+	publish(t, uc, 2, "organization.created")
+
+	attempted, err := uc.Dispatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempted, "an event published again is delivered once to each subscribed endpoint")
+	assert.Equal(t, 2, all.count())
+	assert.Equal(t, 1, users.count())
+	assert.Zero(t, deletions.count(), "an endpoint only receives the events it subscribes to")
+	assert.Zero(t, all.invalid+users.invalid, "the deliveries are signed with the secret of their endpoint")
+
+	var body struct {
+		Type string             `json:"type"`
+		Data entities.UserEvent `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(users.bodies[0]), &body))
+	assert.Equal(t, entities.EventUserRegistered, body.Type)
+	assert.Equal(t, "alice", body.Data.Username)
+
+	history, err := uc.Deliveries(ctx, usersEndpoint.ID, entities.WebhookDeliveryFilter{})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, entities.WebhookDeliverySucceeded, history[0].Status)
+	assert.Equal(t, http.StatusNoContent, history[0].ResponseStatus)
+	assert.Equal(t, 1, history[0].Attempts)
+	assert.Equal(t, history[0].ID.String(), users.received[0].Get(signature.HeaderID), "the delivery is identified to the receiver")
+
+	attempted, err = uc.Dispatch(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, attempted, "a succeeded delivery is not sent again")
+}
+
+func TestRetriesAndDeadLetter(t *testing.T) {
+	uc, deliveries, now := newTestUC(t)
+	ctx := context.Background()
+	flaky := newReceiver(t, now, http.StatusInternalServerError, http.StatusBadGateway)
+	flakyEndpoint := register(t, uc, flaky)
+	broken := newReceiver(t, now, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+	brokenEndpoint := register(t, uc, broken)
+	publish(t, uc, 1, entities.EventUserRegistered)
+
+	_, err := uc.Dispatch(ctx)
+	require.NoError(t, err)
+	history, err := uc.Deliveries(ctx, flakyEndpoint.ID, entities.WebhookDeliveryFilter{})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, entities.WebhookDeliveryPending, history[0].Status)
+	assert.Equal(t, http.StatusInternalServerError, history[0].ResponseStatus)
+	assert.Equal(t, "received", history[0].ResponseBody)
+	assert.Equal(t, "unexpected status 500", history[0].LastError)
+	assert.Equal(t, now.Add(10*time.Second), history[0].NextAttemptAt.UTC(), "a failed delivery is retried after the backoff")
+
+	attempted, err := uc.Dispatch(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, attempted, "a failed delivery waits for the backoff")
+
+	*now = now.Add(10 * time.Second)
+	_, err = uc.Dispatch(ctx)
+	require.NoError(t, err)
+	history, err = uc.Deliveries(ctx, flakyEndpoint.ID, entities.WebhookDeliveryFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(20*time.Second), history[0].NextAttemptAt.UTC(), "the backoff doubles with every failure")
+
+	*now = now.Add(20 * time.Second)
+	_, err = uc.Dispatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, flaky.count())
+	history, err = uc.Deliveries(ctx, flakyEndpoint.ID, entities.WebhookDeliveryFilter{Status: entities.WebhookDeliverySucceeded})
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, 3, history[0].Attempts)
+	assert.Empty(t, history[0].LastError)
+	ids := map[string]bool{}
+	for _, header := range flaky.received {
+		ids[header.Get(signature.HeaderID)] = true
+	}
+	assert.Len(t, ids, 1, "the retries share the ID of the delivery")
+
+	dead, err := uc.Deliveries(ctx, brokenEndpoint.ID, entities.WebhookDeliveryFilter{Status: entities.WebhookDeliveryDead})
+	require.NoError(t, err)
+	require.Len(t, dead, 1, "a delivery is dead after the maximum number of attempts")
+	assert.Equal(t, 3, dead[0].Attempts)
+	*now = now.Add(time.Hour)
+	attempted, err = uc.Dispatch(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, attempted, "a dead delivery is not retried")
+
+	_, err = uc.Redeliver(ctx, flakyEndpoint.ID, dead[0].ID)
+	assert.ErrorIs(t, err, database.ErrNotFound, "a delivery is redelivered through its endpoint")
+	redelivered, err := uc.Redeliver(ctx, brokenEndpoint.ID, dead[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, entities.WebhookDeliveryPending, redelivered.Status)
+	assert.Zero(t, redelivered.Attempts)
+	_, err = uc.Dispatch(ctx)
+	require.NoError(t, err)
+	_, err = uc.Dispatch(ctx)
+	require.NoError(t, err)
+	stored, err := deliveries.Read(ctx, dead[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, entities.WebhookDeliveryPending, stored.Status)
+	*now = now.Add(10 * time.Second)
+	_, err = uc.Dispatch(ctx)
+	require.NoError(t, err)
+	stored, err = deliveries.Read(ctx, dead[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, entities.WebhookDeliverySucceeded, stored.Status, "a redelivered delivery gets a full set of attempts")
+	assert.Equal(t, 5, broken.count())
+
+	*now = now.Add(2 * time.Hour)
+	removed, err := uc.Purge(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), removed)
+}
+
+func TestRotateSecret(t *testing.T) {
+	uc, _, now := newTestUC(t)
+	ctx := context.Background()
+	r := newReceiver(t, now)
+	endpoint := register(t, uc, r)
+	previous := endpoint.Secret
+
+	rotated, err := uc.RotateSecret(ctx, endpoint.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, previous, rotated.Secret)
+	assert.Equal(t, now.Add(time.Hour), rotated.PreviousSecretExpiresAt.UTC())
+
+	publish(t, uc, 1, entities.EventUserRegistered)
+	_, err = uc.Dispatch(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, r.count(), "the receiver may verify the deliveries with the previous secret during the grace period")
+	body := []byte(r.bodies[0])
+	require.NoError(t, signature.Verify(r.received[0], body, rotated.Secret, 5*time.Minute, *now))
+
+	*now = now.Add(2 * time.Hour)
+	publish(t, uc, 2, entities.EventUserRegistered)
+	_, err = uc.Dispatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, r.invalid, "the previous secret expires after the grace period")
+	r.secret = rotated.Secret
+	*now = now.Add(time.Minute)
+	_, err = uc.Dispatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, r.count())
+}
+
+func TestEndpoints(t *testing.T) {
+	uc, deliveries, _ := newTestUC(t)
+	ctx := context.Background()
+
+	_, err := uc.Create(ctx, entities.WebhookEndpoint{URL: "not a url"})
+	assert.Error(t, err)
+	_, err = uc.Create(ctx, entities.WebhookEndpoint{URL: "ftp://example.com"})
+	assert.Error(t, err, "the deliveries are posted over HTTP")
+
+	endpoint, err := uc.Create(ctx, entities.WebhookEndpoint{URL: "https://example.com/hook", Events: []string{entities.EventUserDeleted}})
+	require.NoError(t, err)
+	assert.NotEmpty(t, endpoint.Secret)
+
+	stale := endpoint
+	endpoint.Description = "user removals"
+	endpoint, err = uc.Update(ctx, endpoint)
+	require.NoError(t, err)
+	assert.Equal(t, "user removals", endpoint.Description)
+	stale.Description = "outdated"
+	_, err = uc.Update(ctx, stale)
+	assert.ErrorIs(t, err, database.ErrStale, "an endpoint is modified from the version it was read at")
+
+	read, err := uc.Read(ctx, endpoint.ID)
+	require.NoError(t, err)
+	assert.Equal(t, endpoint.Secret, read.Secret, "an update keeps the secret")
+
+	publish(t, uc, 1, entities.EventUserDeleted)
+	require.NoError(t, uc.Delete(ctx, endpoint.ID))
+	_, err = uc.Read(ctx, endpoint.ID)
+	assert.ErrorIs(t, err, database.ErrNotFound)
+	_, err = deliveries.Read(ctx, uuid.NewSHA1(endpoint.ID, []byte("1")))
+	assert.ErrorIs(t, err, database.ErrNotFound, "the deliveries of a removed endpoint are removed")
+	assert.ErrorIs(t, uc.Delete(ctx, endpoint.ID), database.ErrNotFound)
+}
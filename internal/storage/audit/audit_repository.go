@@ -0,0 +1,158 @@
+// Package audit provides the functionality to interact with audit event data in the storage.
+package audit
+
+import (
+	"context"
+	"errors"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"time"
+)
+
+// Repository struct represents an audit repository that provides methods for audit event operations.
+type Repository struct {
+	db database.Database
+}
+
+// Append adds a new audit event to the storage.
+// ctx: The context for the operation.
+// event: The audit event to add.
+// Returns the stored audit event with its sequence number and an error if the operation fails.
+func (r Repository) Append(ctx context.Context, event entities.AuditEvent) (entities.AuditEvent, error) {
+	if err := r.db.Create(ctx, &event); err != nil {
+		return entities.AuditEvent{}, err
+	}
+	return event, nil
+}
+
+// Last retrieves the most recent audit event from the storage.
+// ctx: The context for the operation.
+// Returns the audit event, a boolean indicating if an event exists and an error if the operation fails.
+func (r Repository) Last(ctx context.Context) (entities.AuditEvent, bool, error) {
+	return r.last(ctx, nil)
+}
+
+// LastBefore retrieves the most recent audit event that happened before the given time from the storage.
+// ctx: The context for the operation.
+// before: The time the audit event happened before.
+// Returns the audit event, a boolean indicating if such an event exists and an error if the operation fails.
+func (r Repository) LastBefore(ctx context.Context, before time.Time) (entities.AuditEvent, bool, error) {
+	return r.last(ctx, query.Lt("timestamp", before.UTC()))
+}
+
+// last retrieves the audit event with the highest sequence number among those that satisfy the condition.
+func (r Repository) last(ctx context.Context, where query.Condition) (entities.AuditEvent, bool, error) {
+	var events []entities.AuditEvent
+	if err := r.db.Find(ctx, &events, query.Where(where).OrderedBy(query.Desc("id")).Window(1, 0)); err != nil {
+		return entities.AuditEvent{}, false, err
+	}
+	if len(events) == 0 {
+		return entities.AuditEvent{}, false, nil
+	}
+	return events[0], true, nil
+}
+
+// Count counts the audit events in the storage.
+// ctx: The context for the operation.
+// Returns the number of audit events and an error if the operation fails.
+func (r Repository) Count(ctx context.Context) (int64, error) {
+	return database.Count(ctx, r.db, entities.AuditEvent{}, nil)
+}
+
+// Head retrieves the head of the audit chain from the storage.
+// ctx: The context for the operation.
+// Returns the head, a boolean indicating if the head exists and an error if the operation fails.
+func (r Repository) Head(ctx context.Context) (entities.AuditHead, bool, error) {
+	var head entities.AuditHead
+	err := r.db.Read(ctx, &head, query.Eq("id", entities.AuditHeadID))
+	if errors.Is(err, database.ErrNotFound) {
+		return entities.AuditHead{}, false, nil
+	}
+	if err != nil {
+		return entities.AuditHead{}, false, err
+	}
+	return head, true, nil
+}
+
+// SaveHead adds or moves the head of the audit chain in the storage.
+// ctx: The context for the operation.
+// head: The head to save. A head without a version is added, and the version of an existing head must be the stored one.
+// Returns the saved head with its new version, and an error wrapping database.ErrConflict if another head was saved since it was read.
+func (r Repository) SaveHead(ctx context.Context, head entities.AuditHead) (entities.AuditHead, error) {
+	head.ID = entities.AuditHeadID
+	save := r.db.Update
+	if head.Version == 0 {
+		save = r.db.Create
+	}
+	if err := save(ctx, &head); err != nil {
+		return entities.AuditHead{}, err
+	}
+	return head, nil
+}
+
+// Find retrieves the audit events matching the filter, most recent first.
+// ctx: The context for the operation.
+// filter: The criteria of the audit events to retrieve.
+// Returns the audit events and an error if the operation fails.
+func (r Repository) Find(ctx context.Context, filter entities.AuditFilter) ([]entities.AuditEvent, error) {
+	var conditions []query.Condition
+	if filter.Actor != "" {
+		conditions = append(conditions, query.Eq("actor", filter.Actor))
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, query.Eq("action", filter.Action))
+	}
+	if filter.Outcome != "" {
+		conditions = append(conditions, query.Eq("outcome", filter.Outcome))
+	}
+	if filter.IP != "" {
+		conditions = append(conditions, query.Eq("ip", filter.IP))
+	}
+	var from, to interface{}
+	if !filter.From.IsZero() {
+		from = filter.From.UTC()
+	}
+	if !filter.To.IsZero() {
+		to = filter.To.UTC()
+	}
+	conditions = append(conditions, query.Range("timestamp", from, to))
+
+	q := query.Where(query.And(conditions...)).OrderedBy(query.Desc("id")).Window(filter.Limit, filter.Offset)
+	events := []entities.AuditEvent{}
+	if err := r.db.Find(ctx, &events, q); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ReadAfter retrieves the audit events following the given sequence number, in chain order.
+// ctx: The context for the operation.
+// afterID: The sequence number to start after.
+// limit: The maximum number of audit events to retrieve.
+// Returns the audit events and an error if the operation fails.
+func (r Repository) ReadAfter(ctx context.Context, afterID uint64, limit int) ([]entities.AuditEvent, error) {
+	var events []entities.AuditEvent
+	if err := r.db.Find(ctx, &events, query.Where(query.Gt("id", afterID)).OrderedBy(query.Asc("id")).Window(limit, 0)); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// DeleteThrough removes the audit events up to the given sequence number, which are a prefix of the chain.
+// ctx: The context for the operation.
+// lastID: The sequence number of the last audit event to remove.
+// Returns the number of removed audit events and an error if the operation fails.
+func (r Repository) DeleteThrough(ctx context.Context, lastID uint64) (int64, error) {
+	return r.db.DeleteWhere(ctx, entities.AuditEvent{}, query.Lte("id", lastID))
+}
+
+// NewAuditRepository creates a new audit repository with the provided database.
+// db: The database for the audit repository.
+// Returns an AuditRepository object.
+func NewAuditRepository(db database.Database) storage.AuditRepository {
+	return &Repository{
+		db: db,
+	}
+}
@@ -0,0 +1,77 @@
+// Package invitation provides the functionality to interact with invitation data in the storage.
+package invitation
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+)
+
+// Repository struct represents an invitation repository that provides methods for invitation data operations.
+type Repository struct {
+	db database.Database
+}
+
+// Create adds a new invitation record to the storage.
+// ctx: The context for the operation.
+// model: The invitation record to add.
+// Returns an error if the operation fails.
+func (r Repository) Create(ctx context.Context, model entities.Invitation) error {
+	return r.db.Create(ctx, &model)
+}
+
+// Read retrieves an invitation record from the storage.
+// ctx: The context for the operation.
+// id: The id of the invitation record to retrieve.
+// Returns the invitation record and an error if the operation fails.
+func (r Repository) Read(ctx context.Context, id uuid.UUID) (entities.Invitation, error) {
+	var invitation entities.Invitation
+	if err := r.db.Read(ctx, &invitation, query.Eq("id", id)); err != nil {
+		return entities.Invitation{}, err
+	}
+	return invitation, nil
+}
+
+// ReadAll retrieves the invitation records from the storage, most recent first.
+// ctx: The context for the operation.
+// Returns the invitation records and an error if the operation fails.
+func (r Repository) ReadAll(ctx context.Context) ([]entities.Invitation, error) {
+	invitations := []entities.Invitation{}
+	if err := r.db.Find(ctx, &invitations, query.Query{}.OrderedBy(query.Desc("created_at"))); err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// Update modifies an invitation record in the storage.
+// ctx: The context for the operation.
+// model: The invitation record to modify.
+// Returns an error if the operation fails.
+func (r Repository) Update(ctx context.Context, model entities.Invitation) error {
+	return r.db.Update(ctx, &model)
+}
+
+// ReadByTokenHash retrieves an invitation record of any organization from the storage based on the hash of its token.
+// It deliberately bypasses the tenant scoping, since the organization is only known once the invitation is found.
+// ctx: The context for the operation.
+// hash: The hash of the token of the invitation record to retrieve.
+// Returns the invitation record and an error if the operation fails.
+func (r Repository) ReadByTokenHash(ctx context.Context, hash string) (entities.Invitation, error) {
+	var invitation entities.Invitation
+	if err := r.db.Read(database.WithoutTenantScope(ctx), &invitation, query.Eq("token_hash", hash)); err != nil {
+		return entities.Invitation{}, err
+	}
+	return invitation, nil
+}
+
+// NewInvitationRepository creates a new invitation repository with the provided database.
+// db: The database for the invitation repository.
+// Returns an InvitationRepository object.
+func NewInvitationRepository(db database.Database) storage.InvitationRepository {
+	return &Repository{
+		db: db,
+	}
+}
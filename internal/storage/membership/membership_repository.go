@@ -0,0 +1,115 @@
+// Package membership provides the functionality to interact with membership data in the storage.
+package membership
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+)
+
+// Repository struct represents a membership repository that provides methods for membership data operations.
+type Repository struct {
+	db database.Database
+}
+
+// Create adds a new membership record to the storage.
+// ctx: The context for the operation.
+// model: The membership record to add.
+// Returns an error if the operation fails.
+func (r Repository) Create(ctx context.Context, model entities.Membership) error {
+	return r.db.Create(ctx, &model)
+}
+
+// Read retrieves the membership record of a user from the storage.
+// ctx: The context for the operation.
+// userID: The id of the user.
+// Returns the membership record and an error if the operation fails.
+func (r Repository) Read(ctx context.Context, userID uuid.UUID) (entities.Membership, error) {
+	var membership entities.Membership
+	if err := r.db.Read(ctx, &membership, query.Eq("user_id", userID)); err != nil {
+		return entities.Membership{}, err
+	}
+	return membership, nil
+}
+
+// Update modifies a membership record in the storage.
+// ctx: The context for the operation.
+// model: The membership record to modify.
+// Returns an error if the operation fails.
+func (r Repository) Update(ctx context.Context, model entities.Membership) error {
+	return r.db.Update(ctx, &model)
+}
+
+// Delete removes the membership record of a user from the storage.
+// ctx: The context for the operation.
+// id: The id of the membership record to remove.
+// Returns an error if the operation fails.
+func (r Repository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.Delete(ctx, entities.Membership{}, id)
+}
+
+// ReadAll retrieves all membership records of the organization from the storage.
+// ctx: The context for the operation.
+// Returns the membership records and an error if the operation fails.
+func (r Repository) ReadAll(ctx context.Context) ([]entities.Membership, error) {
+	memberships := []entities.Membership{}
+	if err := r.db.ReadAll(ctx, &memberships); err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
+
+// ReadByUser retrieves the membership records of a user in every organization from the storage.
+// It deliberately bypasses the tenant scoping, since it is used to find the organizations of a user.
+// ctx: The context for the operation.
+// userID: The id of the user.
+// Returns the membership records and an error if the operation fails.
+func (r Repository) ReadByUser(ctx context.Context, userID uuid.UUID) ([]entities.Membership, error) {
+	memberships := []entities.Membership{}
+	if err := r.db.Find(database.WithoutTenantScope(ctx), &memberships, query.Where(query.Eq("user_id", userID))); err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
+
+// ReadByUsers retrieves the membership records of the given users in every organization from the storage.
+// It deliberately bypasses the tenant scoping, as ReadByUser does.
+// ctx: The context for the operation.
+// userIDs: The ids of the users.
+// Returns the membership records and an error if the operation fails.
+func (r Repository) ReadByUsers(ctx context.Context, userIDs []uuid.UUID) ([]entities.Membership, error) {
+	return r.readIn(ctx, "user_id", userIDs)
+}
+
+// ReadByOrganizations retrieves the membership records of the given organizations from the storage.
+// It deliberately bypasses the tenant scoping, since it reads the memberships of several organizations at once.
+// ctx: The context for the operation.
+// orgIDs: The ids of the organizations.
+// Returns the membership records and an error if the operation fails.
+func (r Repository) ReadByOrganizations(ctx context.Context, orgIDs []uuid.UUID) ([]entities.Membership, error) {
+	return r.readIn(ctx, "organization_id", orgIDs)
+}
+
+// readIn retrieves the membership records whose column is one of the ids, in every organization.
+func (r Repository) readIn(ctx context.Context, column string, ids []uuid.UUID) ([]entities.Membership, error) {
+	memberships := []entities.Membership{}
+	if len(ids) == 0 {
+		return memberships, nil
+	}
+	if err := r.db.Find(database.WithoutTenantScope(ctx), &memberships, query.Where(query.In(column, ids))); err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
+
+// NewMembershipRepository creates a new membership repository with the provided database.
+// db: The database for the membership repository.
+// Returns a MembershipRepository object.
+func NewMembershipRepository(db database.Database) storage.MembershipRepository {
+	return &Repository{
+		db: db,
+	}
+}
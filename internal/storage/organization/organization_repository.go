@@ -0,0 +1,119 @@
+// Package organization provides the functionality to interact with organization data in the storage.
+package organization
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+)
+
+// Repository struct represents an organization repository that provides methods for organization data operations.
+type Repository struct {
+	db database.Database
+}
+
+// Create adds a new organization record to the storage.
+// ctx: The context for the operation.
+// model: The organization record to add.
+// Returns an error if the operation fails.
+func (r Repository) Create(ctx context.Context, model entities.Organization) error {
+	return r.db.Create(ctx, &model)
+}
+
+// Read retrieves an organization record from the storage.
+// ctx: The context for the operation.
+// id: The id of the organization record to retrieve.
+// Returns the organization record and an error if the operation fails.
+func (r Repository) Read(ctx context.Context, id uuid.UUID) (entities.Organization, error) {
+	var organization entities.Organization
+	if err := r.db.Read(ctx, &organization, query.Eq("id", id)); err != nil {
+		return entities.Organization{}, err
+	}
+	return organization, nil
+}
+
+// ReadBySlug retrieves an organization record from the storage based on the slug.
+// ctx: The context for the operation.
+// slug: The slug of the organization record to retrieve.
+// Returns the organization record and an error if the operation fails.
+func (r Repository) ReadBySlug(ctx context.Context, slug string) (entities.Organization, error) {
+	var organization entities.Organization
+	if err := r.db.Read(ctx, &organization, query.Eq("slug", slug)); err != nil {
+		return entities.Organization{}, err
+	}
+	return organization, nil
+}
+
+// ReadMany retrieves the organization records with the given ids from the storage.
+// ctx: The context for the operation.
+// ids: The ids of the organization records to retrieve.
+// Returns the organization records and an error if the operation fails.
+func (r Repository) ReadMany(ctx context.Context, ids []uuid.UUID) ([]entities.Organization, error) {
+	organizations := []entities.Organization{}
+	if len(ids) == 0 {
+		return organizations, nil
+	}
+	if err := r.db.Find(ctx, &organizations, query.Where(query.In("id", ids)).OrderedBy(query.Asc("name"))); err != nil {
+		return nil, err
+	}
+	return organizations, nil
+}
+
+// ReadAll retrieves all organization records from the storage, ordered by name.
+// ctx: The context for the operation.
+// Returns the organization records and an error if the operation fails.
+func (r Repository) ReadAll(ctx context.Context) ([]entities.Organization, error) {
+	organizations := []entities.Organization{}
+	if err := r.db.Find(ctx, &organizations, query.Query{}.OrderedBy(query.Asc("name"))); err != nil {
+		return nil, err
+	}
+	return organizations, nil
+}
+
+// List retrieves the organization records that match the query from the storage.
+// ctx: The context for the operation.
+// q: The condition, the order and the window of the organization records to retrieve.
+// Returns the organization records and an error if the operation fails.
+func (r Repository) List(ctx context.Context, q query.Query) ([]entities.Organization, error) {
+	organizations := []entities.Organization{}
+	if err := r.db.Find(ctx, &organizations, q); err != nil {
+		return nil, err
+	}
+	return organizations, nil
+}
+
+// Count counts the organization records that match the condition in the storage.
+// ctx: The context for the operation.
+// where: The condition of the organization records to count. A nil condition counts every organization record.
+// Returns the number of organization records and an error if the operation fails.
+func (r Repository) Count(ctx context.Context, where query.Condition) (int64, error) {
+	return database.Count(ctx, r.db, entities.Organization{}, where)
+}
+
+// Update modifies an organization record in the storage.
+// ctx: The context for the operation.
+// model: The organization record to modify.
+// Returns an error if the operation fails.
+func (r Repository) Update(ctx context.Context, model entities.Organization) error {
+	return r.db.Update(ctx, &model)
+}
+
+// Delete removes an organization record from the storage.
+// ctx: The context for the operation.
+// id: The id of the organization record to remove.
+// Returns an error if the operation fails.
+func (r Repository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.Delete(ctx, entities.Organization{}, id)
+}
+
+// NewOrganizationRepository creates a new organization repository with the provided database.
+// db: The database for the organization repository.
+// Returns an OrganizationRepository object.
+func NewOrganizationRepository(db database.Database) storage.OrganizationRepository {
+	return &Repository{
+		db: db,
+	}
+}
@@ -0,0 +1,68 @@
+// Package outbox provides the functionality to interact with outbox message data in the storage.
+package outbox
+
+import (
+	"context"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"time"
+)
+
+// Repository struct represents an outbox repository that provides methods for outbox message operations.
+// The CRUD operations are the ones of the generic repository, which it adds the lookups and the removals of the messages to.
+type Repository struct {
+	db       database.Database
+	messages database.Repository[entities.OutboxMessage, uint64]
+}
+
+// Append adds a new message to the outbox.
+// ctx: The context for the operation, which carries the transaction of the change the message describes.
+// message: The message to add.
+// Returns the stored message with its sequence number and an error if the operation fails.
+func (r Repository) Append(ctx context.Context, message entities.OutboxMessage) (entities.OutboxMessage, error) {
+	if message.NextAttemptAt.IsZero() {
+		message.NextAttemptAt = time.Now().UTC()
+	}
+	return r.messages.Insert(ctx, message)
+}
+
+// Pending retrieves the messages that are neither published nor dead, in sequence order.
+// ctx: The context for the operation.
+// after: The sequence number after which the messages are retrieved, which pages through the messages. Zero starts from the first.
+// limit: The maximum number of messages to retrieve.
+// Returns the messages and an error if the operation fails.
+func (r Repository) Pending(ctx context.Context, after uint64, limit int) ([]entities.OutboxMessage, error) {
+	q := query.Where(query.And(query.Eq("published", false), query.Eq("dead", false))).OrderedBy(query.Asc("id")).Window(limit, 0)
+	if after > 0 {
+		q = q.StartAfter(after)
+	}
+	return r.messages.List(ctx, q)
+}
+
+// Save modifies a message, if it was not modified since it was read.
+// ctx: The context for the operation.
+// message: The message to modify.
+// Returns the stored message with its new version, and database.ErrStale if the message was modified in the meantime.
+func (r Repository) Save(ctx context.Context, message entities.OutboxMessage) (entities.OutboxMessage, error) {
+	return r.messages.Save(ctx, message)
+}
+
+// DeletePublishedBefore removes the messages that were published before the given time.
+// ctx: The context for the operation.
+// before: The time before which the published messages are removed.
+// Returns the number of removed messages and an error if the operation fails.
+func (r Repository) DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	return r.db.DeleteWhere(ctx, entities.OutboxMessage{}, query.And(query.Eq("published", true), query.Lt("updated_at", before.UTC())))
+}
+
+// NewOutboxRepository creates a new outbox repository with the provided database.
+// db: The database for the outbox repository.
+// Returns an OutboxRepository object.
+func NewOutboxRepository(db database.Database) storage.OutboxRepository {
+	return &Repository{
+		db:       db,
+		messages: database.NewRepository[entities.OutboxMessage, uint64](db),
+	}
+}
@@ -3,8 +3,11 @@ package storage
 
 import (
 	"context"
+	"database/sql"
 	"github.com/google/uuid"
 	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"time"
 )
 
 // UserRepository is an interface that defines the methods required for user data operations.
@@ -51,16 +54,364 @@ type UserRepository interface {
 	// Returns the user record and an error if the operation fails.
 	ReadByUsername(ctx context.Context, username string) (entities.User, error)
 
+	// ReadByToken retrieves a user record from the storage based on the bearer token.
+	// ctx: The context for the operation.
+	// token: The bearer token of the user record to retrieve.
+	// Returns the user record and an error if the operation fails.
+	ReadByToken(ctx context.Context, token string) (entities.User, error)
+
 	// ReadAll retrieves all user records from the storage.
 	// ctx: The context for the operation.
 	// model: The user records to retrieve.
 	// Returns the user records and an error if the operation fails.
 	ReadAll(ctx context.Context, model []entities.User) ([]entities.User, error)
 
+	// ReadMany retrieves the user records with the given ids from the storage.
+	// ctx: The context for the operation.
+	// ids: The ids of the user records to retrieve.
+	// Returns the user records and an error if the operation fails.
+	ReadMany(ctx context.Context, ids []uuid.UUID) ([]entities.User, error)
+
+	// List retrieves the user records that match the query from the storage.
+	// ctx: The context for the operation.
+	// q: The condition, the order and the window of the user records to retrieve.
+	// Returns the user records and an error if the operation fails.
+	List(ctx context.Context, q query.Query) ([]entities.User, error)
+
+	// Count counts the user records that match the condition in the storage.
+	// ctx: The context for the operation.
+	// where: The condition of the user records to count. A nil condition counts every user record.
+	// Returns the number of user records and an error if the operation fails.
+	Count(ctx context.Context, where query.Condition) (int64, error)
+
 	// CheckUserExists checks if a user exists in the storage based on the email and username.
 	// ctx: The context for the operation.
 	// email: The email of the user to check.
 	// username: The username of the user to check.
 	// Returns a boolean indicating if the user exists and an error if the operation fails.
 	CheckUserExists(ctx context.Context, email string, username string) (bool, error)
+
+	// Search retrieves the user records whose username or email matches the search, most relevant first.
+	// ctx: The context for the operation.
+	// search: The text and the window of the search.
+	// Returns the found user records with their ranks, the number of found user records regardless of the window,
+	// and an error if the operation fails.
+	Search(ctx context.Context, search entities.UserSearch) (entities.UserSearchResult, error)
+}
+
+// AuditRepository is an interface that defines the methods required for audit event operations.
+// The audit log is append-only, so it has no methods to modify events.
+// Events can only be removed in bulk, when they fall out of the retention window.
+type AuditRepository interface {
+	// Append adds a new audit event to the storage.
+	// ctx: The context for the operation.
+	// event: The audit event to add.
+	// Returns the stored audit event with its sequence number and an error if the operation fails.
+	Append(ctx context.Context, event entities.AuditEvent) (entities.AuditEvent, error)
+
+	// Last retrieves the most recent audit event from the storage.
+	// ctx: The context for the operation.
+	// Returns the audit event, a boolean indicating if an event exists and an error if the operation fails.
+	Last(ctx context.Context) (entities.AuditEvent, bool, error)
+
+	// LastBefore retrieves the most recent audit event that happened before the given time from the storage.
+	// ctx: The context for the operation.
+	// before: The time the audit event happened before.
+	// Returns the audit event, a boolean indicating if such an event exists and an error if the operation fails.
+	LastBefore(ctx context.Context, before time.Time) (entities.AuditEvent, bool, error)
+
+	// Count counts the audit events in the storage.
+	// ctx: The context for the operation.
+	// Returns the number of audit events and an error if the operation fails.
+	Count(ctx context.Context) (int64, error)
+
+	// Head retrieves the head of the audit chain from the storage.
+	// ctx: The context for the operation.
+	// Returns the head, a boolean indicating if the head exists and an error if the operation fails.
+	Head(ctx context.Context) (entities.AuditHead, bool, error)
+
+	// SaveHead adds or moves the head of the audit chain in the storage.
+	// ctx: The context for the operation.
+	// head: The head to save. A head without a version is added, and the version of an existing head must be the stored one.
+	// Returns the saved head with its new version, and an error wrapping database.ErrConflict if another head was saved since it was read.
+	SaveHead(ctx context.Context, head entities.AuditHead) (entities.AuditHead, error)
+
+	// Find retrieves the audit events matching the filter, most recent first.
+	// ctx: The context for the operation.
+	// filter: The criteria of the audit events to retrieve.
+	// Returns the audit events and an error if the operation fails.
+	Find(ctx context.Context, filter entities.AuditFilter) ([]entities.AuditEvent, error)
+
+	// ReadAfter retrieves the audit events following the given sequence number, in chain order.
+	// ctx: The context for the operation.
+	// afterID: The sequence number to start after.
+	// limit: The maximum number of audit events to retrieve.
+	// Returns the audit events and an error if the operation fails.
+	ReadAfter(ctx context.Context, afterID uint64, limit int) ([]entities.AuditEvent, error)
+
+	// DeleteThrough removes the audit events up to the given sequence number, which are a prefix of the chain.
+	// ctx: The context for the operation.
+	// lastID: The sequence number of the last audit event to remove.
+	// Returns the number of removed audit events and an error if the operation fails.
+	DeleteThrough(ctx context.Context, lastID uint64) (int64, error)
+}
+
+// OrganizationRepository is an interface that defines the methods required for organization data operations.
+type OrganizationRepository interface {
+	// Create adds a new organization record to the storage.
+	// ctx: The context for the operation.
+	// model: The organization record to add.
+	// Returns an error if the operation fails.
+	Create(ctx context.Context, model entities.Organization) error
+
+	// Read retrieves an organization record from the storage.
+	// ctx: The context for the operation.
+	// id: The id of the organization record to retrieve.
+	// Returns the organization record and an error if the operation fails.
+	Read(ctx context.Context, id uuid.UUID) (entities.Organization, error)
+
+	// ReadBySlug retrieves an organization record from the storage based on the slug.
+	// ctx: The context for the operation.
+	// slug: The slug of the organization record to retrieve.
+	// Returns the organization record and an error if the operation fails.
+	ReadBySlug(ctx context.Context, slug string) (entities.Organization, error)
+
+	// ReadMany retrieves the organization records with the given ids from the storage.
+	// ctx: The context for the operation.
+	// ids: The ids of the organization records to retrieve.
+	// Returns the organization records and an error if the operation fails.
+	ReadMany(ctx context.Context, ids []uuid.UUID) ([]entities.Organization, error)
+
+	// ReadAll retrieves all organization records from the storage, ordered by name.
+	// ctx: The context for the operation.
+	// Returns the organization records and an error if the operation fails.
+	ReadAll(ctx context.Context) ([]entities.Organization, error)
+
+	// List retrieves the organization records that match the query from the storage.
+	// ctx: The context for the operation.
+	// q: The condition, the order and the window of the organization records to retrieve.
+	// Returns the organization records and an error if the operation fails.
+	List(ctx context.Context, q query.Query) ([]entities.Organization, error)
+
+	// Count counts the organization records that match the condition in the storage.
+	// ctx: The context for the operation.
+	// where: The condition of the organization records to count. A nil condition counts every organization record.
+	// Returns the number of organization records and an error if the operation fails.
+	Count(ctx context.Context, where query.Condition) (int64, error)
+
+	// Update modifies an organization record in the storage.
+	// ctx: The context for the operation.
+	// model: The organization record to modify.
+	// Returns an error if the operation fails.
+	Update(ctx context.Context, model entities.Organization) error
+
+	// Delete removes an organization record from the storage.
+	// ctx: The context for the operation.
+	// id: The id of the organization record to remove.
+	// Returns an error if the operation fails.
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// MembershipRepository is an interface that defines the methods required for membership data operations.
+// Except for ReadByUser, ReadByUsers and ReadByOrganizations, every method is scoped to the organization in the context.
+type MembershipRepository interface {
+	// Create adds a new membership record to the storage.
+	// ctx: The context for the operation.
+	// model: The membership record to add.
+	// Returns an error if the operation fails.
+	Create(ctx context.Context, model entities.Membership) error
+
+	// Read retrieves the membership record of a user from the storage.
+	// ctx: The context for the operation.
+	// userID: The id of the user.
+	// Returns the membership record and an error if the operation fails.
+	Read(ctx context.Context, userID uuid.UUID) (entities.Membership, error)
+
+	// Update modifies a membership record in the storage.
+	// ctx: The context for the operation.
+	// model: The membership record to modify.
+	// Returns an error if the operation fails.
+	Update(ctx context.Context, model entities.Membership) error
+
+	// Delete removes the membership record of a user from the storage.
+	// ctx: The context for the operation.
+	// id: The id of the membership record to remove.
+	// Returns an error if the operation fails.
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	// ReadAll retrieves all membership records of the organization from the storage.
+	// ctx: The context for the operation.
+	// Returns the membership records and an error if the operation fails.
+	ReadAll(ctx context.Context) ([]entities.Membership, error)
+
+	// ReadByUser retrieves the membership records of a user in every organization from the storage.
+	// ctx: The context for the operation.
+	// userID: The id of the user.
+	// Returns the membership records and an error if the operation fails.
+	ReadByUser(ctx context.Context, userID uuid.UUID) ([]entities.Membership, error)
+
+	// ReadByUsers retrieves the membership records of the given users in every organization from the storage.
+	// ctx: The context for the operation.
+	// userIDs: The ids of the users.
+	// Returns the membership records and an error if the operation fails.
+	ReadByUsers(ctx context.Context, userIDs []uuid.UUID) ([]entities.Membership, error)
+
+	// ReadByOrganizations retrieves the membership records of the given organizations from the storage.
+	// ctx: The context for the operation.
+	// orgIDs: The ids of the organizations.
+	// Returns the membership records and an error if the operation fails.
+	ReadByOrganizations(ctx context.Context, orgIDs []uuid.UUID) ([]entities.Membership, error)
+}
+
+// InvitationRepository is an interface that defines the methods required for invitation data operations.
+// Except for ReadByToken, every method is scoped to the organization in the context, unless the context disables the tenant scoping.
+type InvitationRepository interface {
+	// Create adds a new invitation record to the storage.
+	// ctx: The context for the operation.
+	// model: The invitation record to add.
+	// Returns an error if the operation fails.
+	Create(ctx context.Context, model entities.Invitation) error
+
+	// Read retrieves an invitation record from the storage.
+	// ctx: The context for the operation.
+	// id: The id of the invitation record to retrieve.
+	// Returns the invitation record and an error if the operation fails.
+	Read(ctx context.Context, id uuid.UUID) (entities.Invitation, error)
+
+	// ReadAll retrieves the invitation records from the storage, most recent first.
+	// ctx: The context for the operation.
+	// Returns the invitation records and an error if the operation fails.
+	ReadAll(ctx context.Context) ([]entities.Invitation, error)
+
+	// Update modifies an invitation record in the storage.
+	// ctx: The context for the operation.
+	// model: The invitation record to modify.
+	// Returns an error if the operation fails.
+	Update(ctx context.Context, model entities.Invitation) error
+
+	// ReadByTokenHash retrieves an invitation record of any organization from the storage based on the hash of its token.
+	// ctx: The context for the operation.
+	// hash: The hash of the token of the invitation record to retrieve.
+	// Returns the invitation record and an error if the operation fails.
+	ReadByTokenHash(ctx context.Context, hash string) (entities.Invitation, error)
+}
+
+// OutboxRepository is an interface that defines the methods required for outbox message operations.
+// The messages are appended in the transaction of the change they describe, so that they are stored if and only if it is.
+type OutboxRepository interface {
+	// Append adds a new message to the outbox.
+	// ctx: The context for the operation, which carries the transaction of the change the message describes.
+	// message: The message to add.
+	// Returns the stored message with its sequence number and an error if the operation fails.
+	Append(ctx context.Context, message entities.OutboxMessage) (entities.OutboxMessage, error)
+
+	// Pending retrieves the messages that are neither published nor dead, in sequence order.
+	// ctx: The context for the operation.
+	// after: The sequence number after which the messages are retrieved, which pages through the messages. Zero starts from the first.
+	// limit: The maximum number of messages to retrieve.
+	// Returns the messages and an error if the operation fails.
+	Pending(ctx context.Context, after uint64, limit int) ([]entities.OutboxMessage, error)
+
+	// Save modifies a message, if it was not modified since it was read.
+	// ctx: The context for the operation.
+	// message: The message to modify.
+	// Returns the stored message with its new version, and database.ErrStale if the message was modified in the meantime.
+	Save(ctx context.Context, message entities.OutboxMessage) (entities.OutboxMessage, error)
+
+	// DeletePublishedBefore removes the messages that were published before the given time.
+	// ctx: The context for the operation.
+	// before: The time before which the published messages are removed.
+	// Returns the number of removed messages and an error if the operation fails.
+	DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// WebhookEndpointRepository is an interface that defines the methods required for webhook endpoint operations.
+type WebhookEndpointRepository interface {
+	// Create adds a new endpoint to the storage.
+	// ctx: The context for the operation.
+	// endpoint: The endpoint to add.
+	// Returns the stored endpoint and an error if the operation fails.
+	Create(ctx context.Context, endpoint entities.WebhookEndpoint) (entities.WebhookEndpoint, error)
+
+	// Read retrieves an endpoint from the storage.
+	// ctx: The context for the operation.
+	// id: The id of the endpoint to retrieve.
+	// Returns the endpoint and an error if the operation fails.
+	Read(ctx context.Context, id uuid.UUID) (entities.WebhookEndpoint, error)
+
+	// ReadAll retrieves the endpoints from the storage, oldest first.
+	// ctx: The context for the operation.
+	// Returns the endpoints and an error if the operation fails.
+	ReadAll(ctx context.Context) ([]entities.WebhookEndpoint, error)
+
+	// Save modifies an endpoint, if it was not modified since it was read.
+	// ctx: The context for the operation.
+	// endpoint: The endpoint to modify.
+	// Returns the stored endpoint with its new version, and database.ErrStale if the endpoint was modified in the meantime.
+	Save(ctx context.Context, endpoint entities.WebhookEndpoint) (entities.WebhookEndpoint, error)
+
+	// Delete removes an endpoint from the storage.
+	// ctx: The context for the operation.
+	// id: The id of the endpoint to remove.
+	// Returns an error if the operation fails.
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// WebhookDeliveryRepository is an interface that defines the methods required for webhook delivery operations.
+type WebhookDeliveryRepository interface {
+	// Create adds a new delivery to the storage.
+	// ctx: The context for the operation.
+	// delivery: The delivery to add.
+	// Returns a *database.ConflictError if the delivery already exists, and an error if the operation fails.
+	Create(ctx context.Context, delivery entities.WebhookDelivery) error
+
+	// Read retrieves a delivery from the storage.
+	// ctx: The context for the operation.
+	// id: The id of the delivery to retrieve.
+	// Returns the delivery and an error if the operation fails.
+	Read(ctx context.Context, id uuid.UUID) (entities.WebhookDelivery, error)
+
+	// ReadByEndpoint retrieves the deliveries of an endpoint matching the filter, most recent first.
+	// ctx: The context for the operation.
+	// endpointID: The id of the endpoint.
+	// filter: The status and window of the deliveries.
+	// Returns the deliveries and an error if the operation fails.
+	ReadByEndpoint(ctx context.Context, endpointID uuid.UUID, filter entities.WebhookDeliveryFilter) ([]entities.WebhookDelivery, error)
+
+	// Due retrieves the pending deliveries that may be sent at the given time, the longest waiting first.
+	// ctx: The context for the operation.
+	// now: The time the deliveries are sent at.
+	// limit: The maximum number of deliveries to retrieve.
+	// Returns the deliveries and an error if the operation fails.
+	Due(ctx context.Context, now time.Time, limit int) ([]entities.WebhookDelivery, error)
+
+	// Save modifies a delivery, if it was not modified since it was read.
+	// ctx: The context for the operation.
+	// delivery: The delivery to modify.
+	// Returns the stored delivery with its new version, and database.ErrStale if the delivery was modified in the meantime.
+	Save(ctx context.Context, delivery entities.WebhookDelivery) (entities.WebhookDelivery, error)
+
+	// DeleteByEndpoint removes the deliveries of an endpoint.
+	// ctx: The context for the operation.
+	// endpointID: The id of the endpoint.
+	// Returns the number of removed deliveries and an error if the operation fails.
+	DeleteByEndpoint(ctx context.Context, endpointID uuid.UUID) (int64, error)
+
+	// DeleteFinishedBefore removes the deliveries that succeeded or are dead and were last modified before the given time.
+	// ctx: The context for the operation.
+	// before: The time before which the finished deliveries are removed.
+	// Returns the number of removed deliveries and an error if the operation fails.
+	DeleteFinishedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Transactor is an interface that defines the method required to run several storage operations atomically.
+// The repositories take part in the transaction through the context, so they need no changes of their own.
+type Transactor interface {
+	// WithTx runs fn in a transaction, which is committed if fn returns nil and rolled back otherwise.
+	// A nested call runs fn in a savepoint of the enclosing transaction.
+	// ctx: The context for the operation.
+	// fn: The function to run in the transaction. The repositories must be called with the context it receives.
+	// opts: The isolation level and read-only flag of the transaction, instead of the configured ones.
+	// Returns the error returned by fn, or an error if the transaction cannot be started or committed.
+	WithTx(ctx context.Context, fn func(ctx context.Context) error, opts ...*sql.TxOptions) error
 }
@@ -0,0 +1,1475 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	gmssql "github.com/dolthub/go-mysql-server/sql"
+	vitess "github.com/dolthub/vitess/go/mysql"
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	auditmigrations "github.com/nikita-voronoy/go-clean-arch/internal/modules/audit/migrations"
+	authmigrations "github.com/nikita-voronoy/go-clean-arch/internal/modules/auth/migrations"
+	invitationmigrations "github.com/nikita-voronoy/go-clean-arch/internal/modules/invitation/migrations"
+	orgmigrations "github.com/nikita-voronoy/go-clean-arch/internal/modules/organization/migrations"
+	outboxmigrations "github.com/nikita-voronoy/go-clean-arch/internal/modules/outbox/migrations"
+	webhookmigrations "github.com/nikita-voronoy/go-clean-arch/internal/modules/webhook/migrations"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/audit"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/invitation"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/membership"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/organization"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/outbox"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/user"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage/webhook"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/cache"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/mysql"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/postgres"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/keyring"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/migrate"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pg is the ephemeral PostgreSQL server the suite runs against. It is nil if the server could not be started.
+var (
+	pg            *config.PostgresConfig
+	pgUnavailable error
+	pgDatabases   atomic.Int64
+)
+
+// my is the in-process MySQL server the suite runs against. It is nil if the server could not be started.
+var (
+	my            *config.MySQLConfig
+	myUnavailable error
+	myDatabases   atomic.Int64
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	stopPostgres := startPostgres()
+	stopMySQL := startMySQL()
+	code := m.Run()
+	stopMySQL()
+	stopPostgres()
+	os.Exit(code)
+}
+
+// startPostgres starts an embedded PostgreSQL server, unless the tests run in short mode.
+// Returns the function that stops the server.
+func startPostgres() func() {
+	if testing.Short() {
+		pgUnavailable = fmt.Errorf("skipped in short mode")
+		return func() {}
+	}
+	dir, err := os.MkdirTemp("", "postgres")
+	if err != nil {
+		pgUnavailable = err
+		return func() {}
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		pgUnavailable = err
+		return func() {}
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	_ = l.Close()
+
+	server := embeddedpostgres.NewDatabase(embeddedpostgres.DefaultConfig().
+		Port(uint32(port)).
+		Username("postgres").
+		Password("postgres").
+		Database("postgres").
+		RuntimePath(filepath.Join(dir, "runtime")).
+		Logger(io.Discard).
+		StartTimeout(time.Minute))
+	if err := server.Start(); err != nil {
+		pgUnavailable = err
+		_ = os.RemoveAll(dir)
+		return func() {}
+	}
+	pg = &config.PostgresConfig{Host: "127.0.0.1", Port: port, User: "postgres", Password: "postgres", DBName: "postgres", SSLMode: "disable"}
+	return func() {
+		_ = server.Stop()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+// startMySQL starts an in-process MySQL compatible server, unless the tests run in short mode.
+// Returns the function that stops the server.
+func startMySQL() func() {
+	if testing.Short() {
+		myUnavailable = fmt.Errorf("skipped in short mode")
+		return func() {}
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		myUnavailable = err
+		return func() {}
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	_ = l.Close()
+
+	provider := memory.NewDBProvider()
+	sessions := func(ctx context.Context, conn *vitess.Conn, addr string) (gmssql.Session, error) {
+		return memory.NewSession(gmssql.NewBaseSessionWithClientServer(addr, gmssql.Client{Address: addr, User: "root", Capabilities: conn.Capabilities}, conn.ConnectionID), provider), nil
+	}
+	s, err := server.NewServer(server.Config{Protocol: "tcp", Address: l.Addr().String()}, sqle.NewDefault(provider), sessions, nil)
+	if err != nil {
+		myUnavailable = err
+		return func() {}
+	}
+	go func() { _ = s.Start() }()
+	my = &config.MySQLConfig{Host: "127.0.0.1", Port: port, User: "root", TLS: "false"}
+	return func() { _ = s.Close() }
+}
+
+// openMySQL creates a database for the test on the in-process MySQL server.
+// uuidStorage: The column type of the UUIDs.
+// Returns the configuration of the database.
+func openMySQL(t *testing.T, uuidStorage string) *config.Config {
+	if my == nil {
+		t.Skipf("mysql is not available: %v", myUnavailable)
+	}
+	// Every test gets its own database, so the tests do not see each other's records.
+	admin, err := sql.Open("mysql", mysql.Config(*my).FormatDSN())
+	require.NoError(t, err)
+	defer admin.Close()
+	cfg := *my
+	cfg.DBName = fmt.Sprintf("repositories_%d", myDatabases.Add(1))
+	cfg.UUIDStorage = uuidStorage
+	_, err = admin.Exec("CREATE DATABASE " + cfg.DBName)
+	require.NoError(t, err)
+	return &config.Config{DB: config.DatabaseConfig{DatabaseType: "mysql", MySQL: cfg}}
+}
+
+// backend struct represents a database the repository suite runs against.
+// The in-process MySQL server does not support savepoints, unlike the MySQL and MariaDB servers.
+type backend struct {
+	name         string
+	open         func(t *testing.T) *config.Config
+	noSavepoints bool
+}
+
+var backends = []backend{
+	{name: "sqlite", open: func(t *testing.T) *config.Config {
+		return &config.Config{DB: config.DatabaseConfig{
+			DatabaseType: "sqlite",
+			Sqlite:       config.SqliteConfig{DatabasePath: filepath.Join(t.TempDir(), "repositories.sqlite3")},
+		}}
+	}},
+	{name: "bolt", open: func(t *testing.T) *config.Config {
+		return &config.Config{DB: config.DatabaseConfig{
+			DatabaseType: "bolt",
+			Bolt:         config.BoltConfig{Path: filepath.Join(t.TempDir(), "repositories.bolt"), TimeoutSeconds: 1},
+		}}
+	}},
+	{name: "memory", open: func(t *testing.T) *config.Config {
+		return &config.Config{DB: config.DatabaseConfig{DatabaseType: "memory"}}
+	}},
+	{name: "postgres", open: func(t *testing.T) *config.Config {
+		if pg == nil {
+			t.Skipf("postgres is not available: %v", pgUnavailable)
+		}
+		// Every test gets its own database, so the tests do not see each other's records.
+		admin, err := sql.Open("pgx", postgres.DSN(*pg))
+		require.NoError(t, err)
+		defer admin.Close()
+		cfg := *pg
+		cfg.DBName = fmt.Sprintf("repositories_%d", pgDatabases.Add(1))
+		_, err = admin.Exec("CREATE DATABASE " + cfg.DBName)
+		require.NoError(t, err)
+		return &config.Config{DB: config.DatabaseConfig{DatabaseType: "postgres", Postgres: cfg}}
+	}},
+	{name: "mysql-char36", noSavepoints: true, open: func(t *testing.T) *config.Config {
+		return openMySQL(t, mysql.UUIDChar)
+	}},
+	{name: "mysql-binary16", noSavepoints: true, open: func(t *testing.T) *config.Config {
+		return openMySQL(t, mysql.UUIDBinary)
+	}},
+}
+
+// forEachBackend runs the test against every database, with the tenant scoping the application uses.
+func forEachBackend(t *testing.T, test func(t *testing.T, db database.Database)) {
+	forBackends(t, backends, test)
+}
+
+// forEachSavepointBackend runs the test against every database that supports savepoints.
+func forEachSavepointBackend(t *testing.T, test func(t *testing.T, db database.Database)) {
+	var supported []backend
+	for _, b := range backends {
+		if !b.noSavepoints {
+			supported = append(supported, b)
+		}
+	}
+	forBackends(t, supported, test)
+}
+
+// sources are the migrations of the modules whose repositories the suite tests.
+var sources = []migrate.Source{authmigrations.Source, auditmigrations.Source, orgmigrations.Source, invitationmigrations.Source, outboxmigrations.Source, webhookmigrations.Source}
+
+// migrations is the number of migrations of the sources.
+const migrations = 19
+
+// forBackends runs the test against the migrated databases, with the tenant scoping the application uses.
+func forBackends(t *testing.T, backends []backend, test func(t *testing.T, db database.Database)) {
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			db, err := database.NewDatabase(b.open(t))
+			require.NoError(t, err, "Failed to create new database")
+			if closer, ok := db.(io.Closer); ok {
+				t.Cleanup(func() { _ = closer.Close() })
+			}
+			migrator, err := database.NewMigrator(db, sources...)
+			if !errors.Is(err, database.ErrMigrationsNotSupported) {
+				require.NoError(t, err, "Failed to load the migrations")
+				_, err = migrator.Up(context.Background(), 0)
+				require.NoError(t, err, "Failed to migrate the database")
+			}
+			test(t, database.NewTenantDatabase(db, "organization_id"))
+		})
+	}
+}
+
+func newUser(name string) entities.User {
+	return entities.User{ID: uuid.New(), Username: name, Email: name + "@example.com", Password: "hash"}
+}
+
+func newKeyring(t *testing.T) *keyring.Keyring {
+	keys, err := keyring.New([]keyring.Key{{ID: "k1", Secret: bytes.Repeat([]byte{1}, keyring.KeySize)}}, "k1", bytes.Repeat([]byte{9}, keyring.KeySize))
+	require.NoError(t, err)
+	return keys
+}
+
+func TestUserRepository(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db database.Database) {
+		ctx := context.Background()
+		users := user.NewUserRepository(db)
+
+		alice := newUser("alice")
+		alice.Token = "alice-token"
+		require.NoError(t, users.Create(ctx, alice))
+		require.NoError(t, users.Create(ctx, newUser("bob")))
+		assert.Error(t, users.Create(ctx, entities.User{ID: uuid.New(), Username: "alice", Email: "other@example.com", Password: "hash"}), "usernames are unique")
+
+		read, err := users.Read(ctx, alice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, alice.ID, read.ID)
+		for _, lookup := range []func() (entities.User, error){
+			func() (entities.User, error) { return users.ReadByEmail(ctx, "alice@example.com") },
+			func() (entities.User, error) { return users.ReadByUsername(ctx, "alice") },
+			func() (entities.User, error) { return users.ReadByToken(ctx, "alice-token") },
+		} {
+			found, err := lookup()
+			require.NoError(t, err)
+			assert.Equal(t, alice.ID, found.ID)
+		}
+		_, err = users.ReadByEmail(ctx, "nobody@example.com")
+		assert.Error(t, err)
+
+		exists, err := users.CheckUserExists(ctx, "nobody@example.com", "bob")
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		many, err := users.ReadMany(ctx, []uuid.UUID{alice.ID, uuid.New()})
+		require.NoError(t, err)
+		require.Len(t, many, 1)
+		assert.Equal(t, alice.ID, many[0].ID)
+		listed, err := users.List(ctx, query.Query{}.OrderedBy(query.Asc("username")).Window(1, 1))
+		require.NoError(t, err)
+		require.Len(t, listed, 1)
+		assert.Equal(t, "bob", listed[0].Username)
+		count, err := users.Count(ctx, query.Like("username", "ALI%"))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		read.Deactivated = true
+		read.TokenOrganizationID = uuid.New()
+		require.NoError(t, users.Update(ctx, read))
+		updated, err := users.Read(ctx, alice.ID)
+		require.NoError(t, err)
+		assert.True(t, updated.Deactivated)
+		assert.Equal(t, read.TokenOrganizationID, updated.TokenOrganizationID)
+
+		var all []entities.User
+		all, err = users.ReadAll(ctx, all)
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+
+		require.NoError(t, users.Delete(ctx, alice.ID))
+		_, err = users.Read(ctx, alice.ID)
+		assert.Error(t, err)
+	})
+}
+
+func TestUserSearch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db database.Database) {
+		ctx := context.Background()
+		users := user.NewUserRepository(db)
+
+		alice, alicia, bob := newUser("alice"), newUser("alicia"), newUser("bob")
+		alicia.Email = "alicia@corp.org"
+		bob.Email = "bob@alibaba.com"
+		for _, u := range []entities.User{alice, alicia, bob} {
+			require.NoError(t, users.Create(ctx, u))
+		}
+		usernames := func(result entities.UserSearchResult) []string {
+			var names []string
+			for _, hit := range result.Hits {
+				names = append(names, hit.User.Username)
+			}
+			return names
+		}
+
+		result, err := users.Search(ctx, entities.UserSearch{Q: "ALI"})
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, result.Total)
+		require.Len(t, result.Hits, 3)
+		assert.ElementsMatch(t, []string{"alice", "alicia"}, usernames(result)[:2], "the usernames weigh more than the emails")
+		assert.Equal(t, "bob", result.Hits[2].User.Username)
+		assert.Greater(t, result.Hits[1].Rank, result.Hits[2].Rank)
+
+		result, err = users.Search(ctx, entities.UserSearch{Q: "ali exa"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alice"}, usernames(result), "every term must match")
+
+		result, err = users.Search(ctx, entities.UserSearch{Q: "ali", Limit: 1, Offset: 2})
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, result.Total, "the total ignores the window")
+		assert.Equal(t, []string{"bob"}, usernames(result))
+
+		result, err = users.Search(ctx, entities.UserSearch{Q: "lic"})
+		require.NoError(t, err)
+		assert.Empty(t, result.Hits, "the terms match the start of the words")
+		assert.Zero(t, result.Total)
+
+		carol, err := users.Read(ctx, alice.ID)
+		require.NoError(t, err)
+		carol.Username, carol.Email = "carol", "carol@example.com"
+		require.NoError(t, users.Update(ctx, carol))
+		require.NoError(t, users.Delete(ctx, bob.ID))
+		result, err = users.Search(ctx, entities.UserSearch{Q: "ali"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alicia"}, usernames(result), "the index follows the writes")
+		result, err = users.Search(ctx, entities.UserSearch{Q: "carol"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"carol"}, usernames(result))
+	})
+}
+
+func TestUserSearchEncryptedEmails(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db database.Database) {
+		ctx := context.Background()
+		encrypted := database.NewEncryptedDatabase(db, newKeyring(t))
+		users := user.NewUserRepository(encrypted)
+
+		alice, bob, legacy := newUser("alice"), newUser("bob"), newUser("dave")
+		bob.Email = "bob@alibaba.com"
+		legacy.Email = "dave@alibaba.com"
+		require.NoError(t, users.Create(ctx, alice))
+		require.NoError(t, users.Create(ctx, bob))
+		require.NoError(t, user.NewUserRepository(db).Create(ctx, legacy), "a row written before the encryption was enabled")
+
+		result, err := users.Search(ctx, entities.UserSearch{Q: "ali"})
+		require.NoError(t, err)
+		require.Len(t, result.Hits, 1, "the encrypted emails are not searched, whether the database has a full-text index or not")
+		assert.Equal(t, "alice", result.Hits[0].User.Username)
+		assert.EqualValues(t, 1, result.Total)
+
+		result, err = users.Search(ctx, entities.UserSearch{Q: "alibaba"})
+		require.NoError(t, err)
+		assert.Empty(t, result.Hits, "nor are the emails not encrypted yet")
+		assert.Zero(t, result.Total)
+
+		var found []entities.User
+		_, _, err = database.Search(ctx, encrypted, &found, query.Search("alice").In("email"))
+		assert.ErrorIs(t, err, database.ErrSearchNotSupported, "an encrypted column cannot be searched")
+		_, _, err = database.Search(ctx, encrypted, &found, query.Search("alice"))
+		assert.ErrorIs(t, err, database.ErrSearchNotSupported, "nor can every column, which would include the encrypted ones")
+		_, _, err = database.Search(ctx, encrypted, &found, query.Search("alice").In("username"))
+		if !errors.Is(err, database.ErrSearchNotSupported) {
+			require.NoError(t, err)
+			require.Len(t, found, 1)
+			assert.Equal(t, alice.Email, found[0].Email, "the emails of the found users are decrypted")
+		}
+	})
+}
+
+func TestEncryptedPersonalData(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db database.Database) {
+		encrypted := database.NewEncryptedDatabase(db, newKeyring(t))
+		ctx := database.WithTenant(context.Background(), uuid.New())
+		const email = "alice@example.com"
+
+		invitations := invitation.NewInvitationRepository(encrypted)
+		invite := entities.Invitation{ID: uuid.New(), Email: email, TokenHash: "alice-token-hash", ExpiresAt: time.Now().UTC().Add(time.Hour)}
+		require.NoError(t, invitations.Create(ctx, invite))
+		events := audit.NewAuditRepository(encrypted)
+		event := entities.AuditEvent{Actor: email, Action: entities.AuditActionLogin, Outcome: entities.AuditOutcomeSuccess, Timestamp: time.Now().UTC().Truncate(time.Microsecond)}
+		event.Hash = event.ComputeHash()
+		_, err := events.Append(ctx, event)
+		require.NoError(t, err)
+		message, err := entities.NewOutboxMessage(entities.AggregateUser, "alice", entities.EventUserRegistered, entities.UserEvent{Email: email})
+		require.NoError(t, err)
+		_, err = outbox.NewOutboxRepository(encrypted).Append(ctx, message)
+		require.NoError(t, err)
+		delivery := entities.WebhookDelivery{ID: uuid.New(), EndpointID: uuid.New(), EventID: "1", EventType: entities.EventUserRegistered, Payload: message.Payload, Status: entities.WebhookDeliveryPending, NextAttemptAt: time.Now().UTC()}
+		require.NoError(t, webhook.NewDeliveryRepository(encrypted).Create(ctx, delivery))
+
+		var storedInvitation entities.Invitation
+		require.NoError(t, db.Read(ctx, &storedInvitation, query.Eq("id", invite.ID)))
+		var storedEvents []entities.AuditEvent
+		require.NoError(t, db.ReadAll(ctx, &storedEvents))
+		var storedMessages []entities.OutboxMessage
+		require.NoError(t, db.ReadAll(ctx, &storedMessages))
+		var storedDelivery entities.WebhookDelivery
+		require.NoError(t, db.Read(ctx, &storedDelivery, query.Eq("id", delivery.ID)))
+		require.Len(t, storedEvents, 1)
+		require.Len(t, storedMessages, 1)
+		for _, stored := range []string{storedInvitation.Email, storedEvents[0].Actor, storedMessages[0].Payload, storedDelivery.Payload} {
+			assert.NotContains(t, stored, email, "the emails are not stored in plaintext")
+		}
+
+		read, err := invitations.Read(ctx, invite.ID)
+		require.NoError(t, err)
+		assert.Equal(t, email, read.Email)
+		found, err := events.Find(ctx, entities.AuditFilter{Actor: email})
+		require.NoError(t, err)
+		require.Len(t, found, 1, "the events are looked up by the blind index of the actor")
+		assert.Equal(t, found[0].Hash, found[0].ComputeHash(), "the chain is hashed over the plaintext")
+		pending, err := outbox.NewOutboxRepository(encrypted).Pending(ctx, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, message.Payload, pending[0].Payload)
+
+		endpoints := webhook.NewEndpointRepository(encrypted)
+		endpoint, err := endpoints.Create(ctx, entities.WebhookEndpoint{ID: uuid.New(), URL: "https://example.com/hook", Secret: "whsec_current"})
+		require.NoError(t, err)
+		endpoint.PreviousSecret, endpoint.PreviousSecretExpiresAt = "whsec_previous", time.Now().UTC().Add(time.Hour)
+		_, err = endpoints.Save(ctx, endpoint)
+		require.NoError(t, err)
+		var storedEndpoint entities.WebhookEndpoint
+		require.NoError(t, db.Read(ctx, &storedEndpoint, query.Eq("id", endpoint.ID)))
+		assert.NotContains(t, storedEndpoint.Secret, "whsec_current", "the secrets are not stored in plaintext")
+		assert.NotContains(t, storedEndpoint.PreviousSecret, "whsec_previous", "the secrets are not stored in plaintext")
+		endpoint, err = endpoints.Read(ctx, endpoint.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "whsec_current", endpoint.Secret)
+		assert.Equal(t, "whsec_previous", endpoint.PreviousSecret)
+	})
+}
+
+func TestGenericRepository(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db database.Database) {
+		ctx := context.Background()
+		orgs := database.NewRepository[entities.Organization, uuid.UUID](db)
+
+		var ids []uuid.UUID
+		for _, slug := range []string{"acme", "globex", "initech"} {
+			org := entities.Organization{ID: uuid.New(), Name: strings.ToUpper(slug), Slug: slug}
+			require.NoError(t, orgs.Create(ctx, org))
+			ids = append(ids, org.ID)
+		}
+		assert.ErrorIs(t, orgs.Create(ctx, entities.Organization{ID: uuid.New(), Name: "Acme", Slug: "acme"}), database.ErrConflict)
+
+		org, err := orgs.Get(ctx, ids[1])
+		require.NoError(t, err)
+		assert.Equal(t, "globex", org.Slug)
+
+		listed, err := orgs.List(ctx, query.Where(query.And(query.In("id", ids[:2]), query.Ne("slug", "acme"))))
+		require.NoError(t, err)
+		require.Len(t, listed, 1)
+		assert.Equal(t, "globex", listed[0].Slug)
+
+		listed, err = orgs.List(ctx, query.Where(query.Or(query.Eq("slug", "acme"), query.Eq("slug", "initech"))).OrderedBy(query.Desc("slug")).Window(1, 1))
+		require.NoError(t, err)
+		require.Len(t, listed, 1)
+		assert.Equal(t, "acme", listed[0].Slug)
+
+		count, err := orgs.Count(ctx, query.Or(query.Eq("slug", "acme"), query.Like("name", "INIT%")))
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, count)
+		count, err = orgs.Count(ctx, nil)
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, count)
+
+		org.Name = "Globex Corporation"
+		require.NoError(t, orgs.Update(ctx, org))
+		found, err := orgs.First(ctx, query.Eq("name", "Globex Corporation"))
+		require.NoError(t, err)
+		assert.Equal(t, ids[1], found.ID)
+
+		require.NoError(t, orgs.Delete(ctx, ids[1]))
+		_, err = orgs.Get(ctx, ids[1])
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		exists, err := orgs.Exists(ctx, query.Eq("slug", "globex"))
+		require.NoError(t, err)
+		assert.False(t, exists)
+		_, err = orgs.List(ctx, query.Where(query.Eq("slug; DROP TABLE organizations", "acme")))
+		assert.Error(t, err, "a column name cannot inject SQL")
+	})
+}
+
+func TestQuery(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db database.Database) {
+		ctx := context.Background()
+		orgs := database.NewRepository[entities.Organization, uuid.UUID](db)
+		for _, name := range []string{"Acme Corp", "Globex_Corp", "Initech", "Umbrella Corp", "Wayne 100%"} {
+			slug := strings.ToLower(strings.NewReplacer(" ", "-", "_", "-", "%", "").Replace(name))
+			require.NoError(t, orgs.Create(ctx, entities.Organization{ID: uuid.New(), Name: name, Slug: slug}))
+		}
+		names := func(q query.Query) []string {
+			t.Helper()
+			listed, err := orgs.List(ctx, q)
+			require.NoError(t, err)
+			var names []string
+			for _, org := range listed {
+				names = append(names, org.Name)
+			}
+			return names
+		}
+		byName := func(where query.Condition) query.Query { return query.Where(where).OrderedBy(query.Asc("name")) }
+
+		assert.Equal(t, []string{"Acme Corp", "Globex_Corp", "Umbrella Corp"}, names(byName(query.Like("name", "%corp"))), "the pattern ignores the case")
+		assert.Equal(t, []string{"Globex_Corp"}, names(byName(query.Like("name", "%"+query.Escape("_")+"%"))))
+		assert.Equal(t, []string{"Wayne 100%"}, names(byName(query.Like("name", "%"+query.Escape("100%")))))
+		assert.Equal(t, []string{"Globex_Corp", "Initech"}, names(byName(query.Range("name", "B", "J"))), "the upper bound is excluded")
+		assert.Equal(t, []string{"Umbrella Corp", "Wayne 100%"}, names(byName(query.Range("name", "Initech!", nil))))
+		assert.Empty(t, names(byName(query.In("slug", []string{}))), "an empty IN matches no record")
+		assert.Empty(t, names(byName(query.Or())), "an empty disjunction matches no record")
+		assert.Len(t, names(byName(query.Or(query.Eq("slug", "initech"), nil))), 5, "a nil condition matches every record")
+		assert.Empty(t, names(byName(query.Eq("slug", nil))), "a comparison with NULL is never true")
+
+		// The cursor pages through the records in the order of the query, descending here.
+		var (
+			pages [][]string
+			q     = query.Query{Limit: 2}.OrderedBy(query.Desc("name"), query.Asc("id"))
+		)
+		for {
+			listed, err := orgs.List(ctx, q)
+			require.NoError(t, err)
+			if len(listed) == 0 {
+				break
+			}
+			var page []string
+			for _, org := range listed {
+				page = append(page, org.Name)
+			}
+			pages = append(pages, page)
+			last := listed[len(listed)-1]
+			q = q.StartAfter(last.Name, last.ID)
+		}
+		assert.Equal(t, [][]string{{"Wayne 100%", "Umbrella Corp"}, {"Initech", "Globex_Corp"}, {"Acme Corp"}}, pages)
+
+		_, err := orgs.List(ctx, byName(nil).StartAfter("Acme Corp", uuid.New()))
+		assert.ErrorIs(t, err, query.ErrCursor)
+		_, err = orgs.List(ctx, query.Query{}.OrderedBy(query.Asc("name; DROP TABLE organizations")))
+		assert.Error(t, err, "an ordering column cannot inject SQL")
+	})
+}
+
+func TestOrganizationRepository(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db database.Database) {
+		ctx := context.Background()
+		orgs := organization.NewOrganizationRepository(db)
+
+		globex := entities.Organization{ID: uuid.New(), Name: "Globex", Slug: "globex"}
+		acme := entities.Organization{ID: uuid.New(), Name: "Acme", Slug: "acme"}
+		initech := entities.Organization{ID: uuid.New(), Name: "Initech", Slug: "initech"}
+		for _, org := range []entities.Organization{globex, acme, initech} {
+			require.NoError(t, orgs.Create(ctx, org))
+		}
+
+		found, err := orgs.ReadBySlug(ctx, "acme")
+		require.NoError(t, err)
+		assert.Equal(t, acme.ID, found.ID)
+
+		many, err := orgs.ReadMany(ctx, []uuid.UUID{globex.ID, acme.ID})
+		require.NoError(t, err)
+		require.Len(t, many, 2)
+		assert.Equal(t, []string{"Acme", "Globex"}, []string{many[0].Name, many[1].Name})
+
+		listed, err := orgs.List(ctx, query.Where(query.Like("name", "%C%")).OrderedBy(query.Asc("name")).Window(1, 1))
+		require.NoError(t, err)
+		require.Len(t, listed, 1)
+		assert.Equal(t, "Initech", listed[0].Name)
+		count, err := orgs.Count(ctx, query.Like("name", "%C%"))
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+
+		found.Name = "Acme Corporation"
+		require.NoError(t, orgs.Update(ctx, found))
+		require.NoError(t, orgs.Delete(ctx, initech.ID))
+		all, err := orgs.ReadAll(ctx)
+		require.NoError(t, err)
+		require.Len(t, all, 2)
+		assert.Equal(t, "Acme Corporation", all[0].Name)
+	})
+}
+
+func TestMembershipRepository(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db database.Database) {
+		memberships := membership.NewMembershipRepository(db)
+		acme, globex, userID := uuid.New(), uuid.New(), uuid.New()
+		acmeCtx := database.WithTenant(context.Background(), acme)
+		globexCtx := database.WithTenant(context.Background(), globex)
+
+		require.NoError(t, memberships.Create(acmeCtx, entities.Membership{ID: uuid.New(), UserID: userID, Role: entities.RoleOwner}))
+		require.NoError(t, memberships.Create(globexCtx, entities.Membership{ID: uuid.New(), UserID: userID, Role: entities.RoleMember}))
+		require.NoError(t, memberships.Create(globexCtx, entities.Membership{ID: uuid.New(), UserID: uuid.New(), Role: entities.RoleMember}))
+		assert.Error(t, memberships.Create(acmeCtx, entities.Membership{ID: uuid.New(), UserID: userID, Role: entities.RoleMember}), "a user is a member of an organization once")
+		assert.Error(t, memberships.Create(context.Background(), entities.Membership{ID: uuid.New(), UserID: userID, Role: entities.RoleMember}), "tenant-owned records need a tenant")
+
+		m, err := memberships.Read(acmeCtx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, acme, m.OrganizationID)
+		assert.Equal(t, entities.RoleOwner, m.Role)
+
+		scoped, err := memberships.ReadAll(globexCtx)
+		require.NoError(t, err)
+		assert.Len(t, scoped, 2)
+		mine, err := memberships.ReadByUser(context.Background(), userID)
+		require.NoError(t, err)
+		assert.Len(t, mine, 2)
+		mine, err = memberships.ReadByUsers(acmeCtx, []uuid.UUID{userID})
+		require.NoError(t, err)
+		assert.Len(t, mine, 2)
+		all, err := memberships.ReadByOrganizations(acmeCtx, []uuid.UUID{acme, globex})
+		require.NoError(t, err)
+		assert.Len(t, all, 3)
+
+		m.Role = entities.RoleAdmin
+		require.NoError(t, memberships.Update(acmeCtx, m))
+		m, err = memberships.Read(acmeCtx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, entities.RoleAdmin, m.Role)
+
+		require.NoError(t, memberships.Delete(globexCtx, m.ID), "deleting the record of another tenant is a no-op")
+		_, err = memberships.Read(acmeCtx, userID)
+		assert.NoError(t, err)
+		require.NoError(t, memberships.Delete(acmeCtx, m.ID))
+		_, err = memberships.Read(acmeCtx, userID)
+		assert.Error(t, err)
+	})
+}
+
+func TestInvitationRepository(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db database.Database) {
+		invitations := invitation.NewInvitationRepository(db)
+		orgID := uuid.New()
+		ctx := database.WithTenant(context.Background(), orgID)
+		expires := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+
+		first := entities.Invitation{ID: uuid.New(), Email: "first@example.com", TokenHash: "first-token-hash", ExpiresAt: expires}
+		require.NoError(t, invitations.Create(ctx, first))
+		time.Sleep(10 * time.Millisecond)
+		second := entities.Invitation{ID: uuid.New(), Email: "second@example.com", TokenHash: "second-token-hash", ExpiresAt: expires, Role: entities.RoleAdmin}
+		require.NoError(t, invitations.Create(ctx, second))
+
+		read, err := invitations.Read(ctx, first.ID)
+		require.NoError(t, err)
+		assert.Equal(t, orgID, read.OrganizationID)
+		assert.True(t, expires.Equal(read.ExpiresAt))
+		assert.True(t, read.AcceptedAt.IsZero(), "a pending invitation has no acceptance time")
+
+		all, err := invitations.ReadAll(ctx)
+		require.NoError(t, err)
+		require.Len(t, all, 2)
+		assert.Equal(t, second.ID, all[0].ID, "the newest invitation comes first")
+
+		byToken, err := invitations.ReadByTokenHash(context.Background(), "second-token-hash")
+		require.NoError(t, err)
+		assert.Equal(t, second.ID, byToken.ID)
+
+		accepted := time.Now().UTC().Truncate(time.Second)
+		read.AcceptedAt = accepted
+		require.NoError(t, invitations.Update(ctx, read))
+		read, err = invitations.Read(ctx, first.ID)
+		require.NoError(t, err)
+		assert.True(t, accepted.Equal(read.AcceptedAt))
+	})
+}
+
+func TestAuditRepository(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db database.Database) {
+		ctx := context.Background()
+		events := audit.NewAuditRepository(db)
+
+		_, ok, err := events.Last(ctx)
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		start := time.Now().UTC().Truncate(time.Microsecond)
+		var appended []entities.AuditEvent
+		for i, actor := range []string{"alice", "bob", "alice"} {
+			event := entities.AuditEvent{Actor: actor, Action: entities.AuditActionLogin, Outcome: "success", Timestamp: start.Add(time.Duration(i) * time.Hour)}
+			event.Hash = event.ComputeHash()
+			stored, err := events.Append(ctx, event)
+			require.NoError(t, err)
+			appended = append(appended, stored)
+		}
+		assert.Less(t, appended[0].ID, appended[1].ID)
+
+		last, ok, err := events.Last(ctx)
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, appended[2].ID, last.ID)
+		assert.Equal(t, last.Hash, last.ComputeHash(), "the stored timestamp keeps the hash valid")
+
+		found, err := events.Find(ctx, entities.AuditFilter{Actor: "alice"})
+		require.NoError(t, err)
+		assert.Len(t, found, 2)
+
+		after, err := events.ReadAfter(ctx, appended[0].ID, 10)
+		require.NoError(t, err)
+		assert.Len(t, after, 2)
+
+		cutoff, ok, err := events.LastBefore(ctx, start.Add(90*time.Minute))
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, appended[1].ID, cutoff.ID)
+		_, ok, err = events.LastBefore(ctx, start)
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		deleted, err := events.DeleteThrough(ctx, cutoff.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), deleted)
+		count, err := events.Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		head, ok, err := events.Head(ctx)
+		require.NoError(t, err)
+		if !ok {
+			head, err = events.SaveHead(ctx, entities.AuditHead{})
+			require.NoError(t, err)
+		}
+		assert.Equal(t, int64(0), head.Count, "the head of the migrated events starts empty")
+		moved := head
+		moved.LastID, moved.Hash, moved.Count = last.ID, last.Hash, 1
+		moved, err = events.SaveHead(ctx, moved)
+		require.NoError(t, err)
+		_, err = events.SaveHead(ctx, head)
+		assert.ErrorIs(t, err, database.ErrConflict, "a head moved by another writer cannot be moved from its previous version")
+		read, ok, err := events.Head(ctx)
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, moved, read)
+	})
+}
+
+func TestOutboxRepository(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db database.Database) {
+		ctx := context.Background()
+		messages := outbox.NewOutboxRepository(db)
+
+		var appended []entities.OutboxMessage
+		for _, id := range []string{"a", "b", "a"} {
+			message, err := entities.NewOutboxMessage(entities.AggregateUser, id, entities.EventUserRegistered, entities.UserEvent{Username: id})
+			require.NoError(t, err)
+			stored, err := messages.Append(ctx, message)
+			require.NoError(t, err)
+			appended = append(appended, stored)
+		}
+		assert.Less(t, appended[0].ID, appended[1].ID)
+		assert.Less(t, appended[1].ID, appended[2].ID)
+
+		pending, err := messages.Pending(ctx, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, pending, 3)
+		assert.Equal(t, appended[0].ID, pending[0].ID, "the messages are pending in sequence order")
+		assert.JSONEq(t, `{"id":"00000000-0000-0000-0000-000000000000","username":"a"}`, pending[0].Payload)
+
+		claimed := pending[0]
+		claimed.Published = true
+		saved, err := messages.Save(ctx, claimed)
+		require.NoError(t, err)
+		assert.Equal(t, claimed.Version+1, saved.Version)
+		_, err = messages.Save(ctx, claimed)
+		assert.ErrorIs(t, err, database.ErrStale, "a message is saved once from the version it was read at")
+
+		pending, err = messages.Pending(ctx, 0, 1)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, appended[1].ID, pending[0].ID)
+		pending, err = messages.Pending(ctx, appended[1].ID, 10)
+		require.NoError(t, err)
+		require.Len(t, pending, 1, "the messages are paged by sequence number")
+		assert.Equal(t, appended[2].ID, pending[0].ID)
+
+		dead := pending[0]
+		dead.Dead = true
+		_, err = messages.Save(ctx, dead)
+		require.NoError(t, err)
+		pending, err = messages.Pending(ctx, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, pending, 1, "the dead messages are not pending")
+		assert.Equal(t, appended[1].ID, pending[0].ID)
+
+		deleted, err := messages.DeletePublishedBefore(ctx, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Zero(t, deleted, "the recently published messages are kept")
+		deleted, err = messages.DeletePublishedBefore(ctx, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted, "the pending messages are kept")
+	})
+}
+
+func TestWebhookRepository(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db database.Database) {
+		ctx := context.Background()
+		endpoints := webhook.NewEndpointRepository(db)
+		deliveries := webhook.NewDeliveryRepository(db)
+
+		created, err := endpoints.Create(ctx, entities.WebhookEndpoint{ID: uuid.New(), URL: "https://example.com/hook", Events: []string{"user.registered", "user.deleted"}, Secret: "whsec_a"})
+		require.NoError(t, err)
+		other, err := endpoints.Create(ctx, entities.WebhookEndpoint{ID: uuid.New(), URL: "https://example.org/hook", Secret: "whsec_b"})
+		require.NoError(t, err)
+
+		read, err := endpoints.Read(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"user.registered", "user.deleted"}, read.Events)
+		assert.Equal(t, "whsec_a", read.Secret)
+		assert.True(t, read.PreviousSecretExpiresAt.IsZero())
+
+		read.Events = []string{"user.*"}
+		read.Disabled = true
+		saved, err := endpoints.Save(ctx, read)
+		require.NoError(t, err)
+		_, err = endpoints.Save(ctx, read)
+		assert.ErrorIs(t, err, database.ErrStale, "an endpoint is saved once from the version it was read at")
+
+		all, err := endpoints.ReadAll(ctx)
+		require.NoError(t, err)
+		require.Len(t, all, 2)
+		assert.Equal(t, []string{"user.*"}, all[0].Events)
+		assert.Equal(t, saved.Version, all[0].Version)
+		assert.True(t, all[0].Disabled)
+		assert.Empty(t, all[1].Events, "an endpoint without event types receives every event")
+
+		now := time.Now().UTC().Truncate(time.Second)
+		for i, at := range []time.Time{now.Add(-time.Minute), now.Add(-2 * time.Minute), now.Add(time.Minute)} {
+			require.NoError(t, deliveries.Create(ctx, entities.WebhookDelivery{ID: uuid.New(), EndpointID: created.ID, EventID: fmt.Sprint(i), EventType: entities.EventUserRegistered, Status: entities.WebhookDeliveryPending, NextAttemptAt: at}))
+		}
+		duplicate := entities.WebhookDelivery{ID: uuid.New(), EndpointID: other.ID, EventID: "0", EventType: entities.EventUserRegistered, Status: entities.WebhookDeliveryPending, NextAttemptAt: now}
+		require.NoError(t, deliveries.Create(ctx, duplicate))
+		assert.ErrorIs(t, deliveries.Create(ctx, duplicate), database.ErrConflict, "a delivery is enqueued once")
+
+		due, err := deliveries.Due(ctx, now, 10)
+		require.NoError(t, err)
+		require.Len(t, due, 3)
+		assert.Equal(t, "1", due[0].EventID, "the longest waiting delivery comes first")
+
+		finished := due[0]
+		finished.Status = entities.WebhookDeliveryDead
+		finished.Attempts = 3
+		_, err = deliveries.Save(ctx, finished)
+		require.NoError(t, err)
+		dead, err := deliveries.ReadByEndpoint(ctx, created.ID, entities.WebhookDeliveryFilter{Status: entities.WebhookDeliveryDead})
+		require.NoError(t, err)
+		require.Len(t, dead, 1)
+		assert.Equal(t, 3, dead[0].Attempts)
+		history, err := deliveries.ReadByEndpoint(ctx, created.ID, entities.WebhookDeliveryFilter{Limit: 2})
+		require.NoError(t, err)
+		assert.Len(t, history, 2)
+
+		removed, err := deliveries.DeleteFinishedBefore(ctx, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), removed, "the pending deliveries are kept")
+		removed, err = deliveries.DeleteByEndpoint(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), removed)
+		require.NoError(t, endpoints.Delete(ctx, created.ID))
+		_, err = endpoints.Read(ctx, created.ID)
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		_, err = deliveries.Read(ctx, duplicate.ID)
+		assert.NoError(t, err, "the deliveries of the other endpoints are kept")
+	})
+}
+
+func TestUserEvents(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db database.Database) {
+		ctx := context.Background()
+		messages := outbox.NewOutboxRepository(db)
+		transactor := storage.NewTransactor(db)
+		users := user.NewEventRepository(user.NewUserRepository(db), transactor, messages)
+		types := func() []string {
+			pending, err := messages.Pending(ctx, 0, 100)
+			require.NoError(t, err)
+			var types []string
+			for _, message := range pending {
+				types = append(types, message.Type)
+			}
+			return types
+		}
+
+		alice := newUser("alice")
+		alice.Metadata.LastLoginAt = time.Now().UTC().Truncate(time.Second)
+		require.NoError(t, users.Create(ctx, alice))
+		assert.Error(t, users.Create(ctx, newUser("alice")), "usernames are unique")
+		assert.Equal(t, []string{entities.EventUserRegistered}, types(), "a failed write records no event")
+
+		failure := errors.New("failure")
+		err := transactor.WithTx(ctx, func(ctx context.Context) error {
+			require.NoError(t, users.Create(ctx, newUser("bob")))
+			return failure
+		})
+		assert.ErrorIs(t, err, failure)
+		assert.Len(t, types(), 1, "the events of a rolled back transaction are dropped")
+
+		stored, err := users.Read(ctx, alice.ID)
+		require.NoError(t, err)
+		stored.Deactivated = true
+		require.NoError(t, users.Update(ctx, stored))
+		assert.Len(t, types(), 1, "an update that keeps the username, email and password records no event")
+
+		stored, err = users.Read(ctx, alice.ID)
+		require.NoError(t, err)
+		stored.Email = "alice@example.org"
+		require.NoError(t, users.Update(ctx, stored))
+		stored, err = users.Read(ctx, alice.ID)
+		require.NoError(t, err)
+		stored.Password = "new-hash"
+		require.NoError(t, users.Update(ctx, stored))
+		require.NoError(t, users.Delete(ctx, alice.ID))
+		require.NoError(t, users.Delete(ctx, alice.ID))
+		assert.Equal(t, []string{entities.EventUserRegistered, entities.EventUserUpdated, entities.EventUserPasswordChanged, entities.EventUserDeleted}, types())
+
+		pending, err := messages.Pending(ctx, 0, 100)
+		require.NoError(t, err)
+		for _, message := range pending {
+			assert.Equal(t, entities.AggregateUser, message.AggregateType)
+			assert.Equal(t, alice.ID.String(), message.AggregateID)
+			assert.NotContains(t, message.Payload, "hash", "the events carry no password")
+		}
+	})
+}
+
+// countingUsers struct represents a user repository that counts its lookups, and may hold them until it is released.
+type countingUsers struct {
+	storage.UserRepository
+	reads   atomic.Int32
+	release chan struct{}
+}
+
+func (c *countingUsers) wait() {
+	c.reads.Add(1)
+	if c.release != nil {
+		<-c.release
+	}
+}
+
+func (c *countingUsers) Read(ctx context.Context, id uuid.UUID) (entities.User, error) {
+	c.wait()
+	return c.UserRepository.Read(ctx, id)
+}
+
+func (c *countingUsers) ReadByEmail(ctx context.Context, email string) (entities.User, error) {
+	c.wait()
+	return c.UserRepository.ReadByEmail(ctx, email)
+}
+
+func (c *countingUsers) ReadByUsername(ctx context.Context, username string) (entities.User, error) {
+	c.wait()
+	return c.UserRepository.ReadByUsername(ctx, username)
+}
+
+func TestCachedUserRepository(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db database.Database) {
+		ctx := context.Background()
+		counting := &countingUsers{UserRepository: user.NewUserRepository(db)}
+		users := user.NewCachedRepository(counting, cache.NewLRU(100), nil, time.Minute, time.Minute)
+		lookUp := func(lookup func() (entities.User, error)) (entities.User, int32, error) {
+			before := counting.reads.Load()
+			found, err := lookup()
+			return found, counting.reads.Load() - before, err
+		}
+
+		alice := newUser("alice")
+		alice.Metadata.LastLoginAt = time.Now().UTC().Truncate(time.Second)
+		_, reads, err := lookUp(func() (entities.User, error) { return users.ReadByEmail(ctx, alice.Email) })
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		assert.EqualValues(t, 1, reads)
+		_, reads, err = lookUp(func() (entities.User, error) { return users.ReadByEmail(ctx, alice.Email) })
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		assert.EqualValues(t, 0, reads, "The absence of a user is cached")
+
+		require.NoError(t, users.Create(ctx, alice))
+		found, reads, err := lookUp(func() (entities.User, error) { return users.ReadByEmail(ctx, alice.Email) })
+		require.NoError(t, err, "A created user replaces its cached absence")
+		assert.EqualValues(t, 1, reads)
+		for _, lookup := range []func() (entities.User, error){
+			func() (entities.User, error) { return users.ReadByEmail(ctx, alice.Email) },
+			func() (entities.User, error) { return users.ReadByUsername(ctx, alice.Username) },
+			func() (entities.User, error) { return users.Read(ctx, alice.ID) },
+		} {
+			cached, reads, err := lookUp(lookup)
+			require.NoError(t, err)
+			assert.EqualValues(t, 0, reads, "The user is cached under its ID, email and username")
+			assert.Equal(t, found, cached)
+		}
+
+		found.Email = "alice@example.org"
+		require.NoError(t, users.Update(ctx, found))
+		_, err = users.ReadByEmail(ctx, alice.Email)
+		assert.ErrorIs(t, err, database.ErrNotFound, "The previous email no longer leads to the user")
+		updated, reads, err := lookUp(func() (entities.User, error) { return users.ReadByUsername(ctx, alice.Username) })
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, reads, "An update removes the cached user")
+		assert.Equal(t, "alice@example.org", updated.Email)
+
+		transactor := storage.NewTransactor(db)
+		require.NoError(t, transactor.WithTx(ctx, func(ctx context.Context) error {
+			updated.Deactivated = true
+			if err := users.Update(ctx, updated); err != nil {
+				return err
+			}
+			inTx, reads, err := lookUp(func() (entities.User, error) { return users.Read(ctx, alice.ID) })
+			assert.EqualValues(t, 1, reads, "The reads in a transaction go to the storage")
+			assert.True(t, inTx.Deactivated)
+			return err
+		}))
+		deactivated, reads, err := lookUp(func() (entities.User, error) { return users.Read(ctx, alice.ID) })
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, reads, "The reads in a transaction do not fill the cache")
+		assert.True(t, deactivated.Deactivated)
+
+		require.NoError(t, users.Delete(ctx, alice.ID))
+		counting.reads.Store(0)
+		counting.release = make(chan struct{})
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := users.Read(ctx, alice.ID)
+				assert.ErrorIs(t, err, database.ErrNotFound, "A deleted user is removed from the cache")
+			}()
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(counting.release)
+		wg.Wait()
+		assert.EqualValues(t, 1, counting.reads.Load(), "The concurrent misses share a single read")
+	})
+}
+
+// recordingCache struct represents a cache that remembers the keys and the values it was asked to store.
+type recordingCache struct {
+	cache.Cache
+	keys   []string
+	values [][]byte
+}
+
+func (c *recordingCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.keys = append(c.keys, key)
+	c.values = append(c.values, value)
+	return c.Cache.Set(ctx, key, value, ttl)
+}
+
+func TestCachedUserRepositoryHidesEncryptedFields(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db database.Database) {
+		ctx := context.Background()
+		keys := newKeyring(t)
+		recording := &recordingCache{Cache: cache.NewLRU(100)}
+		users := user.NewCachedRepository(user.NewUserRepository(database.NewEncryptedDatabase(db, keys)), recording, keys, time.Minute, time.Minute)
+
+		_, err := users.ReadByEmail(ctx, "nobody@example.com")
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		alice := newUser("alice")
+		alice.Token = "alice-token"
+		require.NoError(t, users.Create(ctx, alice))
+		found, err := users.ReadByEmail(ctx, alice.Email)
+		require.NoError(t, err)
+		cached, err := users.ReadByEmail(ctx, alice.Email)
+		require.NoError(t, err)
+		assert.Equal(t, found, cached)
+		assert.Equal(t, "alice-token", cached.Token, "The cached users are decrypted")
+		byID, err := users.Read(ctx, alice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, found, byID)
+
+		require.NotEmpty(t, recording.keys)
+		for _, key := range recording.keys {
+			assert.NotContains(t, key, "@", "The cache keys must not disclose the encrypted emails")
+		}
+		for _, value := range recording.values {
+			for _, secret := range []string{alice.Email, alice.Password, alice.Token} {
+				assert.NotContains(t, string(value), secret, "The cached users must not disclose their encrypted fields")
+			}
+		}
+	})
+}
+
+func TestAfterTx(t *testing.T) {
+	forEachSavepointBackend(t, func(t *testing.T, db database.Database) {
+		ctx := context.Background()
+		transactor := storage.NewTransactor(db)
+		assert.False(t, storage.AfterTx(ctx, func(context.Context) {}), "The context carries no transaction")
+
+		var ran []string
+		failure := errors.New("failure")
+		err := transactor.WithTx(ctx, func(ctx context.Context) error {
+			assert.True(t, storage.InTx(ctx))
+			require.True(t, storage.AfterTx(ctx, func(ctx context.Context) {
+				assert.False(t, storage.InTx(ctx), "The functions are given a context without the transaction")
+				ran = append(ran, "outer")
+			}))
+			require.NoError(t, transactor.WithTx(ctx, func(ctx context.Context) error {
+				storage.AfterTx(ctx, func(context.Context) { ran = append(ran, "nested") })
+				return nil
+			}))
+			assert.Empty(t, ran, "The functions run once the outermost transaction ends")
+			return failure
+		})
+		assert.ErrorIs(t, err, failure)
+		assert.Equal(t, []string{"outer", "nested"}, ran, "The functions run after a rollback too")
+	})
+}
+
+func TestAfterCommit(t *testing.T) {
+	forEachSavepointBackend(t, func(t *testing.T, db database.Database) {
+		ctx := context.Background()
+		transactor := storage.NewTransactor(db)
+		assert.False(t, storage.AfterCommit(ctx, func(context.Context) {}), "The context carries no transaction")
+
+		var ran []string
+		failure := errors.New("failure")
+		err := transactor.WithTx(ctx, func(ctx context.Context) error {
+			require.True(t, storage.AfterCommit(ctx, func(ctx context.Context) {
+				assert.False(t, storage.InTx(ctx), "The functions are given a context without the transaction")
+				ran = append(ran, "outer")
+			}))
+			assert.ErrorIs(t, transactor.WithTx(ctx, func(ctx context.Context) error {
+				storage.AfterCommit(ctx, func(context.Context) { ran = append(ran, "rolled back") })
+				return failure
+			}), failure)
+			require.NoError(t, transactor.WithTx(ctx, func(ctx context.Context) error {
+				storage.AfterCommit(ctx, func(context.Context) { ran = append(ran, "nested") })
+				return nil
+			}))
+			assert.Empty(t, ran, "The functions run once the outermost transaction is committed")
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"outer", "nested"}, ran, "The functions of a savepoint that is rolled back never run")
+
+		ran = nil
+		err = transactor.WithTx(ctx, func(ctx context.Context) error {
+			storage.AfterCommit(ctx, func(context.Context) { ran = append(ran, "outer") })
+			return failure
+		})
+		assert.ErrorIs(t, err, failure)
+		assert.Empty(t, ran, "The functions do not run after a rollback")
+	})
+}
+
+func TestTransactions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db database.Database) {
+		ctx := context.Background()
+		users := user.NewUserRepository(db)
+		failure := errors.New("failure")
+		exists := func(name string) bool {
+			found, err := users.CheckUserExists(ctx, name+"@example.com", name)
+			require.NoError(t, err)
+			return found
+		}
+
+		require.NoError(t, db.WithTx(ctx, func(ctx context.Context) error {
+			require.NoError(t, users.Create(ctx, newUser("alice")))
+			_, err := users.ReadByUsername(ctx, "alice")
+			assert.NoError(t, err, "a transaction reads its own writes")
+			return nil
+		}))
+		assert.True(t, exists("alice"), "a committed transaction is kept")
+
+		err := db.WithTx(ctx, func(ctx context.Context) error {
+			require.NoError(t, users.Create(ctx, newUser("bob")))
+			return failure
+		})
+		assert.ErrorIs(t, err, failure)
+		assert.False(t, exists("bob"), "a failed transaction is rolled back")
+
+		assert.Panics(t, func() {
+			_ = db.WithTx(ctx, func(ctx context.Context) error {
+				require.NoError(t, users.Create(ctx, newUser("carol")))
+				panic(failure)
+			})
+		})
+		assert.False(t, exists("carol"), "a panicking transaction is rolled back")
+
+	})
+}
+
+func TestSavepoints(t *testing.T) {
+	forEachSavepointBackend(t, func(t *testing.T, db database.Database) {
+		ctx := context.Background()
+		users := user.NewUserRepository(db)
+		failure := errors.New("failure")
+		exists := func(name string) bool {
+			found, err := users.CheckUserExists(ctx, name+"@example.com", name)
+			require.NoError(t, err)
+			return found
+		}
+
+		require.NoError(t, db.WithTx(ctx, func(ctx context.Context) error {
+			require.NoError(t, users.Create(ctx, newUser("dave")))
+			err := db.WithTx(ctx, func(ctx context.Context) error {
+				require.NoError(t, users.Create(ctx, newUser("erin")))
+				return failure
+			})
+			assert.ErrorIs(t, err, failure)
+			require.NoError(t, db.WithTx(ctx, func(ctx context.Context) error {
+				return users.Create(ctx, newUser("frank"))
+			}))
+			return nil
+		}))
+		assert.True(t, exists("dave"))
+		assert.False(t, exists("erin"), "a failed savepoint is rolled back")
+		assert.True(t, exists("frank"), "a savepoint is committed with its transaction")
+
+		err := db.WithTx(ctx, func(ctx context.Context) error {
+			require.NoError(t, db.WithTx(ctx, func(ctx context.Context) error {
+				return users.Create(ctx, newUser("grace"))
+			}))
+			return failure
+		})
+		assert.ErrorIs(t, err, failure)
+		assert.False(t, exists("grace"), "a savepoint is rolled back with its transaction")
+	})
+}
+
+func TestErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db database.Database) {
+		ctx := context.Background()
+		users := user.NewUserRepository(db)
+		alice := newUser("alice")
+		require.NoError(t, users.Create(ctx, alice))
+
+		_, err := users.Read(ctx, uuid.New())
+		assert.ErrorIs(t, err, database.ErrNotFound)
+		_, err = users.ReadByEmail(ctx, "nobody@example.com")
+		assert.ErrorIs(t, err, database.ErrNotFound)
+
+		duplicate := newUser("alice")
+		duplicate.Email = "other@example.com"
+		err = users.Create(ctx, duplicate)
+		assert.ErrorIs(t, err, database.ErrConflict)
+		var conflict *database.ConflictError
+		if assert.ErrorAs(t, err, &conflict) {
+			assert.Equal(t, "users", conflict.Table)
+			assert.Equal(t, "username", conflict.Field())
+		}
+		err = db.WithTx(ctx, func(ctx context.Context) error {
+			return users.Create(ctx, alice)
+		})
+		assert.ErrorIs(t, err, database.ErrConflict, "a conflict is reported through the transaction")
+
+		expired, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+		defer cancel()
+		_, err = users.Read(expired, alice.ID)
+		assert.ErrorIs(t, err, database.ErrTimeout)
+	})
+}
+
+func TestOptimisticConcurrency(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db database.Database) {
+		ctx := context.Background()
+		users := user.NewUserRepository(db)
+		alice := newUser("alice")
+		alice.Metadata.LastLoginAt = time.Now().UTC().Truncate(time.Second)
+		require.NoError(t, users.Create(ctx, alice))
+		require.NoError(t, users.Create(ctx, newUser("bob")))
+		alice, err := users.ReadByUsername(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), alice.Metadata.Version, "a new record has the first version")
+
+		// Two edits based on the same read: the first one wins, and the second one must not overwrite it.
+		profile, login := alice, alice
+		profile.Email = "alice@example.org"
+		require.NoError(t, db.Update(ctx, &profile))
+		assert.Equal(t, int64(2), profile.Metadata.Version, "the new version is written back")
+		login.Token = "alice-token"
+		err = db.Update(ctx, &login)
+		assert.ErrorIs(t, err, database.ErrStale)
+		assert.ErrorIs(t, err, database.ErrConflict)
+		assert.Equal(t, int64(1), login.Metadata.Version, "the version of a failed update is kept")
+
+		stored, err := users.Read(ctx, alice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "alice@example.org", stored.Email)
+		assert.Empty(t, stored.Token)
+		assert.Equal(t, int64(2), stored.Metadata.Version)
+
+		unversioned := stored
+		unversioned.Metadata.Version = 0
+		assert.ErrorIs(t, db.Update(ctx, &unversioned), database.ErrStale, "a record without a version is not the stored one")
+
+		// A unique constraint is still reported as such, and not as an outdated version.
+		stored.Username = "bob"
+		err = db.Update(ctx, &stored)
+		assert.ErrorIs(t, err, database.ErrConflict)
+		assert.NotErrorIs(t, err, database.ErrStale)
+		assert.Equal(t, int64(2), stored.Metadata.Version)
+
+		carol := newUser("carol")
+		require.NoError(t, db.Update(ctx, &carol), "an update of a missing record adds it")
+		created, err := users.Read(ctx, carol.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), created.Metadata.Version)
+	})
+}
+
+func TestMigrations(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db database.Database) {
+		ctx := context.Background()
+		migrator, err := database.NewMigrator(db, sources...)
+		if errors.Is(err, database.ErrMigrationsNotSupported) {
+			t.Skip("the database has no schema")
+		}
+		require.NoError(t, err)
+
+		statuses, err := migrator.Status(ctx)
+		require.NoError(t, err)
+		require.Len(t, statuses, migrations)
+		for _, status := range statuses {
+			assert.False(t, status.AppliedAt.IsZero(), "%s is not applied", status.Migration)
+		}
+
+		reverted, err := migrator.Down(ctx, 0)
+		require.NoError(t, err)
+		assert.Len(t, reverted, migrations)
+		assert.Error(t, db.ReadAll(ctx, &[]entities.User{}), "the users table is dropped")
+
+		// Concurrent migrators, such as instances of the application starting at once, apply every migration once.
+		var (
+			wg      sync.WaitGroup
+			applied atomic.Int64
+			errs    = make(chan error, 3)
+		)
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				done, err := migrator.Up(ctx, 0)
+				applied.Add(int64(len(done)))
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, int64(migrations), applied.Load())
+
+		users := user.NewUserRepository(db)
+		require.NoError(t, users.Create(ctx, newUser("alice")))
+		statuses, err = migrator.Status(ctx)
+		require.NoError(t, err)
+		for _, status := range statuses {
+			assert.False(t, status.AppliedAt.IsZero(), "%s is not applied", status.Migration)
+		}
+	})
+}
+
+func TestMigrationsAdoptBaselineDatabase(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "baseline.sqlite3")
+
+	// The schema the application created before the migrations were versioned, with a user signed up then.
+	baseline, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	for _, statement := range []string{
+		"CREATE TABLE `user_logins` (`email` text,`password` text)",
+		"CREATE TABLE `users` (`id` uuid DEFAULT \"DEFAULT\",`username` text NOT NULL UNIQUE,`password` text,`email` text NOT NULL UNIQUE," +
+			"`created_at` datetime,`updated_at` datetime,`last_login_at` datetime DEFAULT null,`token` text,PRIMARY KEY (`id`))",
+		"INSERT INTO users (id, username, password, email, created_at, updated_at) VALUES ('" + uuid.NewString() + "', 'alice', 'hash', 'alice@example.com', '2024-01-02 03:04:05', '2024-01-02 03:04:05')",
+	} {
+		_, err = baseline.Exec(statement)
+		require.NoError(t, err)
+	}
+	require.NoError(t, baseline.Close())
+
+	db, err := database.NewDatabase(&config.Config{DB: config.DatabaseConfig{DatabaseType: "sqlite", Sqlite: config.SqliteConfig{DatabasePath: path}}})
+	require.NoError(t, err)
+	if closer, ok := db.(io.Closer); ok {
+		t.Cleanup(func() { _ = closer.Close() })
+	}
+	migrator, err := database.NewMigrator(db, sources...)
+	require.NoError(t, err)
+	applied, err := migrator.Up(ctx, 0)
+	require.NoError(t, err, "The existing database is adopted")
+	assert.Len(t, applied, migrations)
+
+	users := user.NewUserRepository(db)
+	alice, err := users.ReadByUsername(ctx, "alice")
+	require.NoError(t, err, "The existing users are kept")
+	assert.Equal(t, int64(1), alice.Metadata.Version)
+	assert.False(t, alice.Deactivated)
+	alice.ExternalID = "alice-external"
+	require.NoError(t, users.Update(ctx, alice), "The added columns are written")
+	bob := newUser("bob")
+	require.NoError(t, users.Create(ctx, bob))
+}
+
+func TestMigrationsExpireUnhashedInvitations(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "invitations.sqlite3")
+	db, err := database.NewDatabase(&config.Config{DB: config.DatabaseConfig{DatabaseType: "sqlite", Sqlite: config.SqliteConfig{DatabasePath: path}}})
+	require.NoError(t, err)
+	if closer, ok := db.(io.Closer); ok {
+		t.Cleanup(func() { _ = closer.Close() })
+	}
+	migrator, err := database.NewMigrator(db, sources...)
+	require.NoError(t, err)
+	_, err = migrator.Up(ctx, 0)
+	require.NoError(t, err)
+	reverted, err := migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	require.Equal(t, "hash_tokens", reverted[0].Name)
+
+	// An invitation created before the tokens were hashed, whose link is still outstanding.
+	id := uuid.New()
+	legacy, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = legacy.Exec("INSERT INTO invitations (id, email, token, expires_at, created_at, updated_at) VALUES (?, 'legacy@example.com', 'legacy-token', '2099-01-02 03:04:05', '2024-01-02 03:04:05', '2024-01-02 03:04:05')", id.String())
+	require.NoError(t, err)
+	require.NoError(t, legacy.Close())
+
+	_, err = migrator.Up(ctx, 0)
+	require.NoError(t, err)
+	invitations := invitation.NewInvitationRepository(db)
+	read, err := invitations.Read(database.WithoutTenantScope(ctx), id)
+	require.NoError(t, err)
+	assert.NotEqual(t, "legacy-token", read.TokenHash, "the plaintext token is not kept")
+	assert.Equal(t, entities.InvitationExpired, read.StatusAt(time.Now()), "the invitation expires so that it can be resent")
+	sum := sha256.Sum256([]byte("legacy-token"))
+	_, err = invitations.ReadByTokenHash(ctx, hex.EncodeToString(sum[:]))
+	assert.ErrorIs(t, err, database.ErrNotFound)
+}
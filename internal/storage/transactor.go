@@ -0,0 +1,134 @@
+// Package storage provides the functionality to run storage operations in transactions.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"sync"
+)
+
+// txKey is the context key under which the hooks of the transaction of the context are stored.
+type txKey struct{}
+
+// txHooks struct represents the functions to run once a transaction ends, and those to run only once it is committed.
+type txHooks struct {
+	mu      sync.Mutex
+	fns     []func(ctx context.Context)
+	commits []func(ctx context.Context)
+}
+
+// transactor struct represents the transactions of the database, which let the repositories defer work until they end.
+type transactor struct {
+	db database.Database
+}
+
+// NewTransactor exposes the transactions of the database to the use cases.
+// db: The database the repositories use.
+// Returns a Transactor object.
+func NewTransactor(db database.Database) Transactor {
+	return transactor{db: db}
+}
+
+// WithTx runs fn in a transaction of the database, and then the functions registered with AfterTx, even if fn panics,
+// and the functions registered with AfterCommit if the transaction is committed.
+// A nested call runs fn in a savepoint, and leaves the functions to the enclosing transaction.
+// ctx: The context for the operation.
+// fn: The function to run in the transaction.
+// opts: The isolation level and read-only flag of the transaction.
+// Returns the error returned by fn, or an error if the transaction cannot be started or committed.
+func (t transactor) WithTx(ctx context.Context, fn func(ctx context.Context) error, opts ...*sql.TxOptions) error {
+	if hooks, ok := ctx.Value(txKey{}).(*txHooks); ok {
+		// The functions registered with AfterCommit in a savepoint that is rolled back never run.
+		mark := hooks.mark()
+		err := t.db.WithTx(ctx, fn, opts...)
+		if err != nil {
+			hooks.discard(mark)
+		}
+		return err
+	}
+	hooks := &txHooks{}
+	defer hooks.run(ctx)
+	err := t.db.WithTx(context.WithValue(ctx, txKey{}, hooks), fn, opts...)
+	hooks.run(ctx)
+	if err == nil {
+		hooks.commit(ctx)
+	}
+	return err
+}
+
+// InTx reports whether the context carries a transaction started by a Transactor.
+// ctx: The context to inspect.
+// Returns true if the operations of the context run in a transaction.
+func InTx(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(*txHooks)
+	return ok
+}
+
+// AfterTx registers a function to run once the transaction of the context ends, whether it is committed or rolled back,
+// such as the record of a failed attempt, which the rollback does not undo.
+// ctx: The context of the transaction.
+// fn: The function to run. It is given the context the transaction was started with, which carries no transaction.
+// Returns false, and does not register fn, if the context carries no transaction.
+func AfterTx(ctx context.Context, fn func(ctx context.Context)) bool {
+	hooks, ok := ctx.Value(txKey{}).(*txHooks)
+	if !ok {
+		return false
+	}
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.fns = append(hooks.fns, fn)
+	return true
+}
+
+// AfterCommit registers a function to run once the transaction of the context is committed, such as the publication
+// of an event about the changes of the transaction, which must not be seen if they are rolled back.
+// ctx: The context of the transaction.
+// fn: The function to run. It is given the context the transaction was started with, which carries no transaction.
+// Returns false, and does not register fn, if the context carries no transaction.
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) bool {
+	hooks, ok := ctx.Value(txKey{}).(*txHooks)
+	if !ok {
+		return false
+	}
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.commits = append(hooks.commits, fn)
+	return true
+}
+
+// mark returns the number of functions registered with AfterCommit, which a savepoint that is rolled back discards the later ones of.
+func (h *txHooks) mark() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.commits)
+}
+
+// discard forgets the functions registered with AfterCommit after the mark.
+func (h *txHooks) discard(mark int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.commits = h.commits[:mark]
+}
+
+// commit runs the functions registered with AfterCommit once.
+func (h *txHooks) commit(ctx context.Context) {
+	h.mu.Lock()
+	fns := h.commits
+	h.commits = nil
+	h.mu.Unlock()
+	for _, fn := range fns {
+		fn(ctx)
+	}
+}
+
+// run runs the functions registered with AfterTx once.
+func (h *txHooks) run(ctx context.Context) {
+	h.mu.Lock()
+	fns := h.fns
+	h.fns = nil
+	h.mu.Unlock()
+	for _, fn := range fns {
+		fn(ctx)
+	}
+}
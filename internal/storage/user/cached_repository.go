@@ -0,0 +1,322 @@
+// Package user provides the functionality to cache the user data of the storage.
+package user
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/cache"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/keyring"
+	"golang.org/x/sync/singleflight"
+	"log"
+	"time"
+)
+
+// The prefixes of the cache keys. A user is cached under its ID, and its email and username are cached with its ID,
+// so that a change of the email or the username needs no lookup of the previous ones: the user a key leads to is checked.
+const (
+	idPrefix       = "users:id:"
+	emailPrefix    = "users:email:"
+	usernamePrefix = "users:username:"
+)
+
+const (
+	// emailContext is the context of the blind indexes the emails are cached under, which differ from those of the database.
+	emailContext = "cache:users:email"
+	// userContext is the context the cached users are encrypted with.
+	userContext = "cache:users"
+)
+
+// CachedRepository struct represents a user repository decorator that caches the users read by ID, email and username.
+// The absence of a user is cached as well, for a shorter time, and the concurrent misses of a key share a single read.
+// The writes remove the entries they affect, and again once their transaction ends, so that a read that filled the cache
+// while the transaction ran is removed too. The reads in a transaction go to the storage, which may hold uncommitted changes,
+// and so do the reads that must see the latest writes, see database.WithPrimary.
+// The writes that bypass the repository, e.g. the restore of a backup, and a read that fills the cache with a user
+// a concurrent write modifies, are seen once the entries expire.
+// When the fields of the users are encrypted, the cached users are encrypted as well, and the emails are cached under their
+// blind index, so that neither the values nor the keys of the cache disclose what the database encrypts.
+type CachedRepository struct {
+	users       storage.UserRepository
+	cache       cache.Cache
+	keys        *keyring.Keyring
+	ttl         time.Duration
+	negativeTTL time.Duration
+	group       singleflight.Group
+}
+
+// NewCachedRepository creates a new caching decorator of a user repository.
+// users: The repository to decorate.
+// c: The cache of the users. The users are cached with their email, password hash and token, so a shared cache must be protected as well as the database.
+// keys: The keyring the fields of the users are encrypted with, which encrypts the cached users and whose blind indexes are the cache keys of the emails.
+// Nil caches the users in clear and the emails under their value.
+// ttl: How long a user is cached.
+// negativeTTL: How long the absence of a user is cached. Zero disables the negative caching.
+// Returns a *CachedRepository object.
+func NewCachedRepository(users storage.UserRepository, c cache.Cache, keys *keyring.Keyring, ttl, negativeTTL time.Duration) *CachedRepository {
+	return &CachedRepository{
+		users:       users,
+		cache:       c,
+		keys:        keys,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+	}
+}
+
+// Create adds a new user record to the storage, and removes the cached absence of its ID, email and username.
+// ctx: The context for the operation.
+// model: The user record to add.
+// Returns an error if the operation fails.
+func (r *CachedRepository) Create(ctx context.Context, model entities.User) error {
+	err := r.users.Create(ctx, model)
+	r.invalidate(ctx, model)
+	return err
+}
+
+// Read retrieves a user record from the cache, or from the storage on a miss.
+// ctx: The context for the operation.
+// id: The id of the user record to retrieve.
+// Returns the user record and an error if the operation fails.
+func (r *CachedRepository) Read(ctx context.Context, id uuid.UUID) (entities.User, error) {
+	if storage.InTx(ctx) || database.PrimaryFromContext(ctx) {
+		return r.users.Read(ctx, id)
+	}
+	return r.share(idPrefix+id.String(), func() (entities.User, error) {
+		if user, found, err := r.cached(ctx, id); found {
+			return user, err
+		}
+		return r.load(ctx, idPrefix+id.String(), func() (entities.User, error) {
+			return r.users.Read(ctx, id)
+		})
+	})
+}
+
+// Update modifies a user record in the storage, and removes the cached user.
+// ctx: The context for the operation.
+// model: The user record to modify.
+// Returns an error if the operation fails.
+func (r *CachedRepository) Update(ctx context.Context, model entities.User) error {
+	err := r.users.Update(ctx, model)
+	r.invalidate(ctx, model)
+	return err
+}
+
+// Delete removes a user record from the storage and from the cache.
+// ctx: The context for the operation.
+// id: The id of the user record to remove.
+// Returns an error if the operation fails.
+func (r *CachedRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	err := r.users.Delete(ctx, id)
+	r.invalidate(ctx, entities.User{ID: id})
+	return err
+}
+
+// ReadByEmail retrieves a user record from the cache based on the email, or from the storage on a miss.
+// ctx: The context for the operation.
+// email: The email of the user record to retrieve.
+// Returns the user record and an error if the operation fails.
+func (r *CachedRepository) ReadByEmail(ctx context.Context, email string) (entities.User, error) {
+	return r.readBy(ctx, r.emailKey(email), func(user entities.User) bool {
+		return user.Email == email
+	}, func() (entities.User, error) {
+		return r.users.ReadByEmail(ctx, email)
+	})
+}
+
+// ReadByUsername retrieves a user record from the cache based on the username, or from the storage on a miss.
+// ctx: The context for the operation.
+// username: The username of the user record to retrieve.
+// Returns the user record and an error if the operation fails.
+func (r *CachedRepository) ReadByUsername(ctx context.Context, username string) (entities.User, error) {
+	return r.readBy(ctx, usernamePrefix+username, func(user entities.User) bool {
+		return user.Username == username
+	}, func() (entities.User, error) {
+		return r.users.ReadByUsername(ctx, username)
+	})
+}
+
+// ReadByToken retrieves a user record from the storage based on the bearer token. The tokens are not cached.
+// ctx: The context for the operation.
+// token: The bearer token of the user record to retrieve.
+// Returns the user record and an error if the operation fails.
+func (r *CachedRepository) ReadByToken(ctx context.Context, token string) (entities.User, error) {
+	return r.users.ReadByToken(ctx, token)
+}
+
+// ReadAll retrieves all user records from the storage.
+// ctx: The context for the operation.
+// model: The user records to retrieve.
+// Returns the user records and an error if the operation fails.
+func (r *CachedRepository) ReadAll(ctx context.Context, model []entities.User) ([]entities.User, error) {
+	return r.users.ReadAll(ctx, model)
+}
+
+// ReadMany retrieves the user records with the given ids from the storage. The lists are not cached.
+// ctx: The context for the operation.
+// ids: The ids of the user records to retrieve.
+// Returns the user records and an error if the operation fails.
+func (r *CachedRepository) ReadMany(ctx context.Context, ids []uuid.UUID) ([]entities.User, error) {
+	return r.users.ReadMany(ctx, ids)
+}
+
+// List retrieves the user records that match the query from the storage. The lists are not cached.
+// ctx: The context for the operation.
+// q: The condition, the order and the window of the user records to retrieve.
+// Returns the user records and an error if the operation fails.
+func (r *CachedRepository) List(ctx context.Context, q query.Query) ([]entities.User, error) {
+	return r.users.List(ctx, q)
+}
+
+// Count counts the user records that match the condition in the storage. The counts are not cached.
+// ctx: The context for the operation.
+// where: The condition of the user records to count. A nil condition counts every user record.
+// Returns the number of user records and an error if the operation fails.
+func (r *CachedRepository) Count(ctx context.Context, where query.Condition) (int64, error) {
+	return r.users.Count(ctx, where)
+}
+
+// CheckUserExists checks if a user exists in the storage based on the email and username.
+// ctx: The context for the operation.
+// email: The email of the user to check.
+// username: The username of the user to check.
+// Returns a boolean indicating if the user exists and an error if the operation fails.
+func (r *CachedRepository) CheckUserExists(ctx context.Context, email string, username string) (bool, error) {
+	return r.users.CheckUserExists(ctx, email, username)
+}
+
+// Search retrieves the user records whose username or email matches the search from the storage. The searches are not cached.
+// ctx: The context for the operation.
+// search: The text and the window of the search.
+// Returns the found user records with their ranks, the number of found user records regardless of the window,
+// and an error if the operation fails.
+func (r *CachedRepository) Search(ctx context.Context, search entities.UserSearch) (entities.UserSearchResult, error) {
+	return r.users.Search(ctx, search)
+}
+
+// readBy retrieves the user a key of an email or a username leads to, if it still matches, or reads it from the storage.
+func (r *CachedRepository) readBy(ctx context.Context, key string, matches func(entities.User) bool, read func() (entities.User, error)) (entities.User, error) {
+	if storage.InTx(ctx) || database.PrimaryFromContext(ctx) {
+		return read()
+	}
+	return r.share(key, func() (entities.User, error) {
+		value, found, err := r.cache.Get(ctx, key)
+		if err != nil {
+			log.Printf("Failed to read the user cache: %v\n", err)
+		}
+		if found && len(value) == 0 {
+			return entities.User{}, database.ErrNotFound
+		}
+		if id, err := uuid.FromBytes(value); found && err == nil {
+			if user, found, err := r.cached(ctx, id); found && err == nil && matches(user) {
+				return user, nil
+			}
+		}
+		return r.load(ctx, key, read)
+	})
+}
+
+// cached retrieves the user cached under its ID.
+// Returns the user, a boolean indicating if the cache has an entry, and database.ErrNotFound if the entry is the absence of the user.
+func (r *CachedRepository) cached(ctx context.Context, id uuid.UUID) (entities.User, bool, error) {
+	value, found, err := r.cache.Get(ctx, idPrefix+id.String())
+	if err != nil {
+		log.Printf("Failed to read the user cache: %v\n", err)
+		return entities.User{}, false, nil
+	}
+	if !found {
+		return entities.User{}, false, nil
+	}
+	if len(value) == 0 {
+		return entities.User{}, true, database.ErrNotFound
+	}
+	if r.keys != nil {
+		plaintext, err := r.keys.Decrypt(string(value), userContext)
+		if err != nil {
+			return entities.User{}, false, nil
+		}
+		value = []byte(plaintext)
+	}
+	var user entities.User
+	if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&user); err != nil {
+		return entities.User{}, false, nil
+	}
+	return user, true, nil
+}
+
+// load reads a user from the storage and caches it, or caches its absence under the key it was looked up with.
+func (r *CachedRepository) load(ctx context.Context, key string, read func() (entities.User, error)) (entities.User, error) {
+	user, err := read()
+	if errors.Is(err, database.ErrNotFound) && r.negativeTTL > 0 {
+		r.set(ctx, key, nil, r.negativeTTL)
+	}
+	if err != nil {
+		return user, err
+	}
+	var value bytes.Buffer
+	if err := gob.NewEncoder(&value).Encode(user); err != nil {
+		return user, nil
+	}
+	encoded := value.Bytes()
+	if r.keys != nil {
+		sealed, err := r.keys.Encrypt(value.String(), userContext)
+		if err != nil {
+			return user, nil
+		}
+		encoded = []byte(sealed)
+	}
+	r.set(ctx, idPrefix+user.ID.String(), encoded, r.ttl)
+	r.set(ctx, r.emailKey(user.Email), user.ID[:], r.ttl)
+	r.set(ctx, usernamePrefix+user.Username, user.ID[:], r.ttl)
+	return user, nil
+}
+
+// emailKey returns the cache key of an email: its blind index if the emails are encrypted, or the email itself otherwise.
+func (r *CachedRepository) emailKey(email string) string {
+	if r.keys == nil {
+		return emailPrefix + email
+	}
+	return emailPrefix + r.keys.Index(email, emailContext)
+}
+
+// share runs a read once for the concurrent callers of the same key.
+func (r *CachedRepository) share(key string, read func() (entities.User, error)) (entities.User, error) {
+	user, err, _ := r.group.Do(key, func() (interface{}, error) {
+		return read()
+	})
+	return user.(entities.User), err
+}
+
+// set caches a value, and logs the failures, since the cache only spares reads of the storage.
+func (r *CachedRepository) set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	if err := r.cache.Set(ctx, key, value, ttl); err != nil {
+		log.Printf("Failed to write the user cache: %v\n", err)
+	}
+}
+
+// invalidate removes the entries of a user, and again once the transaction of the context ends.
+func (r *CachedRepository) invalidate(ctx context.Context, user entities.User) {
+	keys := []string{idPrefix + user.ID.String()}
+	if user.Email != "" {
+		keys = append(keys, r.emailKey(user.Email))
+	}
+	if user.Username != "" {
+		keys = append(keys, usernamePrefix+user.Username)
+	}
+	r.forget(ctx, keys)
+	storage.AfterTx(ctx, func(ctx context.Context) {
+		r.forget(context.WithoutCancel(ctx), keys)
+	})
+}
+
+// forget removes entries of the cache, and logs the failures.
+func (r *CachedRepository) forget(ctx context.Context, keys []string) {
+	if err := r.cache.Delete(ctx, keys...); err != nil {
+		log.Printf("Failed to invalidate the user cache: %v\n", err)
+	}
+}
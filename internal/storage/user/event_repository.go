@@ -0,0 +1,117 @@
+// Package user provides the functionality to record the domain events of the user data of the storage.
+package user
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+)
+
+// EventRepository struct represents a user repository decorator that appends the domain events of the writes to the outbox,
+// in the transaction of the write, so that an event is stored if and only if the change it describes is.
+// A write outside a transaction runs in one of its own.
+type EventRepository struct {
+	storage.UserRepository
+	tx     storage.Transactor
+	outbox storage.OutboxRepository
+}
+
+// NewEventRepository creates a new event recording decorator of a user repository.
+// users: The repository to decorate.
+// tx: The transactions the writes and their events run in.
+// outbox: The outbox the events are appended to.
+// Returns an *EventRepository object.
+func NewEventRepository(users storage.UserRepository, tx storage.Transactor, outbox storage.OutboxRepository) *EventRepository {
+	return &EventRepository{
+		UserRepository: users,
+		tx:             tx,
+		outbox:         outbox,
+	}
+}
+
+// Create adds a new user record to the storage, and appends a user.registered event.
+// ctx: The context for the operation.
+// model: The user record to add.
+// Returns an error if the operation fails.
+func (r *EventRepository) Create(ctx context.Context, model entities.User) error {
+	return r.atomically(ctx, func(ctx context.Context) error {
+		if err := r.UserRepository.Create(ctx, model); err != nil {
+			return err
+		}
+		return r.append(ctx, entities.EventUserRegistered, model)
+	})
+}
+
+// Update modifies a user record in the storage, and appends a user.updated event if its username or email changed,
+// and a user.password_changed event if its password changed.
+// ctx: The context for the operation.
+// model: The user record to modify.
+// Returns an error if the operation fails.
+func (r *EventRepository) Update(ctx context.Context, model entities.User) error {
+	return r.atomically(ctx, func(ctx context.Context) error {
+		previous, readErr := r.UserRepository.Read(ctx, model.ID)
+		if readErr != nil && !errors.Is(readErr, database.ErrNotFound) {
+			return readErr
+		}
+		if err := r.UserRepository.Update(ctx, model); err != nil {
+			return err
+		}
+		if readErr != nil {
+			return nil
+		}
+		if previous.Username != model.Username || previous.Email != model.Email {
+			if err := r.append(ctx, entities.EventUserUpdated, model); err != nil {
+				return err
+			}
+		}
+		if previous.Password != model.Password {
+			return r.append(ctx, entities.EventUserPasswordChanged, model)
+		}
+		return nil
+	})
+}
+
+// Delete removes a user record from the storage, and appends a user.deleted event if it existed.
+// ctx: The context for the operation.
+// id: The id of the user record to remove.
+// Returns an error if the operation fails.
+func (r *EventRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.atomically(ctx, func(ctx context.Context) error {
+		previous, err := r.UserRepository.Read(ctx, id)
+		if errors.Is(err, database.ErrNotFound) {
+			return r.UserRepository.Delete(ctx, id)
+		}
+		if err != nil {
+			return err
+		}
+		if err := r.UserRepository.Delete(ctx, id); err != nil {
+			return err
+		}
+		return r.append(ctx, entities.EventUserDeleted, previous)
+	})
+}
+
+// atomically runs fn in the transaction of the context, or in a new one.
+func (r *EventRepository) atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	if storage.InTx(ctx) {
+		return fn(ctx)
+	}
+	return r.tx.WithTx(ctx, fn)
+}
+
+// append appends an event of a user to the outbox. The event carries neither the password nor the token of the user.
+func (r *EventRepository) append(ctx context.Context, eventType string, user entities.User) error {
+	message, err := entities.NewOutboxMessage(entities.AggregateUser, user.ID.String(), eventType, entities.UserEvent{
+		ID:       user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.outbox.Append(ctx, message)
+	return err
+}
@@ -8,11 +8,16 @@ import (
 	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
 	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
 	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"sort"
+	"strings"
 )
 
 // Repository struct represents a user repository that provides methods for user data operations.
+// The CRUD operations are the ones of the generic repository, which it adds the lookups of the users to.
 type Repository struct {
-	db database.Database
+	db    database.Database
+	users database.Repository[entities.User, uuid.UUID]
 }
 
 // Create adds a new user record to the storage.
@@ -20,10 +25,7 @@ type Repository struct {
 // model: The user record to add.
 // Returns an error if the operation fails.
 func (r Repository) Create(ctx context.Context, model entities.User) error {
-	if err := r.db.Create(ctx, &model); err != nil {
-		return err
-	}
-	return nil
+	return r.users.Create(ctx, model)
 }
 
 // Read retrieves a user record from the storage.
@@ -31,12 +33,7 @@ func (r Repository) Create(ctx context.Context, model entities.User) error {
 // id: The id of the user record to retrieve.
 // Returns the user record and an error if the operation fails.
 func (r Repository) Read(ctx context.Context, id uuid.UUID) (entities.User, error) {
-	var user entities.User
-	result := r.db.Read(ctx, &user, "id = ?", id)
-	if result != nil {
-		return entities.User{}, result
-	}
-	return user, nil
+	return r.users.Get(ctx, id)
 }
 
 // Update modifies a user record in the storage.
@@ -44,10 +41,7 @@ func (r Repository) Read(ctx context.Context, id uuid.UUID) (entities.User, erro
 // model: The user record to modify.
 // Returns an error if the operation fails.
 func (r Repository) Update(ctx context.Context, model entities.User) error {
-	if err := r.db.Update(ctx, &model); err != nil {
-		return err
-	}
-	return nil
+	return r.users.Update(ctx, model)
 }
 
 // Delete removes a user record from the storage.
@@ -55,10 +49,7 @@ func (r Repository) Update(ctx context.Context, model entities.User) error {
 // id: The id of the user record to remove.
 // Returns an error if the operation fails.
 func (r Repository) Delete(ctx context.Context, id uuid.UUID) error {
-	if err := r.db.Delete(ctx, entities.User{}, id); err != nil {
-		return err
-	}
-	return nil
+	return r.users.Delete(ctx, id)
 }
 
 // ReadByEmail retrieves a user record from the storage based on the email.
@@ -66,11 +57,7 @@ func (r Repository) Delete(ctx context.Context, id uuid.UUID) error {
 // email: The email of the user record to retrieve.
 // Returns the user record and an error if the operation fails.
 func (r Repository) ReadByEmail(ctx context.Context, email string) (entities.User, error) {
-	var user entities.User
-	if err := r.db.Read(ctx, &user, "email = ?", email); err != nil {
-		return entities.User{}, errors.New("record not found")
-	}
-	return user, nil
+	return r.users.First(ctx, query.Eq("email", email))
 }
 
 // ReadByUsername retrieves a user record from the storage based on the username.
@@ -78,11 +65,18 @@ func (r Repository) ReadByEmail(ctx context.Context, email string) (entities.Use
 // username: The username of the user record to retrieve.
 // Returns the user record and an error if the operation fails.
 func (r Repository) ReadByUsername(ctx context.Context, username string) (entities.User, error) {
-	var user entities.User
-	if err := r.db.Read(ctx, &user, "username = ?", username); err != nil {
-		return entities.User{}, errors.New("record not found")
+	return r.users.First(ctx, query.Eq("username", username))
+}
+
+// ReadByToken retrieves a user record from the storage based on the bearer token.
+// ctx: The context for the operation.
+// token: The bearer token of the user record to retrieve.
+// Returns the user record and an error if the operation fails.
+func (r Repository) ReadByToken(ctx context.Context, token string) (entities.User, error) {
+	if token == "" {
+		return entities.User{}, database.ErrNotFound
 	}
-	return user, nil
+	return r.users.First(ctx, query.Eq("token", token))
 }
 
 // CheckUserExists checks if a user exists in the storage based on the email and username.
@@ -91,28 +85,173 @@ func (r Repository) ReadByUsername(ctx context.Context, username string) (entiti
 // username: The username of the user to check.
 // Returns a boolean indicating if the user exists and an error if the operation fails.
 func (r Repository) CheckUserExists(ctx context.Context, email string, username string) (bool, error) {
-	var user entities.User
-	err := r.db.Read(ctx, &user, "email = ? OR username = ?", email, username)
-	if err != nil {
-		if errors.Is(err, errors.New("record not found")) {
-			// If no record is found, it means the user doesn't exist.
-			// Return false and no error.
-			return false, nil
-		}
-		return false, err
-	}
-	return true, nil
+	return r.users.Exists(ctx, query.Or(query.Eq("email", email), query.Eq("username", username)))
 }
 
 // ReadAll retrieves all user records from the storage.
 // ctx: The context for the operation.
-// model: The user records to retrieve.
+// model: The user records to retrieve. It is replaced by the retrieved records.
 // Returns the user records and an error if the operation fails.
 func (r Repository) ReadAll(ctx context.Context, model []entities.User) ([]entities.User, error) {
-	if err := r.db.ReadAll(ctx, &model); err != nil {
-		return nil, err
+	return r.users.List(ctx, query.Query{})
+}
+
+// ReadMany retrieves the user records with the given ids from the storage.
+// ctx: The context for the operation.
+// ids: The ids of the user records to retrieve.
+// Returns the user records and an error if the operation fails.
+func (r Repository) ReadMany(ctx context.Context, ids []uuid.UUID) ([]entities.User, error) {
+	if len(ids) == 0 {
+		return []entities.User{}, nil
+	}
+	return r.users.List(ctx, query.Where(query.In("id", ids)))
+}
+
+// List retrieves the user records that match the query from the storage.
+// ctx: The context for the operation.
+// q: The condition, the order and the window of the user records to retrieve.
+// Returns the user records and an error if the operation fails.
+func (r Repository) List(ctx context.Context, q query.Query) ([]entities.User, error) {
+	return r.users.List(ctx, q)
+}
+
+// Count counts the user records that match the condition in the storage.
+// ctx: The context for the operation.
+// where: The condition of the user records to count. A nil condition counts every user record.
+// Returns the number of user records and an error if the operation fails.
+func (r Repository) Count(ctx context.Context, where query.Condition) (int64, error) {
+	return r.users.Count(ctx, where)
+}
+
+// Search retrieves the user records whose username or email matches the search, most relevant first.
+// The users are searched with the full-text index of the database, or by a scan of the users if the database has none.
+// The encrypted emails cannot be indexed, so that only the usernames are searched when the emails are encrypted, whatever the database:
+// a search of the words of an email then finds nothing, rather than failing, since the words of a search may be those of usernames.
+// ctx: The context for the operation.
+// search: The text and the window of the search.
+// Returns the found user records with their ranks, the number of found user records regardless of the window,
+// and an error if the operation fails.
+func (r Repository) Search(ctx context.Context, search entities.UserSearch) (entities.UserSearchResult, error) {
+	text := query.Search(search.Q).Window(search.Limit, search.Offset)
+	if database.Encrypted(r.db, entities.User{}, "email") {
+		text = text.In("username")
+	}
+	users, ranks, total, err := r.users.Search(ctx, text)
+	if errors.Is(err, database.ErrSearchNotSupported) {
+		users, ranks, total, err = r.scan(ctx, text)
+	}
+	if err != nil {
+		return entities.UserSearchResult{}, err
+	}
+	result := entities.UserSearchResult{
+		Hits:   make([]entities.UserSearchHit, 0, len(users)),
+		Total:  total,
+		Limit:  search.Limit,
+		Offset: search.Offset,
+	}
+	for i, user := range users {
+		result.Hits = append(result.Hits, entities.UserSearchHit{User: user, Rank: ranks[i]})
+	}
+	return result, nil
+}
+
+// scan searches the users by reading all of them, for the databases without a full-text index.
+// The usernames weigh twice as much as the emails, as in the full-text indexes, and the users of equal ranks are ordered by username.
+// ctx: The context for the operation.
+// search: The terms and the window of the search.
+// Returns the users in the window, their ranks, the number of matching users, and an error if the users cannot be read.
+func (r Repository) scan(ctx context.Context, search query.TextSearch) ([]entities.User, []float64, int64, error) {
+	if len(search.Terms) == 0 {
+		return nil, nil, 0, nil
+	}
+	all, err := r.users.List(ctx, query.Query{})
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	var (
+		users []entities.User
+		ranks []float64
+	)
+	for _, user := range all {
+		if rank, ok := rank(user, search); ok {
+			users = append(users, user)
+			ranks = append(ranks, rank)
+		}
+	}
+	order := make([]int, len(users))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		if ranks[order[i]] != ranks[order[j]] {
+			return ranks[order[i]] > ranks[order[j]]
+		}
+		return users[order[i]].Username < users[order[j]].Username
+	})
+
+	total := int64(len(order))
+	start := min(max(search.Offset, 0), len(order))
+	end := len(order)
+	if search.Limit > 0 {
+		end = min(start+search.Limit, end)
+	}
+	windowUsers := make([]entities.User, 0, end-start)
+	windowRanks := make([]float64, 0, end-start)
+	for _, i := range order[start:end] {
+		windowUsers = append(windowUsers, users[i])
+		windowRanks = append(windowRanks, ranks[i])
+	}
+	return windowUsers, windowRanks, total, nil
+}
+
+// rank ranks a user for the terms of a search: every term must start a word of the username or the email,
+// and counts 2 if it starts a word of the username and 1 otherwise. The email is left out if the search names the columns without it.
+// user: The user to rank.
+// search: The terms and the columns of the search.
+// Returns the rank of the user, and false if a term matches neither its username nor its email.
+func rank(user entities.User, search query.TextSearch) (float64, bool) {
+	var username, email []string
+	if searched(search, "username") {
+		username = query.Terms(user.Username)
+	}
+	if searched(search, "email") {
+		email = query.Terms(user.Email)
+	}
+	var rank float64
+	for _, term := range search.Terms {
+		switch {
+		case prefixes(username, term):
+			rank += 2
+		case prefixes(email, term):
+			rank++
+		default:
+			return 0, false
+		}
+	}
+	return rank, true
+}
+
+// searched reports whether a search matches the terms in a column.
+func searched(search query.TextSearch, column string) bool {
+	if len(search.Columns) == 0 {
+		return true
+	}
+	for _, c := range search.Columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixes reports whether a term starts one of the words.
+func prefixes(words []string, term string) bool {
+	for _, word := range words {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
 	}
-	return model, nil
+	return false
 }
 
 // NewUserRepository creates a new user repository with the provided database.
@@ -120,6 +259,7 @@ func (r Repository) ReadAll(ctx context.Context, model []entities.User) ([]entit
 // Returns a UserRepository object.
 func NewUserRepository(db database.Database) storage.UserRepository {
 	return &Repository{
-		db: db,
+		db:    db,
+		users: database.NewRepository[entities.User, uuid.UUID](db),
 	}
 }
@@ -0,0 +1,177 @@
+// Package webhook provides the functionality to interact with webhook endpoint and delivery data in the storage.
+package webhook
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/internal/storage"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"strings"
+	"time"
+)
+
+// EndpointRepository struct represents a webhook endpoint repository that provides methods for webhook endpoint operations.
+// The CRUD operations are the ones of the generic repository. The event types of an endpoint are stored comma separated.
+type EndpointRepository struct {
+	endpoints database.Repository[entities.WebhookEndpoint, uuid.UUID]
+}
+
+// Create adds a new endpoint to the storage.
+// ctx: The context for the operation.
+// endpoint: The endpoint to add.
+// Returns the stored endpoint and an error if the operation fails.
+func (r EndpointRepository) Create(ctx context.Context, endpoint entities.WebhookEndpoint) (entities.WebhookEndpoint, error) {
+	endpoint.EventTypes = strings.Join(endpoint.Events, ",")
+	return r.endpoints.Insert(ctx, endpoint)
+}
+
+// Read retrieves an endpoint from the storage.
+// ctx: The context for the operation.
+// id: The id of the endpoint to retrieve.
+// Returns the endpoint and an error if the operation fails.
+func (r EndpointRepository) Read(ctx context.Context, id uuid.UUID) (entities.WebhookEndpoint, error) {
+	endpoint, err := r.endpoints.Get(ctx, id)
+	if err != nil {
+		return entities.WebhookEndpoint{}, err
+	}
+	return withEvents(endpoint), nil
+}
+
+// ReadAll retrieves the endpoints from the storage, oldest first.
+// ctx: The context for the operation.
+// Returns the endpoints and an error if the operation fails.
+func (r EndpointRepository) ReadAll(ctx context.Context) ([]entities.WebhookEndpoint, error) {
+	endpoints, err := r.endpoints.List(ctx, query.Query{}.OrderedBy(query.Asc("created_at"), query.Asc("id")))
+	if err != nil {
+		return nil, err
+	}
+	for i := range endpoints {
+		endpoints[i] = withEvents(endpoints[i])
+	}
+	return endpoints, nil
+}
+
+// Save modifies an endpoint, if it was not modified since it was read.
+// ctx: The context for the operation.
+// endpoint: The endpoint to modify.
+// Returns the stored endpoint with its new version, and database.ErrStale if the endpoint was modified in the meantime.
+func (r EndpointRepository) Save(ctx context.Context, endpoint entities.WebhookEndpoint) (entities.WebhookEndpoint, error) {
+	endpoint.EventTypes = strings.Join(endpoint.Events, ",")
+	return r.endpoints.Save(ctx, endpoint)
+}
+
+// Delete removes an endpoint from the storage.
+// ctx: The context for the operation.
+// id: The id of the endpoint to remove.
+// Returns an error if the operation fails.
+func (r EndpointRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.endpoints.Delete(ctx, id)
+}
+
+// withEvents splits the stored event types of an endpoint.
+func withEvents(endpoint entities.WebhookEndpoint) entities.WebhookEndpoint {
+	endpoint.Events = []string{}
+	if endpoint.EventTypes != "" {
+		endpoint.Events = strings.Split(endpoint.EventTypes, ",")
+	}
+	return endpoint
+}
+
+// NewEndpointRepository creates a new webhook endpoint repository with the provided database.
+// db: The database for the webhook endpoint repository.
+// Returns a WebhookEndpointRepository object.
+func NewEndpointRepository(db database.Database) storage.WebhookEndpointRepository {
+	return &EndpointRepository{
+		endpoints: database.NewRepository[entities.WebhookEndpoint, uuid.UUID](db),
+	}
+}
+
+// DeliveryRepository struct represents a webhook delivery repository that provides methods for webhook delivery operations.
+// The CRUD operations are the ones of the generic repository, which it adds the lookups and the removals of the deliveries to.
+type DeliveryRepository struct {
+	db         database.Database
+	deliveries database.Repository[entities.WebhookDelivery, uuid.UUID]
+}
+
+// Create adds a new delivery to the storage.
+// ctx: The context for the operation.
+// delivery: The delivery to add.
+// Returns a *database.ConflictError if the delivery already exists, and an error if the operation fails.
+func (r DeliveryRepository) Create(ctx context.Context, delivery entities.WebhookDelivery) error {
+	return r.deliveries.Create(ctx, delivery)
+}
+
+// Read retrieves a delivery from the storage.
+// ctx: The context for the operation.
+// id: The id of the delivery to retrieve.
+// Returns the delivery and an error if the operation fails.
+func (r DeliveryRepository) Read(ctx context.Context, id uuid.UUID) (entities.WebhookDelivery, error) {
+	return r.deliveries.Get(ctx, id)
+}
+
+// ReadByEndpoint retrieves the deliveries of an endpoint matching the filter, most recent first.
+// ctx: The context for the operation.
+// endpointID: The id of the endpoint.
+// filter: The status and window of the deliveries.
+// Returns the deliveries and an error if the operation fails.
+func (r DeliveryRepository) ReadByEndpoint(ctx context.Context, endpointID uuid.UUID, filter entities.WebhookDeliveryFilter) ([]entities.WebhookDelivery, error) {
+	where := query.Eq("endpoint_id", endpointID)
+	if filter.Status != "" {
+		where = query.And(where, query.Eq("status", filter.Status))
+	}
+	deliveries, err := r.deliveries.List(ctx, query.Where(where).OrderedBy(query.Desc("created_at"), query.Desc("id")).Window(filter.Limit, filter.Offset))
+	if err != nil {
+		return nil, err
+	}
+	if deliveries == nil {
+		deliveries = []entities.WebhookDelivery{}
+	}
+	return deliveries, nil
+}
+
+// Due retrieves the pending deliveries that may be sent at the given time, the longest waiting first.
+// ctx: The context for the operation.
+// now: The time the deliveries are sent at.
+// limit: The maximum number of deliveries to retrieve.
+// Returns the deliveries and an error if the operation fails.
+func (r DeliveryRepository) Due(ctx context.Context, now time.Time, limit int) ([]entities.WebhookDelivery, error) {
+	where := query.And(query.Eq("status", entities.WebhookDeliveryPending), query.Lte("next_attempt_at", now.UTC()))
+	return r.deliveries.List(ctx, query.Where(where).OrderedBy(query.Asc("next_attempt_at"), query.Asc("id")).Window(limit, 0))
+}
+
+// Save modifies a delivery, if it was not modified since it was read.
+// ctx: The context for the operation.
+// delivery: The delivery to modify.
+// Returns the stored delivery with its new version, and database.ErrStale if the delivery was modified in the meantime.
+func (r DeliveryRepository) Save(ctx context.Context, delivery entities.WebhookDelivery) (entities.WebhookDelivery, error) {
+	return r.deliveries.Save(ctx, delivery)
+}
+
+// DeleteByEndpoint removes the deliveries of an endpoint.
+// ctx: The context for the operation.
+// endpointID: The id of the endpoint.
+// Returns the number of removed deliveries and an error if the operation fails.
+func (r DeliveryRepository) DeleteByEndpoint(ctx context.Context, endpointID uuid.UUID) (int64, error) {
+	return r.db.DeleteWhere(ctx, entities.WebhookDelivery{}, query.Eq("endpoint_id", endpointID))
+}
+
+// DeleteFinishedBefore removes the deliveries that succeeded or are dead and were last modified before the given time.
+// ctx: The context for the operation.
+// before: The time before which the finished deliveries are removed.
+// Returns the number of removed deliveries and an error if the operation fails.
+func (r DeliveryRepository) DeleteFinishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	finished := query.In("status", []string{entities.WebhookDeliverySucceeded, entities.WebhookDeliveryDead})
+	return r.db.DeleteWhere(ctx, entities.WebhookDelivery{}, query.And(finished, query.Lt("updated_at", before.UTC())))
+}
+
+// NewDeliveryRepository creates a new webhook delivery repository with the provided database.
+// db: The database for the webhook delivery repository.
+// Returns a WebhookDeliveryRepository object.
+func NewDeliveryRepository(db database.Database) storage.WebhookDeliveryRepository {
+	return &DeliveryRepository{
+		db:         db,
+		deliveries: database.NewRepository[entities.WebhookDelivery, uuid.UUID](db),
+	}
+}
@@ -0,0 +1,49 @@
+// Package attempt provides the functionality shared by the workers that hand over stored work, such as the relays of the outbox
+// and the senders of the webhooks, which attempt every item until it succeeds or fails too many times.
+// A worker claims an item before it attempts it, by pushing back the time it may be attempted by a lease,
+// so that the workers of several instances do not attempt it at once unless the lease expires.
+// An item that fails is attempted again after a backoff, and the error of the attempt is stored with it, truncated.
+package attempt
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+// Lease returns the time until which an item is left to the worker that claims it.
+// now: The time the item is claimed.
+// lease: The duration of the lease, which must exceed the duration of an attempt.
+// Returns the time the item may be attempted again by any worker.
+func Lease(now time.Time, lease time.Duration) time.Time {
+	return now.Add(lease)
+}
+
+// Backoff returns the time to wait before the next attempt of an item, which doubles with every failed attempt.
+// attempts: The number of failed attempts, from 1.
+// base: The time to wait after the first failed attempt.
+// limit: The longest time to wait.
+// Returns the time to wait.
+func Backoff(attempts int, base, limit time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		return limit
+	}
+	return delay
+}
+
+// Truncate shortens a text to at most the given number of bytes, without splitting a character.
+// text: The text to shorten, such as the error of an attempt.
+// length: The maximum number of bytes.
+// Returns the text, or its beginning if it is longer.
+func Truncate(text string, length int) string {
+	if len(text) <= length {
+		return text
+	}
+	for length > 0 && !utf8.RuneStart(text[length]) {
+		length--
+	}
+	return text[:length]
+}
@@ -0,0 +1,28 @@
+package attempt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, Backoff(1, time.Second, 5*time.Second), "the first retry waits for the base delay")
+	assert.Equal(t, 2*time.Second, Backoff(2, time.Second, 5*time.Second), "the delay doubles with every failure")
+	assert.Equal(t, 4*time.Second, Backoff(3, time.Second, 5*time.Second))
+	assert.Equal(t, 5*time.Second, Backoff(10, time.Second, 5*time.Second), "the delay is capped")
+	assert.Equal(t, 5*time.Second, Backoff(1, 10*time.Second, 5*time.Second), "the base delay is capped too")
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "short", Truncate("short", 10))
+	assert.Equal(t, "trun", Truncate("truncated", 4))
+	assert.Equal(t, "h", Truncate("hé", 2), "a character is not split")
+	assert.Equal(t, "hé", Truncate("hé", 3))
+}
+
+func TestLease(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	assert.Equal(t, now.Add(30*time.Second), Lease(now, 30*time.Second))
+}
@@ -0,0 +1,50 @@
+// Package cache provides the caches of the application: an in-process LRU cache and a client of a Redis server.
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"time"
+)
+
+// Cache is an interface that defines the methods of a key-value cache whose entries expire.
+// The values are opaque bytes, so that the callers choose their encoding. An empty value is a valid value.
+type Cache interface {
+	// Get retrieves the value of a key.
+	// ctx: The context for the operation.
+	// key: The key of the value.
+	// Returns the value, a boolean indicating if the key has an unexpired value and an error if the operation fails.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Set stores the value of a key until it expires.
+	// ctx: The context for the operation.
+	// key: The key of the value.
+	// value: The value to store.
+	// ttl: How long the value is kept.
+	// Returns an error if the operation fails.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Delete removes the values of the keys. The keys without a value are ignored.
+	// ctx: The context for the operation.
+	// keys: The keys of the values to remove.
+	// Returns an error if the operation fails.
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// New creates the cache of the provided configuration.
+// cfg: The cache configuration.
+// Returns the cache, or nil if the caching is disabled, and an error if the cache type is not supported.
+func New(cfg config.CacheConfig) (Cache, error) {
+	switch cfg.Type {
+	case "", "none":
+		return nil, nil
+	case "memory":
+		return NewLRU(cfg.Size), nil
+	case "redis":
+		timeout := time.Duration(cfg.Redis.TimeoutSeconds) * time.Second
+		return NewRedis(cfg.Redis.Address, cfg.Redis.Password, cfg.Redis.DB, timeout), nil
+	default:
+		return nil, fmt.Errorf("cache type %q not supported", cfg.Type)
+	}
+}
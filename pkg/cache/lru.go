@@ -0,0 +1,114 @@
+// Package cache provides an in-process cache that evicts the least recently used entries.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultSize is the number of entries of an LRU cache whose size is not configured.
+const defaultSize = 10000
+
+// entry struct represents a value of the LRU cache and its expiry.
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// LRU struct represents an in-process cache of a bounded number of entries, which evicts the least recently used entry
+// when it is full. The expired entries are removed when they are read or evicted.
+type LRU struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+	now     func() time.Time
+}
+
+// NewLRU creates a new empty LRU cache.
+// size: The maximum number of entries. Zero or a negative value uses a default of 10000 entries.
+// Returns a *LRU object.
+func NewLRU(size int) *LRU {
+	if size <= 0 {
+		size = defaultSize
+	}
+	return &LRU{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// Get retrieves the value of a key, which becomes the most recently used.
+// ctx: The context for the operation.
+// key: The key of the value.
+// Returns a copy of the value and a boolean indicating if the key has an unexpired value. The error is always nil.
+func (c *LRU) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := element.Value.(*entry)
+	if !c.now().Before(e.expires) {
+		c.remove(element)
+		return nil, false, nil
+	}
+	c.order.MoveToFront(element)
+	return append([]byte{}, e.value...), true, nil
+}
+
+// Set stores a copy of the value of a key, and evicts the least recently used entry if the cache is full.
+// ctx: The context for the operation.
+// key: The key of the value.
+// value: The value to store.
+// ttl: How long the value is kept.
+// Returns nil.
+func (c *LRU) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := &entry{key: key, value: append([]byte{}, value...), expires: c.now().Add(ttl)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = e
+		c.order.MoveToFront(element)
+		return nil
+	}
+	c.entries[key] = c.order.PushFront(e)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+// Delete removes the values of the keys.
+// ctx: The context for the operation.
+// keys: The keys of the values to remove.
+// Returns nil.
+func (c *LRU) Delete(_ context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if element, ok := c.entries[key]; ok {
+			c.remove(element)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of entries of the cache, including the expired ones that have not been removed yet.
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// remove removes an entry. The caller must hold the lock.
+func (c *LRU) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*entry).key)
+}
@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUEvictsTheLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU(2)
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, c.Set(ctx, "b", []byte("2"), time.Minute))
+	_, found, _ := c.Get(ctx, "a")
+	require.True(t, found)
+
+	require.NoError(t, c.Set(ctx, "c", []byte("3"), time.Minute))
+	_, found, _ = c.Get(ctx, "b")
+	assert.False(t, found, "The least recently used entry is evicted")
+	value, found, _ := c.Get(ctx, "a")
+	assert.True(t, found)
+	assert.Equal(t, []byte("1"), value)
+	assert.Equal(t, 2, c.Len())
+
+	value[0] = 'x'
+	value, _, _ = c.Get(ctx, "a")
+	assert.Equal(t, []byte("1"), value, "The cached values are copies")
+
+	require.NoError(t, c.Delete(ctx, "a", "missing"))
+	_, found, _ = c.Get(ctx, "a")
+	assert.False(t, found)
+}
+
+func TestLRUExpiresEntries(t *testing.T) {
+	c := NewLRU(0)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "empty", nil, time.Second))
+	value, found, _ := c.Get(ctx, "empty")
+	assert.True(t, found, "An empty value is a value")
+	assert.Empty(t, value)
+
+	now = now.Add(time.Second)
+	_, found, _ = c.Get(ctx, "empty")
+	assert.False(t, found)
+	assert.Equal(t, 0, c.Len(), "An expired entry is removed when it is read")
+}
@@ -0,0 +1,272 @@
+// Package cache provides a cache stored in a Redis server, which the instances of the application share.
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// idleConns is the number of idle connections the Redis client keeps.
+const idleConns = 8
+
+// defaultTimeout is the time a Redis command may take when the client has no timeout.
+const defaultTimeout = 5 * time.Second
+
+// ErrClosed is returned by the commands of a closed Redis client.
+var ErrClosed = errors.New("cache closed")
+
+// RedisError is the error the Redis server replies to a command with, e.g. "WRONGTYPE ...".
+type RedisError string
+
+// Error returns the message of the server.
+func (e RedisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// Redis struct represents a client of a Redis server, or of any server that speaks its protocol, that keeps a pool
+// of connections. It only sends the few commands the Cache interface needs.
+type Redis struct {
+	address  string
+	password string
+	db       int
+	timeout  time.Duration
+
+	mu     sync.Mutex
+	idle   []*redisConn
+	closed bool
+}
+
+// redisConn struct represents a connection to the Redis server.
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedis creates a new Redis client. The connections are opened when the commands need them.
+// address: The host and port of the server, e.g. "localhost:6379".
+// password: The password of the server. The connections are not authenticated if it is empty.
+// db: The number of the database of the server.
+// timeout: The time a command may take, including the connection. Zero uses a default of five seconds.
+// Returns a *Redis object.
+func NewRedis(address, password string, db int, timeout time.Duration) *Redis {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Redis{address: address, password: password, db: db, timeout: timeout}
+}
+
+// Get retrieves the value of a key.
+// ctx: The context for the operation.
+// key: The key of the value.
+// Returns the value, a boolean indicating if the key has a value and an error if the operation fails.
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := r.do(ctx, "GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected reply %T to GET", reply)
+	}
+	return value, true, nil
+}
+
+// Set stores the value of a key until it expires.
+// ctx: The context for the operation.
+// key: The key of the value.
+// value: The value to store.
+// ttl: How long the value is kept, rounded down to the millisecond and at least one millisecond.
+// Returns an error if the operation fails.
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	milliseconds := ttl.Milliseconds()
+	if milliseconds < 1 {
+		milliseconds = 1
+	}
+	_, err := r.do(ctx, "SET", key, string(value), "PX", strconv.FormatInt(milliseconds, 10))
+	return err
+}
+
+// Delete removes the values of the keys.
+// ctx: The context for the operation.
+// keys: The keys of the values to remove.
+// Returns an error if the operation fails.
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := r.do(ctx, append([]string{"DEL"}, keys...)...)
+	return err
+}
+
+// Ping checks that the Redis server is reachable.
+// ctx: The context for the operation.
+// Returns an error if the server cannot be reached.
+func (r *Redis) Ping(ctx context.Context) error {
+	_, err := r.do(ctx, "PING")
+	return err
+}
+
+// Close closes the idle connections, and the busy ones once their command completes.
+// Returns nil.
+func (r *Redis) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	for _, c := range r.idle {
+		_ = c.conn.Close()
+	}
+	r.idle = nil
+	return nil
+}
+
+// do sends a command on a connection of the pool and reads its reply.
+// The connection is closed instead of returned to the pool if the command fails on it, since its state is unknown.
+func (r *Redis) do(ctx context.Context, args ...string) (interface{}, error) {
+	c, err := r.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.roundTrip(r.deadline(ctx), args...)
+	var redisErr RedisError
+	if err != nil && !errors.As(err, &redisErr) {
+		_ = c.conn.Close()
+		return nil, err
+	}
+	r.put(c)
+	return reply, err
+}
+
+// get returns an idle connection, or opens a new one.
+func (r *Redis) get(ctx context.Context) (*redisConn, error) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if n := len(r.idle); n > 0 {
+		c := r.idle[n-1]
+		r.idle = r.idle[:n-1]
+		r.mu.Unlock()
+		return c, nil
+	}
+	r.mu.Unlock()
+
+	dialer := net.Dialer{Deadline: r.deadline(ctx)}
+	conn, err := dialer.DialContext(ctx, "tcp", r.address)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{conn: conn, reader: bufio.NewReader(conn)}
+	if r.password != "" {
+		if _, err := c.roundTrip(r.deadline(ctx), "AUTH", r.password); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	if r.db != 0 {
+		if _, err := c.roundTrip(r.deadline(ctx), "SELECT", strconv.Itoa(r.db)); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// put returns a connection to the pool, or closes it if the pool is full or the client is closed.
+func (r *Redis) put(c *redisConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || len(r.idle) >= idleConns {
+		_ = c.conn.Close()
+		return
+	}
+	r.idle = append(r.idle, c)
+}
+
+// deadline returns the deadline of a command, which is the one of the context if it is earlier than the timeout.
+func (r *Redis) deadline(ctx context.Context) time.Time {
+	deadline := time.Now().Add(r.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		return d
+	}
+	return deadline
+}
+
+// roundTrip writes a command as an array of bulk strings and reads its reply.
+func (c *redisConn) roundTrip(deadline time.Time, args ...string) (interface{}, error) {
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+// read reads a reply: a simple string, an integer, a bulk string, or an array of replies.
+// A null bulk string or array is returned as nil, and an error reply as a RedisError.
+func (c *redisConn) read() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, text := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return text, nil
+	case '-':
+		return nil, RedisError(text)
+	case ':':
+		return strconv.ParseInt(text, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(text)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		value := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, value); err != nil {
+			return nil, err
+		}
+		return value[:n], nil
+	case '*':
+		n, err := strconv.Atoi(text)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		replies := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			reply, err := c.read()
+			if err != nil {
+				return nil, err
+			}
+			replies = append(replies, reply)
+		}
+		return replies, nil
+	default:
+		return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+	}
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+	c := NewRedis(server.Addr(), "secret", 2, time.Second)
+	t.Cleanup(func() { _ = c.Close() })
+	ctx := context.Background()
+
+	require.NoError(t, c.Ping(ctx))
+	_, found, err := c.Get(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	require.NoError(t, c.Set(ctx, "a", []byte("1\r\n2"), time.Minute))
+	require.NoError(t, c.Set(ctx, "empty", nil, time.Minute))
+	value, found, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []byte("1\r\n2"), value, "The values are binary safe")
+	value, found, err = c.Get(ctx, "empty")
+	require.NoError(t, err)
+	assert.True(t, found, "An empty value is a value")
+	assert.Empty(t, value)
+	server.Select(2)
+	assert.True(t, server.Exists("a"), "The configured database is selected")
+
+	server.FastForward(time.Minute)
+	_, found, err = c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, found, "The values expire")
+
+	require.NoError(t, c.Set(ctx, "b", []byte("2"), time.Minute))
+	require.NoError(t, c.Delete(ctx, "b", "missing"))
+	_, found, err = c.Get(ctx, "b")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	_, err = server.Lpush("other", "x")
+	require.NoError(t, err)
+	_, _, err = c.Get(ctx, "other")
+	var redisErr RedisError
+	assert.ErrorAs(t, err, &redisErr, "The error replies are returned")
+	require.NoError(t, c.Ping(ctx), "The connection is still usable after an error reply")
+
+	require.NoError(t, c.Close())
+	_, _, err = c.Get(ctx, "a")
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestRedisRejectsWrongPassword(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+	c := NewRedis(server.Addr(), "wrong", 0, time.Second)
+	t.Cleanup(func() { _ = c.Close() })
+	assert.Error(t, c.Ping(context.Background()))
+}
+
+func TestNew(t *testing.T) {
+	c, err := New(config.CacheConfig{Type: "none"})
+	require.NoError(t, err)
+	assert.Nil(t, c)
+	c, err = New(config.CacheConfig{Type: "memory", Size: 5})
+	require.NoError(t, err)
+	assert.IsType(t, &LRU{}, c)
+	c, err = New(config.CacheConfig{Type: "redis", Redis: config.RedisConfig{Address: "localhost:6379"}})
+	require.NoError(t, err)
+	assert.IsType(t, &Redis{}, c)
+	_, err = New(config.CacheConfig{Type: "memcached"})
+	assert.Error(t, err)
+}
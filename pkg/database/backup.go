@@ -0,0 +1,45 @@
+// Package database provides the functionality to back up a database while it is in use.
+package database
+
+import (
+	"errors"
+	"golang.org/x/net/context"
+	"io"
+)
+
+// ErrBackupNotSupported is returned when the database cannot write a backup of itself.
+var ErrBackupNotSupported = errors.New("database does not support online backups")
+
+// Backuper is an interface implemented by the databases that can write a consistent copy of themselves while they are in use.
+type Backuper interface {
+	// Backup writes a consistent copy of the database.
+	// ctx: The context for the operation.
+	// w: The writer to write the copy to.
+	// Returns the number of written bytes and an error if the operation fails.
+	Backup(ctx context.Context, w io.Writer) (int64, error)
+}
+
+// Wrapper is an interface implemented by the database decorators, which gives access to the decorated database.
+type Wrapper interface {
+	// Unwrap returns the decorated database.
+	Unwrap() Database
+}
+
+// Backup writes a consistent copy of the database, or of the database it decorates.
+// ctx: The context for the operation.
+// db: The database to back up.
+// w: The writer to write the copy to.
+// Returns the number of written bytes, and ErrBackupNotSupported if neither the database nor the databases it decorates support backups.
+func Backup(ctx context.Context, db Database, w io.Writer) (int64, error) {
+	for db != nil {
+		if backuper, ok := db.(Backuper); ok {
+			return backuper.Backup(ctx, w)
+		}
+		wrapper, ok := db.(Wrapper)
+		if !ok {
+			break
+		}
+		db = wrapper.Unwrap()
+	}
+	return 0, ErrBackupNotSupported
+}
@@ -0,0 +1,545 @@
+// Package bolt provides the functionality to interact with an embedded bbolt key-value database.
+// It needs no cgo, so the application can be cross-compiled into a single binary.
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/dberr"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/records"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	bbolt "go.etcd.io/bbolt"
+	"gorm.io/gorm/schema"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// rowsBucket is the name of the bucket of a table that holds its records, keyed by primary key.
+// The other buckets of a table are its unique indexes, which map the indexed values to the primary key.
+var rowsBucket = []byte("rows")
+
+// Database struct represents a bbolt database.
+// Every table is a bucket, derived from the GORM schema of the entities like the tables of the SQL databases,
+// and every write runs in a single transaction together with the updates of the unique indexes,
+// or in the transaction carried by its context.
+type Database struct {
+	db      *bbolt.DB
+	schemas sync.Map
+}
+
+// NewDatabase opens the bbolt database file of the provided configuration, and creates it if it does not exist.
+// cfg: The configuration object that contains the bbolt database settings.
+// Returns a Database object if the database is successfully opened.
+// Returns an error if the file cannot be opened, or is still locked by another process after the timeout.
+func NewDatabase(cfg *config.Config) (*Database, error) {
+	db, err := bbolt.Open(cfg.DB.Bolt.Path, 0o600, &bbolt.Options{Timeout: time.Duration(cfg.DB.Bolt.TimeoutSeconds) * time.Second})
+	if errors.Is(err, bbolt.ErrTimeout) {
+		return nil, dberr.Timeout(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Database{db: db}, nil
+}
+
+// Create adds a new record to the bbolt database.
+// The generated primary key and timestamps are written back to the entity if it is a pointer.
+// ctx: The context for the operation.
+// entity: The record to add.
+// Returns database.ErrConflict if the record violates a unique constraint, or an error if the operation fails.
+func (d *Database) Create(ctx context.Context, entity interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return dberr.Context(err)
+	}
+	s, err := records.Parse(entity, &d.schemas)
+	if err != nil {
+		return err
+	}
+	return d.update(ctx, func(tx *bbolt.Tx, j *journal) error {
+		t, err := createTable(tx, s, j)
+		if err != nil {
+			return err
+		}
+		return t.insert(ctx, records.Record(entity))
+	})
+}
+
+// Read retrieves the matching record with the lowest primary key from the bbolt database.
+// A condition on the primary key or a unique column is answered from the index instead of scanning the table.
+// ctx: The context for the operation.
+// entity: The record to retrieve.
+// where: The condition to match. A nil condition matches every record.
+// Returns database.ErrNotFound if no record matches, or an error if the operation fails.
+func (d *Database) Read(ctx context.Context, entity interface{}, where query.Condition) error {
+	if err := ctx.Err(); err != nil {
+		return dberr.Context(err)
+	}
+	s, err := records.Parse(entity, &d.schemas)
+	if err != nil {
+		return err
+	}
+	cond, err := records.Compile(s, where)
+	if err != nil {
+		return err
+	}
+	return d.view(ctx, func(tx *bbolt.Tx) error {
+		rows, err := openTable(tx, s).match(ctx, cond)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return dberr.ErrNotFound
+		}
+		first := rows[0]
+		for _, row := range rows[1:] {
+			if records.ComparePrimaryKeys(ctx, s, row, first) < 0 {
+				first = row
+			}
+		}
+		return records.Copy(ctx, s, records.Record(entity), first)
+	})
+}
+
+// Update modifies a record in the bbolt database, or adds it if no record has its primary key.
+// ctx: The context for the operation.
+// entity: The record to modify. The version of a versioned record must be the stored one, and is incremented.
+// Returns database.ErrConflict if the record violates a unique constraint or is based on an outdated version, or an error if the operation fails.
+func (d *Database) Update(ctx context.Context, entity interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return dberr.Context(err)
+	}
+	s, err := records.Parse(entity, &d.schemas)
+	if err != nil {
+		return err
+	}
+	return d.update(ctx, func(tx *bbolt.Tx, j *journal) error {
+		t, err := createTable(tx, s, j)
+		if err != nil {
+			return err
+		}
+		value := records.Record(entity)
+		key, err := t.primaryKey(ctx, value)
+		if err != nil {
+			return err
+		}
+		data := t.rows.Get(key)
+		if data == nil {
+			return t.insert(ctx, value)
+		}
+		old := records.New(s)
+		if err := t.decode(ctx, data, old); err != nil {
+			return err
+		}
+		if err := records.CheckVersion(ctx, s, value, old); err != nil {
+			return err
+		}
+		if err := records.Touch(ctx, s, value, false); err != nil {
+			return err
+		}
+		if err := t.unindex(ctx, old); err != nil {
+			return err
+		}
+		if err := t.index(ctx, value, key); err != nil {
+			return err
+		}
+		if err := records.NextVersion(ctx, s, value); err != nil {
+			return err
+		}
+		return t.put(ctx, key, value)
+	})
+}
+
+// Delete removes a record from the bbolt database.
+// ctx: The context for the operation.
+// entity: The record to remove.
+// id: The id of the record to remove.
+// Returns an error if the operation fails.
+func (d *Database) Delete(ctx context.Context, entity interface{}, id interface{}) error {
+	_, err := d.DeleteWhere(ctx, entity, query.Eq("id", id))
+	return err
+}
+
+// ReadAll retrieves all records from the bbolt database, in primary key order.
+// ctx: The context for the operation.
+// entity: The records to retrieve.
+// Returns an error if the operation fails.
+func (d *Database) ReadAll(ctx context.Context, entity interface{}) error {
+	return d.Find(ctx, entity, query.Query{})
+}
+
+// Find retrieves the records the query selects from the bbolt database.
+// ctx: The context for the operation.
+// entity: The records to retrieve.
+// q: The condition, ordering and window of the records. Without an ordering, the records are in primary key order.
+// Returns an error if the operation fails.
+func (d *Database) Find(ctx context.Context, entity interface{}, q query.Query) error {
+	if err := ctx.Err(); err != nil {
+		return dberr.Context(err)
+	}
+	if err := records.CheckSlice(entity); err != nil {
+		return err
+	}
+	s, err := records.Parse(entity, &d.schemas)
+	if err != nil {
+		return err
+	}
+	where, err := q.Condition()
+	if err != nil {
+		return err
+	}
+	cond, err := records.Compile(s, where)
+	if err != nil {
+		return err
+	}
+	return d.view(ctx, func(tx *bbolt.Tx) error {
+		rows, err := openTable(tx, s).match(ctx, cond)
+		if err != nil {
+			return err
+		}
+		if err := records.Sort(ctx, s, rows, q.OrderBy); err != nil {
+			return err
+		}
+		return records.Assign(ctx, s, entity, records.Page(rows, q.Limit, q.Offset))
+	})
+}
+
+// DeleteWhere removes the records matching the condition from the bbolt database.
+// ctx: The context for the operation.
+// entity: The type of the records to remove.
+// where: The condition to match. A nil condition matches every record.
+// Returns the number of removed records and an error if the operation fails.
+func (d *Database) DeleteWhere(ctx context.Context, entity interface{}, where query.Condition) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, dberr.Context(err)
+	}
+	s, err := records.Parse(entity, &d.schemas)
+	if err != nil {
+		return 0, err
+	}
+	cond, err := records.Compile(s, where)
+	if err != nil {
+		return 0, err
+	}
+	var removed int64
+	err = d.update(ctx, func(tx *bbolt.Tx, j *journal) error {
+		t := openTable(tx, s)
+		t.journal = j
+		rows, err := t.match(ctx, cond)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			key, err := t.primaryKey(ctx, row)
+			if err != nil {
+				return err
+			}
+			if err := t.unindex(ctx, row); err != nil {
+				return err
+			}
+			if err := t.journal.delete(t.rows, key); err != nil {
+				return err
+			}
+		}
+		removed = int64(len(rows))
+		return nil
+	})
+	return removed, err
+}
+
+// Backup writes a consistent copy of the bbolt database file while the database stays in use.
+// ctx: The context for the operation.
+// w: The writer to write the copy to.
+// Returns the number of written bytes and an error if the operation fails.
+func (d *Database) Backup(ctx context.Context, w io.Writer) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, dberr.Context(err)
+	}
+	var written int64
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		written, err = tx.WriteTo(w)
+		return err
+	})
+	return written, err
+}
+
+// Stats reports the usage of the connection pool of the bbolt database, which has none since it is a single file opened once.
+// Returns zero statistics.
+func (d *Database) Stats() sql.DBStats {
+	return sql.DBStats{}
+}
+
+// Close closes the bbolt database and releases the lock of its file.
+// Returns an error if the operation fails.
+func (d *Database) Close() error {
+	return d.db.Close()
+}
+
+// table struct represents the buckets of a table in a transaction.
+// The buckets are nil in a read-only transaction if the table was never written to.
+type table struct {
+	schema      *schema.Schema
+	rows        *bbolt.Bucket
+	bucket      *bbolt.Bucket
+	constraints []records.Constraint
+	journal     *journal
+}
+
+// createTable returns the table of the schema in a writable transaction, and creates its buckets on first use.
+// The writes to the table are journaled in j, if it is not nil.
+func createTable(tx *bbolt.Tx, s *schema.Schema, j *journal) (*table, error) {
+	bucket, err := tx.CreateBucketIfNotExists([]byte(s.Table))
+	if err != nil {
+		return nil, err
+	}
+	rows, err := bucket.CreateBucketIfNotExists(rowsBucket)
+	if err != nil {
+		return nil, err
+	}
+	t := &table{schema: s, rows: rows, bucket: bucket, constraints: records.UniqueConstraints(s), journal: j}
+	for _, constraint := range t.indexes() {
+		if _, err := bucket.CreateBucketIfNotExists([]byte(constraint.Name)); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// openTable returns the table of the schema in a transaction, without creating it.
+func openTable(tx *bbolt.Tx, s *schema.Schema) *table {
+	t := &table{schema: s, constraints: records.UniqueConstraints(s)}
+	if t.bucket = tx.Bucket([]byte(s.Table)); t.bucket != nil {
+		t.rows = t.bucket.Bucket(rowsBucket)
+	}
+	return t
+}
+
+// indexes returns the unique constraints that are stored as indexes, i.e. all of them but the primary key.
+func (t *table) indexes() []records.Constraint {
+	indexes := make([]records.Constraint, 0, len(t.constraints))
+	for _, constraint := range t.constraints {
+		if constraint.Name != "primary" {
+			indexes = append(indexes, constraint)
+		}
+	}
+	return indexes
+}
+
+// insert adds a record to the table, after generating its auto-increment primary key and its timestamps.
+// ctx: The context for the operation.
+// value: The record to add.
+// Returns database.ErrConflict if the record violates a unique constraint, or an error if the operation fails.
+func (t *table) insert(ctx context.Context, value reflect.Value) error {
+	if err := records.Touch(ctx, t.schema, value, true); err != nil {
+		return err
+	}
+	if pk := t.schema.PrioritizedPrimaryField; pk != nil && pk.AutoIncrement {
+		current, zero := pk.ValueOf(ctx, value)
+		if zero {
+			id, err := t.journal.nextSequence(t.bucket)
+			if err != nil {
+				return err
+			}
+			if err := pk.Set(ctx, value, id); err != nil {
+				return err
+			}
+		} else if n, ok := records.Normalize(current).(int64); ok && n > 0 && uint64(n) > t.bucket.Sequence() {
+			if err := t.journal.setSequence(t.bucket, uint64(n)); err != nil {
+				return err
+			}
+		}
+	}
+
+	key, err := t.primaryKey(ctx, value)
+	if err != nil {
+		return err
+	}
+	if t.rows.Get(key) != nil {
+		return t.constraints[0].Conflict()
+	}
+	if err := t.index(ctx, value, key); err != nil {
+		return err
+	}
+	return t.put(ctx, key, value)
+}
+
+// primaryKey returns the encoded primary key of a record.
+func (t *table) primaryKey(ctx context.Context, value reflect.Value) ([]byte, error) {
+	if len(t.schema.PrimaryFields) == 0 {
+		return nil, fmt.Errorf("table %s has no primary key", t.schema.Table)
+	}
+	values := make([]interface{}, 0, len(t.schema.PrimaryFields))
+	for _, field := range t.schema.PrimaryFields {
+		values = append(values, field.ReflectValueOf(ctx, value).Interface())
+	}
+	return encodeKey(values...)
+}
+
+// index adds the entries of a record to the unique indexes.
+// ctx: The context for the operation.
+// value: The record.
+// key: The primary key of the record.
+// Returns database.ErrConflict if another record has the same values in a unique index.
+func (t *table) index(ctx context.Context, value reflect.Value, key []byte) error {
+	for _, constraint := range t.indexes() {
+		values, ok := constraint.Values(ctx, value)
+		if !ok {
+			continue
+		}
+		indexKey, err := encodeKey(values...)
+		if err != nil {
+			return err
+		}
+		index := t.bucket.Bucket([]byte(constraint.Name))
+		if existing := index.Get(indexKey); existing != nil && !bytes.Equal(existing, key) {
+			return constraint.Conflict()
+		}
+		if err := t.journal.put(index, indexKey, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unindex removes the entries of a record from the unique indexes.
+func (t *table) unindex(ctx context.Context, value reflect.Value) error {
+	for _, constraint := range t.indexes() {
+		values, ok := constraint.Values(ctx, value)
+		if !ok {
+			continue
+		}
+		indexKey, err := encodeKey(values...)
+		if err != nil {
+			return err
+		}
+		if err := t.journal.delete(t.bucket.Bucket([]byte(constraint.Name)), indexKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// match returns the records matching the condition, in primary key order.
+// If the condition requires the primary key or a unique column to be equal to a value,
+// only the record the key or the index points to is considered.
+func (t *table) match(ctx context.Context, cond records.Condition) ([]reflect.Value, error) {
+	if t.rows == nil {
+		return nil, nil
+	}
+	var candidates [][]byte
+	lookup := false
+	if len(t.schema.PrimaryFields) == 1 {
+		if value, ok := records.Equality(cond, t.schema.PrimaryFields[0]); ok {
+			lookup = true
+			if records.Normalize(value) != nil {
+				key, err := encodeKey(value)
+				if err != nil {
+					return nil, err
+				}
+				candidates = [][]byte{t.rows.Get(key)}
+			}
+		}
+	}
+	for _, constraint := range t.indexes() {
+		if lookup || len(constraint.Fields) != 1 {
+			continue
+		}
+		if value, ok := records.Equality(cond, constraint.Fields[0]); ok {
+			lookup = true
+			if records.Normalize(value) == nil {
+				// As in SQL, a comparison with NULL is never true.
+				continue
+			}
+			indexKey, err := encodeKey(value)
+			if err != nil {
+				return nil, err
+			}
+			if key := t.bucket.Bucket([]byte(constraint.Name)).Get(indexKey); key != nil {
+				candidates = [][]byte{t.rows.Get(key)}
+			}
+		}
+	}
+	if !lookup {
+		err := t.rows.ForEach(func(_, data []byte) error {
+			candidates = append(candidates, data)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var rows []reflect.Value
+	for _, data := range candidates {
+		if data == nil {
+			continue
+		}
+		row := records.New(t.schema)
+		if err := t.decode(ctx, data, row); err != nil {
+			return nil, err
+		}
+		matches, err := cond.Match(ctx, row)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// put stores a record under its primary key.
+func (t *table) put(ctx context.Context, key []byte, value reflect.Value) error {
+	data, err := t.encode(ctx, value)
+	if err != nil {
+		return err
+	}
+	return t.journal.put(t.rows, key, data)
+}
+
+// encode encodes the columns of a record as a JSON object keyed by column name.
+// Fields that are not columns are not stored, as in the SQL databases.
+func (t *table) encode(ctx context.Context, value reflect.Value) ([]byte, error) {
+	columns := make(map[string]json.RawMessage, len(t.schema.DBNames))
+	for _, field := range t.schema.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		data, err := json.Marshal(field.ReflectValueOf(ctx, value).Interface())
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s.%s: %w", t.schema.Table, field.DBName, err)
+		}
+		columns[field.DBName] = data
+	}
+	return json.Marshal(columns)
+}
+
+// decode decodes a record encoded by encode. Columns added to the entity since the record was stored keep their zero value.
+func (t *table) decode(ctx context.Context, data []byte, row reflect.Value) error {
+	var columns map[string]json.RawMessage
+	if err := json.Unmarshal(data, &columns); err != nil {
+		return fmt.Errorf("failed to decode a record of %s: %w", t.schema.Table, err)
+	}
+	for _, field := range t.schema.Fields {
+		raw, ok := columns[field.DBName]
+		if field.DBName == "" || !ok {
+			continue
+		}
+		value := reflect.New(field.FieldType)
+		if err := json.Unmarshal(raw, value.Interface()); err != nil {
+			return fmt.Errorf("failed to decode %s.%s: %w", t.schema.Table, field.DBName, err)
+		}
+		if err := field.Set(ctx, row, value.Elem().Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/dberr"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	bbolt "go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDatabase(t *testing.T, path string) *Database {
+	db, err := NewDatabase(&config.Config{DB: config.DatabaseConfig{Bolt: config.BoltConfig{Path: path, TimeoutSeconds: 1}}})
+	require.NoError(t, err, "Failed to open database")
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestUniqueIndexesFollowUpdates(t *testing.T) {
+	db := newTestDatabase(t, filepath.Join(t.TempDir(), "test.bolt"))
+	ctx := context.Background()
+
+	alice := entities.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com"}
+	require.NoError(t, db.Create(ctx, &alice))
+	err := db.Create(ctx, &entities.User{ID: uuid.New(), Username: "other", Email: "alice@example.com"})
+	assert.ErrorIs(t, err, dberr.ErrConflict, "Emails are unique")
+	err = db.Create(ctx, &entities.User{ID: alice.ID, Username: "other", Email: "other@example.com"})
+	assert.ErrorIs(t, err, dberr.ErrConflict, "Primary keys are unique")
+
+	alice.Email = "alicia@example.com"
+	require.NoError(t, db.Update(ctx, &alice))
+
+	var read entities.User
+	assert.ErrorIs(t, db.Read(ctx, &read, query.Eq("email", "alice@example.com")), dberr.ErrNotFound, "The old index entry must be removed")
+	require.NoError(t, db.Read(ctx, &read, query.Eq("email", "alicia@example.com")))
+	assert.Equal(t, alice.ID, read.ID)
+	require.NoError(t, db.Create(ctx, &entities.User{ID: uuid.New(), Username: "bob", Email: "alice@example.com"}), "The old email must be free again")
+
+	require.NoError(t, db.Delete(ctx, entities.User{}, alice.ID))
+	require.NoError(t, db.Create(ctx, &entities.User{ID: uuid.New(), Username: "alice", Email: "alicia@example.com"}), "The deleted user's email must be free again")
+
+	err = db.Create(ctx, &entities.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com"})
+	assert.ErrorIs(t, err, dberr.ErrConflict)
+	var users []entities.User
+	require.NoError(t, db.Find(ctx, &users, query.Where(query.Eq("email", "bob@example.com"))))
+	assert.Empty(t, users, "A failed write must not leave index entries behind")
+}
+
+func TestFailedWritesInTransactionsAreUndone(t *testing.T) {
+	db := newTestDatabase(t, filepath.Join(t.TempDir(), "test.bolt"))
+	ctx := context.Background()
+
+	require.NoError(t, db.WithTx(ctx, func(ctx context.Context) error {
+		require.NoError(t, db.Create(ctx, &entities.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com"}))
+		// The username is indexed before the email is found to be taken.
+		err := db.Create(ctx, &entities.User{ID: uuid.New(), Username: "bob", Email: "alice@example.com"})
+		assert.ErrorIs(t, err, dberr.ErrConflict)
+		return db.Create(ctx, &entities.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com"})
+	}), "The index entry of the failed write must be undone")
+
+	err := db.WithTx(ctx, func(ctx context.Context) error {
+		return db.Create(ctx, &entities.User{ID: uuid.New(), Username: "carol", Email: "carol@example.com"})
+	}, &sql.TxOptions{ReadOnly: true})
+	assert.ErrorIs(t, err, bbolt.ErrTxNotWritable)
+
+	var users []entities.User
+	require.NoError(t, db.ReadAll(ctx, &users))
+	assert.Len(t, users, 2)
+}
+
+func TestRecordsSurviveReopening(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.bolt")
+	ctx := context.Background()
+
+	db := newTestDatabase(t, path)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, db.Create(ctx, &entities.AuditEvent{Actor: "alice", Action: "login", Outcome: "success", Timestamp: start.Add(time.Duration(i) * time.Minute)}))
+	}
+	require.NoError(t, db.Close())
+
+	db = newTestDatabase(t, path)
+	event := entities.AuditEvent{Actor: "bob", Action: "login", Outcome: "success", Timestamp: start}
+	require.NoError(t, db.Create(ctx, &event))
+	assert.Equal(t, uint64(4), event.ID, "The sequence must be persisted")
+
+	var events []entities.AuditEvent
+	require.NoError(t, db.Find(ctx, &events, query.Where(query.Gt("timestamp", start)).OrderedBy(query.Desc("id"))))
+	require.Len(t, events, 2)
+	assert.Equal(t, uint64(3), events[0].ID)
+	assert.True(t, start.Add(2*time.Minute).Equal(events[0].Timestamp))
+}
+
+func TestBackup(t *testing.T) {
+	dir := t.TempDir()
+	db := newTestDatabase(t, filepath.Join(dir, "test.bolt"))
+	ctx := context.Background()
+	user := entities.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com"}
+	require.NoError(t, db.Create(ctx, &user))
+
+	var backup bytes.Buffer
+	written, err := db.Backup(ctx, &backup)
+	require.NoError(t, err)
+	assert.Equal(t, int64(backup.Len()), written)
+
+	path := filepath.Join(dir, "backup.bolt")
+	require.NoError(t, os.WriteFile(path, backup.Bytes(), 0o600))
+	restored := newTestDatabase(t, path)
+	var read entities.User
+	require.NoError(t, restored.Read(ctx, &read, query.Eq("username", "alice")))
+	assert.Equal(t, user.ID, read.ID)
+}
+
+func TestEncodeKeyPreservesOrder(t *testing.T) {
+	ordered := [][]interface{}{
+		{int64(-5), int64(0), int64(3), int64(1 << 40)},
+		{-2.5, 0.0, 1.5},
+		{"", "a", "a\x00", "ab", "b"},
+		{time.Unix(-10, 0), time.Unix(0, 1), time.Unix(0, 2), time.Unix(5, 0)},
+	}
+	for _, values := range ordered {
+		for i := 1; i < len(values); i++ {
+			a, err := encodeKey(values[i-1])
+			require.NoError(t, err)
+			b, err := encodeKey(values[i])
+			require.NoError(t, err)
+			assert.Equal(t, -1, bytes.Compare(a, b), "%v must sort before %v", values[i-1], values[i])
+		}
+	}
+
+	a, err := encodeKey("a\x00", "b")
+	require.NoError(t, err)
+	b, err := encodeKey("a", "\x00b")
+	require.NoError(t, err)
+	assert.NotEqual(t, a, b, "Composite keys must be unambiguous")
+}
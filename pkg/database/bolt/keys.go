@@ -0,0 +1,68 @@
+package bolt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/records"
+	"math"
+	"time"
+)
+
+// The type tags of the encoded key values, so that values of different types never encode to the same bytes.
+const (
+	tagString byte = iota + 1
+	tagInt
+	tagFloat
+	tagBool
+	tagTime
+)
+
+// encodeKey encodes the values of a primary key or a unique index into a key.
+// The encoding preserves the order of the values of each type, so that the records are iterated in primary key order,
+// and it is unambiguous for composite keys.
+// values: The values of the key.
+// Returns the key and an error if a value has a type that cannot be encoded.
+func encodeKey(values ...interface{}) ([]byte, error) {
+	var key []byte
+	for _, value := range values {
+		switch v := records.Normalize(value).(type) {
+		case string:
+			key = append(key, tagString)
+			for i := 0; i < len(v); i++ {
+				// The zero bytes are escaped, so that the terminator cannot appear inside a value.
+				if v[i] == 0 {
+					key = append(key, 0, 0xff)
+					continue
+				}
+				key = append(key, v[i])
+			}
+			key = append(key, 0, 1)
+		case int64:
+			key = append(key, tagInt)
+			key = binary.BigEndian.AppendUint64(key, uint64(v)^(1<<63))
+		case float64:
+			bits := math.Float64bits(v)
+			if v < 0 {
+				bits = ^bits
+			} else {
+				bits ^= 1 << 63
+			}
+			key = append(key, tagFloat)
+			key = binary.BigEndian.AppendUint64(key, bits)
+		case bool:
+			key = append(key, tagBool)
+			if v {
+				key = append(key, 1)
+			} else {
+				key = append(key, 0)
+			}
+		case time.Time:
+			key = append(key, tagTime)
+			key = binary.BigEndian.AppendUint64(key, uint64(v.Unix())^(1<<63))
+			key = binary.BigEndian.AppendUint32(key, uint32(v.Nanosecond()))
+		default:
+			return nil, fmt.Errorf("cannot use %T as a key", value)
+		}
+	}
+	return key, nil
+}
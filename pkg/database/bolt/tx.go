@@ -0,0 +1,168 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/dberr"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// txKey is the context key under which the transaction of a database is stored.
+type txKey struct {
+	db *Database
+}
+
+// transaction struct represents a bbolt transaction started by WithTx.
+// bbolt has no savepoints, so the writes are journaled to be undone when a savepoint or a failed operation is rolled back.
+type transaction struct {
+	tx      *bbolt.Tx
+	journal *journal
+	done    bool
+}
+
+// journal struct represents the undo log of the writes of a transaction. A nil journal logs nothing.
+type journal struct {
+	undo []func() error
+}
+
+// WithTx runs fn in a transaction of the bbolt database, or in a savepoint if the context already carries a transaction.
+// bbolt allows a single writable transaction at a time, so the transactions are serializable,
+// and a writable transaction waits for the one that is running.
+// ctx: The context for the operation.
+// fn: The function to run in the transaction.
+// opts: The options of the transaction. Only the read-only flag is used, a read-only transaction does not wait for the writable one.
+// Returns the error returned by fn, or an error if the transaction cannot be started or committed.
+func (d *Database) WithTx(ctx context.Context, fn func(ctx context.Context) error, opts ...*sql.TxOptions) error {
+	if err := ctx.Err(); err != nil {
+		return dberr.Context(err)
+	}
+	if t := d.tx(ctx); t != nil {
+		return t.savepoint(func() error { return fn(ctx) })
+	}
+
+	writable := len(opts) == 0 || opts[0] == nil || !opts[0].ReadOnly
+	tx, err := d.db.Begin(writable)
+	if err != nil {
+		return err
+	}
+	t := &transaction{tx: tx, journal: &journal{}}
+	committed := false
+	defer func() {
+		t.done = true
+		// A panic rolls the transaction back as well.
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+	if err := fn(context.WithValue(ctx, txKey{db: d}, t)); err != nil {
+		return err
+	}
+	committed = true
+	if !writable {
+		return tx.Rollback()
+	}
+	return tx.Commit()
+}
+
+// tx returns the open transaction of the database carried by the context, or nil if there is none.
+func (d *Database) tx(ctx context.Context) *transaction {
+	if t, ok := ctx.Value(txKey{db: d}).(*transaction); ok && !t.done {
+		return t
+	}
+	return nil
+}
+
+// view runs a read operation in the transaction carried by the context, or in a read-only transaction of its own.
+func (d *Database) view(ctx context.Context, fn func(tx *bbolt.Tx) error) error {
+	if t := d.tx(ctx); t != nil {
+		return fn(t.tx)
+	}
+	return d.db.View(fn)
+}
+
+// update runs a write operation in the transaction carried by the context, or in a writable transaction of its own.
+// In the transaction of the context, the writes of a failed operation are undone, so that every operation is atomic.
+func (d *Database) update(ctx context.Context, fn func(tx *bbolt.Tx, j *journal) error) error {
+	if t := d.tx(ctx); t != nil {
+		if !t.tx.Writable() {
+			return bbolt.ErrTxNotWritable
+		}
+		return t.savepoint(func() error { return fn(t.tx, t.journal) })
+	}
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		return fn(tx, nil)
+	})
+}
+
+// savepoint runs fn and undoes the writes it journaled if it fails or panics.
+func (t *transaction) savepoint(fn func() error) (err error) {
+	mark := len(t.journal.undo)
+	completed := false
+	defer func() {
+		if !completed || err != nil {
+			if undoErr := t.journal.rollback(mark); undoErr != nil && err == nil {
+				err = undoErr
+			}
+		}
+	}()
+	err = fn()
+	completed = true
+	return err
+}
+
+// put stores a value in a bucket, and journals the previous value of the key.
+func (j *journal) put(bucket *bbolt.Bucket, key, value []byte) error {
+	j.save(bucket, key)
+	return bucket.Put(key, value)
+}
+
+// delete removes a key from a bucket, and journals its previous value.
+func (j *journal) delete(bucket *bbolt.Bucket, key []byte) error {
+	j.save(bucket, key)
+	return bucket.Delete(key)
+}
+
+// setSequence sets the sequence of a bucket, and journals its previous value.
+func (j *journal) setSequence(bucket *bbolt.Bucket, sequence uint64) error {
+	if j != nil {
+		previous := bucket.Sequence()
+		j.undo = append(j.undo, func() error { return bucket.SetSequence(previous) })
+	}
+	return bucket.SetSequence(sequence)
+}
+
+// nextSequence increments the sequence of a bucket, and journals its previous value.
+func (j *journal) nextSequence(bucket *bbolt.Bucket) (uint64, error) {
+	if err := j.setSequence(bucket, bucket.Sequence()+1); err != nil {
+		return 0, err
+	}
+	return bucket.Sequence(), nil
+}
+
+// save journals the current value of a key, which is only valid until the transaction writes again and is thus copied.
+func (j *journal) save(bucket *bbolt.Bucket, key []byte) {
+	if j == nil {
+		return
+	}
+	key = bytes.Clone(key)
+	previous := bucket.Get(key)
+	if previous == nil {
+		j.undo = append(j.undo, func() error { return bucket.Delete(key) })
+		return
+	}
+	previous = bytes.Clone(previous)
+	j.undo = append(j.undo, func() error { return bucket.Put(key, previous) })
+}
+
+// rollback undoes the writes journaled since the mark, in reverse order.
+func (j *journal) rollback(mark int) error {
+	for len(j.undo) > mark {
+		undo := j.undo[len(j.undo)-1]
+		j.undo = j.undo[:len(j.undo)-1]
+		if err := undo(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
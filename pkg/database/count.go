@@ -0,0 +1,44 @@
+// Package database provides the functionality to count the records of a database.
+package database
+
+import (
+	"context"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"reflect"
+)
+
+// Counter is an interface implemented by the databases that count the records matching a condition without reading them,
+// such as the SQL databases, and by the decorators that scope or rewrite the condition of the count.
+type Counter interface {
+	// Count counts the records matching the condition.
+	// ctx: The context for the operation.
+	// entity: A record of the type of the records to count.
+	// where: The condition to match. A nil condition matches every record.
+	// Returns the number of matching records and an error if the operation fails.
+	Count(ctx context.Context, entity interface{}, where query.Condition) (int64, error)
+}
+
+// Count counts the records matching a condition with the database, or with the database it decorates,
+// or by reading the matching records if no database counts them.
+// ctx: The context for the operation.
+// db: The database of the records.
+// entity: A record of the type of the records to count.
+// where: The condition to match. A nil condition matches every record.
+// Returns the number of matching records and an error if the operation fails.
+func Count(ctx context.Context, db Database, entity interface{}, where query.Condition) (int64, error) {
+	for d := db; d != nil; {
+		if counter, ok := d.(Counter); ok {
+			return counter.Count(ctx, entity, where)
+		}
+		wrapper, ok := d.(Wrapper)
+		if !ok {
+			break
+		}
+		d = wrapper.Unwrap()
+	}
+	rows := reflect.New(reflect.SliceOf(reflect.Indirect(reflect.ValueOf(entity)).Type()))
+	if err := db.Find(ctx, rows.Interface(), query.Where(where)); err != nil {
+		return 0, err
+	}
+	return int64(rows.Elem().Len()), nil
+}
@@ -2,6 +2,8 @@
 package database
 
 import (
+	"database/sql"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
 	"golang.org/x/net/context"
 )
 
@@ -9,8 +11,11 @@ import (
 // It includes methods for creating, reading, updating, and deleting records.
 // Each method requires a context and an entity.
 // The entity is the record that needs to be created, read, updated, or deleted.
-// For the Read method, a compareString and compareValue are also required to find the record.
+// For the Read method, a condition built with the query package is also required to find the record.
 // For the Delete method, an id is required to find the record.
+// The operations run in the transaction carried by the context, if WithTx started one.
+// The operations report a missing record as ErrNotFound, the violation of a unique constraint as a *ConflictError,
+// and an expired deadline or lock wait as ErrTimeout, whatever the backend, so that errors.Is matches them.
 type Database interface {
 	// Create adds a new record to the database.
 	// ctx: The context for the operation.
@@ -21,15 +26,17 @@ type Database interface {
 	// Read retrieves a record from the database.
 	// ctx: The context for the operation.
 	// entity: The record to retrieve.
-	// compareString: The field to compare.
-	// compareValue: The value to compare.
+	// where: The condition to match, e.g. query.Eq("email", email). A nil condition matches every record.
 	// Returns an error if the operation fails.
-	Read(ctx context.Context, entity interface{}, compareString string, compareValue ...interface{}) error
+	Read(ctx context.Context, entity interface{}, where query.Condition) error
 
-	// Update modifies a record in the database.
+	// Update modifies a record in the database, or adds it if no record has its primary key.
+	// The records with a "version" column are versioned: a new record gets the version 1, and an update compares the version
+	// of the record with the stored one and increments it, so that an update based on an outdated read fails instead of
+	// overwriting the modifications it has not seen.
 	// ctx: The context for the operation.
-	// entity: The record to modify.
-	// Returns an error if the operation fails.
+	// entity: The record to modify. The new version is written back.
+	// Returns an error if the operation fails, and a *ConflictError that wraps ErrStale if the version is outdated.
 	Update(ctx context.Context, entity interface{}) error
 
 	// Delete removes a record from the database.
@@ -44,4 +51,32 @@ type Database interface {
 	// entity: The records to retrieve.
 	// Returns an error if the operation fails.
 	ReadAll(ctx context.Context, entity interface{}) error
+
+	// Find retrieves the records the query selects from the database.
+	// ctx: The context for the operation.
+	// entity: The records to retrieve.
+	// q: The condition, ordering, window and cursor of the records, e.g. query.Where(query.Eq("org_id", id)).OrderedBy(query.Asc("name")).
+	// Returns an error if the operation fails, or if the query refers to a column the entity does not have.
+	Find(ctx context.Context, entity interface{}, q query.Query) error
+
+	// DeleteWhere removes the records matching the condition from the database.
+	// ctx: The context for the operation.
+	// entity: The type of the records to remove.
+	// where: The condition to match. A nil condition matches every record.
+	// Returns the number of removed records and an error if the operation fails.
+	DeleteWhere(ctx context.Context, entity interface{}, where query.Condition) (int64, error)
+
+	// WithTx runs fn in a transaction, which is committed if fn returns nil and rolled back otherwise.
+	// The transaction travels in the context passed to fn, so the operations that receive it, or a context derived from it,
+	// run in the transaction. The context must not be used after fn returns, nor by several goroutines at once.
+	// A nested call runs fn in a savepoint of the enclosing transaction, so that only its own changes are rolled back if it fails.
+	// ctx: The context for the operation.
+	// fn: The function to run in the transaction.
+	// opts: The isolation level and read-only flag of the transaction, instead of the configured ones. They are ignored by nested calls.
+	// Returns the error returned by fn, or an error if the transaction cannot be started or committed.
+	WithTx(ctx context.Context, fn func(ctx context.Context) error, opts ...*sql.TxOptions) error
+
+	// Stats reports the usage of the connection pool of the database, for the metrics and the health checks.
+	// Returns the statistics of the pool, which are zero for the databases without one.
+	Stats() sql.DBStats
 }
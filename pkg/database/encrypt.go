@@ -0,0 +1,577 @@
+// Package database provides the functionality to encrypt the tagged fields of the records a database stores.
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/records"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/keyring"
+	"golang.org/x/net/context"
+	"gorm.io/gorm/schema"
+	"reflect"
+	"sync"
+)
+
+// EncryptTag is the tag of the encrypted string fields. Its value is the column of the blind index of the field,
+// e.g. `encrypt:"email_index"`, which the lookups by equality of the field are rewritten to, or is empty if the field is never looked up.
+// The blind index field is a string, or a *string so that the records without a value have no index and do not conflict in a unique index.
+const EncryptTag = "encrypt"
+
+// ErrEncryptedQuery is returned when a query compares, matches or orders an encrypted column other than by equality to a value,
+// which cannot be evaluated on its encrypted values.
+var ErrEncryptedQuery = errors.New("the encrypted columns can only be looked up by equality")
+
+// encryptedField struct represents an encrypted field of an entity type and the field of its blind index.
+type encryptedField struct {
+	field   *schema.Field // The encrypted field.
+	index   *schema.Field // The field of the blind index, or nil if the field has none.
+	context string        // The table and the column of the field, which its values are bound to.
+}
+
+// EncryptedDatabase struct represents a database decorator that encrypts the fields tagged with EncryptTag when the records are written,
+// and decrypts them when they are read, so that the stored records never hold their plaintext.
+// The lookups by equality of an encrypted field are rewritten to its blind index, so that the unique constraints and the lookups
+// keep working. The values written before the encryption was enabled are read and matched as they are until Reencrypt rewrites them.
+// Operations on entities without encrypted fields are passed through unchanged.
+type EncryptedDatabase struct {
+	db      Database
+	keys    *keyring.Keyring
+	schemas sync.Map
+	fields  sync.Map
+}
+
+// NewEncryptedDatabase creates a new encryption decorator.
+// db: The database to decorate.
+// keys: The keys the fields are encrypted with and the key of their blind indexes.
+// Returns a *EncryptedDatabase object.
+func NewEncryptedDatabase(db Database, keys *keyring.Keyring) *EncryptedDatabase {
+	return &EncryptedDatabase{
+		db:   db,
+		keys: keys,
+	}
+}
+
+// Unwrap returns the decorated database.
+func (e *EncryptedDatabase) Unwrap() Database {
+	return e.db
+}
+
+// Create adds a new record to the database, with its encrypted fields encrypted by the primary key.
+// ctx: The context for the operation.
+// entity: The record to add. Its fields keep their plaintext, and its blind indexes are set.
+// Returns an error if the operation fails.
+func (e *EncryptedDatabase) Create(ctx context.Context, entity interface{}) error {
+	return e.write(ctx, entity, e.db.Create)
+}
+
+// Read retrieves a record from the database and decrypts its encrypted fields.
+// ctx: The context for the operation.
+// entity: The record to retrieve.
+// where: The condition to match. The equalities of the encrypted columns are matched with their blind indexes.
+// Returns an error if the operation fails, ErrEncryptedQuery if the condition cannot be evaluated, and keyring.ErrDecrypt
+// or keyring.ErrUnknownKey if a field cannot be decrypted.
+func (e *EncryptedDatabase) Read(ctx context.Context, entity interface{}, where query.Condition) error {
+	fields, err := e.encryptedFields(entity)
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return e.db.Read(ctx, entity, where)
+	}
+	if where, err = e.rewrite(fields, where); err != nil {
+		return err
+	}
+	if err := e.db.Read(ctx, entity, where); err != nil {
+		return err
+	}
+	return e.decrypt(ctx, fields, entity)
+}
+
+// Update modifies a record in the database, with its encrypted fields encrypted by the primary key.
+// ctx: The context for the operation.
+// entity: The record to modify. Its fields keep their plaintext, and its blind indexes are set.
+// Returns an error if the operation fails.
+func (e *EncryptedDatabase) Update(ctx context.Context, entity interface{}) error {
+	return e.write(ctx, entity, e.db.Update)
+}
+
+// Delete removes a record from the database.
+// ctx: The context for the operation.
+// entity: The record to remove.
+// id: The id of the record to remove.
+// Returns an error if the operation fails.
+func (e *EncryptedDatabase) Delete(ctx context.Context, entity interface{}, id interface{}) error {
+	return e.db.Delete(ctx, entity, id)
+}
+
+// ReadAll retrieves all records from the database and decrypts their encrypted fields.
+// ctx: The context for the operation.
+// entity: The records to retrieve.
+// Returns an error if the operation fails or a field cannot be decrypted.
+func (e *EncryptedDatabase) ReadAll(ctx context.Context, entity interface{}) error {
+	fields, err := e.encryptedFields(entity)
+	if err != nil {
+		return err
+	}
+	if err := e.db.ReadAll(ctx, entity); err != nil || len(fields) == 0 {
+		return err
+	}
+	return e.decrypt(ctx, fields, entity)
+}
+
+// Find retrieves the records matching the condition from the database and decrypts their encrypted fields.
+// ctx: The context for the operation.
+// entity: The records to retrieve.
+// q: The condition, ordering and window of the records. The encrypted columns can be matched by equality, but not ordered.
+// Returns an error if the operation fails, ErrEncryptedQuery if the query cannot be evaluated, and an error if a field cannot be decrypted.
+func (e *EncryptedDatabase) Find(ctx context.Context, entity interface{}, q query.Query) error {
+	fields, err := e.encryptedFields(entity)
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return e.db.Find(ctx, entity, q)
+	}
+	for _, order := range q.OrderBy {
+		if _, ok := fields[order.Column]; ok {
+			return fmt.Errorf("%w: cannot order by %s", ErrEncryptedQuery, order.Column)
+		}
+	}
+	if q.Where, err = e.rewrite(fields, q.Where); err != nil {
+		return err
+	}
+	if err := e.db.Find(ctx, entity, q); err != nil {
+		return err
+	}
+	return e.decrypt(ctx, fields, entity)
+}
+
+// DeleteWhere removes the records matching the condition from the database.
+// ctx: The context for the operation.
+// entity: The type of the records to remove.
+// where: The condition to match. The equalities of the encrypted columns are matched with their blind indexes.
+// Returns the number of removed records and an error if the operation fails.
+func (e *EncryptedDatabase) DeleteWhere(ctx context.Context, entity interface{}, where query.Condition) (int64, error) {
+	fields, err := e.encryptedFields(entity)
+	if err != nil {
+		return 0, err
+	}
+	if len(fields) == 0 {
+		return e.db.DeleteWhere(ctx, entity, where)
+	}
+	if where, err = e.rewrite(fields, where); err != nil {
+		return 0, err
+	}
+	return e.db.DeleteWhere(ctx, entity, where)
+}
+
+// Count counts the records matching the condition in the decorated database.
+// ctx: The context for the operation.
+// entity: A record of the type of the records to count.
+// where: The condition to match. The equalities of the encrypted columns are matched with their blind indexes.
+// Returns the number of matching records, ErrEncryptedQuery if the condition cannot be evaluated, and an error if the operation fails.
+func (e *EncryptedDatabase) Count(ctx context.Context, entity interface{}, where query.Condition) (int64, error) {
+	fields, err := e.encryptedFields(entity)
+	if err != nil {
+		return 0, err
+	}
+	if len(fields) > 0 {
+		if where, err = e.rewrite(fields, where); err != nil {
+			return 0, err
+		}
+	}
+	return Count(ctx, e.db, entity, where)
+}
+
+// Search searches the records with the full-text index of the decorated database and decrypts their encrypted fields.
+// The full-text index holds the stored values, so that the encrypted fields cannot be searched, and should neither be indexed
+// nor be among the columns of the search, see Encrypted.
+// ctx: The context for the operation.
+// entity: A pointer to the slice of the records to retrieve.
+// search: The terms and the window of the search. It must name its columns if the records have encrypted fields.
+// Returns the rank of every record, the number of matching records, ErrSearchNotSupported if the search matches an encrypted
+// column, or every column of records with encrypted fields, and an error if the search fails or a field cannot be decrypted.
+func (e *EncryptedDatabase) Search(ctx context.Context, entity interface{}, search query.TextSearch) ([]float64, int64, error) {
+	fields, err := e.encryptedFields(entity)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(fields) > 0 && len(search.Columns) == 0 {
+		return nil, 0, fmt.Errorf("%w: the encrypted columns cannot be searched, so the search must name its columns", ErrSearchNotSupported)
+	}
+	for _, column := range search.Columns {
+		if _, found := fields[column]; found {
+			return nil, 0, fmt.Errorf("%w: the %s column is encrypted", ErrSearchNotSupported, column)
+		}
+	}
+	ranks, total, err := Search(ctx, e.db, entity, search)
+	if err != nil || len(fields) == 0 {
+		return ranks, total, err
+	}
+	return ranks, total, e.decrypt(ctx, fields, entity)
+}
+
+// Encrypted reports whether a column of an entity type is encrypted by the database, or by a database it decorates,
+// so that the callers can leave it out of the operations that cannot be evaluated on its encrypted values.
+// db: The database of the entity.
+// entity: A record of the entity type.
+// column: The column of the entity.
+// Returns true if an EncryptedDatabase encrypts the column.
+func Encrypted(db Database, entity interface{}, column string) bool {
+	for db != nil {
+		if encrypted, ok := db.(*EncryptedDatabase); ok {
+			fields, err := encrypted.encryptedFields(entity)
+			if _, found := fields[column]; err == nil && found {
+				return true
+			}
+		}
+		wrapper, ok := db.(Wrapper)
+		if !ok {
+			break
+		}
+		db = wrapper.Unwrap()
+	}
+	return false
+}
+
+// Stats reports the usage of the connection pool of the decorated database.
+// Returns the statistics of the pool.
+func (e *EncryptedDatabase) Stats() sql.DBStats {
+	return e.db.Stats()
+}
+
+// WithTx runs fn in a transaction of the decorated database. The encryption applies to the operations of fn as to any other.
+// ctx: The context for the operation.
+// fn: The function to run in the transaction.
+// opts: The isolation level and read-only flag of the transaction.
+// Returns the error returned by fn, or an error if the transaction cannot be started or committed.
+func (e *EncryptedDatabase) WithTx(ctx context.Context, fn func(ctx context.Context) error, opts ...*sql.TxOptions) error {
+	return e.db.WithTx(ctx, fn, opts...)
+}
+
+// Reencrypt rewrites the records of an entity type whose encrypted fields are not encrypted by the primary key, because they were
+// written before the encryption was enabled or before the last rotation, or whose blind indexes are outdated.
+// It is run once a new primary key is configured, after which the previous keys can be removed.
+// The records modified while it runs are skipped, since their modification encrypted them with the primary key.
+// ctx: The context for the operation.
+// entity: A record of the entity type, e.g. entities.User{}.
+// batch: The number of records read at once.
+// Returns the number of rewritten records, and an error if a record cannot be read, decrypted or written.
+func (e *EncryptedDatabase) Reencrypt(ctx context.Context, entity interface{}, batch int) (int64, error) {
+	s, err := records.Parse(entity, &e.schemas)
+	if err != nil {
+		return 0, err
+	}
+	fields, err := e.encryptedFields(entity)
+	if err != nil || len(fields) == 0 {
+		return 0, err
+	}
+	primary := s.PrioritizedPrimaryField
+	if primary == nil {
+		return 0, fmt.Errorf("the table %s has no primary key to page through", s.Table)
+	}
+
+	var (
+		rewritten int64
+		last      interface{}
+	)
+	for {
+		q := query.Query{}.OrderedBy(query.Asc(primary.DBName)).Window(batch, 0)
+		if last != nil {
+			q = q.StartAfter(last)
+		}
+		rows := reflect.New(reflect.SliceOf(s.ModelType))
+		if err := e.db.Find(ctx, rows.Interface(), q); err != nil {
+			return rewritten, err
+		}
+		for i := 0; i < rows.Elem().Len(); i++ {
+			record := rows.Elem().Index(i)
+			last = primary.ReflectValueOf(ctx, record).Interface()
+			outdated, err := e.outdated(ctx, fields, record)
+			if err != nil {
+				return rewritten, err
+			}
+			if !outdated {
+				continue
+			}
+			if err := e.decryptRecord(ctx, fields, record); err != nil {
+				return rewritten, err
+			}
+			if _, err := e.encryptRecord(ctx, fields, record); err != nil {
+				return rewritten, err
+			}
+			err = e.db.Update(ctx, record.Addr().Interface())
+			switch {
+			case errors.Is(err, ErrStale):
+				continue
+			case err != nil:
+				return rewritten, err
+			}
+			rewritten++
+		}
+		if batch <= 0 || rows.Elem().Len() < batch {
+			return rewritten, nil
+		}
+	}
+}
+
+// write encrypts the fields of a record, writes it, and restores the plaintext of its fields.
+// ctx: The context for the operation.
+// entity: The record to write.
+// op: The operation of the decorated database that writes the record.
+// Returns the error of the operation, whose conflicts on a blind index name the encrypted column.
+func (e *EncryptedDatabase) write(ctx context.Context, entity interface{}, op func(ctx context.Context, entity interface{}) error) error {
+	fields, err := e.encryptedFields(entity)
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return op(ctx, entity)
+	}
+	record := records.Record(entity)
+	plaintexts, err := e.encryptRecord(ctx, fields, record)
+	if err != nil {
+		return err
+	}
+	err = op(ctx, record.Addr().Interface())
+	for column, plaintext := range plaintexts {
+		fields[column].field.ReflectValueOf(ctx, record).SetString(plaintext)
+	}
+
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		for i, name := range conflict.Fields {
+			for column, f := range fields {
+				if f.index != nil && f.index.DBName == name {
+					conflict.Fields[i] = column
+				}
+			}
+		}
+	}
+	return err
+}
+
+// encryptRecord encrypts the fields of a record with the primary key and sets their blind indexes.
+// The empty fields are stored empty, without an index.
+// ctx: The context for the operation.
+// fields: The encrypted fields of the record, by column.
+// record: The addressable record.
+// Returns the plaintext of the fields by column, and an error if a field cannot be encrypted.
+func (e *EncryptedDatabase) encryptRecord(ctx context.Context, fields map[string]encryptedField, record reflect.Value) (map[string]string, error) {
+	plaintexts := make(map[string]string, len(fields))
+	for column, f := range fields {
+		value := f.field.ReflectValueOf(ctx, record)
+		plaintext := value.String()
+		plaintexts[column] = plaintext
+		if f.index != nil {
+			setIndex(f.index.ReflectValueOf(ctx, record), plaintext, e.keys.Index(plaintext, f.context))
+		}
+		if plaintext == "" {
+			continue
+		}
+		encrypted, err := e.keys.Encrypt(plaintext, f.context)
+		if err != nil {
+			return nil, err
+		}
+		value.SetString(encrypted)
+	}
+	return plaintexts, nil
+}
+
+// decrypt decrypts the fields of the records read by an operation.
+// ctx: The context for the operation.
+// fields: The encrypted fields of the records, by column.
+// entity: A pointer to a record or to a slice of records.
+// Returns an error if a field cannot be decrypted.
+func (e *EncryptedDatabase) decrypt(ctx context.Context, fields map[string]encryptedField, entity interface{}) error {
+	value := reflect.ValueOf(entity)
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return e.decryptRecord(ctx, fields, value)
+	}
+	for i := 0; i < value.Len(); i++ {
+		record := value.Index(i)
+		for record.Kind() == reflect.Ptr && !record.IsNil() {
+			record = record.Elem()
+		}
+		if err := e.decryptRecord(ctx, fields, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decryptRecord decrypts the fields of a record. The values written before the encryption was enabled are kept as they are.
+// ctx: The context for the operation.
+// fields: The encrypted fields of the record, by column.
+// record: The addressable record.
+// Returns an error if a field cannot be decrypted.
+func (e *EncryptedDatabase) decryptRecord(ctx context.Context, fields map[string]encryptedField, record reflect.Value) error {
+	if record.Kind() != reflect.Struct || !record.CanAddr() {
+		return nil
+	}
+	for column, f := range fields {
+		value := f.field.ReflectValueOf(ctx, record)
+		if !keyring.IsEncrypted(value.String()) {
+			continue
+		}
+		plaintext, err := e.keys.Decrypt(value.String(), f.context)
+		if err != nil {
+			return fmt.Errorf("%s: %w", column, err)
+		}
+		value.SetString(plaintext)
+	}
+	return nil
+}
+
+// outdated reports whether a stored record has to be encrypted again: a field is not encrypted by the primary key,
+// or the blind index of a field does not match its value.
+// ctx: The context for the operation.
+// fields: The encrypted fields of the record, by column.
+// record: The stored record.
+// Returns true if the record is outdated, and an error if a field cannot be decrypted.
+func (e *EncryptedDatabase) outdated(ctx context.Context, fields map[string]encryptedField, record reflect.Value) (bool, error) {
+	for column, f := range fields {
+		stored := f.field.ReflectValueOf(ctx, record).String()
+		plaintext := stored
+		if stored != "" {
+			if !e.keys.Current(stored) {
+				return true, nil
+			}
+			var err error
+			if plaintext, err = e.keys.Decrypt(stored, f.context); err != nil {
+				return false, fmt.Errorf("%s: %w", column, err)
+			}
+		}
+		if f.index == nil {
+			continue
+		}
+		index := f.index.ReflectValueOf(ctx, record)
+		want := reflect.New(index.Type()).Elem()
+		setIndex(want, plaintext, e.keys.Index(plaintext, f.context))
+		if !reflect.DeepEqual(index.Interface(), want.Interface()) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// rewrite rewrites the equalities of the encrypted columns of a condition to their blind indexes.
+// An equality also matches the value as it is, which the records written before the encryption was enabled hold.
+// fields: The encrypted fields of the records, by column.
+// where: The condition.
+// Returns the rewritten condition, and ErrEncryptedQuery if an encrypted column is compared otherwise or has no index.
+func (e *EncryptedDatabase) rewrite(fields map[string]encryptedField, where query.Condition) (query.Condition, error) {
+	switch c := where.(type) {
+	case query.Comparison:
+		f, ok := fields[c.Column]
+		if !ok {
+			return c, nil
+		}
+		if c.Operator != query.Equal || f.index == nil {
+			return nil, fmt.Errorf("%w: %s %s", ErrEncryptedQuery, c.Column, c.Operator)
+		}
+		return query.Or(query.Eq(f.index.DBName, e.keys.Index(fmt.Sprint(c.Value), f.context)), c), nil
+	case query.Membership:
+		if _, ok := fields[c.Column]; !ok || len(c.Values) == 0 {
+			return c, nil
+		}
+		alternatives := make(query.Disjunction, 0, len(c.Values))
+		for _, value := range c.Values {
+			alternative, err := e.rewrite(fields, query.Eq(c.Column, value))
+			if err != nil {
+				return nil, err
+			}
+			alternatives = append(alternatives, alternative)
+		}
+		return alternatives, nil
+	case query.Pattern:
+		if _, ok := fields[c.Column]; ok {
+			return nil, fmt.Errorf("%w: %s LIKE", ErrEncryptedQuery, c.Column)
+		}
+		return c, nil
+	case query.Between:
+		if _, ok := fields[c.Column]; ok {
+			return nil, fmt.Errorf("%w: %s BETWEEN", ErrEncryptedQuery, c.Column)
+		}
+		return c, nil
+	case query.Conjunction:
+		rewritten := make(query.Conjunction, 0, len(c))
+		for _, condition := range c {
+			condition, err := e.rewrite(fields, condition)
+			if err != nil {
+				return nil, err
+			}
+			rewritten = append(rewritten, condition)
+		}
+		return rewritten, nil
+	case query.Disjunction:
+		rewritten := make(query.Disjunction, 0, len(c))
+		for _, condition := range c {
+			condition, err := e.rewrite(fields, condition)
+			if err != nil {
+				return nil, err
+			}
+			rewritten = append(rewritten, condition)
+		}
+		return rewritten, nil
+	default:
+		return where, nil
+	}
+}
+
+// encryptedFields returns the encrypted fields of the entity type, which are parsed once per type.
+// entity: A record, a slice of records or a pointer to either.
+// Returns the encrypted fields by column, and an error if the entity type cannot be parsed or a tag is invalid.
+func (e *EncryptedDatabase) encryptedFields(entity interface{}) (map[string]encryptedField, error) {
+	s, err := records.Parse(entity, &e.schemas)
+	if err != nil {
+		return nil, err
+	}
+	if cached, ok := e.fields.Load(s.ModelType); ok {
+		return cached.(map[string]encryptedField), nil
+	}
+	fields := map[string]encryptedField{}
+	for _, field := range s.Fields {
+		column, ok := field.Tag.Lookup(EncryptTag)
+		if !ok || field.DBName == "" {
+			continue
+		}
+		if field.FieldType.Kind() != reflect.String {
+			return nil, fmt.Errorf("the encrypted field %s.%s is not a string", s.Name, field.Name)
+		}
+		f := encryptedField{field: field, context: s.Table + "." + field.DBName}
+		if column != "" {
+			f.index = s.FieldsByDBName[column]
+			if f.index == nil || f.index.IndirectFieldType.Kind() != reflect.String {
+				return nil, fmt.Errorf("the blind index %s of the encrypted field %s.%s is not a string column", column, s.Name, field.Name)
+			}
+		}
+		fields[field.DBName] = f
+	}
+	e.fields.Store(s.ModelType, fields)
+	return fields, nil
+}
+
+// setIndex sets the blind index field of a value: the index of a non-empty value, and no index, or an empty one, otherwise.
+// field: The addressable blind index field, a string or a *string.
+// plaintext: The value of the encrypted field.
+// index: The blind index of the value.
+func setIndex(field reflect.Value, plaintext, index string) {
+	if field.Kind() == reflect.Ptr {
+		if plaintext == "" {
+			field.Set(reflect.Zero(field.Type()))
+			return
+		}
+		field.Set(reflect.ValueOf(&index))
+		return
+	}
+	if plaintext == "" {
+		index = ""
+	}
+	field.SetString(index)
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/memory"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/keyring"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKeyring(t *testing.T, primary string) *keyring.Keyring {
+	keys, err := keyring.New([]keyring.Key{
+		{ID: "k1", Secret: bytes.Repeat([]byte{1}, keyring.KeySize)},
+		{ID: "k2", Secret: bytes.Repeat([]byte{2}, keyring.KeySize)},
+	}, primary, bytes.Repeat([]byte{9}, keyring.KeySize))
+	require.NoError(t, err)
+	return keys
+}
+
+func newEncryptedTestUser(name string) *entities.User {
+	return &entities.User{
+		ID:       uuid.New(),
+		Username: name,
+		Password: "password",
+		Email:    name + "@example.com",
+		Token:    "token-of-" + name,
+		Metadata: entities.Metadata{LastLoginAt: time.Now()},
+	}
+}
+
+func TestEncryptedDatabase(t *testing.T) {
+	raw := memory.NewDatabase()
+	db := NewEncryptedDatabase(raw, newTestKeyring(t, "k1"))
+	ctx := context.Background()
+
+	alice := newEncryptedTestUser("alice")
+	require.NoError(t, db.Create(ctx, alice))
+	assert.Equal(t, "alice@example.com", alice.Email, "the caller keeps the plaintext")
+	require.NotNil(t, alice.EmailIndex)
+
+	var stored entities.User
+	require.NoError(t, raw.Read(ctx, &stored, query.Eq("id", alice.ID)))
+	assert.True(t, keyring.IsEncrypted(stored.Email))
+	assert.True(t, keyring.IsEncrypted(stored.Token))
+	assert.NotContains(t, stored.Email, "alice")
+	assert.Equal(t, *alice.EmailIndex, *stored.EmailIndex)
+
+	var found entities.User
+	require.NoError(t, db.Read(ctx, &found, query.Eq("email", "alice@example.com")))
+	assert.Equal(t, alice.ID, found.ID)
+	assert.Equal(t, "token-of-alice", found.Token)
+	found = entities.User{}
+	require.NoError(t, db.Read(ctx, &found, query.Or(query.Eq("token", "token-of-alice"), query.Eq("username", "nobody"))))
+	assert.Equal(t, "alice@example.com", found.Email)
+	assert.ErrorIs(t, db.Read(ctx, &entities.User{}, query.Eq("email", "bob@example.com")), ErrNotFound)
+	assert.ErrorIs(t, db.Read(ctx, &entities.User{}, query.Like("email", "%@example.com")), ErrEncryptedQuery)
+
+	duplicate := newEncryptedTestUser("alice2")
+	duplicate.Email = "alice@example.com"
+	err := db.Create(ctx, duplicate)
+	var conflict *ConflictError
+	require.ErrorAs(t, err, &conflict, "the blind index keeps the emails unique")
+	assert.Equal(t, "email", conflict.Field())
+
+	found.Token = ""
+	require.NoError(t, db.Update(ctx, &found))
+	assert.Equal(t, int64(2), found.Metadata.Version)
+	stored = entities.User{}
+	require.NoError(t, raw.Read(ctx, &stored, query.Eq("id", alice.ID)))
+	assert.Empty(t, stored.Token)
+	assert.Nil(t, stored.TokenIndex, "an empty value has no index")
+
+	var users []entities.User
+	require.NoError(t, db.ReadAll(ctx, &users))
+	require.Len(t, users, 1)
+	assert.Equal(t, "alice@example.com", users[0].Email)
+}
+
+func TestEncryptedDatabaseReencrypt(t *testing.T) {
+	raw := memory.NewDatabase()
+	ctx := context.Background()
+
+	legacy := newEncryptedTestUser("legacy")
+	require.NoError(t, raw.Create(ctx, legacy), "a user written before the encryption was enabled")
+	db := NewEncryptedDatabase(raw, newTestKeyring(t, "k1"))
+	require.NoError(t, db.Create(ctx, newEncryptedTestUser("alice")))
+
+	var found entities.User
+	require.NoError(t, db.Read(ctx, &found, query.Eq("email", "legacy@example.com")), "the plaintext values still match")
+	assert.Equal(t, legacy.ID, found.ID)
+
+	n, err := db.Reencrypt(ctx, entities.User{}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n, "only the plaintext user is written")
+	var stored entities.User
+	require.NoError(t, raw.Read(ctx, &stored, query.Eq("id", legacy.ID)))
+	assert.True(t, keyring.IsEncrypted(stored.Email))
+	require.NotNil(t, stored.EmailIndex)
+
+	rotated := NewEncryptedDatabase(raw, newTestKeyring(t, "k2"))
+	n, err = rotated.Reencrypt(ctx, entities.User{}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n, "every user is encrypted with the new primary key")
+	n, err = rotated.Reencrypt(ctx, entities.User{}, 10)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	keys, err := keyring.New([]keyring.Key{{ID: "k2", Secret: bytes.Repeat([]byte{2}, keyring.KeySize)}}, "", bytes.Repeat([]byte{9}, keyring.KeySize))
+	require.NoError(t, err)
+	found = entities.User{}
+	require.NoError(t, NewEncryptedDatabase(raw, keys).Read(ctx, &found, query.Eq("email", "alice@example.com")),
+		"the previous key can be removed once the users are encrypted again")
+	assert.Equal(t, "token-of-alice", found.Token)
+}
@@ -0,0 +1,28 @@
+// Package database provides the errors every database maps its native errors to.
+package database
+
+import "github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/dberr"
+
+var (
+	// ErrNotFound is returned when no record matches.
+	ErrNotFound = dberr.ErrNotFound
+	// ErrConflict is returned when a record violates a unique constraint. The error is a *ConflictError.
+	ErrConflict = dberr.ErrConflict
+	// ErrTimeout is returned when an operation does not complete in time, e.g. because it waited too long for a lock.
+	ErrTimeout = dberr.ErrTimeout
+	// ErrStale is returned when an update is based on a version of a record that was modified since.
+	// The error is a *ConflictError, so that errors.Is(err, ErrConflict) matches it too.
+	ErrStale = dberr.ErrStale
+)
+
+// ConflictError struct represents the violation of a unique constraint, with the columns of the constraint if the database reports them.
+// errors.Is(err, ErrConflict) matches it.
+type ConflictError = dberr.ConflictError
+
+// Stale returns the conflict of an update based on an outdated version of a record, for the checks of the versions
+// that happen before the update, such as the ones of the If-Match headers.
+// table: The table of the record.
+// Returns a *ConflictError that wraps ErrStale.
+func Stale(table string) error {
+	return dberr.Stale(table)
+}
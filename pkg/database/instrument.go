@@ -0,0 +1,329 @@
+// Package database provides the functionality to trace the operations of a database and to log the slow ones.
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/records"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"golang.org/x/net/context"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operation struct represents a completed operation of the database, as handed to the sinks.
+type Operation struct {
+	Name     string        // The name of the operation, e.g. "read", "find" or "tx".
+	Table    string        // The table of the records, or empty for the transactions.
+	Query    string        // The condition, ordering and window of the operation with the values redacted, e.g. "email = ?".
+	Rows     int64         // The number of records read, written or removed.
+	Start    time.Time     // The time the operation started.
+	Duration time.Duration // The time the operation took.
+	Err      error         // The error of the operation, or nil.
+}
+
+// Sink is an interface implemented by the observability backends that receive the operations of the database,
+// e.g. to build the spans of a trace or to update metrics.
+type Sink interface {
+	// Record receives a completed operation. It is called synchronously, and must not block.
+	// ctx: The context of the operation.
+	// op: The operation.
+	Record(ctx context.Context, op Operation)
+}
+
+// SinkFunc is an adapter that lets a function be used as a Sink.
+type SinkFunc func(ctx context.Context, op Operation)
+
+// Record calls f(ctx, op).
+func (f SinkFunc) Record(ctx context.Context, op Operation) {
+	f(ctx, op)
+}
+
+// InstrumentedDatabase struct represents a database decorator that measures every operation, logs the operations that exceed
+// a threshold and hands every operation to the sinks.
+// The logged queries never carry the values of the conditions, which may be personal data or secrets.
+type InstrumentedDatabase struct {
+	db      Database
+	slow    time.Duration
+	logger  *log.Logger
+	sinks   []Sink
+	schemas sync.Map
+	now     func() time.Time
+}
+
+// NewInstrumentedDatabase creates a new instrumentation decorator.
+// db: The database to decorate.
+// slow: The duration from which an operation is logged. Zero disables the logging.
+// logger: The logger of the slow operations. The standard logger is used if it is nil.
+// sinks: The observability backends that receive every operation.
+// Returns a *InstrumentedDatabase object.
+func NewInstrumentedDatabase(db Database, slow time.Duration, logger *log.Logger, sinks ...Sink) *InstrumentedDatabase {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &InstrumentedDatabase{
+		db:     db,
+		slow:   slow,
+		logger: logger,
+		sinks:  sinks,
+		now:    time.Now,
+	}
+}
+
+// Unwrap returns the decorated database.
+func (i *InstrumentedDatabase) Unwrap() Database {
+	return i.db
+}
+
+// Create adds a new record to the database.
+// ctx: The context for the operation.
+// entity: The record to add.
+// Returns an error if the operation fails.
+func (i *InstrumentedDatabase) Create(ctx context.Context, entity interface{}) error {
+	return i.measure(ctx, "create", entity, "", func() (int64, error) {
+		return 1, i.db.Create(ctx, entity)
+	})
+}
+
+// Read retrieves a record from the database.
+// ctx: The context for the operation.
+// entity: The record to retrieve.
+// where: The condition to match.
+// Returns an error if the operation fails.
+func (i *InstrumentedDatabase) Read(ctx context.Context, entity interface{}, where query.Condition) error {
+	return i.measure(ctx, "read", entity, redact(where), func() (int64, error) {
+		return 1, i.db.Read(ctx, entity, where)
+	})
+}
+
+// Update modifies a record in the database.
+// ctx: The context for the operation.
+// entity: The record to modify.
+// Returns an error if the operation fails.
+func (i *InstrumentedDatabase) Update(ctx context.Context, entity interface{}) error {
+	return i.measure(ctx, "update", entity, "", func() (int64, error) {
+		return 1, i.db.Update(ctx, entity)
+	})
+}
+
+// Delete removes a record from the database.
+// ctx: The context for the operation.
+// entity: The record to remove.
+// id: The id of the record to remove.
+// Returns an error if the operation fails.
+func (i *InstrumentedDatabase) Delete(ctx context.Context, entity interface{}, id interface{}) error {
+	return i.measure(ctx, "delete", entity, "id = ?", func() (int64, error) {
+		return 1, i.db.Delete(ctx, entity, id)
+	})
+}
+
+// ReadAll retrieves all records from the database.
+// ctx: The context for the operation.
+// entity: The records to retrieve.
+// Returns an error if the operation fails.
+func (i *InstrumentedDatabase) ReadAll(ctx context.Context, entity interface{}) error {
+	return i.measure(ctx, "read_all", entity, "", func() (int64, error) {
+		err := i.db.ReadAll(ctx, entity)
+		return length(entity), err
+	})
+}
+
+// Find retrieves the records the query selects from the database.
+// ctx: The context for the operation.
+// entity: The records to retrieve.
+// q: The condition, ordering and window of the records.
+// Returns an error if the operation fails.
+func (i *InstrumentedDatabase) Find(ctx context.Context, entity interface{}, q query.Query) error {
+	return i.measure(ctx, "find", entity, describe(q), func() (int64, error) {
+		err := i.db.Find(ctx, entity, q)
+		return length(entity), err
+	})
+}
+
+// DeleteWhere removes the records matching the condition from the database.
+// ctx: The context for the operation.
+// entity: The type of the records to remove.
+// where: The condition to match.
+// Returns the number of removed records and an error if the operation fails.
+func (i *InstrumentedDatabase) DeleteWhere(ctx context.Context, entity interface{}, where query.Condition) (int64, error) {
+	var removed int64
+	err := i.measure(ctx, "delete_where", entity, redact(where), func() (int64, error) {
+		var err error
+		removed, err = i.db.DeleteWhere(ctx, entity, where)
+		return removed, err
+	})
+	return removed, err
+}
+
+// Count counts the records matching the condition in the database.
+// ctx: The context for the operation.
+// entity: A record of the type of the records to count.
+// where: The condition to match.
+// Returns the number of matching records and an error if the operation fails.
+func (i *InstrumentedDatabase) Count(ctx context.Context, entity interface{}, where query.Condition) (int64, error) {
+	var count int64
+	err := i.measure(ctx, "count", entity, redact(where), func() (int64, error) {
+		var err error
+		count, err = Count(ctx, i.db, entity, where)
+		return count, err
+	})
+	return count, err
+}
+
+// Search searches the records with the full-text index of the decorated database.
+// ctx: The context for the operation.
+// entity: A pointer to the slice of the records to retrieve.
+// search: The terms and the window of the search.
+// Returns the rank of every record, the number of matching records, and ErrSearchNotSupported if the database has no full-text index.
+func (i *InstrumentedDatabase) Search(ctx context.Context, entity interface{}, search query.TextSearch) ([]float64, int64, error) {
+	var (
+		ranks []float64
+		total int64
+	)
+	err := i.measure(ctx, "search", entity, "MATCH ?", func() (int64, error) {
+		var err error
+		ranks, total, err = Search(ctx, i.db, entity, search)
+		return length(entity), err
+	})
+	return ranks, total, err
+}
+
+// WithTx runs fn in a transaction of the database. The transaction is measured as a whole, and its operations one by one.
+// ctx: The context for the operation.
+// fn: The function to run in the transaction.
+// opts: The isolation level and read-only flag of the transaction.
+// Returns the error returned by fn, or an error if the transaction cannot be started or committed.
+func (i *InstrumentedDatabase) WithTx(ctx context.Context, fn func(ctx context.Context) error, opts ...*sql.TxOptions) error {
+	return i.measure(ctx, "tx", nil, "", func() (int64, error) {
+		return 0, i.db.WithTx(ctx, fn, opts...)
+	})
+}
+
+// Stats reports the usage of the connection pool of the decorated database.
+// Returns the statistics of the pool.
+func (i *InstrumentedDatabase) Stats() sql.DBStats {
+	return i.db.Stats()
+}
+
+// measure runs an operation, hands it to the sinks, and logs it if it is slow.
+// The rows fn returns are discarded if it fails.
+func (i *InstrumentedDatabase) measure(ctx context.Context, name string, entity interface{}, q string, fn func() (int64, error)) error {
+	start := i.now()
+	rows, err := fn()
+	if err != nil {
+		rows = 0
+	}
+	op := Operation{Name: name, Table: i.table(entity), Query: q, Rows: rows, Start: start, Duration: i.now().Sub(start), Err: err}
+	for _, sink := range i.sinks {
+		sink.Record(ctx, op)
+	}
+	if i.slow > 0 && op.Duration >= i.slow {
+		i.logger.Printf("Slow database operation: %s", op)
+	}
+	return err
+}
+
+// table returns the table of the records of the entity, or an empty string if it has none.
+func (i *InstrumentedDatabase) table(entity interface{}) string {
+	if entity == nil {
+		return ""
+	}
+	s, err := records.Parse(entity, &i.schemas)
+	if err != nil {
+		return ""
+	}
+	return s.Table
+}
+
+// String formats the operation for the logs.
+func (op Operation) String() string {
+	text := op.Name
+	if op.Table != "" {
+		text += " " + op.Table
+	}
+	if op.Query != "" {
+		text += " where " + op.Query
+	}
+	text += fmt.Sprintf(" took %s, %d rows", op.Duration, op.Rows)
+	if op.Err != nil {
+		text += fmt.Sprintf(", error: %v", op.Err)
+	}
+	return text
+}
+
+// length returns the number of records of a pointer to a slice.
+func length(entity interface{}) int64 {
+	value := reflect.Indirect(reflect.ValueOf(entity))
+	if value.Kind() != reflect.Slice {
+		return 0
+	}
+	return int64(value.Len())
+}
+
+// describe formats a query with its values redacted.
+func describe(q query.Query) string {
+	parts := []string{redact(q.Where)}
+	if len(q.OrderBy) > 0 {
+		orders := make([]string, 0, len(q.OrderBy))
+		for _, order := range q.OrderBy {
+			if order.Desc {
+				orders = append(orders, order.Column+" DESC")
+			} else {
+				orders = append(orders, order.Column)
+			}
+		}
+		parts = append(parts, "ORDER BY "+strings.Join(orders, ", "))
+	}
+	if len(q.After) > 0 {
+		parts = append(parts, "AFTER ?")
+	}
+	if q.Limit > 0 {
+		parts = append(parts, fmt.Sprintf("LIMIT %d", q.Limit))
+	}
+	if q.Offset > 0 {
+		parts = append(parts, fmt.Sprintf("OFFSET %d", q.Offset))
+	}
+	return strings.TrimSpace(strings.Join(parts, " "))
+}
+
+// redact formats a condition with its values replaced by placeholders, e.g. "email = ?".
+// A nil condition is formatted as an empty string.
+func redact(cond query.Condition) string {
+	switch c := cond.(type) {
+	case nil:
+		return ""
+	case query.Comparison:
+		return fmt.Sprintf("%s %s ?", c.Column, c.Operator)
+	case query.Membership:
+		return fmt.Sprintf("%s IN (%s)", c.Column, strings.TrimSuffix(strings.Repeat("?, ", len(c.Values)), ", "))
+	case query.Pattern:
+		return c.Column + " LIKE ?"
+	case query.Between:
+		var bounds []string
+		if c.From != nil {
+			bounds = append(bounds, c.Column+" >= ?")
+		}
+		if c.To != nil {
+			bounds = append(bounds, c.Column+" < ?")
+		}
+		return strings.Join(bounds, " AND ")
+	case query.Conjunction:
+		return join(c, " AND ")
+	case query.Disjunction:
+		return join(c, " OR ")
+	default:
+		return fmt.Sprintf("%T", cond)
+	}
+}
+
+// join formats the conditions of a conjunction or a disjunction.
+func join(conditions []query.Condition, separator string) string {
+	parts := make([]string, 0, len(conditions))
+	for _, c := range conditions {
+		parts = append(parts, redact(c))
+	}
+	return "(" + strings.Join(parts, separator) + ")"
+}
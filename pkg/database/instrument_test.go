@@ -0,0 +1,78 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/memory"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentedDatabaseRecordsOperations(t *testing.T) {
+	var ops []Operation
+	db := NewInstrumentedDatabase(memory.NewDatabase(), 0, nil, SinkFunc(func(ctx context.Context, op Operation) {
+		ops = append(ops, op)
+	}))
+	ctx := context.Background()
+
+	require.NoError(t, db.Create(ctx, newReplicaUser("alice")))
+	require.NoError(t, db.Create(ctx, newReplicaUser("bob")))
+	var users []entities.User
+	require.NoError(t, db.Find(ctx, &users, query.Where(query.Like("email", "%@example.com")).OrderedBy(query.Desc("username")).Window(10, 0)))
+	var user entities.User
+	assert.ErrorIs(t, db.Read(ctx, &user, query.Eq("email", "carol@example.com")), ErrNotFound)
+	removed, err := db.DeleteWhere(ctx, entities.User{}, query.In("username", []string{"alice", "bob"}))
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, removed)
+
+	require.Len(t, ops, 5)
+	assert.Equal(t, "create", ops[0].Name)
+	assert.Equal(t, "users", ops[0].Table)
+	assert.EqualValues(t, 1, ops[0].Rows)
+	assert.Equal(t, "find", ops[2].Name)
+	assert.Equal(t, "email LIKE ? ORDER BY username DESC LIMIT 10", ops[2].Query)
+	assert.EqualValues(t, 2, ops[2].Rows)
+	assert.Equal(t, "email = ?", ops[3].Query)
+	assert.ErrorIs(t, ops[3].Err, ErrNotFound)
+	assert.EqualValues(t, 0, ops[3].Rows)
+	assert.Equal(t, "username IN (?, ?)", ops[4].Query)
+	assert.EqualValues(t, 2, ops[4].Rows)
+	for _, op := range ops {
+		assert.False(t, op.Start.IsZero())
+	}
+}
+
+// slowDatabase struct represents a database whose reads move the clock two seconds forward.
+type slowDatabase struct {
+	Database
+	clock *time.Time
+}
+
+func (d slowDatabase) Read(ctx context.Context, entity interface{}, where query.Condition) error {
+	*d.clock = d.clock.Add(2 * time.Second)
+	return d.Database.Read(ctx, entity, where)
+}
+
+func TestInstrumentedDatabaseLogsSlowOperations(t *testing.T) {
+	var logs bytes.Buffer
+	clock := time.Now()
+	db := NewInstrumentedDatabase(slowDatabase{memory.NewDatabase(), &clock}, time.Second, log.New(&logs, "", 0))
+	db.now = func() time.Time { return clock }
+	ctx := context.Background()
+
+	require.NoError(t, db.Create(ctx, newReplicaUser("alice")))
+	assert.Empty(t, logs.String(), "The fast operations are not logged")
+
+	var user entities.User
+	where := query.Or(query.Eq("email", "alice@example.com"), query.Range("created_at", time.Now(), nil))
+	require.NoError(t, db.Read(ctx, &user, where))
+	assert.Contains(t, logs.String(), "Slow database operation: read users where (email = ? OR created_at >= ?) took")
+	assert.Contains(t, logs.String(), "1 rows")
+	assert.NotContains(t, logs.String(), "alice", "The values of the queries are redacted")
+}
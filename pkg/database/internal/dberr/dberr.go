@@ -0,0 +1,88 @@
+// Package dberr provides the errors every database backend maps its native errors to.
+// The database package exports them, the backends cannot import it since it imports them.
+package dberr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned when no record matches.
+	ErrNotFound = errors.New("record not found")
+	// ErrConflict is returned when a record violates a unique constraint. The error is a *ConflictError.
+	ErrConflict = errors.New("record conflicts with an existing record")
+	// ErrTimeout is returned when an operation does not complete in time, e.g. because it waited too long for a lock.
+	ErrTimeout = errors.New("database operation timed out")
+	// ErrStale is returned, wrapped in a *ConflictError, when an update is based on a version of a record that was modified since.
+	ErrStale = errors.New("record was modified since it was read")
+	// ErrSearchNotSupported is returned when a database has no full-text index of the records to search.
+	ErrSearchNotSupported = errors.New("database does not support full-text search")
+)
+
+// ConflictError struct represents the violation of a unique constraint.
+type ConflictError struct {
+	Table      string   // The table of the record, if the database reports it.
+	Fields     []string // The columns of the violated constraint, if the database reports them.
+	Constraint string   // The name of the violated constraint, if the database reports it.
+	Err        error    // The native error of the database, or nil.
+}
+
+// Field returns the first column of the violated constraint, e.g. "email", or an empty string if it is unknown.
+func (e *ConflictError) Field() string {
+	if len(e.Fields) == 0 {
+		return ""
+	}
+	return e.Fields[0]
+}
+
+// Error returns the message of the error, which names the violated columns if they are known.
+func (e *ConflictError) Error() string {
+	switch {
+	case errors.Is(e.Err, ErrStale):
+		return fmt.Sprintf("%v: %v", ErrConflict, ErrStale)
+	case len(e.Fields) > 0:
+		return fmt.Sprintf("%v: %s", ErrConflict, strings.Join(e.Fields, ", "))
+	case e.Constraint != "":
+		return fmt.Sprintf("%v: %s", ErrConflict, e.Constraint)
+	default:
+		return ErrConflict.Error()
+	}
+}
+
+// Is reports whether the target is ErrConflict, so that errors.Is(err, ErrConflict) matches every conflict.
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// Unwrap returns the native error of the database.
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// Stale returns the conflict of an update based on an outdated version of a record.
+// table: The table of the record.
+// Returns a *ConflictError that wraps ErrStale.
+func Stale(table string) error {
+	return &ConflictError{Table: table, Err: ErrStale}
+}
+
+// Timeout wraps an error with ErrTimeout, keeping the original error in the chain.
+func Timeout(err error) error {
+	if err == nil || errors.Is(err, ErrTimeout) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrTimeout, err)
+}
+
+// Context maps the errors of a context that is done: an expired deadline is a timeout, a cancellation is returned as is.
+// err: The error of an operation.
+// Returns the mapped error, or err if it is not an error of a context.
+func Context(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Timeout(err)
+	}
+	return err
+}
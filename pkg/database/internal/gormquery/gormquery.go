@@ -0,0 +1,218 @@
+// Package gormquery provides the statements the SQL databases share: the typed queries compiled to the clauses of GORM,
+// and the compare-and-swap updates of the versioned records.
+// The columns are looked up in the schema of the entity and quoted, and the values are bound, so that no query injects SQL.
+package gormquery
+
+import (
+	"fmt"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/dberr"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/records"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Where adds the condition to the session.
+// db: The session of the operation.
+// entity: The record or records the operation works on, whose schema holds the columns of the condition.
+// where: The condition. A nil condition matches every record, which also allows a delete without a where clause.
+// like: The SQL of a Pattern, whose two ? are the column and the pattern, e.g. "? LIKE ? ESCAPE '\'".
+// Returns the session and an error if the condition refers to an unknown column.
+func Where(db *gorm.DB, entity interface{}, where query.Condition, like string) (*gorm.DB, error) {
+	s, err := parse(db, entity)
+	if err != nil {
+		return nil, err
+	}
+	expr, err := compile(s, where, like)
+	if err != nil {
+		return nil, err
+	}
+	if expr == nil {
+		return db.Session(&gorm.Session{AllowGlobalUpdate: true}), nil
+	}
+	return db.Where(expr), nil
+}
+
+// Find adds the condition, the ordering and the window of the query to the session.
+// db: The session of the operation.
+// entity: The records the query reads.
+// q: The query.
+// like: The SQL of a Pattern, see Where.
+// Returns the session and an error if the query refers to an unknown column or its cursor does not match its ordering.
+func Find(db *gorm.DB, entity interface{}, q query.Query, like string) (*gorm.DB, error) {
+	where, err := q.Condition()
+	if err != nil {
+		return nil, err
+	}
+	db, err = Where(db, entity, where, like)
+	if err != nil {
+		return nil, err
+	}
+	s, err := parse(db, entity)
+	if err != nil {
+		return nil, err
+	}
+	for _, order := range q.OrderBy {
+		column, err := lookUp(s, order.Column)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Order(clause.OrderByColumn{Column: column, Desc: order.Desc})
+	}
+	if q.Limit > 0 {
+		db = db.Limit(q.Limit)
+	}
+	if q.Offset > 0 {
+		db = db.Offset(q.Offset)
+	}
+	return db, nil
+}
+
+// Save writes a record, or adds it if no record has its primary key, as gorm.DB.Save does.
+// A versioned record is only written if the stored record has its version, which is then incremented in the same statement,
+// so that of two concurrent updates based on the same read, the second one fails.
+// db: The session of the operation.
+// entity: A pointer to the record. The new version is written back, and restored if the update fails.
+// Returns dberr.ErrStale, in a *dberr.ConflictError, if the version is outdated, or the error of GORM.
+func Save(db *gorm.DB, entity interface{}) error {
+	s, err := parse(db, entity)
+	if err != nil {
+		return err
+	}
+	field := s.FieldsByDBName[records.VersionColumn]
+	if field == nil {
+		return db.Save(entity).Error
+	}
+
+	ctx := db.Statement.Context
+	row := records.Record(entity)
+	version := field.ReflectValueOf(ctx, row).Int()
+	if err := field.Set(ctx, row, version+1); err != nil {
+		return err
+	}
+	result := db.Select("*").Where(clause.Eq{Column: clause.Column{Name: field.DBName}, Value: version}).Updates(entity)
+	if result.Error == nil && result.RowsAffected == 1 {
+		return nil
+	}
+	if err := field.Set(ctx, row, version); err != nil {
+		return err
+	}
+	if result.Error != nil {
+		return result.Error
+	}
+
+	// No row has both the primary key and the version: the record is either stale or new.
+	var count int64
+	fresh := db.Session(&gorm.Session{NewDB: true})
+	for _, primary := range s.PrimaryFields {
+		value, _ := primary.ValueOf(ctx, row)
+		fresh = fresh.Where(clause.Eq{Column: clause.Column{Name: primary.DBName}, Value: value})
+	}
+	if err := fresh.Model(entity).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return dberr.Stale(s.Table)
+	}
+	return db.Session(&gorm.Session{NewDB: true}).Create(entity).Error
+}
+
+// parse returns the schema of the entity, which GORM caches.
+func parse(db *gorm.DB, entity interface{}) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(entity); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
+// lookUp returns the quoted column of the schema with the name, which is a column or a field name.
+func lookUp(s *schema.Schema, name string) (clause.Column, error) {
+	field := s.LookUpField(name)
+	if field == nil || field.DBName == "" {
+		return clause.Column{}, fmt.Errorf("unknown column %q of table %s", name, s.Table)
+	}
+	return clause.Column{Name: field.DBName}, nil
+}
+
+// compile compiles the condition to an expression, or to nil if it matches every record.
+func compile(s *schema.Schema, cond query.Condition, like string) (clause.Expression, error) {
+	switch c := cond.(type) {
+	case nil:
+		return nil, nil
+	case query.Comparison:
+		column, err := lookUp(s, c.Column)
+		if err != nil {
+			return nil, err
+		}
+		switch c.Operator {
+		case query.Equal, query.NotEqual, query.Less, query.LessOrEqual, query.Greater, query.GreaterOrEqual:
+		default:
+			return nil, fmt.Errorf("unsupported operator %q", c.Operator)
+		}
+		// A bound NULL is never equal to anything, as with the other databases, where clause.Eq would test IS NULL.
+		return clause.Expr{SQL: "? " + string(c.Operator) + " ?", Vars: []interface{}{column, c.Value}}, nil
+	case query.Membership:
+		column, err := lookUp(s, c.Column)
+		if err != nil {
+			return nil, err
+		}
+		return clause.IN{Column: column, Values: c.Values}, nil
+	case query.Pattern:
+		column, err := lookUp(s, c.Column)
+		if err != nil {
+			return nil, err
+		}
+		return clause.Expr{SQL: like, Vars: []interface{}{column, c.Pattern}}, nil
+	case query.Between:
+		if _, err := lookUp(s, c.Column); err != nil {
+			return nil, err
+		}
+		var bounds []query.Condition
+		if c.From != nil {
+			bounds = append(bounds, query.Gte(c.Column, c.From))
+		}
+		if c.To != nil {
+			bounds = append(bounds, query.Lt(c.Column, c.To))
+		}
+		return compile(s, query.And(bounds...), like)
+	case query.Conjunction:
+		var exprs []clause.Expression
+		for _, term := range c {
+			expr, err := compile(s, term, like)
+			if err != nil {
+				return nil, err
+			}
+			if expr != nil {
+				exprs = append(exprs, expr)
+			}
+		}
+		if len(exprs) == 0 {
+			return nil, nil
+		}
+		return clause.And(exprs...), nil
+	case query.Disjunction:
+		if len(c) == 0 {
+			return clause.Expr{SQL: "1 = 0"}, nil
+		}
+		var (
+			exprs []clause.Expression
+			all   bool
+		)
+		for _, term := range c {
+			expr, err := compile(s, term, like)
+			if err != nil {
+				return nil, err
+			}
+			all = all || expr == nil
+			exprs = append(exprs, expr)
+		}
+		if all {
+			return nil, nil
+		}
+		return clause.Or(exprs...), nil
+	default:
+		return nil, fmt.Errorf("unsupported condition %T", cond)
+	}
+}
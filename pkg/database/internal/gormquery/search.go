@@ -0,0 +1,96 @@
+package gormquery
+
+import (
+	"fmt"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"reflect"
+)
+
+// ranked struct represents a record found by a full-text search.
+type ranked struct {
+	ID    string  `gorm:"column:id"`    // The primary key of the record.
+	Score float64 `gorm:"column:score"` // The rank of the record, higher is more relevant.
+}
+
+// Table returns the quoted table of the records of the entity.
+// db: The session of the operation.
+// entity: The records the operation works on.
+// Returns the name of the table, its quoted name, and an error if the entity cannot be parsed.
+func Table(db *gorm.DB, entity interface{}) (string, string, error) {
+	s, err := parse(db, entity)
+	if err != nil {
+		return "", "", err
+	}
+	return s.Table, db.Statement.Quote(s.Table), nil
+}
+
+// Search runs the statements of a full-text search and reads the found records, most relevant first.
+// db: The session of the operation.
+// entity: A pointer to the slice of the records to retrieve.
+// search: The window of the search.
+// unlimited: The LIMIT of a window without a limit, e.g. "-1" or "ALL".
+// selectRanked: The SQL that selects the primary keys of the matching records as "id" and their ranks as "score", most relevant first.
+// count: The SQL that counts the matching records.
+// args: The arguments of both statements.
+// Returns the rank of every record, the number of matching records, and an error if a statement fails.
+func Search(db *gorm.DB, entity interface{}, search query.TextSearch, unlimited, selectRanked, count string, args ...interface{}) ([]float64, int64, error) {
+	s, err := parse(db, entity)
+	if err != nil {
+		return nil, 0, err
+	}
+	if s.PrioritizedPrimaryField == nil {
+		return nil, 0, fmt.Errorf("the table %s has no primary key", s.Table)
+	}
+	records := reflect.ValueOf(entity)
+	if records.Kind() != reflect.Ptr || records.Elem().Kind() != reflect.Slice {
+		return nil, 0, fmt.Errorf("the records of a search must be a pointer to a slice, not %T", entity)
+	}
+	records = records.Elem()
+	records.Set(reflect.MakeSlice(records.Type(), 0, 0))
+	if len(search.Terms) == 0 {
+		return []float64{}, 0, nil
+	}
+
+	var total int64
+	if err := db.Raw(count, args...).Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	limit := unlimited
+	if search.Limit > 0 {
+		limit = fmt.Sprint(search.Limit)
+	}
+	var rows []ranked
+	if err := db.Raw(fmt.Sprintf("%s LIMIT %s OFFSET %d", selectRanked, limit, max(search.Offset, 0)), args...).Scan(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	if len(rows) == 0 {
+		return []float64{}, total, nil
+	}
+
+	keys := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, row.ID)
+	}
+	found := reflect.New(records.Type())
+	primary := clause.Column{Name: s.PrioritizedPrimaryField.DBName}
+	if err := db.Where(clause.IN{Column: primary, Values: keys}).Find(found.Interface()).Error; err != nil {
+		return nil, 0, err
+	}
+	// The records come in the order of the database, and are put back in the order of their ranks.
+	// The records removed since they were ranked are left out.
+	byKey := make(map[string]reflect.Value, found.Elem().Len())
+	for i := 0; i < found.Elem().Len(); i++ {
+		record := found.Elem().Index(i)
+		byKey[fmt.Sprint(s.PrioritizedPrimaryField.ReflectValueOf(db.Statement.Context, reflect.Indirect(record)).Interface())] = record
+	}
+	ranks := make([]float64, 0, len(rows))
+	for _, row := range rows {
+		if record, ok := byKey[row.ID]; ok {
+			records.Set(reflect.Append(records, record))
+			ranks = append(ranks, row.Score)
+		}
+	}
+	return ranks, total, nil
+}
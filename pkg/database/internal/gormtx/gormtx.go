@@ -0,0 +1,70 @@
+// Package gormtx provides the transactions of the GORM based databases, which travel in the context of the operations.
+package gormtx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gorm.io/gorm"
+)
+
+// key is the context key under which the transaction of a database is stored.
+// It holds the database, so that a transaction is never used by another database.
+type key struct {
+	db *gorm.DB
+}
+
+// Isolation returns the isolation level of the configured name.
+// name: The name of the isolation level, e.g. "read_committed". An empty name is the default level of the database.
+// Returns the isolation level and an error if the name is unknown.
+func Isolation(name string) (sql.IsolationLevel, error) {
+	switch name {
+	case "":
+		return sql.LevelDefault, nil
+	case "read_uncommitted":
+		return sql.LevelReadUncommitted, nil
+	case "read_committed":
+		return sql.LevelReadCommitted, nil
+	case "repeatable_read":
+		return sql.LevelRepeatableRead, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	default:
+		return sql.LevelDefault, fmt.Errorf("unknown isolation level %q", name)
+	}
+}
+
+// Conn returns the session the operations of the context run in: the transaction carried by the context, or the database.
+// ctx: The context for the operation.
+// db: The database.
+// Returns the session, bound to the context.
+func Conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(key{db: db}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
+// WithTx runs fn in a transaction of the database, or in a savepoint if the context already carries a transaction.
+// ctx: The context for the operation.
+// db: The database.
+// isolation: The configured isolation level, used unless opts sets one.
+// fn: The function to run in the transaction.
+// opts: The options of the transaction. They are ignored by nested calls.
+// Returns the error returned by fn, or an error if the transaction cannot be started or committed.
+func WithTx(ctx context.Context, db *gorm.DB, isolation sql.IsolationLevel, fn func(ctx context.Context) error, opts ...*sql.TxOptions) error {
+	if tx, ok := ctx.Value(key{db: db}).(*gorm.DB); ok {
+		// GORM rolls back to a savepoint when Transaction is called on a transaction.
+		return tx.WithContext(ctx).Transaction(func(*gorm.DB) error {
+			return fn(ctx)
+		})
+	}
+
+	options := &sql.TxOptions{Isolation: isolation}
+	if len(opts) > 0 && opts[0] != nil {
+		options = opts[0]
+	}
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, key{db: db}, tx))
+	}, options)
+}
@@ -0,0 +1,368 @@
+// Package records provides the functionality the key-value databases share to store the entities as records:
+// the GORM schema of the entities, the compiled conditions and orderings of the queries, and the unique constraints.
+package records
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"gorm.io/gorm/schema"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Condition is a compiled query condition, bound to its values.
+type Condition interface {
+	// Match reports whether the record matches the condition.
+	// ctx: The context for the operation.
+	// row: The record to match.
+	// Returns an error if a column cannot be compared with its value.
+	Match(ctx context.Context, row reflect.Value) (bool, error)
+}
+
+// always is the condition of an empty query, which matches every row.
+type always struct{}
+
+func (always) Match(context.Context, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+// and is the conjunction of two conditions.
+type and struct {
+	left, right Condition
+}
+
+func (c and) Match(ctx context.Context, row reflect.Value) (bool, error) {
+	matches, err := c.left.Match(ctx, row)
+	if err != nil || !matches {
+		return false, err
+	}
+	return c.right.Match(ctx, row)
+}
+
+// or is the disjunction of two conditions.
+type or struct {
+	left, right Condition
+}
+
+func (c or) Match(ctx context.Context, row reflect.Value) (bool, error) {
+	matches, err := c.left.Match(ctx, row)
+	if err != nil || matches {
+		return matches, err
+	}
+	return c.right.Match(ctx, row)
+}
+
+// never is the condition of an empty disjunction, which matches no row.
+type never struct{}
+
+func (never) Match(context.Context, reflect.Value) (bool, error) {
+	return false, nil
+}
+
+// pattern matches a text column with a pattern.
+type pattern struct {
+	field *schema.Field
+	re    *regexp.Regexp
+}
+
+func (c pattern) Match(ctx context.Context, row reflect.Value) (bool, error) {
+	switch column := Normalize(c.field.ReflectValueOf(ctx, row).Interface()).(type) {
+	case nil:
+		return false, nil
+	case string:
+		return c.re.MatchString(column), nil
+	default:
+		return false, fmt.Errorf("cannot match the %T column %s with a pattern", column, c.field.DBName)
+	}
+}
+
+// comparison compares a column to a value, e.g. "email = ?".
+type comparison struct {
+	field    *schema.Field
+	operator string
+	value    interface{}
+}
+
+func (c comparison) Match(ctx context.Context, row reflect.Value) (bool, error) {
+	column := c.field.ReflectValueOf(ctx, row).Interface()
+
+	if c.operator == "in" {
+		values := reflect.ValueOf(c.value)
+		for i := 0; i < values.Len(); i++ {
+			cmp, err := Compare(column, values.Index(i).Interface())
+			if err != nil {
+				return false, err
+			}
+			if cmp == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	if Normalize(column) == nil || Normalize(c.value) == nil {
+		// As in SQL, a comparison with NULL is never true.
+		return false, nil
+	}
+	cmp, err := Compare(column, c.value)
+	if err != nil {
+		return false, err
+	}
+	switch c.operator {
+	case "=":
+		return cmp == 0, nil
+	case "<>", "!=":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+// Compile compiles a typed condition to a predicate on the records.
+// s: The schema of the table the columns belong to.
+// cond: The condition. A nil condition matches every record.
+// Returns the condition and an error if it refers to an unknown column or an IN has no values to compare.
+func Compile(s *schema.Schema, cond query.Condition) (Condition, error) {
+	switch c := cond.(type) {
+	case nil:
+		return always{}, nil
+	case query.Comparison:
+		field, err := lookUp(s, c.Column)
+		if err != nil {
+			return nil, err
+		}
+		switch c.Operator {
+		case query.Equal, query.NotEqual, query.Less, query.LessOrEqual, query.Greater, query.GreaterOrEqual:
+		default:
+			return nil, fmt.Errorf("unsupported operator %q", c.Operator)
+		}
+		return comparison{field: field, operator: string(c.Operator), value: c.Value}, nil
+	case query.Membership:
+		field, err := lookUp(s, c.Column)
+		if err != nil {
+			return nil, err
+		}
+		return comparison{field: field, operator: "in", value: c.Values}, nil
+	case query.Pattern:
+		field, err := lookUp(s, c.Column)
+		if err != nil {
+			return nil, err
+		}
+		return pattern{field: field, re: like(c.Pattern)}, nil
+	case query.Between:
+		var bounds []query.Condition
+		if c.From != nil {
+			bounds = append(bounds, query.Gte(c.Column, c.From))
+		}
+		if c.To != nil {
+			bounds = append(bounds, query.Lt(c.Column, c.To))
+		}
+		if _, err := lookUp(s, c.Column); err != nil {
+			return nil, err
+		}
+		return Compile(s, query.And(bounds...))
+	case query.Conjunction:
+		var compiled Condition = always{}
+		for i, term := range c {
+			right, err := Compile(s, term)
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 {
+				compiled = right
+			} else {
+				compiled = and{left: compiled, right: right}
+			}
+		}
+		return compiled, nil
+	case query.Disjunction:
+		var compiled Condition = never{}
+		for i, term := range c {
+			right, err := Compile(s, term)
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 {
+				compiled = right
+			} else {
+				compiled = or{left: compiled, right: right}
+			}
+		}
+		return compiled, nil
+	default:
+		return nil, fmt.Errorf("unsupported condition %T", cond)
+	}
+}
+
+// lookUp returns the field of the column.
+// Returns an error if the table has no such column.
+func lookUp(s *schema.Schema, column string) (*schema.Field, error) {
+	field := s.LookUpField(column)
+	if field == nil || field.DBName == "" {
+		return nil, fmt.Errorf("unknown column %q of table %s", column, s.Table)
+	}
+	return field, nil
+}
+
+// like converts a pattern of a query.Pattern to a regular expression, which ignores the case like the SQL databases.
+func like(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString(`(?is)^`)
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			b.WriteString(`.*`)
+		case r == '_':
+			b.WriteString(`.`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString(`$`)
+	return regexp.MustCompile(b.String())
+}
+
+// Equality returns the value the condition requires the column to be equal to, if every matching record
+// has to satisfy "column = ?". The databases use it to look records up by a key instead of scanning the table.
+// cond: The condition.
+// field: The column.
+// Returns the value and true if the condition requires the equality, or false otherwise.
+func Equality(cond Condition, field *schema.Field) (interface{}, bool) {
+	switch c := cond.(type) {
+	case comparison:
+		if c.field == field && c.operator == "=" {
+			return c.value, true
+		}
+	case and:
+		if value, ok := Equality(c.left, field); ok {
+			return value, true
+		}
+		return Equality(c.right, field)
+	}
+	return nil, false
+}
+
+// isBytes reports whether the value is a byte slice, which is compared as a string rather than as a list.
+func isBytes(value interface{}) bool {
+	_, ok := value.([]byte)
+	return ok
+}
+
+// Normalize converts a value to one of the types Compare works on: nil, string, int64, float64, bool or time.Time.
+// Values implementing driver.Valuer, such as UUIDs, are converted to the value they are stored as.
+func Normalize(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	if t, ok := value.(time.Time); ok {
+		return t
+	}
+	if valuer, ok := value.(driver.Valuer); ok {
+		v := reflect.ValueOf(value)
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return nil
+		}
+		stored, err := valuer.Value()
+		if err != nil {
+			return value
+		}
+		if _, again := stored.(driver.Valuer); again {
+			return stored
+		}
+		return Normalize(stored)
+	}
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return Normalize(v.Elem().Interface())
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	return value
+}
+
+// Compare compares two values after normalizing them.
+// Returns a negative number, zero or a positive number if the first value is lower than, equal to or greater than
+// the second value, and an error if the values cannot be compared.
+func Compare(a, b interface{}) (int, error) {
+	na, nb := Normalize(a), Normalize(b)
+	switch x := na.(type) {
+	case string:
+		if y, ok := nb.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	case int64:
+		switch y := nb.(type) {
+		case int64:
+			return compareOrdered(x, y), nil
+		case float64:
+			return compareOrdered(float64(x), y), nil
+		}
+	case float64:
+		switch y := nb.(type) {
+		case float64:
+			return compareOrdered(x, y), nil
+		case int64:
+			return compareOrdered(x, float64(y)), nil
+		}
+	case bool:
+		if y, ok := nb.(bool); ok {
+			switch {
+			case x == y:
+				return 0, nil
+			case !x:
+				return -1, nil
+			default:
+				return 1, nil
+			}
+		}
+	case time.Time:
+		if y, ok := nb.(time.Time); ok {
+			return x.Compare(y), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %T with %T", a, b)
+}
+
+// compareOrdered compares two numbers.
+func compareOrdered[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
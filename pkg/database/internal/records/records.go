@@ -0,0 +1,335 @@
+package records
+
+import (
+	"context"
+	"fmt"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/dberr"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"gorm.io/gorm/schema"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VersionColumn is the column of the versions of the versioned records, which every update compares and increments.
+const VersionColumn = "version"
+
+// Constraint struct represents a unique constraint of a table.
+type Constraint struct {
+	Name   string          // The name of the constraint.
+	Fields []*schema.Field // The columns of the constraint.
+}
+
+// Parse returns the GORM schema of the entity type.
+// entity: A record, a slice of records or a pointer to either.
+// cache: The cache of the parsed schemas.
+// Returns the schema and an error if the entity type cannot be parsed.
+func Parse(entity interface{}, cache *sync.Map) (*schema.Schema, error) {
+	return schema.Parse(entity, cache, schema.NamingStrategy{})
+}
+
+// Record returns the struct value of the entity. It is addressable if the entity is a pointer,
+// and an addressable copy otherwise, since the generated values cannot be written back to a value.
+// entity: The record.
+// Returns the struct value.
+func Record(entity interface{}) reflect.Value {
+	value := reflect.ValueOf(entity)
+	if value.Kind() == reflect.Ptr {
+		return value.Elem()
+	}
+	copied := reflect.New(value.Type()).Elem()
+	copied.Set(value)
+	return copied
+}
+
+// New returns a new empty addressable record of the schema.
+func New(s *schema.Schema) reflect.Value {
+	return reflect.New(s.ModelType).Elem()
+}
+
+// Copy copies the columns of a record to another record. Fields that are not columns are left unchanged.
+// ctx: The context for the operation.
+// s: The schema of the records.
+// dst: The addressable record to copy to.
+// src: The record to copy from.
+// Returns an error if a column cannot be set.
+func Copy(ctx context.Context, s *schema.Schema, dst, src reflect.Value) error {
+	for _, field := range s.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		if err := field.Set(ctx, dst, field.ReflectValueOf(ctx, src).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Touch sets the automatic timestamps of a record, as GORM does: a new record gets the creation and update times
+// it does not have yet, and a modified record gets a new update time. A new versioned record without a version gets the first one.
+// ctx: The context for the operation.
+// s: The schema of the record.
+// value: The addressable record.
+// created: Whether the record is new.
+// Returns an error if a timestamp cannot be set.
+func Touch(ctx context.Context, s *schema.Schema, value reflect.Value, created bool) error {
+	// The monotonic clock reading is stripped, as a round trip through a database would.
+	now := time.Now().Round(0)
+	for _, field := range s.Fields {
+		unit := field.AutoUpdateTime
+		if created && field.AutoCreateTime > 0 {
+			unit = field.AutoCreateTime
+		}
+		if unit == 0 {
+			continue
+		}
+		if _, zero := field.ValueOf(ctx, value); created && !zero {
+			continue
+		}
+		var err error
+		switch unit {
+		case schema.UnixNanosecond:
+			err = field.Set(ctx, value, now.UnixNano())
+		case schema.UnixMillisecond:
+			err = field.Set(ctx, value, now.UnixMilli())
+		case schema.UnixSecond:
+			err = field.Set(ctx, value, now.Unix())
+		default:
+			err = field.Set(ctx, value, now)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if field := versionField(s); created && field != nil {
+		if _, zero := field.ValueOf(ctx, value); zero {
+			return field.Set(ctx, value, 1)
+		}
+	}
+	return nil
+}
+
+// CheckVersion checks that a record to write is based on the version of the stored record, so that an update
+// does not overwrite a modification it has not seen.
+// ctx: The context for the operation.
+// s: The schema of the records.
+// value: The record to write.
+// stored: The stored record with the same primary key.
+// Returns dberr.ErrStale, in a *dberr.ConflictError, if the versions differ.
+func CheckVersion(ctx context.Context, s *schema.Schema, value, stored reflect.Value) error {
+	field := versionField(s)
+	if field == nil {
+		return nil
+	}
+	if field.ReflectValueOf(ctx, value).Int() != field.ReflectValueOf(ctx, stored).Int() {
+		return dberr.Stale(s.Table)
+	}
+	return nil
+}
+
+// NextVersion increments the version of a record to write, once the update is known to succeed.
+// ctx: The context for the operation.
+// s: The schema of the record.
+// value: The addressable record.
+// Returns an error if the version cannot be set.
+func NextVersion(ctx context.Context, s *schema.Schema, value reflect.Value) error {
+	field := versionField(s)
+	if field == nil {
+		return nil
+	}
+	return field.Set(ctx, value, field.ReflectValueOf(ctx, value).Int()+1)
+}
+
+// versionField returns the version field of the schema, or nil if its records are not versioned.
+func versionField(s *schema.Schema) *schema.Field {
+	return s.FieldsByDBName[VersionColumn]
+}
+
+// ComparePrimaryKeys compares the primary keys of two records.
+// Returns a negative number, zero or a positive number if the first key is lower than, equal to or greater than the second key.
+func ComparePrimaryKeys(ctx context.Context, s *schema.Schema, a, b reflect.Value) int {
+	for _, field := range s.PrimaryFields {
+		c, err := Compare(field.ReflectValueOf(ctx, a).Interface(), field.ReflectValueOf(ctx, b).Interface())
+		if err == nil && c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// UniqueConstraints returns the unique constraints of a table: the primary key, the unique columns and the unique indexes.
+func UniqueConstraints(s *schema.Schema) []Constraint {
+	var constraints []Constraint
+	if len(s.PrimaryFields) > 0 {
+		constraints = append(constraints, Constraint{Name: "primary", Fields: s.PrimaryFields})
+	}
+	for _, field := range s.Fields {
+		if field.Unique && !field.PrimaryKey {
+			constraints = append(constraints, Constraint{Name: "uni_" + s.Table + "_" + field.DBName, Fields: []*schema.Field{field}})
+		}
+	}
+	indexes := s.ParseIndexes()
+	names := make([]string, 0, len(indexes))
+	for name, index := range indexes {
+		if index.Class == "UNIQUE" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fields := make([]*schema.Field, 0, len(indexes[name].Fields))
+		for _, option := range indexes[name].Fields {
+			fields = append(fields, option.Field)
+		}
+		constraints = append(constraints, Constraint{Name: name, Fields: fields})
+	}
+	return constraints
+}
+
+// Values returns the values of the columns of the constraint in the record.
+// ctx: The context for the operation.
+// row: The record.
+// Returns the values, and false if one of them is NULL, since NULL values never conflict.
+func (c Constraint) Values(ctx context.Context, row reflect.Value) ([]interface{}, bool) {
+	values := make([]interface{}, 0, len(c.Fields))
+	for _, field := range c.Fields {
+		value := Normalize(field.ReflectValueOf(ctx, row).Interface())
+		if value == nil {
+			return nil, false
+		}
+		values = append(values, value)
+	}
+	return values, true
+}
+
+// Conflicts reports whether two records have the same values in the columns of the constraint.
+func (c Constraint) Conflicts(ctx context.Context, a, b reflect.Value) bool {
+	for _, field := range c.Fields {
+		cmp, err := Compare(field.ReflectValueOf(ctx, a).Interface(), field.ReflectValueOf(ctx, b).Interface())
+		if err != nil || cmp != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Conflict returns the error of a record that violates the constraint.
+func (c Constraint) Conflict() error {
+	err := &dberr.ConflictError{Constraint: c.Name}
+	for _, field := range c.Fields {
+		err.Table = field.Schema.Table
+		err.Fields = append(err.Fields, field.DBName)
+	}
+	return err
+}
+
+// String returns the table and the columns of the constraint, for the error messages.
+func (c Constraint) String() string {
+	names := make([]string, 0, len(c.Fields))
+	for _, field := range c.Fields {
+		names = append(names, field.DBName)
+	}
+	if len(c.Fields) == 0 {
+		return c.Name
+	}
+	return c.Fields[0].Schema.Table + "." + strings.Join(names, ", ")
+}
+
+// Sort sorts the records by the orderings. Records with equal keys keep their order.
+// ctx: The context for the operation.
+// s: The schema of the records.
+// rows: The records to sort.
+// orders: The orderings of the records. No ordering keeps the order of the records.
+// Returns an error if an ordering refers to an unknown column.
+func Sort(ctx context.Context, s *schema.Schema, rows []reflect.Value, orders []query.Order) error {
+	type key struct {
+		field *schema.Field
+		desc  bool
+	}
+	var keys []key
+	for _, order := range orders {
+		field, err := lookUp(s, order.Column)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key{field: field, desc: order.Desc})
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, k := range keys {
+			c, err := Compare(k.field.ReflectValueOf(ctx, rows[i]).Interface(), k.field.ReflectValueOf(ctx, rows[j]).Interface())
+			if err != nil || c == 0 {
+				continue
+			}
+			if k.desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+	return nil
+}
+
+// Page returns the records of a page.
+// rows: The records.
+// limit: The maximum number of records. Zero or a negative value means no limit.
+// offset: The number of records to skip.
+func Page(rows []reflect.Value, limit int, offset int) []reflect.Value {
+	if offset > 0 {
+		if offset > len(rows) {
+			offset = len(rows)
+		}
+		rows = rows[offset:]
+	}
+	if limit > 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return rows
+}
+
+// CheckSlice checks that the entity is a pointer to a slice, which the records are assigned to.
+func CheckSlice(entity interface{}) error {
+	dest := reflect.ValueOf(entity)
+	if dest.Kind() != reflect.Ptr || dest.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("expected a pointer to a slice, got %T", entity)
+	}
+	return nil
+}
+
+// Assign replaces the content of the slice the entity points to by copies of the records.
+// ctx: The context for the operation.
+// s: The schema of the records.
+// entity: The pointer to a slice of records or of pointers to records.
+// rows: The records.
+// Returns an error if a column cannot be set.
+func Assign(ctx context.Context, s *schema.Schema, entity interface{}, rows []reflect.Value) error {
+	if err := CheckSlice(entity); err != nil {
+		return err
+	}
+	slice := reflect.ValueOf(entity).Elem()
+	elemType := slice.Type().Elem()
+	result := reflect.MakeSlice(slice.Type(), 0, len(rows))
+	for _, row := range rows {
+		var elem reflect.Value
+		if elemType.Kind() == reflect.Ptr {
+			elem = reflect.New(elemType.Elem())
+		} else {
+			elem = reflect.New(elemType)
+		}
+		if err := Copy(ctx, s, elem.Elem(), row); err != nil {
+			return err
+		}
+		if elemType.Kind() != reflect.Ptr {
+			elem = elem.Elem()
+		}
+		result = reflect.Append(result, elem)
+	}
+	slice.Set(result)
+	return nil
+}
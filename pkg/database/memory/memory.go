@@ -0,0 +1,449 @@
+// Package memory provides an in-memory implementation of the database, for tests and demos.
+// It needs no cgo and no server, and it keeps the records only as long as the process runs.
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/dberr"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/records"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"gorm.io/gorm/schema"
+	"reflect"
+	"sync"
+)
+
+// Database struct represents an in-memory database.
+// The tables are derived from the GORM schema of the entities, so that the column names, the primary keys,
+// the unique constraints and the automatic timestamps are the same as in the SQL databases.
+// A transaction holds the lock of the database until it ends, so the transactions are serializable.
+type Database struct {
+	mu      sync.RWMutex
+	schemas sync.Map
+	tables  map[string]*table
+}
+
+// ErrReadOnly is returned when a read-only transaction modifies the database.
+var ErrReadOnly = errors.New("transaction is read-only")
+
+// txKey is the context key under which the transaction of a database is stored.
+type txKey struct {
+	db *Database
+}
+
+// transaction struct represents a transaction, which holds the lock of the database until it is done.
+type transaction struct {
+	readOnly bool
+	done     bool
+}
+
+// table struct represents the records of one entity type.
+type table struct {
+	schema *schema.Schema
+	rows   []reflect.Value
+	seq    int64
+}
+
+// NewDatabase creates a new empty in-memory database.
+// Returns a Database object.
+func NewDatabase() *Database {
+	return &Database{tables: map[string]*table{}}
+}
+
+// Create adds a new record to the in-memory database.
+// The generated primary key and timestamps are written back to the entity if it is a pointer.
+// ctx: The context for the operation.
+// entity: The record to add.
+// Returns database.ErrConflict if the record violates a unique constraint, or an error if the operation fails.
+func (d *Database) Create(ctx context.Context, entity interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return dberr.Context(err)
+	}
+	unlock, err := d.lock(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	t, err := d.table(entity, true)
+	if err != nil {
+		return err
+	}
+	return t.insert(ctx, records.Record(entity))
+}
+
+// Read retrieves the matching record with the lowest primary key from the in-memory database.
+// ctx: The context for the operation.
+// entity: The record to retrieve.
+// where: The condition to match. A nil condition matches every record.
+// Returns database.ErrNotFound if no record matches, or an error if the operation fails.
+func (d *Database) Read(ctx context.Context, entity interface{}, where query.Condition) error {
+	if err := ctx.Err(); err != nil {
+		return dberr.Context(err)
+	}
+	unlock, err := d.lock(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	t, err := d.table(entity, false)
+	if err != nil {
+		return err
+	}
+	rows, err := t.match(ctx, where)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return dberr.ErrNotFound
+	}
+	first := rows[0]
+	for _, row := range rows[1:] {
+		if records.ComparePrimaryKeys(ctx, t.schema, row, first) < 0 {
+			first = row
+		}
+	}
+	return records.Copy(ctx, t.schema, records.Record(entity), first)
+}
+
+// Update modifies a record in the in-memory database, or adds it if no record has its primary key.
+// ctx: The context for the operation.
+// entity: The record to modify. The version of a versioned record must be the stored one, and is incremented.
+// Returns database.ErrConflict if the record violates a unique constraint or is based on an outdated version, or an error if the operation fails.
+func (d *Database) Update(ctx context.Context, entity interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return dberr.Context(err)
+	}
+	unlock, err := d.lock(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	t, err := d.table(entity, true)
+	if err != nil {
+		return err
+	}
+	value := records.Record(entity)
+	index := t.find(ctx, value)
+	if index < 0 {
+		return t.insert(ctx, value)
+	}
+	if err := records.CheckVersion(ctx, t.schema, value, t.rows[index]); err != nil {
+		return err
+	}
+	if err := records.Touch(ctx, t.schema, value, false); err != nil {
+		return err
+	}
+	if err := t.checkUnique(ctx, value, index); err != nil {
+		return err
+	}
+	if err := records.NextVersion(ctx, t.schema, value); err != nil {
+		return err
+	}
+	// The row is replaced rather than modified, since the snapshots of the transactions share the rows.
+	row := records.New(t.schema)
+	if err := records.Copy(ctx, t.schema, row, value); err != nil {
+		return err
+	}
+	t.rows[index] = row
+	return nil
+}
+
+// Delete removes a record from the in-memory database.
+// ctx: The context for the operation.
+// entity: The record to remove.
+// id: The id of the record to remove.
+// Returns an error if the operation fails.
+func (d *Database) Delete(ctx context.Context, entity interface{}, id interface{}) error {
+	_, err := d.DeleteWhere(ctx, entity, query.Eq("id", id))
+	return err
+}
+
+// ReadAll retrieves all records from the in-memory database, in the order they were added.
+// ctx: The context for the operation.
+// entity: The records to retrieve.
+// Returns an error if the operation fails.
+func (d *Database) ReadAll(ctx context.Context, entity interface{}) error {
+	return d.Find(ctx, entity, query.Query{})
+}
+
+// Find retrieves the records the query selects from the in-memory database.
+// ctx: The context for the operation.
+// entity: The records to retrieve.
+// q: The condition, ordering and window of the records. Without an ordering, the records are in the order they were added in.
+// Returns an error if the operation fails.
+func (d *Database) Find(ctx context.Context, entity interface{}, q query.Query) error {
+	if err := ctx.Err(); err != nil {
+		return dberr.Context(err)
+	}
+	if err := records.CheckSlice(entity); err != nil {
+		return err
+	}
+	unlock, err := d.lock(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	t, err := d.table(entity, false)
+	if err != nil {
+		return err
+	}
+	where, err := q.Condition()
+	if err != nil {
+		return err
+	}
+	rows, err := t.match(ctx, where)
+	if err != nil {
+		return err
+	}
+	if err := records.Sort(ctx, t.schema, rows, q.OrderBy); err != nil {
+		return err
+	}
+	return records.Assign(ctx, t.schema, entity, records.Page(rows, q.Limit, q.Offset))
+}
+
+// DeleteWhere removes the records matching the condition from the in-memory database.
+// ctx: The context for the operation.
+// entity: The type of the records to remove.
+// where: The condition to match. A nil condition matches every record.
+// Returns the number of removed records and an error if the operation fails.
+func (d *Database) DeleteWhere(ctx context.Context, entity interface{}, where query.Condition) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, dberr.Context(err)
+	}
+	unlock, err := d.lock(ctx, true)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	t, err := d.table(entity, true)
+	if err != nil {
+		return 0, err
+	}
+	cond, err := records.Compile(t.schema, where)
+	if err != nil {
+		return 0, err
+	}
+	kept := make([]reflect.Value, 0, len(t.rows))
+	for _, row := range t.rows {
+		matches, err := cond.Match(ctx, row)
+		if err != nil {
+			return 0, err
+		}
+		if !matches {
+			kept = append(kept, row)
+		}
+	}
+	removed := int64(len(t.rows) - len(kept))
+	t.rows = kept
+	return removed, nil
+}
+
+// Stats reports the usage of the connection pool of the in-memory database, which has none.
+// Returns zero statistics.
+func (d *Database) Stats() sql.DBStats {
+	return sql.DBStats{}
+}
+
+// WithTx runs fn in a transaction of the in-memory database, or in a savepoint if the context already carries a transaction.
+// The transaction holds the lock of the database, so operations with a context that does not carry it wait until it ends.
+// ctx: The context for the operation.
+// fn: The function to run in the transaction.
+// opts: The options of the transaction. Only the read-only flag is used, since the transactions are always serializable.
+// Returns the error returned by fn, or an error if the transaction cannot be started.
+func (d *Database) WithTx(ctx context.Context, fn func(ctx context.Context) error, opts ...*sql.TxOptions) error {
+	if err := ctx.Err(); err != nil {
+		return dberr.Context(err)
+	}
+	if tx := d.tx(ctx); tx != nil {
+		return d.savepoint(tx, func() error { return fn(ctx) })
+	}
+
+	tx := &transaction{readOnly: len(opts) > 0 && opts[0] != nil && opts[0].ReadOnly}
+	if tx.readOnly {
+		d.mu.RLock()
+		defer d.mu.RUnlock()
+	} else {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+	}
+	defer func() { tx.done = true }()
+	return d.savepoint(tx, func() error {
+		return fn(context.WithValue(ctx, txKey{db: d}, tx))
+	})
+}
+
+// savepoint runs fn and rolls the changes it made back if it fails or panics.
+// tx: The transaction fn runs in. The changes of a read-only transaction are rejected, so nothing is rolled back.
+// fn: The function to run.
+// Returns the error returned by fn.
+func (d *Database) savepoint(tx *transaction, fn func() error) (err error) {
+	if tx.readOnly {
+		return fn()
+	}
+	saved := d.snapshot()
+	completed := false
+	defer func() {
+		if !completed || err != nil {
+			d.restore(saved)
+		}
+	}()
+	err = fn()
+	completed = true
+	return err
+}
+
+// lock locks the database for an operation, unless the context carries a transaction, which already holds the lock.
+// ctx: The context for the operation.
+// write: Whether the operation modifies the database.
+// Returns the function that unlocks the database, and an error if the operation writes in a read-only transaction.
+func (d *Database) lock(ctx context.Context, write bool) (func(), error) {
+	if tx := d.tx(ctx); tx != nil {
+		if write && tx.readOnly {
+			return nil, ErrReadOnly
+		}
+		return func() {}, nil
+	}
+	if write {
+		d.mu.Lock()
+		return d.mu.Unlock, nil
+	}
+	d.mu.RLock()
+	return d.mu.RUnlock, nil
+}
+
+// tx returns the open transaction of the database carried by the context, or nil if there is none.
+func (d *Database) tx(ctx context.Context) *transaction {
+	if tx, ok := ctx.Value(txKey{db: d}).(*transaction); ok && !tx.done {
+		return tx
+	}
+	return nil
+}
+
+// snapshot returns the rows and sequences of every table, which restore rolls the database back to.
+// The rows are replaced rather than modified, so copying the slices is enough.
+func (d *Database) snapshot() map[string]table {
+	saved := make(map[string]table, len(d.tables))
+	for name, t := range d.tables {
+		saved[name] = table{rows: append([]reflect.Value(nil), t.rows...), seq: t.seq}
+	}
+	return saved
+}
+
+// restore rolls the database back to a snapshot. The tables created since the snapshot are emptied.
+func (d *Database) restore(saved map[string]table) {
+	for name, t := range d.tables {
+		t.rows, t.seq = saved[name].rows, saved[name].seq
+	}
+}
+
+// table returns the table of the entity type.
+// A table that does not exist yet is created by the writes, which hold the write lock, while the reads, which may
+// run concurrently under the read lock, get an empty table that is not stored.
+// entity: A record, a slice of records or a pointer to either.
+// create: Whether the operation writes, and stores the table it creates.
+// Returns the table and an error if the entity type cannot be parsed.
+func (d *Database) table(entity interface{}, create bool) (*table, error) {
+	s, err := records.Parse(entity, &d.schemas)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := d.tables[s.Table]
+	if !ok {
+		t = &table{schema: s}
+		if create {
+			d.tables[s.Table] = t
+		}
+	}
+	return t, nil
+}
+
+// insert adds a record to the table, after generating its auto-increment primary key and its timestamps.
+// ctx: The context for the operation.
+// value: The record to add.
+// Returns database.ErrConflict if the record violates a unique constraint, or an error if the operation fails.
+func (t *table) insert(ctx context.Context, value reflect.Value) error {
+	if err := records.Touch(ctx, t.schema, value, true); err != nil {
+		return err
+	}
+
+	var id int64
+	pk := t.schema.PrioritizedPrimaryField
+	if pk != nil && pk.AutoIncrement {
+		current, zero := pk.ValueOf(ctx, value)
+		if zero {
+			id = t.seq + 1
+			if err := pk.Set(ctx, value, id); err != nil {
+				return err
+			}
+		} else if n, ok := records.Normalize(current).(int64); ok {
+			id = n
+		}
+	}
+
+	if err := t.checkUnique(ctx, value, -1); err != nil {
+		return err
+	}
+	row := records.New(t.schema)
+	if err := records.Copy(ctx, t.schema, row, value); err != nil {
+		return err
+	}
+	if id > t.seq {
+		t.seq = id
+	}
+	t.rows = append(t.rows, row)
+	return nil
+}
+
+// find returns the index of the row with the primary key of the record, or -1 if there is none.
+func (t *table) find(ctx context.Context, value reflect.Value) int {
+	if len(t.schema.PrimaryFields) == 0 {
+		return -1
+	}
+	for i, row := range t.rows {
+		if records.ComparePrimaryKeys(ctx, t.schema, row, value) == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkUnique checks that the record does not violate a unique constraint of the table.
+// ctx: The context for the operation.
+// value: The record to check.
+// skip: The index of the row the record replaces, or -1 if the record is new.
+// Returns database.ErrConflict if a constraint is violated.
+func (t *table) checkUnique(ctx context.Context, value reflect.Value, skip int) error {
+	for _, constraint := range records.UniqueConstraints(t.schema) {
+		for i, row := range t.rows {
+			if i != skip && constraint.Conflicts(ctx, row, value) {
+				return constraint.Conflict()
+			}
+		}
+	}
+	return nil
+}
+
+// match returns the rows matching the condition, in the order they were added.
+func (t *table) match(ctx context.Context, where query.Condition) ([]reflect.Value, error) {
+	cond, err := records.Compile(t.schema, where)
+	if err != nil {
+		return nil, err
+	}
+	var rows []reflect.Value
+	for _, row := range t.rows {
+		matches, err := cond.Match(ctx, row)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
@@ -0,0 +1,211 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/dberr"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateAndRead(t *testing.T) {
+	db := NewDatabase()
+	ctx := context.Background()
+
+	user := entities.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com"}
+	require.NoError(t, db.Create(ctx, &user))
+	assert.False(t, user.Metadata.CreatedAt.IsZero(), "The creation time must be written back")
+	assert.Equal(t, user.Metadata.CreatedAt, user.Metadata.UpdatedAt)
+
+	var read entities.User
+	require.NoError(t, db.Read(ctx, &read, query.Or(query.Eq("email", "nobody@example.com"), query.Eq("username", "alice"))))
+	assert.Equal(t, user.ID, read.ID)
+	assert.Equal(t, user.Metadata.CreatedAt, read.Metadata.CreatedAt)
+
+	read.Username = "changed"
+	var again entities.User
+	require.NoError(t, db.Read(ctx, &again, query.Eq("id", user.ID)))
+	assert.Equal(t, "alice", again.Username, "Records must not share memory with the callers")
+
+	err := db.Read(ctx, &again, query.Eq("id", uuid.New()))
+	assert.ErrorIs(t, err, dberr.ErrNotFound)
+
+	invitation := entities.Invitation{ID: uuid.New(), Email: "bob@example.com", Token: "token", TokenHash: "hash", Status: entities.InvitationPending}
+	require.NoError(t, db.Create(ctx, &invitation))
+	var invitations []entities.Invitation
+	require.NoError(t, db.ReadAll(ctx, &invitations))
+	require.Len(t, invitations, 1)
+	assert.Empty(t, invitations[0].Status, "Fields that are not columns must not be stored")
+	assert.Empty(t, invitations[0].Token)
+}
+
+func TestConcurrentReads(t *testing.T) {
+	db := NewDatabase()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			var user entities.User
+			assert.ErrorIs(t, db.Read(ctx, &user, query.Eq("username", "alice")), dberr.ErrNotFound)
+		}()
+		go func() {
+			defer wg.Done()
+			var organizations []entities.Organization
+			assert.NoError(t, db.Find(ctx, &organizations, query.Query{}), "the reads of a table that does not exist yet find nothing")
+		}()
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, db.Create(ctx, &entities.Invitation{ID: uuid.New(), Email: fmt.Sprintf("user%d@example.com", i), TokenHash: fmt.Sprint(i)}))
+		}(i)
+	}
+	wg.Wait()
+
+	var invitations []entities.Invitation
+	require.NoError(t, db.ReadAll(ctx, &invitations))
+	assert.Len(t, invitations, 8)
+}
+
+func TestUniqueConstraints(t *testing.T) {
+	db := NewDatabase()
+	ctx := context.Background()
+
+	require.NoError(t, db.Create(ctx, &entities.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com"}))
+	err := db.Create(ctx, &entities.User{ID: uuid.New(), Username: "alice", Email: "other@example.com"})
+	assert.ErrorIs(t, err, dberr.ErrConflict, "Usernames are unique")
+
+	orgID, userID := uuid.New(), uuid.New()
+	require.NoError(t, db.Create(ctx, &entities.Membership{ID: uuid.New(), OrganizationID: orgID, UserID: userID, Role: entities.RoleMember}))
+	err = db.Create(ctx, &entities.Membership{ID: uuid.New(), OrganizationID: orgID, UserID: userID, Role: entities.RoleAdmin})
+	assert.ErrorIs(t, err, dberr.ErrConflict, "The composite unique index must be enforced")
+	assert.NoError(t, db.Create(ctx, &entities.Membership{ID: uuid.New(), OrganizationID: uuid.New(), UserID: userID, Role: entities.RoleMember}))
+
+	bob := entities.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com"}
+	require.NoError(t, db.Create(ctx, &bob))
+	bob.Email = "alice@example.com"
+	assert.ErrorIs(t, db.Update(ctx, &bob), dberr.ErrConflict)
+	bob.Email = "robert@example.com"
+	assert.NoError(t, db.Update(ctx, &bob), "A record must not conflict with itself")
+}
+
+func TestUpdate(t *testing.T) {
+	db := NewDatabase()
+	ctx := context.Background()
+
+	user := entities.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com"}
+	require.NoError(t, db.Create(ctx, &user))
+	created := user.Metadata.UpdatedAt
+	time.Sleep(time.Millisecond)
+
+	user.Username = "alicia"
+	require.NoError(t, db.Update(ctx, &user))
+	assert.True(t, user.Metadata.UpdatedAt.After(created), "The update time must be refreshed")
+
+	var users []entities.User
+	require.NoError(t, db.ReadAll(ctx, &users))
+	require.Len(t, users, 1)
+	assert.Equal(t, "alicia", users[0].Username)
+
+	require.NoError(t, db.Update(ctx, &entities.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com"}))
+	require.NoError(t, db.ReadAll(ctx, &users))
+	assert.Len(t, users, 2, "Updating a missing record must add it")
+}
+
+func TestFind(t *testing.T) {
+	db := NewDatabase()
+	ctx := context.Background()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, actor := range []string{"alice", "bob", "alice", "carol"} {
+		event := entities.AuditEvent{Actor: actor, Action: "login", Outcome: "success", Timestamp: start.Add(time.Duration(i) * time.Hour)}
+		require.NoError(t, db.Create(ctx, &event))
+		assert.Equal(t, uint64(i+1), event.ID, "The IDs must be generated in sequence")
+	}
+
+	var events []entities.AuditEvent
+	require.NoError(t, db.Find(ctx, &events, query.Where(query.Eq("actor", "alice")).OrderedBy(query.Desc("id"))))
+	require.Len(t, events, 2)
+	assert.Equal(t, uint64(3), events[0].ID)
+	assert.Equal(t, uint64(1), events[1].ID)
+
+	require.NoError(t, db.Find(ctx, &events, query.Where(query.And(query.Or(query.Eq("actor", "alice"), query.Eq("actor", "carol")), query.Gte("timestamp", start.Add(time.Hour)))).OrderedBy(query.Asc("id")).Window(2, 1)))
+	require.Len(t, events, 1, "The offset must skip the first match")
+	assert.Equal(t, uint64(4), events[0].ID)
+
+	var pointers []*entities.AuditEvent
+	require.NoError(t, db.Find(ctx, &pointers, query.Where(query.In("actor", []string{"bob", "carol"})).OrderedBy(query.Asc("actor"), query.Desc("id"))))
+	require.Len(t, pointers, 2)
+	assert.Equal(t, "bob", pointers[0].Actor)
+
+	removed, err := db.DeleteWhere(ctx, entities.AuditEvent{}, query.Lt("timestamp", start.Add(2*time.Hour)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), removed)
+
+	require.NoError(t, db.Create(ctx, &entities.AuditEvent{Actor: "dave", Action: "login", Outcome: "success", Timestamp: start}))
+	require.NoError(t, db.Find(ctx, &events, query.Query{}.OrderedBy(query.Desc("id")).Window(1, 0)))
+	assert.Equal(t, uint64(5), events[0].ID, "Deleted IDs must not be reused")
+}
+
+func TestInvalidQueries(t *testing.T) {
+	db := NewDatabase()
+	ctx := context.Background()
+	var users []entities.User
+
+	for _, q := range []query.Query{
+		query.Where(query.Eq("unknown", "alice@example.com")),
+		query.Where(query.Or(query.Eq("email", "alice@example.com"), query.Like("email; DROP TABLE users", "%"))),
+		query.Where(query.Comparison{Column: "email", Operator: "LIKE", Value: "alice@example.com"}),
+		query.Query{}.OrderedBy(query.Desc("unknown")),
+		query.Query{}.OrderedBy(query.Asc("username")).StartAfter("alice", 1),
+	} {
+		assert.Error(t, db.Find(ctx, &users, q), "%+v", q)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	db := NewDatabase()
+	ctx := context.Background()
+	alice := entities.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com"}
+	require.NoError(t, db.Create(ctx, &alice))
+
+	failure := errors.New("failure")
+	err := db.WithTx(ctx, func(ctx context.Context) error {
+		updated := alice
+		updated.Username = "alicia"
+		require.NoError(t, db.Update(ctx, &updated))
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	var read entities.User
+	require.NoError(t, db.Read(ctx, &read, query.Eq("id", alice.ID)))
+	assert.Equal(t, "alice", read.Username, "The updated row must be restored")
+
+	err = db.WithTx(ctx, func(ctx context.Context) error {
+		require.NoError(t, db.Read(ctx, &read, query.Eq("id", alice.ID)))
+		return db.Create(ctx, &entities.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com"})
+	}, &sql.TxOptions{ReadOnly: true})
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	done := make(chan struct{})
+	require.NoError(t, db.WithTx(ctx, func(txCtx context.Context) error {
+		go func() {
+			defer close(done)
+			// An operation outside of the transaction waits until the transaction ends.
+			assert.NoError(t, db.Read(ctx, &entities.User{}, query.Eq("username", "carol")))
+		}()
+		time.Sleep(10 * time.Millisecond)
+		return db.Create(txCtx, &entities.User{ID: uuid.New(), Username: "carol", Email: "carol@example.com"})
+	}))
+	<-done
+}
@@ -0,0 +1,37 @@
+// Package database provides the functionality to migrate the schema of a database.
+package database
+
+import (
+	"errors"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/migrate"
+)
+
+// ErrMigrationsNotSupported is returned when the database has no schema to migrate.
+var ErrMigrationsNotSupported = errors.New("database does not support schema migrations")
+
+// Migratable is an interface implemented by the databases whose schema is created and changed by versioned SQL migrations.
+type Migratable interface {
+	// Migrator returns the migrator of the database with the migrations of the sources.
+	// sources: The migrations of the modules.
+	// Returns a *migrate.Migrator object and an error if the migrations cannot be loaded.
+	Migrator(sources ...migrate.Source) (*migrate.Migrator, error)
+}
+
+// NewMigrator returns the migrator of the database, or of the database it decorates.
+// db: The database to migrate.
+// sources: The migrations of the modules.
+// Returns a *migrate.Migrator object, and ErrMigrationsNotSupported if neither the database nor the databases it decorates have a schema,
+// such as the in-memory and the embedded key-value databases, which create their tables on first use.
+func NewMigrator(db Database, sources ...migrate.Source) (*migrate.Migrator, error) {
+	for db != nil {
+		if migratable, ok := db.(Migratable); ok {
+			return migratable.Migrator(sources...)
+		}
+		wrapper, ok := db.(Wrapper)
+		if !ok {
+			break
+		}
+		db = wrapper.Unwrap()
+	}
+	return nil, ErrMigrationsNotSupported
+}
@@ -0,0 +1,128 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/dberr"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/records"
+	"gorm.io/gorm"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// The error numbers that are mapped to the errors of the database package.
+const (
+	duplicateEntry   = 1062 // A unique key or the primary key is violated.
+	lockWaitTimeout  = 1205 // The innodb_lock_wait_timeout elapsed.
+	statementTimeout = 1969 // The max_statement_time of MariaDB elapsed.
+	queryTimeout     = 3024 // The max_execution_time of MySQL elapsed.
+)
+
+// The messages of a duplicate entry. MySQL and MariaDB name the entry and the key, e.g. "Duplicate entry 'alice' for key 'users.uni_users_username'",
+// while the in-process server of the tests names the kind of key and the values, e.g. "duplicate unique key given: [alice]".
+var (
+	duplicateEntryMessage = regexp.MustCompile(`^Duplicate entry '(.*)' for key '(?:[^']*\.)?([^'.]*)'$`)
+	duplicateKeyMessage   = regexp.MustCompile(`^duplicate (primary|unique) key given: \[(.*)\]$`)
+)
+
+// translate maps the errors of GORM and MySQL to the errors of the database package.
+// err: The error of an operation.
+// Returns the mapped error, or err if it has no counterpart.
+func translate(err error) error {
+	var myErr *gomysql.MySQLError
+	switch {
+	case err == nil, errors.Is(err, dberr.ErrNotFound), errors.Is(err, dberr.ErrConflict), errors.Is(err, dberr.ErrTimeout):
+		return err
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return dberr.ErrNotFound
+	case !errors.As(err, &myErr):
+		return dberr.Context(err)
+	}
+
+	switch myErr.Number {
+	case duplicateEntry:
+		_, key := duplicate(myErr.Message)
+		return &dberr.ConflictError{Constraint: key, Err: err}
+	case lockWaitTimeout, statementTimeout, queryTimeout:
+		return dberr.Timeout(err)
+	default:
+		return err
+	}
+}
+
+// duplicate parses the message of a duplicate entry.
+// Returns the values of the entry and the name of the key, each empty if the message does not tell.
+func duplicate(message string) (string, string) {
+	if match := duplicateEntryMessage.FindStringSubmatch(message); match != nil {
+		return match[1], match[2]
+	}
+	if match := duplicateKeyMessage.FindStringSubmatch(message); match != nil {
+		if match[1] == "primary" {
+			return match[2], "PRIMARY"
+		}
+		return match[2], ""
+	}
+	return "", ""
+}
+
+// conflict names the table and the columns of the constraint a record violated, since MySQL only reports the name of the key.
+// The key is looked up among the unique constraints of the entity, or if it is unknown, the constraint is found by the values of the entry.
+// ctx: The context for the operation.
+// entity: The record that was written.
+// err: The mapped error of the write.
+// Returns the conflict with its columns, or err if it is not a conflict or the constraint cannot be found.
+func (g Database) conflict(ctx context.Context, entity interface{}, err error) error {
+	var (
+		conflict *dberr.ConflictError
+		myErr    *gomysql.MySQLError
+	)
+	if !errors.As(err, &conflict) || len(conflict.Fields) > 0 || !errors.As(err, &myErr) {
+		return err
+	}
+	stmt := &gorm.Statement{DB: g.db}
+	if stmt.Parse(entity) != nil {
+		return err
+	}
+
+	entry, key := duplicate(myErr.Message)
+	row := records.Record(entity)
+	for _, constraint := range records.UniqueConstraints(stmt.Schema) {
+		if !violates(ctx, constraint, key, entry, row) {
+			continue
+		}
+		resolved := &dberr.ConflictError{Table: stmt.Schema.Table, Constraint: key, Err: conflict.Err}
+		if key == "" {
+			resolved.Constraint = constraint.Name
+		}
+		for _, field := range constraint.Fields {
+			resolved.Fields = append(resolved.Fields, field.DBName)
+		}
+		return resolved
+	}
+	return err
+}
+
+// violates reports whether the constraint is the one a duplicate entry of the record violated.
+// A unique column is a key named after the constraint GORM created, or after the column itself, which older versions of GORM did.
+func violates(ctx context.Context, constraint records.Constraint, key, entry string, row reflect.Value) bool {
+	if key != "" {
+		// MySQL names the primary key PRIMARY.
+		return strings.EqualFold(key, constraint.Name) || (len(constraint.Fields) == 1 && key == constraint.Fields[0].DBName)
+	}
+	if entry == "" {
+		return false
+	}
+	values, ok := constraint.Values(ctx, row)
+	if !ok {
+		return false
+	}
+	texts := make([]string, 0, len(values))
+	for _, value := range values {
+		texts = append(texts, fmt.Sprint(value))
+	}
+	// MySQL joins the values of a composite key with dashes, the in-process server with spaces.
+	return entry == strings.Join(texts, "-") || entry == strings.Join(texts, " ")
+}
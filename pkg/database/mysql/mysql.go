@@ -0,0 +1,235 @@
+// Package mysql provides the functionality to interact with a MySQL or MariaDB database.
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/gormquery"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/gormtx"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/migrate"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"net"
+	"strconv"
+	"time"
+)
+
+// The column types the UUIDs can be stored as.
+const (
+	UUIDChar   = "char(36)"   // Stores the UUIDs as text in their canonical form, which is readable in SQL clients.
+	UUIDBinary = "binary(16)" // Stores the UUIDs as 16 raw bytes, which halves the size of the indexes.
+)
+
+// sqlMode is the SQL mode of every session. It makes the server reject invalid values instead of truncating them,
+// which MySQL does by default on older versions and MariaDB does on some distributions.
+const sqlMode = "'STRICT_ALL_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_ENGINE_SUBSTITUTION'"
+
+// like is the SQL of a pattern match. The backslash is the default escape character of MySQL,
+// and the case is ignored whatever the collation of the column.
+const like = "LOWER(?) LIKE LOWER(?)"
+
+// Database struct represents a MySQL database connection.
+type Database struct {
+	db        *gorm.DB
+	isolation sql.IsolationLevel
+	uuidType  string
+}
+
+// NewDatabase creates a new MySQL database connection based on the provided configuration.
+// cfg: The configuration object that contains the MySQL database settings.
+// Returns a Database object if the database connection is successfully established.
+// Returns an error if the UUID storage or the isolation level is unknown, or if the connection cannot be established.
+// The schema is created by the migrations, see Migrator.
+func NewDatabase(cfg *config.Config) (*Database, error) {
+	uuidType := cfg.DB.MySQL.UUIDStorage
+	if uuidType == "" {
+		uuidType = UUIDChar
+	}
+	if uuidType != UUIDChar && uuidType != UUIDBinary {
+		return nil, fmt.Errorf("unknown uuid storage %q, expected %q or %q", uuidType, UUIDChar, UUIDBinary)
+	}
+	isolation, err := gormtx.Isolation(cfg.DB.Isolation)
+	if err != nil {
+		return nil, err
+	}
+
+	connector, err := gomysql.NewConnector(Config(cfg.DB.MySQL))
+	if err != nil {
+		return nil, err
+	}
+	if uuidType == UUIDBinary {
+		connector = binaryUUIDConnector{Connector: connector}
+	}
+	sqlDB := sql.OpenDB(connector)
+	sqlDB.SetMaxOpenConns(cfg.DB.MySQL.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.DB.MySQL.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(time.Duration(cfg.DB.MySQL.ConnMaxLifetimeMinutes) * time.Minute)
+
+	conn, err := gorm.Open(gormmysql.New(gormmysql.Config{Conn: sqlDB}), &gorm.Config{})
+	if err != nil {
+		_ = sqlDB.Close()
+		return nil, err
+	}
+	return &Database{db: conn, isolation: isolation, uuidType: uuidType}, nil
+}
+
+// Config builds the driver configuration of the provided MySQL configuration.
+// The connections use utf8mb4, parse the datetime columns as UTC times and run in strict mode.
+// cfg: The MySQL configuration.
+// Returns the driver configuration.
+func Config(cfg config.MySQLConfig) *gomysql.Config {
+	c := gomysql.NewConfig()
+	c.User = cfg.User
+	c.Passwd = cfg.Password
+	c.Net = "tcp"
+	c.Addr = net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	c.DBName = cfg.DBName
+	c.TLSConfig = cfg.TLS
+	c.Collation = "utf8mb4_unicode_ci"
+	c.ParseTime = true
+	c.Loc = time.UTC
+	c.Params = map[string]string{"sql_mode": sqlMode, "time_zone": "'+00:00'"}
+	return c
+}
+
+// Migrator returns the migrator of the MySQL database, whose migrations create the UUID columns with the configured column type.
+// sources: The migrations of the modules.
+// Returns a *migrate.Migrator object and an error if the migrations cannot be loaded.
+func (g Database) Migrator(sources ...migrate.Source) (*migrate.Migrator, error) {
+	sqlDB, err := g.db.DB()
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(sqlDB, migrate.MySQL(g.uuidType), sources...)
+}
+
+// Create adds a new record to the MySQL database.
+// ctx: The context for the operation.
+// entity: The record to add.
+// Returns an error if the operation fails.
+func (g Database) Create(ctx context.Context, entity interface{}) error {
+	return g.conflict(ctx, entity, translate(gormtx.Conn(ctx, g.db).Create(entity).Error))
+}
+
+// Read retrieves a record from the MySQL database.
+// ctx: The context for the operation.
+// entity: The record to retrieve.
+// where: The condition to match. A nil condition matches every record.
+// Returns database.ErrNotFound if no record matches, or an error if the operation fails.
+func (g Database) Read(ctx context.Context, entity interface{}, where query.Condition) error {
+	conn, err := gormquery.Where(gormtx.Conn(ctx, g.db), entity, where, like)
+	if err != nil {
+		return err
+	}
+	return translate(conn.First(entity).Error)
+}
+
+// Update modifies a record in the MySQL database, or adds it if no record has its primary key.
+// ctx: The context for the operation.
+// entity: The record to modify. The version of a versioned record must be the stored one, and is incremented.
+// Returns database.ErrConflict if the record violates a unique constraint or is based on an outdated version, or an error if the operation fails.
+func (g Database) Update(ctx context.Context, entity interface{}) error {
+	return g.conflict(ctx, entity, translate(gormquery.Save(gormtx.Conn(ctx, g.db), entity)))
+}
+
+// Delete removes a record from the MySQL database.
+// ctx: The context for the operation.
+// entity: The record to remove.
+// id: The id of the record to remove.
+// Returns an error if the operation fails.
+func (g Database) Delete(ctx context.Context, entity interface{}, id interface{}) error {
+	return translate(gormtx.Conn(ctx, g.db).Where("id = ?", id).Delete(entity).Error)
+}
+
+// ReadAll retrieves all records from the MySQL database.
+// ctx: The context for the operation.
+// entity: The records to retrieve.
+// Returns an error if the operation fails.
+func (g Database) ReadAll(ctx context.Context, entity interface{}) error {
+	return translate(gormtx.Conn(ctx, g.db).Find(entity).Error)
+}
+
+// Find retrieves the records the query selects from the MySQL database.
+// ctx: The context for the operation.
+// entity: The records to retrieve.
+// q: The condition, ordering and window of the records.
+// Returns an error if the operation fails.
+func (g Database) Find(ctx context.Context, entity interface{}, q query.Query) error {
+	conn, err := gormquery.Find(gormtx.Conn(ctx, g.db), entity, q, like)
+	if err != nil {
+		return err
+	}
+	return translate(conn.Find(entity).Error)
+}
+
+// Count counts the records matching the condition in the MySQL database.
+// ctx: The context for the operation.
+// entity: A record of the type of the records to count.
+// where: The condition to match. A nil condition matches every record.
+// Returns the number of matching records and an error if the operation fails.
+func (g Database) Count(ctx context.Context, entity interface{}, where query.Condition) (int64, error) {
+	conn, err := gormquery.Where(gormtx.Conn(ctx, g.db), entity, where, like)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	return count, translate(conn.Model(entity).Count(&count).Error)
+}
+
+// DeleteWhere removes the records matching the condition from the MySQL database.
+// ctx: The context for the operation.
+// entity: The type of the records to remove.
+// where: The condition to match. A nil condition matches every record.
+// Returns the number of removed records and an error if the operation fails.
+func (g Database) DeleteWhere(ctx context.Context, entity interface{}, where query.Condition) (int64, error) {
+	conn, err := gormquery.Where(gormtx.Conn(ctx, g.db), entity, where, like)
+	if err != nil {
+		return 0, err
+	}
+	result := conn.Delete(entity)
+	return result.RowsAffected, translate(result.Error)
+}
+
+// WithTx runs fn in a transaction of the MySQL database, or in a savepoint if the context already carries a transaction.
+// ctx: The context for the operation.
+// fn: The function to run in the transaction.
+// opts: The isolation level and read-only flag of the transaction, instead of the configured ones.
+// Returns the error returned by fn, or an error if the transaction cannot be started or committed.
+func (g Database) WithTx(ctx context.Context, fn func(ctx context.Context) error, opts ...*sql.TxOptions) error {
+	return translate(gormtx.WithTx(ctx, g.db, g.isolation, fn, opts...))
+}
+
+// Stats reports the usage of the connection pool of the MySQL database.
+// Returns the statistics of the pool.
+func (g Database) Stats() sql.DBStats {
+	sqlDB, err := g.db.DB()
+	if err != nil {
+		return sql.DBStats{}
+	}
+	return sqlDB.Stats()
+}
+
+// Ping checks that the MySQL server is reachable.
+// ctx: The context for the operation.
+// Returns an error if the server cannot be reached.
+func (g Database) Ping(ctx context.Context) error {
+	sqlDB, err := g.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Close closes the connection pool of the MySQL database.
+// Returns an error if the operation fails.
+func (g Database) Close() error {
+	sqlDB, err := g.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
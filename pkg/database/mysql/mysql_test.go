@@ -0,0 +1,87 @@
+package mysql
+
+import (
+	"database/sql/driver"
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/dberr"
+	"gorm.io/gorm"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	dsn := Config(config.MySQLConfig{
+		Host:     "db.internal",
+		Port:     3307,
+		User:     "app",
+		Password: "p@ss:word/",
+		DBName:   "app",
+		TLS:      "skip-verify",
+	}).FormatDSN()
+
+	parsed, err := gomysql.ParseDSN(dsn)
+	require.NoError(t, err, "Failed to parse %s", dsn)
+	assert.Equal(t, "db.internal:3307", parsed.Addr)
+	assert.Equal(t, "app", parsed.User)
+	assert.Equal(t, "p@ss:word/", parsed.Passwd)
+	assert.Equal(t, "app", parsed.DBName)
+	assert.Equal(t, "skip-verify", parsed.TLSConfig)
+	assert.Equal(t, "utf8mb4_unicode_ci", parsed.Collation)
+	assert.True(t, parsed.ParseTime)
+	assert.Equal(t, sqlMode, parsed.Params["sql_mode"])
+}
+
+func TestNewDatabaseRejectsUnknownUUIDStorage(t *testing.T) {
+	_, err := NewDatabase(&config.Config{DB: config.DatabaseConfig{MySQL: config.MySQLConfig{UUIDStorage: "varchar(36)"}}})
+	assert.ErrorContains(t, err, "unknown uuid storage")
+}
+
+func TestConvertUUID(t *testing.T) {
+	id := uuid.New()
+
+	value := driver.NamedValue{Value: id}
+	assert.True(t, convertUUID(&value))
+	assert.Equal(t, id[:], value.Value)
+
+	value = driver.NamedValue{Value: &id}
+	assert.True(t, convertUUID(&value))
+	assert.Equal(t, id[:], value.Value)
+
+	var missing *uuid.UUID
+	value = driver.NamedValue{Value: missing}
+	assert.False(t, convertUUID(&value), "A nil UUID must be left to the driver")
+
+	value = driver.NamedValue{Value: id.String()}
+	assert.False(t, convertUUID(&value), "Only UUID values are converted")
+}
+
+func TestDuplicate(t *testing.T) {
+	entry, key := duplicate("Duplicate entry 'alice@example.com' for key 'users.uni_users_email'")
+	assert.Equal(t, "alice@example.com", entry)
+	assert.Equal(t, "uni_users_email", key, "MySQL 8 prefixes the key with the table")
+
+	_, key = duplicate("Duplicate entry 'alice' for key 'username'")
+	assert.Equal(t, "username", key)
+
+	entry, key = duplicate("duplicate primary key given: [42]")
+	assert.Equal(t, "42", entry)
+	assert.Equal(t, "PRIMARY", key)
+
+	entry, key = duplicate("duplicate unique key given: [alice]")
+	assert.Equal(t, "alice", entry)
+	assert.Empty(t, key, "the in-process server does not name unique keys")
+}
+
+func TestTranslate(t *testing.T) {
+	err := translate(&gomysql.MySQLError{Number: duplicateEntry, Message: "Duplicate entry 'acme' for key 'organizations.uni_organizations_slug'"})
+	var conflict *dberr.ConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "uni_organizations_slug", conflict.Constraint)
+
+	assert.ErrorIs(t, translate(&gomysql.MySQLError{Number: lockWaitTimeout}), dberr.ErrTimeout)
+	assert.ErrorIs(t, translate(gorm.ErrRecordNotFound), dberr.ErrNotFound)
+}
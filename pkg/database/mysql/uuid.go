@@ -0,0 +1,174 @@
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"github.com/google/uuid"
+)
+
+// binaryUUIDConnector struct represents a connector whose connections send the UUID arguments as 16 raw bytes,
+// for the databases that store the UUIDs in binary(16) columns. Reading needs no conversion, since uuid.UUID scans raw bytes.
+type binaryUUIDConnector struct {
+	driver.Connector
+}
+
+// Connect opens a connection that converts the UUID arguments.
+// ctx: The context for the operation.
+// Returns the connection and an error if the operation fails.
+func (c binaryUUIDConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &binaryUUIDConn{Conn: conn}, nil
+}
+
+// convertUUID replaces a UUID argument by its bytes.
+// nv: The argument to convert.
+// Returns true if the argument was a UUID.
+func convertUUID(nv *driver.NamedValue) bool {
+	var id uuid.UUID
+	switch v := nv.Value.(type) {
+	case uuid.UUID:
+		id = v
+	case *uuid.UUID:
+		if v == nil {
+			return false
+		}
+		id = *v
+	default:
+		return false
+	}
+	b := make([]byte, len(id))
+	copy(b, id[:])
+	nv.Value = b
+	return true
+}
+
+// binaryUUIDConn struct represents a driver connection that converts the UUID arguments of its queries and statements.
+// Every optional interface of the MySQL driver connection is forwarded.
+type binaryUUIDConn struct {
+	driver.Conn
+}
+
+// CheckNamedValue converts the UUID arguments and leaves the other arguments to the driver.
+func (c *binaryUUIDConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if convertUUID(nv) {
+		return nil
+	}
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// PrepareContext prepares a statement that converts the UUID arguments.
+func (c *binaryUUIDConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &binaryUUIDStmt{Stmt: stmt}, nil
+}
+
+// Prepare prepares a statement that converts the UUID arguments.
+func (c *binaryUUIDConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// BeginTx starts a transaction on the connection.
+func (c *binaryUUIDConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+// ExecContext executes a query that returns no rows.
+func (c *binaryUUIDConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := c.Conn.(driver.ExecerContext); ok {
+		return execer.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+// QueryContext executes a query that returns rows.
+func (c *binaryUUIDConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if queryer, ok := c.Conn.(driver.QueryerContext); ok {
+		return queryer.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+// Ping checks that the connection is alive.
+func (c *binaryUUIDConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// ResetSession resets the connection before it is reused.
+func (c *binaryUUIDConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid reports whether the connection can be reused.
+func (c *binaryUUIDConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// binaryUUIDStmt struct represents a prepared statement that converts the UUID arguments.
+// The statement is checked before the connection, so it has to convert the arguments itself.
+type binaryUUIDStmt struct {
+	driver.Stmt
+}
+
+// CheckNamedValue converts the UUID arguments and leaves the other arguments to the driver.
+func (s *binaryUUIDStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if convertUUID(nv) {
+		return nil
+	}
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// ExecContext executes the statement.
+func (s *binaryUUIDStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return s.Stmt.Exec(values)
+}
+
+// QueryContext executes the statement and returns its rows.
+func (s *binaryUUIDStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		return queryer.QueryContext(ctx, args)
+	}
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return s.Stmt.Query(values)
+}
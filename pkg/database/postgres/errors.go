@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/dberr"
+	"gorm.io/gorm"
+	"regexp"
+	"strings"
+)
+
+// The SQLSTATE codes that are mapped to the errors of the database package.
+const (
+	uniqueViolation    = "23505" // A unique constraint or the primary key is violated.
+	queryCanceled      = "57014" // The statement_timeout elapsed, or the query was canceled.
+	lockNotAvailable   = "55P03" // The lock_timeout elapsed.
+	idleInTxnTimeout   = "25P03" // The idle_in_transaction_session_timeout elapsed.
+	transactionTimeout = "25P04" // The transaction_timeout elapsed.
+)
+
+// keyColumns matches the columns in the detail of a unique violation, e.g. "Key (email)=(alice@example.com) already exists.".
+var keyColumns = regexp.MustCompile(`^Key \(([^)]*)\)=`)
+
+// translate maps the errors of GORM and PostgreSQL to the errors of the database package.
+// err: The error of an operation.
+// Returns the mapped error, or err if it has no counterpart.
+func translate(err error) error {
+	var pgErr *pgconn.PgError
+	switch {
+	case err == nil, errors.Is(err, dberr.ErrNotFound), errors.Is(err, dberr.ErrConflict), errors.Is(err, dberr.ErrTimeout):
+		return err
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return dberr.ErrNotFound
+	case !errors.As(err, &pgErr):
+		return dberr.Context(err)
+	}
+
+	switch pgErr.Code {
+	case uniqueViolation:
+		conflict := &dberr.ConflictError{Table: pgErr.TableName, Constraint: pgErr.ConstraintName, Err: err}
+		if match := keyColumns.FindStringSubmatch(pgErr.Detail); match != nil {
+			conflict.Fields = strings.Split(match[1], ", ")
+		}
+		return conflict
+	case queryCanceled, lockNotAvailable, idleInTxnTimeout, transactionTimeout:
+		return dberr.Timeout(err)
+	default:
+		return err
+	}
+}
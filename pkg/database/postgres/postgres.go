@@ -0,0 +1,260 @@
+// Package postgres provides the functionality to interact with a PostgreSQL database.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/dberr"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/gormquery"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/gormtx"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/migrate"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// like is the SQL of a pattern match, which ignores the case as on the other databases.
+const like = `? ILIKE ? ESCAPE '\'`
+
+// Database struct represents a PostgreSQL database connection.
+type Database struct {
+	db        *gorm.DB
+	isolation sql.IsolationLevel
+}
+
+// NewDatabase creates a new PostgreSQL database connection based on the provided configuration.
+// cfg: The configuration object that contains the PostgreSQL database settings.
+// Returns a Database object if the database connection is successfully established.
+// Returns an error if the isolation level is unknown, or if the connection cannot be established.
+// The schema is created by the migrations, see Migrator.
+func NewDatabase(cfg *config.Config) (*Database, error) {
+	isolation, err := gormtx.Isolation(cfg.DB.Isolation)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := gorm.Open(postgres.Open(DSN(cfg.DB.Postgres)), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := conn.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(cfg.DB.Postgres.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.DB.Postgres.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(time.Duration(cfg.DB.Postgres.ConnMaxLifetimeMinutes) * time.Minute)
+	return &Database{db: conn, isolation: isolation}, nil
+}
+
+// DSN builds the keyword/value connection string of the provided PostgreSQL configuration.
+// The values are quoted, so that passwords may contain spaces and quotes. The session time zone is always UTC.
+// cfg: The PostgreSQL configuration.
+// Returns the connection string.
+func DSN(cfg config.PostgresConfig) string {
+	parts := []string{
+		"host=" + quote(cfg.Host),
+		fmt.Sprintf("port=%d", cfg.Port),
+		"user=" + quote(cfg.User),
+		"password=" + quote(cfg.Password),
+		"dbname=" + quote(cfg.DBName),
+		"TimeZone=UTC",
+	}
+	if cfg.SSLMode != "" {
+		parts = append(parts, "sslmode="+quote(cfg.SSLMode))
+	}
+	if cfg.SSLRootCert != "" {
+		parts = append(parts, "sslrootcert="+quote(cfg.SSLRootCert))
+	}
+	return strings.Join(parts, " ")
+}
+
+// quote quotes a connection string value, escaping the backslashes and single quotes.
+func quote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// Migrator returns the migrator of the PostgreSQL database.
+// sources: The migrations of the modules.
+// Returns a *migrate.Migrator object and an error if the migrations cannot be loaded.
+func (g Database) Migrator(sources ...migrate.Source) (*migrate.Migrator, error) {
+	sqlDB, err := g.db.DB()
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(sqlDB, migrate.Postgres(), sources...)
+}
+
+// Create adds a new record to the PostgreSQL database.
+// ctx: The context for the operation.
+// entity: The record to add.
+// Returns an error if the operation fails.
+func (g Database) Create(ctx context.Context, entity interface{}) error {
+	return translate(gormtx.Conn(ctx, g.db).Create(entity).Error)
+}
+
+// Read retrieves a record from the PostgreSQL database.
+// ctx: The context for the operation.
+// entity: The record to retrieve.
+// where: The condition to match. A nil condition matches every record.
+// Returns database.ErrNotFound if no record matches, or an error if the operation fails.
+func (g Database) Read(ctx context.Context, entity interface{}, where query.Condition) error {
+	conn, err := gormquery.Where(gormtx.Conn(ctx, g.db), entity, where, like)
+	if err != nil {
+		return err
+	}
+	return translate(conn.First(entity).Error)
+}
+
+// Update modifies a record in the PostgreSQL database, or adds it if no record has its primary key.
+// ctx: The context for the operation.
+// entity: The record to modify. The version of a versioned record must be the stored one, and is incremented.
+// Returns database.ErrConflict if the record violates a unique constraint or is based on an outdated version, or an error if the operation fails.
+func (g Database) Update(ctx context.Context, entity interface{}) error {
+	return translate(gormquery.Save(gormtx.Conn(ctx, g.db), entity))
+}
+
+// Delete removes a record from the PostgreSQL database.
+// ctx: The context for the operation.
+// entity: The record to remove.
+// id: The id of the record to remove.
+// Returns an error if the operation fails.
+func (g Database) Delete(ctx context.Context, entity interface{}, id interface{}) error {
+	return translate(gormtx.Conn(ctx, g.db).Where("id = ?", id).Delete(entity).Error)
+}
+
+// ReadAll retrieves all records from the PostgreSQL database.
+// ctx: The context for the operation.
+// entity: The records to retrieve.
+// Returns an error if the operation fails.
+func (g Database) ReadAll(ctx context.Context, entity interface{}) error {
+	return translate(gormtx.Conn(ctx, g.db).Find(entity).Error)
+}
+
+// Find retrieves the records the query selects from the PostgreSQL database.
+// ctx: The context for the operation.
+// entity: The records to retrieve.
+// q: The condition, ordering and window of the records.
+// Returns an error if the operation fails.
+func (g Database) Find(ctx context.Context, entity interface{}, q query.Query) error {
+	conn, err := gormquery.Find(gormtx.Conn(ctx, g.db), entity, q, like)
+	if err != nil {
+		return err
+	}
+	return translate(conn.Find(entity).Error)
+}
+
+// Count counts the records matching the condition in the PostgreSQL database.
+// ctx: The context for the operation.
+// entity: A record of the type of the records to count.
+// where: The condition to match. A nil condition matches every record.
+// Returns the number of matching records and an error if the operation fails.
+func (g Database) Count(ctx context.Context, entity interface{}, where query.Condition) (int64, error) {
+	conn, err := gormquery.Where(gormtx.Conn(ctx, g.db), entity, where, like)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	return count, translate(conn.Model(entity).Count(&count).Error)
+}
+
+// DeleteWhere removes the records matching the condition from the PostgreSQL database.
+// ctx: The context for the operation.
+// entity: The type of the records to remove.
+// where: The condition to match. A nil condition matches every record.
+// Returns the number of removed records and an error if the operation fails.
+func (g Database) DeleteWhere(ctx context.Context, entity interface{}, where query.Condition) (int64, error) {
+	conn, err := gormquery.Where(gormtx.Conn(ctx, g.db), entity, where, like)
+	if err != nil {
+		return 0, err
+	}
+	result := conn.Delete(entity)
+	return result.RowsAffected, translate(result.Error)
+}
+
+// Search retrieves the records that match a full-text search from the tsvector column of their table, "search".
+// Every term matches the words it prefixes, in the columns of the search if it names them, and the records are ranked by ts_rank
+// with the weights the column is built with.
+// ctx: The context for the operation.
+// entity: A pointer to the slice of the records to retrieve.
+// search: The terms and the window of the search.
+// Returns the rank of every record, the number of matching records, and database.ErrSearchNotSupported if the table has no search column.
+func (g Database) Search(ctx context.Context, entity interface{}, search query.TextSearch) ([]float64, int64, error) {
+	conn := gormtx.Conn(ctx, g.db)
+	name, table, err := gormquery.Table(conn, entity)
+	if err != nil {
+		return nil, 0, err
+	}
+	var columns int64
+	err = conn.Raw("SELECT count(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = 'search'", name).
+		Scan(&columns).Error
+	if err != nil {
+		return nil, 0, translate(err)
+	}
+	if columns == 0 {
+		return nil, 0, dberr.ErrSearchNotSupported
+	}
+	// The terms only hold letters and digits, which are not operators of the tsquery syntax.
+	prefixes := make([]string, 0, len(search.Terms))
+	for _, term := range search.Terms {
+		prefixes = append(prefixes, term+":*")
+	}
+	// The columns of the search are matched with their own words, which the index narrows the records down for.
+	where := "search @@ terms"
+	if len(search.Columns) > 0 {
+		columns := make([]string, 0, len(search.Columns))
+		for _, column := range search.Columns {
+			columns = append(columns, conn.Statement.Quote(column))
+		}
+		where += fmt.Sprintf(" AND to_tsvector('simple', regexp_replace(concat_ws(' ', %s), '[^[:alnum:]]+', ' ', 'g')) @@ terms", strings.Join(columns, ", "))
+	}
+	ranks, total, err := gormquery.Search(conn, entity, search, "ALL",
+		fmt.Sprintf("SELECT id, ts_rank(search, terms) AS score FROM %s, to_tsquery('simple', ?) terms WHERE %s ORDER BY score DESC, id", table, where),
+		fmt.Sprintf("SELECT count(*) FROM %s, to_tsquery('simple', ?) terms WHERE %s", table, where),
+		strings.Join(prefixes, " & "))
+	return ranks, total, translate(err)
+}
+
+// WithTx runs fn in a transaction of the PostgreSQL database, or in a savepoint if the context already carries a transaction.
+// ctx: The context for the operation.
+// fn: The function to run in the transaction.
+// opts: The isolation level and read-only flag of the transaction, instead of the configured ones.
+// Returns the error returned by fn, or an error if the transaction cannot be started or committed.
+func (g Database) WithTx(ctx context.Context, fn func(ctx context.Context) error, opts ...*sql.TxOptions) error {
+	return translate(gormtx.WithTx(ctx, g.db, g.isolation, fn, opts...))
+}
+
+// Stats reports the usage of the connection pool of the PostgreSQL database.
+// Returns the statistics of the pool.
+func (g Database) Stats() sql.DBStats {
+	sqlDB, err := g.db.DB()
+	if err != nil {
+		return sql.DBStats{}
+	}
+	return sqlDB.Stats()
+}
+
+// Ping checks that the PostgreSQL server is reachable.
+// ctx: The context for the operation.
+// Returns an error if the server cannot be reached.
+func (g Database) Ping(ctx context.Context) error {
+	sqlDB, err := g.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Close closes the connection pool of the PostgreSQL database.
+// Returns an error if the operation fails.
+func (g Database) Close() error {
+	sqlDB, err := g.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/dberr"
+	"gorm.io/gorm"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDSN(t *testing.T) {
+	dsn := DSN(config.PostgresConfig{
+		Host:     "db.internal",
+		Port:     6432,
+		User:     "app",
+		Password: `it's a \secret`,
+		DBName:   "app db",
+		SSLMode:  "require",
+	})
+
+	parsed, err := pgconn.ParseConfig(dsn)
+	require.NoError(t, err, "Failed to parse %s", dsn)
+	assert.Equal(t, "db.internal", parsed.Host)
+	assert.Equal(t, uint16(6432), parsed.Port)
+	assert.Equal(t, "app", parsed.User)
+	assert.Equal(t, `it's a \secret`, parsed.Password)
+	assert.Equal(t, "app db", parsed.Database)
+	assert.Equal(t, "UTC", parsed.RuntimeParams["TimeZone"])
+	assert.NotNil(t, parsed.TLSConfig, "require must use TLS")
+}
+
+func TestTranslate(t *testing.T) {
+	err := translate(&pgconn.PgError{
+		Code:           uniqueViolation,
+		Detail:         "Key (organization_id, user_id)=(1, 2) already exists.",
+		TableName:      "memberships",
+		ConstraintName: "idx_membership_org_user",
+	})
+	var conflict *dberr.ConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "memberships", conflict.Table)
+	assert.Equal(t, []string{"organization_id", "user_id"}, conflict.Fields)
+	assert.Equal(t, "idx_membership_org_user", conflict.Constraint)
+
+	assert.ErrorIs(t, translate(&pgconn.PgError{Code: lockNotAvailable}), dberr.ErrTimeout)
+	assert.ErrorIs(t, translate(gorm.ErrRecordNotFound), dberr.ErrNotFound)
+	assert.ErrorIs(t, translate(context.DeadlineExceeded), dberr.ErrTimeout)
+	assert.NotErrorIs(t, translate(context.Canceled), dberr.ErrTimeout, "a cancellation is not a timeout")
+}
@@ -3,22 +3,94 @@ package database
 
 import (
 	"errors"
+	"fmt"
 	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/bolt"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/memory"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/mysql"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/postgres"
 	"github.com/nikita-voronoy/go-clean-arch/pkg/database/sqlite"
+	"io"
+	"time"
 )
 
 // NewDatabase creates a new database connection based on the provided configuration.
-// It currently supports only SQLite databases.
+// It supports SQLite, PostgreSQL and MySQL databases, the embedded bbolt database, and an in-memory database for tests and demos.
+// If replicas are configured, the plain reads are spread over them, see ReplicatedDatabase.
 // cfg: The configuration object that contains the database settings.
 // Returns a Database object if the database connection is successfully established.
-// Returns an error if the database type is not supported or if the connection cannot be established.
+// Returns an error if the database type is not supported, if it has no replicas but some are configured,
+// or if a connection cannot be established.
 func NewDatabase(cfg *config.Config) (Database, error) {
+	primary, err := open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	replication := cfg.DB.Replication
+	if len(replication.Replicas) == 0 {
+		return primary, nil
+	}
+
+	replicas := make([]Database, 0, len(replication.Replicas))
+	for i, replica := range replication.Replicas {
+		db, err := openReplica(cfg, replica)
+		if err != nil {
+			for _, opened := range append(replicas, primary) {
+				if closer, ok := opened.(io.Closer); ok {
+					_ = closer.Close()
+				}
+			}
+			return nil, fmt.Errorf("replica %d: %w", i+1, err)
+		}
+		replicas = append(replicas, db)
+	}
+	sticky := time.Duration(replication.StickySeconds) * time.Second
+	interval := time.Duration(replication.HealthCheckSeconds) * time.Second
+	return NewReplicatedDatabase(primary, replicas, sticky, interval), nil
+}
+
+// open creates the database connection of the configured database type.
+func open(cfg *config.Config) (Database, error) {
 	switch cfg.DB.DatabaseType {
 	case "sqlite":
 		// Create a new SQLite database connection.
 		return sqlite.NewDatabase(cfg)
+	case "postgres":
+		// Create a new PostgreSQL database connection.
+		return postgres.NewDatabase(cfg)
+	case "mysql":
+		// Create a new MySQL database connection.
+		return mysql.NewDatabase(cfg)
+	case "bolt":
+		// Open the embedded bbolt database.
+		return bolt.NewDatabase(cfg)
+	case "memory":
+		// Create a new in-memory database.
+		return memory.NewDatabase(), nil
 	default:
 		// Return an error if the database type is not supported.
 		return nil, errors.New("database type not supported")
 	}
 }
+
+// openReplica creates the connection of a replica, with the configuration of the primary and the location of the replica.
+func openReplica(cfg *config.Config, replica config.ReplicaConfig) (Database, error) {
+	replicaCfg := *cfg
+	switch cfg.DB.DatabaseType {
+	case "sqlite":
+		replicaCfg.DB.Sqlite.DatabasePath = replica.DatabasePath
+	case "postgres":
+		replicaCfg.DB.Postgres.Host = replica.Host
+		if replica.Port != 0 {
+			replicaCfg.DB.Postgres.Port = replica.Port
+		}
+	case "mysql":
+		replicaCfg.DB.MySQL.Host = replica.Host
+		if replica.Port != 0 {
+			replicaCfg.DB.MySQL.Port = replica.Port
+		}
+	default:
+		return nil, fmt.Errorf("database type %q does not support replicas", cfg.DB.DatabaseType)
+	}
+	return open(&replicaCfg)
+}
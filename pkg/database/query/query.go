@@ -0,0 +1,244 @@
+// Package query provides the typed queries of the database package: the conditions, orderings, windows and cursors
+// of the records to read or remove. Every database compiles them natively, the SQL databases to the clauses of GORM,
+// with the columns quoted and the values bound, and the key-value databases to predicates on the records,
+// so that no SQL is written by the repositories.
+package query
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrCursor is returned when the cursor of a query does not match its ordering.
+var ErrCursor = errors.New("the cursor needs a value for every ordering column")
+
+// Condition is the interface of the conditions on the records: Comparison, Membership, Pattern, Between,
+// Conjunction and Disjunction. A nil Condition matches every record.
+type Condition interface {
+	condition()
+}
+
+// Operator is the operator of a Comparison.
+type Operator string
+
+// The operators of the comparisons.
+const (
+	Equal          Operator = "="  // The column equals the value.
+	NotEqual       Operator = "<>" // The column differs from the value.
+	Less           Operator = "<"  // The column is lower than the value.
+	LessOrEqual    Operator = "<=" // The column is lower than or equal to the value.
+	Greater        Operator = ">"  // The column is greater than the value.
+	GreaterOrEqual Operator = ">=" // The column is greater than or equal to the value.
+)
+
+// Comparison struct represents the comparison of a column to a value. As in SQL, a comparison with a NULL column is never true.
+type Comparison struct {
+	Column   string      // The name of the column.
+	Operator Operator    // The operator of the comparison.
+	Value    interface{} // The value the column is compared to.
+}
+
+// Membership struct represents the membership of a column in a list of values, "column IN (values)".
+type Membership struct {
+	Column string        // The name of the column.
+	Values []interface{} // The values. An empty list matches no record.
+}
+
+// Pattern struct represents the match of a text column with a pattern, in which % stands for any sequence of characters,
+// _ for any single character, and \ escapes the next character. The match ignores the case of the ASCII letters on every database.
+type Pattern struct {
+	Column  string // The name of the column.
+	Pattern string // The pattern, e.g. "%@example.com". Use Escape to match a text literally.
+}
+
+// Between struct represents the range of the values of a column, from From included to To excluded.
+type Between struct {
+	Column string      // The name of the column.
+	From   interface{} // The lower bound, included. A nil bound leaves the range open.
+	To     interface{} // The upper bound, excluded. A nil bound leaves the range open.
+}
+
+// Conjunction is the condition satisfied by the records that satisfy every condition. An empty conjunction matches every record.
+type Conjunction []Condition
+
+// Disjunction is the condition satisfied by the records that satisfy any of the conditions. An empty disjunction matches no record.
+type Disjunction []Condition
+
+func (Comparison) condition()  {}
+func (Membership) condition()  {}
+func (Pattern) condition()     {}
+func (Between) condition()     {}
+func (Conjunction) condition() {}
+func (Disjunction) condition() {}
+
+// Eq returns the condition of the records whose column equals the value.
+func Eq(column string, value interface{}) Condition {
+	return Comparison{Column: column, Operator: Equal, Value: value}
+}
+
+// Ne returns the condition of the records whose column differs from the value.
+func Ne(column string, value interface{}) Condition {
+	return Comparison{Column: column, Operator: NotEqual, Value: value}
+}
+
+// Lt returns the condition of the records whose column is lower than the value.
+func Lt(column string, value interface{}) Condition {
+	return Comparison{Column: column, Operator: Less, Value: value}
+}
+
+// Lte returns the condition of the records whose column is lower than or equal to the value.
+func Lte(column string, value interface{}) Condition {
+	return Comparison{Column: column, Operator: LessOrEqual, Value: value}
+}
+
+// Gt returns the condition of the records whose column is greater than the value.
+func Gt(column string, value interface{}) Condition {
+	return Comparison{Column: column, Operator: Greater, Value: value}
+}
+
+// Gte returns the condition of the records whose column is greater than or equal to the value.
+func Gte(column string, value interface{}) Condition {
+	return Comparison{Column: column, Operator: GreaterOrEqual, Value: value}
+}
+
+// In returns the condition of the records whose column equals one of the values.
+// values: A slice or an array of the values, e.g. []uuid.UUID.
+func In(column string, values interface{}) Condition {
+	membership := Membership{Column: column}
+	list := reflect.ValueOf(values)
+	if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+		return Membership{Column: column, Values: []interface{}{values}}
+	}
+	for i := 0; i < list.Len(); i++ {
+		membership.Values = append(membership.Values, list.Index(i).Interface())
+	}
+	return membership
+}
+
+// Like returns the condition of the records whose text column matches the pattern, see Pattern.
+func Like(column, pattern string) Condition {
+	return Pattern{Column: column, Pattern: pattern}
+}
+
+// Range returns the condition of the records whose column is in the range from from included to to excluded.
+// A nil bound leaves the range open on its side.
+func Range(column string, from, to interface{}) Condition {
+	return Between{Column: column, From: from, To: to}
+}
+
+// And returns the condition of the records that satisfy every condition. The nil conditions are ignored.
+func And(conditions ...Condition) Condition {
+	var conjunction Conjunction
+	for _, c := range conditions {
+		if c != nil {
+			conjunction = append(conjunction, c)
+		}
+	}
+	switch len(conjunction) {
+	case 0:
+		return nil
+	case 1:
+		return conjunction[0]
+	default:
+		return conjunction
+	}
+}
+
+// Or returns the condition of the records that satisfy any of the conditions.
+// A nil condition matches every record, so that the disjunction does too.
+func Or(conditions ...Condition) Condition {
+	var disjunction Disjunction
+	for _, c := range conditions {
+		if c == nil {
+			return nil
+		}
+		disjunction = append(disjunction, c)
+	}
+	if len(disjunction) == 1 {
+		return disjunction[0]
+	}
+	return disjunction
+}
+
+// Escape escapes the wildcards of a text, so that a Pattern matches it literally, e.g. Like("email", "%"+Escape(domain)).
+func Escape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(text)
+}
+
+// Order struct represents the ordering of the records by a column.
+type Order struct {
+	Column string // The name of the column.
+	Desc   bool   // Whether the records are in descending order.
+}
+
+// Asc returns the ascending ordering by the column.
+func Asc(column string) Order {
+	return Order{Column: column}
+}
+
+// Desc returns the descending ordering by the column.
+func Desc(column string) Order {
+	return Order{Column: column, Desc: true}
+}
+
+// Query struct represents the records to read: a condition, an ordering and a window.
+type Query struct {
+	Where   Condition     // The condition of the records. A nil condition matches every record.
+	OrderBy []Order       // The ordering of the records. Without an ordering, the records are in the order of the database.
+	Limit   int           // The maximum number of records. Zero or a negative value means no limit.
+	Offset  int           // The number of records to skip.
+	After   []interface{} // The cursor: the values of the ordering columns of the last record of the previous page, if any.
+}
+
+// Where returns the query of the records that satisfy the condition.
+func Where(condition Condition) Query {
+	return Query{Where: condition}
+}
+
+// OrderedBy returns a copy of the query with the ordering.
+func (q Query) OrderedBy(orders ...Order) Query {
+	q.OrderBy = orders
+	return q
+}
+
+// Window returns a copy of the query with the limit and the offset.
+func (q Query) Window(limit, offset int) Query {
+	q.Limit, q.Offset = limit, offset
+	return q
+}
+
+// StartAfter returns a copy of the query that starts after the record with the values of the ordering columns,
+// which pages through the records without an offset, so that the pages stay consistent while records are added.
+// The ordering should end with a unique column, such as the primary key, so that no record is skipped.
+func (q Query) StartAfter(values ...interface{}) Query {
+	q.After = values
+	return q
+}
+
+// Condition returns the condition of the records the query reads, which includes the records after the cursor.
+// Returns the condition and ErrCursor if the cursor has not a value for every ordering column.
+func (q Query) Condition() (Condition, error) {
+	if q.After == nil {
+		return q.Where, nil
+	}
+	if len(q.After) != len(q.OrderBy) {
+		return nil, fmt.Errorf("%w: %d values for %d columns", ErrCursor, len(q.After), len(q.OrderBy))
+	}
+	// (a > x) OR (a = x AND b > y) OR ..., with < for the descending columns.
+	var seek []Condition
+	for i, order := range q.OrderBy {
+		var terms []Condition
+		for j := 0; j < i; j++ {
+			terms = append(terms, Eq(q.OrderBy[j].Column, q.After[j]))
+		}
+		if order.Desc {
+			terms = append(terms, Lt(order.Column, q.After[i]))
+		} else {
+			terms = append(terms, Gt(order.Column, q.After[i]))
+		}
+		seek = append(seek, And(terms...))
+	}
+	return And(q.Where, Or(seek...)), nil
+}
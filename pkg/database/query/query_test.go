@@ -0,0 +1,54 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstructors(t *testing.T) {
+	assert.Equal(t, Membership{Column: "id", Values: []interface{}{1, 2}}, In("id", []int{1, 2}))
+	assert.Equal(t, Membership{Column: "id", Values: []interface{}{1}}, In("id", 1), "a single value is a list of one")
+
+	assert.Nil(t, And(), "an empty conjunction matches every record")
+	assert.Nil(t, And(nil, nil))
+	assert.Equal(t, Eq("a", 1), And(nil, Eq("a", 1)), "a single condition is not wrapped")
+	assert.Equal(t, Conjunction{Eq("a", 1), Ne("b", 2)}, And(Eq("a", 1), nil, Ne("b", 2)))
+
+	assert.Equal(t, Disjunction(nil), Or(), "an empty disjunction matches no record")
+	assert.Nil(t, Or(Eq("a", 1), nil), "a nil condition matches every record")
+	assert.Equal(t, Disjunction{Lt("a", 1), Gt("a", 2)}, Or(Lt("a", 1), Gt("a", 2)))
+
+	assert.Equal(t, `100\%\_\\`, Escape(`100%_\`))
+}
+
+func TestCondition(t *testing.T) {
+	q := Where(Eq("org_id", 7)).OrderedBy(Desc("created_at"), Asc("id")).Window(10, 0)
+	where, err := q.Condition()
+	require.NoError(t, err)
+	assert.Equal(t, Eq("org_id", 7), where, "a query without a cursor has its own condition")
+
+	where, err = q.StartAfter("2024-01-01", 42).Condition()
+	require.NoError(t, err)
+	assert.Equal(t, Conjunction{
+		Eq("org_id", 7),
+		Disjunction{
+			Lt("created_at", "2024-01-01"),
+			Conjunction{Eq("created_at", "2024-01-01"), Gt("id", 42)},
+		},
+	}, where)
+
+	_, err = q.StartAfter(42).Condition()
+	assert.ErrorIs(t, err, ErrCursor)
+}
+
+func TestTerms(t *testing.T) {
+	assert.Equal(t, []string{"alice", "example", "com"}, Terms("Alice@Example.com"))
+	assert.Equal(t, []string{"ali", "bob"}, Terms("  ali, bob ALI "), "the terms are deduplicated")
+	assert.Equal(t, []string{"zoë", "or", "42"}, Terms(`"Zoë" OR 42*`), "the operators of the query syntaxes are words or separators")
+	assert.Empty(t, Terms("*:& !"))
+
+	search := Search("ali").Window(10, 20)
+	assert.Equal(t, TextSearch{Terms: []string{"ali"}, Limit: 10, Offset: 20}, search)
+}
@@ -0,0 +1,55 @@
+// Package query provides the full-text searches of the database package, which the databases with a full-text index
+// run natively.
+package query
+
+import (
+	"strings"
+	"unicode"
+)
+
+// TextSearch struct represents a full-text search: the records that have a word starting with each of the terms,
+// most relevant first.
+type TextSearch struct {
+	Terms   []string // The lowercase terms, see Terms. A search without terms matches no record.
+	Columns []string // The indexed columns the terms must match. Every indexed column is matched if it is empty.
+	Limit   int      // The maximum number of records. Zero or a negative value means no limit.
+	Offset  int      // The number of records to skip.
+}
+
+// Search returns the full-text search of the terms of a text.
+// text: The text typed by the user, e.g. "ali example".
+// Returns the search.
+func Search(text string) TextSearch {
+	return TextSearch{Terms: Terms(text)}
+}
+
+// Window returns a copy of the search with the limit and the offset.
+func (s TextSearch) Window(limit, offset int) TextSearch {
+	s.Limit, s.Offset = limit, offset
+	return s
+}
+
+// In returns a copy of the search that only matches the terms in the columns.
+func (s TextSearch) In(columns ...string) TextSearch {
+	s.Columns = columns
+	return s
+}
+
+// Terms splits a text into its words, the sequences of letters and digits, in lowercase and without duplicates.
+// The other characters separate the words, as the full-text indexes tokenize the text, so that "alice@example.com"
+// is made of the terms "alice", "example" and "com", and no term needs to be escaped.
+// text: The text to split.
+// Returns the terms in the order of the text.
+func Terms(text string) []string {
+	var terms []string
+	seen := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if !seen[word] {
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
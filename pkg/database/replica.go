@@ -0,0 +1,319 @@
+// Package database provides the functionality to split the reads and the writes of a database between a primary and its replicas.
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"golang.org/x/net/context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Pinger is an interface implemented by the databases that can check that they are reachable.
+type Pinger interface {
+	// Ping checks that the database is reachable.
+	// ctx: The context for the operation.
+	// Returns an error if the database cannot be reached.
+	Ping(ctx context.Context) error
+}
+
+// session struct represents the database operations of a request, which remembers the time of its last write.
+type session struct {
+	lastWrite atomic.Int64 // The time of the last write, in nanoseconds since the epoch, or zero.
+}
+
+// sessionKey is the context key under which the session of a request is stored.
+type sessionKey struct{}
+
+// primaryKey is the context key that routes the reads to the primary.
+type primaryKey struct{}
+
+// WithSession returns a copy of the context that tracks the writes of a request, so that the reads that follow a write
+// read it from the primary until the replicas have caught up.
+// ctx: The parent context.
+// Returns the new context.
+func WithSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{})
+}
+
+// WithPrimary returns a copy of the context whose reads are routed to the primary, for the reads that must see the latest writes.
+// ctx: The parent context.
+// Returns the new context.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// PrimaryFromContext reports whether the reads of the context must see the latest writes, see WithPrimary,
+// so that the decorators that serve reads from elsewhere, such as the caches, read them from the database.
+// ctx: The context to inspect.
+// Returns true if the reads of the context are routed to the primary.
+func PrimaryFromContext(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+
+// replica struct represents a replica of the database and the outcome of its last health check.
+type replica struct {
+	db      Database
+	healthy atomic.Bool
+}
+
+// ReplicatedDatabase struct represents a database decorator that sends the writes and the transactions to the primary
+// and spreads the plain reads over the healthy replicas.
+// The reads of a session that wrote within the stickiness window are sent to the primary, so that a request reads its own writes.
+// The migrations and the backups apply to the primary, which Unwrap returns.
+type ReplicatedDatabase struct {
+	primary  Database
+	replicas []*replica
+	sticky   time.Duration
+	next     atomic.Uint64
+	stop     chan struct{}
+	stopped  sync.WaitGroup
+	close    sync.Once
+}
+
+// NewReplicatedDatabase creates a new database decorator that routes the operations to the primary and its replicas.
+// Every replica starts healthy, and the replicas that implement Pinger are checked at every interval.
+// primary: The database that receives the writes, the transactions and the reads of the sticky sessions.
+// replicas: The databases that receive the plain reads.
+// sticky: How long the reads of a session are sent to the primary after it wrote, which should exceed the replication lag.
+// interval: The time between the health checks of the replicas. Zero disables the periodic checks.
+// Returns a *ReplicatedDatabase object.
+func NewReplicatedDatabase(primary Database, replicas []Database, sticky, interval time.Duration) *ReplicatedDatabase {
+	r := &ReplicatedDatabase{
+		primary: primary,
+		sticky:  sticky,
+		stop:    make(chan struct{}),
+	}
+	for _, db := range replicas {
+		rep := &replica{db: db}
+		rep.healthy.Store(true)
+		r.replicas = append(r.replicas, rep)
+	}
+	if interval > 0 && len(r.replicas) > 0 {
+		r.stopped.Add(1)
+		go r.watch(interval)
+	}
+	return r
+}
+
+// Unwrap returns the primary database.
+func (r *ReplicatedDatabase) Unwrap() Database {
+	return r.primary
+}
+
+// Create adds a new record to the primary database.
+// ctx: The context for the operation.
+// entity: The record to add.
+// Returns an error if the operation fails.
+func (r *ReplicatedDatabase) Create(ctx context.Context, entity interface{}) error {
+	r.wrote(ctx)
+	return r.primary.Create(ctx, entity)
+}
+
+// Read retrieves a record from a replica, or from the primary if the context requires it.
+// ctx: The context for the operation.
+// entity: The record to retrieve.
+// where: The condition to match.
+// Returns an error if the operation fails.
+func (r *ReplicatedDatabase) Read(ctx context.Context, entity interface{}, where query.Condition) error {
+	return r.read(ctx, func(db Database) error {
+		return db.Read(ctx, entity, where)
+	})
+}
+
+// Update modifies a record in the primary database.
+// ctx: The context for the operation.
+// entity: The record to modify.
+// Returns an error if the operation fails.
+func (r *ReplicatedDatabase) Update(ctx context.Context, entity interface{}) error {
+	r.wrote(ctx)
+	return r.primary.Update(ctx, entity)
+}
+
+// Delete removes a record from the primary database.
+// ctx: The context for the operation.
+// entity: The record to remove.
+// id: The id of the record to remove.
+// Returns an error if the operation fails.
+func (r *ReplicatedDatabase) Delete(ctx context.Context, entity interface{}, id interface{}) error {
+	r.wrote(ctx)
+	return r.primary.Delete(ctx, entity, id)
+}
+
+// ReadAll retrieves all records from a replica, or from the primary if the context requires it.
+// ctx: The context for the operation.
+// entity: The records to retrieve.
+// Returns an error if the operation fails.
+func (r *ReplicatedDatabase) ReadAll(ctx context.Context, entity interface{}) error {
+	return r.read(ctx, func(db Database) error {
+		return db.ReadAll(ctx, entity)
+	})
+}
+
+// Find retrieves the records the query selects from a replica, or from the primary if the context requires it.
+// ctx: The context for the operation.
+// entity: The records to retrieve.
+// q: The condition, ordering and window of the records.
+// Returns an error if the operation fails.
+func (r *ReplicatedDatabase) Find(ctx context.Context, entity interface{}, q query.Query) error {
+	return r.read(ctx, func(db Database) error {
+		return db.Find(ctx, entity, q)
+	})
+}
+
+// DeleteWhere removes the records matching the condition from the primary database.
+// ctx: The context for the operation.
+// entity: The type of the records to remove.
+// where: The condition to match.
+// Returns the number of removed records and an error if the operation fails.
+func (r *ReplicatedDatabase) DeleteWhere(ctx context.Context, entity interface{}, where query.Condition) (int64, error) {
+	r.wrote(ctx)
+	return r.primary.DeleteWhere(ctx, entity, where)
+}
+
+// WithTx runs fn in a transaction of the primary database, whose reads are sent to the primary as well.
+// ctx: The context for the operation.
+// fn: The function to run in the transaction.
+// opts: The isolation level and read-only flag of the transaction.
+// Returns the error returned by fn, or an error if the transaction cannot be started or committed.
+func (r *ReplicatedDatabase) WithTx(ctx context.Context, fn func(ctx context.Context) error, opts ...*sql.TxOptions) error {
+	if len(opts) == 0 || opts[0] == nil || !opts[0].ReadOnly {
+		r.wrote(ctx)
+	}
+	return r.primary.WithTx(ctx, func(ctx context.Context) error {
+		return fn(WithPrimary(ctx))
+	}, opts...)
+}
+
+// Stats reports the usage of the connection pools of the primary and the replicas, added up.
+// Returns the statistics of the pools.
+func (r *ReplicatedDatabase) Stats() sql.DBStats {
+	var total sql.DBStats
+	for _, db := range append([]Database{r.primary}, r.databases()...) {
+		stats := db.Stats()
+		total.MaxOpenConnections += stats.MaxOpenConnections
+		total.OpenConnections += stats.OpenConnections
+		total.InUse += stats.InUse
+		total.Idle += stats.Idle
+		total.WaitCount += stats.WaitCount
+		total.WaitDuration += stats.WaitDuration
+		total.MaxIdleClosed += stats.MaxIdleClosed
+		total.MaxIdleTimeClosed += stats.MaxIdleTimeClosed
+		total.MaxLifetimeClosed += stats.MaxLifetimeClosed
+	}
+	return total
+}
+
+// Close stops the health checks and closes the primary and the replicas.
+// Returns the errors of the databases that cannot be closed.
+func (r *ReplicatedDatabase) Close() error {
+	var errs []error
+	r.close.Do(func() {
+		close(r.stop)
+		r.stopped.Wait()
+		for _, db := range append([]Database{r.primary}, r.databases()...) {
+			if closer, ok := db.(io.Closer); ok {
+				errs = append(errs, closer.Close())
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// Check runs the health checks of the replicas at once, instead of waiting for the next interval.
+// ctx: The context for the operation.
+func (r *ReplicatedDatabase) Check(ctx context.Context) {
+	for _, rep := range r.replicas {
+		if pinger, ok := rep.db.(Pinger); ok {
+			rep.healthy.Store(pinger.Ping(ctx) == nil)
+		}
+	}
+}
+
+// Healthy returns the number of replicas that passed their last health check.
+func (r *ReplicatedDatabase) Healthy() int {
+	healthy := 0
+	for _, rep := range r.replicas {
+		if rep.healthy.Load() {
+			healthy++
+		}
+	}
+	return healthy
+}
+
+// watch runs the health checks at every interval until the database is closed.
+func (r *ReplicatedDatabase) watch(interval time.Duration) {
+	defer r.stopped.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			r.Check(ctx)
+			cancel()
+		}
+	}
+}
+
+// read runs a read on the database the context is routed to.
+// A replica that fails a read and then its health check is left out until it passes one again, and the read is retried on the primary.
+func (r *ReplicatedDatabase) read(ctx context.Context, fn func(db Database) error) error {
+	rep := r.route(ctx)
+	if rep == nil {
+		return fn(r.primary)
+	}
+	err := fn(rep.db)
+	if err == nil || errors.Is(err, ErrNotFound) || ctx.Err() != nil {
+		return err
+	}
+	pinger, ok := rep.db.(Pinger)
+	if !ok || pinger.Ping(ctx) == nil {
+		return err
+	}
+	rep.healthy.Store(false)
+	return fn(r.primary)
+}
+
+// route returns the replica the reads of the context are sent to, or nil if they are sent to the primary.
+// The healthy replicas take turns.
+func (r *ReplicatedDatabase) route(ctx context.Context) *replica {
+	if PrimaryFromContext(ctx) {
+		return nil
+	}
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+		if last := s.lastWrite.Load(); last != 0 && time.Since(time.Unix(0, last)) < r.sticky {
+			return nil
+		}
+	}
+	n := uint64(len(r.replicas))
+	start := r.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if rep := r.replicas[(start+i)%n]; rep.healthy.Load() {
+			return rep
+		}
+	}
+	return nil
+}
+
+// wrote records a write in the session of the context, if it has one.
+func (r *ReplicatedDatabase) wrote(ctx context.Context) {
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+		s.lastWrite.Store(time.Now().UnixNano())
+	}
+}
+
+// databases returns the replicas.
+func (r *ReplicatedDatabase) databases() []Database {
+	dbs := make([]Database, 0, len(r.replicas))
+	for _, rep := range r.replicas {
+		dbs = append(dbs, rep.db)
+	}
+	return dbs
+}
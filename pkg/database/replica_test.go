@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	authmigrations "github.com/nikita-voronoy/go-clean-arch/internal/modules/auth/migrations"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/sqlite"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openSqlite opens and migrates a SQLite file, which stands in for a primary or a replica.
+func openSqlite(t *testing.T, path string) *sqlite.Database {
+	db, err := sqlite.NewDatabase(&config.Config{DB: config.DatabaseConfig{Sqlite: config.SqliteConfig{DatabasePath: path}}})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	migrator, err := db.Migrator(authmigrations.Source)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background(), 0)
+	require.NoError(t, err)
+	return db
+}
+
+// newReplicated returns a primary, two replicas, and the database that routes the operations to them.
+// The files are not replicated, so that every read reveals the database it was sent to.
+func newReplicated(t *testing.T, sticky time.Duration) (*ReplicatedDatabase, Database, []*sqlite.Database) {
+	dir := t.TempDir()
+	primary := openSqlite(t, filepath.Join(dir, "primary.sqlite3"))
+	replicas := []*sqlite.Database{openSqlite(t, filepath.Join(dir, "replica1.sqlite3")), openSqlite(t, filepath.Join(dir, "replica2.sqlite3"))}
+	db := NewReplicatedDatabase(primary, []Database{replicas[0], replicas[1]}, sticky, 0)
+	t.Cleanup(func() { _ = db.Close() })
+	return db, primary, replicas
+}
+
+// usernames returns the usernames of the users the database returns.
+func usernames(t *testing.T, ctx context.Context, db Database) []string {
+	var users []entities.User
+	require.NoError(t, db.Find(ctx, &users, query.Query{}.OrderedBy(query.Asc("username"))))
+	names := make([]string, 0, len(users))
+	for _, user := range users {
+		names = append(names, user.Username)
+	}
+	return names
+}
+
+// readUser reads the user with the ID into a new record.
+func readUser(ctx context.Context, db Database, id uuid.UUID) (entities.User, error) {
+	var user entities.User
+	err := db.Read(ctx, &user, query.Eq("id", id))
+	return user, err
+}
+
+func newReplicaUser(name string) *entities.User {
+	return &entities.User{ID: uuid.New(), Username: name, Email: name + "@example.com"}
+}
+
+func TestReplicatedDatabaseRoutesReadsToReplicas(t *testing.T) {
+	db, primary, replicas := newReplicated(t, time.Minute)
+	ctx := context.Background()
+	require.NoError(t, replicas[0].Create(ctx, newReplicaUser("replica1")))
+	require.NoError(t, replicas[1].Create(ctx, newReplicaUser("replica2")))
+
+	seen := map[string]int{}
+	for i := 0; i < 4; i++ {
+		names := usernames(t, ctx, db)
+		require.Len(t, names, 1)
+		seen[names[0]]++
+	}
+	assert.Equal(t, map[string]int{"replica1": 2, "replica2": 2}, seen, "The replicas take turns")
+
+	alice := newReplicaUser("alice")
+	require.NoError(t, db.Create(ctx, alice))
+	assert.Equal(t, []string{"alice"}, usernames(t, ctx, primary), "The writes go to the primary")
+	_, err := readUser(ctx, db, alice.ID)
+	assert.ErrorIs(t, err, ErrNotFound, "A read without a session goes to a replica")
+	_, err = readUser(WithPrimary(ctx), db, alice.ID)
+	require.NoError(t, err)
+
+	var read entities.User
+	require.NoError(t, db.WithTx(ctx, func(ctx context.Context) error {
+		bob := newReplicaUser("bob")
+		if err := db.Create(ctx, bob); err != nil {
+			return err
+		}
+		read, err = readUser(ctx, db, bob.ID)
+		return err
+	}), "The reads of a transaction go to the primary")
+	assert.Equal(t, "bob", read.Username)
+}
+
+func TestReplicatedDatabaseReadsYourWrites(t *testing.T) {
+	db, _, _ := newReplicated(t, 100*time.Millisecond)
+	ctx := WithSession(context.Background())
+
+	alice := newReplicaUser("alice")
+	_, err := readUser(ctx, db, alice.ID)
+	assert.ErrorIs(t, err, ErrNotFound, "A session that did not write reads from a replica")
+	require.NoError(t, db.Create(ctx, alice))
+	_, err = readUser(ctx, db, alice.ID)
+	assert.NoError(t, err, "A session reads its writes from the primary")
+	_, err = readUser(WithSession(context.Background()), db, alice.ID)
+	assert.ErrorIs(t, err, ErrNotFound, "Other sessions are not sticky")
+
+	time.Sleep(150 * time.Millisecond)
+	_, err = readUser(ctx, db, alice.ID)
+	assert.ErrorIs(t, err, ErrNotFound, "The session reads from the replicas once the window is over")
+}
+
+func TestReplicatedDatabaseSkipsUnhealthyReplicas(t *testing.T) {
+	db, primary, replicas := newReplicated(t, time.Minute)
+	ctx := context.Background()
+	require.NoError(t, primary.Create(ctx, newReplicaUser("primary")))
+	require.NoError(t, replicas[1].Create(ctx, newReplicaUser("replica2")))
+
+	require.NoError(t, replicas[0].Close())
+	db.Check(ctx)
+	assert.Equal(t, 1, db.Healthy())
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, []string{"replica2"}, usernames(t, ctx, db), "The unhealthy replica is left out")
+	}
+
+	require.NoError(t, replicas[1].Close())
+	assert.Equal(t, []string{"primary"}, usernames(t, ctx, db), "A failed read is retried on the primary")
+	assert.Equal(t, 0, db.Healthy(), "A replica that fails a read and its health check is left out")
+	assert.Equal(t, []string{"primary"}, usernames(t, ctx, db), "The reads go to the primary once no replica is healthy")
+}
+
+func TestNewDatabaseWithReplicas(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{DB: config.DatabaseConfig{
+		DatabaseType: "sqlite",
+		Sqlite:       config.SqliteConfig{DatabasePath: filepath.Join(dir, "primary.sqlite3")},
+		Replication: config.ReplicationConfig{
+			Replicas:      []config.ReplicaConfig{{DatabasePath: filepath.Join(dir, "replica1.sqlite3")}, {DatabasePath: filepath.Join(dir, "replica2.sqlite3")}},
+			StickySeconds: 5,
+		},
+	}}
+	db, err := NewDatabase(cfg)
+	require.NoError(t, err)
+	replicated, ok := db.(*ReplicatedDatabase)
+	require.True(t, ok, "The configured replicas must be used")
+	t.Cleanup(func() { _ = replicated.Close() })
+	assert.Equal(t, 2, replicated.Healthy())
+
+	migrator, err := NewMigrator(db, authmigrations.Source)
+	require.NoError(t, err, "The primary is migrated")
+	_, err = migrator.Up(context.Background(), 0)
+	require.NoError(t, err)
+	require.NoError(t, db.Create(context.Background(), newReplicaUser("alice")))
+
+	cfg.DB.DatabaseType = "bolt"
+	cfg.DB.Bolt = config.BoltConfig{Path: filepath.Join(dir, "db.bolt"), TimeoutSeconds: 1}
+	_, err = NewDatabase(cfg)
+	assert.Error(t, err, "The embedded databases have no replicas")
+}
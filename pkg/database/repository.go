@@ -0,0 +1,143 @@
+// Package database provides the generic repository of the entities stored in a database.
+package database
+
+import (
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"golang.org/x/net/context"
+)
+
+// Repository struct represents the typed storage of the entities of type T, whose primary key "id" is of type ID.
+// It implements the CRUD operations every repository needs on top of a Database, so that a repository of a module
+// only adds the lookups specific to its entity.
+type Repository[T any, ID comparable] struct {
+	db Database
+}
+
+// NewRepository creates a new repository of the entities of type T in the database.
+// db: The database the entities are stored in.
+// Returns a Repository object.
+func NewRepository[T any, ID comparable](db Database) Repository[T, ID] {
+	return Repository[T, ID]{db: db}
+}
+
+// Create adds a new entity.
+// ctx: The context for the operation.
+// entity: The entity to add.
+// Returns an error if the operation fails, or a *ConflictError if a unique column is taken.
+func (r Repository[T, ID]) Create(ctx context.Context, entity T) error {
+	_, err := r.Insert(ctx, entity)
+	return err
+}
+
+// Insert adds a new entity and returns it as stored, with the values the database gave it, such as an auto-incremented id,
+// the timestamps and the version.
+// ctx: The context for the operation.
+// entity: The entity to add.
+// Returns the stored entity, and an error if the operation fails, or a *ConflictError if a unique column is taken.
+func (r Repository[T, ID]) Insert(ctx context.Context, entity T) (T, error) {
+	if err := r.db.Create(ctx, &entity); err != nil {
+		var zero T
+		return zero, err
+	}
+	return entity, nil
+}
+
+// Get retrieves the entity with the id.
+// ctx: The context for the operation.
+// id: The id of the entity.
+// Returns the entity, and ErrNotFound if there is none.
+func (r Repository[T, ID]) Get(ctx context.Context, id ID) (T, error) {
+	var entity T
+	if err := r.db.Read(ctx, &entity, query.Eq("id", id)); err != nil {
+		var zero T
+		return zero, err
+	}
+	return entity, nil
+}
+
+// First retrieves the first entity, in primary key order, that satisfies the condition.
+// ctx: The context for the operation.
+// where: The condition the entity satisfies, e.g. query.Or(query.Eq("email", email), query.Eq("username", username)).
+// Returns the entity, and ErrNotFound if there is none.
+func (r Repository[T, ID]) First(ctx context.Context, where query.Condition) (T, error) {
+	var entity T
+	if err := r.db.Read(ctx, &entity, where); err != nil {
+		var zero T
+		return zero, err
+	}
+	return entity, nil
+}
+
+// Update modifies an entity, which is identified by its id.
+// ctx: The context for the operation.
+// entity: The entity to modify.
+// Returns an error if the operation fails, or a *ConflictError if a unique column is taken.
+func (r Repository[T, ID]) Update(ctx context.Context, entity T) error {
+	_, err := r.Save(ctx, entity)
+	return err
+}
+
+// Save modifies an entity, which is identified by its id, and returns it as stored, with its new version and modification time.
+// ctx: The context for the operation.
+// entity: The entity to modify.
+// Returns the stored entity, and an error if the operation fails, or a *ConflictError if a unique column is taken.
+func (r Repository[T, ID]) Save(ctx context.Context, entity T) (T, error) {
+	if err := r.db.Update(ctx, &entity); err != nil {
+		var zero T
+		return zero, err
+	}
+	return entity, nil
+}
+
+// Delete removes the entity with the id. Removing an entity that does not exist is not an error.
+// ctx: The context for the operation.
+// id: The id of the entity.
+// Returns an error if the operation fails.
+func (r Repository[T, ID]) Delete(ctx context.Context, id ID) error {
+	var entity T
+	return r.db.Delete(ctx, entity, id)
+}
+
+// List retrieves the entities the query selects.
+// ctx: The context for the operation.
+// q: The condition, ordering, window and cursor of the entities. The zero Query lists every entity.
+// Returns the entities and an error if the operation fails.
+func (r Repository[T, ID]) List(ctx context.Context, q query.Query) ([]T, error) {
+	var entities []T
+	if err := r.db.Find(ctx, &entities, q); err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
+
+// Search retrieves the entities that match a full-text search, most relevant first.
+// ctx: The context for the operation.
+// search: The terms and the window of the search, e.g. query.Search("ali example").Window(20, 0).
+// Returns the entities, their ranks, higher is more relevant, the number of matching entities regardless of the window,
+// and ErrSearchNotSupported if the database has no full-text index of the entities.
+func (r Repository[T, ID]) Search(ctx context.Context, search query.TextSearch) ([]T, []float64, int64, error) {
+	var entities []T
+	ranks, total, err := Search(ctx, r.db, &entities, search)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return entities, ranks, total, nil
+}
+
+// Count counts the entities that satisfy the condition.
+// ctx: The context for the operation.
+// where: The condition the entities satisfy. A nil condition counts every entity.
+// Returns the number of entities and an error if the operation fails.
+func (r Repository[T, ID]) Count(ctx context.Context, where query.Condition) (int64, error) {
+	var entity T
+	return Count(ctx, r.db, entity, where)
+}
+
+// Exists reports whether an entity satisfies the condition.
+// ctx: The context for the operation.
+// where: The condition an entity satisfies.
+// Returns true if there is such an entity, and an error if the operation fails.
+func (r Repository[T, ID]) Exists(ctx context.Context, where query.Condition) (bool, error) {
+	entities, err := r.List(ctx, query.Where(where).Window(1, 0))
+	return len(entities) > 0, err
+}
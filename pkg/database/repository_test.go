@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/memory"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	users := NewRepository[entities.User, uuid.UUID](memory.NewDatabase())
+
+	alice := entities.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com"}
+	inserted, err := users.Insert(ctx, alice)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), inserted.Metadata.Version, "the stored entity is returned")
+	modified := inserted
+	modified.Email = "alice@example.org"
+	saved, err := users.Save(ctx, modified)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), saved.Metadata.Version)
+	assert.Equal(t, "alice@example.org", saved.Email)
+	_, err = users.Save(ctx, inserted)
+	assert.ErrorIs(t, err, ErrStale, "the stale version is rejected")
+	require.NoError(t, users.Create(ctx, entities.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com"}))
+
+	first, err := users.First(ctx, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, first.ID)
+
+	found, err := users.First(ctx, query.Eq("username", "alice"))
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, found.ID)
+
+	listed, err := users.List(ctx, query.Query{}.OrderedBy(query.Desc("username")))
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	assert.Equal(t, "bob", listed[0].Username)
+
+	require.NoError(t, users.Delete(ctx, alice.ID))
+	_, err = users.Get(ctx, alice.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = users.First(ctx, query.Eq("username", "alice"))
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,46 @@
+// Package database provides the functionality to search the records of a database with its full-text index.
+package database
+
+import (
+	"context"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/dberr"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+)
+
+// ErrSearchNotSupported is returned when neither the database nor the databases it decorates have a full-text index of the records,
+// such as the in-memory, the embedded key-value and the MySQL databases, or a SQLite library without the FTS5 extension.
+var ErrSearchNotSupported = dberr.ErrSearchNotSupported
+
+// Searcher is an interface implemented by the databases that search the records with a full-text index, which the migrations
+// of the table create: an FTS5 table named "<table>_search" with the primary key in its "id" column for SQLite, and a tsvector
+// column named "search" with a GIN index for PostgreSQL. The migrations choose the indexed columns and their weights, and name the
+// columns of the FTS5 tables as the columns of the table, so that a search can be restricted to some of them.
+type Searcher interface {
+	// Search retrieves the records that match the search, most relevant first.
+	// ctx: The context for the operation.
+	// entity: A pointer to the slice of the records to retrieve.
+	// search: The terms and the window of the search.
+	// Returns the rank of every record, higher is more relevant, the number of matching records regardless of the window,
+	// and ErrSearchNotSupported if the table has no full-text index.
+	Search(ctx context.Context, entity interface{}, search query.TextSearch) ([]float64, int64, error)
+}
+
+// Search retrieves the records that match a full-text search with the full-text index of the database, or of the database it decorates.
+// ctx: The context for the operation.
+// db: The database to search.
+// entity: A pointer to the slice of the records to retrieve.
+// search: The terms and the window of the search.
+// Returns the rank of every record, the number of matching records, and ErrSearchNotSupported if no database has a full-text index.
+func Search(ctx context.Context, db Database, entity interface{}, search query.TextSearch) ([]float64, int64, error) {
+	for db != nil {
+		if searcher, ok := db.(Searcher); ok {
+			return searcher.Search(ctx, entity, search)
+		}
+		wrapper, ok := db.(Wrapper)
+		if !ok {
+			break
+		}
+		db = wrapper.Unwrap()
+	}
+	return nil, 0, ErrSearchNotSupported
+}
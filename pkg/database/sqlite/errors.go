@@ -0,0 +1,50 @@
+package sqlite
+
+import (
+	"errors"
+	"github.com/mattn/go-sqlite3"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/dberr"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// translate maps the errors of GORM and SQLite to the errors of the database package.
+// err: The error of an operation.
+// Returns the mapped error, or err if it has no counterpart.
+func translate(err error) error {
+	var sqliteErr sqlite3.Error
+	switch {
+	case err == nil, errors.Is(err, dberr.ErrNotFound), errors.Is(err, dberr.ErrConflict), errors.Is(err, dberr.ErrTimeout):
+		return err
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return dberr.ErrNotFound
+	case !errors.As(err, &sqliteErr):
+		return dberr.Context(err)
+	case sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey:
+		return conflict(sqliteErr)
+	case sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked:
+		// The busy timeout elapsed while another connection held the lock.
+		return dberr.Timeout(err)
+	default:
+		return err
+	}
+}
+
+// conflict returns the conflict error of a unique constraint violation.
+// SQLite names the columns in the message, e.g. "UNIQUE constraint failed: memberships.organization_id, memberships.user_id".
+func conflict(err sqlite3.Error) error {
+	conflict := &dberr.ConflictError{Err: err}
+	_, columns, ok := strings.Cut(err.Error(), "constraint failed: ")
+	if !ok {
+		return conflict
+	}
+	for _, column := range strings.Split(columns, ", ") {
+		table, name, ok := strings.Cut(column, ".")
+		if !ok {
+			continue
+		}
+		conflict.Table = table
+		conflict.Fields = append(conflict.Fields, name)
+	}
+	return conflict
+}
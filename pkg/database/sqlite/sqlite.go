@@ -3,30 +3,113 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 	"github.com/nikita-voronoy/go-clean-arch/config"
-	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/dberr"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/gormquery"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/gormtx"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/migrate"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"strings"
+	"time"
 )
 
+// busyTimeout is the time an operation waits for the lock of a database another connection writes to, unless another time is configured.
+const busyTimeout = 5 * time.Second
+
+// journalModes are the journal modes of SQLite.
+var journalModes = map[string]bool{"DELETE": true, "TRUNCATE": true, "PERSIST": true, "MEMORY": true, "WAL": true, "OFF": true}
+
+// like is the SQL of a pattern match. SQLite's LIKE ignores the case of the ASCII letters.
+const like = `? LIKE ? ESCAPE '\'`
+
 // Database struct represents a SQLite database connection.
 type Database struct {
-	db *gorm.DB
+	db        *gorm.DB
+	isolation sql.IsolationLevel
 }
 
 // NewDatabase creates a new SQLite database connection based on the provided configuration.
 // cfg: The configuration object that contains the SQLite database settings.
 // Returns a Database object if the database connection is successfully established.
-// Returns an error if the connection cannot be established or if the database migration fails.
+// Returns an error if the isolation level or the journal mode is unknown, or if the connection cannot be established.
+// The schema is created by the migrations, see Migrator.
 func NewDatabase(cfg *config.Config) (*Database, error) {
-	conn, err := gorm.Open(sqlite.Open(cfg.DB.Sqlite.DatabasePath), &gorm.Config{})
+	isolation, err := gormtx.Isolation(cfg.DB.Isolation)
+	if err != nil {
+		return nil, err
+	}
+	if mode := cfg.DB.Sqlite.JournalMode; mode != "" && !journalModes[strings.ToUpper(mode)] {
+		return nil, fmt.Errorf("unknown journal mode %q", mode)
+	}
+	conn, err := gorm.Open(sqlite.Open(DSN(cfg.DB.Sqlite)), &gorm.Config{})
 	if err != nil {
 		return nil, err // return an error instead of panicking
 	}
-	if err := conn.AutoMigrate(entities.UserLogin{}, entities.User{Metadata: entities.Metadata{}}); err != nil {
+
+	sqlDB, err := conn.DB()
+	if err != nil {
 		return nil, err
 	}
-	return &Database{db: conn}, nil
+	sqlDB.SetMaxOpenConns(cfg.DB.Sqlite.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.DB.Sqlite.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(time.Duration(cfg.DB.Sqlite.ConnMaxLifetimeMinutes) * time.Minute)
+	return &Database{db: conn, isolation: isolation}, nil
+}
+
+// DSN builds the data source name of the provided SQLite configuration, which sets the pragmas of every connection.
+// The transactions take the write lock when they begin, so that concurrent transactions wait for each other
+// instead of failing when they upgrade their read lock, and a locked database is retried for the busy timeout.
+// cfg: The SQLite configuration.
+// Returns the data source name.
+func DSN(cfg config.SqliteConfig) string {
+	separator := "?"
+	if strings.Contains(cfg.DatabasePath, "?") {
+		separator = "&"
+	}
+	timeout := busyTimeout.Milliseconds()
+	if cfg.BusyTimeoutMilliseconds > 0 {
+		timeout = int64(cfg.BusyTimeoutMilliseconds)
+	}
+	dsn := fmt.Sprintf("%s%s_txlock=immediate&_busy_timeout=%d", cfg.DatabasePath, separator, timeout)
+	if cfg.JournalMode != "" {
+		dsn += "&_journal_mode=" + strings.ToUpper(cfg.JournalMode)
+	}
+	if cfg.ForeignKeys {
+		dsn += "&_foreign_keys=1"
+	}
+	return dsn
+}
+
+// Migrator returns the migrator of the SQLite database.
+// The repeatable scripts create the full-text indexes if the SQLite library is built with the FTS5 extension, see the sqlite_fts5 build tag,
+// including in a database migrated by a library without it.
+// A library without the extension cannot run the triggers that keep the indexes up to date, so it refuses a database that has them.
+// sources: The migrations of the modules.
+// Returns a *migrate.Migrator object and an error if the migrations cannot be loaded or the database needs the FTS5 extension.
+func (g Database) Migrator(sources ...migrate.Source) (*migrate.Migrator, error) {
+	sqlDB, err := g.db.DB()
+	if err != nil {
+		return nil, err
+	}
+	var fts5 bool
+	if err := sqlDB.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err != nil {
+		return nil, err
+	}
+	if fts5 {
+		return migrate.New(sqlDB, migrate.SQLiteFTS5(), sources...)
+	}
+	var indexes int64
+	if err := sqlDB.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND sql LIKE '%USING fts5%'").Scan(&indexes); err != nil {
+		return nil, err
+	}
+	if indexes > 0 {
+		return nil, fmt.Errorf("the database has full-text indexes, which the SQLite library cannot update without the FTS5 extension: build with -tags sqlite_fts5")
+	}
+	return migrate.New(sqlDB, migrate.SQLite(), sources...)
 }
 
 // Create adds a new record to the SQLite database.
@@ -34,25 +117,28 @@ func NewDatabase(cfg *config.Config) (*Database, error) {
 // entity: The record to add.
 // Returns an error if the operation fails.
 func (g Database) Create(ctx context.Context, entity interface{}) error {
-	return g.db.WithContext(ctx).Create(entity).Error
+	return translate(gormtx.Conn(ctx, g.db).Create(entity).Error)
 }
 
 // Read retrieves a record from the SQLite database.
 // ctx: The context for the operation.
 // entity: The record to retrieve.
-// compareString: The field to compare.
-// compareValues: The values to compare.
-// Returns an error if the operation fails.
-func (g Database) Read(ctx context.Context, entity interface{}, compareString string, compareValues ...interface{}) error {
-	return g.db.WithContext(ctx).Where(compareString, compareValues...).First(entity).Error
+// where: The condition to match. A nil condition matches every record.
+// Returns database.ErrNotFound if no record matches, or an error if the operation fails.
+func (g Database) Read(ctx context.Context, entity interface{}, where query.Condition) error {
+	conn, err := gormquery.Where(gormtx.Conn(ctx, g.db), entity, where, like)
+	if err != nil {
+		return err
+	}
+	return translate(conn.First(entity).Error)
 }
 
-// Update modifies a record in the SQLite database.
+// Update modifies a record in the SQLite database, or adds it if no record has its primary key.
 // ctx: The context for the operation.
-// entity: The record to modify.
-// Returns an error if the operation fails.
+// entity: The record to modify. The version of a versioned record must be the stored one, and is incremented.
+// Returns database.ErrConflict if the record violates a unique constraint or is based on an outdated version, or an error if the operation fails.
 func (g Database) Update(ctx context.Context, entity interface{}) error {
-	return g.db.WithContext(ctx).Save(entity).Error
+	return translate(gormquery.Save(gormtx.Conn(ctx, g.db), entity))
 }
 
 // Delete removes a record from the SQLite database.
@@ -61,7 +147,7 @@ func (g Database) Update(ctx context.Context, entity interface{}) error {
 // id: The id of the record to remove.
 // Returns an error if the operation fails.
 func (g Database) Delete(ctx context.Context, entity interface{}, id interface{}) error {
-	return g.db.WithContext(ctx).Where("id = ?", id).Delete(entity).Error
+	return translate(gormtx.Conn(ctx, g.db).Where("id = ?", id).Delete(entity).Error)
 }
 
 // ReadAll retrieves all records from the SQLite database.
@@ -69,6 +155,129 @@ func (g Database) Delete(ctx context.Context, entity interface{}, id interface{}
 // entity: The records to retrieve.
 // Returns an error if the operation fails.
 func (g Database) ReadAll(ctx context.Context, entity interface{}) error {
-	result := g.db.WithContext(ctx).Find(entity)
-	return result.Error
+	result := gormtx.Conn(ctx, g.db).Find(entity)
+	return translate(result.Error)
+}
+
+// Find retrieves the records the query selects from the SQLite database.
+// ctx: The context for the operation.
+// entity: The records to retrieve.
+// q: The condition, ordering and window of the records.
+// Returns an error if the operation fails.
+func (g Database) Find(ctx context.Context, entity interface{}, q query.Query) error {
+	conn, err := gormquery.Find(gormtx.Conn(ctx, g.db), entity, q, like)
+	if err != nil {
+		return err
+	}
+	return translate(conn.Find(entity).Error)
+}
+
+// Count counts the records matching the condition in the SQLite database.
+// ctx: The context for the operation.
+// entity: A record of the type of the records to count.
+// where: The condition to match. A nil condition matches every record.
+// Returns the number of matching records and an error if the operation fails.
+func (g Database) Count(ctx context.Context, entity interface{}, where query.Condition) (int64, error) {
+	conn, err := gormquery.Where(gormtx.Conn(ctx, g.db), entity, where, like)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	return count, translate(conn.Model(entity).Count(&count).Error)
+}
+
+// DeleteWhere removes the records matching the condition from the SQLite database.
+// ctx: The context for the operation.
+// entity: The type of the records to remove.
+// where: The condition to match. A nil condition matches every record.
+// Returns the number of removed records and an error if the operation fails.
+func (g Database) DeleteWhere(ctx context.Context, entity interface{}, where query.Condition) (int64, error) {
+	conn, err := gormquery.Where(gormtx.Conn(ctx, g.db), entity, where, like)
+	if err != nil {
+		return 0, err
+	}
+	result := conn.Delete(entity)
+	return result.RowsAffected, translate(result.Error)
+}
+
+// Search retrieves the records that match a full-text search from the FTS5 table of their table, "<table>_search".
+// Every term matches the words it prefixes, in the columns of the search if it names them, and the records are ranked by bm25
+// with the weights the table is configured with.
+// ctx: The context for the operation.
+// entity: A pointer to the slice of the records to retrieve.
+// search: The terms and the window of the search.
+// Returns the rank of every record, the number of matching records, and database.ErrSearchNotSupported if the table has no FTS5 table.
+func (g Database) Search(ctx context.Context, entity interface{}, search query.TextSearch) ([]float64, int64, error) {
+	conn := gormtx.Conn(ctx, g.db)
+	table, _, err := gormquery.Table(conn, entity)
+	if err != nil {
+		return nil, 0, err
+	}
+	index := table + "_search"
+	var tables int64
+	if err := conn.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", index).Scan(&tables).Error; err != nil {
+		return nil, 0, translate(err)
+	}
+	if tables == 0 {
+		return nil, 0, dberr.ErrSearchNotSupported
+	}
+	// The terms are quoted so that they are not read as the operators of the query syntax.
+	prefixes := make([]string, 0, len(search.Terms))
+	for _, term := range search.Terms {
+		prefixes = append(prefixes, `"`+strings.ReplaceAll(term, `"`, `""`)+`"*`)
+	}
+	match := strings.Join(prefixes, " ")
+	if len(search.Columns) > 0 {
+		columns := make([]string, 0, len(search.Columns))
+		for _, column := range search.Columns {
+			columns = append(columns, `"`+strings.ReplaceAll(column, `"`, `""`)+`"`)
+		}
+		match = fmt.Sprintf("{%s} : (%s)", strings.Join(columns, " "), match)
+	}
+	index = conn.Statement.Quote(index)
+	ranks, total, err := gormquery.Search(conn, entity, search, "-1",
+		fmt.Sprintf("SELECT id, -rank AS score FROM %s WHERE %s MATCH ? ORDER BY rank, id", index, index),
+		fmt.Sprintf("SELECT count(*) FROM %s WHERE %s MATCH ?", index, index),
+		match)
+	return ranks, total, translate(err)
+}
+
+// WithTx runs fn in a transaction of the SQLite database, or in a savepoint if the context already carries a transaction.
+// ctx: The context for the operation.
+// fn: The function to run in the transaction.
+// opts: The isolation level and read-only flag of the transaction, instead of the configured ones.
+// Returns the error returned by fn, or an error if the transaction cannot be started or committed.
+func (g Database) WithTx(ctx context.Context, fn func(ctx context.Context) error, opts ...*sql.TxOptions) error {
+	return translate(gormtx.WithTx(ctx, g.db, g.isolation, fn, opts...))
+}
+
+// Stats reports the usage of the connection pool of the SQLite database.
+// Returns the statistics of the pool.
+func (g Database) Stats() sql.DBStats {
+	sqlDB, err := g.db.DB()
+	if err != nil {
+		return sql.DBStats{}
+	}
+	return sqlDB.Stats()
+}
+
+// Ping checks that the SQLite database can be opened.
+// ctx: The context for the operation.
+// Returns an error if the database cannot be opened.
+func (g Database) Ping(ctx context.Context) error {
+	sqlDB, err := g.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Close closes the connection pool of the SQLite database.
+// Returns an error if the operation fails.
+func (g Database) Close() error {
+	sqlDB, err := g.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
@@ -5,10 +5,14 @@ import (
 	"github.com/google/uuid"
 	"github.com/nikita-voronoy/go-clean-arch/config"
 	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/migrate"
+	"path/filepath"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewDatabase(t *testing.T) {
@@ -26,17 +30,28 @@ func TestNewDatabase(t *testing.T) {
 	assert.NotNil(t, db, "Database is nil")
 }
 
+// users is a migration that creates the table of the users.
+var users = migrate.Source{Module: "test", FS: fstest.MapFS{
+	"sqlite/1_create_users.up.sql": {Data: []byte(`CREATE TABLE users (id uuid PRIMARY KEY, username text NOT NULL UNIQUE, password text, email text NOT NULL UNIQUE,
+		created_at datetime, updated_at datetime, last_login_at datetime, token text, email_index text UNIQUE, token_index text, token_organization_id uuid, external_id text, deactivated numeric NOT NULL DEFAULT false, version integer NOT NULL DEFAULT 1)`)},
+	"sqlite/1_create_users.down.sql": {Data: []byte(`DROP TABLE users`)},
+}}
+
 func TestCreate(t *testing.T) {
 	cfg := &config.Config{
 		DB: config.DatabaseConfig{
 			Sqlite: config.SqliteConfig{
-				DatabasePath: ":memory:",
+				DatabasePath: filepath.Join(t.TempDir(), "create.sqlite3"),
 			},
 		},
 	}
 
 	db, err := NewDatabase(cfg)
-	assert.NoError(t, err, "Failed to create new database")
+	require.NoError(t, err, "Failed to create new database")
+	migrator, err := db.Migrator(users)
+	require.NoError(t, err, "Failed to load the migrations")
+	_, err = migrator.Up(context.Background(), 0)
+	require.NoError(t, err, "Failed to migrate the database")
 
 	user := &entities.User{
 		ID:       uuid.New(),
@@ -54,3 +69,42 @@ func TestCreate(t *testing.T) {
 	err = db.Create(context.Background(), user)
 	assert.NoError(t, err, "Failed to create user")
 }
+
+func TestDSN(t *testing.T) {
+	assert.Equal(t, "db.sqlite3?_txlock=immediate&_busy_timeout=5000", DSN(config.SqliteConfig{DatabasePath: "db.sqlite3"}))
+	assert.Equal(t, "file:db.sqlite3?cache=shared&_txlock=immediate&_busy_timeout=5000", DSN(config.SqliteConfig{DatabasePath: "file:db.sqlite3?cache=shared"}))
+	assert.Equal(t, "db.sqlite3?_txlock=immediate&_busy_timeout=250&_journal_mode=WAL&_foreign_keys=1",
+		DSN(config.SqliteConfig{DatabasePath: "db.sqlite3", JournalMode: "wal", BusyTimeoutMilliseconds: 250, ForeignKeys: true}))
+}
+
+func TestNewDatabaseAppliesPragmas(t *testing.T) {
+	db, err := NewDatabase(&config.Config{DB: config.DatabaseConfig{Sqlite: config.SqliteConfig{
+		DatabasePath:            filepath.Join(t.TempDir(), "db.sqlite3"),
+		JournalMode:             "wal",
+		BusyTimeoutMilliseconds: 1234,
+		ForeignKeys:             true,
+		MaxOpenConns:            3,
+	}}})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	var journalMode string
+	var busyTimeout, foreignKeys int
+	require.NoError(t, db.db.Raw("PRAGMA journal_mode").Scan(&journalMode).Error)
+	require.NoError(t, db.db.Raw("PRAGMA busy_timeout").Scan(&busyTimeout).Error)
+	require.NoError(t, db.db.Raw("PRAGMA foreign_keys").Scan(&foreignKeys).Error)
+	assert.Equal(t, "wal", journalMode)
+	assert.Equal(t, 1234, busyTimeout)
+	assert.Equal(t, 1, foreignKeys)
+	assert.Equal(t, 3, db.Stats().MaxOpenConnections)
+}
+
+func TestNewDatabaseRejectsUnknownJournalMode(t *testing.T) {
+	_, err := NewDatabase(&config.Config{DB: config.DatabaseConfig{Sqlite: config.SqliteConfig{DatabasePath: ":memory:", JournalMode: "fast"}}})
+	assert.Error(t, err)
+}
+
+func TestNewDatabaseRejectsUnknownIsolation(t *testing.T) {
+	_, err := NewDatabase(&config.Config{DB: config.DatabaseConfig{Isolation: "snapshot", Sqlite: config.SqliteConfig{DatabasePath: ":memory:"}}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,336 @@
+// Package database provides the functionality to scope database operations to a tenant.
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/internal/records"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"golang.org/x/net/context"
+	"gorm.io/gorm/schema"
+	"reflect"
+	"sync"
+)
+
+var (
+	// ErrTenantRequired is returned when a tenant-owned record is accessed without a tenant in the context.
+	ErrTenantRequired = errors.New("tenant required")
+	// ErrTenantMismatch is returned when a tenant-owned record does not belong to the tenant in the context.
+	ErrTenantMismatch = errors.New("record belongs to another tenant")
+)
+
+// TenantOwned is an interface implemented by the entities that belong to a tenant.
+// The operations on these entities are scoped to the tenant carried by the context.
+type TenantOwned interface {
+	// GetTenantID returns the ID of the tenant the entity belongs to.
+	GetTenantID() uuid.UUID
+
+	// SetTenantID assigns the entity to the tenant with the given ID.
+	SetTenantID(id uuid.UUID)
+}
+
+// tenantKey is the context key under which the tenant ID is stored.
+type tenantKey struct{}
+
+// unscopedKey is the context key that disables the tenant scoping.
+type unscopedKey struct{}
+
+// WithTenant returns a copy of the context that scopes the database operations to the given tenant.
+// ctx: The parent context.
+// id: The ID of the tenant.
+// Returns the new context.
+func WithTenant(ctx context.Context, id uuid.UUID) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// TenantFromContext retrieves the tenant ID from the context.
+// ctx: The context to read from.
+// Returns the tenant ID and a boolean indicating if the context carries one.
+func TenantFromContext(ctx context.Context) (uuid.UUID, bool) {
+	id, ok := ctx.Value(tenantKey{}).(uuid.UUID)
+	return id, ok && id != uuid.Nil
+}
+
+// WithoutTenantScope returns a copy of the context in which the tenant scoping is disabled.
+// It is meant for the few system operations that legitimately work across tenants.
+// ctx: The parent context.
+// Returns the new context.
+func WithoutTenantScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unscopedKey{}, true)
+}
+
+// TenantDatabase struct represents a database decorator that scopes every operation on tenant-owned entities
+// to the tenant carried by the context.
+// Operations on entities that do not implement TenantOwned are passed through unchanged.
+type TenantDatabase struct {
+	db      Database
+	column  string
+	schemas *sync.Map
+}
+
+// NewTenantDatabase creates a new tenant-scoped database decorator.
+// db: The database to decorate.
+// column: The column that holds the tenant ID of the tenant-owned entities.
+// Returns a Database object.
+func NewTenantDatabase(db Database, column string) Database {
+	return &TenantDatabase{
+		db:      db,
+		column:  column,
+		schemas: &sync.Map{},
+	}
+}
+
+// Unwrap returns the decorated database.
+func (t TenantDatabase) Unwrap() Database {
+	return t.db
+}
+
+// Create adds a new record to the database.
+// Tenant-owned records are assigned to the tenant in the context.
+// ctx: The context for the operation.
+// entity: The record to add.
+// Returns an error if the operation fails.
+func (t TenantDatabase) Create(ctx context.Context, entity interface{}) error {
+	if owned, ok := entity.(TenantOwned); ok {
+		tenant, scoped, err := t.tenant(ctx, entity)
+		if err != nil {
+			return err
+		}
+		if scoped {
+			if owned.GetTenantID() != uuid.Nil && owned.GetTenantID() != tenant {
+				return ErrTenantMismatch
+			}
+			owned.SetTenantID(tenant)
+		}
+	}
+	return t.db.Create(ctx, entity)
+}
+
+// Read retrieves a record of the tenant in the context from the database.
+// ctx: The context for the operation.
+// entity: The record to retrieve.
+// where: The condition to match.
+// Returns an error if the operation fails.
+func (t TenantDatabase) Read(ctx context.Context, entity interface{}, where query.Condition) error {
+	where, err := t.scope(ctx, entity, where)
+	if err != nil {
+		return err
+	}
+	return t.db.Read(ctx, entity, where)
+}
+
+// Update modifies a record in the database.
+// Tenant-owned records without a tenant are assigned to the tenant in the context, as they are on Create.
+// The stored record they modify must belong to the tenant in the context too, which is checked in the transaction of the update
+// so that a record of another tenant cannot be taken over.
+// ctx: The context for the operation.
+// entity: The record to modify.
+// Returns ErrTenantMismatch if the record is assigned to another tenant, ErrNotFound if the stored record belongs
+// to another tenant, or an error if the operation fails.
+func (t TenantDatabase) Update(ctx context.Context, entity interface{}) error {
+	owned, ok := entity.(TenantOwned)
+	if !ok {
+		return t.db.Update(ctx, entity)
+	}
+	tenant, scoped, err := t.tenant(ctx, entity)
+	if err != nil {
+		return err
+	}
+	if !scoped {
+		return t.db.Update(ctx, entity)
+	}
+	if owned.GetTenantID() != uuid.Nil && owned.GetTenantID() != tenant {
+		return ErrTenantMismatch
+	}
+	owned.SetTenantID(tenant)
+	s, err := t.schema(entity)
+	if err != nil {
+		return err
+	}
+	id, _ := s.PrioritizedPrimaryField.ValueOf(ctx, records.Record(entity))
+	return t.db.WithTx(ctx, func(ctx context.Context) error {
+		stored := reflect.New(s.ModelType).Interface()
+		err := t.db.Read(ctx, stored, query.Eq(s.PrioritizedPrimaryField.DBName, id))
+		switch {
+		case errors.Is(err, ErrNotFound):
+			// The update adds the record, to the tenant in the context.
+		case err != nil:
+			return err
+		case stored.(TenantOwned).GetTenantID() != tenant:
+			return ErrNotFound
+		}
+		return t.db.Update(ctx, entity)
+	})
+}
+
+// Delete removes a record of the tenant in the context from the database.
+// ctx: The context for the operation.
+// entity: The record to remove.
+// id: The id of the record to remove.
+// Returns an error if the operation fails.
+func (t TenantDatabase) Delete(ctx context.Context, entity interface{}, id interface{}) error {
+	tenant, scoped, err := t.tenant(ctx, entity)
+	if err != nil {
+		return err
+	}
+	if !scoped {
+		return t.db.Delete(ctx, entity, id)
+	}
+	s, err := t.schema(entity)
+	if err != nil {
+		return err
+	}
+	_, err = t.db.DeleteWhere(ctx, entity, query.And(query.Eq(s.PrioritizedPrimaryField.DBName, id), query.Eq(t.column, tenant)))
+	return err
+}
+
+// ReadAll retrieves all records of the tenant in the context from the database.
+// ctx: The context for the operation.
+// entity: The records to retrieve.
+// Returns an error if the operation fails.
+func (t TenantDatabase) ReadAll(ctx context.Context, entity interface{}) error {
+	tenant, scoped, err := t.tenant(ctx, entity)
+	if err != nil {
+		return err
+	}
+	if !scoped {
+		return t.db.ReadAll(ctx, entity)
+	}
+	return t.db.Find(ctx, entity, query.Where(query.Eq(t.column, tenant)))
+}
+
+// Find retrieves the records of the tenant in the context matching the condition from the database.
+// ctx: The context for the operation.
+// entity: The records to retrieve.
+// q: The condition, ordering and window of the records.
+// Returns an error if the operation fails.
+func (t TenantDatabase) Find(ctx context.Context, entity interface{}, q query.Query) error {
+	where, err := t.scope(ctx, entity, q.Where)
+	if err != nil {
+		return err
+	}
+	q.Where = where
+	return t.db.Find(ctx, entity, q)
+}
+
+// DeleteWhere removes the records of the tenant in the context matching the condition from the database.
+// ctx: The context for the operation.
+// entity: The type of the records to remove.
+// where: The condition to match.
+// Returns the number of removed records and an error if the operation fails.
+func (t TenantDatabase) DeleteWhere(ctx context.Context, entity interface{}, where query.Condition) (int64, error) {
+	where, err := t.scope(ctx, entity, where)
+	if err != nil {
+		return 0, err
+	}
+	return t.db.DeleteWhere(ctx, entity, where)
+}
+
+// Count counts the records matching the condition in the decorated database, within the tenant of the context.
+// ctx: The context for the operation.
+// entity: A record of the type of the records to count.
+// where: The condition to match.
+// Returns the number of matching records and an error if the operation fails or the tenant is missing.
+func (t TenantDatabase) Count(ctx context.Context, entity interface{}, where query.Condition) (int64, error) {
+	where, err := t.scope(ctx, entity, where)
+	if err != nil {
+		return 0, err
+	}
+	return Count(ctx, t.db, entity, where)
+}
+
+// Search searches the records with the full-text index of the decorated database.
+// The full-text indexes are not scoped to the tenants, so that the tenant-owned records can only be searched without a tenant scope.
+// ctx: The context for the operation.
+// entity: A pointer to the slice of the records to retrieve.
+// search: The terms and the window of the search.
+// Returns the rank of every record, the number of matching records, and ErrSearchNotSupported if the records have to be scoped
+// or the database has no full-text index.
+func (t TenantDatabase) Search(ctx context.Context, entity interface{}, search query.TextSearch) ([]float64, int64, error) {
+	_, scoped, err := t.tenant(ctx, entity)
+	if err != nil {
+		return nil, 0, err
+	}
+	if scoped {
+		return nil, 0, ErrSearchNotSupported
+	}
+	return Search(ctx, t.db, entity, search)
+}
+
+// Stats reports the usage of the connection pool of the decorated database.
+// Returns the statistics of the pool.
+func (t TenantDatabase) Stats() sql.DBStats {
+	return t.db.Stats()
+}
+
+// WithTx runs fn in a transaction of the decorated database. The tenant scoping applies to the operations of fn as to any other.
+// ctx: The context for the operation.
+// fn: The function to run in the transaction.
+// opts: The isolation level and read-only flag of the transaction.
+// Returns the error returned by fn, or an error if the transaction cannot be started or committed.
+func (t TenantDatabase) WithTx(ctx context.Context, fn func(ctx context.Context) error, opts ...*sql.TxOptions) error {
+	return t.db.WithTx(ctx, fn, opts...)
+}
+
+// tenant determines whether the operation on the entity has to be scoped and to which tenant.
+// ctx: The context for the operation.
+// entity: The record or records the operation works on.
+// Returns the tenant ID, a boolean indicating if the operation has to be scoped, and ErrTenantRequired
+// if the entity is tenant-owned but the context carries no tenant.
+func (t TenantDatabase) tenant(ctx context.Context, entity interface{}) (uuid.UUID, bool, error) {
+	if !isTenantOwned(entity) {
+		return uuid.Nil, false, nil
+	}
+	if unscoped, _ := ctx.Value(unscopedKey{}).(bool); unscoped {
+		return uuid.Nil, false, nil
+	}
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return uuid.Nil, false, ErrTenantRequired
+	}
+	return tenant, true, nil
+}
+
+// schema parses the schema of a tenant-owned entity, whose primary key identifies the records that Update and Delete check against the tenant.
+// entity: The record the operation works on.
+// Returns the schema and an error if the entity cannot be parsed or has no primary key.
+func (t TenantDatabase) schema(entity interface{}) (*schema.Schema, error) {
+	s, err := records.Parse(entity, t.schemas)
+	if err != nil {
+		return nil, err
+	}
+	if s.PrioritizedPrimaryField == nil {
+		return nil, fmt.Errorf("the table %s has no primary key", s.Table)
+	}
+	return s, nil
+}
+
+// scope adds the tenant condition to the condition of a query.
+// ctx: The context for the operation.
+// entity: The record or records the query works on.
+// where: The condition of the query.
+// Returns the scoped condition and an error if the tenant cannot be determined.
+func (t TenantDatabase) scope(ctx context.Context, entity interface{}, where query.Condition) (query.Condition, error) {
+	tenant, scoped, err := t.tenant(ctx, entity)
+	if err != nil || !scoped {
+		return where, err
+	}
+	return query.And(where, query.Eq(t.column, tenant)), nil
+}
+
+// isTenantOwned reports whether the entity, or the element type of a slice of entities, implements TenantOwned.
+// entity: The record or records to inspect.
+// Returns true if the entity is tenant-owned.
+func isTenantOwned(entity interface{}) bool {
+	typ := reflect.TypeOf(entity)
+	if typ == nil {
+		return false
+	}
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		typ = typ.Elem()
+	}
+	_, ok := reflect.New(typ).Interface().(TenantOwned)
+	return ok
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/nikita-voronoy/go-clean-arch/internal/entities"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/memory"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTenantTestDatabase(t *testing.T) Database {
+	return NewTenantDatabase(memory.NewDatabase(), "organization_id")
+}
+
+func TestTenantDatabaseScopesQueries(t *testing.T) {
+	db := newTenantTestDatabase(t)
+	orgA, orgB := uuid.New(), uuid.New()
+	ctxA := WithTenant(context.Background(), orgA)
+	ctxB := WithTenant(context.Background(), orgB)
+	userID := uuid.New()
+
+	memberA := &entities.Membership{ID: uuid.New(), UserID: userID, Role: entities.RoleOwner}
+	require.NoError(t, db.Create(ctxA, memberA))
+	assert.Equal(t, orgA, memberA.OrganizationID, "Create must assign the tenant of the context")
+	require.NoError(t, db.Create(ctxB, &entities.Membership{ID: uuid.New(), UserID: userID, Role: entities.RoleMember}))
+
+	var membership entities.Membership
+	require.NoError(t, db.Read(ctxB, &membership, query.Eq("user_id", userID)))
+	assert.Equal(t, entities.RoleMember, membership.Role)
+
+	var memberships []entities.Membership
+	require.NoError(t, db.ReadAll(ctxA, &memberships))
+	assert.Len(t, memberships, 1)
+	count, err := Count(ctxA, db, entities.Membership{}, query.Eq("user_id", userID))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, count, "Count must be scoped to the tenant")
+
+	require.NoError(t, db.Delete(ctxB, entities.Membership{}, memberA.ID))
+	var remaining entities.Membership
+	require.NoError(t, db.Read(ctxA, &remaining, query.Eq("id", memberA.ID)), "Delete must not cross tenants")
+
+	memberships = nil
+	require.NoError(t, db.Find(WithoutTenantScope(context.Background()), &memberships, query.Where(query.Eq("user_id", userID))))
+	assert.Len(t, memberships, 2)
+}
+
+func TestTenantDatabaseRejectsCrossTenantUpdates(t *testing.T) {
+	db := newTenantTestDatabase(t)
+	orgA, orgB := uuid.New(), uuid.New()
+	ctxA := WithTenant(context.Background(), orgA)
+	ctxB := WithTenant(context.Background(), orgB)
+
+	member := &entities.Membership{ID: uuid.New(), UserID: uuid.New(), Role: entities.RoleMember}
+	require.NoError(t, db.Create(ctxA, member))
+
+	takeover := &entities.Membership{ID: member.ID, OrganizationID: orgB, UserID: uuid.New(), Role: entities.RoleOwner}
+	assert.ErrorIs(t, db.Update(ctxB, takeover), ErrNotFound, "a record of another tenant cannot be overwritten")
+	var stored entities.Membership
+	require.NoError(t, db.Read(ctxA, &stored, query.Eq("id", member.ID)))
+	assert.Equal(t, entities.RoleMember, stored.Role)
+	assert.Equal(t, member.UserID, stored.UserID)
+
+	stored.Role = entities.RoleAdmin
+	require.NoError(t, db.Update(ctxA, &stored), "the tenant of a record modifies it")
+	added := &entities.Membership{ID: uuid.New(), OrganizationID: orgB, UserID: uuid.New(), Role: entities.RoleMember}
+	require.NoError(t, db.Update(ctxB, added), "an update of a record that does not exist adds it to the tenant")
+
+	require.NoError(t, db.Read(ctxA, &stored, query.Eq("id", member.ID)))
+	untagged := stored
+	untagged.OrganizationID, untagged.Role = uuid.Nil, entities.RoleOwner
+	assert.ErrorIs(t, db.Update(ctxB, &untagged), ErrNotFound, "a record without a tenant cannot take over a record of another tenant")
+	untagged.OrganizationID = uuid.Nil
+	require.NoError(t, db.Update(ctxA, &untagged), "a record without a tenant is assigned to the tenant of the context")
+	assert.Equal(t, orgA, untagged.OrganizationID)
+	require.NoError(t, db.Read(ctxA, &stored, query.Eq("id", member.ID)))
+	assert.Equal(t, entities.RoleOwner, stored.Role)
+}
+
+// note is a tenant-owned entity whose primary key is not named id.
+type note struct {
+	Key            uuid.UUID `gorm:"type:uuid;primaryKey"`
+	OrganizationID uuid.UUID `gorm:"type:uuid"`
+	Text           string
+}
+
+func (n *note) GetTenantID() uuid.UUID   { return n.OrganizationID }
+func (n *note) SetTenantID(id uuid.UUID) { n.OrganizationID = id }
+
+func TestTenantDatabaseDeletesByPrimaryKey(t *testing.T) {
+	db := newTenantTestDatabase(t)
+	ctxA := WithTenant(context.Background(), uuid.New())
+	ctxB := WithTenant(context.Background(), uuid.New())
+
+	kept, deleted := &note{Key: uuid.New(), Text: "kept"}, &note{Key: uuid.New(), Text: "deleted"}
+	require.NoError(t, db.Create(ctxA, kept))
+	require.NoError(t, db.Create(ctxA, deleted))
+
+	require.NoError(t, db.Delete(ctxB, &note{}, deleted.Key))
+	var stored note
+	require.NoError(t, db.Read(ctxA, &stored, query.Eq("key", deleted.Key)), "Delete must not cross tenants")
+
+	require.NoError(t, db.Delete(ctxA, &note{}, deleted.Key))
+	assert.ErrorIs(t, db.Read(ctxA, &stored, query.Eq("key", deleted.Key)), ErrNotFound)
+	require.NoError(t, db.Read(ctxA, &stored, query.Eq("key", kept.Key)), "Delete removes only the record with the primary key")
+	assert.Equal(t, "kept", stored.Text)
+}
+
+func TestTenantDatabaseRejectsUnscopedAccess(t *testing.T) {
+	db := newTenantTestDatabase(t)
+	ctx := context.Background()
+
+	var memberships []entities.Membership
+	assert.ErrorIs(t, db.ReadAll(ctx, &memberships), ErrTenantRequired)
+	assert.ErrorIs(t, db.Create(ctx, &entities.Membership{ID: uuid.New()}), ErrTenantRequired)
+
+	member := &entities.Membership{ID: uuid.New(), UserID: uuid.New(), Role: entities.RoleMember}
+	require.NoError(t, db.Create(WithTenant(ctx, uuid.New()), member))
+	assert.ErrorIs(t, db.Update(WithTenant(ctx, uuid.New()), member), ErrTenantMismatch)
+
+	var users []entities.User
+	assert.NoError(t, db.ReadAll(ctx, &users), "Entities that are not tenant-owned must not be scoped")
+}
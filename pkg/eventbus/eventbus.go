@@ -0,0 +1,246 @@
+// Package eventbus provides an in-process bus of typed events, which lets the modules react to each other.
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by the publications on a closed bus.
+var ErrClosed = errors.New("event bus closed")
+
+// PanicError is the error of a subscriber that panicked, which is isolated from the publisher and the other subscribers.
+type PanicError struct {
+	Value interface{} // The value the subscriber panicked with.
+	Stack []byte      // The stack of the subscriber when it panicked.
+}
+
+// Error returns the value the subscriber panicked with.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("event subscriber panicked: %v", e.Value)
+}
+
+// RetryPolicy struct represents how a subscriber is called again after it fails.
+// Attempts: The number of times the subscriber is called at most. Zero and one call it once.
+// Backoff: The time waited before the second call, which doubles before every further call.
+// MaxBackoff: The maximum time waited between two calls. Zero does not cap the time.
+// A subscriber that panics is not called again, since a panic is a bug that another call does not fix.
+type RetryPolicy struct {
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Option is a function that configures a subscriber.
+type Option func(*subscriber)
+
+// Async makes a subscriber run in its own goroutine, so that the publication does not wait for it and is not failed by it.
+// The context it receives is not canceled with the one of the publication.
+func Async() Option {
+	return func(s *subscriber) {
+		s.async = true
+	}
+}
+
+// WithRetry makes a subscriber be called again when it fails.
+// policy: The number of calls and the time waited between them.
+func WithRetry(policy RetryPolicy) Option {
+	return func(s *subscriber) {
+		s.retry = policy
+	}
+}
+
+// WithName names a subscriber in the errors and the logs, instead of the name of its function.
+// name: The name of the subscriber.
+func WithName(name string) Option {
+	return func(s *subscriber) {
+		s.name = name
+	}
+}
+
+// subscriber struct represents a function that handles the events of a type.
+type subscriber struct {
+	eventType reflect.Type
+	name      string
+	async     bool
+	retry     RetryPolicy
+	handle    func(ctx context.Context, event interface{}) error
+}
+
+// Bus struct represents an in-process bus that delivers every published event to the subscribers of its type,
+// and to the subscribers of the interfaces it implements, e.g. to the subscribers of interface{}.
+// The synchronous subscribers run in the goroutine of the publisher, in the order they subscribed in.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []*subscriber
+	closed      bool
+	running     sync.WaitGroup
+}
+
+// New creates a new event bus.
+// Returns a *Bus object.
+func New() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers a function that handles the events of a type.
+// b: The bus to subscribe to.
+// handler: The function that handles the events. An interface type subscribes to the events that implement it.
+// opts: The options of the subscriber, e.g. Async() or WithRetry(policy).
+// Returns a function that removes the subscriber.
+func Subscribe[E any](b *Bus, handler func(ctx context.Context, event E) error, opts ...Option) (unsubscribe func()) {
+	s := &subscriber{
+		eventType: reflect.TypeOf((*E)(nil)).Elem(),
+		name:      runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name(),
+		handle: func(ctx context.Context, event interface{}) error {
+			return handler(ctx, event.(E))
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, s)
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, subscribed := range b.subscribers {
+			if subscribed == s {
+				b.subscribers = append(b.subscribers[:i:i], b.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish delivers an event to its subscribers. It waits for the synchronous subscribers, and starts the asynchronous ones.
+// A nil bus has no subscribers.
+// ctx: The context for the operation, which the subscribers receive.
+// b: The bus to publish the event on.
+// event: The event to deliver.
+// Returns the errors of the synchronous subscribers that failed, each after its retries, and ErrClosed if the bus is closed.
+func Publish[E any](ctx context.Context, b *Bus, event E) error {
+	if b == nil {
+		return nil
+	}
+	// An event published as an interface is delivered by its dynamic type.
+	eventType := reflect.TypeOf((*E)(nil)).Elem()
+	if value := reflect.ValueOf(event); eventType.Kind() == reflect.Interface && value.IsValid() {
+		eventType = value.Type()
+	}
+
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrClosed
+	}
+	var waited []*subscriber
+	for _, s := range b.subscribers {
+		if !s.accepts(eventType) {
+			continue
+		}
+		if !s.async {
+			waited = append(waited, s)
+			continue
+		}
+		// The asynchronous subscribers are counted under the lock, so that Close waits for every one it does not reject.
+		b.running.Add(1)
+		go func(s *subscriber) {
+			defer b.running.Done()
+			if err := s.deliver(context.WithoutCancel(ctx), event); err != nil {
+				logFailure(eventType, s, err)
+			}
+		}(s)
+	}
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, s := range waited {
+		if err := s.deliver(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close rejects the new publications, and waits for the asynchronous subscribers that are running.
+// ctx: The context that bounds the wait.
+// Returns the error of the context if the subscribers do not complete in time.
+func (b *Bus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// accepts reports whether the subscriber handles the events of a type.
+func (s *subscriber) accepts(eventType reflect.Type) bool {
+	if s.eventType == eventType {
+		return true
+	}
+	return s.eventType.Kind() == reflect.Interface && eventType.Implements(s.eventType)
+}
+
+// deliver calls the subscriber, and again as its retry policy allows while it fails.
+func (s *subscriber) deliver(ctx context.Context, event interface{}) error {
+	delay := s.retry.Backoff
+	for attempt := 1; ; attempt++ {
+		err := s.call(ctx, event)
+		var panicErr *PanicError
+		if err == nil || attempt >= s.retry.Attempts || errors.As(err, &panicErr) {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay *= 2
+		if s.retry.MaxBackoff > 0 && delay > s.retry.MaxBackoff {
+			delay = s.retry.MaxBackoff
+		}
+	}
+}
+
+// call calls the subscriber, and turns a panic into a *PanicError.
+func (s *subscriber) call(ctx context.Context, event interface{}) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = &PanicError{Value: value, Stack: debug.Stack()}
+		}
+	}()
+	return s.handle(ctx, event)
+}
+
+// logFailure logs the failure of an asynchronous subscriber, which has no publisher to return it to, with the stack of a panic.
+func logFailure(eventType reflect.Type, s *subscriber, err error) {
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		log.Printf("Subscriber %s of %s panicked: %v\n%s", s.name, eventType, panicErr.Value, panicErr.Stack)
+		return
+	}
+	log.Printf("Subscriber %s of %s failed: %v\n", s.name, eventType, err)
+}
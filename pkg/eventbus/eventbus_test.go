@@ -0,0 +1,124 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type registered struct{ name string }
+
+type deleted struct{ name string }
+
+func (d deleted) String() string { return "deleted " + d.name }
+
+type stringer interface{ String() string }
+
+func TestPublishDeliversByType(t *testing.T) {
+	bus := New()
+	ctx := context.Background()
+	var calls []string
+	Subscribe(bus, func(_ context.Context, event registered) error {
+		calls = append(calls, "first "+event.name)
+		return nil
+	})
+	Subscribe(bus, func(_ context.Context, event registered) error {
+		calls = append(calls, "second "+event.name)
+		return nil
+	})
+	Subscribe(bus, func(_ context.Context, event stringer) error {
+		calls = append(calls, event.String())
+		return nil
+	})
+	unsubscribe := Subscribe(bus, func(_ context.Context, event deleted) error {
+		calls = append(calls, "removed")
+		return nil
+	})
+	unsubscribe()
+
+	require.NoError(t, Publish(ctx, bus, registered{name: "alice"}))
+	require.NoError(t, Publish[interface{}](ctx, bus, deleted{name: "bob"}))
+	assert.Equal(t, []string{"first alice", "second alice", "deleted bob"}, calls, "the subscribers of an interface receive the events that implement it")
+	assert.NoError(t, Publish(ctx, (*Bus)(nil), registered{}), "a nil bus has no subscribers")
+}
+
+func TestPublishIsolatesFailures(t *testing.T) {
+	bus := New()
+	ctx := context.Background()
+	failure := errors.New("failure")
+	var delivered atomic.Bool
+	Subscribe(bus, func(context.Context, registered) error { panic("boom") }, WithName("panicking"))
+	Subscribe(bus, func(context.Context, registered) error { return failure }, WithName("failing"))
+	Subscribe(bus, func(context.Context, registered) error {
+		delivered.Store(true)
+		return nil
+	})
+
+	err := Publish(ctx, bus, registered{})
+	var panicErr *PanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "boom", panicErr.Value)
+	assert.ErrorIs(t, err, failure)
+	assert.Contains(t, err.Error(), "panicking")
+	assert.True(t, delivered.Load(), "a failing subscriber does not keep the event from the others")
+}
+
+func TestRetry(t *testing.T) {
+	bus := New()
+	ctx := context.Background()
+	var attempts, panics atomic.Int32
+	Subscribe(bus, func(context.Context, registered) error {
+		if attempts.Add(1) < 3 {
+			return errors.New("unavailable")
+		}
+		return nil
+	}, WithRetry(RetryPolicy{Attempts: 3, Backoff: time.Millisecond}))
+	Subscribe(bus, func(context.Context, registered) error {
+		panics.Add(1)
+		panic("boom")
+	}, WithRetry(RetryPolicy{Attempts: 3}))
+
+	err := Publish(ctx, bus, registered{})
+	assert.EqualValues(t, 3, attempts.Load())
+	assert.EqualValues(t, 1, panics.Load(), "a panicking subscriber is not called again")
+	var panicErr *PanicError
+	assert.ErrorAs(t, err, &panicErr)
+
+	attempts.Store(-10)
+	err = Publish(ctx, bus, registered{})
+	assert.Error(t, err, "the subscriber fails once its attempts are exhausted")
+	assert.EqualValues(t, -7, attempts.Load())
+}
+
+func TestAsync(t *testing.T) {
+	bus := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	release := make(chan struct{})
+	var handled atomic.Int32
+	Subscribe(bus, func(ctx context.Context, event registered) error {
+		<-release
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		handled.Add(1)
+		return errors.New("logged")
+	}, Async())
+	Subscribe(bus, func(context.Context, registered) error { panic("boom") }, Async())
+
+	require.NoError(t, Publish(ctx, bus, registered{}), "the asynchronous subscribers do not fail the publication")
+	cancel()
+
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer closeCancel()
+	assert.ErrorIs(t, bus.Close(closeCtx), context.DeadlineExceeded, "Close waits for the running subscribers")
+	assert.ErrorIs(t, Publish(context.Background(), bus, registered{}), ErrClosed)
+
+	close(release)
+	require.NoError(t, bus.Close(context.Background()))
+	assert.EqualValues(t, 1, handled.Load(), "an asynchronous subscriber outlives the context of the publication")
+}
@@ -0,0 +1,58 @@
+// Package eventbustest provides the helpers to test the publishers and the subscribers of an event bus.
+package eventbustest
+
+import (
+	"context"
+	"github.com/nikita-voronoy/go-clean-arch/pkg/eventbus"
+	"sync"
+)
+
+// Recorder struct represents a synchronous subscriber of every event of a bus, which records them in the order they are published.
+type Recorder struct {
+	mu     sync.Mutex
+	events []interface{}
+}
+
+// NewRecorder creates a recorder of the events published on a bus.
+// bus: The bus to record the events of.
+// Returns a *Recorder object.
+func NewRecorder(bus *eventbus.Bus) *Recorder {
+	r := &Recorder{}
+	eventbus.Subscribe(bus, r.record, eventbus.WithName("eventbustest.Recorder"))
+	return r
+}
+
+// Events returns the recorded events.
+func (r *Recorder) Events() []interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]interface{}(nil), r.events...)
+}
+
+// Reset forgets the recorded events.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = nil
+}
+
+// Events returns the recorded events of a type.
+// r: The recorder of the events.
+// Returns the events of the type, in the order they were published.
+func Events[E any](r *Recorder) []E {
+	var events []E
+	for _, event := range r.Events() {
+		if typed, ok := event.(E); ok {
+			events = append(events, typed)
+		}
+	}
+	return events
+}
+
+// record records an event.
+func (r *Recorder) record(_ context.Context, event interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
@@ -0,0 +1,212 @@
+// Package keyring provides the keys the fields of the records are encrypted with, and the blind indexes that let
+// the encrypted fields be looked up by equality.
+// The values are sealed with AES-256-GCM by the primary key and carry the ID of their key, so that the keys can be
+// rotated: a new primary key encrypts the new values while the previous keys still decrypt the values they sealed.
+package keyring
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeySize is the number of bytes of the keys, which are AES-256 and HMAC-SHA256 keys.
+const KeySize = 32
+
+// prefix is the prefix of the encrypted values, followed by the ID of the key and the sealed value: "enc:<id>:<base64>".
+const prefix = "enc:"
+
+var (
+	// ErrInvalidKey is returned when a key is malformed, has not the size of a key, or is listed twice.
+	ErrInvalidKey = errors.New("invalid encryption key")
+	// ErrUnknownKey is returned when a value is encrypted with a key the keyring does not hold.
+	ErrUnknownKey = errors.New("unknown encryption key")
+	// ErrDecrypt is returned when an encrypted value is malformed or fails its authentication.
+	ErrDecrypt = errors.New("cannot decrypt value")
+)
+
+// Key struct represents an encryption key.
+type Key struct {
+	ID     string // The ID of the key, which the values it encrypts carry. It cannot contain a colon.
+	Secret []byte // The KeySize bytes of the key.
+}
+
+// Keyring struct represents the encryption keys: the primary key that encrypts the values, the previous keys that
+// only decrypt them, and the key of the blind indexes.
+type Keyring struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+	index   []byte
+}
+
+// New creates a new keyring.
+// keys: The encryption keys. Values encrypted with a key that is not listed cannot be decrypted.
+// primary: The ID of the key that encrypts the values. The first key is the primary key if it is empty.
+// indexKey: The KeySize bytes of the key of the blind indexes. Changing it invalidates the stored indexes until the records are encrypted again.
+// Returns the keyring, and ErrInvalidKey if a key is invalid, the primary key is not listed, or no key is given.
+func New(keys []Key, primary string, indexKey []byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no key", ErrInvalidKey)
+	}
+	if len(indexKey) != KeySize {
+		return nil, fmt.Errorf("%w: the index key has %d bytes instead of %d", ErrInvalidKey, len(indexKey), KeySize)
+	}
+	if primary == "" {
+		primary = keys[0].ID
+	}
+	k := &Keyring{
+		primary: primary,
+		aeads:   make(map[string]cipher.AEAD, len(keys)),
+		index:   indexKey,
+	}
+	for _, key := range keys {
+		if key.ID == "" || strings.Contains(key.ID, ":") {
+			return nil, fmt.Errorf("%w: invalid ID %q", ErrInvalidKey, key.ID)
+		}
+		if _, ok := k.aeads[key.ID]; ok {
+			return nil, fmt.Errorf("%w: the key %q is listed twice", ErrInvalidKey, key.ID)
+		}
+		if len(key.Secret) != KeySize {
+			return nil, fmt.Errorf("%w: the key %q has %d bytes instead of %d", ErrInvalidKey, key.ID, len(key.Secret), KeySize)
+		}
+		block, err := aes.NewCipher(key.Secret)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.aeads[key.ID] = aead
+	}
+	if _, ok := k.aeads[primary]; !ok {
+		return nil, fmt.Errorf("%w: the primary key %q is not listed", ErrInvalidKey, primary)
+	}
+	return k, nil
+}
+
+// ParseKey parses a key written as "<id>:<base64 secret>", as the configuration and the key files list them.
+// text: The key.
+// Returns the key and ErrInvalidKey if it is malformed.
+func ParseKey(text string) (Key, error) {
+	id, encoded, ok := strings.Cut(strings.TrimSpace(text), ":")
+	if !ok || id == "" {
+		return Key{}, fmt.Errorf("%w: expected <id>:<base64 secret>", ErrInvalidKey)
+	}
+	secret, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return Key{}, fmt.Errorf("%w: the secret of the key %q is not base64: %v", ErrInvalidKey, id, err)
+	}
+	return Key{ID: id, Secret: secret}, nil
+}
+
+// ReadFile reads the keys of a key file, which lists a key per line as "<id>:<base64 secret>".
+// The empty lines and the lines starting with # are ignored.
+// path: The path of the key file.
+// Returns the keys in the order of the file, and an error if the file cannot be read or a key is malformed.
+func ReadFile(path string) ([]Key, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var keys []Key
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, err := ParseKey(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, scanner.Err()
+}
+
+// NewSecret generates a random secret and encodes it as a key file lists it.
+// Returns the base64 encoding of KeySize random bytes.
+func NewSecret() (string, error) {
+	secret := make([]byte, KeySize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(secret), nil
+}
+
+// Encrypt encrypts a value with the primary key.
+// plaintext: The value to encrypt.
+// context: The context the value is bound to, e.g. its table and column, which its decryption must give again.
+// Returns the encrypted value, "enc:<key id>:<base64 nonce and ciphertext>", and an error if no nonce can be generated.
+func (k *Keyring) Encrypt(plaintext, context string) (string, error) {
+	aead := k.aeads[k.primary]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(context))
+	return prefix + k.primary + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value encrypted by any key of the keyring.
+// value: The encrypted value.
+// context: The context the value was encrypted with.
+// Returns the plaintext, ErrUnknownKey if the key of the value is not in the keyring, and ErrDecrypt if the value is
+// malformed or was encrypted for another context.
+func (k *Keyring) Decrypt(value, context string) (string, error) {
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !IsEncrypted(value) || !ok {
+		return "", ErrDecrypt
+	}
+	aead, ok := k.aeads[id]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(context))
+	if err != nil {
+		return "", ErrDecrypt
+	}
+	return string(plaintext), nil
+}
+
+// Current reports whether a value is encrypted with the primary key, so that it need not be encrypted again.
+// value: The encrypted value.
+func (k *Keyring) Current(value string) bool {
+	return strings.HasPrefix(value, prefix+k.primary+":")
+}
+
+// Index returns the blind index of a value: a keyed hash that is equal for equal values, so that it can be looked up and
+// made unique, and that reveals nothing else about the value.
+// value: The plaintext value.
+// context: The context of the index, e.g. its table and column, so that the indexes of equal values differ across columns.
+// Returns the hex encoding of the HMAC-SHA256 of the value.
+func (k *Keyring) Index(value, context string) string {
+	mac := hmac.New(sha256.New, k.index)
+	mac.Write([]byte(context))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsEncrypted reports whether a value has the form of an encrypted value, as opposed to a value written before the
+// encryption was enabled.
+// value: The stored value.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
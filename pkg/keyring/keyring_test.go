@@ -0,0 +1,88 @@
+package keyring
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKey(t *testing.T, id string, fill byte) Key {
+	t.Helper()
+	return Key{ID: id, Secret: bytes.Repeat([]byte{fill}, KeySize)}
+}
+
+func TestEncryptAndRotate(t *testing.T) {
+	index := bytes.Repeat([]byte{9}, KeySize)
+	old, err := New([]Key{newKey(t, "k1", 1)}, "", index)
+	require.NoError(t, err)
+
+	encrypted, err := old.Encrypt("alice@example.com", "users.email")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, "enc:k1:"))
+	assert.NotContains(t, encrypted, "alice")
+	again, err := old.Encrypt("alice@example.com", "users.email")
+	require.NoError(t, err)
+	assert.NotEqual(t, encrypted, again, "every encryption has its own nonce")
+
+	plaintext, err := old.Decrypt(encrypted, "users.email")
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", plaintext)
+	_, err = old.Decrypt(encrypted, "users.token")
+	assert.ErrorIs(t, err, ErrDecrypt, "a value is bound to its context")
+	_, err = old.Decrypt(encrypted[:len(encrypted)-2], "users.email")
+	assert.ErrorIs(t, err, ErrDecrypt)
+
+	rotated, err := New([]Key{newKey(t, "k1", 1), newKey(t, "k2", 2)}, "k2", index)
+	require.NoError(t, err)
+	assert.False(t, rotated.Current(encrypted))
+	plaintext, err = rotated.Decrypt(encrypted, "users.email")
+	require.NoError(t, err, "the previous keys still decrypt their values")
+	assert.Equal(t, "alice@example.com", plaintext)
+	encrypted, err = rotated.Encrypt(plaintext, "users.email")
+	require.NoError(t, err)
+	assert.True(t, rotated.Current(encrypted))
+
+	_, err = old.Decrypt(encrypted, "users.email")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	assert.Equal(t, old.Index("alice@example.com", "users.email"), rotated.Index("alice@example.com", "users.email"),
+		"the blind indexes do not depend on the encryption keys")
+	assert.NotEqual(t, old.Index("alice@example.com", "users.email"), old.Index("alice@example.com", "users.token"))
+	assert.NotEqual(t, old.Index("alice@example.com", "users.email"), old.Index("bob@example.com", "users.email"))
+}
+
+func TestNewRejectsInvalidKeys(t *testing.T) {
+	index := bytes.Repeat([]byte{9}, KeySize)
+	_, err := New(nil, "", index)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = New([]Key{{ID: "k1", Secret: []byte("short")}}, "", index)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = New([]Key{newKey(t, "k1", 1), newKey(t, "k1", 2)}, "", index)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = New([]Key{newKey(t, "k1", 1)}, "k2", index)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = New([]Key{newKey(t, "k1", 1)}, "", nil)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestReadFile(t *testing.T) {
+	secret, err := NewSecret()
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(path, []byte("# rotated on 2026-10-18\nk2:"+secret+"\n\nk1:"+secret+"\n"), 0o600))
+
+	keys, err := ReadFile(path)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, "k2", keys[0].ID)
+	assert.Len(t, keys[0].Secret, KeySize)
+
+	require.NoError(t, os.WriteFile(path, []byte("k1\n"), 0o600))
+	_, err = ReadFile(path)
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
@@ -0,0 +1,258 @@
+// Package messaging provides the envelopes the domain events are exchanged in with the other services.
+package messaging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/encoding/protowire"
+	"time"
+)
+
+// The content types of the encodings of the envelopes, which carry the version of the envelope format.
+const (
+	ContentTypeJSON     = "application/vnd.events.v1+json"
+	ContentTypeProtobuf = "application/vnd.events.v1+protobuf"
+)
+
+// ErrMalformedEnvelope is returned when an envelope cannot be decoded.
+var ErrMalformedEnvelope = errors.New("malformed envelope")
+
+// Envelope struct represents a domain event as it is exchanged with the other services.
+// ID: The ID of the event, which is the same for every publication of the event, so that the consumers can drop the duplicates.
+// Type: The type of the event, e.g. "user.registered".
+// Version: The version of the schema of the data of the event, which is incremented by an incompatible change.
+// Source: The name of the application that published the event.
+// AggregateType: The type of the aggregate the event is about, e.g. "user".
+// AggregateID: The ID of the aggregate the event is about.
+// OccurredAt: The time the event happened.
+// Data: The JSON encoding of the event.
+type Envelope struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	Version       int             `json:"version"`
+	Source        string          `json:"source"`
+	AggregateType string          `json:"aggregate_type,omitempty"`
+	AggregateID   string          `json:"aggregate_id,omitempty"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// Decode decodes the data of an envelope.
+// envelope: The envelope of the event.
+// Returns the event and an error if the data does not match its type.
+func Decode[E any](envelope Envelope) (E, error) {
+	var event E
+	if err := json.Unmarshal(envelope.Data, &event); err != nil {
+		return event, fmt.Errorf("%w: %s data: %w", ErrMalformedEnvelope, envelope.Type, err)
+	}
+	return event, nil
+}
+
+// Codec is an interface that defines the methods required to encode the envelopes.
+type Codec interface {
+	// ContentType returns the content type of the encoding, which the messages carry in their Content-Type header.
+	ContentType() string
+
+	// Marshal encodes an envelope.
+	// envelope: The envelope to encode.
+	// Returns the encoded envelope and an error if it cannot be encoded.
+	Marshal(envelope Envelope) ([]byte, error)
+
+	// Unmarshal decodes an envelope.
+	// data: The encoded envelope.
+	// Returns the envelope, and an error wrapping ErrMalformedEnvelope if it cannot be decoded.
+	Unmarshal(data []byte) (Envelope, error)
+}
+
+// NewCodec returns the codec of an encoding.
+// encoding: The name of the encoding, "json" or "protobuf". An empty name is "json".
+// Returns the codec, and an error if the encoding is unknown.
+func NewCodec(encoding string) (Codec, error) {
+	switch encoding {
+	case "", "json":
+		return JSONCodec{}, nil
+	case "protobuf":
+		return ProtobufCodec{}, nil
+	default:
+		return nil, fmt.Errorf("unknown envelope encoding %q", encoding)
+	}
+}
+
+// codecFor returns the codec of a content type. A message without a content type is taken to be JSON.
+func codecFor(contentType string) (Codec, error) {
+	switch contentType {
+	case "", ContentTypeJSON:
+		return JSONCodec{}, nil
+	case ContentTypeProtobuf:
+		return ProtobufCodec{}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown content type %q", ErrMalformedEnvelope, contentType)
+	}
+}
+
+// JSONCodec struct represents the JSON encoding of the envelopes.
+type JSONCodec struct{}
+
+// ContentType returns the content type of the JSON envelopes.
+func (JSONCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+// Marshal encodes an envelope as JSON.
+// envelope: The envelope to encode.
+// Returns the encoded envelope and an error if its data is not valid JSON.
+func (JSONCodec) Marshal(envelope Envelope) ([]byte, error) {
+	return json.Marshal(envelope)
+}
+
+// Unmarshal decodes a JSON envelope.
+// data: The encoded envelope.
+// Returns the envelope, and an error wrapping ErrMalformedEnvelope if it cannot be decoded.
+func (JSONCodec) Unmarshal(data []byte) (Envelope, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return Envelope{}, fmt.Errorf("%w: %w", ErrMalformedEnvelope, err)
+	}
+	return envelope, nil
+}
+
+// The numbers of the fields of the protobuf envelope, as declared in envelope.proto.
+const (
+	fieldID            protowire.Number = 1
+	fieldType          protowire.Number = 2
+	fieldVersion       protowire.Number = 3
+	fieldSource        protowire.Number = 4
+	fieldAggregateType protowire.Number = 5
+	fieldAggregateID   protowire.Number = 6
+	fieldOccurredAt    protowire.Number = 7
+	fieldData          protowire.Number = 8
+
+	// The numbers of the fields of google.protobuf.Timestamp.
+	fieldSeconds protowire.Number = 1
+	fieldNanos   protowire.Number = 2
+)
+
+// ProtobufCodec struct represents the protobuf encoding of the envelopes, whose schema is envelope.proto.
+type ProtobufCodec struct{}
+
+// ContentType returns the content type of the protobuf envelopes.
+func (ProtobufCodec) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+// Marshal encodes an envelope as protobuf.
+// envelope: The envelope to encode.
+// Returns the encoded envelope and nil.
+func (ProtobufCodec) Marshal(envelope Envelope) ([]byte, error) {
+	var b []byte
+	b = appendString(b, fieldID, envelope.ID)
+	b = appendString(b, fieldType, envelope.Type)
+	if envelope.Version != 0 {
+		b = protowire.AppendTag(b, fieldVersion, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(envelope.Version))
+	}
+	b = appendString(b, fieldSource, envelope.Source)
+	b = appendString(b, fieldAggregateType, envelope.AggregateType)
+	b = appendString(b, fieldAggregateID, envelope.AggregateID)
+	if !envelope.OccurredAt.IsZero() {
+		var timestamp []byte
+		timestamp = protowire.AppendTag(timestamp, fieldSeconds, protowire.VarintType)
+		timestamp = protowire.AppendVarint(timestamp, uint64(envelope.OccurredAt.Unix()))
+		if nanos := envelope.OccurredAt.Nanosecond(); nanos != 0 {
+			timestamp = protowire.AppendTag(timestamp, fieldNanos, protowire.VarintType)
+			timestamp = protowire.AppendVarint(timestamp, uint64(nanos))
+		}
+		b = protowire.AppendTag(b, fieldOccurredAt, protowire.BytesType)
+		b = protowire.AppendBytes(b, timestamp)
+	}
+	if len(envelope.Data) > 0 {
+		b = protowire.AppendTag(b, fieldData, protowire.BytesType)
+		b = protowire.AppendBytes(b, envelope.Data)
+	}
+	return b, nil
+}
+
+// Unmarshal decodes a protobuf envelope. The unknown fields, which a later version of the schema may add, are skipped.
+// data: The encoded envelope.
+// Returns the envelope, and an error wrapping ErrMalformedEnvelope if it cannot be decoded.
+func (ProtobufCodec) Unmarshal(data []byte) (Envelope, error) {
+	var envelope Envelope
+	err := walk(data, func(number protowire.Number, kind protowire.Type, value []byte, varint uint64) error {
+		switch {
+		case number == fieldID && kind == protowire.BytesType:
+			envelope.ID = string(value)
+		case number == fieldType && kind == protowire.BytesType:
+			envelope.Type = string(value)
+		case number == fieldVersion && kind == protowire.VarintType:
+			envelope.Version = int(varint)
+		case number == fieldSource && kind == protowire.BytesType:
+			envelope.Source = string(value)
+		case number == fieldAggregateType && kind == protowire.BytesType:
+			envelope.AggregateType = string(value)
+		case number == fieldAggregateID && kind == protowire.BytesType:
+			envelope.AggregateID = string(value)
+		case number == fieldData && kind == protowire.BytesType:
+			envelope.Data = append(json.RawMessage(nil), value...)
+		case number == fieldOccurredAt && kind == protowire.BytesType:
+			var seconds, nanos uint64
+			err := walk(value, func(number protowire.Number, kind protowire.Type, _ []byte, varint uint64) error {
+				switch {
+				case number == fieldSeconds && kind == protowire.VarintType:
+					seconds = varint
+				case number == fieldNanos && kind == protowire.VarintType:
+					nanos = varint
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			envelope.OccurredAt = time.Unix(int64(seconds), int64(nanos)).UTC()
+		}
+		return nil
+	})
+	if err != nil {
+		return Envelope{}, err
+	}
+	return envelope, nil
+}
+
+// appendString appends a string field, unless it is empty, which is its default value.
+func appendString(b []byte, number protowire.Number, value string) []byte {
+	if value == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, number, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+// walk calls fn with every field of a protobuf message: with its value if it is length-delimited, or its varint.
+func walk(data []byte, fn func(number protowire.Number, kind protowire.Type, value []byte, varint uint64) error) error {
+	for len(data) > 0 {
+		number, kind, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return fmt.Errorf("%w: %w", ErrMalformedEnvelope, protowire.ParseError(n))
+		}
+		data = data[n:]
+
+		var value []byte
+		var varint uint64
+		switch kind {
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(data)
+		case protowire.VarintType:
+			varint, n = protowire.ConsumeVarint(data)
+		default:
+			n = protowire.ConsumeFieldValue(number, kind, data)
+		}
+		if n < 0 {
+			return fmt.Errorf("%w: %w", ErrMalformedEnvelope, protowire.ParseError(n))
+		}
+		data = data[n:]
+		if err := fn(number, kind, value, varint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package messaging
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func newEnvelope() Envelope {
+	return Envelope{
+		ID:            "42",
+		Type:          "user.registered",
+		Version:       1,
+		Source:        "test",
+		AggregateType: "user",
+		AggregateID:   "alice",
+		OccurredAt:    time.Date(2026, 10, 18, 12, 0, 0, 123456789, time.UTC),
+		Data:          json.RawMessage(`{"username":"alice"}`),
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	for _, encoding := range []string{"json", "protobuf"} {
+		t.Run(encoding, func(t *testing.T) {
+			codec, err := NewCodec(encoding)
+			require.NoError(t, err)
+			data, err := codec.Marshal(newEnvelope())
+			require.NoError(t, err)
+
+			decoded, err := codec.Unmarshal(data)
+			require.NoError(t, err)
+			assert.Equal(t, newEnvelope(), decoded)
+			fromContentType, err := codecFor(codec.ContentType())
+			require.NoError(t, err)
+			assert.Equal(t, codec, fromContentType)
+
+			_, err = codec.Unmarshal([]byte{0xff})
+			assert.ErrorIs(t, err, ErrMalformedEnvelope)
+		})
+	}
+	_, err := NewCodec("xml")
+	assert.Error(t, err)
+}
+
+func TestProtobufSkipsUnknownFields(t *testing.T) {
+	data, err := ProtobufCodec{}.Marshal(newEnvelope())
+	require.NoError(t, err)
+	// A later version of the schema adds a field, which the consumers of this version ignore.
+	data = protowire.AppendTag(data, 100, protowire.BytesType)
+	data = protowire.AppendString(data, "added later")
+	data = protowire.AppendTag(data, 101, protowire.Fixed64Type)
+	data = protowire.AppendFixed64(data, 7)
+
+	decoded, err := ProtobufCodec{}.Unmarshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, newEnvelope(), decoded)
+}
+
+func TestDecode(t *testing.T) {
+	event, err := Decode[struct{ Username string }](newEnvelope())
+	require.NoError(t, err)
+	assert.Equal(t, "alice", event.Username)
+
+	_, err = Decode[struct{ Username int }](newEnvelope())
+	assert.ErrorIs(t, err, ErrMalformedEnvelope)
+}
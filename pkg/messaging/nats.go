@@ -0,0 +1,330 @@
+// Package messaging provides a client of NATS JetStream, which publishes the domain events to the other services and consumes theirs.
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The headers of the messages of the events.
+const (
+	headerContentType = "Content-Type"
+	headerEventType   = "Event-Type"
+)
+
+// defaultTimeout is the time the connection and a publication may take when the configuration sets none.
+const defaultTimeout = 5 * time.Second
+
+// Handler is a function that handles the events a consumer receives.
+// An error has the event delivered again, after the retry delay of the consumer.
+type Handler func(ctx context.Context, envelope Envelope) error
+
+// ConsumerConfig struct represents a durable consumer of the stream of the domain events.
+// Name: The durable name of the consumer, which keeps its position in the stream across restarts and is shared by its instances.
+// Types: The types of the events to consume, in which "*" matches a token and ">" the remaining ones, e.g. "user.>". Every event is consumed if it is empty.
+// MaxDeliver: The number of times an event is delivered at most. Zero delivers it until it is handled.
+// AckWait: The time a handler may take before the event is delivered again. Zero uses the default of the server.
+// RetryDelay: The time the delivery of an event that failed is delayed by, multiplied by the number of its deliveries.
+type ConsumerConfig struct {
+	Name       string
+	Types      []string
+	MaxDeliver int
+	AckWait    time.Duration
+	RetryDelay time.Duration
+}
+
+// Client struct represents a connection to NATS JetStream, to a server it embeds or to an external one.
+// The events are published to the subjects "<prefix>.<type>" of a stream, which is created if it does not exist.
+type Client struct {
+	server   *server.Server
+	storeDir string
+	conn     *nats.Conn
+	js       jetstream.JetStream
+	codec    Codec
+	stream   string
+	prefix   string
+	source   string
+	timeout  time.Duration
+
+	mu        sync.Mutex
+	consumers []jetstream.ConsumeContext
+}
+
+// Connect connects to the configured NATS server, and creates the stream of the domain events.
+// cfg: The configuration of the server and the stream.
+// Returns the client, which is nil if the mode is "none" or empty, and an error if the mode or the encoding is unknown,
+// or the server cannot be reached.
+func Connect(cfg config.NATSConfig) (*Client, error) {
+	codec, err := NewCodec(cfg.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		codec:   codec,
+		stream:  cfg.Stream,
+		prefix:  cfg.SubjectPrefix,
+		source:  cfg.Source,
+		timeout: time.Duration(cfg.TimeoutSeconds) * time.Second,
+	}
+	if c.timeout <= 0 {
+		c.timeout = defaultTimeout
+	}
+
+	storage := jetstream.FileStorage
+	var opts []nats.Option
+	url := cfg.URL
+	switch cfg.Mode {
+	case "", "none":
+		return nil, nil
+	case "external":
+	case "embedded":
+		if cfg.StoreDir == "" {
+			storage = jetstream.MemoryStorage
+		}
+		if err := c.embed(cfg); err != nil {
+			return nil, err
+		}
+		opts = append(opts, nats.InProcessServer(c.server))
+		url = ""
+	default:
+		return nil, fmt.Errorf("unknown nats mode %q", cfg.Mode)
+	}
+
+	c.conn, err = nats.Connect(url, append(opts, nats.Name(c.source), nats.Timeout(c.timeout))...)
+	if err != nil {
+		c.shutdown()
+		return nil, err
+	}
+	c.js, err = jetstream.New(c.conn)
+	if err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	_, err = c.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:       c.stream,
+		Subjects:   []string{c.prefix + ".>"},
+		Storage:    storage,
+		Duplicates: time.Duration(cfg.DuplicateWindowSeconds) * time.Second,
+	})
+	if err != nil {
+		_ = c.Close()
+		return nil, fmt.Errorf("failed to create the %s stream: %w", c.stream, err)
+	}
+	return c, nil
+}
+
+// Subject returns the subject the events of a type are published to.
+// eventType: The type of the events, e.g. "user.registered".
+// Returns the subject, e.g. "events.user.registered".
+func (c *Client) Subject(eventType string) string {
+	return c.prefix + "." + eventType
+}
+
+// ClientURL returns the URL the other services connect to the embedded server with.
+// Returns the URL, which is empty if the server is external or serves the application only.
+func (c *Client) ClientURL() string {
+	if c.server == nil || c.server.Addr() == nil {
+		return ""
+	}
+	return c.server.ClientURL()
+}
+
+// Publish publishes an event to the subject of its type, and waits for the stream to store it.
+// The stream drops an event that is published again with the same ID within its duplicate window.
+// ctx: The context for the operation.
+// envelope: The event to publish. Its source is the one of the client if it has none.
+// Returns an error if the event has no ID or type, or the stream did not store it.
+func (c *Client) Publish(ctx context.Context, envelope Envelope) error {
+	if envelope.ID == "" || envelope.Type == "" {
+		return errors.New("an event needs an ID and a type")
+	}
+	if envelope.Source == "" {
+		envelope.Source = c.source
+	}
+	data, err := c.codec.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(c.Subject(envelope.Type))
+	msg.Data = data
+	msg.Header.Set(headerContentType, c.codec.ContentType())
+	msg.Header.Set(headerEventType, envelope.Type)
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	_, err = c.js.PublishMsg(ctx, msg, jetstream.WithMsgID(envelope.Source+":"+envelope.ID))
+	return err
+}
+
+// Consume delivers the events of the stream to a handler, until the context is done or the returned function is called.
+// An event is acknowledged once the handler returns nil. An event that cannot be decoded is dropped,
+// since delivering it again would not help, and an event whose handler fails or panics is delivered again later.
+// ctx: The context the handler receives.
+// consumer: The consumer of the events, which is created if it does not exist.
+// handler: The function that handles the events.
+// Returns a function that stops the delivery, and an error if the consumer cannot be created.
+func (c *Client) Consume(ctx context.Context, consumer ConsumerConfig, handler Handler) (stop func(), err error) {
+	cfg := jetstream.ConsumerConfig{
+		Durable:    consumer.Name,
+		AckPolicy:  jetstream.AckExplicitPolicy,
+		AckWait:    consumer.AckWait,
+		MaxDeliver: consumer.MaxDeliver,
+	}
+	if cfg.MaxDeliver == 0 {
+		cfg.MaxDeliver = -1
+	}
+	var subjects []string
+	for _, eventType := range consumer.Types {
+		subjects = append(subjects, c.Subject(eventType))
+	}
+	if len(subjects) == 1 {
+		cfg.FilterSubject = subjects[0]
+	} else {
+		cfg.FilterSubjects = subjects
+	}
+
+	createCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	created, err := c.js.CreateOrUpdateConsumer(createCtx, c.stream, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the %s consumer: %w", consumer.Name, err)
+	}
+	consumeCtx, err := created.Consume(func(msg jetstream.Msg) {
+		c.handle(ctx, consumer, handler, msg)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.consumers = append(c.consumers, consumeCtx)
+	c.mu.Unlock()
+	var once sync.Once
+	stop = func() {
+		once.Do(consumeCtx.Stop)
+	}
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return stop, nil
+}
+
+// Close stops the consumers, waits for the pending publications and shuts the embedded server down.
+// Returns an error if the connection cannot be drained.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	for _, consumer := range c.consumers {
+		consumer.Stop()
+	}
+	c.consumers = nil
+	c.mu.Unlock()
+
+	var err error
+	if c.conn != nil {
+		err = c.conn.Drain()
+		// The connection drains in the background, and must be closed before the embedded server shuts down.
+		deadline := time.Now().Add(c.timeout)
+		for !c.conn.IsClosed() && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	c.shutdown()
+	return err
+}
+
+// handle decodes an event and hands it to the handler, and acknowledges it according to the outcome.
+func (c *Client) handle(ctx context.Context, consumer ConsumerConfig, handler Handler, msg jetstream.Msg) {
+	codec, err := codecFor(msg.Headers().Get(headerContentType))
+	var envelope Envelope
+	if err == nil {
+		envelope, err = codec.Unmarshal(msg.Data())
+	}
+	if err != nil {
+		log.Printf("Dropped a malformed event of %s on %s: %v\n", consumer.Name, msg.Subject(), err)
+		_ = msg.Term()
+		return
+	}
+
+	if err := call(ctx, handler, envelope); err != nil {
+		delay := consumer.RetryDelay
+		if metadata, err := msg.Metadata(); err == nil && metadata.NumDelivered > 0 {
+			delay *= time.Duration(metadata.NumDelivered)
+		}
+		log.Printf("Failed to handle the event %s %s in %s: %v\n", envelope.Type, envelope.ID, consumer.Name, err)
+		_ = msg.NakWithDelay(delay)
+		return
+	}
+	_ = msg.Ack()
+}
+
+// call calls a handler, and turns a panic into an error.
+func call(ctx context.Context, handler Handler, envelope Envelope) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = fmt.Errorf("handler panicked: %v", value)
+		}
+	}()
+	return handler(ctx, envelope)
+}
+
+// embed starts a NATS server with JetStream in the process. It listens on the configured port only if it is set.
+func (c *Client) embed(cfg config.NATSConfig) error {
+	storeDir := cfg.StoreDir
+	if storeDir == "" {
+		// The server needs a directory even if the streams are kept in memory.
+		dir, err := os.MkdirTemp("", "nats-")
+		if err != nil {
+			return err
+		}
+		storeDir, c.storeDir = dir, dir
+	}
+	opts := &server.Options{
+		ServerName: strings.ReplaceAll(c.source, " ", "-"),
+		JetStream:  true,
+		StoreDir:   storeDir,
+		Port:       cfg.Port,
+		DontListen: cfg.Port == 0,
+		NoSigs:     true,
+		NoLog:      true,
+	}
+	ns, err := server.NewServer(opts)
+	if err != nil {
+		c.shutdown()
+		return err
+	}
+	c.server = ns
+	ns.Start()
+	if !ns.ReadyForConnections(c.timeout) {
+		c.shutdown()
+		return errors.New("the embedded nats server did not start in time")
+	}
+	return nil
+}
+
+// shutdown stops the embedded server and removes its temporary directory.
+func (c *Client) shutdown() {
+	if c.server != nil {
+		c.server.Shutdown()
+		c.server.WaitForShutdown()
+		c.server = nil
+	}
+	if c.storeDir != "" {
+		_ = os.RemoveAll(c.storeDir)
+		c.storeDir = ""
+	}
+}
@@ -0,0 +1,128 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"github.com/nats-io/nats.go"
+	"github.com/nikita-voronoy/go-clean-arch/config"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, encoding string) *Client {
+	client, err := Connect(config.NATSConfig{
+		Mode:                   "embedded",
+		Stream:                 "EVENTS",
+		SubjectPrefix:          "events",
+		Encoding:               encoding,
+		Source:                 "test",
+		DuplicateWindowSeconds: 60,
+		TimeoutSeconds:         5,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// received collects the envelopes a consumer handles.
+type received struct {
+	mu        sync.Mutex
+	envelopes []Envelope
+}
+
+func (r *received) add(envelope Envelope) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.envelopes = append(r.envelopes, envelope)
+}
+
+func (r *received) ids() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ids []string
+	for _, envelope := range r.envelopes {
+		ids = append(ids, envelope.ID)
+	}
+	return ids
+}
+
+func TestConnect(t *testing.T) {
+	client, err := Connect(config.NATSConfig{Mode: "none"})
+	require.NoError(t, err)
+	assert.Nil(t, client)
+	_, err = Connect(config.NATSConfig{Mode: "clustered"})
+	assert.Error(t, err)
+	_, err = Connect(config.NATSConfig{Mode: "external", URL: "nats://127.0.0.1:1", TimeoutSeconds: 1})
+	assert.Error(t, err, "an unreachable server fails the connection")
+}
+
+func TestPublishAndConsume(t *testing.T) {
+	for _, encoding := range []string{"json", "protobuf"} {
+		t.Run(encoding, func(t *testing.T) {
+			client := newTestClient(t, encoding)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			for _, envelope := range []Envelope{
+				{ID: "1", Type: "user.registered", Version: 1, Data: []byte(`{}`)},
+				{ID: "2", Type: "user.deleted", Version: 1, Data: []byte(`{}`)},
+				{ID: "1", Type: "user.registered", Version: 1, Data: []byte(`{}`)},
+				{ID: "3", Type: "organization.created", Version: 1, Data: []byte(`{}`)},
+			} {
+				require.NoError(t, client.Publish(ctx, envelope))
+			}
+			assert.Error(t, client.Publish(ctx, Envelope{Type: "user.registered"}), "an event needs an ID")
+
+			var users received
+			_, err := client.Consume(ctx, ConsumerConfig{Name: "users", Types: []string{"user.>"}}, func(_ context.Context, envelope Envelope) error {
+				users.add(envelope)
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Eventually(t, func() bool { return len(users.ids()) == 2 }, 5*time.Second, 10*time.Millisecond)
+			assert.Equal(t, []string{"1", "2"}, users.ids(), "the duplicate is dropped and the other subjects are filtered out")
+			assert.Equal(t, "test", users.envelopes[0].Source)
+		})
+	}
+}
+
+func TestConsumeRetriesAndDropsMalformedEvents(t *testing.T) {
+	client := newTestClient(t, "json")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// An event published without the envelope cannot be decoded, and is not delivered again.
+	msg := nats.NewMsg(client.Subject("user.registered"))
+	msg.Data = []byte("not an envelope")
+	_, err := client.js.PublishMsg(ctx, msg)
+	require.NoError(t, err)
+	require.NoError(t, client.Publish(ctx, Envelope{ID: "1", Type: "user.registered", Data: []byte(`{}`)}))
+
+	var handled received
+	var attempts int
+	var mu sync.Mutex
+	stop, err := client.Consume(ctx, ConsumerConfig{Name: "flaky", RetryDelay: 10 * time.Millisecond}, func(_ context.Context, envelope Envelope) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		switch attempts {
+		case 1:
+			return errors.New("unavailable")
+		case 2:
+			panic("boom")
+		}
+		handled.add(envelope)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return len(handled.ids()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 3, attempts, "a failed or panicking handler has the event delivered again")
+}
@@ -0,0 +1,162 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// lockName is the name of the advisory lock the migrators of a database share.
+const lockName = "schema_migrations"
+
+// lockTimeout is how long a migrator waits for another migrator to finish.
+const lockTimeout = 5 * time.Minute
+
+// Dialect interface represents the differences between the SQL databases that matter to the migrations.
+type Dialect interface {
+	// Name returns the name of the directory the migrations of the dialect are read from.
+	Name() string
+	// Placeholder returns the placeholder of the n-th argument of a statement, counting from 1.
+	Placeholder(n int) string
+	// Variables returns the variables the migration files are executed with as templates.
+	Variables() map[string]string
+	// Split splits a migration file into the statements to execute.
+	Split(script string) []string
+	// Lock acquires the migration lock of the database on the connection.
+	// Returns the function that releases the lock, and an error if it cannot be acquired.
+	Lock(ctx context.Context, conn *sql.Conn) (func(), error)
+}
+
+// SQLite returns the dialect of SQLite.
+// SQLite has no advisory locks: the migrations of concurrent migrators are serialized by the write lock of their transactions.
+// The migration files and the repeatable scripts refer to the FTS5 extension as {{.FTS5}}, which is empty since the library may lack it, see SQLiteFTS5.
+func SQLite() Dialect {
+	return sqliteDialect{}
+}
+
+// SQLiteFTS5 returns the dialect of a SQLite library built with the FTS5 extension, so that the repeatable scripts create
+// the full-text indexes in their {{if .FTS5}} sections.
+func SQLiteFTS5() Dialect {
+	return sqliteDialect{fts5: true}
+}
+
+type sqliteDialect struct {
+	fts5 bool
+}
+
+func (sqliteDialect) Name() string           { return "sqlite" }
+func (sqliteDialect) Placeholder(int) string { return "?" }
+func (d sqliteDialect) Variables() map[string]string {
+	if d.fts5 {
+		return map[string]string{"FTS5": "true"}
+	}
+	return map[string]string{"FTS5": ""}
+}
+func (sqliteDialect) Split(script string) []string                    { return []string{script} }
+func (sqliteDialect) Lock(context.Context, *sql.Conn) (func(), error) { return func() {}, nil }
+
+// Postgres returns the dialect of PostgreSQL, which holds a session-level advisory lock while migrating.
+func Postgres() Dialect {
+	return postgresDialect{}
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string                 { return "postgres" }
+func (postgresDialect) Placeholder(n int) string     { return fmt.Sprintf("$%d", n) }
+func (postgresDialect) Variables() map[string]string { return map[string]string{} }
+func (postgresDialect) Split(script string) []string { return []string{script} }
+
+func (postgresDialect) Lock(ctx context.Context, conn *sql.Conn) (func(), error) {
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock(hashtext($1))", lockName); err != nil {
+		return nil, err
+	}
+	return func() {
+		_, _ = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(hashtext($1))", lockName)
+	}, nil
+}
+
+// MySQL returns the dialect of MySQL, which holds a named lock while migrating.
+// MySQL commits DDL implicitly, so a migration that fails halfway is not rolled back.
+// uuidType: The column type of the UUIDs, which the migration files refer to as {{.UUID}}.
+func MySQL(uuidType string) Dialect {
+	return mysqlDialect{uuidType: uuidType}
+}
+
+type mysqlDialect struct {
+	uuidType string
+}
+
+func (mysqlDialect) Name() string           { return "mysql" }
+func (mysqlDialect) Placeholder(int) string { return "?" }
+func (d mysqlDialect) Variables() map[string]string {
+	return map[string]string{"UUID": d.uuidType}
+}
+
+// Split splits the script on the semicolons outside of quotes and comments, since the driver executes one statement at a time.
+func (mysqlDialect) Split(script string) []string {
+	var (
+		statements []string
+		current    strings.Builder
+		quote      byte
+	)
+	flush := func() {
+		if statement := strings.TrimSpace(current.String()); statement != "" {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+	}
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' && i+1 < len(script) {
+				current.WriteByte(c)
+				i++
+				c = script[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '-' && strings.HasPrefix(script[i:], "-- "), c == '#':
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			i += end
+			current.WriteByte('\n')
+			continue
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				end = len(script) - i - 2
+			}
+			i += end + 3
+			current.WriteByte(' ')
+			continue
+		case c == ';':
+			flush()
+			continue
+		}
+		current.WriteByte(c)
+	}
+	flush()
+	return statements
+}
+
+func (mysqlDialect) Lock(ctx context.Context, conn *sql.Conn) (func(), error) {
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(lockTimeout.Seconds())).Scan(&acquired); err != nil {
+		return nil, err
+	}
+	if acquired.Int64 != 1 {
+		return nil, errors.New("another migrator holds the lock")
+	}
+	return func() {
+		_, _ = conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+	}, nil
+}
@@ -0,0 +1,481 @@
+// Package migrate provides the versioned SQL migrations of the schema of a database.
+// Every module ships its migrations as files, usually embedded with embed.FS, in a directory per dialect:
+// <dialect>/<version>_<name>.up.sql applies a migration and <dialect>/<version>_<name>.down.sql reverts it.
+// The applied migrations are recorded in the schema_migrations table.
+// A module may also ship repeatable scripts, <dialect>/repeatable/<name>.sql, which are idempotent and run after every migration is applied,
+// such as the objects that depend on what the database library is built with.
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Table is the table the applied migrations are recorded in.
+const Table = "schema_migrations"
+
+// VersionLayout is the layout of the version of a new migration, the UTC time it was created at.
+const VersionLayout = "20060102150405"
+
+// ErrIrreversible is returned when a migration without a down file is reverted.
+var ErrIrreversible = errors.New("migration cannot be reverted")
+
+// repeatableDir is the directory of the repeatable scripts of a dialect.
+const repeatableDir = "repeatable"
+
+// filename matches the name of a migration file, e.g. "20240101120000_create_users.up.sql".
+var filename = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// scriptname matches the name of a repeatable script, e.g. "users_search.sql".
+var scriptname = regexp.MustCompile(`^([a-z0-9_]+)\.sql$`)
+
+// Migration struct represents a versioned change of the schema.
+type Migration struct {
+	Version int64  // The version of the migration, which orders the migrations of every module.
+	Name    string // The name of the migration, e.g. "create_users".
+	Module  string // The module the migration belongs to.
+	Up      string // The SQL that applies the migration.
+	Down    string // The SQL that reverts the migration. It is empty if the migration cannot be reverted.
+}
+
+// String returns the version and the name of the migration, e.g. "20240101120000_create_users".
+func (m Migration) String() string {
+	return fmt.Sprintf("%d_%s", m.Version, m.Name)
+}
+
+// Script struct represents a repeatable script, which brings the schema to a state regardless of the state it finds.
+type Script struct {
+	Name   string // The name of the script, e.g. "users_search".
+	Module string // The module the script belongs to.
+	SQL    string // The SQL of the script, which must be idempotent.
+}
+
+// String returns the module and the name of the script, e.g. "auth/users_search".
+func (s Script) String() string {
+	return s.Module + "/" + s.Name
+}
+
+// Source struct represents the migrations of a module.
+type Source struct {
+	Module string // The name of the module.
+	FS     fs.FS  // The migration files, in a directory per dialect.
+}
+
+// Status struct represents the state of a migration in a database.
+type Status struct {
+	Migration           // The migration. Only the version, the name and the module are known of a missing migration.
+	AppliedAt time.Time // The time the migration was applied at, or the zero time if it is pending.
+	Missing   bool      // Whether the migration is applied but none of the sources has its files.
+}
+
+// Load reads the migrations of a dialect from the sources.
+// The SQL of the migrations is a text/template, which is executed with the variables of the dialect, e.g. {{.UUID}}.
+// dialect: The dialect of the database.
+// sources: The migrations of the modules.
+// Returns the migrations ordered by version, and an error if a file cannot be read, is misnamed,
+// has no up file, or if two migrations have the same version.
+func Load(dialect Dialect, sources ...Source) ([]Migration, error) {
+	byVersion := make(map[int64]*Migration)
+	for _, source := range sources {
+		entries, err := fs.ReadDir(source.FS, dialect.Name())
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("migrate: %s: %w", source.Module, err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() && entry.Name() == repeatableDir {
+				continue
+			}
+			match := filename.FindStringSubmatch(entry.Name())
+			if entry.IsDir() || match == nil {
+				return nil, fmt.Errorf("migrate: %s: %s is not named <version>_<name>.(up|down).sql", source.Module, entry.Name())
+			}
+			version, err := strconv.ParseInt(match[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("migrate: %s: %s: %w", source.Module, entry.Name(), err)
+			}
+			m, ok := byVersion[version]
+			if !ok {
+				m = &Migration{Version: version, Name: match[2], Module: source.Module}
+				byVersion[version] = m
+			}
+			if m.Module != source.Module || m.Name != match[2] {
+				return nil, fmt.Errorf("migrate: the version %d is used by %s/%s and %s/%s", version, m.Module, m.Name, source.Module, match[2])
+			}
+			text, err := render(source.FS, path.Join(dialect.Name(), entry.Name()), dialect.Variables())
+			if err != nil {
+				return nil, fmt.Errorf("migrate: %s: %w", source.Module, err)
+			}
+			if match[3] == "up" {
+				m.Up = text
+			} else {
+				m.Down = text
+			}
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migrate: %s: %s has no up file", m.Module, m)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// LoadScripts reads the repeatable scripts of a dialect from the sources.
+// The SQL of the scripts is a text/template, which is executed with the variables of the dialect as the migrations are.
+// dialect: The dialect of the database.
+// sources: The scripts of the modules.
+// Returns the scripts ordered by name and module, and an error if a file cannot be read or is misnamed.
+func LoadScripts(dialect Dialect, sources ...Source) ([]Script, error) {
+	var scripts []Script
+	for _, source := range sources {
+		dir := path.Join(dialect.Name(), repeatableDir)
+		entries, err := fs.ReadDir(source.FS, dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("migrate: %s: %w", source.Module, err)
+		}
+		for _, entry := range entries {
+			match := scriptname.FindStringSubmatch(entry.Name())
+			if entry.IsDir() || match == nil {
+				return nil, fmt.Errorf("migrate: %s: %s is not named <name>.sql", source.Module, path.Join(repeatableDir, entry.Name()))
+			}
+			text, err := render(source.FS, path.Join(dir, entry.Name()), dialect.Variables())
+			if err != nil {
+				return nil, fmt.Errorf("migrate: %s: %w", source.Module, err)
+			}
+			scripts = append(scripts, Script{Name: match[1], Module: source.Module, SQL: text})
+		}
+	}
+	sort.Slice(scripts, func(i, j int) bool {
+		if scripts[i].Name != scripts[j].Name {
+			return scripts[i].Name < scripts[j].Name
+		}
+		return scripts[i].Module < scripts[j].Module
+	})
+	return scripts, nil
+}
+
+// render executes the template of a migration file with the variables of the dialect.
+func render(fsys fs.FS, name string, variables map[string]string) (string, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, variables); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Migrator struct represents the migrations of a database.
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+	scripts    []Script
+}
+
+// New creates a new migrator of the database with the migrations of the sources.
+// db: The database to migrate.
+// dialect: The dialect of the database.
+// sources: The migrations of the modules.
+// Returns a *Migrator object and an error if the migrations or the repeatable scripts cannot be loaded.
+func New(db *sql.DB, dialect Dialect, sources ...Source) (*Migrator, error) {
+	migrations, err := Load(dialect, sources...)
+	if err != nil {
+		return nil, err
+	}
+	scripts, err := LoadScripts(dialect, sources...)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations, scripts: scripts}, nil
+}
+
+// Dialect returns the name of the dialect of the database, which is the directory its migrations are read from.
+func (m *Migrator) Dialect() string {
+	return m.dialect.Name()
+}
+
+// Up applies the pending migrations in the order of their versions.
+// Every migration runs in a transaction, together with its record in the schema_migrations table, on the dialects whose DDL is transactional.
+// Once no migration is pending, the repeatable scripts run, each in a transaction, even if no migration was applied.
+// ctx: The context for the operation.
+// steps: The number of migrations to apply. Zero or a negative value applies every pending migration.
+// Returns the applied migrations and an error if a migration or a script fails. The migrations before the failed one stay applied.
+func (m *Migrator) Up(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int64]time.Time) error {
+		for _, migration := range m.migrations {
+			if steps > 0 && len(done) == steps {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			ran, err := m.apply(ctx, conn, migration, true)
+			if err != nil {
+				return fmt.Errorf("migrate: %s: %w", migration, err)
+			}
+			if ran {
+				done = append(done, migration)
+			}
+			applied[migration.Version] = time.Now()
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; !ok {
+				return nil
+			}
+		}
+		for _, script := range m.scripts {
+			if err := m.run(ctx, conn, script); err != nil {
+				return fmt.Errorf("migrate: %s: %w", script, err)
+			}
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the most recently applied migrations, in the reverse order of their versions.
+// ctx: The context for the operation.
+// steps: The number of migrations to revert. Zero or a negative value reverts every applied migration.
+// Returns the reverted migrations and an error if a migration fails, its files are missing, or it cannot be reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	known := make(map[int64]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int64]time.Time) error {
+		versions := make([]int64, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for _, version := range versions {
+			if steps > 0 && len(done) == steps {
+				break
+			}
+			migration, ok := known[version]
+			if !ok {
+				return fmt.Errorf("migrate: the files of the applied migration %d are missing", version)
+			}
+			if strings.TrimSpace(migration.Down) == "" {
+				return fmt.Errorf("migrate: %s: %w", migration, ErrIrreversible)
+			}
+			ran, err := m.apply(ctx, conn, migration, false)
+			if err != nil {
+				return fmt.Errorf("migrate: %s: %w", migration, err)
+			}
+			if ran {
+				done = append(done, migration)
+			}
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status reports the state of every migration, ordered by version.
+// ctx: The context for the operation.
+// Returns the states of the known migrations and of the applied migrations whose files are missing, and an error if the operation fails.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int64]time.Time) error {
+		names, err := m.appliedNames(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			statuses = append(statuses, Status{Migration: migration, AppliedAt: applied[migration.Version]})
+			delete(names, migration.Version)
+		}
+		for version, migration := range names {
+			statuses = append(statuses, Status{Migration: migration, AppliedAt: applied[version], Missing: true})
+		}
+		return nil
+	})
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, err
+}
+
+// locked runs fn on a connection that holds the migration lock of the database, so that concurrent migrators,
+// such as several instances of the application starting at once, apply every migration once.
+// fn receives the applied versions and the times they were applied at.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn, applied map[int64]time.Time) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	unlock, err := m.dialect.Lock(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("migrate: failed to acquire the migration lock: %w", err)
+	}
+	defer unlock()
+
+	if _, err := conn.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+Table+" (version BIGINT NOT NULL PRIMARY KEY, module VARCHAR(255) NOT NULL, name VARCHAR(255) NOT NULL, applied_at TIMESTAMP NOT NULL)"); err != nil {
+		return fmt.Errorf("migrate: failed to create %s: %w", Table, err)
+	}
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM "+Table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			version   int64
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return err
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_ = rows.Close()
+	return fn(conn, applied)
+}
+
+// appliedNames returns the modules and the names of the applied migrations by version.
+func (m *Migrator) appliedNames(ctx context.Context, conn *sql.Conn) (map[int64]Migration, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, module, name FROM "+Table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names := make(map[int64]Migration)
+	for rows.Next() {
+		var migration Migration
+		if err := rows.Scan(&migration.Version, &migration.Module, &migration.Name); err != nil {
+			return nil, err
+		}
+		names[migration.Version] = migration
+	}
+	return names, rows.Err()
+}
+
+// apply applies or reverts a migration and records it in a transaction.
+// The record is checked again in the transaction, which takes the write lock of the dialects without a migration lock,
+// so that a migration another migrator applied in the meantime is skipped.
+// Returns whether the migration ran, and an error if it fails.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration, up bool) (bool, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var count int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+Table+" WHERE version = "+m.dialect.Placeholder(1), migration.Version).Scan(&count); err != nil {
+		return false, err
+	}
+	if (count > 0) == up {
+		return false, nil
+	}
+
+	script, record, args := migration.Down, "DELETE FROM "+Table+" WHERE version = "+m.dialect.Placeholder(1), []interface{}{migration.Version}
+	if up {
+		script = migration.Up
+		record = fmt.Sprintf("INSERT INTO %s (version, module, name, applied_at) VALUES (%s, %s, %s, %s)", Table,
+			m.dialect.Placeholder(1), m.dialect.Placeholder(2), m.dialect.Placeholder(3), m.dialect.Placeholder(4))
+		args = []interface{}{migration.Version, migration.Module, migration.Name, time.Now().UTC()}
+	}
+	for _, statement := range m.dialect.Split(script) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return false, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// run runs a repeatable script in a transaction.
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, script Script) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, statement := range m.dialect.Split(script.SQL) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Create writes the empty up and down files of a new migration for every dialect.
+// dir: The migrations directory of the module, which holds a directory per dialect.
+// name: The name of the migration. It is converted to lowercase snake case.
+// dialects: The names of the dialects to write the files for.
+// now: The time the migration is created at, which is its version.
+// Returns the paths of the written files and an error if the name is empty or a file cannot be written.
+func Create(dir, name string, dialects []string, now time.Time) ([]string, error) {
+	name = strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return nil, errors.New("migrate: the name of the migration is empty")
+	}
+	version := now.UTC().Format(VersionLayout)
+
+	var paths []string
+	for _, dialect := range dialects {
+		if err := os.MkdirAll(filepath.Join(dir, dialect), 0o755); err != nil {
+			return paths, err
+		}
+		for _, direction := range []string{"up", "down"} {
+			p := filepath.Join(dir, dialect, fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+			content := fmt.Sprintf("-- %s: %s the migration %s.\n", dialect, map[string]string{"up": "Applies", "down": "Reverts"}[direction], name)
+			f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+			if err != nil {
+				return paths, err
+			}
+			_, err = f.WriteString(content)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return paths, err
+			}
+			paths = append(paths, p)
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,262 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// source returns a source of migration files with the given contents.
+func source(module string, files map[string]string) Source {
+	fsys := fstest.MapFS{}
+	for name, data := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(data)}
+	}
+	return Source{Module: module, FS: fsys}
+}
+
+var (
+	users = source("auth", map[string]string{
+		"sqlite/1_create_users.up.sql":   "CREATE TABLE users (id integer PRIMARY KEY); CREATE INDEX idx_users ON users (id);",
+		"sqlite/1_create_users.down.sql": "DROP TABLE users;",
+		"sqlite/3_add_email.up.sql":      "ALTER TABLE users ADD COLUMN email text;",
+		"sqlite/3_add_email.down.sql":    "ALTER TABLE users DROP COLUMN email;",
+		"mysql/1_create_users.up.sql":    "CREATE TABLE users (id {{.UUID}} PRIMARY KEY);",
+	})
+	events = source("audit", map[string]string{
+		"sqlite/2_create_events.up.sql": "CREATE TABLE events (id integer PRIMARY KEY);",
+	})
+)
+
+func openSQLite(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "migrate.sqlite3")+"?_txlock=immediate&_busy_timeout=5000")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func versions(migrations []Migration) []int64 {
+	var v []int64
+	for _, m := range migrations {
+		v = append(v, m.Version)
+	}
+	return v
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(SQLite(), users, events)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, versions(migrations), "the migrations of every module are ordered by version")
+	assert.Equal(t, "audit", migrations[1].Module)
+	assert.Equal(t, "1_create_users", migrations[0].String())
+
+	migrations, err = Load(MySQL("binary(16)"), users, events)
+	require.NoError(t, err)
+	require.Len(t, migrations, 1)
+	assert.Equal(t, "CREATE TABLE users (id binary(16) PRIMARY KEY);", migrations[0].Up)
+
+	_, err = Load(SQLite(), events, source("other", map[string]string{"sqlite/2_other.up.sql": "SELECT 1"}))
+	assert.ErrorContains(t, err, "the version 2 is used by")
+	_, err = Load(SQLite(), source("other", map[string]string{"sqlite/create.sql": "SELECT 1"}))
+	assert.ErrorContains(t, err, "is not named")
+	_, err = Load(SQLite(), source("other", map[string]string{"sqlite/1_create.down.sql": "SELECT 1"}))
+	assert.ErrorContains(t, err, "has no up file")
+	_, err = Load(SQLite(), source("other", map[string]string{"sqlite/1_create.up.sql": "{{.UUID}}"}))
+	assert.Error(t, err, "an unknown variable is an error")
+
+	fts := source("other", map[string]string{"sqlite/1_create.up.sql": "-- index\n{{if .FTS5}}CREATE VIRTUAL TABLE t USING fts5(a);{{end}}"})
+	migrations, err = Load(SQLite(), fts)
+	require.NoError(t, err)
+	assert.Equal(t, "-- index\n", migrations[0].Up, "the FTS5 sections are left out without the extension")
+	migrations, err = Load(SQLiteFTS5(), fts)
+	require.NoError(t, err)
+	assert.Equal(t, "-- index\nCREATE VIRTUAL TABLE t USING fts5(a);", migrations[0].Up)
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+	m, err := New(db, SQLite(), users, events)
+	require.NoError(t, err)
+
+	applied, err := m.Up(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, versions(applied))
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+	assert.False(t, statuses[1].AppliedAt.IsZero())
+	assert.True(t, statuses[2].AppliedAt.IsZero(), "the third migration is pending")
+
+	applied, err = m.Up(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3}, versions(applied))
+	_, err = db.Exec("INSERT INTO users (id, email) VALUES (1, 'alice@example.com')")
+	require.NoError(t, err)
+
+	reverted, err := m.Down(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3}, versions(reverted))
+
+	_, err = m.Down(ctx, 0)
+	assert.ErrorIs(t, err, ErrIrreversible, "the events migration has no down file")
+
+	// A migrator without the files of an applied migration reports it as missing and refuses to revert it.
+	m, err = New(db, SQLite(), users)
+	require.NoError(t, err)
+	statuses, err = m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+	assert.True(t, statuses[1].Missing)
+	assert.Equal(t, "create_events", statuses[1].Name)
+	_, err = m.Down(ctx, 0)
+	assert.ErrorContains(t, err, "the files of the applied migration 2 are missing")
+}
+
+func TestMigratorRollsBackFailedMigration(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+	broken := source("auth", map[string]string{
+		"sqlite/1_create_users.up.sql": "CREATE TABLE users (id integer PRIMARY KEY); CREATE TABLE users (id integer);",
+	})
+	m, err := New(db, SQLite(), broken)
+	require.NoError(t, err)
+
+	_, err = m.Up(ctx, 0)
+	assert.ErrorContains(t, err, "1_create_users")
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'users'").Scan(&count))
+	assert.Zero(t, count, "the statements of the failed migration are rolled back")
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM "+Table).Scan(&count))
+	assert.Zero(t, count)
+}
+
+func TestConcurrentMigrators(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "migrate.sqlite3") + "?_txlock=immediate&_busy_timeout=5000"
+
+	results := make(chan []Migration, 4)
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		go func() {
+			// Every migrator has its own connections, like the instances of the application.
+			db, err := sql.Open("sqlite3", path)
+			if err != nil {
+				errs <- err
+				results <- nil
+				return
+			}
+			defer db.Close()
+			m, err := New(db, SQLite(), users, events)
+			if err != nil {
+				errs <- err
+				results <- nil
+				return
+			}
+			applied, err := m.Up(ctx, 0)
+			errs <- err
+			results <- applied
+		}()
+	}
+	total := 0
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, <-errs)
+		total += len(<-results)
+	}
+	assert.Equal(t, 3, total, "every migration is applied once")
+}
+
+func TestSplit(t *testing.T) {
+	script := `-- Creates the users; and their index.
+CREATE TABLE users (name varchar(10) DEFAULT 'a;b', note text COMMENT "it's; fine");
+/* a block; comment */ CREATE INDEX idx ON users (name);
+# a hash; comment
+INSERT INTO users (name) VALUES ('it\'s;');`
+
+	assert.Equal(t, []string{
+		"CREATE TABLE users (name varchar(10) DEFAULT 'a;b', note text COMMENT \"it's; fine\")",
+		"CREATE INDEX idx ON users (name)",
+		"INSERT INTO users (name) VALUES ('it\\'s;')",
+	}, MySQL("char(36)").Split(script))
+	assert.Equal(t, []string{script}, SQLite().Split(script))
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	paths, err := Create(dir, "Add Users' Email", []string{"sqlite", "mysql"}, now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "sqlite", "20240102030405_add_users_email.up.sql"),
+		filepath.Join(dir, "sqlite", "20240102030405_add_users_email.down.sql"),
+		filepath.Join(dir, "mysql", "20240102030405_add_users_email.up.sql"),
+		filepath.Join(dir, "mysql", "20240102030405_add_users_email.down.sql"),
+	}, paths)
+
+	_, err = Create(dir, "Add Users' Email", []string{"sqlite"}, now)
+	assert.ErrorIs(t, err, os.ErrExist, "an existing migration is not overwritten")
+	_, err = Create(dir, "!!", []string{"sqlite"}, now)
+	assert.Error(t, err)
+
+	migrations, err := Load(SQLite(), Source{Module: "auth", FS: os.DirFS(dir)})
+	require.NoError(t, err)
+	require.Len(t, migrations, 1)
+	assert.Equal(t, int64(20240102030405), migrations[0].Version)
+}
+
+func TestRepeatableScripts(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+	search := source("search", map[string]string{
+		"sqlite/repeatable/users_search.sql": "{{if .FTS5}}CREATE TABLE IF NOT EXISTS users_search (id integer);" +
+			"INSERT INTO users_search (id) SELECT id FROM users WHERE NOT EXISTS (SELECT 1 FROM users_search);{{end}}",
+	})
+	count := func(table string) int {
+		var n int
+		require.NoError(t, db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&n))
+		if n == 0 {
+			return -1
+		}
+		require.NoError(t, db.QueryRow("SELECT count(*) FROM "+table).Scan(&n))
+		return n
+	}
+
+	scripts, err := LoadScripts(SQLite(), users, search)
+	require.NoError(t, err)
+	require.Len(t, scripts, 1)
+	assert.Equal(t, "search/users_search", scripts[0].String())
+	_, err = Load(SQLite(), users, search)
+	require.NoError(t, err, "the repeatable scripts are not migrations")
+	_, err = LoadScripts(SQLite(), source("other", map[string]string{"sqlite/repeatable/1_create.up.sql": "SELECT 1"}))
+	assert.ErrorContains(t, err, "is not named")
+
+	fts5, err := New(db, SQLiteFTS5(), users, events, search)
+	require.NoError(t, err)
+	_, err = fts5.Up(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, -1, count("users_search"), "the scripts wait for the pending migrations")
+
+	plain, err := New(db, SQLite(), users, events, search)
+	require.NoError(t, err)
+	_, err = plain.Up(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, -1, count("users_search"), "the FTS5 sections are left out without the extension")
+	_, err = db.Exec("INSERT INTO users (id, email) VALUES (1, 'alice@example.com'), (2, 'bob@example.com')")
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		applied, err := fts5.Up(ctx, 0)
+		require.NoError(t, err)
+		assert.Empty(t, applied)
+		assert.Equal(t, 2, count("users_search"), "a database migrated without the extension gets the index once, with its rows")
+	}
+}
@@ -0,0 +1,105 @@
+package scim
+
+import (
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"strings"
+	"unicode"
+)
+
+// Column struct represents the column of the storage an attribute of the resources is stored in,
+// which the filters on the attribute are translated to.
+// Name: The name of the column.
+// Value: Converts a value of the attribute to a value of the column, and returns false if it cannot, e.g. an ID that is not a UUID,
+// in which case the filter is left to the caller. The column stores the strings as they are if it is nil, and only the strings are compared with it.
+type Column struct {
+	Name  string
+	Value func(value interface{}) (interface{}, bool)
+}
+
+// Condition translates the part of a filter the storage can evaluate to a condition, so that the storage selects and pages the resources.
+// The strings of the attributes that are not case-exact are matched with patterns, which ignore the case of the ASCII letters
+// on every database, so that only the ASCII values are translated. The presence tests, the negations, the multi-valued and
+// the qualified attributes are left to the caller, as are the attributes without a column.
+// filter: The filter to translate. A nil filter matches every resource.
+// columns: The columns of the attributes, keyed by their lower case names, e.g. "username" or "externalid".
+// Returns the condition, which is nil if it matches every resource, and the rest of the filter, which the caller applies
+// to the resources the condition selects, and which is nil if the condition is equivalent to the filter.
+func Condition(filter Filter, columns map[string]Column) (query.Condition, Filter) {
+	switch f := filter.(type) {
+	case nil:
+		return nil, nil
+	case andFilter:
+		left, leftRest := Condition(f.left, columns)
+		right, rightRest := Condition(f.right, columns)
+		var rest Filter
+		switch {
+		case leftRest != nil && rightRest != nil:
+			rest = andFilter{left: leftRest, right: rightRest}
+		case leftRest != nil:
+			rest = leftRest
+		default:
+			rest = rightRest
+		}
+		return query.And(left, right), rest
+	case orFilter:
+		left, leftRest := Condition(f.left, columns)
+		right, rightRest := Condition(f.right, columns)
+		if leftRest != nil || rightRest != nil {
+			return nil, f
+		}
+		return query.Or(left, right), nil
+	case compareFilter:
+		if condition := compareCondition(f, columns); condition != nil {
+			return condition, nil
+		}
+	}
+	return nil, filter
+}
+
+// compareCondition translates a comparison to a condition.
+// Returns the condition, or nil if the storage cannot evaluate the comparison as the filter does.
+func compareCondition(f compareFilter, columns map[string]Column) query.Condition {
+	column, ok := columns[strings.ToLower(f.path.Attr)]
+	if !ok || f.path.URN != "" || f.path.Sub != "" || f.value == nil {
+		return nil
+	}
+	if text, ok := f.value.(string); ok && column.Value == nil && !caseExact[strings.ToLower(f.path.Attr)] {
+		if !ascii(text) {
+			return nil
+		}
+		pattern := query.Escape(text)
+		switch f.op {
+		case "eq":
+			return query.Like(column.Name, pattern)
+		case "co":
+			return query.Like(column.Name, "%"+pattern+"%")
+		case "sw":
+			return query.Like(column.Name, pattern+"%")
+		case "ew":
+			return query.Like(column.Name, "%"+pattern)
+		}
+		return nil
+	}
+	if f.op != "eq" {
+		return nil
+	}
+	value := f.value
+	if column.Value != nil {
+		if value, ok = column.Value(value); !ok {
+			return nil
+		}
+	} else if _, ok := value.(string); !ok {
+		return nil
+	}
+	return query.Eq(column.Name, value)
+}
+
+// ascii reports whether a text only has ASCII characters.
+func ascii(text string) bool {
+	for _, r := range text {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package scim
+
+import (
+	"testing"
+
+	"github.com/nikita-voronoy/go-clean-arch/pkg/database/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCondition(t *testing.T) {
+	columns := map[string]Column{
+		"externalid": {Name: "external_id"},
+		"username":   {Name: "username"},
+		"active": {Name: "deactivated", Value: func(value interface{}) (interface{}, bool) {
+			active, ok := value.(bool)
+			return !active, ok
+		}},
+	}
+
+	tests := []struct {
+		filter    string
+		condition query.Condition
+		rest      string
+	}{
+		{`userName eq "bjensen"`, query.Like("username", "bjensen"), ""},
+		{`userName co "b_j%"`, query.Like("username", `%b\_j\%%`), ""},
+		{`userName sw "bj"`, query.Like("username", "bj%"), ""},
+		{`userName ew "sen"`, query.Like("username", "%sen"), ""},
+		{`externalId eq "BJensen"`, query.Eq("external_id", "BJensen"), ""},
+		{`active eq false`, query.Eq("deactivated", true), ""},
+		{`userName sw "bj" and active eq true`, query.And(query.Like("username", "bj%"), query.Eq("deactivated", false)), ""},
+		{`userName eq "a" or userName eq "b"`, query.Or(query.Like("username", "a"), query.Like("username", "b")), ""},
+		{`userName sw "bj" and emails.value ew "@example.com"`, query.Like("username", "bj%"), `emails.value ew "@example.com"`},
+		{`userName eq "a" or emails pr`, nil, `userName eq "a" or emails pr`},
+		{`userName eq "bjørn"`, nil, `userName eq "bjørn"`},
+		{`externalId co "jen"`, nil, `externalId co "jen"`},
+		{`not (userName eq "bjensen")`, nil, `not (userName eq "bjensen")`},
+		{`nickName eq "babs"`, nil, `nickName eq "babs"`},
+		{`active eq "yes"`, nil, `active eq "yes"`},
+	}
+	for _, tt := range tests {
+		filter, err := ParseFilter(tt.filter)
+		require.NoError(t, err, tt.filter)
+		condition, rest := Condition(filter, columns)
+		assert.Equal(t, tt.condition, condition, tt.filter)
+		if tt.rest == "" {
+			assert.Nil(t, rest, tt.filter)
+			continue
+		}
+		expected, err := ParseFilter(tt.rest)
+		require.NoError(t, err, tt.rest)
+		assert.Equal(t, expected, rest, tt.filter)
+	}
+
+	condition, rest := Condition(nil, columns)
+	assert.Nil(t, condition)
+	assert.Nil(t, rest)
+}
@@ -0,0 +1,567 @@
+package scim
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Filter is a parsed filter expression, as defined in RFC 7644 section 3.4.2.2.
+type Filter interface {
+	// Match reports whether the resource matches the filter.
+	// resource: The JSON object of the resource, as returned by ToMap.
+	// Returns true if the resource matches the filter.
+	Match(resource map[string]interface{}) bool
+}
+
+// AttrPath struct represents the path of an attribute, such as "name.givenName".
+// URN: The URN of the schema of the attribute. It is empty if the path is not qualified.
+// Attr: The name of the attribute.
+// Sub: The name of the sub-attribute. It is empty if the path does not point to a sub-attribute.
+type AttrPath struct {
+	URN  string
+	Attr string
+	Sub  string
+}
+
+// caseExact is the set of attributes whose string values are compared case-sensitively.
+var caseExact = map[string]bool{
+	"id":         true,
+	"externalid": true,
+}
+
+// parseAttrPath parses the path of an attribute.
+// s: The path to parse.
+// Returns the path and an error if the path is not valid.
+func parseAttrPath(s string) (AttrPath, error) {
+	var path AttrPath
+	if i := strings.LastIndex(s, ":"); i >= 0 {
+		path.URN, s = s[:i], s[i+1:]
+	}
+	path.Attr = s
+	if i := strings.Index(s, "."); i >= 0 {
+		path.Attr, path.Sub = s[:i], s[i+1:]
+	}
+	if !validName(path.Attr) || (path.Sub != "" && !validName(path.Sub)) || strings.Contains(path.Sub, ".") {
+		return AttrPath{}, BadRequest(ErrInvalidPath, "invalid attribute path %q", s)
+	}
+	return path, nil
+}
+
+// validName reports whether s is a valid attribute name.
+func validName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if i == 0 && r == '$' {
+			continue
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the path as it is written in a filter.
+func (p AttrPath) String() string {
+	s := p.Attr
+	if p.Sub != "" {
+		s += "." + p.Sub
+	}
+	if p.URN != "" {
+		s = p.URN + ":" + s
+	}
+	return s
+}
+
+// container returns the object that holds the attribute of the path.
+// Attributes of the core schemas live at the top level, while the attributes of extensions live in an object named after the extension.
+// resource: The JSON object of the resource.
+// Returns the object that holds the attribute, or nil if there is none.
+func (p AttrPath) container(resource map[string]interface{}) map[string]interface{} {
+	if p.URN == "" || p.URN == UserSchema || p.URN == GroupSchema {
+		return resource
+	}
+	extension, _ := lookup(resource, p.URN).(map[string]interface{})
+	return extension
+}
+
+// items returns the values of the attribute of the path in the resource, flattening the multi-valued attributes.
+// resource: The JSON object of the resource.
+// Returns the values, without the null ones.
+func (p AttrPath) items(resource map[string]interface{}) []interface{} {
+	raw := lookup(p.container(resource), p.Attr)
+	if items, ok := raw.([]interface{}); ok {
+		return items
+	}
+	if raw != nil {
+		return []interface{}{raw}
+	}
+	return nil
+}
+
+// values returns the values a filter compares for the attribute of the path in the resource.
+// Without a sub-attribute, the values of a multi-valued complex attribute are compared by their "value" sub-attribute.
+// resource: The JSON object of the resource.
+// Returns the values, without the null ones.
+func (p AttrPath) values(resource map[string]interface{}) []interface{} {
+	_, multiValued := lookup(p.container(resource), p.Attr).([]interface{})
+	var values []interface{}
+	for _, v := range p.items(resource) {
+		if object, ok := v.(map[string]interface{}); ok && (p.Sub != "" || multiValued) {
+			sub := p.Sub
+			if sub == "" {
+				sub = "value"
+			}
+			v = lookup(object, sub)
+		} else if p.Sub != "" {
+			continue
+		}
+		if v != nil {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// lookup returns the value of the attribute of the object, matching the name case-insensitively.
+func lookup(object map[string]interface{}, name string) interface{} {
+	if object == nil {
+		return nil
+	}
+	if v, ok := object[name]; ok {
+		return v
+	}
+	for key, v := range object {
+		if strings.EqualFold(key, name) {
+			return v
+		}
+	}
+	return nil
+}
+
+// lookupKey returns the key of the attribute of the object, matching the name case-insensitively.
+// Returns the name itself if the object has no such attribute.
+func lookupKey(object map[string]interface{}, name string) string {
+	if _, ok := object[name]; ok {
+		return name
+	}
+	for key := range object {
+		if strings.EqualFold(key, name) {
+			return key
+		}
+	}
+	return name
+}
+
+// andFilter matches the resources that match both filters.
+type andFilter struct{ left, right Filter }
+
+func (f andFilter) Match(resource map[string]interface{}) bool {
+	return f.left.Match(resource) && f.right.Match(resource)
+}
+
+// orFilter matches the resources that match either filter.
+type orFilter struct{ left, right Filter }
+
+func (f orFilter) Match(resource map[string]interface{}) bool {
+	return f.left.Match(resource) || f.right.Match(resource)
+}
+
+// notFilter matches the resources that do not match the filter.
+type notFilter struct{ filter Filter }
+
+func (f notFilter) Match(resource map[string]interface{}) bool {
+	return !f.filter.Match(resource)
+}
+
+// presentFilter matches the resources that have a non-empty value for the attribute.
+type presentFilter struct{ path AttrPath }
+
+func (f presentFilter) Match(resource map[string]interface{}) bool {
+	for _, v := range f.path.values(resource) {
+		switch v := v.(type) {
+		case string:
+			if v != "" {
+				return true
+			}
+		case []interface{}:
+			if len(v) > 0 {
+				return true
+			}
+		case map[string]interface{}:
+			if len(v) > 0 {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// valuePathFilter matches the resources that have a value of the multi-valued attribute that matches the filter.
+type valuePathFilter struct {
+	path   AttrPath
+	filter Filter
+}
+
+func (f valuePathFilter) Match(resource map[string]interface{}) bool {
+	for _, item := range f.path.items(resource) {
+		if object, ok := item.(map[string]interface{}); ok && f.filter.Match(object) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareFilter matches the resources that have a value of the attribute that compares to the value of the filter.
+type compareFilter struct {
+	path  AttrPath
+	op    string
+	value interface{}
+}
+
+func (f compareFilter) Match(resource map[string]interface{}) bool {
+	values := f.path.values(resource)
+	if f.value == nil {
+		// Comparing to null tests for the absence of the attribute.
+		return (f.op == "eq") == (len(values) == 0)
+	}
+	if len(values) == 0 {
+		return f.op == "ne"
+	}
+	exact := caseExact[strings.ToLower(f.path.Attr)] && f.path.Sub == ""
+	for _, v := range values {
+		if compare(v, f.op, f.value, exact) {
+			return true
+		}
+	}
+	return false
+}
+
+// compare compares a value of a resource to the value of a filter.
+// v: The value of the resource.
+// op: The comparison operator.
+// target: The value of the filter.
+// exact: Whether strings are compared case-sensitively.
+// Returns the result of the comparison.
+func compare(v interface{}, op string, target interface{}, exact bool) bool {
+	switch v := v.(type) {
+	case string:
+		t, ok := target.(string)
+		if !ok {
+			return op == "ne"
+		}
+		if !exact {
+			v, t = strings.ToLower(v), strings.ToLower(t)
+		}
+		switch op {
+		case "eq":
+			return v == t
+		case "ne":
+			return v != t
+		case "co":
+			return strings.Contains(v, t)
+		case "sw":
+			return strings.HasPrefix(v, t)
+		case "ew":
+			return strings.HasSuffix(v, t)
+		}
+		// Dates are ordered by time, and other strings lexicographically.
+		order := strings.Compare(v, t)
+		if vt, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			if tt, err := time.Parse(time.RFC3339Nano, t); err == nil {
+				order = vt.Compare(tt)
+			}
+		}
+		return ordered(order, op)
+	case float64:
+		t, ok := target.(float64)
+		if !ok {
+			return op == "ne"
+		}
+		switch {
+		case v < t:
+			return ordered(-1, op)
+		case v > t:
+			return ordered(1, op)
+		default:
+			return ordered(0, op)
+		}
+	case bool:
+		t, ok := target.(bool)
+		if !ok {
+			return op == "ne"
+		}
+		switch op {
+		case "eq":
+			return v == t
+		case "ne":
+			return v != t
+		}
+	}
+	return false
+}
+
+// ordered reports whether the result of a comparison satisfies the operator.
+func ordered(order int, op string) bool {
+	switch op {
+	case "eq":
+		return order == 0
+	case "ne":
+		return order != 0
+	case "gt":
+		return order > 0
+	case "ge":
+		return order >= 0
+	case "lt":
+		return order < 0
+	case "le":
+		return order <= 0
+	}
+	return false
+}
+
+// operators is the set of the comparison operators, besides "pr".
+var operators = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true,
+	"gt": true, "ge": true, "lt": true, "le": true,
+}
+
+// ParseFilter parses a filter expression, such as `userName eq "bjensen" and emails[type eq "work"]`.
+// s: The filter expression to parse.
+// Returns the filter and an error with the invalidFilter keyword if the expression is not valid.
+func ParseFilter(s string) (Filter, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, BadRequest(ErrInvalidFilter, "unexpected %q in filter", p.peek().text)
+	}
+	return f, nil
+}
+
+// The kinds of the tokens of a filter.
+const (
+	tokenEOF = iota
+	tokenWord
+	tokenString
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+)
+
+// token struct represents a token of a filter.
+type token struct {
+	kind  int
+	text  string
+	value string
+}
+
+// tokenize splits a filter into its tokens.
+// s: The filter to split.
+// Returns the tokens and an error if the filter has an unterminated string.
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")"})
+			i++
+		case c == '[':
+			tokens = append(tokens, token{kind: tokenLBracket, text: "["})
+			i++
+		case c == ']':
+			tokens = append(tokens, token{kind: tokenRBracket, text: "]"})
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return nil, BadRequest(ErrInvalidFilter, "unterminated string in filter")
+			}
+			var value string
+			if err := json.Unmarshal([]byte(s[i:j+1]), &value); err != nil {
+				return nil, BadRequest(ErrInvalidFilter, "invalid string %s in filter", s[i:j+1])
+			}
+			tokens = append(tokens, token{kind: tokenString, text: s[i : j+1], value: value})
+			i = j + 1
+		default:
+			j := i
+			for ; j < len(s) && !strings.ContainsRune(" \t\n\r()[]\"", rune(s[j])); j++ {
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: s[i:j], value: s[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// parser struct represents a recursive descent parser over the tokens of a filter.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// peek returns the next token without consuming it.
+func (p *parser) peek() token {
+	if p.pos >= len(p.tokens) {
+		return token{kind: tokenEOF, text: "end of filter"}
+	}
+	return p.tokens[p.pos]
+}
+
+// next consumes and returns the next token.
+func (p *parser) next() token {
+	t := p.peek()
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// keyword reports whether the next token is the given keyword, and consumes it if it is.
+func (p *parser) keyword(word string) bool {
+	if t := p.peek(); t.kind == tokenWord && strings.EqualFold(t.value, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expect consumes the next token and fails if it is not of the given kind.
+func (p *parser) expect(kind int, text string) error {
+	if t := p.next(); t.kind != kind {
+		return BadRequest(ErrInvalidFilter, "expected %q but found %q in filter", text, t.text)
+	}
+	return nil
+}
+
+// parseOr parses a disjunction, which binds weaker than a conjunction.
+func (p *parser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orFilter{left, right}
+	}
+	return left, nil
+}
+
+// parseAnd parses a conjunction.
+func (p *parser) parseAnd() (Filter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andFilter{left, right}
+	}
+	return left, nil
+}
+
+// parseUnary parses a negation, a group in parentheses or an attribute expression.
+func (p *parser) parseUnary() (Filter, error) {
+	negate := p.keyword("not")
+	if negate && p.peek().kind != tokenLParen {
+		return nil, BadRequest(ErrInvalidFilter, "expected \"(\" after \"not\" in filter")
+	}
+	if p.peek().kind == tokenLParen {
+		p.next()
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenRParen, ")"); err != nil {
+			return nil, err
+		}
+		if negate {
+			return notFilter{f}, nil
+		}
+		return f, nil
+	}
+	return p.parseAttrExp()
+}
+
+// parseAttrExp parses a comparison, a presence test or a value path.
+func (p *parser) parseAttrExp() (Filter, error) {
+	t := p.next()
+	if t.kind != tokenWord {
+		return nil, BadRequest(ErrInvalidFilter, "expected an attribute but found %q in filter", t.text)
+	}
+	path, err := parseAttrPath(t.value)
+	if err != nil {
+		return nil, BadRequest(ErrInvalidFilter, "invalid attribute %q in filter", t.value)
+	}
+
+	if p.peek().kind == tokenLBracket {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenRBracket, "]"); err != nil {
+			return nil, err
+		}
+		return valuePathFilter{path: path, filter: inner}, nil
+	}
+
+	op := p.next()
+	if op.kind != tokenWord {
+		return nil, BadRequest(ErrInvalidFilter, "expected an operator but found %q in filter", op.text)
+	}
+	operator := strings.ToLower(op.value)
+	if operator == "pr" {
+		return presentFilter{path: path}, nil
+	}
+	if !operators[operator] {
+		return nil, BadRequest(ErrInvalidFilter, "unknown operator %q in filter", op.value)
+	}
+
+	v := p.next()
+	var value interface{}
+	switch v.kind {
+	case tokenString:
+		value = v.value
+	case tokenWord:
+		if err := json.Unmarshal([]byte(strings.ToLower(v.value)), &value); err != nil {
+			return nil, BadRequest(ErrInvalidFilter, "invalid value %q in filter", v.value)
+		}
+		if _, ok := value.(string); ok {
+			return nil, BadRequest(ErrInvalidFilter, "invalid value %q in filter", v.value)
+		}
+	default:
+		return nil, BadRequest(ErrInvalidFilter, "expected a value but found %q in filter", v.text)
+	}
+	return compareFilter{path: path, op: operator, value: value}, nil
+}
@@ -0,0 +1,114 @@
+package scim
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testUser(t *testing.T) map[string]interface{} {
+	m, err := ToMap(User{
+		Schemas:    []string{UserSchema},
+		ID:         "2819c223-7f76-453a-919d-413861904646",
+		ExternalID: "bjensen",
+		UserName:   "bjensen",
+		Emails: []MultiValue{
+			{Value: "bjensen@example.com", Type: "work", Primary: true},
+			{Value: "babs@jensen.org", Type: "home"},
+		},
+		Active: Bool(true),
+		Groups: []Reference{{Value: "e9e30dba-f08f-4109-8486-d5c6a331660a", Display: "Tour Guides"}},
+	})
+	require.NoError(t, err)
+	m["meta"] = map[string]interface{}{"lastModified": "2011-05-13T04:42:34Z"}
+	return m
+}
+
+func TestFilterMatch(t *testing.T) {
+	user := testUser(t)
+
+	tests := []struct {
+		filter string
+		match  bool
+	}{
+		{`userName eq "bjensen"`, true},
+		{`USERNAME eq "BJensen"`, true},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName sw "bje"`, true},
+		{`externalId eq "BJENSEN"`, false},
+		{`id eq "2819c223-7f76-453a-919d-413861904646"`, true},
+		{`userName ne "bjensen"`, false},
+		{`userName co "jens"`, true},
+		{`userName ew "sen"`, true},
+		{`emails co "example.com"`, true},
+		{`emails.value ew "jensen.org"`, true},
+		{`emails[type eq "work" and value co "@example.com"]`, true},
+		{`emails[type eq "home" and value co "@example.com"]`, false},
+		{`emails[type eq "work"] and active eq true`, true},
+		{`title pr`, false},
+		{`emails pr and not (userName eq "other")`, true},
+		{`active eq false or groups.display eq "Tour Guides"`, true},
+		{`userName eq "x" or userName eq "y" and active eq true`, false},
+		{`(userName eq "x" or userName eq "bjensen") and active eq true`, true},
+		{`meta.lastModified gt "2011-05-13T04:42:34Z"`, false},
+		{`meta.lastModified ge "2011-05-13T04:42:34Z"`, true},
+		{`meta.lastModified lt "2011-05-13T06:00:00+02:00"`, true},
+		{`title eq null`, true},
+		{`title ne "Tour Guide"`, true},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.filter)
+		require.NoError(t, err, tt.filter)
+		assert.Equal(t, tt.match, f.Match(user), tt.filter)
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, filter := range []string{
+		``,
+		`userName`,
+		`userName eq`,
+		`userName is "bjensen"`,
+		`userName eq bjensen`,
+		`userName eq "bjensen`,
+		`not userName eq "bjensen"`,
+		`(userName eq "bjensen"`,
+		`emails[type eq "work"`,
+		`userName eq "bjensen" and`,
+		`userName eq "bjensen" "extra"`,
+	} {
+		_, err := ParseFilter(filter)
+		var scimErr *Error
+		require.True(t, errors.As(err, &scimErr), filter)
+		assert.Equal(t, ErrInvalidFilter, scimErr.ScimType, filter)
+		assert.Equal(t, 400, scimErr.Code(), filter)
+	}
+}
+
+func TestQueryPage(t *testing.T) {
+	resources := make([]map[string]interface{}, 0, 5)
+	for _, name := range []string{"alice", "bob", "carol", "dave", "erin"} {
+		resources = append(resources, map[string]interface{}{"userName": name})
+	}
+
+	query, err := ParseQuery(`userName ne "bob"`, "2", "2")
+	require.NoError(t, err)
+	page, total := query.Page(resources)
+	assert.Equal(t, 4, total)
+	assert.Equal(t, []int{2, 3}, page)
+
+	query, err = ParseQuery("", "10", "")
+	require.NoError(t, err)
+	page, total = query.Page(resources)
+	assert.Equal(t, 5, total)
+	assert.Empty(t, page)
+
+	query, err = ParseQuery("", "0", "1000")
+	require.NoError(t, err)
+	assert.Equal(t, 1, query.StartIndex)
+	assert.Equal(t, MaxResults, query.Count)
+
+	_, err = ParseQuery("", "first", "")
+	assert.Error(t, err)
+}
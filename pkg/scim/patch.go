@@ -0,0 +1,354 @@
+package scim
+
+import (
+	"reflect"
+	"strings"
+)
+
+// PatchRequest struct represents the body of a PATCH request, as defined in RFC 7644 section 3.5.2.
+// Schemas: The schemas of the request, which must contain the PatchOp message schema.
+// Operations: The operations to apply, in order.
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation struct represents a single operation of a PATCH request.
+// Op: The operation, which is "add", "replace" or "remove". It is matched case-insensitively.
+// Path: The attribute the operation targets. It is empty if the value holds the attributes to modify.
+// Value: The value to add or replace with, as decoded from JSON.
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// immutable is the set of attributes that cannot be modified by a PATCH request.
+var immutable = map[string]bool{
+	"id":      true,
+	"meta":    true,
+	"schemas": true,
+}
+
+// Apply applies the operations of the request to a resource.
+// The operations are applied to the generic JSON representation of the resource, which the caller converts back with FromMap.
+// resource: The JSON object of the resource, as returned by ToMap. It is modified in place.
+// Returns an error with the SCIM keyword that describes why an operation cannot be applied.
+func (r PatchRequest) Apply(resource map[string]interface{}) error {
+	if !contains(r.Schemas, PatchOpSchema) {
+		return BadRequest(ErrInvalidSyntax, "the request must use the %s schema", PatchOpSchema)
+	}
+	if len(r.Operations) == 0 {
+		return BadRequest(ErrInvalidSyntax, "the request has no operations")
+	}
+	for _, operation := range r.Operations {
+		if err := operation.apply(resource); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// contains reports whether the URNs contain the given URN, ignoring case.
+func contains(urns []string, urn string) bool {
+	for _, u := range urns {
+		if strings.EqualFold(u, urn) {
+			return true
+		}
+	}
+	return false
+}
+
+// patchPath struct represents the target of an operation, such as `emails[type eq "work"].value`.
+// The sub-attribute of the embedded path applies to the values selected by the filter if there is one.
+type patchPath struct {
+	AttrPath
+	filter Filter
+}
+
+// parsePatchPath parses the target of an operation.
+// s: The path to parse.
+// Returns the path and an error with the invalidPath keyword if the path is not valid.
+func parsePatchPath(s string) (patchPath, error) {
+	open := strings.Index(s, "[")
+	if open < 0 {
+		path, err := parseAttrPath(s)
+		return patchPath{AttrPath: path}, err
+	}
+
+	closing := strings.LastIndex(s, "]")
+	if closing < open {
+		return patchPath{}, BadRequest(ErrInvalidPath, "invalid path %q", s)
+	}
+	path, err := parseAttrPath(s[:open])
+	if err != nil || path.Sub != "" {
+		return patchPath{}, BadRequest(ErrInvalidPath, "invalid path %q", s)
+	}
+	filter, err := ParseFilter(s[open+1 : closing])
+	if err != nil {
+		return patchPath{}, BadRequest(ErrInvalidPath, "invalid filter in path %q", s)
+	}
+	if rest := s[closing+1:]; rest != "" {
+		if !strings.HasPrefix(rest, ".") || !validName(rest[1:]) {
+			return patchPath{}, BadRequest(ErrInvalidPath, "invalid path %q", s)
+		}
+		path.Sub = rest[1:]
+	}
+	return patchPath{AttrPath: path, filter: filter}, nil
+}
+
+// apply applies the operation to a resource.
+// resource: The JSON object of the resource.
+// Returns an error if the operation cannot be applied.
+func (o PatchOperation) apply(resource map[string]interface{}) error {
+	op := strings.ToLower(o.Op)
+	if op != "add" && op != "replace" && op != "remove" {
+		return BadRequest(ErrInvalidSyntax, "unknown operation %q", o.Op)
+	}
+
+	if o.Path != "" {
+		path, err := parsePatchPath(o.Path)
+		if err != nil {
+			return err
+		}
+		return path.apply(resource, op, o.Value)
+	}
+
+	// Without a path, the value holds the attributes to add or replace.
+	if op == "remove" {
+		return BadRequest(ErrNoTarget, "a remove operation requires a path")
+	}
+	values, ok := o.Value.(map[string]interface{})
+	if !ok {
+		return BadRequest(ErrInvalidValue, "the value of an operation without a path must be an object")
+	}
+	for key, value := range values {
+		if extension, ok := value.(map[string]interface{}); ok && strings.HasPrefix(strings.ToLower(key), "urn:") {
+			for attr, v := range extension {
+				if err := (patchPath{AttrPath: AttrPath{URN: key, Attr: attr}}).apply(resource, op, v); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		path, err := parseAttrPath(key)
+		if err != nil {
+			return err
+		}
+		if err := (patchPath{AttrPath: path}).apply(resource, op, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply applies an operation to the target of the path.
+// resource: The JSON object of the resource.
+// op: The lowercase operation.
+// value: The value of the operation.
+// Returns an error if the operation cannot be applied.
+func (p patchPath) apply(resource map[string]interface{}, op string, value interface{}) error {
+	if immutable[strings.ToLower(p.Attr)] && p.URN == "" {
+		return BadRequest(ErrMutability, "the %s attribute cannot be modified", p.Attr)
+	}
+	if op != "remove" && value == nil {
+		return BadRequest(ErrInvalidValue, "the %s operation requires a value", op)
+	}
+
+	container := p.container(resource)
+	if container == nil {
+		if op == "remove" {
+			return nil
+		}
+		container = map[string]interface{}{}
+		resource[p.URN] = container
+	}
+	key := lookupKey(container, p.Attr)
+
+	if p.filter != nil {
+		return p.applyFiltered(container, key, op, value)
+	}
+
+	if p.Sub != "" {
+		// The sub-attribute of a complex attribute, or of every value of a multi-valued attribute.
+		switch existing := container[key].(type) {
+		case []interface{}:
+			for _, item := range existing {
+				if object, ok := item.(map[string]interface{}); ok {
+					setSub(object, p.Sub, op, value)
+				}
+			}
+		case map[string]interface{}:
+			setSub(existing, p.Sub, op, value)
+		default:
+			if op != "remove" {
+				container[key] = map[string]interface{}{p.Sub: value}
+			}
+		}
+		return nil
+	}
+
+	existing, multiValued := container[key].([]interface{})
+	switch op {
+	case "add":
+		if multiValued {
+			container[key] = appendValues(existing, value)
+			return nil
+		}
+		container[key] = value
+	case "replace":
+		container[key] = value
+	case "remove":
+		if values, ok := value.([]interface{}); ok && multiValued {
+			// Some identity providers name the values to remove in the value instead of a filter.
+			container[key] = removeValues(existing, values)
+			return nil
+		}
+		delete(container, key)
+	}
+	return nil
+}
+
+// applyFiltered applies an operation to the values of a multi-valued attribute that match the filter of the path.
+// container: The object that holds the attribute.
+// key: The key of the attribute in the container.
+// op: The lowercase operation.
+// value: The value of the operation.
+// Returns an error with the noTarget keyword if no value matches the filter and none can be added.
+func (p patchPath) applyFiltered(container map[string]interface{}, key, op string, value interface{}) error {
+	items, _ := container[key].([]interface{})
+	kept := make([]interface{}, 0, len(items))
+	matched := false
+	for _, item := range items {
+		object, ok := item.(map[string]interface{})
+		if !ok || !p.filter.Match(object) {
+			kept = append(kept, item)
+			continue
+		}
+		matched = true
+		switch {
+		case op == "remove" && p.Sub == "":
+			continue
+		case p.Sub != "":
+			setSub(object, p.Sub, op, value)
+		default:
+			if values, ok := value.(map[string]interface{}); ok {
+				for k, v := range values {
+					object[lookupKey(object, k)] = v
+				}
+			} else {
+				return BadRequest(ErrInvalidValue, "the value for %s must be an object", p.AttrPath)
+			}
+		}
+		kept = append(kept, object)
+	}
+
+	if !matched && op != "remove" {
+		// A value that does not exist yet is added, if the filter describes it.
+		object, ok := seed(p.filter)
+		if !ok {
+			return BadRequest(ErrNoTarget, "no value of %s matches the filter", p.AttrPath)
+		}
+		if p.Sub != "" {
+			object[p.Sub] = value
+		} else if values, ok := value.(map[string]interface{}); ok {
+			for k, v := range values {
+				object[k] = v
+			}
+		}
+		kept = append(kept, object)
+	}
+	container[key] = kept
+	return nil
+}
+
+// setSub applies an operation to the sub-attribute of an object.
+func setSub(object map[string]interface{}, sub, op string, value interface{}) {
+	key := lookupKey(object, sub)
+	if op == "remove" {
+		delete(object, key)
+		return
+	}
+	object[key] = value
+}
+
+// seed builds the value described by a filter made of equality comparisons, such as `type eq "work"`.
+// f: The filter to build the value from.
+// Returns the value and false if the filter does not describe a single value.
+func seed(f Filter) (map[string]interface{}, bool) {
+	switch f := f.(type) {
+	case compareFilter:
+		if f.op != "eq" || f.path.Sub != "" || f.value == nil {
+			return nil, false
+		}
+		return map[string]interface{}{f.path.Attr: f.value}, true
+	case andFilter:
+		left, ok := seed(f.left)
+		if !ok {
+			return nil, false
+		}
+		right, ok := seed(f.right)
+		if !ok {
+			return nil, false
+		}
+		for k, v := range right {
+			left[k] = v
+		}
+		return left, true
+	}
+	return nil, false
+}
+
+// appendValues adds values to a multi-valued attribute, skipping the ones it already has.
+// existing: The values of the attribute.
+// value: The value or the values to add.
+// Returns the values of the attribute.
+func appendValues(existing []interface{}, value interface{}) []interface{} {
+	values, ok := value.([]interface{})
+	if !ok {
+		values = []interface{}{value}
+	}
+	for _, v := range values {
+		if indexOf(existing, v) < 0 {
+			existing = append(existing, v)
+		}
+	}
+	return existing
+}
+
+// removeValues removes values from a multi-valued attribute.
+// existing: The values of the attribute.
+// values: The values to remove.
+// Returns the values of the attribute.
+func removeValues(existing []interface{}, values []interface{}) []interface{} {
+	kept := make([]interface{}, 0, len(existing))
+	for _, item := range existing {
+		if indexOf(values, item) < 0 {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// indexOf returns the index of a value among the values of a multi-valued attribute, or -1.
+// Complex values are identified by their "value" sub-attribute if both have one.
+func indexOf(values []interface{}, v interface{}) int {
+	id := lookupValue(v)
+	for i, item := range values {
+		if id != nil && reflect.DeepEqual(lookupValue(item), id) {
+			return i
+		}
+		if reflect.DeepEqual(item, v) {
+			return i
+		}
+	}
+	return -1
+}
+
+// lookupValue returns the "value" sub-attribute of a complex value, or nil.
+func lookupValue(v interface{}) interface{} {
+	if object, ok := v.(map[string]interface{}); ok {
+		return lookup(object, "value")
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func patch(t *testing.T, resource map[string]interface{}, body string) error {
+	var request PatchRequest
+	require.NoError(t, json.Unmarshal([]byte(body), &request))
+	return request.Apply(resource)
+}
+
+func TestPatchUser(t *testing.T) {
+	resource := testUser(t)
+
+	err := patch(t, resource, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "value": {"active": "False", "userName": "babs"}},
+			{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "babs@example.com"},
+			{"op": "add", "path": "emails[type eq \"other\"].value", "value": "b@example.net"},
+			{"op": "remove", "path": "emails[type eq \"home\"]"},
+			{"op": "add", "path": "externalId", "value": "babs"}
+		]
+	}`)
+	require.NoError(t, err)
+
+	var user User
+	require.NoError(t, FromMap(resource, &user))
+	assert.Equal(t, "babs", user.UserName)
+	assert.Equal(t, "babs", user.ExternalID)
+	require.NotNil(t, user.Active)
+	assert.False(t, bool(*user.Active))
+	assert.Equal(t, []MultiValue{
+		{Value: "babs@example.com", Type: "work", Primary: true},
+		{Value: "b@example.net", Type: "other"},
+	}, user.Emails)
+	assert.Equal(t, "babs@example.com", user.PrimaryEmail())
+}
+
+func TestPatchGroupMembers(t *testing.T) {
+	resource, err := ToMap(Group{
+		Schemas:     []string{GroupSchema},
+		DisplayName: "Tour Guides",
+		Members:     []Reference{{Value: "a"}, {Value: "b"}},
+	})
+	require.NoError(t, err)
+
+	err = patch(t, resource, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "add", "path": "members", "value": [{"value": "b"}, {"value": "c"}, {"value": "d"}]},
+			{"op": "remove", "path": "members[value eq \"a\"]"},
+			{"op": "Remove", "path": "members", "value": [{"value": "d"}]},
+			{"op": "replace", "path": "displayName", "value": "Guides"}
+		]
+	}`)
+	require.NoError(t, err)
+
+	var group Group
+	require.NoError(t, FromMap(resource, &group))
+	assert.Equal(t, "Guides", group.DisplayName)
+	assert.Equal(t, []Reference{{Value: "b"}, {Value: "c"}}, group.Members)
+
+	err = patch(t, resource, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "remove", "path": "members"}]
+	}`)
+	require.NoError(t, err)
+	group = Group{}
+	require.NoError(t, FromMap(resource, &group))
+	assert.Empty(t, group.Members)
+}
+
+func TestPatchErrors(t *testing.T) {
+	tests := []struct {
+		body     string
+		scimType string
+	}{
+		{`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": []}`, ErrInvalidSyntax},
+		{`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:Error"], "Operations": [{"op": "remove", "path": "title"}]}`, ErrInvalidSyntax},
+		{`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "move", "path": "title"}]}`, ErrInvalidSyntax},
+		{`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "remove"}]}`, ErrNoTarget},
+		{`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "id", "value": "x"}]}`, ErrMutability},
+		{`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "emails[type eq \"work\"", "value": "x"}]}`, ErrInvalidPath},
+		{`{"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"], "Operations": [{"op": "replace", "path": "emails[value co \"zzz\"].type", "value": "x"}]}`, ErrNoTarget},
+	}
+	for _, tt := range tests {
+		err := patch(t, testUser(t), tt.body)
+		var scimErr *Error
+		require.True(t, errors.As(err, &scimErr), tt.body)
+		assert.Equal(t, tt.scimType, scimErr.ScimType, tt.body)
+	}
+}
@@ -0,0 +1,111 @@
+package scim
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Boolean is a boolean attribute that also accepts the "True" and "False" strings some identity providers send.
+type Boolean bool
+
+// UnmarshalJSON decodes a JSON boolean or a string holding a boolean.
+func (b *Boolean) UnmarshalJSON(data []byte) error {
+	var value bool
+	if err := json.Unmarshal(data, &value); err == nil {
+		*b = Boolean(value)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	value, err := strconv.ParseBool(strings.ToLower(text))
+	if err != nil {
+		return err
+	}
+	*b = Boolean(value)
+	return nil
+}
+
+// Bool returns a pointer to the given boolean, for the optional boolean attributes.
+func Bool(value bool) *Boolean {
+	b := Boolean(value)
+	return &b
+}
+
+// MultiValue struct represents a value of a multi-valued attribute, such as an email of a user.
+// Value: The value.
+// Display: The human-readable name of the value.
+// Type: The label of the value, such as "work".
+// Primary: Whether the value is the preferred one among the values of the attribute.
+type MultiValue struct {
+	Value   string  `json:"value"`
+	Display string  `json:"display,omitempty"`
+	Type    string  `json:"type,omitempty"`
+	Primary Boolean `json:"primary,omitempty"`
+}
+
+// Reference struct represents a reference to another resource, such as a member of a group.
+// Value: The ID of the referenced resource.
+// Ref: The URI of the referenced resource.
+// Display: The human-readable name of the referenced resource.
+// Type: The type of the referenced resource, such as "User".
+type Reference struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+}
+
+// User struct represents the core user resource.
+// Schemas: The schemas of the resource.
+// ID: The ID of the user, assigned by the service provider.
+// ExternalID: The ID of the user in the identity provider.
+// UserName: The unique name the user signs in with.
+// Emails: The email addresses of the user.
+// Active: Whether the user can sign in. It is nil if the client did not send it.
+// Password: The password of the user. It is write-only and never returned.
+// Groups: The groups the user is a member of. It is read-only.
+// Meta: The metadata of the resource.
+type User struct {
+	Schemas    []string     `json:"schemas"`
+	ID         string       `json:"id,omitempty"`
+	ExternalID string       `json:"externalId,omitempty"`
+	UserName   string       `json:"userName"`
+	Emails     []MultiValue `json:"emails,omitempty"`
+	Active     *Boolean     `json:"active,omitempty"`
+	Password   string       `json:"password,omitempty"`
+	Groups     []Reference  `json:"groups,omitempty"`
+	Meta       *Meta        `json:"meta,omitempty"`
+}
+
+// PrimaryEmail returns the primary email of the user, or the first one if none is marked as primary.
+// Returns an empty string if the user has no email.
+func (u User) PrimaryEmail() string {
+	for _, email := range u.Emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+	return ""
+}
+
+// Group struct represents the core group resource.
+// Schemas: The schemas of the resource.
+// ID: The ID of the group, assigned by the service provider.
+// ExternalID: The ID of the group in the identity provider.
+// DisplayName: The human-readable name of the group.
+// Members: The members of the group.
+// Meta: The metadata of the resource.
+type Group struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	ExternalID  string      `json:"externalId,omitempty"`
+	DisplayName string      `json:"displayName"`
+	Members     []Reference `json:"members,omitempty"`
+	Meta        *Meta       `json:"meta,omitempty"`
+}
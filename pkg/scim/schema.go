@@ -0,0 +1,218 @@
+package scim
+
+// DocumentMeta struct represents the metadata of a discovery document, which has no creation or modification time.
+// ResourceType: The name of the type of the document.
+// Location: The URI of the document.
+type DocumentMeta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location,omitempty"`
+}
+
+// Supported struct represents a feature of the service provider that is either supported or not.
+type Supported struct {
+	Supported bool `json:"supported"`
+}
+
+// BulkSupport struct represents the support of the bulk operations.
+type BulkSupport struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+// FilterSupport struct represents the support of the filters.
+type FilterSupport struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+// AuthenticationScheme struct represents a way to authenticate against the service provider.
+type AuthenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Primary     bool   `json:"primary"`
+}
+
+// ServiceProviderConfig struct represents the features the service provider supports, as defined in RFC 7643 section 5.
+type ServiceProviderConfig struct {
+	Schemas               []string               `json:"schemas"`
+	Patch                 Supported              `json:"patch"`
+	Bulk                  BulkSupport            `json:"bulk"`
+	Filter                FilterSupport          `json:"filter"`
+	ChangePassword        Supported              `json:"changePassword"`
+	Sort                  Supported              `json:"sort"`
+	ETag                  Supported              `json:"etag"`
+	AuthenticationSchemes []AuthenticationScheme `json:"authenticationSchemes"`
+	Meta                  DocumentMeta           `json:"meta"`
+}
+
+// NewServiceProviderConfig creates the configuration of a service provider that supports the features of this package.
+// baseURL: The URL the SCIM endpoints are served under, such as "https://example.com/scim/v2".
+// Returns the service provider configuration.
+func NewServiceProviderConfig(baseURL string) ServiceProviderConfig {
+	return ServiceProviderConfig{
+		Schemas:        []string{ServiceProviderConfigSchema},
+		Patch:          Supported{Supported: true},
+		Bulk:           BulkSupport{Supported: false},
+		Filter:         FilterSupport{Supported: true, MaxResults: MaxResults},
+		ChangePassword: Supported{Supported: true},
+		Sort:           Supported{Supported: false},
+		ETag:           Supported{Supported: true},
+		AuthenticationSchemes: []AuthenticationScheme{{
+			Type:        "oauthbearertoken",
+			Name:        "OAuth Bearer Token",
+			Description: "Authentication with the bearer token configured for the SCIM endpoints.",
+			Primary:     true,
+		}},
+		Meta: DocumentMeta{ResourceType: "ServiceProviderConfig", Location: baseURL + "/ServiceProviderConfig"},
+	}
+}
+
+// ResourceType struct represents a type of resource the service provider serves, as defined in RFC 7643 section 6.
+type ResourceType struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Endpoint    string       `json:"endpoint"`
+	Description string       `json:"description"`
+	Schema      string       `json:"schema"`
+	Meta        DocumentMeta `json:"meta"`
+}
+
+// ResourceTypes returns the user and group resource types.
+// baseURL: The URL the SCIM endpoints are served under.
+// Returns the resource types.
+func ResourceTypes(baseURL string) []ResourceType {
+	return []ResourceType{
+		{
+			Schemas:     []string{ResourceTypeSchema},
+			ID:          "User",
+			Name:        "User",
+			Endpoint:    "/Users",
+			Description: "User Account",
+			Schema:      UserSchema,
+			Meta:        DocumentMeta{ResourceType: "ResourceType", Location: baseURL + "/ResourceTypes/User"},
+		},
+		{
+			Schemas:     []string{ResourceTypeSchema},
+			ID:          "Group",
+			Name:        "Group",
+			Endpoint:    "/Groups",
+			Description: "Group",
+			Schema:      GroupSchema,
+			Meta:        DocumentMeta{ResourceType: "ResourceType", Location: baseURL + "/ResourceTypes/Group"},
+		},
+	}
+}
+
+// Attribute struct represents the definition of an attribute of a schema, as defined in RFC 7643 section 7.
+type Attribute struct {
+	Name           string      `json:"name"`
+	Type           string      `json:"type"`
+	MultiValued    bool        `json:"multiValued"`
+	Description    string      `json:"description"`
+	Required       bool        `json:"required"`
+	CaseExact      bool        `json:"caseExact"`
+	Mutability     string      `json:"mutability"`
+	Returned       string      `json:"returned"`
+	Uniqueness     string      `json:"uniqueness"`
+	ReferenceTypes []string    `json:"referenceTypes,omitempty"`
+	SubAttributes  []Attribute `json:"subAttributes,omitempty"`
+}
+
+// Schema struct represents the definition of a schema, as defined in RFC 7643 section 7.
+type Schema struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Attributes  []Attribute  `json:"attributes"`
+	Meta        DocumentMeta `json:"meta"`
+}
+
+// attribute creates the definition of a single-valued attribute that is neither required, case-exact nor unique.
+func attribute(name, kind, description string) Attribute {
+	return Attribute{
+		Name:        name,
+		Type:        kind,
+		Description: description,
+		Mutability:  "readWrite",
+		Returned:    "default",
+		Uniqueness:  "none",
+	}
+}
+
+// reference creates the definition of a multi-valued attribute that references other resources.
+func reference(name, description, mutability string, referenceTypes ...string) Attribute {
+	value := attribute("value", "string", "The identifier of the referenced resource.")
+	value.Mutability = mutability
+	ref := attribute("$ref", "reference", "The URI of the referenced resource.")
+	ref.Mutability = mutability
+	ref.ReferenceTypes = referenceTypes
+	display := attribute("display", "string", "A human-readable name of the referenced resource.")
+	display.Mutability = "readOnly"
+
+	a := attribute(name, "complex", description)
+	a.MultiValued = true
+	a.Mutability = mutability
+	a.SubAttributes = []Attribute{value, ref, display}
+	return a
+}
+
+// Schemas returns the definitions of the user and group schemas, limited to the attributes the service provider stores.
+// baseURL: The URL the SCIM endpoints are served under.
+// Returns the schemas.
+func Schemas(baseURL string) []Schema {
+	userName := attribute("userName", "string", "Unique identifier for the User, used to sign in.")
+	userName.Required = true
+	userName.Uniqueness = "server"
+
+	emailValue := attribute("value", "string", "Email address of the User.")
+	emailType := attribute("type", "string", "A label indicating the email's function, e.g. 'work' or 'home'.")
+	emailPrimary := attribute("primary", "boolean", "Whether the email is the primary one of the User.")
+	emails := attribute("emails", "complex", "Email addresses of the User. The primary email must be unique.")
+	emails.MultiValued = true
+	emails.Required = true
+	emails.SubAttributes = []Attribute{emailValue, emailType, emailPrimary}
+
+	password := attribute("password", "string", "The password of the User.")
+	password.Mutability = "writeOnly"
+	password.Returned = "never"
+
+	externalID := attribute("externalId", "string", "Identifier of the resource in the provisioning client.")
+	externalID.CaseExact = true
+
+	displayName := attribute("displayName", "string", "A human-readable name for the Group.")
+	displayName.Required = true
+
+	return []Schema{
+		{
+			Schemas:     []string{SchemaSchema},
+			ID:          UserSchema,
+			Name:        "User",
+			Description: "User Account",
+			Attributes: []Attribute{
+				userName,
+				externalID,
+				emails,
+				attribute("active", "boolean", "Whether the User can sign in."),
+				password,
+				reference("groups", "The Groups the User is a member of.", "readOnly", "Group"),
+			},
+			Meta: DocumentMeta{ResourceType: "Schema", Location: baseURL + "/Schemas/" + UserSchema},
+		},
+		{
+			Schemas:     []string{SchemaSchema},
+			ID:          GroupSchema,
+			Name:        "Group",
+			Description: "Group",
+			Attributes: []Attribute{
+				displayName,
+				externalID,
+				reference("members", "The members of the Group.", "readWrite", "User"),
+			},
+			Meta: DocumentMeta{ResourceType: "Schema", Location: baseURL + "/Schemas/" + GroupSchema},
+		},
+	}
+}
@@ -0,0 +1,287 @@
+// Package scim provides the functionality to speak the SCIM 2.0 protocol (RFC 7643 and RFC 7644).
+// It contains the core resources, the filter expressions, the PATCH operations and the discovery documents,
+// and leaves the mapping of the resources onto the storage to the caller.
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The URNs of the schemas and messages of the protocol.
+const (
+	UserSchema                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	GroupSchema                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	ListResponseSchema          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	PatchOpSchema               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ErrorSchema                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	ServiceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	ResourceTypeSchema          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+)
+
+// MediaType is the content type of the SCIM requests and responses.
+const MediaType = "application/scim+json"
+
+// The scimType values of the errors, as defined in RFC 7644 section 3.12.
+const (
+	ErrInvalidFilter = "invalidFilter"
+	ErrInvalidSyntax = "invalidSyntax"
+	ErrInvalidPath   = "invalidPath"
+	ErrInvalidValue  = "invalidValue"
+	ErrNoTarget      = "noTarget"
+	ErrMutability    = "mutability"
+	ErrUniqueness    = "uniqueness"
+	ErrTooMany       = "tooMany"
+)
+
+// Error struct represents a SCIM error response.
+// Schemas: The schemas of the error, which is always the error message schema.
+// Status: The HTTP status code of the error, serialized as a string.
+// ScimType: The SCIM detail error keyword. It is empty for errors without one, such as 404.
+// Detail: The human-readable description of the error.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// Error returns the description of the error.
+func (e *Error) Error() string {
+	if e.ScimType != "" {
+		return fmt.Sprintf("scim %s: %s", e.ScimType, e.Detail)
+	}
+	return "scim: " + e.Detail
+}
+
+// Code returns the HTTP status code of the error.
+func (e *Error) Code() int {
+	var code int
+	if _, err := fmt.Sscan(e.Status, &code); err != nil {
+		return http.StatusInternalServerError
+	}
+	return code
+}
+
+// NewError creates a new SCIM error.
+// status: The HTTP status code of the error.
+// scimType: The SCIM detail error keyword, or an empty string.
+// format: The format of the description, followed by its arguments.
+// Returns the error.
+func NewError(status int, scimType string, format string, args ...interface{}) *Error {
+	return &Error{
+		Schemas:  []string{ErrorSchema},
+		Status:   fmt.Sprint(status),
+		ScimType: scimType,
+		Detail:   fmt.Sprintf(format, args...),
+	}
+}
+
+// BadRequest creates a new SCIM error with the 400 status code.
+// scimType: The SCIM detail error keyword.
+// format: The format of the description, followed by its arguments.
+// Returns the error.
+func BadRequest(scimType string, format string, args ...interface{}) *Error {
+	return NewError(http.StatusBadRequest, scimType, format, args...)
+}
+
+// NotFound creates a new SCIM error with the 404 status code.
+// resourceType: The type of the resource that was not found.
+// id: The ID of the resource that was not found.
+// Returns the error.
+func NotFound(resourceType, id string) *Error {
+	return NewError(http.StatusNotFound, "", "%s %s not found", resourceType, id)
+}
+
+// Conflict creates a new SCIM error with the 409 status code and the uniqueness keyword.
+// format: The format of the description, followed by its arguments.
+// Returns the error.
+func Conflict(format string, args ...interface{}) *Error {
+	return NewError(http.StatusConflict, ErrUniqueness, format, args...)
+}
+
+// PreconditionFailed creates a new SCIM error with the 412 status code, for a modification based on an outdated version.
+// format: The format of the description, followed by its arguments.
+// Returns the error.
+func PreconditionFailed(format string, args ...interface{}) *Error {
+	return NewError(http.StatusPreconditionFailed, "", format, args...)
+}
+
+// Meta struct represents the metadata of a resource.
+// ResourceType: The name of the type of the resource.
+// Created: The time the resource was created.
+// LastModified: The time the resource was last modified.
+// Location: The URI of the resource.
+// Version: The entity tag of the version of the resource, which the ETag header also carries.
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location,omitempty"`
+	Version      string    `json:"version,omitempty"`
+}
+
+// ETag returns the weak entity tag of a version of a resource, as defined in RFC 7644 section 3.14.
+// version: The version of the resource.
+// Returns the entity tag, e.g. W/"3".
+func ETag(version int64) string {
+	return fmt.Sprintf("W/%q", strconv.FormatInt(version, 10))
+}
+
+// ParseIfMatch parses the If-Match header of a request that modifies a resource.
+// The tags are compared weakly, since they only identify the version of the resource.
+// header: The value of the header, or an empty string.
+// Returns the version the client based the modification on, or 0 if the header is missing or is "*",
+// and a SCIM error with the 412 status code if the header holds no version of the resource.
+func ParseIfMatch(header string) (int64, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	tag := strings.TrimPrefix(header, "W/")
+	if unquoted, err := strconv.Unquote(tag); err == nil {
+		if version, err := strconv.ParseInt(unquoted, 10, 64); err == nil && version > 0 {
+			return version, nil
+		}
+	}
+	return 0, PreconditionFailed("the entity tag %s does not match the resource", header)
+}
+
+// ListResponse struct represents the response to a query.
+// Schemas: The schemas of the response, which is always the list response schema.
+// TotalResults: The number of results matched by the query, across every page.
+// StartIndex: The 1-based index of the first result of the page.
+// ItemsPerPage: The number of results of the page.
+// Resources: The results of the page.
+type ListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// NewListResponse creates a new list response for a page of results.
+// resources: The results of the page. It must be a slice.
+// count: The number of results of the page.
+// total: The number of results matched by the query.
+// startIndex: The 1-based index of the first result of the page.
+// Returns the list response.
+func NewListResponse(resources interface{}, count, total, startIndex int) ListResponse {
+	return ListResponse{
+		Schemas:      []string{ListResponseSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: count,
+		Resources:    resources,
+	}
+}
+
+// DefaultCount is the number of results of a page if the client does not ask for a number.
+const DefaultCount = 100
+
+// MaxResults is the largest number of results of a page.
+const MaxResults = 200
+
+// Query struct represents the parameters of a query.
+// Filter: The filter the results must match. It is nil if the query has no filter.
+// StartIndex: The 1-based index of the first result to return.
+// Count: The number of results to return.
+type Query struct {
+	Filter     Filter
+	StartIndex int
+	Count      int
+}
+
+// ParseQuery parses the query parameters of a list request.
+// filter: The filter parameter, or an empty string.
+// startIndex: The startIndex parameter, or an empty string.
+// count: The count parameter, or an empty string.
+// Returns the query and an error if a parameter is not valid.
+func ParseQuery(filter, startIndex, count string) (Query, error) {
+	query := Query{StartIndex: 1, Count: DefaultCount}
+	if filter != "" {
+		f, err := ParseFilter(filter)
+		if err != nil {
+			return Query{}, err
+		}
+		query.Filter = f
+	}
+	if startIndex != "" {
+		if _, err := fmt.Sscan(startIndex, &query.StartIndex); err != nil {
+			return Query{}, BadRequest(ErrInvalidValue, "startIndex must be an integer")
+		}
+		if query.StartIndex < 1 {
+			query.StartIndex = 1
+		}
+	}
+	if count != "" {
+		if _, err := fmt.Sscan(count, &query.Count); err != nil {
+			return Query{}, BadRequest(ErrInvalidValue, "count must be an integer")
+		}
+		if query.Count < 0 {
+			query.Count = 0
+		}
+	}
+	if query.Count > MaxResults {
+		query.Count = MaxResults
+	}
+	return query, nil
+}
+
+// Page applies the filter and the pagination of the query to the resources.
+// resources: The resources to query, as returned by ToMap.
+// Returns the indexes of the resources of the page and the number of resources that matched the filter.
+func (q Query) Page(resources []map[string]interface{}) ([]int, int) {
+	matched := make([]int, 0, len(resources))
+	for i, resource := range resources {
+		if q.Filter == nil || q.Filter.Match(resource) {
+			matched = append(matched, i)
+		}
+	}
+	total := len(matched)
+	start := q.StartIndex - 1
+	if start > total {
+		start = total
+	}
+	end := start + q.Count
+	if end > total {
+		end = total
+	}
+	return matched[start:end], total
+}
+
+// ToMap converts a resource to its generic JSON representation, which filters and PATCH operations work on.
+// resource: The resource to convert.
+// Returns the JSON object of the resource and an error if the resource cannot be serialized.
+func ToMap(resource interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FromMap converts the generic JSON representation of a resource back to the resource.
+// m: The JSON object of the resource.
+// resource: A pointer to the resource to fill.
+// Returns an error with the invalidValue keyword if the object does not fit the resource.
+func FromMap(m map[string]interface{}, resource interface{}) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return BadRequest(ErrInvalidValue, "%v", err)
+	}
+	if err := json.Unmarshal(data, resource); err != nil {
+		return BadRequest(ErrInvalidValue, "%v", err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// Package signature provides the HMAC-SHA256 signatures of the webhooks, in the scheme of the Standard Webhooks specification.
+// A request carries its ID, the time it was signed and the signatures of "id.timestamp.body" in the Webhook-Id,
+// Webhook-Timestamp and Webhook-Signature headers. Since the timestamp is signed, a receiver that rejects the old
+// timestamps and remembers the IDs of the recent requests rejects the replays.
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The headers of a signed request.
+const (
+	HeaderID        = "Webhook-Id"        // The ID of the message, which the retries of the message share.
+	HeaderTimestamp = "Webhook-Timestamp" // The Unix time the request was signed at.
+	HeaderSignature = "Webhook-Signature" // The space separated signatures of the request, one per secret.
+)
+
+// secretPrefix is the prefix of the secrets, which tells them apart from the other credentials.
+const secretPrefix = "whsec_"
+
+// version is the version of the signatures, which prefixes every signature.
+const version = "v1"
+
+var (
+	// ErrMissingHeaders is returned when a request lacks one of the headers of the signature.
+	ErrMissingHeaders = errors.New("missing webhook signature headers")
+	// ErrExpired is returned when the timestamp of a request is outside of the tolerance, e.g. because it is replayed.
+	ErrExpired = errors.New("webhook timestamp outside of the tolerance")
+	// ErrMismatch is returned when no signature of a request matches the secret.
+	ErrMismatch = errors.New("no matching webhook signature")
+	// ErrInvalidSecret is returned when a secret is not a base64 encoded key.
+	ErrInvalidSecret = errors.New("invalid webhook secret")
+)
+
+// NewSecret generates a random secret.
+// Returns the secret, with its whsec_ prefix, and an error if the random source fails.
+func NewSecret() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return secretPrefix + base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Sign signs a request with each of the secrets, so that a receiver may verify it with any of them, e.g. while a secret is rotated.
+// id: The ID of the message.
+// timestamp: The time the request is signed at.
+// body: The body of the request.
+// secrets: The secrets to sign with.
+// Returns the value of the Webhook-Signature header, and an error if a secret is invalid.
+func Sign(id string, timestamp time.Time, body []byte, secrets ...string) (string, error) {
+	signatures := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		mac, err := compute(secret, id, timestamp.Unix(), body)
+		if err != nil {
+			return "", err
+		}
+		signatures = append(signatures, version+","+base64.StdEncoding.EncodeToString(mac))
+	}
+	return strings.Join(signatures, " "), nil
+}
+
+// SetHeaders signs a request and sets its headers.
+// header: The headers of the request.
+// id: The ID of the message.
+// timestamp: The time the request is signed at.
+// body: The body of the request.
+// secrets: The secrets to sign with.
+// Returns an error if a secret is invalid.
+func SetHeaders(header http.Header, id string, timestamp time.Time, body []byte, secrets ...string) error {
+	signatures, err := Sign(id, timestamp, body, secrets...)
+	if err != nil {
+		return err
+	}
+	header.Set(HeaderID, id)
+	header.Set(HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	header.Set(HeaderSignature, signatures)
+	return nil
+}
+
+// Verify checks the signature of a request, for the receivers of the webhooks.
+// header: The headers of the request.
+// body: The body of the request.
+// secret: The secret of the receiver.
+// tolerance: How far the timestamp of the request may be from now.
+// now: The current time.
+// Returns ErrMissingHeaders, ErrExpired or ErrMismatch if the request is not authentic, and an error if the secret is invalid.
+func Verify(header http.Header, body []byte, secret string, tolerance time.Duration, now time.Time) error {
+	id, timestamp, signatures := header.Get(HeaderID), header.Get(HeaderTimestamp), header.Get(HeaderSignature)
+	if id == "" || timestamp == "" || signatures == "" {
+		return ErrMissingHeaders
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMissingHeaders, err)
+	}
+	if delta := now.Sub(time.Unix(unix, 0)); delta > tolerance || delta < -tolerance {
+		return ErrExpired
+	}
+
+	expected, err := compute(secret, id, unix, body)
+	if err != nil {
+		return err
+	}
+	for _, signature := range strings.Fields(signatures) {
+		v, encoded, found := strings.Cut(signature, ",")
+		if !found || v != version {
+			continue
+		}
+		mac, err := base64.StdEncoding.DecodeString(encoded)
+		if err == nil && hmac.Equal(mac, expected) {
+			return nil
+		}
+	}
+	return ErrMismatch
+}
+
+// compute returns the HMAC-SHA256 of "id.timestamp.body" with the key of the secret.
+func compute(secret, id string, timestamp int64, body []byte) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, secretPrefix))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id + "." + strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return mac.Sum(nil), nil
+}
@@ -0,0 +1,53 @@
+package signature
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	secret, err := NewSecret()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, "whsec_"))
+	other, err := NewSecret()
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"user.registered"}`)
+	header := http.Header{}
+	require.NoError(t, SetHeaders(header, "msg_1", now, body, other, secret))
+	assert.Equal(t, "msg_1", header.Get(HeaderID))
+	assert.Equal(t, "1700000000", header.Get(HeaderTimestamp))
+	assert.Len(t, strings.Fields(header.Get(HeaderSignature)), 2, "a request is signed with every secret")
+
+	assert.NoError(t, Verify(header, body, secret, 5*time.Minute, now.Add(time.Minute)))
+	assert.NoError(t, Verify(header, body, other, 5*time.Minute, now), "either secret verifies the request")
+	assert.ErrorIs(t, Verify(header, []byte(`{"type":"user.deleted"}`), secret, 5*time.Minute, now), ErrMismatch)
+	assert.ErrorIs(t, Verify(header, body, secret, 5*time.Minute, now.Add(10*time.Minute)), ErrExpired, "an old request is a replay")
+	assert.ErrorIs(t, Verify(header, body, secret, 5*time.Minute, now.Add(-10*time.Minute)), ErrExpired)
+
+	unknown, err := NewSecret()
+	require.NoError(t, err)
+	assert.ErrorIs(t, Verify(header, body, unknown, 5*time.Minute, now), ErrMismatch)
+
+	replayed := header.Clone()
+	replayed.Set(HeaderTimestamp, "1700000600")
+	assert.ErrorIs(t, Verify(replayed, body, secret, 5*time.Minute, now.Add(10*time.Minute)), ErrMismatch, "the timestamp is signed")
+
+	assert.ErrorIs(t, Verify(http.Header{}, body, secret, 5*time.Minute, now), ErrMissingHeaders)
+	_, err = Sign("msg_1", now, body, "whsec_not base64")
+	assert.ErrorIs(t, err, ErrInvalidSecret)
+}
+
+func TestSignKnownVector(t *testing.T) {
+	// The example of the Standard Webhooks specification.
+	signature, err := Sign("msg_p5jXN8AQM9LWM0D4loKWxJek", time.Unix(1614265330, 0), []byte(`{"test": 2432232314}`), "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw")
+	require.NoError(t, err)
+	assert.Equal(t, "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE=", signature)
+}